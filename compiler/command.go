@@ -0,0 +1,58 @@
+package compiler
+
+import (
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// unquoted matches arguments that every shell reads as they are
+var unquoted = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./\\-]+$`)
+
+// CommandLine formats a prepared compiler command so it can be pasted into a shell to run the
+// compiler exactly as sampctl would: in its working directory, with only its environment and with
+// every argument quoted where needed.
+func CommandLine(cmd *exec.Cmd) string {
+	return commandLine(cmd, runtime.GOOS == "windows")
+}
+
+func commandLine(cmd *exec.Cmd, windows bool) string {
+	quote := quotePOSIX
+	if windows {
+		quote = quoteWindows
+	}
+
+	var parts []string
+	if cmd.Dir != "" {
+		if windows {
+			parts = append(parts, "cd", "/d", quote(cmd.Dir), "&&")
+		} else {
+			parts = append(parts, "cd", quote(cmd.Dir), "&&")
+		}
+	}
+	if !windows && cmd.Env != nil {
+		parts = append(parts, "env", "-i")
+		for _, variable := range cmd.Env {
+			parts = append(parts, quote(variable))
+		}
+	}
+	for _, arg := range cmd.Args {
+		parts = append(parts, quote(arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+func quotePOSIX(arg string) string {
+	if unquoted.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
+
+func quoteWindows(arg string) string {
+	if unquoted.MatchString(arg) {
+		return arg
+	}
+	return `"` + strings.Replace(arg, `"`, `\"`, -1) + `"`
+}
@@ -0,0 +1,26 @@
+package compiler
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_commandLine(t *testing.T) {
+	cmd := exec.Command("/cache/pawn/3.10.8/pawncc", "/my pkg/main.pwn", "-D/my pkg", "-o/my pkg/main.amx", "-d3", "NAME=it's")
+	cmd.Dir = "/my pkg"
+	cmd.Env = []string{"LD_LIBRARY_PATH=/cache/pawn/3.10.8"}
+
+	assert.Equal(t,
+		`cd '/my pkg' && env -i LD_LIBRARY_PATH=/cache/pawn/3.10.8 /cache/pawn/3.10.8/pawncc '/my pkg/main.pwn' '-D/my pkg' '-o/my pkg/main.amx' -d3 'NAME=it'\''s'`,
+		commandLine(cmd, false))
+
+	cmd = exec.Command(`C:\cache\pawn\pawncc.exe`, `C:\my pkg\main.pwn`, `-DC:\my pkg`, "-d3")
+	cmd.Dir = `C:\my pkg`
+	cmd.Env = []string{`LD_LIBRARY_PATH=C:\cache\pawn`}
+
+	assert.Equal(t,
+		`cd /d "C:\my pkg" && C:\cache\pawn\pawncc.exe "C:\my pkg\main.pwn" "-DC:\my pkg" -d3`,
+		commandLine(cmd, true))
+}
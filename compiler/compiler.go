@@ -44,14 +44,18 @@ var (
 
 // CompileSource compiles a given input script to the specified output path using compiler version
 func CompileSource(ctx context.Context, gh *github.Client, execDir, errorDir, cacheDir, platform string, config types.BuildConfig, relative bool) (problems types.BuildProblems, result types.BuildResult, err error) {
-	print.Info("Compiling", config.Input, "with compiler version", config.Version)
+	if config.Compiler != "" {
+		print.Info("Compiling", config.Input, "with compiler", config.Compiler)
+	} else {
+		print.Info("Compiling", config.Input, "with compiler version", config.Version)
+	}
 
 	cmd, err := PrepareCommand(ctx, gh, execDir, cacheDir, platform, config)
 	if err != nil {
 		return
 	}
 
-	err = RunPlugins(context.Background(), config, os.Stdout) // todo: context propagation
+	err = RunPlugins(ctx, config, os.Stdout)
 	if err != nil {
 		return
 	}
@@ -80,11 +84,56 @@ func PrepareCommand(ctx context.Context, gh *github.Client, execDir, cacheDir, p
 	} else {
 		config.WorkingDir = util.FullPath(config.WorkingDir)
 	}
+	if !util.Exists(config.WorkingDir) {
+		err = errors.Errorf("no such working directory '%s'", config.WorkingDir)
+		return
+	}
 
-	runtimeDir := filepath.Join(cacheDir, "pawn", string(config.Version))
-	pkg, err := GetCompilerPackage(ctx, gh, config.Version, runtimeDir, platform, cacheDir)
+	// a build with coverage markers is compiled from instrumented copies of the sources in its
+	// working directory, the include paths into it point at the copies too
+	if config.CoverageMarkers {
+		var sources string
+		sources, err = InstrumentCoverage(config.WorkingDir, cacheDir, output)
+		if err != nil {
+			return
+		}
+		print.Verb("compiling instrumented sources from", sources)
+		input = instrumentedPath(config.WorkingDir, sources, input)
+		includes := make([]string, len(config.Includes))
+		for i, inc := range config.Includes {
+			if !filepath.IsAbs(inc) {
+				inc = filepath.Join(execDir, inc)
+			}
+			includes[i] = instrumentedPath(config.WorkingDir, sources, inc)
+		}
+		config.Includes = includes
+		config.WorkingDir = sources
+	} else {
+		err = removeCoverageManifest(output)
+		if err != nil {
+			err = errors.Wrap(err, "failed to remove coverage manifest of previous build")
+			return
+		}
+	}
+
+	// a compiler provided by a package resource is already installed, otherwise download the version
+	var runtimeDir, binary string
+	if config.CompilerPath != "" {
+		runtimeDir = filepath.Dir(config.CompilerPath)
+		binary = config.CompilerPath
+	} else {
+		runtimeDir = filepath.Join(cacheDir, "pawn", string(config.Version))
+		var pkg types.Compiler
+		pkg, err = GetCompilerPackage(ctx, gh, config.Version, runtimeDir, platform, cacheDir)
+		if err != nil {
+			err = errors.Wrap(err, "failed to get compiler package")
+			return
+		}
+		binary = filepath.Join(runtimeDir, pkg.Binary)
+	}
+
+	options, err := OptionArgs(config)
 	if err != nil {
-		err = errors.Wrap(err, "failed to get compiler package")
 		return
 	}
 
@@ -93,7 +142,13 @@ func PrepareCommand(ctx context.Context, gh *github.Client, execDir, cacheDir, p
 		"-D" + config.WorkingDir,
 		"-o" + output,
 	}
-	args = append(args, config.Args...)
+	args = append(args, options...)
+
+	prefix, err := prefixArgs(execDir, cacheDir, config)
+	if err != nil {
+		return
+	}
+	args = append(args, prefix...)
 
 	includePaths := make(map[string]struct{})
 	includeFiles := make(map[string]string)
@@ -162,15 +217,94 @@ func PrepareCommand(ctx context.Context, gh *github.Client, execDir, cacheDir, p
 		}
 	}
 
-	cmd = exec.CommandContext(ctx, filepath.Join(runtimeDir, pkg.Binary), args...) //nolint:gas
+	// the compiler runs in its working directory as well as being told about it with -D so anything
+	// it resolves relative to the process, rather than the active directory, resolves the same way
+	cmd = exec.CommandContext(ctx, binary, args...) //nolint:gas
+	cmd.Dir = config.WorkingDir
 	cmd.Env = []string{
 		fmt.Sprintf("LD_LIBRARY_PATH=%s", runtimeDir),
 		fmt.Sprintf("DYLD_LIBRARY_PATH=%s", runtimeDir),
 	}
+	cmd.Env = append(cmd.Env, util.TempDirEnv()...)
 
 	return
 }
 
+// prefixArgs returns the compiler arguments that include the coverage markers and force-includes of
+// a build ahead of the input script
+func prefixArgs(execDir, cacheDir string, config types.BuildConfig) (args []string, err error) {
+	var prefix []string
+	if config.CoverageMarkers {
+		var coverageDir string
+		coverageDir, err = PrepareCoverageMarkers(cacheDir)
+		if err != nil {
+			return
+		}
+		print.Verb("including coverage markers", CoverageInclude, "from", coverageDir)
+		args = append(args, "-i"+coverageDir)
+		prefix = append(prefix, CoverageInclude)
+	}
+	prefix = append(prefix, config.ForceIncludes...)
+
+	// the prefix file is implicitly included before the input script, there can only be one so
+	// force-includes are gathered into a generated one
+	if len(config.ForceIncludes) > 0 {
+		var prefixDir string
+		prefixDir, err = PreparePrefix(execDir, cacheDir, prefix)
+		if err != nil {
+			return
+		}
+		print.Verb("force-including", prefix, "from", prefixDir)
+		args = append(args, "-i"+prefixDir, "-p"+PrefixInclude)
+	} else if len(prefix) > 0 {
+		args = append(args, "-p"+prefix[0])
+	}
+	return
+}
+
+// OptionArgs returns the arguments of a build config with the flags for its typed options, such as
+// the debug level, in place of any of the same flags in the raw arguments.
+func OptionArgs(config types.BuildConfig) (args []string, err error) {
+	var (
+		replace []string
+		options []string
+	)
+	if config.Debug != nil {
+		if *config.Debug < 0 || *config.Debug > 3 {
+			return nil, errors.Errorf("debug level must be between 0 and 3, not %d", *config.Debug)
+		}
+		replace = append(replace, "-d")
+		options = append(options, fmt.Sprintf("-d%d", *config.Debug))
+	}
+	if config.Optimization != nil {
+		if *config.Optimization < 0 || *config.Optimization > 2 {
+			return nil, errors.Errorf("optimization level must be between 0 and 2, not %d", *config.Optimization)
+		}
+		replace = append(replace, "-O")
+		options = append(options, fmt.Sprintf("-O%d", *config.Optimization))
+	}
+	if config.Compress != nil {
+		replace = append(replace, "-C")
+		if *config.Compress {
+			options = append(options, "-C+")
+		} else {
+			options = append(options, "-C-")
+		}
+	}
+
+outer:
+	for _, arg := range config.Args {
+		for _, prefix := range replace {
+			if strings.HasPrefix(arg, prefix) {
+				print.Verb("build option replaces argument", arg)
+				continue outer
+			}
+		}
+		args = append(args, arg)
+	}
+	return append(args, options...), nil
+}
+
 // CompileWithCommand takes a prepared command and executes it
 func CompileWithCommand(cmd *exec.Cmd, workingDir, errorDir string, relative bool) (problems types.BuildProblems, result types.BuildResult, err error) {
 	var (
@@ -207,7 +341,7 @@ func CompileWithCommand(cmd *exec.Cmd, workingDir, errorDir string, relative boo
 				if string(filepath.Separator) != `\` {
 					problem.File = strings.Replace(problem.File, "\\", "/", -1)
 				}
-				problem.File = filepath.Clean(problem.File)
+				problem.File = originalSource(filepath.Clean(problem.File))
 				if relative {
 					rel, errInner := filepath.Rel(errorDir, problem.File)
 					if errInner == nil {
@@ -277,7 +411,10 @@ func CompileWithCommand(cmd *exec.Cmd, workingDir, errorDir string, relative boo
 	}
 
 	for problem := range problemChan {
-		fmt.Println(problem)
+		// in quiet mode only problems that fail the build are shown
+		if !print.IsQuiet() || problem.Severity != types.ProblemWarning {
+			fmt.Println(problem)
+		}
 		problems = append(problems, problem)
 	}
 
@@ -180,3 +180,38 @@ func TestCompileSource(t *testing.T) {
 		})
 	}
 }
+
+func TestOptionArgs(t *testing.T) {
+	level := func(n int) *int { return &n }
+	enabled := func(b bool) *bool { return &b }
+
+	tests := []struct {
+		name     string
+		config   types.BuildConfig
+		wantArgs []string
+		wantErr  bool
+	}{
+		{"none", types.BuildConfig{Args: []string{"-d3", "-;+"}}, []string{"-d3", "-;+"}, false},
+		{"debug replaces arg", types.BuildConfig{Args: []string{"-d3", "-;+"}, Debug: level(0)}, []string{"-;+", "-d0"}, false},
+		{"all", types.BuildConfig{
+			Args:         []string{"-d3", "-O1", "-C-", "-Dignored", "-Z+"},
+			Debug:        level(2),
+			Optimization: level(2),
+			Compress:     enabled(true),
+		}, []string{"-Dignored", "-Z+", "-d2", "-O2", "-C+"}, false},
+		{"no compression", types.BuildConfig{Compress: enabled(false)}, []string{"-C-"}, false},
+		{"debug out of range", types.BuildConfig{Debug: level(4)}, nil, true},
+		{"optimization out of range", types.BuildConfig{Optimization: level(-1)}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := OptionArgs(tt.config)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
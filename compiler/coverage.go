@@ -0,0 +1,413 @@
+package compiler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+// CoverageInclude is the name of the include file that is forced into builds with coverage markers
+const CoverageInclude = "sampctl_coverage"
+
+const coverageIncTemplate = `// This file was generated by sampctl for builds with coverage markers
+// DO NOT EDIT THIS FILE MANUALLY!
+
+#if defined _sampctl_coverage_included
+	#endinput
+#endif
+#define _sampctl_coverage_included
+
+#define SAMPCTL_COVERAGE
+
+// COVERAGE_HIT(name) records a hit of name, the runtime counts these into a report once the server
+// stops. Every function defined in the sources of the build starts with one, it can also be placed
+// by hand to measure anything else.
+#define COVERAGE_HIT(%0) print("*** Coverage: " #%0)
+`
+
+// PrepareCoverageMarkers writes the include that defines the `COVERAGE_HIT` marker to a directory
+// inside the cache and returns the include path that must be passed to the compiler.
+func PrepareCoverageMarkers(cacheDir string) (dir string, err error) {
+	dir = filepath.Join(cacheDir, "coverage")
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		err = errors.Wrap(err, "failed to create coverage markers directory")
+		return
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, CoverageInclude+".inc"), []byte(coverageIncTemplate), 0600)
+	if err != nil {
+		err = errors.Wrap(err, "failed to write coverage markers include")
+		return
+	}
+
+	return
+}
+
+var (
+	// stock Float:GetDistance(
+	matchFunctionHeader = regexp.MustCompile(`^(?:(?:static|stock|public)\s+)*(?:[A-Za-z_@][\w@]*:)?([A-Za-z_@][\w@]*)\s*\(`)
+
+	// the keywords a statement can start with that look like a function header
+	functionKeywords = map[string]bool{
+		"if": true, "else": true, "for": true, "while": true, "do": true, "switch": true, "case": true,
+		"return": true, "sizeof": true, "tagof": true, "defined": true, "state": true, "assert": true,
+		"native": true, "forward": true, "new": true, "enum": true, "const": true, "operator": true,
+	}
+)
+
+// coverageSourcesMarker is written into the root of instrumented sources and holds the directory
+// they were instrumented from, so problems in them are reported against the original files
+const coverageSourcesMarker = ".sampctl-source"
+
+// InstrumentCoverage copies the source files under root, which is the working directory of a build,
+// to a directory inside the cache with a `COVERAGE_HIT` marker at the start of every function they
+// define and returns that directory. The functions are listed in the coverage manifest of the
+// output. Dependencies and hidden directories are left out, they're compiled from where they are.
+// The markers are inserted on the same line as the opening brace so line numbers don't change.
+func InstrumentCoverage(root, cacheDir, output string) (dir string, err error) {
+	sum := sha256.Sum256([]byte(root))
+	dir = filepath.Join(cacheDir, "coverage", "sources", hex.EncodeToString(sum[:8]))
+
+	err = os.RemoveAll(dir)
+	if err != nil {
+		err = errors.Wrap(err, "failed to remove previously instrumented sources")
+		return
+	}
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		err = errors.Wrap(err, "failed to create instrumented sources directory")
+		return
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, coverageSourcesMarker), []byte(root), 0600)
+	if err != nil {
+		err = errors.Wrap(err, "failed to mark instrumented sources")
+		return
+	}
+
+	functions := []types.CoverageFunction{}
+	err = filepath.Walk(root, func(path string, info os.FileInfo, errWalk error) error {
+		if errWalk != nil {
+			return errWalk
+		}
+		rel, errWalk := filepath.Rel(root, path)
+		if errWalk != nil {
+			return errWalk
+		}
+		if info.IsDir() {
+			if path != root && !instrumented(rel) {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(filepath.Join(dir, rel), 0700)
+		}
+		switch filepath.Ext(path) {
+		case ".pwn", ".inc", ".p", ".pawn":
+		default:
+			return nil
+		}
+
+		contents, errWalk := ioutil.ReadFile(path)
+		if errWalk != nil {
+			return errWalk
+		}
+		contents, found := instrumentSource(contents)
+		for _, function := range found {
+			function.File = filepath.ToSlash(rel)
+			functions = append(functions, function)
+		}
+		return ioutil.WriteFile(filepath.Join(dir, rel), contents, 0600)
+	})
+	if err != nil {
+		err = errors.Wrap(err, "failed to instrument sources")
+		return
+	}
+
+	contents, err := json.MarshalIndent(functions, "", "\t")
+	if err != nil {
+		return
+	}
+	err = ioutil.WriteFile(CoverageManifest(output), contents, 0600)
+	if err != nil {
+		err = errors.Wrap(err, "failed to write coverage manifest")
+	}
+	return
+}
+
+// instrumented is false for the directories under the working directory of a build that aren't
+// instrumented, given relative to it
+func instrumented(rel string) bool {
+	top := strings.Split(filepath.ToSlash(rel), "/")[0]
+	return top == "." || !strings.HasPrefix(top, ".") && top != "dependencies"
+}
+
+// instrumentedPath returns where a file or directory under the working directory of a build is
+// in its instrumented sources, or the path itself if it isn't instrumented
+func instrumentedPath(root, dir, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || !instrumented(rel) {
+		return path
+	}
+	return filepath.Join(dir, rel)
+}
+
+// originalSource returns the file that an instrumented source was copied from, or the file itself
+// if it isn't one
+func originalSource(file string) string {
+	const sources = "/coverage/sources/"
+	slashed := filepath.ToSlash(file)
+	i := strings.Index(slashed, sources)
+	if i == -1 {
+		return file
+	}
+	parts := strings.SplitN(slashed[i+len(sources):], "/", 2)
+	if len(parts) != 2 {
+		return file
+	}
+	root, err := ioutil.ReadFile(filepath.Join(filepath.FromSlash(slashed[:i+len(sources)]+parts[0]), coverageSourcesMarker))
+	if err != nil {
+		return file
+	}
+	return filepath.Join(string(root), filepath.FromSlash(parts[1]))
+}
+
+// CoverageManifest is where the functions a build with coverage markers instrumented are listed,
+// next to its output
+func CoverageManifest(output string) string {
+	return strings.TrimSuffix(output, filepath.Ext(output)) + ".coverage.json"
+}
+
+// ReadCoverageManifest reads the functions the output of a build was instrumented with, there are
+// none if it wasn't built with coverage markers
+func ReadCoverageManifest(output string) (functions []types.CoverageFunction, err error) {
+	contents, err := ioutil.ReadFile(CoverageManifest(output))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	err = json.Unmarshal(contents, &functions)
+	if err != nil {
+		err = errors.Wrap(err, "failed to read coverage manifest")
+	}
+	return
+}
+
+// removeCoverageManifest removes the coverage manifest of an output that is built without coverage
+// markers, so the functions of an earlier build aren't reported for it
+func removeCoverageManifest(output string) error {
+	manifest := CoverageManifest(output)
+	if !util.Exists(manifest) {
+		return nil
+	}
+	return os.Remove(manifest)
+}
+
+// instrumentSource inserts a `COVERAGE_HIT` marker after the opening brace of every function that
+// is defined in a source file and returns the functions with their name and line. Like the collision
+// detector this isn't a full Pawn parser: a function is a header at the start of a line outside of
+// any braces followed by a body, with comments, strings and preprocessor directives ignored.
+// Functions that already contain a marker with their name are left as they are.
+func instrumentSource(src []byte) (result []byte, functions []types.CoverageFunction) {
+	code := codeOnly(src)
+
+	var (
+		insertions []int
+		depth      = 0
+		line       = 1
+		lineStart  = true
+	)
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		switch c {
+		case '\n':
+			line++
+			lineStart = true
+			continue
+		case ' ', '\t', '\r':
+			continue
+		}
+		if lineStart && depth == 0 {
+			end := bytes.IndexByte(code[i:], '\n')
+			if end == -1 {
+				end = len(code) - i
+			}
+			if match := matchFunctionHeader.FindSubmatchIndex(code[i : i+end]); match != nil {
+				name := string(code[i+match[2] : i+match[3]])
+				body := functionBody(code, i+match[1]-1)
+				if body != -1 && !functionKeywords[name] && !marked(code[body:blockEnd(code, body)], name) {
+					insertions = append(insertions, body+1)
+					functions = append(functions, types.CoverageFunction{Name: name, Line: line})
+				}
+			}
+		}
+		lineStart = false
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for n, at := range insertions {
+		out.Write(src[last:at])
+		fmt.Fprintf(&out, " COVERAGE_HIT(%s);", functions[n].Name)
+		last = at
+	}
+	out.Write(src[last:])
+	return out.Bytes(), functions
+}
+
+// functionBody returns the index of the brace that opens the body of a function whose parameters
+// open at `open`, or -1 if it's a declaration or not a function at all. The parameters may have
+// default values in braces and the body may follow a state specifier such as `<auto:a>`.
+func functionBody(code []byte, open int) int {
+	depth := 0
+	i := open
+	for ; i < len(code); i++ {
+		if code[i] == '(' {
+			depth++
+		} else if code[i] == ')' {
+			depth--
+			if depth == 0 {
+				break
+			}
+		} else if code[i] == ';' {
+			return -1
+		}
+	}
+	i = skipSpace(code, i+1)
+	if i < len(code) && code[i] == '<' {
+		end := bytes.IndexAny(code[i:], ">;{")
+		if end == -1 || code[i+end] != '>' {
+			return -1
+		}
+		i = skipSpace(code, i+end+1)
+	}
+	if i < len(code) && code[i] == '{' {
+		return i
+	}
+	return -1
+}
+
+// marked is true if a function body already has a marker with the name of the function
+func marked(body []byte, name string) bool {
+	return regexp.MustCompile(`COVERAGE_HIT\(\s*` + regexp.QuoteMeta(name) + `\s*\)`).Match(body)
+}
+
+// blockEnd returns the index after the brace that closes the one at `open`
+func blockEnd(code []byte, open int) int {
+	depth := 0
+	for i := open; i < len(code); i++ {
+		if code[i] == '{' {
+			depth++
+		} else if code[i] == '}' {
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(code)
+}
+
+func skipSpace(code []byte, i int) int {
+	for i < len(code) && (code[i] == ' ' || code[i] == '\t' || code[i] == '\r' || code[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+// codeOnly returns a copy of a source file with its comments, string and character literals and
+// preprocessor directives replaced by spaces, so any braces and parentheses left are code. Line
+// breaks are kept so positions and line numbers are the same as in the source.
+func codeOnly(src []byte) []byte {
+	code := make([]byte, len(src))
+	copy(code, src)
+	blank := func(from, to int) {
+		for j := from; j < to && j < len(code); j++ {
+			if code[j] != '\n' {
+				code[j] = ' '
+			}
+		}
+	}
+
+	lineStart := true
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		switch {
+		case c == '\n':
+			lineStart = true
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			continue
+
+		case lineStart && c == '#':
+			// a directive ends at the end of the line unless it's continued with a backslash
+			end := i
+			for end < len(code) {
+				next := bytes.IndexByte(code[end:], '\n')
+				if next == -1 {
+					end = len(code)
+					break
+				}
+				end += next
+				if !bytes.HasSuffix(bytes.TrimRight(code[i:end], "\r"), []byte("\\")) {
+					break
+				}
+				end++
+			}
+			blank(i, end)
+			i = end - 1
+
+		case c == '/' && i+1 < len(code) && code[i+1] == '/':
+			end := bytes.IndexByte(code[i:], '\n')
+			if end == -1 {
+				end = len(code) - i
+			}
+			blank(i, i+end)
+			i += end - 1
+
+		case c == '/' && i+1 < len(code) && code[i+1] == '*':
+			end := bytes.Index(code[i+2:], []byte("*/"))
+			if end == -1 {
+				end = len(code)
+			} else {
+				end += i + 4
+			}
+			blank(i, end)
+			i = end - 1
+
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(code) && code[end] != c && code[end] != '\n' {
+				if code[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			blank(i, end+1)
+			i = end
+		}
+		lineStart = false
+	}
+	return code
+}
@@ -0,0 +1,160 @@
+package compiler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestPrepareCoverageMarkers(t *testing.T) {
+	cacheDir := util.FullPath("./tests/coverage")
+	os.RemoveAll(cacheDir)
+
+	dir, err := PrepareCoverageMarkers(cacheDir)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(cacheDir, "coverage"), dir)
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, CoverageInclude+".inc"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(contents), "#define SAMPCTL_COVERAGE\n")
+	assert.Contains(t, string(contents), "#define COVERAGE_HIT(%0) print(\"*** Coverage: \" #%0)\n")
+
+	// preparing it again for the next build overwrites it
+	again, err := PrepareCoverageMarkers(cacheDir)
+	assert.NoError(t, err)
+	assert.Equal(t, dir, again)
+}
+
+func Test_instrumentSource(t *testing.T) {
+	src := `#include <a_samp>
+#define MACRO(%0) Fake(%0) {}
+
+// Commented(x) {
+main() {
+	print("Quoted() {");
+}
+
+forward OnThing(playerid);
+public OnThing(playerid)
+{
+	if (playerid) {
+		Helper(playerid);
+	}
+	return 1;
+}
+
+static stock Float:Helper(value, const arr[] = {1, 2}) { return float(value); }
+
+Stated() <auto:a> {}
+
+native Declared(value);
+
+Marked() {
+	COVERAGE_HIT(Marked);
+}
+
+Branched(value) {
+	if (value) {
+		COVERAGE_HIT(Branched_Value);
+	}
+}
+/* Block() {
+} */
+`
+	result, functions := instrumentSource([]byte(src))
+	assert.Equal(t, []types.CoverageFunction{
+		{Name: "main", Line: 5},
+		{Name: "OnThing", Line: 10},
+		{Name: "Helper", Line: 18},
+		{Name: "Stated", Line: 20},
+		{Name: "Branched", Line: 28},
+	}, functions)
+	assert.Equal(t, `#include <a_samp>
+#define MACRO(%0) Fake(%0) {}
+
+// Commented(x) {
+main() { COVERAGE_HIT(main);
+	print("Quoted() {");
+}
+
+forward OnThing(playerid);
+public OnThing(playerid)
+{ COVERAGE_HIT(OnThing);
+	if (playerid) {
+		Helper(playerid);
+	}
+	return 1;
+}
+
+static stock Float:Helper(value, const arr[] = {1, 2}) { COVERAGE_HIT(Helper); return float(value); }
+
+Stated() <auto:a> { COVERAGE_HIT(Stated);}
+
+native Declared(value);
+
+Marked() {
+	COVERAGE_HIT(Marked);
+}
+
+Branched(value) { COVERAGE_HIT(Branched);
+	if (value) {
+		COVERAGE_HIT(Branched_Value);
+	}
+}
+/* Block() {
+} */
+`, string(result))
+}
+
+func TestInstrumentCoverage(t *testing.T) {
+	dir := util.FullPath("./tests/coverage-instrument")
+	os.RemoveAll(dir)
+	root := filepath.Join(dir, "package")
+	cacheDir := filepath.Join(dir, "cache")
+	write := func(name, contents string) {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(root, name), []byte(contents), 0600))
+	}
+	write("test.pwn", "#include \"lib\"\nmain() {}\n")
+	write("lib.inc", "stock Lib() {}\n")
+	write("dependencies/dep/dep.inc", "stock Dep() {}\n")
+	write(".git/hooks.inc", "stock Hook() {}\n")
+	output := filepath.Join(root, "test.amx")
+
+	sources, err := InstrumentCoverage(root, cacheDir, output)
+	assert.NoError(t, err)
+	contents, err := ioutil.ReadFile(filepath.Join(sources, "test.pwn"))
+	assert.NoError(t, err)
+	assert.Equal(t, "#include \"lib\"\nmain() { COVERAGE_HIT(main);}\n", string(contents))
+	assert.True(t, util.Exists(filepath.Join(sources, "lib.inc")))
+	assert.False(t, util.Exists(filepath.Join(sources, "dependencies")))
+	assert.False(t, util.Exists(filepath.Join(sources, ".git")))
+
+	functions, err := ReadCoverageManifest(output)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.CoverageFunction{
+		{Name: "Lib", File: "lib.inc", Line: 1},
+		{Name: "main", File: "test.pwn", Line: 2},
+	}, functions)
+
+	// paths are mapped to the instrumented sources and back
+	assert.Equal(t, filepath.Join(sources, "test.pwn"), instrumentedPath(root, sources, filepath.Join(root, "test.pwn")))
+	assert.Equal(t, sources, instrumentedPath(root, sources, root))
+	dep := filepath.Join(root, "dependencies", "dep")
+	assert.Equal(t, dep, instrumentedPath(root, sources, dep))
+	assert.Equal(t, dir, instrumentedPath(root, sources, dir))
+	assert.Equal(t, filepath.Join(root, "lib.inc"), originalSource(filepath.Join(sources, "lib.inc")))
+	assert.Equal(t, filepath.Join(root, "lib.inc"), originalSource(filepath.Join(root, "lib.inc")))
+
+	// a build without coverage markers doesn't keep the manifest of an earlier one
+	assert.NoError(t, removeCoverageManifest(output))
+	functions, err = ReadCoverageManifest(output)
+	assert.NoError(t, err)
+	assert.Empty(t, functions)
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sync"
 
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
@@ -59,9 +61,34 @@ func FromNet(ctx context.Context, gh *github.Client, meta versioning.DependencyM
 		}
 	}
 
-	path, _, err := download.ReleaseAssetByPattern(ctx, gh, meta, regexp.MustCompile(compiler.Match), "", GetCompilerFilename(meta.Tag, platform, compiler.Method), cacheDir)
-	if err != nil {
-		return
+	var (
+		filename = GetCompilerFilename(meta.Tag, platform, compiler.Method)
+		path     string
+	)
+	if len(sources.Mirrors) > 0 {
+		path, err = fromMirrors(ctx, filename, meta.Tag, platform, cacheDir)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			print.Warn(err, "- falling back to GitHub release")
+		}
+	}
+	if path == "" {
+		err = retry(ctx, func() (errInner error) {
+			path, _, errInner = download.ReleaseAssetByPattern(ctx, gh, meta, regexp.MustCompile(compiler.Match), "", filename, cacheDir)
+			if errInner != nil {
+				return
+			}
+			errInner = verifyConfiguredChecksum(path, filename)
+			if errInner != nil {
+				os.Remove(path) // nolint
+			}
+			return
+		})
+		if err != nil {
+			return
+		}
 	}
 
 	method := download.ExtractFuncFromName(compiler.Method)
@@ -79,7 +106,46 @@ func FromNet(ctx context.Context, gh *github.Client, meta versioning.DependencyM
 	return
 }
 
-// GetCompilerPackage downloads and installs a Pawn compiler to a user directory
+var (
+	installLocksMu sync.Mutex
+	installLocks   = make(map[string]chan struct{})
+)
+
+// lockInstall serialises installs of a compiler that share any of the given keys, such as the
+// directory it's extracted to or the cached download, so concurrent builds don't write over each
+// other. Keys are locked in the order given, the returned function releases all of them. Waiting
+// for a lock gives up if the context is cancelled, releasing those already held.
+func lockInstall(ctx context.Context, keys ...string) (unlock func(), err error) {
+	var held []chan struct{}
+	unlock = func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			<-held[i]
+		}
+	}
+
+	for _, key := range keys {
+		installLocksMu.Lock()
+		lock, ok := installLocks[key]
+		if !ok {
+			lock = make(chan struct{}, 1)
+			installLocks[key] = lock
+		}
+		installLocksMu.Unlock()
+
+		select {
+		case lock <- struct{}{}:
+			held = append(held, lock)
+		case <-ctx.Done():
+			unlock()
+			return nil, errors.Wrap(ctx.Err(), "cancelled while waiting for another compiler install")
+		}
+	}
+	return unlock, nil
+}
+
+// GetCompilerPackage downloads and installs a Pawn compiler to a user directory. It is safe to call
+// concurrently, only one caller downloads a version to the cache or installs a compiler to a
+// directory at a time and the others wait for it then use the cached copy.
 func GetCompilerPackage(ctx context.Context, gh *github.Client, version types.CompilerVersion, dir, platform, cacheDir string) (compiler types.Compiler, err error) {
 	meta := versioning.DependencyMeta{
 		Site: "github.com",
@@ -94,6 +160,16 @@ func GetCompilerPackage(ctx context.Context, gh *github.Client, version types.Co
 		meta.Tag = "v" + meta.Tag
 	}
 
+	// the cached download of a version is shared by every directory it's installed to, so it's locked
+	// as well as the directory, always first so two installs can't each wait for the other
+	unlock, err := lockInstall(ctx,
+		filepath.Join(util.FullPath(cacheDir), "pawnc-"+meta.Tag+"-"+platform),
+		util.FullPath(dir))
+	if err != nil {
+		return
+	}
+	defer unlock()
+
 	compiler, hit, err := FromCache(meta, dir, platform, cacheDir)
 	if err != nil {
 		err = errors.Wrapf(err, "failed to get package %s from cache", version)
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -90,3 +91,41 @@ func Test_CompilerFromCache(t *testing.T) {
 		})
 	}
 }
+
+func Test_lockInstall(t *testing.T) {
+	unlock, err := lockInstall(context.Background(), "tests/lock")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = lockInstall(ctx, "tests/lock")
+	assert.Error(t, err, "lock should be held until released")
+
+	other, err := lockInstall(context.Background(), "tests/other")
+	assert.NoError(t, err, "locks for other directories are independent")
+	other()
+
+	// every key is locked, and those already held are released when waiting for the others gives up
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = lockInstall(ctx, "tests/other", "tests/lock")
+	assert.Error(t, err, "lock should be held until released")
+	other, err = lockInstall(context.Background(), "tests/other")
+	assert.NoError(t, err, "locks held while waiting are released")
+	other()
+
+	acquired := make(chan struct{})
+	go func() {
+		next, errInner := lockInstall(context.Background(), "tests/lock")
+		assert.NoError(t, errInner)
+		next()
+		close(acquired)
+	}()
+
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("waiting caller did not acquire the released lock")
+	}
+}
@@ -0,0 +1,72 @@
+package compiler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/util"
+)
+
+// PrefixInclude is the name of the include file that lists the force-includes of a build, the
+// compiler only accepts a single prefix file so they are all included from this one
+const PrefixInclude = "sampctl_prefix"
+
+// PreparePrefix writes a prefix include that includes each of the given files in order to a
+// directory inside the cache and returns the include path that must be passed to the compiler. A
+// file that exists relative to execDir is included by its path, anything else is included by name
+// from the include paths like `#include <name>`.
+func PreparePrefix(execDir, cacheDir string, includes []string) (dir string, err error) {
+	var contents bytes.Buffer
+	fmt.Fprintln(&contents, "// This file was generated by sampctl for the force-includes of a build")
+	fmt.Fprintln(&contents, "// DO NOT EDIT THIS FILE MANUALLY!")
+	fmt.Fprintln(&contents)
+	for _, include := range includes {
+		fmt.Fprintln(&contents, "#include", prefixDirective(execDir, include))
+	}
+
+	// the directory is named after the contents so builds with different force-includes don't
+	// overwrite each other's prefix while they run
+	sum := sha256.Sum256(contents.Bytes())
+	dir = filepath.Join(cacheDir, "prefix", hex.EncodeToString(sum[:8]))
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		err = errors.Wrap(err, "failed to create prefix directory")
+		return
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, PrefixInclude+".inc"), contents.Bytes(), 0600)
+	if err != nil {
+		err = errors.Wrap(err, "failed to write prefix include")
+		return
+	}
+
+	return
+}
+
+// ForceIncludeFile returns the path of a force-include that is a file relative to execDir, or an
+// empty string if it's the name of an include that is found through the include paths
+func ForceIncludeFile(execDir, include string) string {
+	path := include
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(execDir, include)
+	}
+	if !util.Exists(path) {
+		return ""
+	}
+	return path
+}
+
+func prefixDirective(execDir, include string) string {
+	if path := ForceIncludeFile(execDir, include); path != "" {
+		return fmt.Sprintf("\"%s\"", filepath.ToSlash(path))
+	}
+	return fmt.Sprintf("<%s>", include)
+}
@@ -0,0 +1,67 @@
+package compiler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestPreparePrefix(t *testing.T) {
+	dir := util.FullPath("./tests/prefix")
+	os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "config"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "config", "settings.inc"), []byte("#define MAX_THINGS 10\n"), 0600))
+	cacheDir := filepath.Join(dir, "cache")
+
+	prefixDir, err := PreparePrefix(dir, cacheDir, []string{CoverageInclude, "config/settings.inc", "a_samp"})
+	assert.NoError(t, err)
+	contents, err := ioutil.ReadFile(filepath.Join(prefixDir, PrefixInclude+".inc"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(contents), "#include <"+CoverageInclude+">\n#include \""+filepath.ToSlash(filepath.Join(dir, "config", "settings.inc"))+"\"\n#include <a_samp>\n")
+
+	// the same force-includes share a prefix and different ones don't
+	again, err := PreparePrefix(dir, cacheDir, []string{CoverageInclude, "config/settings.inc", "a_samp"})
+	assert.NoError(t, err)
+	assert.Equal(t, prefixDir, again)
+	other, err := PreparePrefix(dir, cacheDir, []string{"a_samp"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, prefixDir, other)
+}
+
+func Test_prefixArgs(t *testing.T) {
+	dir := util.FullPath("./tests/prefix-args")
+	os.RemoveAll(dir)
+	cacheDir := filepath.Join(dir, "cache")
+	coverageDir := filepath.Join(cacheDir, "coverage")
+	prefixDir := func(prefix ...string) string {
+		prefixDir, err := PreparePrefix(dir, cacheDir, prefix)
+		assert.NoError(t, err)
+		return prefixDir
+	}
+
+	tests := []struct {
+		name   string
+		config types.BuildConfig
+		want   []string
+	}{
+		{"none", types.BuildConfig{}, nil},
+		{"coverage markers", types.BuildConfig{CoverageMarkers: true}, []string{"-i" + coverageDir, "-p" + CoverageInclude}},
+		{"force-includes", types.BuildConfig{ForceIncludes: []string{"a_samp"}}, []string{"-i" + prefixDir("a_samp"), "-p" + PrefixInclude}},
+		{"both", types.BuildConfig{CoverageMarkers: true, ForceIncludes: []string{"a_samp"}}, []string{
+			"-i" + coverageDir, "-i" + prefixDir(CoverageInclude, "a_samp"), "-p" + PrefixInclude,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := prefixArgs(dir, cacheDir, tt.config)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, args)
+		})
+	}
+}
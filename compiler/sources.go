@@ -0,0 +1,177 @@
+package compiler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+)
+
+// Sources controls where compiler packages are downloaded from. Mirrors are tried in order before
+// the GitHub release, which makes it possible to use a self-hosted copy when GitHub is unreachable.
+// A mirror is populated by copying the compiler packages from the sampctl cache directory.
+type Sources struct {
+	// URLs of mirrors, `{file}`, `{version}` and `{platform}` are replaced with the cached package
+	// file name, the version tag and the platform. If none are present, the file name is appended.
+	Mirrors []string
+	// SHA-256 checksums of compiler packages by file name. A package downloaded from a mirror that
+	// has no checksum here is verified against a `.sha256` file next to it on the mirror instead.
+	Checksums map[string]string
+	// Attempts is the number of times each source is tried before moving on to the next
+	Attempts int
+}
+
+var sources = Sources{Attempts: 3}
+
+// retryDelay is the delay before the second attempt at a download, it doubles after each attempt
+var retryDelay = time.Second
+
+// SetSources configures where compiler packages are downloaded from
+func SetSources(s Sources) {
+	if s.Attempts < 1 {
+		s.Attempts = 1
+	}
+	sources = s
+}
+
+// fromMirrors downloads a compiler package from the first mirror that has it with a valid checksum
+func fromMirrors(ctx context.Context, filename, version, platform, cacheDir string) (path string, err error) {
+	if len(sources.Mirrors) == 0 {
+		return "", errors.New("no compiler mirrors configured")
+	}
+
+	for _, mirror := range sources.Mirrors {
+		location := mirrorURL(mirror, filename, version, platform)
+
+		err = retry(ctx, func() (errInner error) {
+			path, errInner = download.FromNet(ctx, location, cacheDir, filename)
+			if errInner != nil {
+				return
+			}
+			return verifyMirrorChecksum(ctx, path, location, filename)
+		})
+		if err == nil {
+			print.Verb("downloaded compiler package from mirror", location)
+			return
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		print.Warn("Failed to download compiler package from mirror", location, "-", err)
+		if path != "" {
+			os.Remove(path) // nolint
+			path = ""
+		}
+	}
+
+	return "", errors.Wrap(err, "all compiler mirrors failed")
+}
+
+func mirrorURL(mirror, filename, version, platform string) string {
+	if !strings.Contains(mirror, "{") {
+		return strings.TrimSuffix(mirror, "/") + "/" + filename
+	}
+	return strings.NewReplacer(
+		"{file}", filename,
+		"{version}", version,
+		"{platform}", platform,
+	).Replace(mirror)
+}
+
+func verifyMirrorChecksum(ctx context.Context, path, location, filename string) (err error) {
+	expected, ok := sources.Checksums[filename]
+	if !ok {
+		expected, err = fetchChecksum(ctx, location+".sha256")
+		if err != nil {
+			return errors.Wrap(err, "mirror has no checksum for package")
+		}
+	}
+	return verifyChecksum(path, expected)
+}
+
+// verifyConfiguredChecksum verifies a package against its configured checksum, if it has one
+func verifyConfiguredChecksum(path, filename string) (err error) {
+	expected, ok := sources.Checksums[filename]
+	if !ok {
+		return
+	}
+	return verifyChecksum(path, expected)
+}
+
+func verifyChecksum(path, expected string) (err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open package for checksum")
+	}
+	defer f.Close() // nolint
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return errors.Wrap(err, "failed to read package for checksum")
+	}
+
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if !strings.EqualFold(actual, expected) {
+		return errors.Errorf("checksum mismatch: expected %s, got %s", expected, actual)
+	}
+	return
+}
+
+// fetchChecksum reads a checksum file in the `sha256sum` output format, only the hash is used
+func fetchChecksum(ctx context.Context, location string) (checksum string, err error) {
+	req, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		return
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close() // nolint
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("%s: %s", location, resp.Status)
+	}
+
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	fields := strings.Fields(string(contents))
+	if len(fields) == 0 {
+		return "", errors.Errorf("%s is empty", location)
+	}
+	return fields[0], nil
+}
+
+// retry runs fn until it succeeds or the configured number of attempts is reached, waiting longer
+// between each attempt.
+func retry(ctx context.Context, fn func() error) (err error) {
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= sources.Attempts {
+			return
+		}
+
+		print.Verb("attempt", attempt, "failed:", err, "- retrying in", delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
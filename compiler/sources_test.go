@@ -0,0 +1,93 @@
+package compiler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_fromMirrors(t *testing.T) {
+	defer SetSources(Sources{Attempts: 3})
+	retryDelay = 0
+
+	content := []byte("compiler package")
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	requests := make(map[string]int)
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests["good"+r.URL.Path]++
+		switch r.URL.Path {
+		case "/v3.10.7/pawn-v3.10.7-linux.tgz":
+			w.Write(content) // nolint
+		case "/v3.10.7/pawn-v3.10.7-linux.tgz.sha256":
+			fmt.Fprintf(w, "%s  pawn-v3.10.7-linux.tgz\n", checksum)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer good.Close()
+	tampered := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests["tampered"+r.URL.Path]++
+		if r.URL.Path == "/pawn-v3.10.7-linux.tgz" {
+			w.Write([]byte("something else")) // nolint
+			return
+		}
+		fmt.Fprintln(w, checksum)
+	}))
+	defer tampered.Close()
+
+	cacheDir, err := ioutil.TempDir("", "sampctl-mirrors")
+	assert.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	tests := []struct {
+		name         string
+		sources      Sources
+		wantErr      bool
+		wantRequests map[string]int
+	}{
+		{"template", Sources{Mirrors: []string{good.URL + "/{version}/{file}"}}, false, map[string]int{
+			"good/v3.10.7/pawn-v3.10.7-linux.tgz":        1,
+			"good/v3.10.7/pawn-v3.10.7-linux.tgz.sha256": 1,
+		}},
+		{"fallback after mismatch", Sources{Mirrors: []string{tampered.URL, good.URL + "/{version}/{file}"}, Attempts: 2}, false, map[string]int{
+			"tampered/pawn-v3.10.7-linux.tgz":            2,
+			"tampered/pawn-v3.10.7-linux.tgz.sha256":     2,
+			"good/v3.10.7/pawn-v3.10.7-linux.tgz":        1,
+			"good/v3.10.7/pawn-v3.10.7-linux.tgz.sha256": 1,
+		}},
+		{"configured checksum", Sources{Mirrors: []string{good.URL + "/{version}/{file}"}, Checksums: map[string]string{"pawn-v3.10.7-linux.tgz": "00"}}, true, map[string]int{
+			"good/v3.10.7/pawn-v3.10.7-linux.tgz": 1,
+		}},
+		{"missing", Sources{Mirrors: []string{good.URL}}, true, map[string]int{
+			"good/pawn-v3.10.7-linux.tgz": 1,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = make(map[string]int)
+			SetSources(tt.sources)
+
+			path, err := fromMirrors(context.Background(), "pawn-v3.10.7-linux.tgz", "v3.10.7", "linux", cacheDir)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Empty(t, path)
+			} else {
+				assert.NoError(t, err)
+				got, errRead := ioutil.ReadFile(path)
+				assert.NoError(t, errRead)
+				assert.Equal(t, content, got)
+			}
+			assert.Equal(t, tt.wantRequests, requests)
+		})
+	}
+}
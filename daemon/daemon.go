@@ -0,0 +1,348 @@
+// Package daemon serves a small local HTTP API for ensuring, validating and building packages so
+// editor integrations can keep one sampctl process running instead of starting a new one for every
+// action. Loaded packages stay in memory between requests and are only loaded again when their
+// package definition or lockfile changes.
+//
+// Every endpoint takes a POST with a JSON `Request` body and responds with JSON, errors are
+// responded to with an `ErrorResponse` and a non-200 status.
+//
+// Anything the API is asked to build runs code on the machine, so only requests that could not
+// have come from a web page are handled: they must be sent to a loopback host without an `Origin`
+// header, with a `Content-Type` of `application/json` and with the token of the server, which is
+// created when it starts, in an `Authorization: Bearer <token>` header.
+package daemon
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+
+	"github.com/Southclaws/sampctl/events"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+// Request is the body of every API call
+type Request struct {
+	Dir    string `json:"dir"`              // the package directory
+	Build  string `json:"build,omitempty"`  // the build config for `/build` and `/validate`, the first one by default
+	Ensure bool   `json:"ensure,omitempty"` // whether `/build` ensures dependencies first
+	Update bool   `json:"update,omitempty"` // whether `/ensure` updates dependencies that are already at their locked commit
+}
+
+// EnsureResponse lists the dependencies that were vendored by `/ensure`
+type EnsureResponse struct {
+	Dependencies []ResolvedDependency `json:"dependencies"`
+}
+
+// ResolvedDependency is a dependency and the commit it was vendored at
+type ResolvedDependency struct {
+	Dependency string `json:"dependency"`
+	Commit     string `json:"commit"`
+}
+
+// ValidateResponse lists the problems `/validate` found that would stop the package from building
+type ValidateResponse struct {
+	Problems []string `json:"problems"`
+}
+
+// DiagnosticsResponse holds the diagnostics of the most recent build of a package by `/build`
+type DiagnosticsResponse struct {
+	Build       string                   `json:"build"`
+	Diagnostics []types.ReportDiagnostic `json:"diagnostics"`
+}
+
+// ErrorResponse is the body of every response that isn't successful
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server holds the packages that were loaded by requests, create one with `New`
+type Server struct {
+	GitHub   *github.Client
+	GitAuth  transport.AuthMethod
+	Platform string
+	CacheDir string
+	Token    string // the token every request must be authorised with
+
+	lock       sync.Mutex
+	workspaces map[string]*workspace
+}
+
+// workspace is a package directory the daemon has loaded, requests for the same directory are
+// handled one at a time
+type workspace struct {
+	lock   sync.Mutex
+	dir    string
+	pcx    *rook.PackageContext
+	stamp  string             // identifies the package definition and lockfile the context was loaded from
+	report *types.BuildReport // the report of the most recent build
+}
+
+// New creates a daemon that loads packages with the given GitHub client, git authentication,
+// platform and cache directory, and a new random token that requests must be authorised with
+func New(gh *github.Client, auth transport.AuthMethod, platform, cacheDir string) (*Server, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return nil, errors.Wrap(err, "failed to generate token")
+	}
+	return &Server{
+		GitHub:     gh,
+		GitAuth:    auth,
+		Platform:   platform,
+		CacheDir:   cacheDir,
+		Token:      hex.EncodeToString(token),
+		workspaces: make(map[string]*workspace),
+	}, nil
+}
+
+// Handler returns the HTTP handler for the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ensure", s.handle(s.ensure))
+	mux.HandleFunc("/build", s.handle(s.build))
+	mux.HandleFunc("/validate", s.handle(s.validate))
+	mux.HandleFunc("/diagnostics", s.handle(s.diagnostics))
+	return mux
+}
+
+// ListenAndServe serves the API on an address until the context is cancelled
+func (s *Server) ListenAndServe(ctx context.Context, addr string) (err error) {
+	srv := &http.Server{Addr: addr, Handler: s.Handler()}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background()) // nolint
+	}()
+
+	print.Info("serving API on", addr)
+	err = srv.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return errors.Wrap(err, "failed to serve API")
+}
+
+type handlerFunc func(ctx context.Context, ws *workspace, req Request) (response interface{}, err error)
+
+// handle decodes the request, finds the workspace for its directory and encodes the response
+func (s *Server) handle(fn handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			respond(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "requests must be sent with POST"})
+			return
+		}
+		if status, problem := s.authorise(r); problem != "" {
+			respond(w, status, ErrorResponse{Error: problem})
+			return
+		}
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respond(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+			return
+		}
+		if req.Dir == "" {
+			respond(w, http.StatusBadRequest, ErrorResponse{Error: "request has no package directory"})
+			return
+		}
+
+		ws := s.workspace(util.FullPath(req.Dir))
+		ws.lock.Lock()
+		defer ws.lock.Unlock()
+
+		print.Verb("handling", r.URL.Path, "for", ws.dir)
+		response, err := fn(r.Context(), ws, req)
+		if err != nil {
+			respond(w, http.StatusUnprocessableEntity, ErrorResponse{Error: err.Error()})
+			return
+		}
+		respond(w, http.StatusOK, response)
+	}
+}
+
+// authorise checks that a request was sent by a local client that knows the token of the server.
+// Browsers send an `Origin` header with cross-origin requests and can only send a POST without
+// asking first if its content type is a form or plain text, so these are refused before anything
+// else. The host is checked too, so a page on a domain that resolves to 127.0.0.1 can't reach the
+// API as a same-origin request.
+func (s *Server) authorise(r *http.Request) (status int, problem string) {
+	if r.Header.Get("Origin") != "" {
+		return http.StatusForbidden, "cross-origin requests are not allowed"
+	}
+	if !isLoopback(r.Host) {
+		return http.StatusForbidden, fmt.Sprintf("requests must be sent to a loopback address, not %s", r.Host)
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return http.StatusUnsupportedMediaType, "requests must have a Content-Type of application/json"
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if s.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+		return http.StatusUnauthorized, "requests must be authorised with the token of the server"
+	}
+	return http.StatusOK, ""
+}
+
+// isLoopback is true if the host of a request, with or without a port, is `localhost` or a
+// loopback IP address
+func isLoopback(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+func respond(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		print.Verb("failed to write response:", err)
+	}
+}
+
+func (s *Server) workspace(dir string) *workspace {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ws, ok := s.workspaces[dir]
+	if !ok {
+		ws = &workspace{dir: dir}
+		s.workspaces[dir] = ws
+	}
+	return ws
+}
+
+// context returns the package context of a workspace, loading it again if its package definition
+// or lockfile changed since it was loaded
+func (s *Server) context(ctx context.Context, ws *workspace) (pcx *rook.PackageContext, err error) {
+	stamp := definitionStamp(ws.dir)
+	if ws.pcx != nil && ws.stamp == stamp {
+		return ws.pcx, nil
+	}
+
+	print.Verb("loading package", ws.dir)
+	pcx, err = rook.NewPackageContext(ctx, s.GitHub, s.GitAuth, true, ws.dir, s.Platform, s.CacheDir, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to interpret directory as Pawn package")
+	}
+	ws.pcx = pcx
+	ws.stamp = stamp
+	return
+}
+
+// definitionStamp describes the files a package context is loaded from by their sizes and
+// modification times, it changes whenever one of them is written
+func definitionStamp(dir string) string {
+	var parts []string
+	for _, name := range []string{"pawn.json", "pawn.yaml", types.LockfileName} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s:%d:%d", name, info.ModTime().UnixNano(), info.Size()))
+	}
+	return strings.Join(parts, ";")
+}
+
+func (s *Server) ensure(ctx context.Context, ws *workspace, req Request) (response interface{}, err error) {
+	pcx, err := s.context(ctx, ws)
+	if err != nil {
+		return
+	}
+	// ensuring adds to the plugins and include paths of the context, so it's loaded fresh next time
+	defer func() { ws.pcx = nil }()
+
+	resolved := EnsureResponse{Dependencies: []ResolvedDependency{}}
+	bus := events.NewBus()
+	bus.Subscribe(func(e events.Event) {
+		if dep, ok := e.(events.DependencyResolved); ok {
+			resolved.Dependencies = append(resolved.Dependencies, ResolvedDependency{
+				Dependency: dep.Dependency.String(),
+				Commit:     dep.Commit,
+			})
+		}
+	})
+
+	err = pcx.EnsureDependencies(events.WithBus(ctx, bus), req.Update)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to ensure")
+	}
+	return resolved, nil
+}
+
+func (s *Server) build(ctx context.Context, ws *workspace, req Request) (response interface{}, err error) {
+	pcx, err := s.context(ctx, ws)
+	if err != nil {
+		return
+	}
+	if req.Ensure {
+		defer func() { ws.pcx = nil }()
+	}
+
+	var report *types.BuildReport
+	bus := events.NewBus()
+	bus.Subscribe(func(e events.Event) {
+		if reported, ok := e.(events.BuildReported); ok {
+			report = &reported.Report
+		}
+	})
+
+	// a failed build is still reported, the error is in the report
+	_, _, err = pcx.Build(events.WithBus(ctx, bus), buildName(req), req.Ensure, false, false, "")
+	if report == nil {
+		if err == nil {
+			err = errors.New("build finished without a report")
+		}
+		return nil, err
+	}
+	ws.report = report
+	return report, nil
+}
+
+func (s *Server) validate(ctx context.Context, ws *workspace, req Request) (response interface{}, err error) {
+	pcx, err := s.context(ctx, ws)
+	if err != nil {
+		return
+	}
+	problems, err := pcx.Validate(ctx, buildName(req))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to validate")
+	}
+	if problems == nil {
+		problems = []string{}
+	}
+	return ValidateResponse{Problems: problems}, nil
+}
+
+func (s *Server) diagnostics(ctx context.Context, ws *workspace, req Request) (response interface{}, err error) {
+	if ws.report == nil {
+		return nil, errors.New("package has not been built by the daemon yet")
+	}
+	return DiagnosticsResponse{Build: ws.report.Build, Diagnostics: ws.report.Diagnostics}, nil
+}
+
+func buildName(req Request) string {
+	if req.Build == "" {
+		return "default"
+	}
+	return req.Build
+}
@@ -0,0 +1,149 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestServer(t *testing.T) {
+	dir := util.FullPath("./tests/workspace")
+	os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "include"), 0700))
+	writeDefinition := func(includes string) {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "pawn.json"), []byte(`{
+	"user": "user",
+	"repo": "repo",
+	"entry": "main.pwn",
+	"output": "main.amx",
+	"builds": [{"name": "main", "includes": [`+includes+`]}]
+}`), 0600))
+	}
+	writeDefinition(`"include"`)
+
+	server, err := New(nil, nil, runtime.GOOS, filepath.Join(dir, "cache"))
+	assert.NoError(t, err)
+	assert.Len(t, server.Token, 64)
+	srv := httptest.NewServer(server.Handler())
+	defer srv.Close()
+
+	call := func(path string, req Request, response interface{}) int {
+		body, err := json.Marshal(req)
+		assert.NoError(t, err)
+		r, err := http.NewRequest(http.MethodPost, srv.URL+path, bytes.NewReader(body))
+		assert.NoError(t, err)
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Authorization", "Bearer "+server.Token)
+		resp, err := http.DefaultClient.Do(r)
+		assert.NoError(t, err)
+		defer resp.Body.Close() // nolint
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(response))
+		return resp.StatusCode
+	}
+
+	var validated ValidateResponse
+	assert.Equal(t, http.StatusOK, call("/validate", Request{Dir: dir}, &validated))
+	assert.Equal(t, []string{}, validated.Problems)
+
+	// the package is loaded again once its definition changes
+	time.Sleep(10 * time.Millisecond)
+	writeDefinition(`"include", "missing"`)
+	assert.Equal(t, http.StatusOK, call("/validate", Request{Dir: dir, Build: "main"}, &validated))
+	assert.Equal(t, []string{"include directory missing from build config main is missing"}, validated.Problems)
+
+	var failed ErrorResponse
+	assert.Equal(t, http.StatusUnprocessableEntity, call("/validate", Request{Dir: filepath.Join(dir, "include")}, &failed))
+	assert.Contains(t, failed.Error, "failed to interpret directory as Pawn package")
+
+	assert.Equal(t, http.StatusUnprocessableEntity, call("/diagnostics", Request{Dir: dir}, &failed))
+	assert.Contains(t, failed.Error, "has not been built")
+
+	assert.Equal(t, http.StatusBadRequest, call("/build", Request{}, &failed))
+
+	resp, err := http.Get(srv.URL + "/validate")
+	assert.NoError(t, err)
+	resp.Body.Close() // nolint
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestServerAuthorise(t *testing.T) {
+	server, err := New(nil, nil, runtime.GOOS, util.FullPath("./tests/authorise-cache"))
+	assert.NoError(t, err)
+	srv := httptest.NewServer(server.Handler())
+	defer srv.Close()
+
+	body := `{"dir": "."}`
+	tests := []struct {
+		name    string
+		prepare func(r *http.Request)
+		want    int
+	}{
+		{"cross-origin form", func(r *http.Request) {
+			// what a web page can send without the browser asking first
+			r.Header.Set("Origin", "http://example.com")
+			r.Header.Set("Content-Type", "text/plain")
+		}, http.StatusForbidden},
+		{"cross-origin with token", func(r *http.Request) {
+			r.Header.Set("Origin", "http://example.com")
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set("Authorization", "Bearer "+server.Token)
+		}, http.StatusForbidden},
+		{"rebound host", func(r *http.Request) {
+			r.Host = "attacker.example.com"
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set("Authorization", "Bearer "+server.Token)
+		}, http.StatusForbidden},
+		{"plain text", func(r *http.Request) {
+			r.Header.Set("Content-Type", "text/plain")
+			r.Header.Set("Authorization", "Bearer "+server.Token)
+		}, http.StatusUnsupportedMediaType},
+		{"no token", func(r *http.Request) {
+			r.Header.Set("Content-Type", "application/json")
+		}, http.StatusUnauthorized},
+		{"wrong token", func(r *http.Request) {
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set("Authorization", "Bearer "+strings.Repeat("0", 64))
+		}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodPost, srv.URL+"/build", strings.NewReader(body))
+			assert.NoError(t, err)
+			tt.prepare(r)
+			resp, err := http.DefaultClient.Do(r)
+			assert.NoError(t, err)
+			defer resp.Body.Close() // nolint
+
+			var failed ErrorResponse
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&failed))
+			assert.Equal(t, tt.want, resp.StatusCode, failed.Error)
+		})
+	}
+	assert.Empty(t, server.workspaces, "a refused request loaded a package")
+}
+
+func Test_isLoopback(t *testing.T) {
+	for host, want := range map[string]bool{
+		"127.0.0.1:7878":   true,
+		"localhost:7878":   true,
+		"[::1]:7878":       true,
+		"127.0.0.1":        true,
+		"example.com:7878": false,
+		"0.0.0.0:7878":     false,
+		"192.168.1.2:7878": false,
+	} {
+		assert.Equal(t, want, isLoopback(host), host)
+	}
+}
@@ -0,0 +1,270 @@
+package download
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/util"
+)
+
+// ArchiveFormat represents a compression or archive format detected from the first bytes of a file
+type ArchiveFormat string
+
+const (
+	// ArchiveZip is a .zip archive
+	ArchiveZip ArchiveFormat = "zip"
+	// ArchiveGzip is a gzip compressed tar archive
+	ArchiveGzip ArchiveFormat = "gzip"
+	// ArchiveZlib is a zlib compressed tar archive
+	ArchiveZlib ArchiveFormat = "zlib"
+	// ArchiveBzip2 is a bzip2 compressed tar archive
+	ArchiveBzip2 ArchiveFormat = "bzip2"
+	// ArchiveXz is an xz compressed tar archive, this requires the `xz` command
+	ArchiveXz ArchiveFormat = "xz"
+	// Archive7z is a 7-Zip archive, this requires the `7z` or `7za` command
+	Archive7z ArchiveFormat = "7z"
+)
+
+// ErrUnsupportedArchive is returned when the format of an archive can not be determined
+var ErrUnsupportedArchive = errors.New("unsupported archive format")
+
+var archiveMagic = []struct {
+	format ArchiveFormat
+	magic  []byte
+}{
+	{ArchiveZip, []byte("PK\x03\x04")},
+	{ArchiveGzip, []byte{0x1f, 0x8b}},
+	{ArchiveBzip2, []byte("BZh")},
+	{ArchiveXz, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{Archive7z, []byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}},
+	{ArchiveZlib, []byte{0x78, 0x01}},
+	{ArchiveZlib, []byte{0x78, 0x5e}},
+	{ArchiveZlib, []byte{0x78, 0x9c}},
+	{ArchiveZlib, []byte{0x78, 0xda}},
+}
+
+// DetectArchive determines the format of an archive from its magic bytes rather than its name
+func DetectArchive(src string) (format ArchiveFormat, err error) {
+	f, err := os.Open(src)
+	if err != nil {
+		err = errors.Wrap(err, "failed to open archive")
+		return
+	}
+	defer f.Close() // nolint
+
+	header := make([]byte, 8)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		err = errors.Wrap(err, "failed to read archive header")
+		return
+	}
+	err = nil
+	header = header[:n]
+
+	for _, m := range archiveMagic {
+		if bytes.HasPrefix(header, m.magic) {
+			return m.format, nil
+		}
+	}
+
+	err = errors.Wrapf(ErrUnsupportedArchive, "%s", filepath.Base(src))
+	return
+}
+
+// Extract extracts any supported archive, the format is detected from the contents of the file. The
+// commands that extract the formats the standard library doesn't support are killed once ctx is done.
+func Extract(ctx context.Context, src, dst string, paths map[string]string) (files map[string]string, err error) {
+	format, err := DetectArchive(src)
+	if err != nil {
+		return
+	}
+
+	switch format {
+	case ArchiveZip:
+		return Unzip(src, dst, paths)
+	case Archive7z:
+		return Un7z(ctx, src, dst, paths)
+	default:
+		return untar(ctx, src, dst, paths)
+	}
+}
+
+// Un7z extracts a 7-Zip archive using the `7z` command, the standard library has no support for
+// the format. The archive is extracted to a temporary directory and the matching files are copied.
+// Like tar archives, only regular files are extracted, symbolic links are skipped rather than
+// followed so they can't copy anything from outside of the archive.
+func Un7z(ctx context.Context, src, dst string, paths map[string]string) (files map[string]string, err error) {
+	binary, err := findCommand("7z", "7za")
+	if err != nil {
+		return
+	}
+
+	tmp, err := ioutil.TempDir("", "sampctl-7z")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(tmp) // nolint
+
+	output, err := exec.CommandContext(ctx, binary, "x", "-y", "-o"+tmp, src).CombinedOutput() //nolint:gas
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, errors.Wrapf(err, "failed to extract 7z archive: %s", output)
+	}
+
+	files = make(map[string]string)
+	err = filepath.Walk(tmp, func(path string, info os.FileInfo, errInner error) error {
+		if errInner != nil {
+			return errInner
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		name, errInner := filepath.Rel(tmp, path)
+		if errInner != nil {
+			return errInner
+		}
+
+		found, source, target := nameInPaths(filepath.ToSlash(name), paths)
+		if !found {
+			return nil
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dst, target)
+		}
+
+		errInner = os.MkdirAll(filepath.Dir(target), 0700)
+		if errInner != nil {
+			return errors.Wrap(errInner, "failed to create target dir for file")
+		}
+		errInner = util.CopyFile(path, target)
+		if errInner != nil {
+			return errors.Wrap(errInner, "failed to copy archive file to destination")
+		}
+		errInner = os.Chmod(target, info.Mode().Perm())
+		if errInner != nil {
+			return errors.Wrap(errInner, "failed to set extracted file permissions")
+		}
+
+		files[source] = target
+		return nil
+	})
+	return
+}
+
+// openTar opens a compressed tar archive, the returned function must be called once the reader is
+// no longer needed.
+func openTar(ctx context.Context, src string) (tr *tar.Reader, closer func() error, err error) {
+	format, err := DetectArchive(src)
+	if err != nil {
+		return
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		err = errors.Wrap(err, "failed to open archive")
+		return
+	}
+
+	var (
+		reader     io.Reader
+		closeInner = func() error { return nil }
+	)
+	switch format {
+	case ArchiveGzip:
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(f)
+		if err != nil {
+			err = errors.Wrap(err, "failed to create new gzip reader")
+			break
+		}
+		reader, closeInner = gz, gz.Close
+	case ArchiveZlib:
+		var zl io.ReadCloser
+		zl, err = zlib.NewReader(f)
+		if err != nil {
+			err = errors.Wrap(err, "failed to create new zlib reader")
+			break
+		}
+		reader, closeInner = zl, zl.Close
+	case ArchiveBzip2:
+		reader = bzip2.NewReader(f)
+	case ArchiveXz:
+		reader, closeInner, err = decompressXz(ctx, f)
+	default:
+		err = errors.Wrapf(ErrUnsupportedArchive, "%s is a %s archive, not a tar archive", filepath.Base(src), format)
+	}
+	if err != nil {
+		f.Close() // nolint
+		return
+	}
+
+	tr = tar.NewReader(reader)
+	closer = func() error {
+		if errClose := closeInner(); errClose != nil {
+			f.Close() // nolint
+			return errClose
+		}
+		return f.Close()
+	}
+	return
+}
+
+// decompressXz streams a file through the `xz` command, the standard library has no xz support
+func decompressXz(ctx context.Context, r io.Reader) (reader io.Reader, closer func() error, err error) {
+	binary, err := findCommand("xz")
+	if err != nil {
+		return
+	}
+
+	cmd := exec.CommandContext(ctx, binary, "--decompress", "--stdout") //nolint:gas
+	cmd.Stdin = r
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		err = errors.Wrap(err, "failed to create xz output pipe")
+		return
+	}
+	err = cmd.Start()
+	if err != nil {
+		err = errors.Wrap(err, "failed to start xz")
+		return
+	}
+
+	reader = stdout
+	closer = func() error {
+		// drain the output so xz can exit once the tar reader stops early
+		io.Copy(ioutil.Discard, stdout) // nolint
+		if errWait := cmd.Wait(); errWait != nil {
+			return errors.Wrapf(errWait, "xz failed: %s", strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+	return
+}
+
+func findCommand(names ...string) (path string, err error) {
+	for _, name := range names {
+		path, err = exec.LookPath(name)
+		if err == nil {
+			return
+		}
+	}
+	err = errors.Errorf("extracting this archive requires the `%s` command to be installed", names[0])
+	return
+}
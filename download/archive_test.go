@@ -0,0 +1,169 @@
+package download
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name     string
+		compress func(t *testing.T, tarball []byte) []byte
+		want     ArchiveFormat
+	}{
+		{"tar.gz", compressGzip, ArchiveGzip},
+		{"tar.bz2", compressCommand("bzip2"), ArchiveBzip2},
+		{"tar.xz", compressCommand("xz"), ArchiveXz},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "extract")
+			assert.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			// the extension is intentionally wrong, detection must only use the contents
+			src := filepath.Join(dir, "archive.zip")
+			assert.NoError(t, ioutil.WriteFile(src, tt.compress(t, makeTarball(t)), 0600))
+
+			format, err := DetectArchive(src)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, format)
+
+			files, err := Extract(context.Background(), src, filepath.Join(dir, "out"), map[string]string{"plugins/test.so": "plugins/"})
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]string{"plugins/test.so": filepath.Join(dir, "out", "plugins", "test.so")}, files)
+
+			contents, err := ioutil.ReadFile(filepath.Join(dir, "out", "plugins", "test.so"))
+			assert.NoError(t, err)
+			assert.Equal(t, "plugin", string(contents))
+		})
+	}
+}
+
+func TestExtractTruncated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extract")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// cut off part way through, like an interrupted download
+	compressed := compressCommand("xz")(t, makeTarball(t))
+	src := filepath.Join(dir, "archive.tar.xz")
+	assert.NoError(t, ioutil.WriteFile(src, compressed[:len(compressed)/2], 0600))
+
+	_, err = Extract(context.Background(), src, filepath.Join(dir, "out"), map[string]string{"plugins/test.so": "plugins/"})
+	assert.Error(t, err)
+
+	_, err = ListArchive(context.Background(), src)
+	assert.Error(t, err)
+}
+
+func TestExtractCancelled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extract")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "archive.tar.xz")
+	assert.NoError(t, ioutil.WriteFile(src, compressCommand("xz")(t, makeTarball(t)), 0600))
+
+	// the decompressor isn't left running once the operation is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Extract(ctx, src, filepath.Join(dir, "out"), map[string]string{"plugins/test.so": "plugins/"})
+	assert.Error(t, err)
+	assert.False(t, util.Exists(filepath.Join(dir, "out", "plugins", "test.so")))
+}
+
+func TestUn7zSymlinks(t *testing.T) {
+	binary, err := findCommand("7z", "7za")
+	if err != nil {
+		t.Skip("7z is not installed")
+	}
+	dir, err := ioutil.TempDir("", "extract")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	outside := filepath.Join(dir, "secret")
+	assert.NoError(t, ioutil.WriteFile(outside, []byte("secret"), 0600))
+	contents := filepath.Join(dir, "contents")
+	assert.NoError(t, os.MkdirAll(filepath.Join(contents, "plugins"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(contents, "plugins", "test.so"), []byte("plugin"), 0600))
+	assert.NoError(t, os.Symlink(outside, filepath.Join(contents, "plugins", "link.so")))
+
+	// the link is stored as a link rather than the file it points to
+	src := filepath.Join(dir, "archive.7z")
+	cmd := exec.Command(binary, "a", "-snl", src, "plugins")
+	cmd.Dir = contents
+	output, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(output))
+
+	files, err := Extract(context.Background(), src, filepath.Join(dir, "out"), map[string]string{"plugins/test.so": "plugins/", "plugins/link.so": "plugins/"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"plugins/test.so": filepath.Join(dir, "out", "plugins", "test.so")}, files)
+	assert.False(t, util.Exists(filepath.Join(dir, "out", "plugins", "link.so")))
+}
+
+func TestDetectArchiveUnsupported(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extract")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "archive.rar")
+	assert.NoError(t, ioutil.WriteFile(src, []byte("Rar!\x1a\x07\x00"), 0600))
+
+	_, err = Extract(context.Background(), src, dir, nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported archive format")
+}
+
+func makeTarball(t *testing.T) []byte {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for name, contents := range map[string]string{
+		"plugins/test.so": "plugin",
+		"README.md":       "readme",
+	} {
+		assert.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(contents)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(contents))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func compressGzip(t *testing.T, tarball []byte) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	_, err := gz.Write(tarball)
+	assert.NoError(t, err)
+	assert.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func compressCommand(name string) func(t *testing.T, tarball []byte) []byte {
+	return func(t *testing.T, tarball []byte) []byte {
+		if _, err := exec.LookPath(name); err != nil {
+			t.Skip(name, "is not installed")
+		}
+		cmd := exec.Command(name, "--compress", "--stdout")
+		cmd.Stdin = bytes.NewReader(tarball)
+		output, err := cmd.Output()
+		assert.NoError(t, err)
+		return output
+	}
+}
@@ -0,0 +1,25 @@
+package download
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// Client makes the requests of downloads. Its connections are pooled and shared by every download,
+// so resources downloaded at the same time from the same host reuse connections instead of each
+// opening their own.
+var Client = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          32,
+		MaxIdleConnsPerHost:   8,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	},
+}
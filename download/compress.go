@@ -0,0 +1,132 @@
+package download
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ArchiveEntry is a file to be written into an archive
+type ArchiveEntry struct {
+	Name   string      // path inside the archive, using forward slashes
+	Source string      // path of the file on disk
+	Mode   os.FileMode // permissions of the file inside the archive, if zero the permissions of the source are used
+}
+
+// archiveTime is the modification time of every archived file so the same inputs produce identical
+// archives and therefore identical checksums
+var archiveTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// WriteArchive writes the entries to a new zip or gzip compressed tar archive at dst
+func WriteArchive(dst string, format ArchiveFormat, entries []ArchiveEntry) (err error) {
+	sorted := make([]ArchiveEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return errors.Wrap(err, "failed to create archive")
+	}
+	defer func() {
+		if errClose := f.Close(); errClose != nil && err == nil {
+			err = errClose
+		}
+	}()
+
+	switch format {
+	case ArchiveZip:
+		err = writeZip(f, sorted)
+	case ArchiveGzip:
+		err = writeTarGz(f, sorted)
+	default:
+		err = errors.Wrapf(ErrUnsupportedArchive, "can not write %s archives", format)
+	}
+	return
+}
+
+func writeZip(w io.Writer, entries []ArchiveEntry) (err error) {
+	zw := zip.NewWriter(w)
+	for _, entry := range entries {
+		var info os.FileInfo
+		info, err = os.Stat(entry.Source)
+		if err != nil {
+			return errors.Wrapf(err, "failed to stat %s", entry.Source)
+		}
+		header := &zip.FileHeader{
+			Name:   filepath.ToSlash(entry.Name),
+			Method: zip.Deflate,
+		}
+		header.SetModTime(archiveTime)
+		header.SetMode(entryMode(entry, info))
+
+		var fw io.Writer
+		fw, err = zw.CreateHeader(header)
+		if err != nil {
+			return errors.Wrapf(err, "failed to add %s to archive", entry.Name)
+		}
+		err = copyFile(fw, entry.Source)
+		if err != nil {
+			return
+		}
+	}
+	return zw.Close()
+}
+
+func writeTarGz(w io.Writer, entries []ArchiveEntry) (err error) {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, entry := range entries {
+		var info os.FileInfo
+		info, err = os.Stat(entry.Source)
+		if err != nil {
+			return errors.Wrapf(err, "failed to stat %s", entry.Source)
+		}
+		err = tw.WriteHeader(&tar.Header{
+			Name:     filepath.ToSlash(entry.Name),
+			Mode:     int64(entryMode(entry, info)),
+			Size:     info.Size(),
+			ModTime:  archiveTime,
+			Typeflag: tar.TypeReg,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to add %s to archive", entry.Name)
+		}
+		err = copyFile(tw, entry.Source)
+		if err != nil {
+			return
+		}
+	}
+	err = tw.Close()
+	if err != nil {
+		return
+	}
+	return gw.Close()
+}
+
+func entryMode(entry ArchiveEntry, info os.FileInfo) os.FileMode {
+	if entry.Mode != 0 {
+		return entry.Mode
+	}
+	return info.Mode().Perm()
+}
+
+func copyFile(w io.Writer, src string) (err error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", src)
+	}
+	defer f.Close() // nolint
+
+	_, err = io.Copy(w, f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to archive %s", src)
+	}
+	return
+}
@@ -42,15 +42,27 @@ func ExtractFuncFromName(name string) ExtractFunc {
 	}
 }
 
+// cacheDirOverride replaces the default cache directory when it's set
+var cacheDirOverride string
+
+// SetCacheDir sets the directory returned by GetCacheDir, an empty string restores the default
+func SetCacheDir(dir string) {
+	cacheDirOverride = dir
+}
+
 // GetCacheDir returns the full path to the user's cache directory, creating it if it doesn't exist
 func GetCacheDir() (cacheDir string, err error) {
-	home, err := homedir.Dir()
-	if err != nil {
-		return "", errors.Wrap(err, "failed to get home directory")
+	if cacheDirOverride != "" {
+		cacheDir = cacheDirOverride
+	} else {
+		var home string
+		home, err = homedir.Dir()
+		if err != nil {
+			return "", errors.Wrap(err, "failed to get home directory")
+		}
+		cacheDir = filepath.Join(home, ".samp")
 	}
 
-	cacheDir = filepath.Join(home, ".samp")
-
 	err = os.MkdirAll(cacheDir, 0700)
 	if err != nil {
 		err = errors.Wrapf(err, "Failed to create cache directory %s", cacheDir)
@@ -80,8 +92,14 @@ func FromCache(cacheDir, filename, dir string, method ExtractFunc, paths map[str
 }
 
 // FromNet downloads the server package by filename from the specified location to the cache dir
-func FromNet(location, cacheDir, filename string) (result string, err error) {
-	resp, err := http.Get(location)
+func FromNet(ctx context.Context, location, cacheDir, filename string) (result string, err error) {
+	req, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create request for %s", location)
+		return
+	}
+
+	resp, err := Client.Do(req.WithContext(ctx))
 	if err != nil {
 		err = errors.Wrapf(err, "failed to download package from %s", location)
 		return
@@ -92,6 +110,11 @@ func FromNet(location, cacheDir, filename string) (result string, err error) {
 		}
 	}()
 
+	if resp.StatusCode != http.StatusOK {
+		err = errors.Errorf("failed to download package from %s: %s", location, resp.Status)
+		return
+	}
+
 	content, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		err = errors.Wrap(err, "failed to read download contents")
@@ -111,17 +134,33 @@ func FromNet(location, cacheDir, filename string) (result string, err error) {
 
 // ReleaseAssetByPattern downloads a resource file, which is a GitHub release asset
 func ReleaseAssetByPattern(ctx context.Context, gh *github.Client, meta versioning.DependencyMeta, matcher *regexp.Regexp, dir, outputFile, cacheDir string) (filename, tag string, err error) {
-	var (
-		asset  *github.ReleaseAsset
-		assets []string
-	)
+	asset, tag, err := ReleaseAsset(ctx, gh, meta, matcher)
+	if err != nil {
+		return
+	}
 
-	var release *github.RepositoryRelease
-	if meta.Tag == "" {
-		release, err = getLatestReleaseOrPreRelease(ctx, gh, meta.User, meta.Repo)
+	if outputFile == "" {
+		var u *url.URL
+		u, err = url.Parse(*asset.BrowserDownloadURL)
+		if err != nil {
+			err = errors.Wrap(err, "failed to parse download URL from GitHub API")
+			return
+		}
+		outputFile = filepath.Join(dir, filepath.Base(u.Path))
 	} else {
-		release, _, err = gh.Repositories.GetReleaseByTag(ctx, meta.User, meta.Repo, meta.Tag)
+		outputFile = filepath.Join(dir, outputFile)
 	}
+
+	filename, err = FromNet(ctx, *asset.BrowserDownloadURL, cacheDir, outputFile)
+	return
+}
+
+// ReleaseAsset returns the first asset of the release matching the dependency whose name matches
+// the pattern, along with the tag of the release
+func ReleaseAsset(ctx context.Context, gh *github.Client, meta versioning.DependencyMeta, matcher *regexp.Regexp) (asset *github.ReleaseAsset, tag string, err error) {
+	var assets []string
+
+	release, err := GetRelease(ctx, gh, meta)
 	if err != nil {
 		return
 	}
@@ -138,20 +177,16 @@ func ReleaseAssetByPattern(ctx context.Context, gh *github.Client, meta versioni
 		return
 	}
 	tag = release.GetTagName()
+	return
+}
 
-	if outputFile == "" {
-		var u *url.URL
-		u, err = url.Parse(*asset.BrowserDownloadURL)
-		if err != nil {
-			err = errors.Wrap(err, "failed to parse download URL from GitHub API")
-			return
-		}
-		outputFile = filepath.Join(dir, filepath.Base(u.Path))
-	} else {
-		outputFile = filepath.Join(dir, outputFile)
+// GetRelease returns the release matching the tag of the dependency or, if there is no tag, the
+// most recent release or pre-release
+func GetRelease(ctx context.Context, gh *github.Client, meta versioning.DependencyMeta) (release *github.RepositoryRelease, err error) {
+	if meta.Tag == "" {
+		return getLatestReleaseOrPreRelease(ctx, gh, meta.User, meta.Repo)
 	}
-
-	filename, err = FromNet(*asset.BrowserDownloadURL, cacheDir, outputFile)
+	release, _, err = gh.Repositories.GetReleaseByTag(ctx, meta.User, meta.Repo, meta.Tag)
 	return
 }
 
@@ -3,8 +3,7 @@ package download
 import (
 	"archive/tar"
 	"archive/zip"
-	"compress/gzip"
-	"compress/zlib"
+	"context"
 	"io"
 	"os"
 	"path/filepath"
@@ -18,42 +17,25 @@ import (
 // Untar takes a destination path and a reader; a tar reader loops over the tarfile
 // creating the file structure at 'dst' along the way, and writing any files
 // from https://medium.com/@skdomino/taring-untaring-files-in-go-6b07cf56bc07
-// nolint:gocyclo
+// It has the signature of an `ExtractFunc` so the decompression can't be cancelled, use `Extract`
+// where that matters.
 func Untar(src, dst string, paths map[string]string) (files map[string]string, err error) {
-	reader, err := os.Open(src)
+	return untar(context.Background(), src, dst, paths)
+}
+
+// nolint:gocyclo
+func untar(ctx context.Context, src, dst string, paths map[string]string) (files map[string]string, err error) {
+	tr, closer, err := openTar(ctx, src)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to open archive")
+		return nil, err
 	}
 	defer func() {
-		if errClose := reader.Close(); errClose != nil {
-			panic(errClose)
+		// a decompressor that fails part way through, such as on a truncated archive, reports it here
+		if errClose := closer(); errClose != nil && err == nil {
+			files, err = nil, errors.Wrap(errClose, "failed to decompress archive")
 		}
 	}()
 
-	var tr *tar.Reader
-
-	gz, err := gzip.NewReader(reader)
-	if err != nil {
-		var zl io.ReadCloser
-		zl, err = zlib.NewReader(reader)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to create new zlib reader after failed attempt at gzip")
-		}
-		defer func() {
-			if err = zl.Close(); err != nil {
-				panic(err)
-			}
-		}()
-		tr = tar.NewReader(zl)
-	} else {
-		defer func() {
-			if err = gz.Close(); err != nil {
-				panic(err)
-			}
-		}()
-		tr = tar.NewReader(gz)
-	}
-
 	files = make(map[string]string)
 	var header *tar.Header
 loop:
@@ -108,7 +90,7 @@ loop:
 			}
 
 			var file *os.File
-			file, err = os.OpenFile(target, os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode))
+			file, err = os.OpenFile(target, os.O_CREATE|os.O_RDWR|os.O_TRUNC, os.FileMode(header.Mode))
 			if err != nil {
 				return nil, errors.Wrap(err, "failed to open extract target file")
 			}
@@ -118,6 +100,12 @@ loop:
 				}
 			}()
 
+			// the mode passed to OpenFile is subject to umask and ignored for existing files
+			err = preserveMode(file, os.FileMode(header.Mode))
+			if err != nil {
+				return nil, err
+			}
+
 			if _, err = io.Copy(file, tr); err != nil {
 				return nil, errors.Wrap(err, "failed to copy archive file to destination")
 			}
@@ -197,6 +185,11 @@ func Unzip(src, dst string, paths map[string]string) (files map[string]string, e
 				}
 			}()
 
+			err = preserveMode(file, header.Mode())
+			if err != nil {
+				return nil, err
+			}
+
 			_, err = io.Copy(file, archivedFile)
 			if err != nil {
 				return nil, errors.Wrap(err, "failed to copy archive file to destination")
@@ -230,3 +223,68 @@ func nameInPaths(name string, paths map[string]string) (found bool, source, targ
 	}
 	return
 }
+
+// ListArchive returns the names of all regular files inside a zip or tar archive
+func ListArchive(ctx context.Context, src string) (names []string, err error) {
+	format, err := DetectArchive(src)
+	if err != nil {
+		return
+	}
+	if format == Archive7z {
+		return nil, errors.Wrap(ErrUnsupportedArchive, "listing 7z archives is not supported")
+	}
+	if format == ArchiveZip {
+		var reader *zip.ReadCloser
+		reader, err = zip.OpenReader(src)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to open archive")
+		}
+		defer reader.Close() // nolint
+
+		for _, header := range reader.File {
+			if !header.FileInfo().IsDir() {
+				names = append(names, header.Name)
+			}
+		}
+		return
+	}
+
+	tr, closer, err := openTar(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if errClose := closer(); errClose != nil && err == nil {
+			names, err = nil, errors.Wrap(errClose, "failed to decompress archive")
+		}
+	}()
+
+	for {
+		var header *tar.Header
+		header, err = tr.Next()
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read archive")
+		}
+		if header.Typeflag == tar.TypeReg {
+			names = append(names, header.Name)
+		}
+	}
+	return
+}
+
+// preserveMode applies the permission bits stored in the archive to an extracted file, archives
+// that do not store any permissions are left with the default mode.
+func preserveMode(file *os.File, mode os.FileMode) (err error) {
+	if mode.Perm() == 0 {
+		return
+	}
+	err = file.Chmod(mode.Perm())
+	if err != nil {
+		err = errors.Wrap(err, "failed to set extracted file permissions")
+	}
+	return
+}
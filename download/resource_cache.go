@@ -0,0 +1,193 @@
+package download
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/types"
+)
+
+// ResourceEntry is a resource download kept in the resource cache. Downloads are stored by the
+// sha256 of their contents and shared by every package, the index maps the URL an asset was
+// downloaded from and the release it belongs to onto the stored file so a resource is only fetched
+// once and can be found again without looking up the release.
+type ResourceEntry struct {
+	URL        string          `json:"url"`
+	SHA256     string          `json:"sha256"`
+	Name       string          `json:"name"`               // the file name of the release asset
+	Dependency string          `json:"dependency"`         // the `user/repo` the resource belongs to
+	Tag        string          `json:"tag"`                // the release the asset was downloaded from
+	Platform   string          `json:"platform,omitempty"` // the platform the resource is for
+	Resource   *types.Resource `json:"resource,omitempty"` // the resource definition, for use without the package definition
+	Stored     time.Time       `json:"stored"`             // when the entry was added
+}
+
+// Path returns where the file of an entry is stored in the cache directory
+func (e ResourceEntry) Path(cacheDir string) string {
+	return filepath.Join(cacheDir, "resources", e.SHA256, e.Name)
+}
+
+// resourceIndexMu serialises changes to the resource index within this process
+var resourceIndexMu sync.Mutex
+
+// ReadResourceIndex reads the entries of the resource cache, a missing index is an empty cache
+func ReadResourceIndex(cacheDir string) (entries []ResourceEntry, err error) {
+	contents, err := ioutil.ReadFile(filepath.Join(cacheDir, "resources", "index.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read resource cache index")
+	}
+	err = json.Unmarshal(contents, &entries)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode resource cache index")
+	}
+	return
+}
+
+// CachedResourceByURL returns the cache entry for a URL if its file is stored and intact
+func CachedResourceByURL(cacheDir, location string) (entry ResourceEntry, ok bool) {
+	entries, err := ReadResourceIndex(cacheDir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.URL == location && e.intact(cacheDir) {
+			return e, true
+		}
+	}
+	return
+}
+
+// CachedResource returns the cache entry of a dependency's resource for a platform at a release tag
+// if its file is stored and intact. Without a tag, the most recently stored release is used. If a
+// matcher is given, the asset name must match it.
+func CachedResource(cacheDir, dependency, tag, platform string, matcher *regexp.Regexp) (entry ResourceEntry, ok bool) {
+	entries, err := ReadResourceIndex(cacheDir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !strings.EqualFold(e.Dependency, dependency) || e.Platform != platform {
+			continue
+		}
+		if tag != "" && e.Tag != tag {
+			continue
+		}
+		if matcher != nil && !matcher.MatchString(e.Name) {
+			continue
+		}
+		if ok && !e.Stored.After(entry.Stored) {
+			continue
+		}
+		if e.intact(cacheDir) {
+			entry, ok = e, true
+		}
+	}
+	return
+}
+
+// StoreResource moves a downloaded file into the resource cache and indexes it, returning its entry
+func StoreResource(cacheDir, filename string, entry ResourceEntry) (stored ResourceEntry, err error) {
+	entry.SHA256, err = fileSHA256(filename)
+	if err != nil {
+		return
+	}
+	if entry.Name == "" {
+		entry.Name = filepath.Base(filename)
+	}
+	entry.Stored = time.Now().UTC()
+
+	target := entry.Path(cacheDir)
+	if entry.intact(cacheDir) {
+		err = os.Remove(filename)
+	} else {
+		err = os.MkdirAll(filepath.Dir(target), 0700)
+		if err != nil {
+			return stored, errors.Wrap(err, "failed to create resource cache directory")
+		}
+		os.Remove(target) // nolint
+		err = os.Rename(filename, target)
+	}
+	if err != nil {
+		return stored, errors.Wrap(err, "failed to add resource to cache")
+	}
+
+	return entry, IndexResource(cacheDir, entry)
+}
+
+// IndexResource adds an entry for a file that is already stored to the resource cache index, such as
+// when the same asset provides the resource for more than one platform. It replaces any earlier entry
+// for the same release asset or for the same URL on the same platform.
+func IndexResource(cacheDir string, entry ResourceEntry) (err error) {
+	if entry.Stored.IsZero() {
+		entry.Stored = time.Now().UTC()
+	}
+
+	resourceIndexMu.Lock()
+	defer resourceIndexMu.Unlock()
+
+	entries, err := ReadResourceIndex(cacheDir)
+	if err != nil {
+		return
+	}
+	kept := []ResourceEntry{}
+	for _, e := range entries {
+		if e.URL == entry.URL && e.Platform == entry.Platform {
+			continue
+		}
+		if strings.EqualFold(e.Dependency, entry.Dependency) && e.Tag == entry.Tag && e.Platform == entry.Platform && e.Name == entry.Name {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	kept = append(kept, entry)
+
+	contents, err := json.MarshalIndent(kept, "", "    ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode resource cache index")
+	}
+	// write to a temporary file first so a reader never sees a partially written index
+	index := filepath.Join(cacheDir, "resources", "index.json")
+	err = ioutil.WriteFile(index+".tmp", contents, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write resource cache index")
+	}
+	err = os.Rename(index+".tmp", index)
+	if err != nil {
+		return errors.Wrap(err, "failed to write resource cache index")
+	}
+	return
+}
+
+// intact returns true if the stored file of an entry exists and still has the recorded contents
+func (e ResourceEntry) intact(cacheDir string) bool {
+	checksum, err := fileSHA256(e.Path(cacheDir))
+	return err == nil && checksum == e.SHA256
+}
+
+func fileSHA256(filename string) (checksum string, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open resource")
+	}
+	defer f.Close() // nolint
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read resource")
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
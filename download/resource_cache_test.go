@@ -0,0 +1,83 @@
+package download
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestResourceCache(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "resources")
+	assert.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	store := func(url, tag, platform, contents string) ResourceEntry {
+		downloaded := filepath.Join(cacheDir, "download")
+		assert.NoError(t, ioutil.WriteFile(downloaded, []byte(contents), 0600))
+		entry, errStore := StoreResource(cacheDir, downloaded, ResourceEntry{
+			URL:        url,
+			Name:       "plugin.zip",
+			Dependency: "user/plugin",
+			Tag:        tag,
+			Platform:   platform,
+			Resource:   &types.Resource{Name: "plugin.zip", Platform: platform},
+		})
+		assert.NoError(t, errStore)
+		assert.False(t, util.Exists(downloaded), "the download is moved into the cache")
+		return entry
+	}
+
+	first := store("https://example.com/1.0.0/plugin.zip", "1.0.0", "linux", "first")
+	second := store("https://example.com/1.1.0/plugin.zip", "1.1.0", "linux", "second")
+	assert.NotEqual(t, first.SHA256, second.SHA256)
+
+	contents, err := ioutil.ReadFile(first.Path(cacheDir))
+	assert.NoError(t, err)
+	assert.Equal(t, "first", string(contents))
+
+	entry, ok := CachedResourceByURL(cacheDir, "https://example.com/1.0.0/plugin.zip")
+	assert.True(t, ok)
+	assert.Equal(t, first.SHA256, entry.SHA256)
+
+	entry, ok = CachedResource(cacheDir, "User/Plugin", "1.0.0", "linux", regexp.MustCompile(`plugin\.zip`))
+	assert.True(t, ok)
+	assert.Equal(t, "1.0.0", entry.Tag)
+
+	// without a tag, the most recent release is used
+	entry, ok = CachedResource(cacheDir, "user/plugin", "", "linux", nil)
+	assert.True(t, ok)
+	assert.Equal(t, "1.1.0", entry.Tag)
+
+	_, ok = CachedResource(cacheDir, "user/plugin", "1.0.0", "windows", nil)
+	assert.False(t, ok)
+	_, ok = CachedResource(cacheDir, "user/plugin", "1.0.0", "linux", regexp.MustCompile(`\.tar\.gz$`))
+	assert.False(t, ok)
+
+	// the same asset can provide the resource for another platform
+	windows := first
+	windows.Platform = "windows"
+	assert.NoError(t, IndexResource(cacheDir, windows))
+	_, ok = CachedResource(cacheDir, "user/plugin", "1.0.0", "windows", nil)
+	assert.True(t, ok)
+	_, ok = CachedResource(cacheDir, "user/plugin", "1.0.0", "linux", nil)
+	assert.True(t, ok)
+
+	// damaged files are not used
+	assert.NoError(t, ioutil.WriteFile(second.Path(cacheDir), []byte("damaged"), 0600))
+	_, ok = CachedResourceByURL(cacheDir, "https://example.com/1.1.0/plugin.zip")
+	assert.False(t, ok)
+	entry, ok = CachedResource(cacheDir, "user/plugin", "", "linux", nil)
+	assert.True(t, ok)
+	assert.Equal(t, "1.0.0", entry.Tag)
+
+	entries, err := ReadResourceIndex(cacheDir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
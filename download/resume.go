@@ -0,0 +1,167 @@
+package download
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+)
+
+// resumeAttempts is how many times a download that is cut off is resumed before giving up, the
+// partial download is kept either way so the next run carries on from where it stopped
+const resumeAttempts = 3
+
+// partialDownload is the state of an unfinished download, stored next to the partial file so it's
+// only resumed from the same URL and only if the file on the server is still the same one
+type partialDownload struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// errInterrupted is a download that stopped partway through and can be resumed
+type errInterrupted struct {
+	err     error
+	written int64
+}
+
+func (e errInterrupted) Error() string {
+	return fmt.Sprintf("download interrupted after %d bytes: %s", e.written, e.err)
+}
+
+// ResumableFromNet downloads a file from a location to the cache directory like FromNet, except the
+// file is written to disk as it's received and a download that is cut off is resumed with an HTTP
+// range request instead of starting over, both within this call and by later calls for the same
+// file. The partial file is kept at `<filename>.part` until it's complete. If the server doesn't
+// support range requests or the file changed on the server, the download starts from the beginning.
+func ResumableFromNet(ctx context.Context, location, cacheDir, filename string) (result string, err error) {
+	result = filepath.Join(cacheDir, filename)
+	for attempt := 1; ; attempt++ {
+		err = resumeDownload(ctx, location, result)
+		if err == nil {
+			return
+		}
+		if _, ok := err.(errInterrupted); !ok || attempt == resumeAttempts || ctx.Err() != nil {
+			return "", errors.Wrapf(err, "failed to download %s", location)
+		}
+		print.Verb("resuming download of", location, "after", err)
+	}
+}
+
+// resumeDownload makes a single request for the rest of a partial download, or the whole file if
+// there is no partial download, and moves the partial file to the result once it's complete
+func resumeDownload(ctx context.Context, location, result string) (err error) {
+	partial := result + ".part"
+	stateFile := partial + ".json"
+
+	var (
+		state  partialDownload
+		offset int64
+	)
+	if contents, errRead := ioutil.ReadFile(stateFile); errRead == nil && json.Unmarshal(contents, &state) == nil && state.URL == location {
+		if info, errStat := os.Stat(partial); errStat == nil {
+			offset = info.Size()
+		}
+	}
+
+	req, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create request for %s", location)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		// if the file changed since the partial download started, the server sends all of it
+		if state.ETag != "" {
+			req.Header.Set("If-Range", state.ETag)
+		} else if state.LastModified != "" {
+			req.Header.Set("If-Range", state.LastModified)
+		}
+	}
+
+	resp, err := Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "failed to download package from %s", location)
+	}
+	defer resp.Body.Close() // nolint
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if offset == 0 || contentRangeStart(resp.Header.Get("Content-Range")) != offset {
+			return errors.Errorf("unexpected partial response from %s: %s", location, resp.Header.Get("Content-Range"))
+		}
+		print.Verb("resuming download of", location, "from", offset, "bytes")
+		flags = os.O_WRONLY | os.O_APPEND
+	case http.StatusOK:
+		if offset > 0 {
+			print.Verb("server sent all of", location, "instead of resuming, downloading it again")
+		}
+		state = partialDownload{
+			URL:          location,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		}
+		var contents []byte
+		contents, err = json.Marshal(state)
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(stateFile), 0700)
+		}
+		if err == nil {
+			err = ioutil.WriteFile(stateFile, contents, 0600)
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to record download progress")
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the partial file isn't part of the file on the server, start again on the next attempt
+		os.Remove(stateFile) // nolint
+		os.Remove(partial)   // nolint
+		return errInterrupted{errors.Errorf("server rejected resuming from %d bytes", offset), 0}
+	default:
+		return errors.Errorf("failed to download package from %s: %s", location, resp.Status)
+	}
+
+	f, err := os.OpenFile(partial, flags, 0655)
+	if err != nil {
+		return errors.Wrap(err, "failed to open partial download")
+	}
+	written, err := io.Copy(f, resp.Body)
+	errClose := f.Close()
+	if err != nil {
+		return errInterrupted{err, offset + written}
+	}
+	if errClose != nil {
+		return errors.Wrap(errClose, "failed to write partial download")
+	}
+
+	err = os.Rename(partial, result)
+	if err != nil {
+		return errors.Wrap(err, "failed to write package to cache")
+	}
+	os.Remove(stateFile) // nolint
+	return
+}
+
+// contentRangeStart returns the first byte of a `Content-Range: bytes 100-199/200` header
+func contentRangeStart(header string) int64 {
+	header = strings.TrimPrefix(header, "bytes ")
+	end := strings.IndexByte(header, '-')
+	if end == -1 {
+		return -1
+	}
+	start, err := strconv.ParseInt(header[:end], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return start
+}
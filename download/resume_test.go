@@ -0,0 +1,99 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestResumableFromNet(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "resume")
+	assert.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	contents := bytes.Repeat([]byte("0123456789"), 1000)
+	modified := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var ranges []string
+	cut := 0 // how many responses to cut off halfway
+	ignoreRange := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("ETag", `"v1"`)
+		if ignoreRange {
+			w.Write(contents) // nolint
+			return
+		}
+		if cut > 0 {
+			cut--
+			start := 0
+			if r.Header.Get("Range") != "" {
+				start = 4000
+			}
+			w.Header().Set("Content-Length", "10000")
+			w.WriteHeader(http.StatusOK)
+			w.Write(contents[start : start+2000]) // nolint
+			return
+		}
+		http.ServeContent(w, r, "plugin.zip", modified, bytes.NewReader(contents))
+	}))
+	defer server.Close()
+
+	download := func() []byte {
+		result, errDownload := ResumableFromNet(context.Background(), server.URL+"/plugin.zip", cacheDir, "plugin.zip")
+		assert.NoError(t, errDownload)
+		got, errRead := ioutil.ReadFile(result)
+		assert.NoError(t, errRead)
+		os.Remove(result) // nolint
+		return got
+	}
+
+	// a download cut off halfway is resumed from where it stopped
+	cut = 1
+	assert.Equal(t, contents, download())
+	assert.Equal(t, []string{"", "bytes=2000-"}, ranges)
+	assert.False(t, util.Exists(filepath.Join(cacheDir, "plugin.zip.part")))
+
+	// a partial download left by an earlier run is resumed
+	ranges = nil
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(cacheDir, "plugin.zip.part"), contents[:4000], 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(cacheDir, "plugin.zip.part.json"),
+		[]byte(`{"url": "`+server.URL+`/plugin.zip", "etag": "\"v1\""}`), 0600))
+	assert.Equal(t, contents, download())
+	assert.Equal(t, []string{"bytes=4000-"}, ranges)
+
+	// a partial download from a different URL is started again
+	ranges = nil
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(cacheDir, "plugin.zip.part"), []byte("other"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(cacheDir, "plugin.zip.part.json"), []byte(`{"url": "https://other"}`), 0600))
+	assert.Equal(t, contents, download())
+	assert.Equal(t, []string{""}, ranges)
+
+	// a server that doesn't support range requests sends the whole file
+	ranges = nil
+	ignoreRange = true
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(cacheDir, "plugin.zip.part"), contents[:4000], 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(cacheDir, "plugin.zip.part.json"),
+		[]byte(`{"url": "`+server.URL+`/plugin.zip"}`), 0600))
+	assert.Equal(t, contents, download())
+	assert.Equal(t, []string{"bytes=4000-"}, ranges)
+
+	// a download that keeps being cut off fails but keeps its progress
+	ranges = nil
+	ignoreRange = false
+	cut = resumeAttempts
+	_, err = ResumableFromNet(context.Background(), server.URL+"/plugin.zip", cacheDir, "plugin.zip")
+	assert.Error(t, err)
+	assert.Len(t, ranges, resumeAttempts)
+	assert.True(t, util.Exists(filepath.Join(cacheDir, "plugin.zip.part")))
+}
@@ -18,7 +18,7 @@ import (
 func GetRuntimeList(cacheDir string) (runtimes types.Runtimes, err error) {
 	runtimesFile := filepath.Join(cacheDir, "runtimes.json")
 
-	var update bool
+	var update, stale bool
 
 	info, err := os.Stat(runtimesFile)
 	if os.IsNotExist(err) {
@@ -26,6 +26,7 @@ func GetRuntimeList(cacheDir string) (runtimes types.Runtimes, err error) {
 	} else if time.Since(info.ModTime()) > time.Hour*24*7 {
 		// update package list every week
 		update = true
+		stale = true
 	}
 
 	if update {
@@ -33,7 +34,11 @@ func GetRuntimeList(cacheDir string) (runtimes types.Runtimes, err error) {
 		fmt.Fprintln(os.Stderr, "updating runtimes list...") // nolint:gas
 		err = UpdateRuntimeList(cacheDir)
 		if err != nil {
-			return
+			if !stale {
+				return
+			}
+			// an outdated list still knows every server that was cached, so runs work offline
+			fmt.Fprintln(os.Stderr, "failed to update runtimes list, using the cached list:", err) // nolint:gas
 		}
 	}
 
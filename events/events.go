@@ -0,0 +1,199 @@
+// Package events provides a way for programs that embed sampctl to observe long running operations
+// such as ensuring dependencies, building packages and running servers. Subscribers receive typed
+// events instead of having to parse the text that is printed for the command-line interface.
+package events
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// Event is implemented by every event type, use a type switch to handle specific events
+type Event interface {
+	event()
+}
+
+// DependencyResolved is published once a dependency has been vendored at a specific commit
+type DependencyResolved struct {
+	Dependency versioning.DependencyMeta
+	Commit     string
+}
+
+// FileExtracted is published for each file extracted from a downloaded resource archive
+type FileExtracted struct {
+	Source string // the path of the file inside the archive
+	Target string // the path the file was extracted to
+}
+
+// ResourceEnsured is published as each of a set of resources that are downloaded at the same time
+// is ready, with how many of them are ready so far
+type ResourceEnsured struct {
+	Dependency versioning.DependencyMeta
+	Done       int
+	Total      int
+}
+
+// CompileStarted is published when the compiler is invoked for a package
+type CompileStarted struct {
+	Input  string
+	Output string
+}
+
+// Diagnostic is published for every warning or error reported by the compiler
+type Diagnostic struct {
+	Problem types.BuildProblem
+}
+
+// CompileFinished is published once the compiler exits, Err is set if it failed to run
+type CompileFinished struct {
+	Problems types.BuildProblems
+	Result   types.BuildResult
+	Err      error
+}
+
+// BuildReported is published with the report of a build once it finishes
+type BuildReported struct {
+	Report types.BuildReport
+}
+
+// ServerLog is published for each line of output written by a running server
+type ServerLog struct {
+	Line string
+}
+
+// Timing is published when a measured step of an ensure or build finishes
+type Timing struct {
+	Step     string        // the kind of step, one of the Step constants
+	Subject  string        // what the step was for, such as a dependency or a build
+	Duration time.Duration // how long the step took
+}
+
+const (
+	// StepDependency is ensuring a dependency, including cloning or updating it and its resources
+	StepDependency = "dependency"
+	// StepResource is downloading, or finding in the cache, and extracting a resource
+	StepResource = "resource"
+	// StepCompile is running the compiler for a build
+	StepCompile = "compile"
+)
+
+func (DependencyResolved) event() {}
+func (FileExtracted) event()      {}
+func (ResourceEnsured) event()    {}
+func (CompileStarted) event()     {}
+func (Diagnostic) event()         {}
+func (CompileFinished) event()    {}
+func (BuildReported) event()      {}
+func (ServerLog) event()          {}
+func (Timing) event()             {}
+
+// Bus delivers published events to every subscriber, subscribers are called synchronously in the
+// order they subscribed so they should return quickly.
+type Bus struct {
+	lock        sync.RWMutex
+	next        int
+	subscribers map[int]func(Event)
+	order       []int
+}
+
+// NewBus creates an event bus with no subscribers
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[int]func(Event))}
+}
+
+// Subscribe registers a function to receive every event published to the bus, the returned
+// function removes the subscription.
+func (b *Bus) Subscribe(fn func(Event)) (unsubscribe func()) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	id := b.next
+	b.next++
+	b.subscribers[id] = fn
+	b.order = append(b.order, id)
+
+	return func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+
+		delete(b.subscribers, id)
+		for i, existing := range b.order {
+			if existing == id {
+				b.order = append(b.order[:i], b.order[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Publish sends an event to every subscriber, publishing to a nil bus does nothing
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.lock.RLock()
+	subscribers := make([]func(Event), 0, len(b.order))
+	for _, id := range b.order {
+		subscribers = append(subscribers, b.subscribers[id])
+	}
+	b.lock.RUnlock()
+
+	for _, fn := range subscribers {
+		fn(e)
+	}
+}
+
+type contextKey struct{}
+
+// WithBus returns a context that carries the bus, operations that are given this context publish
+// their events to it.
+func WithBus(ctx context.Context, b *Bus) context.Context {
+	return context.WithValue(ctx, contextKey{}, b)
+}
+
+// FromContext returns the bus carried by a context or nil if there isn't one
+func FromContext(ctx context.Context) *Bus {
+	b, _ := ctx.Value(contextKey{}).(*Bus)
+	return b
+}
+
+// Publish sends an event to the bus carried by the context, if there is one
+func Publish(ctx context.Context, e Event) {
+	FromContext(ctx).Publish(e)
+}
+
+// Measure publishes a Timing event for a step that started at the given time, call it with defer
+func Measure(ctx context.Context, step, subject string, started time.Time) {
+	Publish(ctx, Timing{Step: step, Subject: subject, Duration: time.Since(started)})
+}
+
+// LogWriter returns a writer that publishes each complete line written to it as a ServerLog event
+func LogWriter(ctx context.Context) io.Writer {
+	return &logWriter{bus: FromContext(ctx)}
+}
+
+type logWriter struct {
+	bus     *Bus
+	partial []byte
+}
+
+func (w *logWriter) Write(p []byte) (n int, err error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i == -1 {
+			break
+		}
+		w.bus.Publish(ServerLog{Line: strings.TrimRight(string(w.partial[:i]), "\r")})
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+}
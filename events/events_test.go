@@ -0,0 +1,85 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+
+	var first, second []Event
+	unsubscribe := bus.Subscribe(func(e Event) { first = append(first, e) })
+	bus.Subscribe(func(e Event) { second = append(second, e) })
+
+	ctx := WithBus(context.Background(), bus)
+	Publish(ctx, CompileStarted{Input: "test.pwn", Output: "test.amx"})
+	unsubscribe()
+	Publish(ctx, ServerLog{Line: "hello"})
+
+	assert.Equal(t, []Event{CompileStarted{Input: "test.pwn", Output: "test.amx"}}, first)
+	assert.Equal(t, []Event{CompileStarted{Input: "test.pwn", Output: "test.amx"}, ServerLog{Line: "hello"}}, second)
+
+	// publishing without a bus is a no-op
+	Publish(context.Background(), ServerLog{Line: "nobody is listening"})
+}
+
+func TestLogWriter(t *testing.T) {
+	bus := NewBus()
+
+	var lines []string
+	bus.Subscribe(func(e Event) {
+		if log, ok := e.(ServerLog); ok {
+			lines = append(lines, log.Line)
+		}
+	})
+
+	w := LogWriter(WithBus(context.Background(), bus))
+	fmt.Fprint(w, "Server Plugins\r\n-----")
+	fmt.Fprint(w, "---------\n Loaded 0 plugins.\n\nincomplete")
+
+	assert.Equal(t, []string{"Server Plugins", "--------------", " Loaded 0 plugins.", ""}, lines)
+}
+
+func TestTimings(t *testing.T) {
+	bus := NewBus()
+	timings := &Timings{}
+	bus.Subscribe(timings.Collect)
+
+	ctx := WithBus(context.Background(), bus)
+	Publish(ctx, Timing{Step: StepDependency, Subject: "user/fast", Duration: time.Second})
+	Publish(ctx, Timing{Step: StepDependency, Subject: "user/slow", Duration: 3 * time.Second})
+	Publish(ctx, Timing{Step: StepCompile, Subject: "gamemodes/test.pwn", Duration: 2 * time.Second})
+	Publish(ctx, ServerLog{Line: "not a timing"})
+
+	assert.Equal(t, TimingSummary{
+		Entries: []TimingEntry{
+			{Step: StepDependency, Subject: "user/slow", Duration: 3},
+			{Step: StepCompile, Subject: "gamemodes/test.pwn", Duration: 2},
+			{Step: StepDependency, Subject: "user/fast", Duration: 1},
+		},
+		Totals: map[string]float64{StepDependency: 4, StepCompile: 2},
+	}, timings.Summary())
+
+	table := &bytes.Buffer{}
+	assert.NoError(t, timings.WriteTable(table))
+	assert.Equal(t, `STEP        SUBJECT             DURATION
+dependency  user/slow           3s
+compile     gamemodes/test.pwn  2s
+dependency  user/fast           1s
+compile     (total)             2s
+dependency  (total)             4s
+`, table.String())
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, timings.WriteJSON(out))
+	var summary TimingSummary
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &summary))
+	assert.Equal(t, timings.Summary(), summary)
+}
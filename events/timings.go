@@ -0,0 +1,97 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Timings collects the Timing events published to a bus so they can be summarised once an operation
+// finishes, subscribe its Collect method to the bus.
+type Timings struct {
+	lock    sync.Mutex
+	entries []Timing
+}
+
+// TimingSummary is the breakdown of the collected timings, with the slowest entries first
+type TimingSummary struct {
+	Entries []TimingEntry      `json:"entries"`
+	Totals  map[string]float64 `json:"totals"` // total seconds spent on each kind of step
+}
+
+// TimingEntry is a single measured step in a summary
+type TimingEntry struct {
+	Step     string  `json:"step"`
+	Subject  string  `json:"subject"`
+	Duration float64 `json:"duration"` // seconds
+}
+
+// Collect records the event if it's a Timing event
+func (t *Timings) Collect(e Event) {
+	timing, ok := e.(Timing)
+	if !ok {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.entries = append(t.entries, timing)
+}
+
+// Summary returns the collected timings, slowest first, along with the total of each kind of step
+func (t *Timings) Summary() (summary TimingSummary) {
+	t.lock.Lock()
+	entries := append([]Timing(nil), t.entries...)
+	t.lock.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Duration > entries[j].Duration
+	})
+
+	summary.Entries = []TimingEntry{}
+	summary.Totals = make(map[string]float64)
+	for _, entry := range entries {
+		summary.Entries = append(summary.Entries, TimingEntry{
+			Step:     entry.Step,
+			Subject:  entry.Subject,
+			Duration: entry.Duration.Seconds(),
+		})
+		summary.Totals[entry.Step] += entry.Duration.Seconds()
+	}
+	return
+}
+
+// WriteTable writes the summary as a table of each step followed by the totals
+func (t *Timings) WriteTable(w io.Writer) (err error) {
+	summary := t.Summary()
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tSUBJECT\tDURATION")
+	for _, entry := range summary.Entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", entry.Step, entry.Subject, seconds(entry.Duration))
+	}
+
+	var steps []string
+	for step := range summary.Totals {
+		steps = append(steps, step)
+	}
+	sort.Strings(steps)
+	for _, step := range steps {
+		fmt.Fprintf(tw, "%s\t(total)\t%s\n", step, seconds(summary.Totals[step]))
+	}
+	return tw.Flush()
+}
+
+// WriteJSON writes the summary as JSON
+func (t *Timings) WriteJSON(w io.Writer) (err error) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(t.Summary())
+}
+
+func seconds(s float64) string {
+	return time.Duration(s * float64(time.Second)).Round(time.Millisecond).String()
+}
@@ -9,16 +9,15 @@ import (
 
 	"github.com/Masterminds/semver"
 	"github.com/google/go-github/github"
-	"golang.org/x/oauth2"
 	"gopkg.in/segmentio/analytics-go.v3"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
-	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
-	"gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
 	"gopkg.in/urfave/cli.v1"
 
 	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/events"
 	"github.com/Southclaws/sampctl/print"
 	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
 )
 
 var (
@@ -28,9 +27,12 @@ var (
 	gh         *github.Client       // a github client to use for API requests
 	gitAuth    transport.AuthMethod // for private dependencies
 	segment    analytics.Client     // segment.io client
+	bus        = events.NewBus()    // events from long running operations
 )
 
 func main() {
+	bus.Subscribe(printEvent)
+
 	app := cli.NewApp()
 
 	app.Author = "Southclaws"
@@ -57,19 +59,16 @@ func main() {
 		return
 	}
 
-	if config.GitHubToken == "" {
-		gh = github.NewClient(nil)
-	} else {
-		gh = github.NewClient(oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: config.GitHubToken})))
+	// the global settings apply until a command applies the settings of its project
+	settings, err := types.LoadSettings(util.FullPath("."))
+	if err != nil {
+		print.Erro("Failed to load settings:", err)
+		return
 	}
-
-	if config.GitUsername != "" && config.GitPassword != "" {
-		gitAuth = http.NewBasicAuth(config.GitUsername, config.GitPassword)
-	} else {
-		gitAuth, err = ssh.DefaultAuthBuilder("git")
-		if err != nil {
-			print.Verb("Failed to set up SSH:", err)
-		}
+	err = setup(settings)
+	if err != nil {
+		print.Erro(err)
+		return
 	}
 
 	if config.Metrics {
@@ -88,14 +87,30 @@ func main() {
 
 	globalFlags := []cli.Flag{
 		cli.BoolFlag{
-			Name:  "verbose",
-			Usage: "output all detailed information - useful for debugging",
+			Name:  "verbose, v",
+			Usage: "output detailed information about each step - useful for debugging",
+		},
+		cli.BoolFlag{
+			Name:  "debug",
+			Usage: "output everything --verbose does as well as the internals of git operations and the cache",
+		},
+		cli.BoolFlag{
+			Name:  "quiet, q",
+			Usage: "only output errors and a final result=ok or result=error line - useful for scripts",
 		},
 		cli.StringFlag{
 			Name:  "platform",
 			Value: "",
 			Usage: "manually specify the target platform for downloaded binaries to either `windows`, `linux` or `darwin`.",
 		},
+		cli.BoolFlag{
+			Name:  "read-only-vendor",
+			Usage: "use the vendor directory as it is, never ensuring or writing to it - --read-only-vendor=false overrides the setting",
+		},
+		cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "abort ensure and build operations after a duration such as `10m` - by default, `package ensure`, `verify`, `cache`, `lockcheck` and `template make` stop after an hour and everything else has no limit",
+		},
 	}
 	app.Commands = []cli.Command{
 		{
@@ -170,6 +185,139 @@ func main() {
 					Flags:        append(globalFlags, packageUninstallFlags...),
 					BashComplete: packageUninstallBash,
 				},
+				{
+					Name:        "prune",
+					Usage:       "sampctl package prune",
+					Description: "Lists the dependencies in the `dependencies` field in `pawn.json`/`pawn.yaml` that no build includes and optionally removes them.",
+					Action:      packagePrune,
+					Flags:       append(globalFlags, packagePruneFlags...),
+				},
+				{
+					Name:        "fmt",
+					Usage:       "sampctl package fmt",
+					Description: "Rewrites the `pawn.json`/`pawn.yaml` file with canonical key ordering and indentation.",
+					Action:      packageFmt,
+					Flags:       append(globalFlags, packageFmtFlags...),
+				},
+				{
+					Name:        "why",
+					Usage:       "sampctl package why [package definition]",
+					Description: "Lists every chain of dependencies that causes the given package to be installed.",
+					Action:      packageWhy,
+					Flags:       append(globalFlags, packageWhyFlags...),
+				},
+				{
+					Name:        "run-script",
+					Usage:       "sampctl package run-script [name] [args...]",
+					Description: "Runs a named command from the `scripts` field in `pawn.json`/`pawn.yaml` in the package directory, with the include paths and outputs of a build in `SAMPCTL_*` environment variables. Lists the scripts if no name is given.",
+					Action:      packageRunScript,
+					Flags:       append(globalFlags, packageRunScriptFlags...),
+				},
+				{
+					Name:        "provides",
+					Usage:       "sampctl package provides <include>",
+					Description: "Lists the vendored dependencies that provide an include, or the packages that would if none do.",
+					Action:      packageProvides,
+					Flags:       append(globalFlags, packageProvidesFlags...),
+				},
+				{
+					Name:        "search",
+					Usage:       "sampctl package search <query>",
+					Description: "Searches the package index for packages that match every word of the query. The index is a built-in list of well known packages unless an `index` is set in the configuration.",
+					Action:      packageSearch,
+					Flags:       append(globalFlags, packageSearchFlags...),
+				},
+				{
+					Name:        "bump",
+					Usage:       "sampctl package bump <pin|tilde|caret|latest>",
+					Description: "Rewrites the version constraints of all dependencies: `pin` to their current versions exactly, `tilde` or `caret` to allow patch or minor releases of their current versions, or `latest` to the newest versions keeping each constraint's operator. The lockfile and dependencies are updated to match.",
+					Action:      packageBump,
+					Flags:       append(globalFlags, packageBumpFlags...),
+				},
+				{
+					Name:        "compat",
+					Usage:       "sampctl package compat [from] [to]",
+					Description: "Compares the symbols the package exports at two revisions and lists those that were removed or changed as potential breaking changes. By default, compares the latest version tag with the working tree.",
+					Action:      packageCompat,
+					Flags:       append(globalFlags, packageCompatFlags...),
+				},
+				{
+					Name:        "cache",
+					Usage:       "sampctl package cache",
+					Description: "Downloads the plugin resources of all dependencies into the resource cache shared by all packages so the package can be ensured and run without a network.",
+					Action:      packageCache,
+					Flags:       append(globalFlags, packageCacheFlags...),
+				},
+				{
+					Name:        "verify",
+					Usage:       "sampctl package verify",
+					Description: "Checks the files of each vendored dependency against the commit it is locked to in pawn.lock and optionally repairs those that were damaged.",
+					Action:      packageVerify,
+					Flags:       append(globalFlags, packageVerifyFlags...),
+				},
+				{
+					Name:        "lockcheck",
+					Usage:       "sampctl package lockcheck",
+					Description: "Checks that pawn.lock exists, is committed without changes, matches the dependencies in the package definition and is left unchanged by a frozen ensure, for use as a CI gate.",
+					Action:      packageLockcheck,
+					Flags:       append(globalFlags, packageLockcheckFlags...),
+				},
+				{
+					Name:        "discover",
+					Usage:       "sampctl package discover [package definition]",
+					Description: "Inspects the latest GitHub release of a plugin and prints candidate `resources` entries for its assets.",
+					Action:      packageDiscover,
+					Flags:       append(globalFlags, packageDiscoverFlags...),
+				},
+				{
+					Name:        "flatten",
+					Usage:       "sampctl package flatten",
+					Description: "Combines the package's include files and everything they include from the package into a single include file for distribution.",
+					Action:      packageFlatten,
+					Flags:       append(globalFlags, packageFlattenFlags...),
+				},
+				{
+					Name:        "repro",
+					Usage:       "sampctl package repro [build]",
+					Description: "Writes a minimal reproduction of a build for a bug report: the package definition, the lockfile and the exact compiler command.",
+					Action:      packageRepro,
+					Flags:       append(globalFlags, packageReproFlags...),
+				},
+				{
+					Name:        "licenses",
+					Usage:       "sampctl package licenses",
+					Description: "Reports the license of every vendored dependency and flags any that are unknown, incompatible or missing.",
+					Action:      packageLicenses,
+					Flags:       append(globalFlags, packageLicensesFlags...),
+				},
+				{
+					Name:        "audit",
+					Usage:       "sampctl package audit",
+					Description: "Checks the version each vendored dependency is at against a feed of advisories about known-bad versions and reports those that affect it.",
+					Action:      packageAudit,
+					Flags:       append(globalFlags, packageAuditFlags...),
+				},
+				{
+					Name:        "sbom",
+					Usage:       "sampctl package sbom",
+					Description: "Writes a CycloneDX software bill of materials listing every vendored dependency with its version, commit, license and plugin checksums.",
+					Action:      packageSBOM,
+					Flags:       append(globalFlags, packageSBOMFlags...),
+				},
+				{
+					Name:        "plan",
+					Usage:       "sampctl package plan [package definition]",
+					Description: "Shows what updating a dependency, or all dependencies, would change without modifying anything.",
+					Action:      packagePlan,
+					Flags:       append(globalFlags, packagePlanFlags...),
+				},
+				{
+					Name:        "ci",
+					Usage:       "sampctl package ci",
+					Description: "Generates a GitHub Actions workflow that ensures, builds and tests the package.",
+					Action:      packageCI,
+					Flags:       append(globalFlags, packageCIFlags...),
+				},
 				{
 					Name:        "release",
 					Usage:       "sampctl package release",
@@ -200,6 +348,13 @@ func main() {
 					Action:      packageRun,
 					Flags:       append(globalFlags, packageRunFlags...),
 				},
+				{
+					Name:        "test",
+					Usage:       "sampctl package test",
+					Description: "Builds the package, runs it as an automated test and reports the result of each test from the server output.",
+					Action:      packageTest,
+					Flags:       append(globalFlags, packageTestFlags...),
+				},
 				{
 					Name:        "template",
 					Usage:       "sampctl package template <subcommand>",
@@ -230,6 +385,13 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:        "serve",
+			Usage:       "sampctl serve",
+			Description: "Runs a local API for editor integrations to ensure, validate and build packages without starting sampctl for every action.",
+			Action:      serve,
+			Flags:       append(globalFlags, serveFlags...),
+		},
 		{
 			Name:        "version",
 			Description: "Show version number - this is also the version of the container image that will be used for `--container` runtimes.",
@@ -254,10 +416,16 @@ func main() {
 
 	app.Flags = globalFlags
 	app.Before = func(c *cli.Context) error {
-		if c.GlobalBool("verbose") {
+		if c.GlobalBool("debug") {
+			print.SetDebug()
+			print.Debug("Debug logging active")
+		} else if c.GlobalBool("verbose") {
 			print.SetVerbose()
 			print.Verb("Verbose logging active")
 		}
+		if c.GlobalBool("quiet") {
+			print.SetQuiet()
+		}
 		if runtime.GOOS != "windows" {
 			print.SetColoured()
 		}
@@ -279,10 +447,13 @@ func main() {
 		return nil
 	}
 
+	withSettings(app.Commands)
+
 	err = app.Run(os.Args)
 	if err != nil {
 		print.Erro(err)
 	}
+	print.Result(err)
 
 	err = types.WriteConfig(cacheDir, *config)
 	if err != nil {
@@ -337,6 +508,31 @@ func CheckForUpdates(thisVersion string) {
 	}
 }
 
+// timeout returns a context that is cancelled after the duration of the --timeout flag, if it was
+// not set then the fallback duration is used instead. A zero fallback means no deadline at all.
+func timeout(c *cli.Context, fallback time.Duration) (context.Context, context.CancelFunc) {
+	duration := c.Duration("timeout")
+	if duration == 0 {
+		duration = fallback
+	}
+	ctx := events.WithBus(context.Background(), bus)
+	if duration == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, duration)
+}
+
+// printEvent is the command-line subscriber to the event bus. Most operations already print their
+// progress so this only logs the events that would otherwise go unmentioned, in verbose mode.
+func printEvent(e events.Event) {
+	switch event := e.(type) {
+	case events.DependencyResolved:
+		print.Verb(event.Dependency, "resolved to", event.Commit)
+	case events.FileExtracted:
+		print.Verb("extracted", event.Source, "to", event.Target)
+	}
+}
+
 func platform(c *cli.Context) (platform string) {
 	platform = c.String("platform")
 	if platform == "" {
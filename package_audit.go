@@ -0,0 +1,103 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+	"gopkg.in/segmentio/analytics-go.v3"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+	"github.com/Southclaws/sampctl/util"
+)
+
+var packageAuditFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "dir",
+		Value: ".",
+		Usage: "working directory for the project - by default, uses the current directory",
+	},
+	cli.StringFlag{
+		Name:  "advisories",
+		Value: "",
+		Usage: "URL or path of the advisory feed to check dependencies against, set it under `flags` in the settings to use it by default",
+	},
+	cli.StringFlag{
+		Name:  "severity",
+		Value: string(rook.SeverityLow),
+		Usage: "the least severe advisory that fails the audit: `low`, `medium`, `high` or `critical`",
+	},
+}
+
+func packageAudit(c *cli.Context) error {
+	if c.Bool("verbose") {
+		print.SetVerbose()
+	}
+
+	severity := rook.Severity(c.String("severity"))
+	valid := false
+	for _, s := range rook.Severities {
+		valid = valid || s == severity
+	}
+	if !valid {
+		return errors.Errorf("unknown severity %s, must be one of %v", severity, rook.Severities)
+	}
+
+	source := c.String("advisories")
+	if source == "" {
+		return errors.New("no advisory feed to audit against, set one with --advisories")
+	}
+
+	if config.Metrics {
+		segment.Enqueue(analytics.Track{
+			Event:  "package audit",
+			UserId: config.UserID,
+		})
+	}
+
+	cacheDir, err := download.GetCacheDir()
+	if err != nil {
+		print.Erro("Failed to retrieve cache directory path (attempted <user folder>/.samp) ")
+		return err
+	}
+
+	dir := util.FullPath(c.String("dir"))
+
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	pcx, err := rook.NewPackageContext(ctx, gh, gitAuth, true, dir, platform(c), cacheDir, "")
+	if err != nil {
+		return errors.Wrap(err, "failed to interpret directory as Pawn package")
+	}
+
+	advisories, err := rook.LoadAdvisories(ctx, cacheDir, source)
+	if err != nil {
+		return err
+	}
+
+	findings, err := pcx.Audit(advisories)
+	if err != nil {
+		return errors.Wrap(err, "failed to audit dependencies")
+	}
+
+	failed := 0
+	for _, finding := range findings {
+		if rook.Severity(finding.Advisory.Severity).AtLeast(severity) {
+			print.Erro(finding)
+			failed++
+		} else {
+			print.Warn(finding)
+		}
+		if finding.Advisory.URL != "" {
+			print.Info("  see", finding.Advisory.URL)
+		}
+	}
+
+	if failed > 0 {
+		return cli.NewExitError(errors.Errorf("%d dependencies have %s or more severe advisories", failed, severity).Error(), 1)
+	}
+	print.Info("checked", len(pcx.AllDependencies), "dependencies against", len(advisories.Advisories), "advisories")
+
+	return nil
+}
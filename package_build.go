@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
 
 	"github.com/pkg/errors"
 	"gopkg.in/segmentio/analytics-go.v3"
@@ -26,9 +27,14 @@ var packageBuildFlags = []cli.Flag{
 		Name:  "forceEnsure",
 		Usage: "forces dependency ensure before build",
 	},
+	cli.StringFlag{
+		Name:  "stale",
+		Value: "error",
+		Usage: "what to do when vendored dependencies don't match the package definition: error, ensure or ignore",
+	},
 	cli.BoolFlag{
 		Name:  "dryRun",
-		Usage: "does not run the build but outputs the command necessary to do so",
+		Usage: "does not run the build but outputs the exact compiler command line, with every include path, constant and option resolved",
 	},
 	cli.BoolFlag{
 		Name:  "watch",
@@ -39,10 +45,24 @@ var packageBuildFlags = []cli.Flag{
 		Value: "",
 		Usage: "declares a file to store the incrementing build number for easy versioning",
 	},
+	cli.StringFlag{
+		Name:  "report",
+		Value: "",
+		Usage: "writes a JSON report of the build result to a file, such as for a CI artifact",
+	},
+	cli.StringSliceFlag{
+		Name:  "changed",
+		Usage: "only runs the builds that include this file, directly or through other includes, can be repeated",
+	},
+	cli.BoolFlag{
+		Name:  "rebuild",
+		Usage: "compile even if the output is up to date with its sources",
+	},
 	cli.BoolFlag{
 		Name:  "relativePaths",
 		Usage: "force compiler output to use relative paths instead of absolute",
 	},
+	timingsFlag,
 }
 
 func packageBuild(c *cli.Context) error {
@@ -52,9 +72,12 @@ func packageBuild(c *cli.Context) error {
 
 	dir := util.FullPath(c.String("dir"))
 	forceEnsure := c.Bool("forceEnsure")
+	stale := rook.StalePolicy(c.String("stale"))
 	dryRun := c.Bool("dryRun")
 	watch := c.Bool("watch")
 	buildFile := c.String("buildFile")
+	report := c.String("report")
+	changed := c.StringSlice("changed")
 	relativePaths := c.Bool("relativePaths")
 
 	build := c.Args().Get(0)
@@ -68,9 +91,12 @@ func packageBuild(c *cli.Context) error {
 			UserId: config.UserID,
 			Properties: analytics.NewProperties().
 				Set("forceEnsure", forceEnsure).
+				Set("stale", string(stale)).
 				Set("watch", watch).
 				Set("watch", watch).
 				Set("buildFile", buildFile != "").
+				Set("report", report != "").
+				Set("changed", len(changed)).
 				Set("build", build != "default"),
 		})
 	}
@@ -80,10 +106,22 @@ func packageBuild(c *cli.Context) error {
 		return errors.Wrap(err, "failed to get or create cache directory")
 	}
 
-	pcx, err := rook.NewPackageContext(gh, gitAuth, true, dir, platform(c), cacheDir, "")
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	pcx, err := rook.NewPackageContext(ctx, gh, gitAuth, true, dir, platform(c), cacheDir, "")
 	if err != nil {
 		return errors.Wrap(err, "failed to interpret directory as Pawn package")
 	}
+	pcx.Stale = stale
+	pcx.ReportFile = report
+	pcx.Rebuild = c.Bool("rebuild")
+
+	summarise, err := collectTimings(c)
+	if err != nil {
+		return err
+	}
+	defer summarise()
 
 	if watch {
 		err := pcx.BuildWatch(context.Background(), build, forceEnsure, buildFile, relativePaths, nil)
@@ -91,30 +129,55 @@ func packageBuild(c *cli.Context) error {
 			return cli.NewExitError(err.Error(), 1)
 		}
 	} else {
-		problems, result, err := pcx.Build(context.Background(), build, forceEnsure, dryRun, relativePaths, buildFile)
-		if err != nil {
-			return cli.NewExitError(err.Error(), 1)
+		builds := []string{build}
+		if len(changed) > 0 {
+			builds, err = pcx.AffectedBuilds(ctx, changed)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			if len(builds) == 0 {
+				print.Info("No builds include the changed files")
+				return nil
+			}
+			print.Info("Running builds affected by the changed files:", strings.Join(builds, ", "))
 		}
 
-		if problems.Fatal() {
-			return cli.NewExitError(errors.New("Build encountered fatal error"), 1)
-		} else if len(problems.Errors()) > 0 {
-			return cli.NewExitError(errors.Errorf("Build failed with %d problems", len(problems)), 1)
-		} else if len(problems.Warnings()) > 0 {
-			print.Warn("Build", build, "complete with", len(problems), "problems")
-		} else {
-			print.Info("Build", build, "successful with", len(problems), "problems")
+		for i, name := range builds {
+			// dependencies only need to be ensured once for every build
+			err = runBuild(ctx, pcx, name, forceEnsure && i == 0, dryRun, relativePaths, buildFile)
+			if err != nil {
+				return err
+			}
 		}
+	}
+
+	return nil
+}
 
-		print.Verb(fmt.Sprintf("Results, in bytes: Header: %d, Code: %d, Data: %d, Stack/Heap: %d, Estimated usage: %d, Total: %d\n",
-			result.Header,
-			result.Code,
-			result.Data,
-			result.StackHeap,
-			result.Estimate,
-			result.Total))
+func runBuild(ctx context.Context, pcx *rook.PackageContext, build string, forceEnsure, dryRun, relativePaths bool, buildFile string) error {
+	problems, result, err := pcx.Build(ctx, build, forceEnsure, dryRun, relativePaths, buildFile)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
 	}
 
+	if problems.Fatal() {
+		return cli.NewExitError(errors.New("Build encountered fatal error"), 1)
+	} else if len(problems.Errors()) > 0 {
+		return cli.NewExitError(errors.Errorf("Build failed with %d problems", len(problems)), 1)
+	} else if len(problems.Warnings()) > 0 {
+		print.Warn("Build", build, "complete with", len(problems), "problems")
+	} else {
+		print.Info("Build", build, "successful with", len(problems), "problems")
+	}
+
+	print.Verb(fmt.Sprintf("Results, in bytes: Header: %d, Code: %d, Data: %d, Stack/Heap: %d, Estimated usage: %d, Total: %d\n",
+		result.Header,
+		result.Code,
+		result.Data,
+		result.StackHeap,
+		result.Estimate,
+		result.Total))
+
 	return nil
 }
 
@@ -126,7 +189,7 @@ func packageBuildBash(c *cli.Context) {
 		fmt.Fprintln(os.Stderr, err)
 	}
 
-	pcx, err := rook.NewPackageContext(gh, gitAuth, true, dir, runtime.GOOS, cacheDir, "")
+	pcx, err := rook.NewPackageContext(context.Background(), gh, gitAuth, true, dir, runtime.GOOS, cacheDir, "")
 	if err != nil {
 		return
 	}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"gopkg.in/segmentio/analytics-go.v3"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+	"github.com/Southclaws/sampctl/util"
+)
+
+var packageBumpFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "dir",
+		Value: ".",
+		Usage: "working directory for the project - by default, uses the current directory",
+	},
+}
+
+func packageBump(c *cli.Context) error {
+	if c.Bool("verbose") {
+		print.SetVerbose()
+	}
+
+	if len(c.Args()) != 1 {
+		cli.ShowCommandHelpAndExit(c, "bump", 0)
+		return nil
+	}
+	policy := rook.BumpPolicy(c.Args().First())
+
+	if config.Metrics {
+		segment.Enqueue(analytics.Track{
+			Event:  "package bump",
+			UserId: config.UserID,
+			Properties: analytics.NewProperties().
+				Set("policy", string(policy)),
+		})
+	}
+
+	cacheDir, err := download.GetCacheDir()
+	if err != nil {
+		print.Erro("Failed to retrieve cache directory path (attempted <user folder>/.samp) ")
+		return err
+	}
+
+	dir := util.FullPath(c.String("dir"))
+
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	pcx, err := rook.NewPackageContext(ctx, gh, gitAuth, true, dir, platform(c), cacheDir, "")
+	if err != nil {
+		return errors.Wrap(err, "failed to interpret directory as Pawn package")
+	}
+
+	changes, err := pcx.Bump(ctx, policy)
+	if err != nil {
+		return errors.Wrap(err, "failed to bump dependencies")
+	}
+
+	if len(changes) == 0 {
+		print.Info("no dependency constraints changed")
+		return nil
+	}
+
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+
+	print.Info("bumped", len(changes), "dependency constraints")
+
+	return nil
+}
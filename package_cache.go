@@ -0,0 +1,63 @@
+package main
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/segmentio/analytics-go.v3"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+	"github.com/Southclaws/sampctl/util"
+)
+
+var packageCacheFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "dir",
+		Value: ".",
+		Usage: "working directory for the project - by default, uses the current directory",
+	},
+}
+
+func packageCache(c *cli.Context) error {
+	if c.Bool("verbose") {
+		print.SetVerbose()
+	}
+
+	if config.Metrics {
+		segment.Enqueue(analytics.Track{
+			Event:  "package cache",
+			UserId: config.UserID,
+		})
+	}
+
+	cacheDir, err := download.GetCacheDir()
+	if err != nil {
+		print.Erro("Failed to retrieve cache directory path (attempted <user folder>/.samp) ")
+		return err
+	}
+
+	dir := util.FullPath(c.String("dir"))
+
+	ctx, cancel := timeout(c, time.Hour)
+	defer cancel()
+
+	pcx, err := rook.NewPackageContext(ctx, gh, gitAuth, true, dir, platform(c), cacheDir, "")
+	if err != nil {
+		return errors.Wrap(err, "failed to interpret directory as Pawn package")
+	}
+
+	cached, err := pcx.CacheResources(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to cache resources")
+	}
+
+	for _, meta := range cached {
+		print.Info("cached", meta)
+	}
+	print.Info("cached resources of", len(cached), "dependencies for", pcx.Platform)
+
+	return nil
+}
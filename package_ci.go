@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/segmentio/analytics-go.v3"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+var packageCIFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "dir",
+		Value: ".",
+		Usage: "working directory for the project - by default, uses the current directory",
+	},
+	cli.BoolFlag{
+		Name:  "force",
+		Usage: "overwrite an existing workflow file",
+	},
+}
+
+func packageCI(c *cli.Context) error {
+	if c.Bool("verbose") {
+		print.SetVerbose()
+	}
+
+	if config.Metrics {
+		segment.Enqueue(analytics.Track{
+			Event:  "package ci",
+			UserId: config.UserID,
+		})
+	}
+
+	dir := util.FullPath(c.String("dir"))
+
+	pkg, err := types.PackageFromDir(dir)
+	if err != nil {
+		return errors.Wrap(err, "failed to interpret directory as Pawn package")
+	}
+	pkg.LocalPath = dir
+
+	output := filepath.Join(dir, rook.WorkflowPath)
+	if util.Exists(output) && !c.Bool("force") {
+		return errors.Errorf("%s already exists, use --force to overwrite it", output)
+	}
+
+	workflow, err := rook.GenerateWorkflow(pkg)
+	if err != nil {
+		return errors.Wrap(err, "failed to generate workflow")
+	}
+
+	err = os.MkdirAll(filepath.Dir(output), 0755)
+	if err != nil {
+		return errors.Wrap(err, "failed to create workflow directory")
+	}
+	err = ioutil.WriteFile(output, workflow.Contents, 0644)
+	if err != nil {
+		return errors.Wrap(err, "failed to write workflow")
+	}
+
+	print.Info("wrote", output, "which builds", len(workflow.Builds), "build configs")
+	if !workflow.Frozen {
+		print.Warn("no", types.LockfileName, "so dependencies won't be pinned, run `sampctl package ensure` and commit it")
+	}
+	if len(workflow.Tests) == 0 {
+		print.Info("no runtime configs with the `headless` or `y_testing` mode, so the workflow doesn't run any tests")
+	}
+
+	return nil
+}
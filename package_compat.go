@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"gopkg.in/segmentio/analytics-go.v3"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+var packageCompatFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "dir",
+		Value: ".",
+		Usage: "working directory for the project - by default, uses the current directory",
+	},
+	cli.BoolFlag{
+		Name:  "all",
+		Usage: "also list the symbols that were added",
+	},
+	cli.BoolFlag{
+		Name:  "failOnBreaking",
+		Usage: "exit with an error if there are breaking changes, useful for CI",
+	},
+}
+
+func packageCompat(c *cli.Context) error {
+	if c.Bool("verbose") {
+		print.SetVerbose()
+	}
+
+	if config.Metrics {
+		segment.Enqueue(analytics.Track{
+			Event:  "package compat",
+			UserId: config.UserID,
+		})
+	}
+
+	dir := util.FullPath(c.String("dir"))
+
+	pkg, err := types.PackageFromDir(dir)
+	if err != nil {
+		return errors.Wrap(err, "failed to interpret directory as Pawn package")
+	}
+	pkg.LocalPath = dir
+
+	comparison, err := rook.CompareAPI(pkg, c.Args().Get(0), c.Args().Get(1))
+	if err != nil {
+		return errors.Wrap(err, "failed to compare exported symbols")
+	}
+
+	to := comparison.To
+	if to == "" {
+		to = "the working tree"
+	}
+
+	for _, change := range comparison.Changes {
+		if change.Breaking() || c.Bool("all") {
+			fmt.Println(change)
+		}
+	}
+
+	breaking := comparison.Breaking()
+	if len(breaking) == 0 {
+		print.Info("no breaking changes from", comparison.From, "to", to+", a", comparison.Bump(), "version bump is enough")
+		return nil
+	}
+
+	print.Warn(len(breaking), "potential breaking changes from", comparison.From, "to", to+", a major version bump is needed")
+	if c.Bool("failOnBreaking") {
+		return cli.NewExitError("exported symbols changed incompatibly", 1)
+	}
+
+	return nil
+}
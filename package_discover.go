@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"gopkg.in/segmentio/analytics-go.v3"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+var packageDiscoverFlags = []cli.Flag{}
+
+func packageDiscover(c *cli.Context) error {
+	if c.Bool("verbose") {
+		print.SetVerbose()
+	}
+
+	if config.Metrics {
+		segment.Enqueue(analytics.Track{
+			Event:  "package discover",
+			UserId: config.UserID,
+		})
+	}
+
+	if len(c.Args()) != 1 {
+		cli.ShowCommandHelpAndExit(c, "discover", 0)
+		return nil
+	}
+
+	meta, err := versioning.DependencyString(c.Args().First()).Explode()
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse %s as a dependency string", c.Args().First())
+	}
+
+	cacheDir, err := download.GetCacheDir()
+	if err != nil {
+		print.Erro("Failed to retrieve cache directory path (attempted <user folder>/.samp) ")
+		return err
+	}
+
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	resources, err := rook.DiscoverResources(ctx, gh, meta, cacheDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to discover release assets")
+	}
+
+	if len(resources) == 0 {
+		print.Info(meta, "release does not contain any recognisable plugin assets")
+		return nil
+	}
+
+	contents, err := json.MarshalIndent(resources, "", "\t")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode resources")
+	}
+
+	print.Info("Candidate resources for", meta, "- check these before adding them to the package definition")
+	fmt.Println(string(contents))
+
+	return nil
+}
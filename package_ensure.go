@@ -1,7 +1,9 @@
 package main
 
 import (
-	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -24,6 +26,48 @@ var packageEnsureFlags = []cli.Flag{
 		Name:  "update",
 		Usage: "update cached dependencies to latest version",
 	},
+	cli.BoolFlag{
+		Name:  "frozen",
+		Usage: "ensure dependencies at their locked versions and fail if the lockfile would change, useful for CI",
+	},
+	cli.StringFlag{
+		Name:  "strategy",
+		Value: "",
+		Usage: "how version constraints are resolved: `newest`, `minimal`, `upstream` or `locked` - by default, uses the strategy recorded in the lockfile",
+	},
+	cli.BoolFlag{
+		Name:  "check",
+		Usage: "compile each dependency's entry script on its own afterwards and report those that are broken",
+	},
+	cli.BoolFlag{
+		Name:  "refresh",
+		Usage: "resolve version constraints again instead of reusing the versions other packages resolved them to recently",
+	},
+	cli.DurationFlag{
+		Name:  "resolutionTTL",
+		Value: rook.DefaultResolutionTTL,
+		Usage: "how long the version a constraint resolved to is reused for by packages sharing the cache, negative to never reuse them",
+	},
+	cli.BoolFlag{
+		Name:  "interactive",
+		Usage: "ask how to settle version constraints that conflict instead of failing, the decision is written to the package definition",
+	},
+	cli.StringFlag{
+		Name:  "flat",
+		Value: "",
+		Usage: "also put the include files of every dependency directly under this `directory`, for tools that expect a single include directory",
+	},
+	cli.StringFlag{
+		Name:  "ref",
+		Value: "",
+		Usage: "ensure the dependencies the package declared at this tag, branch or commit instead, without touching the working tree",
+	},
+	cli.StringFlag{
+		Name:  "refDir",
+		Value: "",
+		Usage: "`directory` to ensure the dependencies of --ref into - by default, a directory in the cache named after the package and ref",
+	},
+	timingsFlag,
 }
 
 func packageEnsure(c *cli.Context) error {
@@ -54,22 +98,88 @@ func packageEnsure(c *cli.Context) error {
 	dir := util.FullPath(c.String("dir"))
 	forceUpdate := c.Bool("update")
 
-	pcx, err := rook.NewPackageContext(gh, gitAuth, true, dir, platform(c), cacheDir, "")
+	ctx, cancel := timeout(c, time.Hour)
+	defer cancel()
+
+	pcx, err := rook.NewPackageContext(ctx, gh, gitAuth, true, dir, platform(c), cacheDir, "")
 	if err != nil {
 		return errors.Wrap(err, "failed to interpret directory as Pawn package")
 	}
 
 	pcx.Package.Runtime = rook.GetRuntimeConfig(pcx.Package, runtimeName)
+	pcx.Frozen = c.Bool("frozen")
+	pcx.Strategy = rook.ResolutionStrategy(c.String("strategy"))
+	pcx.Refresh = c.Bool("refresh") || forceUpdate
+	pcx.ResolutionTTL = c.Duration("resolutionTTL")
+	if c.Bool("interactive") {
+		pcx.ResolveConflict = rook.PromptConflictResolution
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
-	defer cancel()
+	summarise, err := collectTimings(c)
+	if err != nil {
+		return err
+	}
+	defer summarise()
+
+	if ref := c.String("ref"); ref != "" {
+		refDir := c.String("refDir")
+		if refDir == "" {
+			name := fmt.Sprintf("%s-%s", pcx.Package.User, pcx.Package.Repo)
+			refDir = filepath.Join(cacheDir, "revisions", name, strings.Replace(ref, "/", "-", -1))
+		}
+		refDir = util.FullPath(refDir)
 
-	err = pcx.EnsureDependencies(ctx, forceUpdate)
+		pcx, err = pcx.EnsureRevision(ctx, ref, refDir)
+		if err != nil {
+			return errors.Wrap(err, "failed to ensure")
+		}
+		print.Info("ensured dependencies of", ref, "in", refDir)
+	} else {
+		err = pcx.EnsureDependencies(ctx, forceUpdate)
+		if err != nil {
+			return errors.Wrap(err, "failed to ensure")
+		}
+
+		print.Info("ensured dependencies for package")
+	}
+
+	if flat := c.String("flat"); flat != "" {
+		if !filepath.IsAbs(flat) {
+			flat = filepath.Join(pcx.Package.LocalPath, flat)
+		}
+		files, errFlat := pcx.EnsureFlat(flat)
+		if errFlat != nil {
+			return errors.Wrap(errFlat, "failed to ensure flat include directory")
+		}
+		print.Info("put", files, "include files in", flat)
+	}
+
+	if !c.Bool("check") {
+		return nil
+	}
+
+	checks, err := pcx.CheckDependencies(ctx, "")
 	if err != nil {
-		return errors.Wrap(err, "failed to ensure")
+		return errors.Wrap(err, "failed to check dependencies")
+	}
+
+	broken := 0
+	for _, check := range checks {
+		if check.Broken() {
+			broken++
+			print.Erro(check)
+			for _, problem := range check.Problems {
+				print.Erro("  ", problem)
+			}
+		} else {
+			print.Verb(check)
+		}
+	}
+	if broken > 0 {
+		return errors.Errorf("%d dependencies failed to compile on their own", broken)
 	}
 
-	print.Info("ensured dependencies for package")
+	print.Info("all dependencies compile on their own")
 
 	return nil
 }
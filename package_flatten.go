@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/segmentio/analytics-go.v3"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+	"github.com/Southclaws/sampctl/util"
+)
+
+var packageFlattenFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "dir",
+		Value: ".",
+		Usage: "working directory for the project - by default, uses the current directory",
+	},
+	cli.StringFlag{
+		Name:  "build",
+		Value: "",
+		Usage: "build configuration to use the include paths of",
+	},
+	cli.StringFlag{
+		Name:  "output",
+		Value: "",
+		Usage: "file to write the flattened include to - by default, `dist/<repo>.inc` in the package directory",
+	},
+}
+
+func packageFlatten(c *cli.Context) error {
+	if c.Bool("verbose") {
+		print.SetVerbose()
+	}
+
+	if config.Metrics {
+		segment.Enqueue(analytics.Track{
+			Event:  "package flatten",
+			UserId: config.UserID,
+		})
+	}
+
+	cacheDir, err := download.GetCacheDir()
+	if err != nil {
+		print.Erro("Failed to retrieve cache directory path (attempted <user folder>/.samp) ")
+		return err
+	}
+
+	dir := util.FullPath(c.String("dir"))
+
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	pcx, err := rook.NewPackageContext(ctx, gh, gitAuth, true, dir, platform(c), cacheDir, "")
+	if err != nil {
+		return errors.Wrap(err, "failed to interpret directory as Pawn package")
+	}
+
+	flattened, err := pcx.Flatten(ctx, c.String("build"))
+	if err != nil {
+		return errors.Wrap(err, "failed to flatten package includes")
+	}
+
+	output := c.String("output")
+	if output == "" {
+		output = filepath.Join(dir, "dist", pcx.Package.Repo+".inc")
+	}
+
+	err = os.MkdirAll(filepath.Dir(output), 0700)
+	if err != nil {
+		return errors.Wrap(err, "failed to create output directory")
+	}
+	err = ioutil.WriteFile(output, flattened.Contents, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write flattened include")
+	}
+
+	print.Info("flattened", len(flattened.Inlined), "files into", output)
+	if len(flattened.External) > 0 {
+		print.Info("users of the file must provide:", flattened.External)
+	}
+	if len(flattened.Unresolved) > 0 {
+		print.Warn(len(flattened.Unresolved), "includes could not be resolved and were left as-is")
+	}
+
+	return nil
+}
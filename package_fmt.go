@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+	"gopkg.in/segmentio/analytics-go.v3"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+var packageFmtFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "dir",
+		Value: ".",
+		Usage: "working directory for the project - by default, uses the current directory",
+	},
+}
+
+func packageFmt(c *cli.Context) error {
+	if c.Bool("verbose") {
+		print.SetVerbose()
+	}
+
+	if config.Metrics {
+		segment.Enqueue(analytics.Track{
+			Event:  "package fmt",
+			UserId: config.UserID,
+		})
+	}
+
+	dir := util.FullPath(c.String("dir"))
+
+	err := rook.Format(types.Package{LocalPath: dir})
+	if err != nil {
+		return errors.Wrap(err, "failed to format package definition")
+	}
+
+	print.Info("formatted package definition")
+
+	return nil
+}
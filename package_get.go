@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -51,7 +50,10 @@ func packageGet(c *cli.Context) error {
 		dir = util.FullPath(".")
 	}
 
-	err = rook.Get(context.Background(), gh, dep, dir, gitAuth, platform(c), cacheDir)
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	err = rook.Get(ctx, gh, dep, dir, gitAuth, platform(c), cacheDir)
 	if err != nil {
 		return err
 	}
@@ -1,8 +1,6 @@
 package main
 
 import (
-	"context"
-
 	"github.com/pkg/errors"
 	"gopkg.in/segmentio/analytics-go.v3"
 	"gopkg.in/urfave/cli.v1"
@@ -41,12 +39,15 @@ func packageInit(c *cli.Context) error {
 		return err
 	}
 
-	_, err = rook.NewPackageContext(gh, gitAuth, true, dir, platform(c), cacheDir, "")
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	_, err = rook.NewPackageContext(ctx, gh, gitAuth, true, dir, platform(c), cacheDir, "")
 	if err == nil {
 		return errors.New("Directory already appears to be a package")
 	}
 
-	err = rook.Init(context.Background(), gh, dir, config, gitAuth, platform(c), cacheDir)
+	err = rook.Init(ctx, gh, dir, config, gitAuth, platform(c), cacheDir)
 
 	return err
 }
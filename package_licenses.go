@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"gopkg.in/segmentio/analytics-go.v3"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+	"github.com/Southclaws/sampctl/util"
+)
+
+var packageLicensesFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "dir",
+		Value: ".",
+		Usage: "working directory for the project - by default, uses the current directory",
+	},
+	cli.BoolFlag{
+		Name:  "strict",
+		Usage: "exit with an error if any dependency has an unknown, incompatible or missing license",
+	},
+}
+
+func packageLicenses(c *cli.Context) error {
+	if c.Bool("verbose") {
+		print.SetVerbose()
+	}
+
+	if config.Metrics {
+		segment.Enqueue(analytics.Track{
+			Event:  "package licenses",
+			UserId: config.UserID,
+		})
+	}
+
+	cacheDir, err := download.GetCacheDir()
+	if err != nil {
+		print.Erro("Failed to retrieve cache directory path (attempted <user folder>/.samp) ")
+		return err
+	}
+
+	dir := util.FullPath(c.String("dir"))
+
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	pcx, err := rook.NewPackageContext(ctx, gh, gitAuth, true, dir, platform(c), cacheDir, "")
+	if err != nil {
+		return errors.Wrap(err, "failed to interpret directory as Pawn package")
+	}
+
+	licenses, err := pcx.Licenses()
+	if err != nil {
+		return errors.Wrap(err, "failed to scan dependency licenses")
+	}
+
+	if len(licenses) == 0 {
+		print.Info(pcx.Package, "has no dependencies")
+		return nil
+	}
+
+	counts := make(map[string]int)
+	flagged := 0
+	for _, license := range licenses {
+		if license.Status == rook.LicenseOK {
+			counts[license.License]++
+			fmt.Println(license)
+			continue
+		}
+		flagged++
+		print.Warn(license)
+	}
+
+	names := make([]string, 0, len(counts))
+	for license := range counts {
+		names = append(names, license)
+	}
+	sort.Strings(names)
+	for _, license := range names {
+		print.Info(license, "-", counts[license], "dependencies")
+	}
+	if flagged > 0 {
+		print.Warn(flagged, "of", len(licenses), "dependencies have an unknown, incompatible or missing license")
+		if c.Bool("strict") {
+			return errors.New("dependency licenses require attention")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/segmentio/analytics-go.v3"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+var packagePlanFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "dir",
+		Value: ".",
+		Usage: "working directory for the project - by default, uses the current directory",
+	},
+}
+
+func packagePlan(c *cli.Context) error {
+	if c.Bool("verbose") {
+		print.SetVerbose()
+	}
+
+	if config.Metrics {
+		segment.Enqueue(analytics.Track{
+			Event:  "package plan",
+			UserId: config.UserID,
+		})
+	}
+
+	var target versioning.DependencyMeta
+	if len(c.Args()) > 1 {
+		cli.ShowCommandHelpAndExit(c, "plan", 0)
+		return nil
+	} else if len(c.Args()) == 1 {
+		var err error
+		target, err = versioning.DependencyString(c.Args().First()).Explode()
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse %s as a dependency string", c.Args().First())
+		}
+	}
+
+	cacheDir, err := download.GetCacheDir()
+	if err != nil {
+		print.Erro("Failed to retrieve cache directory path (attempted <user folder>/.samp) ")
+		return err
+	}
+
+	dir := util.FullPath(c.String("dir"))
+
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	pcx, err := rook.NewPackageContext(ctx, gh, gitAuth, true, dir, platform(c), cacheDir, "")
+	if err != nil {
+		return errors.Wrap(err, "failed to interpret directory as Pawn package")
+	}
+
+	plan, err := pcx.UpdatePlan(ctx, target)
+	if err != nil {
+		return errors.Wrap(err, "failed to plan update")
+	}
+
+	if len(plan.Changes) == 0 {
+		print.Info(pcx.Package, "dependencies are up to date")
+		return nil
+	}
+
+	for _, change := range plan.Changes {
+		fmt.Println(change)
+		for _, dep := range change.Added {
+			fmt.Println("  + adds", dep)
+		}
+		for _, dep := range change.Removed {
+			fmt.Println("  - removes", dep)
+		}
+		for _, commit := range change.Commits {
+			fmt.Println("   ", commit)
+		}
+		tags := make([]string, 0, len(change.Notes))
+		for tag := range change.Notes {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		for _, tag := range tags {
+			fmt.Printf("  %s:\n    %s\n", tag, strings.Replace(change.Notes[tag], "\n", "\n    ", -1))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/segmentio/analytics-go.v3"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+var packageProvidesFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "dir",
+		Value: ".",
+		Usage: "working directory for the project - by default, uses the current directory",
+	},
+}
+
+func packageProvides(c *cli.Context) error {
+	if c.Bool("verbose") {
+		print.SetVerbose()
+	}
+
+	if config.Metrics {
+		segment.Enqueue(analytics.Track{
+			Event:  "package provides",
+			UserId: config.UserID,
+		})
+	}
+
+	if len(c.Args()) != 1 {
+		cli.ShowCommandHelpAndExit(c, "provides", 0)
+		return nil
+	}
+	include := c.Args().First()
+
+	cacheDir, err := download.GetCacheDir()
+	if err != nil {
+		print.Erro("Failed to retrieve cache directory path (attempted <user folder>/.samp) ")
+		return err
+	}
+
+	dir := util.FullPath(c.String("dir"))
+
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	pcx, err := rook.NewPackageContext(ctx, gh, gitAuth, true, dir, platform(c), cacheDir, "")
+	if err != nil {
+		return errors.Wrap(err, "failed to interpret directory as Pawn package")
+	}
+
+	providers, err := rook.Provides(pcx.Package, include)
+	if err != nil {
+		return errors.Wrap(err, "failed to find include")
+	}
+
+	if len(providers) > 0 {
+		for _, meta := range providers {
+			fmt.Println(meta)
+		}
+		if len(providers) > 1 {
+			print.Warn("more than one dependency provides", include+", the first include path the compiler searches wins")
+		}
+		return nil
+	}
+
+	print.Info("no vendored dependency provides", include)
+
+	index, err := rook.LoadIndex(ctx, cacheDir)
+	if err != nil {
+		print.Verb("failed to load package index:", err)
+		return nil
+	}
+	for _, dep := range rook.SuggestProviders(index, include) {
+		meta, errInner := dep.Explode()
+		if errInner != nil {
+			continue
+		}
+		if declared(pcx.Package.GetAllDependencies(), meta) {
+			print.Info(dep, "provides it and is a dependency, run `sampctl package ensure` to vendor it")
+		} else {
+			print.Info(dep, "provides it but is not a dependency, run `sampctl package install", dep+"` to add it")
+		}
+	}
+
+	return nil
+}
+
+// declared is true if a list of dependencies has one with the same user and repo as a dependency
+func declared(depStrings []versioning.DependencyString, meta versioning.DependencyMeta) bool {
+	for _, depString := range depStrings {
+		other, err := depString.Explode()
+		if err == nil && strings.EqualFold(other.User, meta.User) && strings.EqualFold(other.Repo, meta.Repo) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"gopkg.in/AlecAivazis/survey.v1"
+	"gopkg.in/segmentio/analytics-go.v3"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+var packagePruneFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "dir",
+		Value: ".",
+		Usage: "working directory for the project - by default, uses the current directory",
+	},
+	cli.BoolFlag{
+		Name:  "remove",
+		Usage: "remove the unused dependencies from the package definition after confirming",
+	},
+}
+
+func packagePrune(c *cli.Context) error {
+	if c.Bool("verbose") {
+		print.SetVerbose()
+	}
+
+	remove := c.Bool("remove")
+
+	if config.Metrics {
+		segment.Enqueue(analytics.Track{
+			Event:  "package prune",
+			UserId: config.UserID,
+			Properties: analytics.NewProperties().
+				Set("remove", remove),
+		})
+	}
+
+	cacheDir, err := download.GetCacheDir()
+	if err != nil {
+		print.Erro("Failed to retrieve cache directory path (attempted <user folder>/.samp) ")
+		return err
+	}
+
+	dir := util.FullPath(c.String("dir"))
+
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	pcx, err := rook.NewPackageContext(ctx, gh, gitAuth, true, dir, platform(c), cacheDir, "")
+	if err != nil {
+		return errors.Wrap(err, "failed to interpret directory as Pawn package")
+	}
+
+	unused, err := pcx.UnusedDependencies(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to find unused dependencies")
+	}
+	if len(unused) == 0 {
+		print.Info("every dependency is included by a build")
+		return nil
+	}
+
+	var removable []versioning.DependencyString
+	for _, dependency := range unused {
+		if len(dependency.Provides) > 0 {
+			print.Warn(dependency.Dependency, "is not included but provides", dependency.Provides, "which are, declare them directly before removing it")
+			continue
+		}
+		fmt.Println(dependency.Dependency)
+		removable = append(removable, dependency.Dependency)
+	}
+	if !remove || len(removable) == 0 {
+		return nil
+	}
+
+	confirmed := false
+	err = survey.AskOne(&survey.Confirm{
+		Message: fmt.Sprintf("Remove %d unused dependencies from the package definition?", len(removable)),
+		Default: false,
+	}, &confirmed, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to open wizard")
+	}
+	if !confirmed {
+		return nil
+	}
+
+	pruned := make(map[versioning.DependencyString]bool)
+	for _, dep := range removable {
+		pruned[dep] = true
+	}
+	kept := []versioning.DependencyString{}
+	for _, dep := range pcx.Package.Dependencies {
+		if !pruned[dep] {
+			kept = append(kept, dep)
+		}
+	}
+	pcx.Package.Dependencies = kept
+
+	err = pcx.Package.WriteDefinition()
+	if err != nil {
+		return errors.Wrap(err, "failed to write package definition")
+	}
+
+	print.Info("removed", len(removable), "dependencies, run `sampctl package ensure` to update the lockfile")
+
+	return nil
+}
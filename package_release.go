@@ -1,7 +1,7 @@
 package main
 
 import (
-	"context"
+	"path/filepath"
 
 	"github.com/pkg/errors"
 	"gopkg.in/segmentio/analytics-go.v3"
@@ -19,6 +19,20 @@ var packageReleaseFlags = []cli.Flag{
 		Value: ".",
 		Usage: "working directory for the project - by default, uses the current directory",
 	},
+	cli.BoolFlag{
+		Name:  "archive",
+		Usage: "export the release archives described by the package resources instead of running the release wizard",
+	},
+	cli.StringFlag{
+		Name:  "version",
+		Value: "",
+		Usage: "version to name the release archives with - by default, uses the current tag of the package",
+	},
+	cli.StringFlag{
+		Name:  "output",
+		Value: "release",
+		Usage: "directory to write the release archives and their checksums to, relative to the working directory",
+	},
 }
 
 func packageRelease(c *cli.Context) error {
@@ -41,12 +55,35 @@ func packageRelease(c *cli.Context) error {
 		return err
 	}
 
-	pcx, err := rook.NewPackageContext(gh, gitAuth, true, dir, platform(c), cacheDir, "")
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	pcx, err := rook.NewPackageContext(ctx, gh, gitAuth, true, dir, platform(c), cacheDir, "")
 	if err != nil {
 		return errors.Wrap(err, "failed to interpret directory as Pawn package")
 	}
 
-	err = rook.Release(context.Background(), gh, gitAuth, pcx.Package)
+	if c.Bool("archive") {
+		version := c.String("version")
+		if version == "" {
+			version = pcx.Package.Tag
+		}
+		output := c.String("output")
+		if !filepath.IsAbs(output) {
+			output = filepath.Join(dir, output)
+		}
+		err = rook.CheckVersionDefine(pcx.Package, version)
+		if err != nil {
+			return err
+		}
+		_, err = rook.ExportRelease(pcx.Package, version, c.String("platform"), output)
+		if err != nil {
+			return errors.Wrap(err, "failed to export release archives")
+		}
+		return nil
+	}
+
+	err = rook.Release(ctx, gh, gitAuth, pcx.Package)
 	if err != nil {
 		return errors.Wrap(err, "failed to release")
 	}
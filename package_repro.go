@@ -0,0 +1,77 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/segmentio/analytics-go.v3"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+	"github.com/Southclaws/sampctl/util"
+)
+
+var packageReproFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "dir",
+		Value: ".",
+		Usage: "working directory for the project - by default, uses the current directory",
+	},
+	cli.StringFlag{
+		Name:  "output",
+		Value: "repro",
+		Usage: "directory to write the reproduction to, relative to the working directory",
+	},
+	cli.BoolFlag{
+		Name:  "archive",
+		Usage: "also write an archive with the entry script and every file it includes, to reproduce the build offline",
+	},
+}
+
+func packageRepro(c *cli.Context) error {
+	if c.Bool("verbose") {
+		print.SetVerbose()
+	}
+
+	if config.Metrics {
+		segment.Enqueue(analytics.Track{
+			Event:  "package repro",
+			UserId: config.UserID,
+		})
+	}
+
+	cacheDir, err := download.GetCacheDir()
+	if err != nil {
+		print.Erro("Failed to retrieve cache directory path (attempted <user folder>/.samp) ")
+		return err
+	}
+
+	dir := util.FullPath(c.String("dir"))
+	build := c.Args().Get(0)
+	output := c.String("output")
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(dir, output)
+	}
+
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	pcx, err := rook.NewPackageContext(ctx, gh, gitAuth, true, dir, platform(c), cacheDir, "")
+	if err != nil {
+		return errors.Wrap(err, "failed to interpret directory as Pawn package")
+	}
+	pcx.AppVersion = c.App.Version
+
+	repro, err := pcx.Reproduce(ctx, build, output, c.Bool("archive"))
+	if err != nil {
+		return errors.Wrap(err, "failed to write reproduction")
+	}
+
+	print.Info("wrote reproduction to", output)
+	if repro.Archive != "" {
+		print.Info("attach", repro.Archive, "to the bug report")
+	}
+	return nil
+}
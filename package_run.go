@@ -27,7 +27,7 @@ var packageRunFlags = []cli.Flag{
 	cli.StringFlag{
 		Name:  "build",
 		Value: "",
-		Usage: "build configuration to use if `--forceBuild` is set",
+		Usage: "build configuration whose output and runtime settings are run, and which is built if `--forceBuild` is set",
 	},
 	cli.BoolFlag{
 		Name:  "forceBuild",
@@ -37,10 +37,19 @@ var packageRunFlags = []cli.Flag{
 		Name:  "forceEnsure",
 		Usage: "forces dependency ensure before build if `--forceBuild` is set",
 	},
+	cli.StringFlag{
+		Name:  "stale",
+		Value: "error",
+		Usage: "what to do when vendored dependencies don't match the package definition: error, ensure or ignore",
+	},
 	cli.BoolFlag{
 		Name:  "noCache",
 		Usage: "forces download of plugins if `--forceEnsure` is set",
 	},
+	cli.BoolFlag{
+		Name:  "frozen",
+		Usage: "run the server and dependencies locked in the lockfile and fail if the lockfile would change, useful for CI",
+	},
 	cli.BoolFlag{
 		Name:  "watch",
 		Usage: "keeps sampctl running and triggers builds whenever source files change",
@@ -66,6 +75,7 @@ func packageRun(c *cli.Context) error {
 	build := c.String("build")
 	forceBuild := c.Bool("forceBuild")
 	forceEnsure := c.Bool("forceEnsure")
+	stale := rook.StalePolicy(c.String("stale"))
 	noCache := c.Bool("noCache")
 	watch := c.Bool("watch")
 	buildFile := c.String("buildFile")
@@ -85,6 +95,7 @@ func packageRun(c *cli.Context) error {
 				Set("build", build).
 				Set("forceBuild", forceBuild).
 				Set("forceEnsure", forceEnsure).
+				Set("stale", string(stale)).
 				Set("noCache", noCache).
 				Set("watch", watch).
 				Set("buildFile", buildFile != "").
@@ -98,7 +109,11 @@ func packageRun(c *cli.Context) error {
 		return err
 	}
 
-	pcx, err := rook.NewPackageContext(gh, gitAuth, true, dir, platform(c), cacheDir, "")
+	// the timeout only applies to loading the package, not to the server once it runs
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	pcx, err := rook.NewPackageContext(ctx, gh, gitAuth, true, dir, platform(c), cacheDir, "")
 	if err != nil {
 		return errors.Wrap(err, "failed to interpret directory as Pawn package")
 	}
@@ -110,7 +125,9 @@ func packageRun(c *cli.Context) error {
 	pcx.BuildName = build
 	pcx.ForceBuild = forceBuild
 	pcx.ForceEnsure = forceEnsure
+	pcx.Stale = stale
 	pcx.NoCache = noCache
+	pcx.Frozen = c.Bool("frozen")
 	pcx.BuildFile = buildFile
 	pcx.Relative = relativePaths
 
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"gopkg.in/segmentio/analytics-go.v3"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+var packageRunScriptFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "dir",
+		Value: ".",
+		Usage: "working directory for the project - by default, uses the current directory",
+	},
+	cli.StringFlag{
+		Name:  "build",
+		Value: "default",
+		Usage: "build configuration whose include paths and outputs are passed to the script",
+	},
+	cli.StringFlag{
+		Name:  "dependency",
+		Value: "",
+		Usage: "runs the script of a vendored dependency instead of the package's own, requires --allowDependencyScripts",
+	},
+	cli.BoolFlag{
+		Name:  "allowDependencyScripts",
+		Usage: "allows running the scripts of dependencies, which are disabled by default",
+	},
+}
+
+func packageRunScript(c *cli.Context) error {
+	if c.Bool("verbose") {
+		print.SetVerbose()
+	}
+
+	if config.Metrics {
+		segment.Enqueue(analytics.Track{
+			Event:  "package run-script",
+			UserId: config.UserID,
+		})
+	}
+
+	cacheDir, err := download.GetCacheDir()
+	if err != nil {
+		print.Erro("Failed to retrieve cache directory path (attempted <user folder>/.samp) ")
+		return err
+	}
+
+	dir := util.FullPath(c.String("dir"))
+
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	pcx, err := rook.NewPackageContext(ctx, gh, gitAuth, true, dir, platform(c), cacheDir, "")
+	if err != nil {
+		return errors.Wrap(err, "failed to interpret directory as Pawn package")
+	}
+
+	if len(c.Args()) == 0 {
+		names := rook.ScriptNames(pcx.Package)
+		if len(names) == 0 {
+			print.Info(pcx.Package, "has no scripts")
+			return nil
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	}
+	name, args := c.Args().First(), c.Args().Tail()
+
+	if c.String("dependency") != "" {
+		dep, errInner := versioning.DependencyString(c.String("dependency")).Explode()
+		if errInner != nil {
+			return errors.Wrapf(errInner, "failed to parse %s as a dependency string", c.String("dependency"))
+		}
+		return pcx.RunDependencyScript(ctx, dep, name, c.String("build"), args, c.Bool("allowDependencyScripts"))
+	}
+
+	return pcx.RunScript(ctx, name, c.String("build"), args)
+}
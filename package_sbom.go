@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/segmentio/analytics-go.v3"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+	"github.com/Southclaws/sampctl/util"
+)
+
+var packageSBOMFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "dir",
+		Value: ".",
+		Usage: "working directory for the project - by default, uses the current directory",
+	},
+	cli.StringFlag{
+		Name:  "output",
+		Value: "",
+		Usage: "file to write the bill of materials to, relative to the working directory - by default, it's printed",
+	},
+	cli.StringFlag{
+		Name:  "advisories",
+		Value: "",
+		Usage: "URL or path of an advisory feed, the advisories that affect dependencies are listed as vulnerabilities",
+	},
+}
+
+func packageSBOM(c *cli.Context) error {
+	if c.Bool("verbose") {
+		print.SetVerbose()
+	}
+
+	if config.Metrics {
+		segment.Enqueue(analytics.Track{
+			Event:  "package sbom",
+			UserId: config.UserID,
+		})
+	}
+
+	cacheDir, err := download.GetCacheDir()
+	if err != nil {
+		print.Erro("Failed to retrieve cache directory path (attempted <user folder>/.samp) ")
+		return err
+	}
+
+	dir := util.FullPath(c.String("dir"))
+
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	pcx, err := rook.NewPackageContext(ctx, gh, gitAuth, true, dir, platform(c), cacheDir, "")
+	if err != nil {
+		return errors.Wrap(err, "failed to interpret directory as Pawn package")
+	}
+	pcx.AppVersion = c.App.Version
+
+	bom, err := pcx.SBOM()
+	if err != nil {
+		return errors.Wrap(err, "failed to generate bill of materials")
+	}
+
+	if source := c.String("advisories"); source != "" {
+		advisories, errAudit := rook.LoadAdvisories(ctx, cacheDir, source)
+		if errAudit != nil {
+			return errAudit
+		}
+		findings, errAudit := pcx.Audit(advisories)
+		if errAudit != nil {
+			return errors.Wrap(errAudit, "failed to audit dependencies")
+		}
+		bom.AddFindings(findings)
+	}
+
+	contents, err := json.MarshalIndent(bom, "", "\t")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode bill of materials")
+	}
+	contents = append(contents, '\n')
+
+	output := c.String("output")
+	if output == "" {
+		_, err = os.Stdout.Write(contents)
+		return err
+	}
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(dir, output)
+	}
+	err = ioutil.WriteFile(output, contents, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write bill of materials")
+	}
+	print.Info("wrote bill of materials of", len(bom.Components), "dependencies to", output)
+
+	return nil
+}
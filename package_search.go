@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/segmentio/analytics-go.v3"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+)
+
+var packageSearchFlags = []cli.Flag{
+	cli.IntFlag{
+		Name:  "limit",
+		Value: 20,
+		Usage: "how many of the best matches to list, 0 lists every match",
+	},
+}
+
+func packageSearch(c *cli.Context) error {
+	if c.Bool("verbose") {
+		print.SetVerbose()
+	}
+
+	if len(c.Args()) == 0 {
+		cli.ShowCommandHelpAndExit(c, "search", 0)
+		return nil
+	}
+	query := strings.Join(c.Args(), " ")
+
+	if config.Metrics {
+		segment.Enqueue(analytics.Track{
+			Event:  "package search",
+			UserId: config.UserID,
+		})
+	}
+
+	cacheDir, err := download.GetCacheDir()
+	if err != nil {
+		print.Erro("Failed to retrieve cache directory path (attempted <user folder>/.samp) ")
+		return err
+	}
+
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	index, err := rook.LoadIndex(ctx, cacheDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to load package index")
+	}
+
+	results := rook.SearchIndex(index, query)
+	if len(results) == 0 {
+		print.Info("no packages match", query)
+		return nil
+	}
+	if limit := c.Int("limit"); limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	for _, entry := range results {
+		if entry.Description == "" {
+			fmt.Println(entry.User + "/" + entry.Repo)
+		} else {
+			fmt.Printf("%s/%s - %s\n", entry.User, entry.Repo, entry.Description)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/segmentio/analytics-go.v3"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+	"github.com/Southclaws/sampctl/util"
+)
+
+var packageTestFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "dir",
+		Value: ".",
+		Usage: "working directory for the project - by default, uses the current directory",
+	},
+	cli.StringFlag{
+		Name:  "build",
+		Value: "",
+		Usage: "build configuration that compiles the test entry - by default, uses the default build",
+	},
+	cli.StringFlag{
+		Name:  "backend",
+		Value: string(rook.TestBackendMarkers),
+		Usage: "how test results are read from the server output: `markers` or `y_testing`",
+	},
+	cli.DurationFlag{
+		Name:  "timeout",
+		Value: rook.DefaultTestTimeout,
+		Usage: "how long the tests may take to run before the run fails",
+	},
+	cli.BoolFlag{
+		Name:  "container",
+		Usage: "runs the tests in a Linux container instead of in the current directory",
+	},
+	cli.BoolFlag{
+		Name:  "forceEnsure",
+		Usage: "forces dependency ensure before the test build",
+	},
+	cli.StringFlag{
+		Name:  "report",
+		Value: "",
+		Usage: "file to write a JSON report of the result of each test to, such as for a CI artifact",
+	},
+}
+
+func packageTest(c *cli.Context) error {
+	if c.Bool("verbose") {
+		print.SetVerbose()
+	}
+
+	dir := util.FullPath(c.String("dir"))
+	backend := rook.TestBackend(c.String("backend"))
+
+	runtimeName := c.Args().Get(0)
+	if runtimeName == "" {
+		runtimeName = "default"
+	}
+
+	if config.Metrics {
+		segment.Enqueue(analytics.Track{
+			Event:  "package test",
+			UserId: config.UserID,
+			Properties: analytics.NewProperties().
+				Set("runtime", runtimeName != "default").
+				Set("backend", string(backend)).
+				Set("container", c.Bool("container")),
+		})
+	}
+
+	cacheDir, err := download.GetCacheDir()
+	if err != nil {
+		print.Erro("Failed to retrieve cache directory path (attempted <user folder>/.samp) ")
+		return err
+	}
+
+	// --timeout limits how long the tests run, not loading the package
+	pcx, err := rook.NewPackageContext(context.Background(), gh, gitAuth, true, dir, platform(c), cacheDir, "")
+	if err != nil {
+		return errors.Wrap(err, "failed to interpret directory as Pawn package")
+	}
+	pcx.Container = c.Bool("container")
+	pcx.ForceEnsure = c.Bool("forceEnsure")
+	pcx.AppVersion = c.App.Version
+	pcx.CacheDir = cacheDir
+
+	result, err := pcx.Test(context.Background(), rook.TestOptions{
+		Build:   c.String("build"),
+		Runtime: runtimeName,
+		Backend: backend,
+		Timeout: c.Duration("timeout"),
+		Output:  os.Stdout,
+	})
+
+	if report := c.String("report"); report != "" {
+		if !filepath.IsAbs(report) {
+			report = filepath.Join(dir, report)
+		}
+		contents, errReport := json.MarshalIndent(result, "", "\t")
+		if errReport == nil {
+			errReport = ioutil.WriteFile(report, contents, 0600)
+		}
+		if errReport != nil {
+			print.Erro("Failed to write test report:", errReport)
+		}
+	}
+
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	for _, tc := range result.Cases {
+		if tc.Passed {
+			print.Info("PASS", tc.Name)
+		} else {
+			print.Erro("FAIL", tc.Name, tc.Message)
+		}
+	}
+	if !result.Passed() {
+		return cli.NewExitError(errors.Errorf("%d of %d tests failed", result.Failures, result.Tests).Error(), 1)
+	}
+	print.Info(result.Tests, "tests passed in", result.Duration)
+
+	return nil
+}
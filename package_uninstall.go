@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -62,12 +61,15 @@ func packageUninstall(c *cli.Context) error {
 		deps = append(deps, versioning.DependencyString(dep))
 	}
 
-	pcx, err := rook.NewPackageContext(gh, gitAuth, true, dir, platform(c), cacheDir, "")
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	pcx, err := rook.NewPackageContext(ctx, gh, gitAuth, true, dir, platform(c), cacheDir, "")
 	if err != nil {
 		return errors.Wrap(err, "failed to interpret directory as Pawn package")
 	}
 
-	err = rook.Uninstall(context.Background(), gh, pcx.Package, deps, development, gitAuth, platform(c), cacheDir)
+	err = rook.Uninstall(ctx, gh, pcx.Package, deps, development, gitAuth, platform(c), cacheDir)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/segmentio/analytics-go.v3"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+	"github.com/Southclaws/sampctl/util"
+)
+
+var packageVerifyFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "dir",
+		Value: ".",
+		Usage: "working directory for the project - by default, uses the current directory",
+	},
+	cli.BoolFlag{
+		Name:  "repair",
+		Usage: "restore damaged dependencies to their locked commits",
+	},
+}
+
+func packageVerify(c *cli.Context) error {
+	if c.Bool("verbose") {
+		print.SetVerbose()
+	}
+
+	if config.Metrics {
+		segment.Enqueue(analytics.Track{
+			Event:  "package verify",
+			UserId: config.UserID,
+			Properties: analytics.NewProperties().
+				Set("repair", c.Bool("repair")),
+		})
+	}
+
+	cacheDir, err := download.GetCacheDir()
+	if err != nil {
+		print.Erro("Failed to retrieve cache directory path (attempted <user folder>/.samp) ")
+		return err
+	}
+
+	dir := util.FullPath(c.String("dir"))
+
+	ctx, cancel := timeout(c, time.Hour)
+	defer cancel()
+
+	pcx, err := rook.NewPackageContext(ctx, gh, gitAuth, true, dir, platform(c), cacheDir, "")
+	if err != nil {
+		return errors.Wrap(err, "failed to interpret directory as Pawn package")
+	}
+
+	checks, err := pcx.Verify(ctx, c.Bool("repair"))
+	for _, check := range checks {
+		if check.Repaired {
+			print.Info("repaired", check)
+		} else {
+			print.Erro(check)
+		}
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to verify dependencies")
+	}
+
+	if len(checks) > 0 && !c.Bool("repair") {
+		return errors.Errorf("%d dependencies do not match their locked commits, run verify with --repair to restore them", len(checks))
+	}
+
+	print.Info("all dependencies match their locked commits")
+
+	return nil
+}
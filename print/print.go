@@ -6,17 +6,62 @@ import (
 	"github.com/fatih/color"
 )
 
+// Level controls which messages are printed
+type Level int
+
+const (
+	// LevelQuiet only prints errors, controlled via the --quiet flag
+	LevelQuiet Level = iota
+	// LevelNormal prints errors, warnings and general purpose messages
+	LevelNormal
+	// LevelVerbose prints detailed messages about what each step is doing, controlled via the -v flag
+	LevelVerbose
+	// LevelDebug prints everything including the internals of git operations and the cache,
+	// controlled via the --debug flag
+	LevelDebug
+)
+
 var (
-	isVerbose  = false
+	level      = LevelNormal
 	isColoured = false
 	infoStyle  = color.New(color.FgBlack).Add(color.BgYellow)
 	warnStyle  = color.New(color.FgBlack).Add(color.BgHiRed)
 	erroStyle  = color.New(color.FgRed).Add(color.BgBlack)
 )
 
-// SetVerbose activates all the Verb calls
+// SetLevel sets which messages are printed
+func SetLevel(l Level) {
+	level = l
+}
+
+// GetLevel returns the level of messages that are printed
+func GetLevel() Level {
+	return level
+}
+
+// SetVerbose activates all the Verb calls, unless quiet mode or debug mode is already set
 func SetVerbose() {
-	isVerbose = true
+	if level != LevelQuiet && level < LevelVerbose {
+		level = LevelVerbose
+	}
+}
+
+// SetDebug activates all the Verb and Debug calls, unless quiet mode is already set
+func SetDebug() {
+	if level != LevelQuiet {
+		level = LevelDebug
+	}
+}
+
+// SetQuiet silences everything but errors, it takes precedence over verbose so a script that asks
+// for quiet output always gets it
+func SetQuiet() {
+	level = LevelQuiet
+}
+
+// IsQuiet returns true if only errors are printed
+func IsQuiet() bool {
+	return level == LevelQuiet
 }
 
 // SetColoured activates ANSI colour codes
@@ -26,13 +71,23 @@ func SetColoured() {
 
 // Verb prints a message only if Verb is set - controlled via the -v flag
 func Verb(a ...interface{}) {
-	if isVerbose {
+	if level >= LevelVerbose {
 		Info(a...)
 	}
 }
 
-// Info is for general purpose messages that are always shown
+// Debug prints a message only if Debug is set - controlled via the --debug flag
+func Debug(a ...interface{}) {
+	if level >= LevelDebug {
+		Info(a...)
+	}
+}
+
+// Info is for general purpose messages that are shown unless quiet
 func Info(a ...interface{}) {
+	if level < LevelNormal {
+		return
+	}
 	if isColoured {
 		fmt.Print(infoStyle.Sprint("INFO:"), " ", color.WhiteString(fmt.Sprintln(a...)))
 	} else {
@@ -42,6 +97,9 @@ func Info(a ...interface{}) {
 
 // Warn is for warnings that do not prevent the command from finishing
 func Warn(a ...interface{}) {
+	if level < LevelNormal {
+		return
+	}
 	if isColoured {
 		fmt.Print(warnStyle.Sprint("WARN:"), " ", color.YellowString(fmt.Sprintln(a...)))
 	} else {
@@ -49,7 +107,7 @@ func Warn(a ...interface{}) {
 	}
 }
 
-// Erro is for warnings that do not prevent the command from finishing
+// Erro is for errors, these are always shown
 func Erro(a ...interface{}) {
 	if isColoured {
 		fmt.Print(erroStyle.Sprint("ERROR:"), " ", color.RedString(fmt.Sprintln(a...)))
@@ -57,3 +115,16 @@ func Erro(a ...interface{}) {
 		fmt.Print("ERROR: ", fmt.Sprintln(a...))
 	}
 }
+
+// Result writes the final result of a command in quiet mode as a single uncoloured line that
+// scripts can parse: `result=ok` or `result=error error="<message>"`
+func Result(err error) {
+	if level != LevelQuiet {
+		return
+	}
+	if err != nil {
+		fmt.Printf("result=error error=%q\n", err.Error())
+	} else {
+		fmt.Println("result=ok")
+	}
+}
@@ -1,8 +1,18 @@
 package print
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
 
 func TestPrints(t *testing.T) {
+	defer SetLevel(LevelNormal)
+
 	Verb("should not appear")
 	SetVerbose()
 	Verb("A Verbose message")
@@ -10,3 +20,54 @@ func TestPrints(t *testing.T) {
 	Warn("A warning message")
 	Erro("An error message")
 }
+
+func TestLevels(t *testing.T) {
+	defer SetLevel(LevelNormal)
+
+	tests := []struct {
+		name  string
+		setup func()
+		want  string
+	}{
+		{"normal", func() { SetLevel(LevelNormal) }, "INFO: info\nWARN: warn\nERROR: erro\n"},
+		{"verbose", SetVerbose, "INFO: verb\nINFO: info\nWARN: warn\nERROR: erro\n"},
+		{"debug", SetDebug, "INFO: debug\nINFO: verb\nINFO: info\nWARN: warn\nERROR: erro\n"},
+		{"debug wins over verbose", func() { SetDebug(); SetVerbose() }, "INFO: debug\nINFO: verb\nINFO: info\nWARN: warn\nERROR: erro\n"},
+		{"quiet", SetQuiet, "ERROR: erro\nresult=error error=\"failed\\nbadly\"\n"},
+		{"quiet wins", func() { SetQuiet(); SetVerbose() }, "ERROR: erro\nresult=error error=\"failed\\nbadly\"\n"},
+		{"quiet wins over debug", func() { SetQuiet(); SetDebug() }, "ERROR: erro\nresult=error error=\"failed\\nbadly\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetLevel(LevelNormal)
+			tt.setup()
+			got := capture(t, func() {
+				Debug("debug")
+				Verb("verb")
+				Info("info")
+				Warn("warn")
+				Erro("erro")
+				Result(errors.New("failed\nbadly"))
+			})
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	SetQuiet()
+	assert.Equal(t, "result=ok\n", capture(t, func() { Result(nil) }))
+}
+
+func capture(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	fn()
+	os.Stdout = stdout
+	w.Close() // nolint
+
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, r)
+	assert.NoError(t, err)
+	return buf.String()
+}
@@ -0,0 +1,123 @@
+package rook
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/util"
+)
+
+// AffectedBuilds returns the names of the build configs for the platform that include any of the
+// changed files, so after a change only the builds that depend on it need to run again. The include
+// set of each build is found by following every `#include` from the entry script with the include
+// paths of that build, conditional compilation is not evaluated so an include inside an `#if` counts
+// even if the build skips it. A change to the package definition affects every build.
+func (pcx *PackageContext) AffectedBuilds(ctx context.Context, changed []string) (builds []string, err error) {
+	targets := make(map[string]bool)
+	for _, file := range changed {
+		targets[util.FullPath(file)] = true
+	}
+	definitionChanged := targets[filepath.Join(pcx.Package.LocalPath, "pawn.json")] ||
+		targets[filepath.Join(pcx.Package.LocalPath, "pawn.yaml")]
+
+	for _, name := range pcx.buildNames() {
+		if definitionChanged {
+			builds = append(builds, name)
+			continue
+		}
+
+		config, errPrepare := pcx.buildPrepare(ctx, name, false, false)
+		if errPrepare != nil {
+			return nil, errors.Wrapf(errPrepare, "failed to prepare build %s", name)
+		}
+		includes := make([]string, len(config.Includes))
+		for i, inc := range config.Includes {
+			if !filepath.IsAbs(inc) {
+				inc = filepath.Join(pcx.Package.LocalPath, inc)
+			}
+			includes[i] = inc
+		}
+
+		files, errIncludes := includeSet(config.Input, includes)
+		if errIncludes != nil {
+			return nil, errors.Wrapf(errIncludes, "failed to find includes of build %s", name)
+		}
+		for file := range files {
+			if targets[file] {
+				print.Verb("build", name, "includes changed file", file)
+				builds = append(builds, name)
+				break
+			}
+		}
+	}
+	return
+}
+
+// buildNames lists the build configs that can be built on the platform by the name `Build` takes
+func (pcx *PackageContext) buildNames() (names []string) {
+	if pcx.Package.Build != nil && pcx.Package.Build.MatchesPlatform(pcx.Platform) {
+		names = append(names, "default")
+	}
+	for _, build := range pcx.Package.Builds {
+		if build.MatchesPlatform(pcx.Platform) {
+			names = append(names, build.Name)
+		}
+	}
+	if len(names) == 0 {
+		names = append(names, "default")
+	}
+	return
+}
+
+// includeSet returns every file an entry script includes, directly or through other includes, along
+// with the entry script itself. Includes that can't be resolved are skipped.
+func includeSet(entry string, includes []string) (files map[string]bool, err error) {
+	files = make(map[string]bool)
+	pending := []string{entry}
+	for len(pending) > 0 {
+		file := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if files[file] {
+			continue
+		}
+		files[file] = true
+
+		var found []string
+		found, err = fileIncludes(file, includes)
+		if err != nil {
+			return
+		}
+		pending = append(pending, found...)
+	}
+	return
+}
+
+// fileIncludes resolves the `#include` and `#tryinclude` directives of a single file
+func fileIncludes(file string, includes []string) (found []string, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", file)
+	}
+	defer f.Close() // nolint
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		match := matchInclude.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		if target, ok := resolveInclude(includes, match[3], filepath.Dir(file), match[2] == `"`); ok {
+			found = append(found, target)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", file)
+	}
+	return
+}
@@ -0,0 +1,67 @@
+package rook
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestPackageContext_AffectedBuilds(t *testing.T) {
+	dir := util.FullPath("./tests/affected")
+	os.RemoveAll(dir)
+	for name, contents := range map[string]string{
+		"main.pwn":              "#include <a_samp>\n#include \"shared\"\n#tryinclude <server>\n",
+		"shared.inc":            "#include <utils>\n",
+		"client/server.inc":     "// client build\n",
+		"server/server.inc":     "#include \"admin.inc\"\n",
+		"server/admin.inc":      "// admin\n",
+		"include/utils.inc":     "// utils\n",
+		"include/unrelated.inc": "// not included\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(contents), 0600))
+	}
+
+	pcx := PackageContext{
+		Package: types.Package{
+			LocalPath: dir,
+			Vendor:    filepath.Join(dir, "dependencies"),
+			Entry:     "main.pwn",
+			Output:    "main.amx",
+			Builds: []*types.BuildConfig{
+				{Name: "client", Includes: []string{"include", "client"}},
+				{Name: "server", Includes: []string{"include", "server"}},
+				{Name: "windows", Platform: "windows", Includes: []string{"include", "server"}},
+			},
+		},
+		Platform: "linux",
+	}
+
+	for _, tt := range []struct {
+		changed []string
+		want    []string
+	}{
+		{[]string{"server/admin.inc"}, []string{"server"}},
+		{[]string{"client/server.inc"}, []string{"client"}},
+		{[]string{"include/utils.inc"}, []string{"client", "server"}},
+		{[]string{"main.pwn"}, []string{"client", "server"}},
+		{[]string{"include/unrelated.inc"}, nil},
+		{[]string{"pawn.json"}, []string{"client", "server"}},
+	} {
+		var changed []string
+		for _, file := range tt.changed {
+			changed = append(changed, filepath.Join(dir, filepath.FromSlash(file)))
+		}
+		builds, err := pcx.AffectedBuilds(context.Background(), changed)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, builds, "changed %v", tt.changed)
+	}
+}
@@ -0,0 +1,100 @@
+package rook
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// aliasOf returns the alias the package declares for a dependency, if any
+func (pcx *PackageContext) aliasOf(meta versioning.DependencyMeta) string {
+	return aliasIn(pcx.Package, meta)
+}
+
+func aliasIn(pkg types.Package, meta versioning.DependencyMeta) string {
+	for dependency, alias := range pkg.Aliases {
+		if strings.EqualFold(dependency, meta.User+"/"+meta.Repo) {
+			return alias
+		}
+	}
+	return ""
+}
+
+// aliasInclude presents the include directory of an aliased dependency at
+// `<vendor>/.aliases/<alias>` and writes `<vendor>/.aliases/<alias>.inc` which includes the main
+// include file of the dependency, so consumers can write `#include <alias>`. The main include file
+// is the one named after the repository or, failing that, the only include file. The returned root
+// directory must be passed to the compiler instead of the include directory of the dependency so
+// the original include name doesn't collide with anything else. Like namespaces, the link or copy
+// and the include file are left as they are if they're already current. See `presentRoot` for where
+// the aliases go when the vendor directory is read-only.
+func (pcx *PackageContext) aliasInclude(meta versioning.DependencyMeta, includeDir string) (root string, err error) {
+	root = pcx.presentRoot(".aliases")
+	target := filepath.Join(root, meta.Alias)
+
+	source, err := filepath.Abs(includeDir)
+	if err != nil {
+		err = errors.Wrap(err, "failed to make canonical path to include directory")
+		return
+	}
+
+	err = presentDir(meta, source, target, "alias "+meta.Alias)
+	if err != nil {
+		return
+	}
+
+	err = writeAliasShim(root, meta, source)
+	return
+}
+
+// writeAliasShim writes `<root>/<alias>.inc` which includes the main include file of an aliased
+// dependency whose include files are in `source`
+func writeAliasShim(root string, meta versioning.DependencyMeta, source string) (err error) {
+	shim := filepath.Join(root, meta.Alias+".inc")
+	main := mainInclude(source, meta.Repo)
+	if main == "" {
+		print.Warn(meta, "has no main include file, its files must be included as", fmt.Sprintf("<%s/file>", meta.Alias))
+		return os.RemoveAll(shim)
+	}
+
+	contents := fmt.Sprintf("// generated by sampctl, %s aliased as %s\n#include \"%s/%s\"\n", meta, meta.Alias, meta.Alias, main)
+	if existing, errRead := ioutil.ReadFile(shim); errRead == nil && string(existing) == contents {
+		return
+	}
+	err = ioutil.WriteFile(shim, []byte(contents), 0600)
+	if err != nil {
+		err = errors.Wrap(err, "failed to write alias include file")
+	}
+	return
+}
+
+func mainInclude(dir, repo string) string {
+	if util.Exists(filepath.Join(dir, repo+".inc")) {
+		return repo + ".inc"
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	main := ""
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".inc" {
+			continue
+		}
+		if main != "" {
+			return ""
+		}
+		main = file.Name()
+	}
+	return main
+}
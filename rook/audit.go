@@ -0,0 +1,198 @@
+package rook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// Severity is how serious the problem described by an advisory is
+type Severity string
+
+const (
+	// SeverityLow is a problem that is unlikely to matter in practice
+	SeverityLow Severity = "low"
+	// SeverityMedium is a problem that matters in some uses of the package
+	SeverityMedium Severity = "medium"
+	// SeverityHigh is a problem that should be fixed soon
+	SeverityHigh Severity = "high"
+	// SeverityCritical is a problem that should be fixed before anything else
+	SeverityCritical Severity = "critical"
+)
+
+// Severities lists the valid severities from the least to the most serious
+var Severities = []Severity{SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// rank orders severities, an unknown severity is ranked below every valid one
+func (s Severity) rank() int {
+	for i, severity := range Severities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// AtLeast is true if the severity is as serious as another or more
+func (s Severity) AtLeast(other Severity) bool {
+	return s.rank() >= other.rank()
+}
+
+// AuditFinding is an advisory that affects the version a dependency is vendored at
+type AuditFinding struct {
+	Dependency versioning.DependencyMeta
+	Version    string // the version tag the dependency is at, empty if it isn't at one
+	Commit     string
+	Advisory   types.Advisory
+}
+
+func (af AuditFinding) String() string {
+	version := af.Version
+	if version == "" {
+		version = af.Commit
+	}
+	text := fmt.Sprintf("%s %s: %s severity advisory %s: %s", af.Dependency, version, af.Advisory.Severity, af.Advisory.ID, af.Advisory.Summary)
+	if af.Advisory.Safe != "" {
+		text += fmt.Sprintf(", upgrade to %s", af.Advisory.Safe)
+	}
+	return text
+}
+
+// LoadAdvisories reads a feed of advisories from a file or an HTTP(S) URL, a feed from a URL is
+// cached for a day
+func LoadAdvisories(ctx context.Context, cacheDir, source string) (advisories types.Advisories, err error) {
+	contents, err := readFeed(ctx, cacheDir, "advisories", source)
+	if err != nil {
+		return advisories, errors.Wrapf(err, "failed to read advisories %s", source)
+	}
+	err = json.Unmarshal(contents, &advisories)
+	if err != nil {
+		return advisories, errors.Wrapf(err, "failed to decode advisories %s", source)
+	}
+
+	for _, advisory := range advisories.Advisories {
+		if advisory.ID == "" || strings.Count(advisory.Dependency, "/") != 1 {
+			return advisories, errors.Errorf("advisory %s must have an id and a user/repo dependency", advisory.ID)
+		}
+		if advisory.Versions == "" && len(advisory.Commits) == 0 {
+			return advisories, errors.Errorf("advisory %s has no affected versions or commits", advisory.ID)
+		}
+		if advisory.Versions != "" {
+			if _, err = semver.NewConstraint(advisory.Versions); err != nil {
+				return advisories, errors.Wrapf(err, "advisory %s has invalid versions", advisory.ID)
+			}
+		}
+		if Severity(advisory.Severity).rank() == -1 {
+			return advisories, errors.Errorf("unknown severity %s in advisory %s, must be one of %v", advisory.Severity, advisory.ID, Severities)
+		}
+	}
+	return
+}
+
+// Audit checks the version each dependency is vendored at against a list of advisories and returns
+// those that affect it, the most serious first. A dependency that isn't at a version tag can only
+// be matched by the commits of an advisory. The package should be ensured first, versions are read
+// from the vendor directory and the lockfile.
+func (pcx *PackageContext) Audit(advisories types.Advisories) (findings []AuditFinding, err error) {
+	lock, err := types.ReadLockfile(pcx.Package.LocalPath)
+	if err != nil {
+		return
+	}
+	if lock == nil {
+		lock = &types.Lockfile{}
+	}
+
+	for _, meta := range pcx.AllDependencies {
+		var version, commit string
+		version, commit, err = pcx.dependencyVersion(meta, *lock)
+		if err != nil {
+			return
+		}
+		for _, advisory := range advisories.Advisories {
+			if !strings.EqualFold(advisory.Dependency, meta.User+"/"+meta.Repo) {
+				continue
+			}
+			if advisoryAffects(advisory, version, commit) {
+				findings = append(findings, AuditFinding{Dependency: meta, Version: version, Commit: commit, Advisory: advisory})
+			} else if version == "" && len(advisory.Commits) == 0 {
+				print.Verb(meta, "is not at a version tag, it can't be checked against advisory", advisory.ID)
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return Severity(findings[i].Advisory.Severity).rank() > Severity(findings[j].Advisory.Severity).rank()
+	})
+	return
+}
+
+// advisoryAffects is true if a version or commit is one of the affected versions of an advisory
+func advisoryAffects(advisory types.Advisory, version, commit string) bool {
+	for _, affected := range advisory.Commits {
+		if commit != "" && len(affected) >= 7 && strings.HasPrefix(commit, affected) {
+			return true
+		}
+	}
+	if advisory.Versions == "" || version == "" {
+		return false
+	}
+	constraint, err := semver.NewConstraint(advisory.Versions)
+	if err != nil {
+		return false
+	}
+	parsed, err := semver.NewVersion(version)
+	if err != nil {
+		return false
+	}
+	return constraint.Check(parsed)
+}
+
+// AddFindings lists the advisories of audit findings as vulnerabilities of the components they
+// affect, each advisory once with every component it affects
+func (bom *BOM) AddFindings(findings []AuditFinding) {
+	refs := make(map[string]string)
+	for _, component := range bom.Components {
+		for _, property := range component.Properties {
+			if property.Name == "sampctl:dependency" {
+				refs[property.Value] = component.BOMRef
+			}
+		}
+	}
+
+	byID := make(map[string]int)
+	for _, finding := range findings {
+		ref, ok := refs[finding.Dependency.String()]
+		if !ok {
+			continue
+		}
+		if i, ok := byID[finding.Advisory.ID]; ok {
+			bom.Vulnerabilities[i].Affects = append(bom.Vulnerabilities[i].Affects, BOMAffect{Ref: ref})
+			continue
+		}
+
+		advisory := finding.Advisory
+		vulnerability := BOMVulnerability{
+			ID:          advisory.ID,
+			Ratings:     []BOMRating{{Severity: advisory.Severity}},
+			Description: advisory.Summary,
+			Affects:     []BOMAffect{{Ref: ref}},
+		}
+		if advisory.URL != "" {
+			vulnerability.Source = &BOMSource{URL: advisory.URL}
+		}
+		if advisory.Safe != "" {
+			vulnerability.Recommendation = "Upgrade to " + advisory.Safe
+		}
+		byID[advisory.ID] = len(bom.Vulnerabilities)
+		bom.Vulnerabilities = append(bom.Vulnerabilities, vulnerability)
+	}
+}
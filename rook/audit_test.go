@@ -0,0 +1,75 @@
+package rook
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_Audit(t *testing.T) {
+	dir := util.FullPath("./tests/audit")
+	os.RemoveAll(dir)
+	vendor := filepath.Join(dir, "dependencies")
+	commitVersions(t, filepath.Join(vendor, "lib"), []string{"1.0.0", "1.1.0"})
+
+	lib := versioning.DependencyMeta{User: "test", Repo: "lib", Tag: "^1.0.0"}
+	plugin := versioning.DependencyMeta{User: "test", Repo: "plugin"}
+	lock := types.NewLockfile([]types.LockedDependency{
+		{Dependency: versioning.DependencyString(plugin.String()), Commit: "0123456789abcdef0123456789abcdef01234567"},
+	})
+	assert.NoError(t, lock.Write(dir))
+
+	feed := filepath.Join(dir, "advisories.json")
+	assert.NoError(t, ioutil.WriteFile(feed, []byte(`{"advisories": [
+		{"id": "PAWN-1", "dependency": "Test/Lib", "versions": "<1.2.0", "severity": "high", "summary": "buffer overflow in lib_Format", "safe": "1.2.0", "url": "https://example.com/PAWN-1"},
+		{"id": "PAWN-2", "dependency": "test/lib", "versions": ">=2.0.0", "severity": "critical", "summary": "not this version"},
+		{"id": "PAWN-3", "dependency": "test/plugin", "commits": ["0123456789ab"], "severity": "critical", "summary": "crashes on load"},
+		{"id": "PAWN-4", "dependency": "test/plugin", "versions": "<1.0.0", "severity": "low", "summary": "needs a version tag"}
+	]}`), 0600))
+
+	advisories, err := LoadAdvisories(context.Background(), dir, feed)
+	assert.NoError(t, err)
+	assert.Len(t, advisories.Advisories, 4)
+
+	pcx := PackageContext{
+		Package:         types.Package{LocalPath: dir, Vendor: vendor},
+		AllDependencies: []versioning.DependencyMeta{lib, plugin},
+	}
+	findings, err := pcx.Audit(advisories)
+	assert.NoError(t, err)
+	assert.Len(t, findings, 2)
+	assert.Equal(t, "test/plugin 0123456789abcdef0123456789abcdef01234567: critical severity advisory PAWN-3: crashes on load", findings[0].String())
+	assert.Equal(t, "test/lib:^1.0.0 1.1.0: high severity advisory PAWN-1: buffer overflow in lib_Format, upgrade to 1.2.0", findings[1].String())
+
+	bom, err := pcx.SBOM()
+	assert.NoError(t, err)
+	bom.AddFindings(findings)
+	assert.Equal(t, []BOMVulnerability{
+		{
+			ID:          "PAWN-3",
+			Ratings:     []BOMRating{{Severity: "critical"}},
+			Description: "crashes on load",
+			Affects:     []BOMAffect{{Ref: "pkg:github/test/plugin@0123456789abcdef0123456789abcdef01234567"}},
+		},
+		{
+			ID:             "PAWN-1",
+			Source:         &BOMSource{URL: "https://example.com/PAWN-1"},
+			Ratings:        []BOMRating{{Severity: "high"}},
+			Description:    "buffer overflow in lib_Format",
+			Recommendation: "Upgrade to 1.2.0",
+			Affects:        []BOMAffect{{Ref: "pkg:github/test/lib@1.1.0"}},
+		},
+	}, bom.Vulnerabilities)
+
+	assert.NoError(t, ioutil.WriteFile(feed, []byte(`{"advisories": [{"id": "PAWN-5", "dependency": "test/lib", "versions": "<1.0.0", "severity": "severe"}]}`), 0600))
+	_, err = LoadAdvisories(context.Background(), dir, feed)
+	assert.EqualError(t, err, "unknown severity severe in advisory PAWN-5, must be one of [low medium high critical]")
+}
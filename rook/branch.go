@@ -0,0 +1,107 @@
+package rook
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+var (
+	// defaultBranches caches default branches looked up from GitHub by `user/repo` so each one is
+	// only requested once per run, a failed lookup is cached as an empty branch
+	defaultBranches   = make(map[string]string)
+	defaultBranchesMu sync.Mutex
+)
+
+// defaultBranchOf returns the default branch the package declares for a dependency, if any
+func (pcx *PackageContext) defaultBranchOf(meta versioning.DependencyMeta) string {
+	for dependency, branch := range pcx.Package.DefaultBranches {
+		if strings.EqualFold(dependency, meta.User+"/"+meta.Repo) {
+			return branch
+		}
+	}
+	return ""
+}
+
+// defaultBranch returns the branch that a dependency without a branch constraint is pulled from. A
+// declared default branch is used as-is, otherwise GitHub is asked for the repository's default
+// branch and failing that, the branch the cached copy is on is used since a clone starts on the
+// default branch of the remote. The result is empty if none of these are available, or if the
+// dependency is pinned to a tag or commit since nothing is pulled from a branch for it.
+func (pcx PackageContext) defaultBranch(ctx context.Context, meta versioning.DependencyMeta) string {
+	if meta.Branch != "" {
+		return meta.Branch
+	}
+	if meta.Tag != "" || meta.Commit != "" {
+		return ""
+	}
+	if meta.DefaultBranch != "" {
+		return meta.DefaultBranch
+	}
+	if pcx.GitHub == nil || meta.Site != "github.com" || pcx.Registry.handles(meta) {
+		return pcx.cachedBranch(meta)
+	}
+
+	key := strings.ToLower(meta.User + "/" + meta.Repo)
+	defaultBranchesMu.Lock()
+	branch, ok := defaultBranches[key]
+	defaultBranchesMu.Unlock()
+	if ok {
+		if branch == "" {
+			return pcx.cachedBranch(meta)
+		}
+		return branch
+	}
+
+	// the lock isn't held during the request so lookups of other dependencies aren't held up by it
+	repo, _, err := pcx.GitHub.Repositories.Get(ctx, meta.User, meta.Repo)
+	if err != nil {
+		print.Verb(meta, "failed to look up default branch:", err)
+		if ctx.Err() == nil {
+			// it won't succeed later in the same run, such as when rate limited or the repository is gone
+			defaultBranchesMu.Lock()
+			defaultBranches[key] = ""
+			defaultBranchesMu.Unlock()
+		}
+		return pcx.cachedBranch(meta)
+	}
+	branch = repo.GetDefaultBranch()
+	print.Verb(meta, "default branch is", branch)
+	defaultBranchesMu.Lock()
+	defaultBranches[key] = branch
+	defaultBranchesMu.Unlock()
+	return branch
+}
+
+// cachedBranch returns the branch the cached copy of a dependency is on, if it has been cached
+func (pcx PackageContext) cachedBranch(meta versioning.DependencyMeta) string {
+	repo, err := git.PlainOpen(pcx.cachePath(meta))
+	if err != nil {
+		return ""
+	}
+	head, err := repo.Head()
+	if err != nil || !head.Name().IsBranch() {
+		return ""
+	}
+	return head.Name().Short()
+}
+
+// pullReference returns the reference to pull a repository from, the given branch if there is one
+// or the branch the repository is currently on. The git library assumes `master` otherwise, which
+// doesn't exist in repositories with a different default branch.
+func pullReference(repo *git.Repository, branch string) plumbing.ReferenceName {
+	if branch != "" {
+		return plumbing.ReferenceName("refs/heads/" + branch)
+	}
+	head, err := repo.Head()
+	if err == nil && head.Name().IsBranch() {
+		return head.Name()
+	}
+	return ""
+}
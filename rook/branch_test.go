@@ -0,0 +1,138 @@
+package rook
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_defaultBranch(t *testing.T) {
+	dir := util.FullPath("./tests/branch")
+	os.RemoveAll(dir)
+
+	pcx := PackageContext{
+		Package:  types.Package{DefaultBranches: map[string]string{"Test/Declared": "trunk"}},
+		CacheDir: dir,
+	}
+	meta := versioning.DependencyMeta{Site: "github.com", User: "test", Repo: "develop"}
+
+	// nothing is known about a dependency that hasn't been cached
+	assert.Equal(t, "", pcx.defaultBranch(context.Background(), meta))
+
+	repo, err := git.PlainInit(meta.CachePath(dir), false)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(meta.CachePath(dir), "develop.inc"), nil, 0644))
+	wt, err := repo.Worktree()
+	assert.NoError(t, err)
+	_, err = wt.Add("develop.inc")
+	assert.NoError(t, err)
+	hash, err := wt.Commit("initial", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@test", When: time.Now()},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference("refs/heads/develop", hash)))
+	assert.NoError(t, repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, "refs/heads/develop")))
+
+	assert.Equal(t, "develop", pcx.defaultBranch(context.Background(), meta))
+	assert.Equal(t, plumbing.ReferenceName("refs/heads/develop"), pullReference(repo, ""))
+	assert.Equal(t, plumbing.ReferenceName("refs/heads/other"), pullReference(repo, "other"))
+
+	// a branch constraint or a declared default branch take precedence
+	withBranch := meta
+	withBranch.Branch = "feature"
+	assert.Equal(t, "feature", pcx.defaultBranch(context.Background(), withBranch))
+
+	declared := versioning.DependencyMeta{Site: "github.com", User: "test", Repo: "declared"}
+	declared.DefaultBranch = pcx.defaultBranchOf(declared)
+	assert.Equal(t, "trunk", declared.DefaultBranch)
+	assert.Equal(t, "trunk", pcx.defaultBranch(context.Background(), declared))
+
+	// a detached HEAD has no branch to pull
+	assert.NoError(t, wt.Checkout(&git.CheckoutOptions{Hash: hash}))
+	assert.Equal(t, plumbing.ReferenceName(""), pullReference(repo, ""))
+}
+
+func TestPackageContext_defaultBranchLookup(t *testing.T) {
+	defaultBranchesMu.Lock()
+	defaultBranches = make(map[string]string)
+	defaultBranchesMu.Unlock()
+
+	var (
+		mu       sync.Mutex
+		requests = make(map[string]int)
+		release  = make(chan struct{})
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/repos/lookup/slow":
+			<-release
+			fmt.Fprint(w, `{"default_branch": "slow-main"}`)
+		case "/repos/lookup/fast":
+			fmt.Fprint(w, `{"default_branch": "fast-main"}`)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message": "API rate limit exceeded"}`)
+		}
+	}))
+	defer server.Close()
+
+	gh := github.NewClient(nil)
+	gh.BaseURL, _ = url.Parse(server.URL + "/")
+	pcx := PackageContext{GitHub: gh, CacheDir: util.FullPath("./tests/branch-lookup")}
+	meta := func(repo string) versioning.DependencyMeta {
+		return versioning.DependencyMeta{Site: "github.com", User: "lookup", Repo: repo}
+	}
+
+	// a slow lookup doesn't hold up the lookups of other dependencies
+	slow := make(chan string)
+	go func() { slow <- pcx.defaultBranch(context.Background(), meta("slow")) }()
+	fast := make(chan string)
+	go func() { fast <- pcx.defaultBranch(context.Background(), meta("fast")) }()
+	select {
+	case branch := <-fast:
+		assert.Equal(t, "fast-main", branch)
+	case <-time.After(5 * time.Second):
+		t.Fatal("lookup was held up by another one in progress")
+	}
+	close(release)
+	assert.Equal(t, "slow-main", <-slow)
+
+	// failed lookups are only attempted once per run
+	assert.Equal(t, "", pcx.defaultBranch(context.Background(), meta("limited")))
+	assert.Equal(t, "", pcx.defaultBranch(context.Background(), meta("limited")))
+	assert.Equal(t, "fast-main", pcx.defaultBranch(context.Background(), meta("fast")))
+
+	// dependencies pinned to a tag or commit don't need one
+	tagged := meta("tagged")
+	tagged.Tag = "1.0.0"
+	assert.Equal(t, "", pcx.defaultBranch(context.Background(), tagged))
+	pinned := meta("pinned")
+	pinned.Commit = "abc123"
+	assert.Equal(t, "", pcx.defaultBranch(context.Background(), pinned))
+	assert.Equal(t, map[string]int{
+		"/repos/lookup/slow":    1,
+		"/repos/lookup/fast":    1,
+		"/repos/lookup/limited": 1,
+	}, requests)
+}
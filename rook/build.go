@@ -9,7 +9,6 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strconv"
-	"strings"
 	"sync/atomic"
 	"syscall"
 	"time"
@@ -18,12 +17,21 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/Southclaws/sampctl/compiler"
+	"github.com/Southclaws/sampctl/events"
 	"github.com/Southclaws/sampctl/print"
 	"github.com/Southclaws/sampctl/types"
 	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
 )
 
 // Build compiles a package, dependencies are ensured and a list of paths are sent to the compiler.
+// If dependencies aren't ensured, the vendored dependencies are checked against the package
+// definition first and the stale policy of the package context decides what happens if they differ.
+// Every include directory is checked before the compiler runs so missing ones are reported with the
+// dependency they belong to. If the package context has a report file, a JSON report of the build is
+// written to it afterwards and the report is published to the event bus of the context. If the build
+// config declares a manifest, it's written to the output directory once the build succeeds. The
+// compile is skipped if the output is up to date with its sources, see `compileKey`.
 func (pcx *PackageContext) Build(
 	ctx context.Context,
 	build string,
@@ -36,7 +44,24 @@ func (pcx *PackageContext) Build(
 	result types.BuildResult,
 	err error,
 ) {
-	config, err := pcx.buildPrepare(ctx, build, ensure, true)
+	var config *types.BuildConfig
+	if (pcx.ReportFile != "" || events.FromContext(ctx) != nil) && !dry {
+		started := time.Now()
+		defer func() {
+			pcx.reportBuild(ctx, pcx.buildReport(build, config, started, problems, result, err))
+		}()
+	}
+
+	ensure, err = pcx.checkStale(ensure)
+	if err != nil {
+		return
+	}
+
+	config, err = pcx.buildPrepare(ctx, build, ensure, true)
+	if err != nil {
+		return
+	}
+	err = pcx.validateIncludes(config)
 	if err != nil {
 		return
 	}
@@ -55,24 +80,43 @@ func (pcx *PackageContext) Build(
 	}
 
 	if dry {
-		fmt.Println(strings.Join(command.Env, " "), strings.Join(command.Args, " "))
+		if len(config.Plugins) > 0 || len(config.Generators) > 0 {
+			print.Info("The build runs pre-build plugins and generators first, they are not part of the command")
+		}
+		fmt.Println(compiler.CommandLine(command))
 	} else {
-		for _, plugin := range config.Plugins {
-			print.Verb("running pre-build plugin", plugin)
-			pluginCmd := exec.Command(plugin[0], plugin[1:]...)
-			pluginCmd.Stdout = os.Stdout
-			pluginCmd.Stderr = os.Stdout
-			err = pluginCmd.Run()
-			if err != nil {
-				print.Erro("Failed to execute pre-build plugin:", plugin[0], err)
-				return
-			}
+		err = pcx.runGenerators(ctx, config.Generators)
+		if err != nil {
+			return
 		}
-		print.Verb("building", pcx.Package, "with", config.Version)
 
-		problems, result, err = compiler.CompileWithCommand(command, config.WorkingDir, pcx.Package.LocalPath, relative)
-		if err != nil {
-			err = errors.Wrap(err, "failed to compile package entry")
+		key, record, hit := pcx.cachedCompile(*config)
+		if hit {
+			print.Info("Build output is up to date, skipping compile")
+			problems, result = record.Problems, record.Result
+		} else {
+			for _, plugin := range config.Plugins {
+				print.Verb("running pre-build plugin", plugin)
+				pluginCmd := exec.CommandContext(ctx, plugin[0], plugin[1:]...) //nolint:gas
+				pluginCmd.Stdout = os.Stdout
+				pluginCmd.Stderr = os.Stdout
+				err = pluginCmd.Run()
+				if err != nil {
+					print.Erro("Failed to execute pre-build plugin:", plugin[0], err)
+					return
+				}
+			}
+			print.Verb("building", pcx.Package, "with", config.Version)
+
+			compileStarted := time.Now()
+			events.Publish(ctx, events.CompileStarted{Input: config.Input, Output: config.Output})
+			problems, result, err = compiler.CompileWithCommand(command, config.WorkingDir, pcx.Package.LocalPath, relative)
+			publishCompileFinished(ctx, config, compileStarted, problems, result, err)
+			if err != nil {
+				err = errors.Wrap(err, "failed to compile package entry")
+			} else {
+				pcx.recordCompile(key, *config, problems, result)
+			}
 		}
 
 		atomic.AddUint32(&buildNumber, 1)
@@ -83,6 +127,10 @@ func (pcx *PackageContext) Build(
 				print.Erro("Failed to write buildfile:", err2)
 			}
 		}
+
+		if config.Manifest != nil && err == nil && problems.IsValid() && !problems.Fatal() {
+			_, err = pcx.writeManifest(*config, buildNumber, time.Now())
+		}
 	}
 
 	return
@@ -90,10 +138,19 @@ func (pcx *PackageContext) Build(
 
 // BuildWatch runs the Build code on file changes
 func (pcx *PackageContext) BuildWatch(ctx context.Context, build string, ensure bool, buildFile string, relative bool, trigger chan types.BuildProblems) (err error) {
+	ensure, err = pcx.checkStale(ensure)
+	if err != nil {
+		return
+	}
+
 	config, err := pcx.buildPrepare(ctx, build, ensure, true)
 	if err != nil {
 		return
 	}
+	err = pcx.validateIncludes(config)
+	if err != nil {
+		return
+	}
 
 	var buildNumber = uint32(0)
 	if buildFile != "" {
@@ -139,6 +196,7 @@ func (pcx *PackageContext) BuildWatch(ctx context.Context, build string, ensure
 		running          atomic.Value
 		ctxInner, cancel = context.WithCancel(ctx)
 		problems         []types.BuildProblem
+		result           types.BuildResult
 		lastEvent        time.Time
 	)
 
@@ -193,18 +251,40 @@ loop:
 				atomic.AddUint32(&buildNumber, 1)
 				fmt.Println("watch-build: starting compilation", buildNumber)
 
-				running.Store(true)
-				problems, _, err = compiler.CompileSource(
-					ctxInner,
-					pcx.GitHub,
-					pcx.Package.LocalPath,
-					pcx.Package.LocalPath,
-					pcx.CacheDir,
-					pcx.Platform,
-					*config,
-					relative,
-				)
-				running.Store(false)
+				errGen := pcx.runGenerators(ctxInner, config.Generators)
+				if errGen != nil {
+					print.Erro("watch-build:", errGen)
+					return
+				}
+
+				started := time.Now()
+				key, record, hit := pcx.cachedCompile(*config)
+				if hit {
+					fmt.Println("watch-build: output is up to date, skipping compile")
+					problems, result, err = record.Problems, record.Result, nil
+				} else {
+					running.Store(true)
+					events.Publish(ctx, events.CompileStarted{Input: config.Input, Output: config.Output})
+					problems, result, err = compiler.CompileSource(
+						ctxInner,
+						pcx.GitHub,
+						pcx.Package.LocalPath,
+						pcx.Package.LocalPath,
+						pcx.CacheDir,
+						pcx.Platform,
+						*config,
+						relative,
+					)
+					running.Store(false)
+					publishCompileFinished(ctx, config, started, problems, result, err)
+					if err == nil {
+						pcx.recordCompile(key, *config, problems, result)
+					}
+				}
+
+				if pcx.ReportFile != "" || events.FromContext(ctx) != nil {
+					pcx.reportBuild(ctx, pcx.buildReport(build, config, started, problems, result, err))
+				}
 
 				if err != nil {
 					if err.Error() == "signal: killed" || err.Error() == "context canceled" {
@@ -236,15 +316,39 @@ loop:
 }
 
 func (pcx *PackageContext) buildPrepare(ctx context.Context, build string, ensure, forceUpdate bool) (config *types.BuildConfig, err error) {
-	config = GetBuildConfig(pcx.Package, build)
+	config = GetBuildConfig(pcx.Package, build, pcx.Platform)
 	if config == nil {
 		err = errors.Errorf("no build config named '%s'", build)
 		return
 	}
 
-	config.WorkingDir = filepath.Dir(util.FullPath(pcx.Package.Entry))
-	config.Input = filepath.Join(pcx.Package.LocalPath, pcx.Package.Entry)
-	config.Output = filepath.Join(pcx.Package.LocalPath, pcx.Package.Output)
+	// a build can compile its own entry script to its own output, otherwise it uses the package's
+	if config.Input == "" {
+		config.Input = pcx.Package.Entry
+	}
+	if config.Output == "" {
+		config.Output = pcx.Package.Output
+	}
+	config.Output, err = pcx.expandOutput(config.Output, config.Name)
+	if err != nil {
+		return
+	}
+	config.Input = filepath.Join(pcx.Package.LocalPath, config.Input)
+	config.Output = filepath.Join(pcx.Package.LocalPath, config.Output)
+
+	// the compiler runs in the package root unless the build sets its working directory, so relative
+	// paths resolve the same way whichever directory the entry script is in
+	entryDir := filepath.Dir(config.Input)
+	if config.WorkingDir == "" {
+		config.WorkingDir = pcx.Package.LocalPath
+	} else if !filepath.IsAbs(config.WorkingDir) {
+		config.WorkingDir = filepath.Join(pcx.Package.LocalPath, config.WorkingDir)
+	}
+
+	// entry scripts in a subdirectory such as `gamemodes/` often include files next to them
+	if filepath.Clean(entryDir) != filepath.Clean(pcx.Package.LocalPath) {
+		config.Includes = append(config.Includes, entryDir)
+	}
 
 	if ensure {
 		err = pcx.EnsureDependencies(ctx, forceUpdate)
@@ -254,50 +358,156 @@ func (pcx *PackageContext) buildPrepare(ctx context.Context, build string, ensur
 		}
 	}
 
+	var (
+		sources    []IncludeSource
+		packages   = []types.Package{pcx.Package}
+		namespaced = false
+		aliased    = false
+		plain      []IncludeSource
+		// the include directories of namespaced and aliased dependencies go after all the others,
+		// so their plain include names only resolve to them if no other dependency has the same names
+		prefixed []IncludeSource
+	)
 	for _, depMeta := range pcx.AllDependencies {
+		pkgInner, found, includeDir, extraDirs := pcx.dependencyIncludes(depMeta)
+		if found {
+			packages = append(packages, pkgInner)
+		}
 
-		// check if local package has a definition
-		incPath := ""
-		hasIncludeResources := false
-		noPackage := false
-		depDir := filepath.Join(pcx.Package.LocalPath, "dependencies", depMeta.Repo)
-		pkgInner, errInner := types.PackageFromDir(depDir)
-		if errInner != nil {
-			print.Verb(depMeta, "using cached copy for include path checking")
-			pkgInner, errInner = types.GetCachedPackage(depMeta, pcx.CacheDir)
-			if errInner != nil {
-				noPackage = true
-			}
+		// additional include roots are always plain search paths, only the main include directory
+		// of a dependency is presented under its namespace or alias
+		for _, extraDir := range extraDirs {
+			sources = append(sources, IncludeSource{Dir: extraDir, Owner: depMeta})
+			plain = append(plain, IncludeSource{Dir: extraDir, Owner: depMeta})
+			config.Includes = append(config.Includes, extraDir)
 		}
 
-		if !noPackage {
-			// check if package specifies an include path
-			if pkgInner.IncludePath != "" {
-				incPath = pkgInner.IncludePath
+		if includeDir != "" {
+			sources = append(sources, IncludeSource{Dir: includeDir, Owner: depMeta})
+
+			if depMeta.Namespace != "" {
+				var root string
+				root, err = pcx.namespaceInclude(depMeta, includeDir)
+				if err != nil {
+					return
+				}
+				if !namespaced {
+					config.Includes = append(config.Includes, root)
+					namespaced = true
+				}
+				// the dependency's own includes still refer to each other by their plain names
+				prefixed = append(prefixed, IncludeSource{Dir: includeDir, Owner: depMeta})
+				continue
 			}
-			// check if the package specifies resources that contain includes
-			for _, res := range pkgInner.Resources {
-				if len(res.Includes) > 0 {
-					hasIncludeResources = true
-					break
+
+			if depMeta.Alias != "" {
+				var root string
+				root, err = pcx.aliasInclude(depMeta, includeDir)
+				if err != nil {
+					return
+				}
+				if !aliased {
+					config.Includes = append(config.Includes, root)
+					aliased = true
 				}
+				// the dependency's own includes still refer to each other by their plain names
+				prefixed = append(prefixed, IncludeSource{Dir: includeDir, Owner: depMeta})
+				continue
 			}
+
+			plain = append(plain, IncludeSource{Dir: includeDir, Owner: depMeta})
+			config.Includes = append(config.Includes, includeDir)
 		}
+	}
+	for _, source := range prefixed {
+		config.Includes = append(config.Includes, source.Dir)
+	}
+
+	warnCollisions(sources)
+	warnShadowedIncludes(plain, prefixed)
 
-		if !hasIncludeResources {
-			config.Includes = append(config.Includes, filepath.Join(depDir, incPath))
+	constants, err := ResolveFeatures(pcx.Package.EnableFeatures, packages)
+	if err != nil {
+		err = errors.Wrap(err, "failed to resolve features")
+		return
+	}
+	if len(constants) > 0 && config.Constants == nil {
+		config.Constants = make(map[string]string)
+	}
+	for name, value := range constants {
+		// constants set explicitly in the build config take precedence over features
+		if _, exists := config.Constants[name]; !exists {
+			config.Constants[name] = value
 		}
 	}
 
 	config.Includes = append(config.Includes, pcx.AllIncludePaths...)
 
+	err = pcx.ensureCompiler(ctx, config)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// dependencyIncludes finds the package definition of a dependency, in the vendor directory or the
+// cache, and the directory its include files are in. The directory is empty when the dependency
+// provides its includes through resources instead. Any additional include paths the dependency
+// declares are returned as extra directories, whether or not it uses resources. For a package in a
+// subdirectory of its repository, paths are relative to that subdirectory.
+func (pcx *PackageContext) dependencyIncludes(depMeta versioning.DependencyMeta) (pkg types.Package, found bool, includeDir string, extraDirs []string) {
+	// check if local package has a definition
+	incPath := ""
+	depDir := depMeta.PackageDir(filepath.Join(pcx.Package.LocalPath, "dependencies", depMeta.VendorName()))
+	pkg, err := types.PackageFromDir(depDir)
+	if err != nil {
+		print.Verb(depMeta, "using cached copy for include path checking")
+		pkg, err = types.GetCachedPackage(depMeta, pcx.CacheDir)
+	}
+
+	if err == nil {
+		found = true
+		pkg.DependencyMeta = depMeta
+
+		// check if package specifies an include path
+		if pkg.IncludePath != "" {
+			incPath = pkg.IncludePath
+		}
+		for _, extra := range pkg.IncludePaths {
+			extraDirs = append(extraDirs, filepath.Join(depDir, extra))
+		}
+		// check if the package specifies resources that contain includes
+		for _, res := range pkg.Resources {
+			if len(res.Includes) > 0 {
+				return
+			}
+		}
+	}
+
+	if incPath == "" {
+		incPath = DetectIncludePath(depDir)
+		if incPath != "" {
+			print.Verb(depMeta, "has no include path, using", incPath, "where its include files are")
+		}
+	}
+	includeDir = filepath.Join(depDir, incPath)
 	return
 }
 
+func publishCompileFinished(ctx context.Context, config *types.BuildConfig, started time.Time, problems types.BuildProblems, result types.BuildResult, err error) {
+	events.Measure(ctx, events.StepCompile, config.Input, started)
+	for _, problem := range problems {
+		events.Publish(ctx, events.Diagnostic{Problem: problem})
+	}
+	events.Publish(ctx, events.CompileFinished{Problems: problems, Result: result, Err: err})
+}
+
 // GetBuildConfig returns a matching build by name from the package build list. If no name is
 // specified, the first build is returned. If the package has no build definitions, a default
-// configuration is returned.
-func GetBuildConfig(pkg types.Package, name string) (config *types.BuildConfig) {
+// configuration is returned. Builds scoped to a different platform are skipped and any overlay
+// for the target platform is merged onto the selected build, then its profile is applied.
+func GetBuildConfig(pkg types.Package, name, platform string) (config *types.BuildConfig) {
 	def := types.GetBuildConfigDefault()
 
 	// if there are no builds at all, use default
@@ -305,17 +515,22 @@ func GetBuildConfig(pkg types.Package, name string) (config *types.BuildConfig)
 		return def
 	}
 
-	// if the user did not specify a specific build config, use the first
+	// if the user did not specify a specific build config, use the first for this platform
 	// otherwise, search for a matching config by name
 	if name == "default" {
-		if pkg.Build != nil {
+		if pkg.Build != nil && pkg.Build.MatchesPlatform(platform) {
 			config = pkg.Build
 		} else {
-			config = pkg.Builds[0]
+			for _, cfg := range pkg.Builds {
+				if cfg.MatchesPlatform(platform) {
+					config = cfg
+					break
+				}
+			}
 		}
 	} else {
 		for _, cfg := range pkg.Builds {
-			if cfg.Name == name {
+			if cfg.Name == name && cfg.MatchesPlatform(platform) {
 				config = cfg
 				break
 			}
@@ -323,10 +538,13 @@ func GetBuildConfig(pkg types.Package, name string) (config *types.BuildConfig)
 	}
 
 	if config == nil {
-		print.Warn("No build config called:", name, "using default")
+		print.Warn("No build config called:", name, "for platform", platform, "using default")
 		return def
 	}
 
+	merged := config.ForPlatform(platform).WithProfile(def.Args)
+	config = &merged
+
 	if config.Version == "" {
 		config.Version = def.Version
 	}
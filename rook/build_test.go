@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -126,3 +127,372 @@ func TestPackage_Build(t *testing.T) {
 		})
 	}
 }
+
+func TestGetBuildConfig(t *testing.T) {
+	level := func(n int) *int { return &n }
+	enabled := func(b bool) *bool { return &b }
+	pkg := types.Package{
+		Builds: []*types.BuildConfig{
+			{Name: "main", Platform: "windows", Constants: map[string]string{"WINDOWS": "1"}},
+			{
+				Name:      "main",
+				Includes:  []string{"include"},
+				Constants: map[string]string{"MODE": "base"},
+				Debug:     level(3),
+				Platforms: map[string]*types.BuildConfig{
+					"linux": {
+						Args:         []string{"-d0"},
+						Includes:     []string{"include/linux"},
+						Constants:    map[string]string{"MODE": "linux"},
+						Optimization: level(2),
+					},
+				},
+			},
+			{Name: "strict", Profile: types.ProfileStrict},
+			{Name: "tuned", Profile: types.ProfileStrict, Args: []string{"-d1", "-;-"}},
+			{Name: "release", Profile: types.ProfileRelease, Compress: enabled(false)},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		build    string
+		platform string
+		want     *types.BuildConfig
+	}{
+		{"windows scoped", "main", "windows", &types.BuildConfig{
+			Name:      "main",
+			Platform:  "windows",
+			Version:   "3.10.4",
+			Args:      types.GetBuildConfigDefault().Args,
+			Includes:  []string{},
+			Plugins:   [][]string{},
+			Constants: map[string]string{"WINDOWS": "1"},
+		}},
+		{"linux overlay", "default", "linux", &types.BuildConfig{
+			Name:         "main",
+			Version:      "3.10.4",
+			Args:         []string{"-d0"},
+			Includes:     []string{"include", "include/linux"},
+			Plugins:      [][]string{},
+			Constants:    map[string]string{"MODE": "linux"},
+			Debug:        level(3),
+			Optimization: level(2),
+		}},
+		{"darwin base", "main", "darwin", &types.BuildConfig{
+			Name:      "main",
+			Version:   "3.10.4",
+			Args:      types.GetBuildConfigDefault().Args,
+			Includes:  []string{"include"},
+			Plugins:   [][]string{},
+			Constants: map[string]string{"MODE": "base"},
+			Debug:     level(3),
+		}},
+		{"strict profile replaces default toggles", "strict", "linux", &types.BuildConfig{
+			Name:      "strict",
+			Profile:   types.ProfileStrict,
+			Version:   "3.10.4",
+			Args:      []string{"-d3", "-\\+", "-Z+", "-;+", "-(+", "-w213+", "-E"},
+			Includes:  []string{},
+			Plugins:   [][]string{},
+			Constants: map[string]string{},
+			Debug:     level(3),
+		}},
+		{"strict profile overridden by args", "tuned", "linux", &types.BuildConfig{
+			Name:      "tuned",
+			Profile:   types.ProfileStrict,
+			Version:   "3.10.4",
+			Args:      []string{"-d1", "-;-", "-(+", "-w213+", "-E"},
+			Includes:  []string{},
+			Plugins:   [][]string{},
+			Constants: map[string]string{},
+		}},
+		{"release profile overridden by option", "release", "linux", &types.BuildConfig{
+			Name:         "release",
+			Profile:      types.ProfileRelease,
+			Version:      "3.10.4",
+			Args:         types.GetBuildConfigDefault().Args,
+			Includes:     []string{},
+			Plugins:      [][]string{},
+			Constants:    map[string]string{},
+			Debug:        level(0),
+			Optimization: level(2),
+			Compress:     enabled(false),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GetBuildConfig(pkg, tt.build, tt.platform)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPackageContext_buildPrepareEntryDir(t *testing.T) {
+	tests := []struct {
+		name           string
+		entry          string
+		workingDir     string
+		wantWorkingDir string
+		wantIncludes   []string
+	}{
+		{"root", "main.pwn", "", "/pkg", []string{}},
+		{"subdir", "gamemodes/main.pwn", "", "/pkg", []string{"/pkg/gamemodes"}},
+		{"override", "gamemodes/main.pwn", "src", "/pkg/src", []string{"/pkg/gamemodes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pcx := PackageContext{Package: types.Package{
+				LocalPath: "/pkg",
+				Entry:     tt.entry,
+				Output:    "main.amx",
+				Builds:    []*types.BuildConfig{{Name: "main", WorkingDir: tt.workingDir}},
+			}}
+
+			config, err := pcx.buildPrepare(context.Background(), "main", false, false)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantWorkingDir, config.WorkingDir)
+			assert.Equal(t, filepath.Join("/pkg", tt.entry), config.Input)
+			assert.Equal(t, tt.wantIncludes, config.Includes)
+		})
+	}
+}
+
+func TestPackageContext_buildPrepareOutput(t *testing.T) {
+	pcx := PackageContext{Package: types.Package{
+		LocalPath: "/pkg",
+		Entry:     "main.pwn",
+		Output:    "main.amx",
+		Builds: []*types.BuildConfig{
+			{Name: "main"},
+			{Name: "debug", Input: "debug.pwn", Output: "debug.amx"},
+		},
+	}}
+
+	config, err := pcx.buildPrepare(context.Background(), "main", false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("/pkg", "main.pwn"), config.Input)
+	assert.Equal(t, filepath.Join("/pkg", "main.amx"), config.Output)
+
+	config, err = pcx.buildPrepare(context.Background(), "debug", false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("/pkg", "debug.pwn"), config.Input)
+	assert.Equal(t, filepath.Join("/pkg", "debug.amx"), config.Output)
+}
+
+func TestPackageContext_buildPrepareNamespace(t *testing.T) {
+	dir := "./tests/namespace"
+	os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "dependencies", "samp-logger"), 0700)
+	ioutil.WriteFile(filepath.Join(dir, "dependencies", "samp-logger", "logger.inc"), []byte("// logger"), 0600)
+
+	logger := versioning.DependencyMeta{User: "Southclaws", Repo: "samp-logger", Namespace: "vendor"}
+	pcx := PackageContext{
+		Package: types.Package{
+			LocalPath: dir,
+			Vendor:    filepath.Join(dir, "dependencies"),
+			Entry:     "main.pwn",
+			Output:    "main.amx",
+		},
+		CacheDir:        "./tests/cache",
+		AllDependencies: []versioning.DependencyMeta{logger},
+	}
+
+	config, err := pcx.buildPrepare(context.Background(), "default", false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "dependencies", ".namespaces"),
+		filepath.Join(dir, "dependencies", "samp-logger"),
+	}, config.Includes)
+	target := filepath.Join(dir, "dependencies", ".namespaces", "vendor", "samp-logger")
+	assert.True(t, util.Exists(filepath.Join(target, "logger.inc")))
+
+	// the namespace is left alone by the next build
+	before, err := os.Lstat(target)
+	assert.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+	_, err = pcx.buildPrepare(context.Background(), "default", false, false)
+	assert.NoError(t, err)
+	after, err := os.Lstat(target)
+	assert.NoError(t, err)
+	assert.Equal(t, before.ModTime(), after.ModTime())
+}
+
+func Test_shadowedIncludes(t *testing.T) {
+	dir := util.FullPath("./tests/namespace-shadowed")
+	os.RemoveAll(dir)
+	write := func(name string) string {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0600))
+		return filepath.Dir(filepath.Join(dir, name))
+	}
+
+	other := versioning.DependencyMeta{User: "other", Repo: "logger"}
+	namespaced := versioning.DependencyMeta{User: "Southclaws", Repo: "samp-logger", Namespace: "vendor"}
+	aliased := versioning.DependencyMeta{User: "someone", Repo: "logger", Alias: "logger2"}
+	otherDir := write("other/logger.inc")
+	namespacedDir := write("namespaced/logger.inc")
+	write("namespaced/logger/impl.inc")
+	write("namespaced/.git/hooks.inc")
+	write("aliased/logger/impl.inc")
+	aliasedDir := write("aliased/util.inc")
+
+	shadowed, err := shadowedIncludes(
+		[]IncludeSource{{Dir: otherDir, Owner: other}},
+		[]IncludeSource{{Dir: namespacedDir, Owner: namespaced}, {Dir: aliasedDir, Owner: aliased}},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []ShadowedInclude{
+		{Name: "logger", Owner: namespaced, By: other},
+		{Name: "logger/impl", Owner: aliased, By: namespaced},
+	}, shadowed)
+
+	// nothing is shadowed without another dependency
+	shadowed, err = shadowedIncludes(nil, []IncludeSource{{Dir: namespacedDir, Owner: namespaced}})
+	assert.NoError(t, err)
+	assert.Empty(t, shadowed)
+}
+
+func TestPresented(t *testing.T) {
+	dir := util.FullPath("./tests/namespace-presented")
+	os.RemoveAll(dir)
+	source := filepath.Join(dir, "source")
+	assert.NoError(t, os.MkdirAll(source, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(source, "logger.inc"), nil, 0600))
+
+	link := filepath.Join(dir, "link")
+	assert.False(t, presented(source, link))
+	if assert.NoError(t, os.Symlink(source, link)) {
+		assert.True(t, presented(source, link))
+		assert.False(t, presented(filepath.Join(dir, "elsewhere"), link))
+	}
+
+	copied := filepath.Join(dir, "copy")
+	assert.NoError(t, copyDir(source, copied))
+	assert.False(t, presented(source, copied), "copy without a marker")
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(copied, copyMarker), []byte(source), 0600))
+	assert.True(t, presented(source, copied))
+	assert.False(t, presented(filepath.Join(dir, "elsewhere"), copied))
+
+	// a copy is stale once a file is added to the source
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(source, "new.inc"), nil, 0600))
+	assert.False(t, presented(source, copied))
+}
+
+func TestPackageContext_buildPrepareAlias(t *testing.T) {
+	dir := "./tests/alias"
+	os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "dependencies", "sc-logger"), 0700)
+	os.MkdirAll(filepath.Join(dir, "dependencies", "logger"), 0700)
+	ioutil.WriteFile(filepath.Join(dir, "dependencies", "sc-logger", "logger.inc"), []byte("// logger"), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "dependencies", "logger", "logger.inc"), []byte("// other logger"), 0600)
+
+	aliased := versioning.DependencyMeta{User: "Southclaws", Repo: "logger", Alias: "sc-logger"}
+	other := versioning.DependencyMeta{User: "other", Repo: "logger"}
+	pcx := PackageContext{
+		Package: types.Package{
+			LocalPath: dir,
+			Vendor:    filepath.Join(dir, "dependencies"),
+			Entry:     "main.pwn",
+			Output:    "main.amx",
+		},
+		CacheDir:        "./tests/cache",
+		AllDependencies: []versioning.DependencyMeta{aliased, other},
+	}
+
+	config, err := pcx.buildPrepare(context.Background(), "default", false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "dependencies", ".aliases"),
+		filepath.Join(dir, "dependencies", "logger"),
+		filepath.Join(dir, "dependencies", "sc-logger"),
+	}, config.Includes)
+	target := filepath.Join(dir, "dependencies", ".aliases", "sc-logger")
+	assert.True(t, util.Exists(filepath.Join(target, "logger.inc")))
+
+	shimPath := filepath.Join(dir, "dependencies", ".aliases", "sc-logger.inc")
+	shim, err := ioutil.ReadFile(shimPath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(shim), `#include "sc-logger/logger.inc"`)
+
+	// the alias is left alone by the next build
+	before, err := os.Lstat(target)
+	assert.NoError(t, err)
+	beforeShim, err := os.Stat(shimPath)
+	assert.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+	_, err = pcx.buildPrepare(context.Background(), "default", false, false)
+	assert.NoError(t, err)
+	after, err := os.Lstat(target)
+	assert.NoError(t, err)
+	afterShim, err := os.Stat(shimPath)
+	assert.NoError(t, err)
+	assert.Equal(t, before.ModTime(), after.ModTime())
+	assert.Equal(t, beforeShim.ModTime(), afterShim.ModTime())
+}
+
+func TestPackageContext_buildPrepareIncludePaths(t *testing.T) {
+	dir := util.FullPath("./tests/include-paths")
+	os.RemoveAll(dir)
+	depDir := filepath.Join(dir, "dependencies", "split-lib")
+	os.MkdirAll(filepath.Join(depDir, "include"), 0700)
+	os.MkdirAll(filepath.Join(depDir, "modules"), 0700)
+	ioutil.WriteFile(filepath.Join(depDir, "pawn.json"), []byte(`{"include_path": "include", "include_paths": ["modules", "extras"]}`), 0600)
+
+	lib := versioning.DependencyMeta{User: "someone", Repo: "split-lib"}
+	pcx := PackageContext{
+		Package: types.Package{
+			LocalPath: dir,
+			Vendor:    filepath.Join(dir, "dependencies"),
+			Entry:     "main.pwn",
+			Output:    "main.amx",
+		},
+		CacheDir:        "./tests/cache",
+		AllDependencies: []versioning.DependencyMeta{lib},
+	}
+
+	config, err := pcx.buildPrepare(context.Background(), "default", false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(depDir, "modules"),
+		filepath.Join(depDir, "extras"),
+		filepath.Join(depDir, "include"),
+	}, config.Includes)
+
+	missing := pcx.missingIncludes(config)
+	assert.Equal(t, []MissingInclude{{Dir: filepath.Join(depDir, "extras"), Owner: "dependency someone/split-lib"}}, missing)
+}
+
+func TestPackageContext_buildPrepareMonorepo(t *testing.T) {
+	dir := util.FullPath("./tests/monorepo")
+	os.RemoveAll(dir)
+	repoDir := filepath.Join(dir, "dependencies", "monorepo-packages-logger")
+	loggerDir := filepath.Join(repoDir, "packages", "logger")
+	os.MkdirAll(filepath.Join(loggerDir, "include"), 0700)
+	ioutil.WriteFile(filepath.Join(repoDir, "pawn.json"), []byte(`{"include_path": "root-include"}`), 0600)
+	ioutil.WriteFile(filepath.Join(loggerDir, "pawn.json"), []byte(`{"include_path": "include", "dependencies": ["someone/formatter"]}`), 0600)
+
+	logger, err := versioning.DependencyString("someone/monorepo//packages/logger").Explode()
+	assert.NoError(t, err)
+	pcx := PackageContext{
+		Package: types.Package{
+			LocalPath: dir,
+			Vendor:    filepath.Join(dir, "dependencies"),
+			Entry:     "main.pwn",
+			Output:    "main.amx",
+		},
+		CacheDir:        "./tests/cache",
+		AllDependencies: []versioning.DependencyMeta{logger},
+	}
+
+	pkg, found, includeDir, _ := pcx.dependencyIncludes(logger)
+	assert.True(t, found)
+	assert.Equal(t, []versioning.DependencyString{"someone/formatter"}, pkg.Dependencies)
+	assert.Equal(t, filepath.Join(loggerDir, "include"), includeDir)
+
+	config, err := pcx.buildPrepare(context.Background(), "default", false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(loggerDir, "include")}, config.Includes)
+}
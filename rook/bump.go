@@ -0,0 +1,235 @@
+package rook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// BumpPolicy describes how `Bump` rewrites the version constraint of a dependency
+type BumpPolicy string
+
+const (
+	// BumpPin constrains each dependency to exactly the version it currently resolves to
+	BumpPin BumpPolicy = "pin"
+	// BumpTilde allows patch releases of the version each dependency currently resolves to
+	BumpTilde BumpPolicy = "tilde"
+	// BumpCaret allows minor and patch releases of the version each dependency currently resolves to
+	BumpCaret BumpPolicy = "caret"
+	// BumpLatest moves each constraint to the newest version while keeping its operator
+	BumpLatest BumpPolicy = "latest"
+)
+
+// BumpPolicies lists the valid policies
+var BumpPolicies = []BumpPolicy{BumpPin, BumpTilde, BumpCaret, BumpLatest}
+
+// BumpChange is a dependency constraint rewritten by `Bump`
+type BumpChange struct {
+	From versioning.DependencyString
+	To   versioning.DependencyString
+}
+
+func (bc BumpChange) String() string {
+	return fmt.Sprintf("%s -> %s", bc.From, bc.To)
+}
+
+// Bump rewrites the version constraints of the dependencies and development dependencies of the
+// package according to a policy, then ensures the dependencies so the lockfile and vendor directory
+// match the new constraints and writes the package definition. Only dependencies with a tag
+// constraint on a repository with semantic version tags are changed, those that track a branch or
+// a commit are left alone. Constraints that aren't a single version with an optional `=`, `~`, `^`
+// or `>=` operator, such as `1.2.x` or `>=1.0.0 <2.0.0`, are also left alone by `latest` since it
+// can't keep their operator.
+//
+// The current version of a dependency is the newest version tag on the commit it is vendored at.
+// For `latest`, the cached copy of each dependency is updated first and prereleases are skipped.
+// Versions come from the version source of each dependency, so for `releases` only versions with a
+// GitHub release count and those marked as prereleases are skipped too.
+func (pcx *PackageContext) Bump(ctx context.Context, policy BumpPolicy) (changes []BumpChange, err error) {
+	valid := false
+	for _, p := range BumpPolicies {
+		if policy == p {
+			valid = true
+		}
+	}
+	if !valid {
+		return nil, errors.Errorf("unknown bump policy %s, must be one of %v", policy, BumpPolicies)
+	}
+
+	bumpAll := func(deps []versioning.DependencyString) (result []versioning.DependencyString, errInner error) {
+		for _, dep := range deps {
+			var bumped versioning.DependencyString
+			bumped, errInner = pcx.bumpDependency(ctx, dep, policy)
+			if errInner != nil {
+				return
+			}
+			if bumped != dep {
+				changes = append(changes, BumpChange{From: dep, To: bumped})
+			}
+			result = append(result, bumped)
+		}
+		return
+	}
+
+	dependencies, err := bumpAll(pcx.Package.Dependencies)
+	if err != nil {
+		return
+	}
+	development, err := bumpAll(pcx.Package.Development)
+	if err != nil {
+		return
+	}
+	if len(changes) == 0 {
+		return
+	}
+	pcx.Package.Dependencies = dependencies
+	pcx.Package.Development = development
+
+	print.Verb(pcx.Package, "ensuring dependencies are cached for bumped constraints")
+	err = pcx.EnsureDependenciesCached(ctx)
+	if err != nil {
+		return
+	}
+
+	print.Verb(pcx.Package, "ensuring dependencies at bumped constraints")
+	err = pcx.EnsureDependencies(ctx, false)
+	if err != nil {
+		return
+	}
+
+	err = pcx.Package.WriteDefinition()
+	return
+}
+
+// bumpDependency returns the dependency string with its constraint rewritten by the policy, or the
+// same string if the policy doesn't apply to it
+func (pcx *PackageContext) bumpDependency(ctx context.Context, dep versioning.DependencyString, policy BumpPolicy) (bumped versioning.DependencyString, err error) {
+	bumped = dep
+
+	meta, err := pcx.explode(dep)
+	if err != nil {
+		return dep, errors.Wrapf(err, "failed to parse %s as a dependency string", dep)
+	}
+	if meta.Tag == "" || !strings.HasSuffix(string(dep), ":"+meta.Tag) {
+		print.Verb(meta, "has no version constraint, not bumping")
+		return
+	}
+	meta.Alias = pcx.aliasOf(meta)
+
+	var version *semver.Version
+	if policy == BumpLatest {
+		_, err = pcx.EnsureDependencyCached(ctx, meta, true)
+		if err != nil {
+			return dep, errors.Wrapf(err, "failed to update cached copy of %s", meta)
+		}
+		version, err = pcx.latestVersion(ctx, meta)
+	} else {
+		version, err = pcx.currentVersion(ctx, meta)
+	}
+	if err != nil {
+		return
+	}
+	if version == nil {
+		print.Warn(meta, "has no version to bump to, leaving it as", meta.Tag)
+		return
+	}
+
+	var operator string
+	switch policy {
+	case BumpPin:
+		operator = ""
+	case BumpTilde:
+		operator = "~"
+	case BumpCaret:
+		operator = "^"
+	case BumpLatest:
+		var ok bool
+		operator, ok = constraintOperator(meta.Tag)
+		if !ok {
+			print.Warn(meta, "constraint", meta.Tag, "is not a single version, leaving it as-is")
+			return
+		}
+	}
+
+	prefix := strings.TrimSuffix(string(dep), meta.Tag)
+	bumped = versioning.DependencyString(prefix + operator + version.Original())
+	return
+}
+
+// currentVersion returns the newest version tag on the commit a dependency is vendored at
+func (pcx *PackageContext) currentVersion(ctx context.Context, meta versioning.DependencyMeta) (version *semver.Version, err error) {
+	commit, err := pcx.vendoredCommit(meta)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s must be ensured before it can be bumped", meta)
+	}
+	tags, err := pcx.cachedVersionTags(ctx, meta)
+	if err != nil {
+		return
+	}
+	for _, tag := range tags {
+		if tag.Ref.Hash().String() != commit {
+			continue
+		}
+		if version == nil || tag.Version.GreaterThan(version) {
+			version = tag.Version
+		}
+	}
+	return
+}
+
+// latestVersion returns the newest version tag of a dependency that isn't a prerelease
+func (pcx *PackageContext) latestVersion(ctx context.Context, meta versioning.DependencyMeta) (version *semver.Version, err error) {
+	tags, err := pcx.cachedVersionTags(ctx, meta)
+	if err != nil {
+		return
+	}
+	for _, tag := range tags {
+		if tag.Version.Prerelease() != "" || tag.Prerelease {
+			continue
+		}
+		if version == nil || tag.Version.GreaterThan(version) {
+			version = tag.Version
+		}
+	}
+	return
+}
+
+// cachedVersionTags lists the versions of a dependency from its version source
+func (pcx *PackageContext) cachedVersionTags(ctx context.Context, meta versioning.DependencyMeta) (tags versioning.VersionedTags, err error) {
+	repo, err := git.PlainOpen(pcx.cachePath(meta))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open cached copy of %s", meta)
+	}
+	source, err := pcx.versionSourceOf(meta)
+	if err != nil {
+		return
+	}
+	if source == VersionSourceReleases {
+		releases, errReleases := pcx.githubReleases(ctx, meta)
+		if errReleases != nil {
+			return nil, errReleases
+		}
+		return releaseVersions(repo, releases), nil
+	}
+	return versioning.GetRepoSemverTags(repo)
+}
+
+// constraintOperator returns the operator of a constraint that is a single version, if it is one
+func constraintOperator(constraint string) (operator string, ok bool) {
+	for _, op := range []string{">=", "=", "~", "^", ""} {
+		if !strings.HasPrefix(constraint, op) {
+			continue
+		}
+		if _, err := semver.NewVersion(strings.TrimSpace(strings.TrimPrefix(constraint, op))); err == nil {
+			return op, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,98 @@
+package rook
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// commitVersions commits a version of a file for each tag in order, commits are made with fixed
+// details so the same versions have the same hashes in every repository
+func commitVersions(t *testing.T, dir string, tags []string) {
+	repo, err := git.PlainInit(dir, false)
+	assert.NoError(t, err)
+	wt, err := repo.Worktree()
+	assert.NoError(t, err)
+
+	when := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, tag := range tags {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "lib.inc"), []byte("// "+tag), 0644))
+		_, err = wt.Add("lib.inc")
+		assert.NoError(t, err)
+		signature := &object.Signature{Name: "test", Email: "test@test", When: when.Add(time.Duration(i) * time.Hour)}
+		hash, errCommit := wt.Commit(tag, &git.CommitOptions{Author: signature, Committer: signature})
+		assert.NoError(t, errCommit)
+		assert.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName("refs/tags/"+tag), hash)))
+	}
+}
+
+func TestPackageContext_bumpDependency(t *testing.T) {
+	dir := util.FullPath("./tests/bump")
+	os.RemoveAll(dir)
+
+	meta := versioning.DependencyMeta{Site: "github.com", User: "test", Repo: "lib"}
+	cacheDir := filepath.Join(dir, "cache")
+	commitVersions(t, meta.CachePath(cacheDir), []string{"v1.0.0", "v1.1.0", "v2.0.0", "v3.0.0-beta"})
+	commitVersions(t, filepath.Join(dir, "package", "dependencies", "lib"), []string{"v1.0.0", "v1.1.0"})
+
+	pcx := PackageContext{
+		Package:  types.Package{LocalPath: filepath.Join(dir, "package"), Vendor: filepath.Join(dir, "package", "dependencies")},
+		CacheDir: cacheDir,
+	}
+
+	for _, tt := range []struct {
+		dep    versioning.DependencyString
+		policy BumpPolicy
+		want   versioning.DependencyString
+	}{
+		{"test/lib:^1.0.0", BumpPin, "test/lib:v1.1.0"},
+		{"test/lib:1.1.0", BumpTilde, "test/lib:~v1.1.0"},
+		{"https://github.com/test/lib:~1.0.0", BumpCaret, "https://github.com/test/lib:^v1.1.0"},
+		{"test/lib@develop", BumpPin, "test/lib@develop"},
+		{"test/lib", BumpCaret, "test/lib"},
+	} {
+		got, err := pcx.bumpDependency(context.Background(), tt.dep, tt.policy)
+		assert.NoError(t, err, tt.dep)
+		assert.Equal(t, tt.want, got, tt.dep)
+	}
+
+	latest, err := pcx.latestVersion(context.Background(), meta)
+	assert.NoError(t, err)
+	assert.Equal(t, "v2.0.0", latest.Original())
+
+	_, err = pcx.Bump(context.Background(), "wider")
+	assert.Error(t, err)
+}
+
+func TestConstraintOperator(t *testing.T) {
+	for _, tt := range []struct {
+		constraint string
+		operator   string
+		ok         bool
+	}{
+		{"1.2.3", "", true},
+		{"v1.2.3", "", true},
+		{"^1.2.3", "^", true},
+		{"~1.2.3", "~", true},
+		{">=1.2.3", ">=", true},
+		{"=1.2.3", "=", true},
+		{"1.2.x", "", false},
+		{">=1.0.0 <2.0.0", "", false},
+	} {
+		operator, ok := constraintOperator(tt.constraint)
+		assert.Equal(t, tt.ok, ok, tt.constraint)
+		assert.Equal(t, tt.operator, operator, tt.constraint)
+	}
+}
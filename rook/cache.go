@@ -1,6 +1,8 @@
 package rook
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -16,8 +18,10 @@ import (
 )
 
 // EnsureDependenciesCached will recursively visit a parent package dependencies
-// in the cache, pulling them if they do not exist yet.
-func (pcx *PackageContext) EnsureDependenciesCached() (errOuter error) {
+// in the cache, pulling them if they do not exist yet. With a read-only vendor
+// directory, the vendored copies are visited instead and it's an error for any
+// of them to be missing.
+func (pcx *PackageContext) EnsureDependenciesCached(ctx context.Context) (errOuter error) {
 	if !pcx.Package.Parent {
 		errOuter = errors.New("package is not a parent package")
 		return
@@ -36,11 +40,18 @@ func (pcx *PackageContext) EnsureDependenciesCached() (errOuter error) {
 		firstIter      = true
 		currentPackage types.Package
 		errInner       error
+		missing        []string
 	)
 
 	// clear the dependencies list in case this function is being called on an
 	// already initialised context that already has some dependencies listed.
 	pcx.AllDependencies = nil
+	pcx.Constraints = nil
+	pcx.OtherPlatforms = nil
+	pcx.requests = nil
+	if pcx.ReadOnlyVendor {
+		pcx.AllPlugins = nil
+	}
 
 	// set the parent package visited state to true, just in case it depends on
 	// itself or a dependency depends on it. This should never happen but if it
@@ -60,43 +71,72 @@ func (pcx *PackageContext) EnsureDependenciesCached() (errOuter error) {
 		// and the path will be it's true, user-defined location.
 		if firstIter {
 			currentPackage = pcx.Package // set the current package to the parent
-			print.Verb(prefix, currentPackage, "is parent")
+			print.Debug(prefix, currentPackage, "is parent")
 		} else {
-			dependencyPath = currentMeta.CachePath(pcx.CacheDir)
-
-			_, errInner = pcx.EnsureDependencyCached(currentMeta, false)
-			if errInner != nil {
-				print.Erro(errInner)
-				return
+			currentMeta.DefaultBranch = pcx.defaultBranchOf(currentMeta)
+
+			if pcx.ReadOnlyVendor {
+				// nothing is cloned, the vendored copy must already be there
+				vendored := filepath.Join(pcx.Package.Vendor, currentMeta.VendorName())
+				if !util.Exists(vendored) {
+					missing = append(missing, fmt.Sprintf("%s is not vendored at %s", currentMeta, vendored))
+					return
+				}
+				dependencyPath = currentMeta.PackageDir(vendored)
+			} else {
+				dependencyPath = currentMeta.PackageDir(pcx.cachePath(currentMeta))
+
+				_, errInner = pcx.EnsureDependencyCached(ctx, currentMeta, false)
+				if errInner != nil {
+					if ctx.Err() != nil {
+						errOuter = ctx.Err()
+					}
+					print.Erro(errInner)
+					return
+				}
+			}
+			if namespace, ok := pcx.Package.Namespaces[currentMeta.User+"/"+currentMeta.Repo]; ok {
+				currentMeta.Namespace = namespace
 			}
+			currentMeta.Alias = pcx.aliasOf(currentMeta)
 			pcx.AllDependencies = append(pcx.AllDependencies, currentMeta)
-			print.Verb(prefix, currentMeta, "ensured")
+			print.Debug(prefix, currentMeta, "ensured")
 
 			currentPackage, errInner = types.PackageFromDir(dependencyPath)
 			if errInner != nil {
 				print.Verb(prefix, currentMeta, "is not a package:", errInner)
 				return
 			}
+
+			// plugins are otherwise found while their resources are ensured
+			if pcx.ReadOnlyVendor && providesPlugins(currentPackage, pcx.Platform) {
+				pcx.AllPlugins = append(pcx.AllPlugins, currentMeta)
+				print.Debug(prefix, currentMeta, "provides plugins")
+			}
 		}
 
 		// Run through resources for the target platform and grab all the
 		// include paths that will be used for includes from resource archives.
 		for _, res := range currentPackage.Resources {
 			if res.Platform != pcx.Platform {
-				print.Verb(prefix, "ignoring platform mismatch", res.Platform)
+				print.Debug(prefix, "ignoring platform mismatch", res.Platform)
 				continue
 			}
 
 			if len(res.Includes) > 0 {
 				targetPath := filepath.Join(pcx.Package.Vendor, res.Path(currentPackage))
+				if pcx.ReadOnlyVendor && !util.Exists(targetPath) {
+					missing = append(missing, fmt.Sprintf("includes of resource %s are not vendored at %s", res.Name, targetPath))
+					continue
+				}
 				pcx.AllIncludePaths = append(pcx.AllIncludePaths, targetPath)
-				print.Verb(prefix, "added target path for resource includes:", targetPath)
+				print.Debug(prefix, "added target path for resource includes:", targetPath)
 			}
 		}
 
 		// mark the repo as visited so we don't hit it again in case it appears
 		// multiple times within the dependency tree.
-		visited[currentMeta.Repo] = true
+		visited[currentMeta.VendorName()] = true
 
 		var subPackageDepStrings []versioning.DependencyString
 
@@ -106,63 +146,95 @@ func (pcx *PackageContext) EnsureDependenciesCached() (errOuter error) {
 			subPackageDepStrings = currentPackage.Dependencies
 		}
 
+		// constraints declared by the parent package itself have no requester
+		requester := currentMeta
+		if firstIter {
+			requester = versioning.DependencyMeta{}
+		}
+
 		// first iteration has finished, mark it false and next iterations will
 		// operate on dependencies
 		firstIter = false
 
-		print.Verb(prefix, "iterating", len(subPackageDepStrings), "dependencies of", currentPackage)
+		print.Debug(prefix, "iterating", len(subPackageDepStrings), "dependencies of", currentPackage)
 		var subPackageDepMeta versioning.DependencyMeta
 		for _, subPackageDepString := range subPackageDepStrings {
-			subPackageDepMeta, errInner = subPackageDepString.Explode()
+			subPackageDepMeta, errInner = pcx.explode(subPackageDepString)
 			if errInner != nil {
 				print.Verb(prefix, "invalid dependency string:", subPackageDepMeta, "in", currentPackage, errInner)
 				continue
 			}
 
-			if _, ok := visited[subPackageDepMeta.Repo]; !ok {
+			if errOuter != nil {
+				break
+			}
+
+			subPackageDepMeta.Alias = pcx.aliasOf(subPackageDepMeta)
+			if pcx.otherPlatform(subPackageDepMeta) {
+				print.Verb(prefix, "ignoring", subPackageDepMeta, "which is only used on", pcx.platformOf(subPackageDepMeta))
+				pcx.OtherPlatforms = append(pcx.OtherPlatforms, subPackageDepMeta)
+				continue
+			}
+			if override := pcx.overrideOf(subPackageDepMeta); override != "" {
+				print.Verb(prefix, "overriding the constraint on", subPackageDepMeta, "with", override)
+				subPackageDepMeta.Tag, subPackageDepMeta.Branch, subPackageDepMeta.Commit = override, "", ""
+			}
+			pcx.addConstraint(subPackageDepMeta)
+			pcx.addRequest(requester, subPackageDepMeta)
+			if _, ok := visited[subPackageDepMeta.VendorName()]; !ok {
 				recurse(subPackageDepMeta)
 			} else {
-				print.Verb(prefix, "already visited", subPackageDepMeta)
+				print.Debug(prefix, "already visited", subPackageDepMeta)
 			}
 		}
 		verboseDepth--
 	}
 	recurse(pcx.Package.DependencyMeta)
 
+	if errOuter == nil && len(missing) > 0 {
+		errOuter = errors.Errorf("the read-only vendor directory %s is missing dependencies:\n%s", pcx.Package.Vendor, strings.Join(missing, "\n"))
+	}
 	return
 }
 
 // EnsureDependencyFromCache ensures the repository at `path` is up to date
-func (pcx PackageContext) EnsureDependencyFromCache(meta versioning.DependencyMeta, path string, forceUpdate bool) (repo *git.Repository, err error) {
-	print.Verb(meta, "ensuring dependency package from cache to", path, "force update:", forceUpdate)
+func (pcx PackageContext) EnsureDependencyFromCache(ctx context.Context, meta versioning.DependencyMeta, path string, forceUpdate bool) (repo *git.Repository, err error) {
+	print.Debug(meta, "ensuring dependency package from cache to", path, "force update:", forceUpdate)
 
-	from, err := filepath.Abs(meta.CachePath(pcx.CacheDir))
+	from, err := filepath.Abs(pcx.cachePath(meta))
 	if err != nil {
 		err = errors.Wrap(err, "failed to make canonical path to cached copy")
 		return
 	}
 	if !util.Exists(filepath.Join(from, ".git")) || forceUpdate {
-		_, err = pcx.EnsureDependencyCached(meta, forceUpdate)
+		_, err = pcx.EnsureDependencyCached(ctx, meta, forceUpdate)
 		if err != nil {
 			return
 		}
 	}
 
-	repo, err = pcx.ensureRepoExists(from, path, meta.Branch, meta.SSH != "", forceUpdate)
+	repo, err = pcx.ensureRepoExists(ctx, from, path, pcx.defaultBranch(ctx, meta), meta.SSH != "", forceUpdate)
 	return
 }
 
 // EnsureDependencyCached clones a package to path using the default branch
-func (pcx PackageContext) EnsureDependencyCached(meta versioning.DependencyMeta, forceUpdate bool) (repo *git.Repository, err error) {
-	return pcx.ensureRepoExists(meta.URL(), meta.CachePath(pcx.CacheDir), meta.Branch, meta.SSH != "", forceUpdate)
+func (pcx PackageContext) EnsureDependencyCached(ctx context.Context, meta versioning.DependencyMeta, forceUpdate bool) (repo *git.Repository, err error) {
+	if pcx.Registry.handles(meta) {
+		return pcx.Registry.ensure(ctx, meta, pcx.cachePath(meta), forceUpdate)
+	}
+	url, ssh := cloneURL(meta)
+	return pcx.ensureRepoExists(ctx, url, meta.CachePath(pcx.CacheDir), pcx.defaultBranch(ctx, meta), ssh, forceUpdate)
 }
 
-func (pcx PackageContext) ensureRepoExists(from, to, branch string, ssh, forceUpdate bool) (repo *git.Repository, err error) {
+func (pcx PackageContext) ensureRepoExists(ctx context.Context, from, to, branch string, ssh, forceUpdate bool) (repo *git.Repository, err error) {
 	repo, err = git.PlainOpen(to)
 	if err != nil {
-		print.Verb("no repo at", to, "-", err, "cloning new copy")
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		print.Debug("no repo at", to, "-", err, "cloning new copy")
 		if util.Exists(to) {
-			print.Verb("removing existing folder", to)
+			print.Debug("removing existing folder", to)
 			err = os.RemoveAll(to)
 			if err != nil {
 				return
@@ -186,8 +258,19 @@ func (pcx PackageContext) ensureRepoExists(from, to, branch string, ssh, forceUp
 			cloneOpts.Auth = pcx.GitAuth
 		}
 
-		print.Verb("cloning latest copy to", to, "with", cloneOpts)
-		return git.PlainClone(to, false, cloneOpts)
+		print.Debug("cloning latest copy to", to, "with", cloneOpts)
+		repo, err = git.PlainCloneContext(ctx, to, false, cloneOpts)
+		if err != nil {
+			// don't leave a partial clone behind, it would be mistaken for a valid repo later
+			print.Debug("removing partially cloned repository", to)
+			if errRemove := os.RemoveAll(to); errRemove != nil {
+				print.Erro("Failed to remove partial clone:", errRemove)
+			}
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+		}
+		return
 	}
 
 	if forceUpdate {
@@ -198,22 +281,26 @@ func (pcx PackageContext) ensureRepoExists(from, to, branch string, ssh, forceUp
 		}
 
 		pullOpts := &git.PullOptions{
-			Depth: 1000,
+			Depth:         1000,
+			ReferenceName: pullReference(repo, branch),
 		}
-		if branch != "" {
-			pullOpts.ReferenceName = plumbing.ReferenceName("refs/heads/" + branch)
+		if ssh {
+			pullOpts.Auth = pcx.GitAuth
 		}
 
-		print.Verb("pulling latest copy to", to, "with", pullOpts)
-		err = wt.Pull(pullOpts)
+		print.Debug("pulling latest copy to", to, "with", pullOpts)
+		err = wt.PullContext(ctx, pullOpts)
 		if err != nil && err != git.NoErrAlreadyUpToDate {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			print.Verb("failed to pull, removing repository and starting fresh")
 			err = os.RemoveAll(to)
 			if err != nil {
 				err = errors.Wrap(err, "failed to remove repo in bad state for re-clone")
 				return
 			}
-			return pcx.ensureRepoExists(from, to, branch, ssh, false)
+			return pcx.ensureRepoExists(ctx, from, to, branch, ssh, false)
 		}
 	}
 
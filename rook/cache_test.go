@@ -1,6 +1,7 @@
 package rook
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -67,7 +68,7 @@ func TestEnsureDependenciesCached(t *testing.T) {
 			tt.pcx.GitHub = gh
 			tt.pcx.GitAuth = gitAuth
 
-			err := tt.pcx.EnsureDependenciesCached()
+			err := tt.pcx.EnsureDependenciesCached(context.Background())
 			if tt.wantErr {
 				assert.Equal(t, tt.wantErr, err)
 			} else {
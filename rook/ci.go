@@ -0,0 +1,168 @@
+package rook
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+// WorkflowPath is where a generated CI workflow is written to, relative to the package
+var WorkflowPath = filepath.Join(".github", "workflows", "sampctl.yml")
+
+// Workflow is a GitHub Actions workflow generated for a package
+type Workflow struct {
+	Contents []byte
+	Builds   []WorkflowBuild // the build configs the workflow compiles, one job each
+	Tests    []string        // the runtime configs the workflow runs as tests
+	Frozen   bool            // whether dependencies are ensured against the lockfile
+}
+
+// WorkflowBuild is a build config and the compiler version it resolves to
+type WorkflowBuild struct {
+	Name     string `yaml:"build"`
+	Compiler string `yaml:"compiler"`
+}
+
+// GenerateWorkflow creates a GitHub Actions workflow that installs sampctl, ensures dependencies,
+// builds each build config of the package with the compiler version it declares and runs each
+// runtime config that is an automated test, `headless` or `y_testing`, after the builds pass. Jobs
+// run on Linux so builds scoped to another platform are left out. Dependencies are ensured with
+// `--frozen` if the package has a lockfile.
+func GenerateWorkflow(pkg types.Package) (workflow Workflow, err error) {
+	workflow.Builds = workflowBuilds(pkg)
+	workflow.Tests = workflowTests(pkg)
+	workflow.Frozen = util.Exists(filepath.Join(pkg.LocalPath, types.LockfileName))
+
+	ensure := "sampctl package ensure"
+	if workflow.Frozen {
+		ensure += " --frozen"
+	}
+
+	setup := []interface{}{
+		yaml.MapSlice{
+			{Key: "uses", Value: "actions/checkout@v2"},
+		},
+		yaml.MapSlice{
+			{Key: "name", Value: "Install sampctl"},
+			{Key: "run", Value: "sudo dpkg --add-architecture i386\n" +
+				"sudo apt-get update\n" +
+				"sudo apt-get install -y libc6:i386\n" +
+				"curl https://raw.githubusercontent.com/Southclaws/sampctl/master/install-deb.sh | sh\n"},
+		},
+		yaml.MapSlice{
+			{Key: "name", Value: "Cache compilers and runtimes"},
+			{Key: "uses", Value: "actions/cache@v2"},
+			{Key: "with", Value: yaml.MapSlice{
+				{Key: "path", Value: "~/.samp"},
+				{Key: "key", Value: "sampctl-${{ runner.os }}-${{ hashFiles('pawn.json', 'pawn.yaml') }}"},
+			}},
+		},
+		yaml.MapSlice{
+			{Key: "name", Value: "Ensure dependencies"},
+			{Key: "run", Value: ensure},
+		},
+	}
+
+	include := make([]interface{}, len(workflow.Builds))
+	for i, build := range workflow.Builds {
+		include[i] = build
+	}
+	jobs := yaml.MapSlice{{Key: "build", Value: yaml.MapSlice{
+		{Key: "name", Value: "build ${{ matrix.build }} (compiler ${{ matrix.compiler }})"},
+		{Key: "runs-on", Value: "ubuntu-latest"},
+		{Key: "strategy", Value: yaml.MapSlice{
+			{Key: "fail-fast", Value: false},
+			{Key: "matrix", Value: yaml.MapSlice{{Key: "include", Value: include}}},
+		}},
+		{Key: "steps", Value: append(setup, yaml.MapSlice{
+			{Key: "name", Value: "Build"},
+			{Key: "run", Value: "sampctl package build ${{ matrix.build }}"},
+		})},
+	}}}
+
+	if len(workflow.Tests) > 0 {
+		jobs = append(jobs, yaml.MapSlice{{Key: "test", Value: yaml.MapSlice{
+			{Key: "name", Value: "test ${{ matrix.runtime }}"},
+			{Key: "needs", Value: "build"},
+			{Key: "runs-on", Value: "ubuntu-latest"},
+			{Key: "strategy", Value: yaml.MapSlice{
+				{Key: "fail-fast", Value: false},
+				{Key: "matrix", Value: yaml.MapSlice{{Key: "runtime", Value: workflow.Tests}}},
+			}},
+			{Key: "steps", Value: append(setup, yaml.MapSlice{
+				{Key: "name", Value: "Test"},
+				{Key: "run", Value: "sampctl package run ${{ matrix.runtime }} --forceBuild"},
+			})},
+		}}}...)
+	}
+
+	contents, err := yaml.Marshal(yaml.MapSlice{
+		{Key: "name", Value: "sampctl"},
+		{Key: "on", Value: []string{"push", "pull_request"}},
+		{Key: "jobs", Value: jobs},
+	})
+	if err != nil {
+		err = errors.Wrap(err, "failed to encode workflow")
+		return
+	}
+
+	workflow.Contents = append([]byte(fmt.Sprintf("# Generated by `sampctl package ci` for %s\n", pkg)), contents...)
+	return
+}
+
+// workflowBuilds lists the build configs that can run on Linux by the name they are built with
+func workflowBuilds(pkg types.Package) (builds []WorkflowBuild) {
+	var names []string
+	if (pkg.Build == nil && len(pkg.Builds) == 0) || (pkg.Build != nil && pkg.Build.MatchesPlatform("linux")) {
+		names = append(names, "default")
+	}
+	for _, cfg := range pkg.Builds {
+		if !cfg.MatchesPlatform("linux") {
+			continue
+		}
+		if cfg.Name != "" {
+			names = append(names, cfg.Name)
+		} else if len(names) == 0 {
+			// an unnamed build is only reachable as the default
+			names = append(names, "default")
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		cfg := GetBuildConfig(pkg, name, "linux")
+		builds = append(builds, WorkflowBuild{Name: name, Compiler: string(cfg.Version)})
+	}
+	return
+}
+
+// workflowTests lists the runtime configs with a run mode that passes or fails on its own
+func workflowTests(pkg types.Package) (tests []string) {
+	isTest := func(cfg *types.Runtime) bool {
+		return cfg.Mode == types.Headless || cfg.Mode == types.YTesting
+	}
+	if pkg.Runtime != nil && isTest(pkg.Runtime) {
+		tests = append(tests, "default")
+	}
+	for i, cfg := range pkg.Runtimes {
+		if !isTest(cfg) {
+			continue
+		}
+		switch {
+		case cfg.Name != "":
+			tests = append(tests, cfg.Name)
+		case i == 0 && pkg.Runtime == nil:
+			tests = append(tests, "default")
+		}
+	}
+	return
+}
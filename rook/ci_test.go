@@ -0,0 +1,85 @@
+package rook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestGenerateWorkflow(t *testing.T) {
+	dir := util.FullPath("./tests/ci")
+	os.RemoveAll(dir)
+	os.MkdirAll(dir, 0755) //nolint
+
+	tests := []struct {
+		name       string
+		pkg        types.Package
+		lockfile   bool
+		wantBuilds []WorkflowBuild
+		wantTests  []string
+	}{
+		{"none", types.Package{}, false, []WorkflowBuild{{"default", "3.10.4"}}, nil},
+		{"single", types.Package{
+			Build:   &types.BuildConfig{Version: "3.10.8"},
+			Runtime: &types.Runtime{Mode: types.YTesting},
+		}, true, []WorkflowBuild{{"default", "3.10.8"}}, []string{"default"}},
+		{"multiple", types.Package{
+			Builds: []*types.BuildConfig{
+				{Name: "main", Version: "3.10.8"},
+				{Name: "legacy", Version: "3.2.3664"},
+				{Name: "windows", Platform: "windows"},
+				{Name: "overlay", Platforms: map[string]*types.BuildConfig{"linux": {Version: "3.10.9"}}},
+			},
+			Runtimes: []*types.Runtime{
+				{Name: "server"},
+				{Name: "tests", Mode: types.Headless},
+			},
+		}, false, []WorkflowBuild{{"main", "3.10.8"}, {"legacy", "3.2.3664"}, {"overlay", "3.10.9"}}, []string{"tests"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(filepath.Join(dir, types.LockfileName)) //nolint
+			if tt.lockfile {
+				err := ioutil.WriteFile(filepath.Join(dir, types.LockfileName), []byte("{}"), 0644)
+				if err != nil {
+					panic(err)
+				}
+			}
+			tt.pkg.LocalPath = dir
+
+			workflow, err := GenerateWorkflow(tt.pkg)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantBuilds, workflow.Builds)
+			assert.Equal(t, tt.wantTests, workflow.Tests)
+			assert.Equal(t, tt.lockfile, workflow.Frozen)
+
+			var decoded struct {
+				Jobs map[string]struct {
+					Strategy struct {
+						Matrix map[string]interface{} `yaml:"matrix"`
+					} `yaml:"strategy"`
+					Steps []map[string]interface{} `yaml:"steps"`
+				} `yaml:"jobs"`
+			}
+			err = yaml.Unmarshal(workflow.Contents, &decoded)
+			assert.NoError(t, err)
+
+			assert.Len(t, decoded.Jobs["build"].Strategy.Matrix["include"], len(tt.wantBuilds))
+			_, hasTests := decoded.Jobs["test"]
+			assert.Equal(t, len(tt.wantTests) > 0, hasTests)
+
+			ensure := "sampctl package ensure"
+			if tt.lockfile {
+				ensure += " --frozen"
+			}
+			assert.Contains(t, decoded.Jobs["build"].Steps, map[string]interface{}{"name": "Ensure dependencies", "run": ensure})
+		})
+	}
+}
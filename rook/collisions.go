@@ -0,0 +1,339 @@
+package rook
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+var (
+	// stock Float:GetDistance(
+	matchStock = regexp.MustCompile(`^\s*(?:static\s+)?stock\s+(?:[A-Za-z_@][\w@]*:)?([A-Za-z_@][\w@]*)\s*\(`)
+
+	// static stock Float:GetDistance(
+	matchStaticStock = regexp.MustCompile(`^\s*static\s+stock\s`)
+
+	// native SetPlayerPos(
+	matchNative = regexp.MustCompile(`^\s*native\s+(?:[A-Za-z_@][\w@]*:)?([A-Za-z_@][\w@]*)\s*\(`)
+
+	// forward OnPlayerThing(playerid);
+	matchForward = regexp.MustCompile(`^\s*forward\s+(?:[A-Za-z_@][\w@]*:)?([A-Za-z_@][\w@]*)\s*\(`)
+
+	// #define MAX_THINGS (10)
+	matchDefine = regexp.MustCompile(`^\s*#define\s+([A-Za-z_@][\w@]*)`)
+
+	// the keywords before the tag and name of a declaration
+	matchDeclarationKeywords = regexp.MustCompile(`^\s*(?:(?:static|stock|native|forward)\s+)+|^\s*#define\s+`)
+
+	// #if !defined MAX_THINGS
+	matchDefinedGuard = regexp.MustCompile(`^\s*#if\s+!\s*defined\s+([A-Za-z_@][\w@]*)`)
+)
+
+// Symbol represents a single declaration found in an include file
+type Symbol struct {
+	Name      string                    // the name of the function or macro
+	Kind      string                    // `stock`, `native`, `forward` or `define`
+	Signature string                    // the tag, name and parameters without extra whitespace
+	Static    bool                      // a `static stock`, which is only visible within its file
+	File      string                    // the file the declaration was found in
+	Line      int                       // the line number of the declaration
+	Owner     versioning.DependencyMeta // the package that owns the file
+}
+
+func (s Symbol) String() string {
+	return fmt.Sprintf("%s %s in %s (%s:%d)", s.Kind, s.Name, s.Owner, s.File, s.Line)
+}
+
+// Collision is a symbol name that is declared by more than one package
+type Collision struct {
+	Name    string
+	Symbols []Symbol
+}
+
+// IncludeSource pairs an include directory with the package that provides it
+type IncludeSource struct {
+	Dir   string
+	Owner versioning.DependencyMeta
+}
+
+// DetectCollisions scans the include files of each source for `stock`, `native`, `forward` and
+// `#define` declarations and returns every name that is declared by more than one package. This is
+// not a full Pawn parser, it only looks at the start of each line outside of comments and skips
+// macros that are guarded by an `#if !defined` check on the previous line. Callbacks are meant to be
+// forwarded by every package that implements them, so a name that is only forwarded collides only
+// if the forwards don't have the same signature.
+func DetectCollisions(sources []IncludeSource) (collisions []Collision, err error) {
+	symbols := make(map[string][]Symbol)
+
+	for _, source := range sources {
+		if !util.Exists(source.Dir) {
+			continue
+		}
+		err = filepath.Walk(source.Dir, func(path string, info os.FileInfo, errInner error) error {
+			if errInner != nil {
+				return errInner
+			}
+			if info.IsDir() {
+				if path != source.Dir && (strings.HasPrefix(info.Name(), ".") || info.Name() == "dependencies") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if filepath.Ext(path) != ".inc" {
+				return nil
+			}
+
+			found, errInner := scanFile(path, path, source.Owner)
+			if errInner != nil {
+				return errInner
+			}
+			for _, symbol := range found {
+				symbols[symbol.Name] = append(symbols[symbol.Name], symbol)
+			}
+			return nil
+		})
+		if err != nil {
+			err = errors.Wrapf(err, "failed to scan include path of %s", source.Owner)
+			return
+		}
+	}
+
+	for name, list := range symbols {
+		owners := make(map[string]struct{})
+		for _, symbol := range list {
+			owners[symbol.Owner.User+"/"+symbol.Owner.Repo] = struct{}{}
+		}
+		if len(owners) > 1 && !sameForwards(list) {
+			collisions = append(collisions, Collision{Name: name, Symbols: list})
+		}
+	}
+
+	sort.Slice(collisions, func(i, j int) bool {
+		return collisions[i].Name < collisions[j].Name
+	})
+
+	return
+}
+
+// sameForwards is true if every symbol is a forward with the same signature
+func sameForwards(symbols []Symbol) bool {
+	for _, symbol := range symbols {
+		if symbol.Kind != "forward" || symbol.Signature != symbols[0].Signature {
+			return false
+		}
+	}
+	return true
+}
+
+func scanSymbols(r io.Reader, file string, owner versioning.DependencyMeta) (symbols []Symbol, err error) {
+	var (
+		scanner   = bufio.NewScanner(r)
+		line      = 0
+		inComment = false
+		guarded   = ""
+		pending   = "" // the start of a signature with parameters that continue on the next lines
+		depth     = 0
+	)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+
+		if inComment {
+			end := strings.Index(text, "*/")
+			if end == -1 {
+				continue
+			}
+			text = text[end+2:]
+			inComment = false
+		}
+		if start := strings.Index(text, "/*"); start != -1 {
+			if !strings.Contains(text[start:], "*/") {
+				inComment = true
+			}
+			text = text[:start]
+		}
+		if start := strings.Index(text, "//"); start != -1 {
+			text = text[:start]
+		}
+
+		if pending != "" {
+			var part string
+			part, depth = signatureParameters(text, depth)
+			pending += " " + part
+			if depth == 0 {
+				symbols[len(symbols)-1].Signature = normaliseSignature(pending)
+				pending = ""
+			}
+			continue
+		}
+
+		var kind string
+		var groups []string
+		if groups = matchStock.FindStringSubmatch(text); len(groups) == 2 {
+			kind = "stock"
+		} else if groups = matchNative.FindStringSubmatch(text); len(groups) == 2 {
+			kind = "native"
+		} else if groups = matchForward.FindStringSubmatch(text); len(groups) == 2 {
+			kind = "forward"
+		} else if groups = matchDefine.FindStringSubmatch(text); len(groups) == 2 {
+			kind = "define"
+			if groups[1] == guarded {
+				kind = ""
+			}
+		}
+
+		guarded = ""
+		if g := matchDefinedGuard.FindStringSubmatch(text); len(g) == 2 {
+			guarded = g[1]
+		}
+
+		if kind == "" {
+			continue
+		}
+
+		signature := matchDeclarationKeywords.ReplaceAllString(text, "")
+		if kind == "define" {
+			// only the parameters of a macro are part of its signature, not its value
+			if strings.HasPrefix(signature[len(groups[1]):], "(") {
+				signature, _ = signatureParameters(signature, 0)
+			} else {
+				signature = groups[1]
+			}
+		} else {
+			signature, depth = signatureParameters(signature, 0)
+			if depth > 0 {
+				pending = signature
+			}
+		}
+
+		symbols = append(symbols, Symbol{
+			Name:      groups[1],
+			Kind:      kind,
+			Signature: normaliseSignature(signature),
+			Static:    kind == "stock" && matchStaticStock.MatchString(text),
+			File:      file,
+			Line:      line,
+			Owner:     owner,
+		})
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// signatureParameters returns the part of a declaration up to the parenthesis that closes its
+// parameters and how many parentheses are still open if they aren't closed on the line
+func signatureParameters(text string, depth int) (string, int) {
+	started := depth > 0
+	for i, c := range text {
+		switch c {
+		case '(':
+			depth++
+			started = true
+		case ')':
+			depth--
+			if started && depth == 0 {
+				return text[:i+1], 0
+			}
+		}
+	}
+	return text, depth
+}
+
+// normaliseSignature removes the whitespace in a signature that doesn't separate two words, so
+// the same declaration formatted differently has the same signature
+func normaliseSignature(signature string) string {
+	words := strings.Fields(signature)
+	var result bytes.Buffer
+	for i, word := range words {
+		if i > 0 && isWordByte(words[i-1][len(words[i-1])-1]) && isWordByte(word[0]) {
+			result.WriteByte(' ')
+		}
+		result.WriteString(word)
+	}
+	return result.String()
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '@' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// Exports scans the include files a package exports for the `native`, `stock`, `forward` and
+// `#define` declarations that other packages can use, for generating an API reference or comparing
+// the API of two versions. Static stocks are skipped since they're private to their file, as are
+// names that start with an underscore, which are used for include guards and internal helpers by
+// convention. The file of each symbol is relative to the package directory.
+func Exports(pkg types.Package) (symbols []Symbol, err error) {
+	includes, err := exportedIncludes(pkg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list exported includes")
+	}
+
+	for _, include := range includes {
+		file := filepath.Join(pkg.IncludePath, filepath.FromSlash(include))
+		var found []Symbol
+		found, err = scanFile(filepath.Join(pkg.LocalPath, file), file, pkg.DependencyMeta)
+		if err != nil {
+			return
+		}
+		symbols = append(symbols, publicSymbols(found)...)
+	}
+	return
+}
+
+// publicSymbols skips the static stocks and underscore prefixed names in a list of symbols
+func publicSymbols(found []Symbol) (symbols []Symbol) {
+	for _, symbol := range found {
+		if symbol.Static || strings.HasPrefix(symbol.Name, "_") {
+			continue
+		}
+		symbols = append(symbols, symbol)
+	}
+	return
+}
+
+func scanFile(path, file string, owner versioning.DependencyMeta) (symbols []Symbol, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close() // nolint
+
+	symbols, err = scanSymbols(f, file, owner)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to scan %s", path)
+	}
+	return
+}
+
+func warnCollisions(sources []IncludeSource) {
+	collisions, err := DetectCollisions(sources)
+	if err != nil {
+		print.Warn("Failed to check dependencies for colliding declarations:", err)
+		return
+	}
+
+	for _, collision := range collisions {
+		print.Warn("Declaration", collision.Name, "collides across multiple packages:")
+		for _, symbol := range collision.Symbols {
+			print.Warn("  ", symbol)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package rook
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestDetectCollisions(t *testing.T) {
+	players := versioning.DependencyMeta{User: "user", Repo: "players"}
+	utils := versioning.DependencyMeta{User: "user", Repo: "utils"}
+
+	collisions, err := DetectCollisions([]IncludeSource{
+		{Dir: "./tests/collisions/players", Owner: players},
+		{Dir: "./tests/collisions/utils", Owner: utils},
+		{Dir: "./tests/collisions/missing", Owner: utils},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []Collision{
+		{Name: "IsValidPlayer", Symbols: []Symbol{
+			{Name: "IsValidPlayer", Kind: "stock", Signature: "IsValidPlayer(playerid)", File: "tests/collisions/players/players.inc", Line: 14, Owner: players},
+			{Name: "IsValidPlayer", Kind: "stock", Signature: "bool:IsValidPlayer(playerid)", File: "tests/collisions/utils/utils.inc", Line: 16, Owner: utils},
+		}},
+		{Name: "OnThingCreated", Symbols: []Symbol{
+			{Name: "OnThingCreated", Kind: "forward", Signature: "OnThingCreated(thing)", File: "tests/collisions/players/players.inc", Line: 20, Owner: players},
+			{Name: "OnThingCreated", Kind: "forward", Signature: "OnThingCreated(Thing:thing)", File: "tests/collisions/utils/utils.inc", Line: 24, Owner: utils},
+		}},
+		{Name: "PLAYER_COLOUR", Symbols: []Symbol{
+			{Name: "PLAYER_COLOUR", Kind: "define", Signature: "PLAYER_COLOUR", File: "tests/collisions/players/players.inc", Line: 10, Owner: players},
+			{Name: "PLAYER_COLOUR", Kind: "define", Signature: "PLAYER_COLOUR", File: "tests/collisions/utils/utils.inc", Line: 20, Owner: utils},
+		}},
+	}, collisions)
+}
+
+func Test_scanSymbols(t *testing.T) {
+	owner := versioning.DependencyMeta{User: "user", Repo: "long"}
+
+	// lines longer than the default limit of the scanner, such as generated tables, are scanned
+	long := "new const gTable[] = {" + strings.Repeat("0, ", 40*1024) + "0};\n"
+	symbols, err := scanSymbols(strings.NewReader(long+"stock Thing() {}\n"), "long.inc", owner)
+	assert.NoError(t, err)
+	assert.Equal(t, []Symbol{
+		{Name: "Thing", Kind: "stock", Signature: "Thing()", File: "long.inc", Line: 2, Owner: owner},
+	}, symbols)
+
+	// but lines longer than the raised limit fail instead of stopping the scan early
+	_, err = scanSymbols(strings.NewReader(strings.Repeat("x", 2*1024*1024)+"\nstock Thing() {}\n"), "huge.inc", owner)
+	assert.Error(t, err)
+}
+
+func TestExports(t *testing.T) {
+	meta := versioning.DependencyMeta{User: "user", Repo: "things"}
+	pkg := types.Package{DependencyMeta: meta, LocalPath: "./tests/exports", IncludePath: "include"}
+
+	symbols, err := Exports(pkg)
+	assert.NoError(t, err)
+	assert.Equal(t, []Symbol{
+		{Name: "DestroyThing", Kind: "stock", Signature: "DestroyThing(Thing:thing)", File: "include/internal/impl.inc", Line: 1, Owner: meta},
+		{Name: "MAX_THINGS", Kind: "define", Signature: "MAX_THINGS", File: "include/things.inc", Line: 6, Owner: meta},
+		{Name: "CreateThing", Kind: "native", Signature: "Thing:CreateThing(Float:x,Float:y)", File: "include/things.inc", Line: 12, Owner: meta},
+		{Name: "OnThingCreated", Kind: "forward", Signature: "OnThingCreated(Thing:thing)", File: "include/things.inc", Line: 13, Owner: meta},
+		{Name: "GetThingDistance", Kind: "stock", Signature: "Float:GetThingDistance(Thing:a,Thing:b)", File: "include/things.inc", Line: 15, Owner: meta},
+		{Name: "SetThingPos", Kind: "native", Signature: "SetThingPos(Thing:thing,Float:x,Float:y)", File: "include/things.inc", Line: 25, Owner: meta},
+		{Name: "IsValidThing", Kind: "define", Signature: "IsValidThing(%0)", File: "include/things.inc", Line: 30, Owner: meta},
+	}, symbols)
+
+	pkg.Exports = []string{"things.inc"}
+	symbols, err = Exports(pkg)
+	assert.NoError(t, err)
+	assert.Len(t, symbols, 6)
+}
@@ -0,0 +1,259 @@
+package rook
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	"github.com/Southclaws/sampctl/runtime"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// APIChangeKind describes how an exported symbol changed between two versions of a package
+type APIChangeKind string
+
+const (
+	// APIRemoved is a symbol that is no longer exported, which includes a renamed symbol
+	APIRemoved APIChangeKind = "removed"
+	// APIChanged is a symbol whose kind or signature changed
+	APIChanged APIChangeKind = "changed"
+	// APIAdded is a symbol that wasn't exported before
+	APIAdded APIChangeKind = "added"
+)
+
+// APIChange is an exported symbol that differs between two versions of a package
+type APIChange struct {
+	Name   string
+	Kind   APIChangeKind
+	Before *Symbol // nil for an added symbol
+	After  *Symbol // nil for a removed symbol
+}
+
+// Breaking is true for changes that can stop code using the previous version from compiling
+func (ac APIChange) Breaking() bool {
+	return ac.Kind != APIAdded
+}
+
+func (ac APIChange) String() string {
+	switch ac.Kind {
+	case APIRemoved:
+		return fmt.Sprintf("removed %s %s (%s:%d)", ac.Before.Kind, ac.Before.Signature, ac.Before.File, ac.Before.Line)
+	case APIChanged:
+		return fmt.Sprintf("changed %s %s to %s %s (%s:%d)", ac.Before.Kind, ac.Before.Signature, ac.After.Kind, ac.After.Signature, ac.After.File, ac.After.Line)
+	default:
+		return fmt.Sprintf("added %s %s (%s:%d)", ac.After.Kind, ac.After.Signature, ac.After.File, ac.After.Line)
+	}
+}
+
+// APIComparison is the difference between the exported symbols of two versions of a package
+type APIComparison struct {
+	From    string // the revision compared from
+	To      string // the revision compared to, empty for the working tree
+	Changes []APIChange
+}
+
+// Breaking returns the changes that can stop code using the previous version from compiling
+func (ac APIComparison) Breaking() (changes []APIChange) {
+	for _, change := range ac.Changes {
+		if change.Breaking() {
+			changes = append(changes, change)
+		}
+	}
+	return
+}
+
+// Bump returns the part of the version that a release with these changes should increment:
+// `major` for breaking changes, `minor` for added symbols and `patch` otherwise
+func (ac APIComparison) Bump() string {
+	bump := "patch"
+	for _, change := range ac.Changes {
+		if change.Breaking() {
+			return "major"
+		}
+		bump = "minor"
+	}
+	return bump
+}
+
+// CompareExports compares two lists of exported symbols by name and returns the symbols that were
+// removed, those whose kind or signature changed and those that were added, sorted by name. A
+// renamed symbol is reported as removed under its old name and added under its new name.
+func CompareExports(before, after []Symbol) (changes []APIChange) {
+	byName := func(symbols []Symbol) map[string]*Symbol {
+		result := make(map[string]*Symbol)
+		for i := range symbols {
+			if _, ok := result[symbols[i].Name]; !ok {
+				result[symbols[i].Name] = &symbols[i]
+			}
+		}
+		return result
+	}
+	old := byName(before)
+	current := byName(after)
+
+	for name, symbol := range old {
+		next, ok := current[name]
+		if !ok {
+			changes = append(changes, APIChange{Name: name, Kind: APIRemoved, Before: symbol})
+		} else if next.Kind != symbol.Kind || next.Signature != symbol.Signature {
+			changes = append(changes, APIChange{Name: name, Kind: APIChanged, Before: symbol, After: next})
+		}
+	}
+	for name, symbol := range current {
+		if _, ok := old[name]; !ok {
+			changes = append(changes, APIChange{Name: name, Kind: APIAdded, After: symbol})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return
+}
+
+// CompareAPI compares the symbols the package exports at one revision with those it exports at
+// another. If from is empty, the newest version tag is compared from and if to is empty, the
+// working tree is compared to. The include path and exports of the current package definition are
+// used for both revisions.
+func CompareAPI(pkg types.Package, from, to string) (comparison APIComparison, err error) {
+	repo, err := git.PlainOpen(pkg.LocalPath)
+	if err != nil {
+		return comparison, errors.Wrap(err, "failed to read package as git repository")
+	}
+
+	if from == "" {
+		var tags versioning.VersionedTags
+		tags, err = versioning.GetRepoSemverTags(repo)
+		if err != nil {
+			return comparison, errors.Wrap(err, "failed to get semver tags")
+		}
+		if len(tags) == 0 {
+			return comparison, errors.New("package has no version tags to compare with")
+		}
+		sort.Sort(sort.Reverse(tags))
+		from = tags[0].Name
+	}
+	comparison.From = from
+	comparison.To = to
+
+	before, err := ExportsAt(pkg, repo, from)
+	if err != nil {
+		return
+	}
+	var after []Symbol
+	if to == "" {
+		after, err = Exports(pkg)
+	} else {
+		after, err = ExportsAt(pkg, repo, to)
+	}
+	if err != nil {
+		return
+	}
+
+	comparison.Changes = CompareExports(before, after)
+	return
+}
+
+// ExportsAt returns the symbols a package exports at a revision of its repository, which may be a
+// tag, branch or commit hash, in the same way as `Exports` does for the working tree
+func ExportsAt(pkg types.Package, repo *git.Repository, revision string) (symbols []Symbol, err error) {
+	commit, err := revisionCommit(repo, revision)
+	if err != nil {
+		return
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read tree of %s", revision)
+	}
+
+	exports := pkg.Exports
+	if len(exports) == 0 {
+		exports = []string{"**/*.inc"}
+	}
+	var matchers []*regexp.Regexp
+	for _, glob := range exports {
+		matchers = append(matchers, runtime.GlobRegexp(glob))
+	}
+	dir := path.Clean(filepath.ToSlash(pkg.IncludePath))
+
+	err = tree.Files().ForEach(func(file *object.File) error {
+		rel := file.Name
+		if dir != "." {
+			if !strings.HasPrefix(rel, dir+"/") {
+				return nil
+			}
+			rel = strings.TrimPrefix(rel, dir+"/")
+		}
+		if strings.HasPrefix(rel, "dependencies/") {
+			return nil
+		}
+		for _, part := range strings.Split(path.Dir(rel), "/") {
+			if strings.HasPrefix(part, ".") && part != "." {
+				return nil
+			}
+		}
+
+		matched := false
+		for _, matcher := range matchers {
+			if matcher.MatchString(rel) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil
+		}
+
+		r, errInner := file.Reader()
+		if errInner != nil {
+			return errInner
+		}
+		defer r.Close() // nolint
+
+		found, errInner := scanSymbols(r, filepath.FromSlash(file.Name), pkg.DependencyMeta)
+		if errInner != nil {
+			return errors.Wrapf(errInner, "failed to scan %s", file.Name)
+		}
+		symbols = append(symbols, publicSymbols(found)...)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to scan exported includes at %s", revision)
+	}
+	return
+}
+
+// revisionCommit returns the commit of a tag, branch or commit hash, annotated tags are followed
+// to the commit they tag
+func revisionCommit(repo *git.Repository, revision string) (commit *object.Commit, err error) {
+	for _, name := range []string{"refs/tags/", "refs/heads/", "refs/remotes/origin/"} {
+		ref, errRef := repo.Reference(plumbing.ReferenceName(name+revision), true)
+		if errRef != nil {
+			continue
+		}
+		if tag, errTag := repo.TagObject(ref.Hash()); errTag == nil {
+			return tag.Commit()
+		}
+		return repo.CommitObject(ref.Hash())
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil || hash.IsZero() {
+		hash = new(plumbing.Hash)
+		*hash = plumbing.NewHash(revision)
+	}
+	commit, err = repo.CommitObject(*hash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find revision %s", revision)
+	}
+	return
+}
@@ -0,0 +1,88 @@
+package rook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestCompareAPI(t *testing.T) {
+	dir := util.FullPath("./tests/compat")
+	os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+
+	repo, err := git.PlainInit(dir, false)
+	assert.NoError(t, err)
+	wt, err := repo.Worktree()
+	assert.NoError(t, err)
+
+	write := func(contents string) {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "things.inc"), []byte(contents), 0644))
+	}
+	commit := func(message string) {
+		_, errAdd := wt.Add("things.inc")
+		assert.NoError(t, errAdd)
+		signature := &object.Signature{Name: "test", Email: "test@test", When: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)}
+		_, errCommit := wt.Commit(message, &git.CommitOptions{Author: signature, Committer: signature})
+		assert.NoError(t, errCommit)
+	}
+
+	write(`#define OLD_THING (1)
+native GetThing(playerid);
+stock IsThing(thing) {}
+`)
+	commit("1.0.0")
+	head, err := repo.Head()
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference("refs/tags/1.0.0", head.Hash())))
+
+	write(`#define OLD_THING (1)
+native GetThing(playerid);
+stock IsThing(thing) {}
+stock CountThings() {}
+`)
+	commit("add CountThings")
+
+	write(`#define NEW_THING (1)
+native GetThing(playerid, &thing);
+stock IsThing(thing) {}
+stock CountThings() {}
+`)
+
+	pkg := types.Package{LocalPath: dir}
+
+	comparison, err := CompareAPI(pkg, "1.0.0", "HEAD")
+	assert.NoError(t, err)
+	assert.Len(t, comparison.Changes, 1)
+	assert.Equal(t, "added stock CountThings() (things.inc:4)", comparison.Changes[0].String())
+	assert.Equal(t, "minor", comparison.Bump())
+
+	comparison, err = CompareAPI(pkg, "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0", comparison.From)
+	var changes []string
+	for _, change := range comparison.Changes {
+		changes = append(changes, change.String())
+	}
+	assert.Equal(t, []string{
+		"added stock CountThings() (things.inc:4)",
+		"changed native GetThing(playerid) to native GetThing(playerid,&thing) (things.inc:2)",
+		"added define NEW_THING (things.inc:1)",
+		"removed define OLD_THING (things.inc:1)",
+	}, changes)
+	assert.Len(t, comparison.Breaking(), 2)
+	assert.Equal(t, "major", comparison.Bump())
+
+	_, err = CompareAPI(pkg, "2.0.0", "")
+	assert.Error(t, err)
+}
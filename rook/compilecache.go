@@ -0,0 +1,166 @@
+package rook
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/compiler"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+)
+
+// compileRecord is the outcome of a successful compile, stored in the cache so a later build or
+// watch of the same inputs can reuse its output
+type compileRecord struct {
+	Key      string              `json:"key"`
+	SHA256   string              `json:"sha256"` // checksum of the output, it's compiled again if it changed
+	Problems types.BuildProblems `json:"problems"`
+	Result   types.BuildResult   `json:"result"`
+}
+
+// compileSources are the extensions of the files the compiler reads
+var compileSources = map[string]bool{".pwn": true, ".inc": true, ".p": true, ".pawn": true}
+
+// compileKey hashes everything that decides what a compile produces: the compiler, its options and
+// the contents of every source file in the directories it reads from. Both `Build` and
+// `BuildWatch` use it so their cache entries are interchangeable. The config must be prepared.
+func (pcx *PackageContext) compileKey(config types.BuildConfig) (key string, err error) {
+	options := struct {
+		Platform      string
+		Version       types.CompilerVersion
+		Compiler      string
+		CompilerPath  string
+		Input         string
+		Output        string
+		WorkingDir    string
+		Args          []string
+		Includes      []string
+		ForceIncludes []string
+		Constants     map[string]string
+		Coverage      bool
+		Debug         *int
+		Optimization  *int
+		Compress      *bool
+	}{
+		pcx.Platform, config.Version, string(config.Compiler), config.CompilerPath,
+		config.Input, config.Output, config.WorkingDir, config.Args, config.Includes,
+		config.ForceIncludes, config.Constants, config.CoverageMarkers, config.Debug, config.Optimization, config.Compress,
+	}
+	contents, err := json.Marshal(options)
+	if err != nil {
+		return
+	}
+
+	files := make(map[string]bool)
+	dirs := append([]string{filepath.Dir(config.Input), config.WorkingDir}, config.Includes...)
+	for _, dir := range dirs {
+		err = filepath.Walk(dir, func(path string, info os.FileInfo, errWalk error) error {
+			if errWalk != nil {
+				if path == dir && os.IsNotExist(errWalk) {
+					return nil
+				}
+				return errWalk
+			}
+			if info.IsDir() {
+				if path != dir && strings.HasPrefix(info.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if compileSources[filepath.Ext(path)] {
+				files[path] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to list sources in %s", dir)
+		}
+	}
+	for _, include := range config.ForceIncludes {
+		if file := compiler.ForceIncludeFile(pcx.Package.LocalPath, include); file != "" {
+			files[file] = true
+		}
+	}
+	sorted := make([]string, 0, len(files))
+	for file := range files {
+		sorted = append(sorted, file)
+	}
+	sort.Strings(sorted)
+
+	hash := sha256.New()
+	hash.Write(contents) // nolint
+	for _, file := range sorted {
+		contents, err = ioutil.ReadFile(file)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to read %s", file)
+		}
+		fmt.Fprintln(hash, filepath.ToSlash(file), len(contents))
+		hash.Write(contents) // nolint
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// compileRecordFile is where the compile record of an output is kept in the cache, records are
+// per output so that builds writing the same file don't reuse each other's output
+func (pcx *PackageContext) compileRecordFile(config types.BuildConfig) string {
+	return filepath.Join(pcx.CacheDir, "compile", stampName(pcx.Package.LocalPath, config.Output)+".json")
+}
+
+// cachedCompile returns the key of a compile and, if the output of a compile with the same key is
+// still in place, the problems and result of that compile. A failure to read the cache only means
+// the compile isn't skipped.
+func (pcx *PackageContext) cachedCompile(config types.BuildConfig) (key string, record compileRecord, hit bool) {
+	key, err := pcx.compileKey(config)
+	if err != nil {
+		print.Warn("Failed to check compile cache, compiling:", err)
+		return "", record, false
+	}
+	if pcx.Rebuild {
+		return key, record, false
+	}
+
+	contents, err := ioutil.ReadFile(pcx.compileRecordFile(config))
+	if err != nil || json.Unmarshal(contents, &record) != nil || record.Key != key {
+		return key, record, false
+	}
+	artifact, err := pcx.reportArtifact(config.Output)
+	if err != nil || artifact.SHA256 != record.SHA256 {
+		return key, record, false
+	}
+	print.Verb(pcx.Package, "output", config.Output, "is up to date with its sources")
+	return key, record, true
+}
+
+// recordCompile stores the outcome of a successful compile in the cache
+func (pcx *PackageContext) recordCompile(key string, config types.BuildConfig, problems types.BuildProblems, result types.BuildResult) {
+	if key == "" || !problems.IsValid() || problems.Fatal() {
+		return
+	}
+	err := func() error {
+		artifact, err := pcx.reportArtifact(config.Output)
+		if err != nil {
+			return err
+		}
+		contents, err := json.Marshal(compileRecord{Key: key, SHA256: artifact.SHA256, Problems: problems, Result: result})
+		if err != nil {
+			return err
+		}
+		filename := pcx.compileRecordFile(config)
+		if err = os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filename, contents, 0600)
+	}()
+	if err != nil {
+		print.Warn("Failed to record compile in the cache, it will compile again next time:", err)
+	}
+}
@@ -0,0 +1,85 @@
+package rook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_cachedCompile(t *testing.T) {
+	dir := util.FullPath("./tests/compilecache")
+	os.RemoveAll(dir)
+	pkgDir := filepath.Join(dir, "package")
+	includeDir := filepath.Join(pkgDir, "dependencies", "lib")
+	assert.NoError(t, os.MkdirAll(includeDir, 0700))
+	write := func(filename, contents string) {
+		assert.NoError(t, ioutil.WriteFile(filename, []byte(contents), 0600))
+	}
+	write(filepath.Join(pkgDir, "test.pwn"), "#include <lib>\nmain() {}\n")
+	write(filepath.Join(includeDir, "lib.inc"), "stock Lib() {}\n")
+
+	pcx := PackageContext{
+		Package: types.Package{
+			LocalPath:      pkgDir,
+			DependencyMeta: versioning.DependencyMeta{User: "user", Repo: "repo"},
+		},
+		Platform: "linux",
+		CacheDir: filepath.Join(dir, "cache"),
+	}
+	config := types.BuildConfig{
+		Version:    "3.10.10",
+		Input:      filepath.Join(pkgDir, "test.pwn"),
+		Output:     filepath.Join(pkgDir, "test.amx"),
+		WorkingDir: pkgDir,
+		Args:       []string{"-d3"},
+		Includes:   []string{includeDir},
+	}
+
+	key, _, hit := pcx.cachedCompile(config)
+	assert.NotEmpty(t, key)
+	assert.False(t, hit)
+
+	// only a successful compile is recorded
+	write(config.Output, "amx")
+	pcx.recordCompile(key, config, types.BuildProblems{{Severity: types.ProblemError}}, types.BuildResult{})
+	_, _, hit = pcx.cachedCompile(config)
+	assert.False(t, hit)
+
+	problems := types.BuildProblems{{File: "test.pwn", Line: 2, Severity: types.ProblemWarning, Description: "unused"}}
+	pcx.recordCompile(key, config, problems, types.BuildResult{Total: 15})
+	again, record, hit := pcx.cachedCompile(config)
+	assert.True(t, hit)
+	assert.Equal(t, key, again)
+	assert.Equal(t, problems, record.Problems)
+	assert.Equal(t, 15, record.Result.Total)
+
+	pcx.Rebuild = true
+	_, _, hit = pcx.cachedCompile(config)
+	assert.False(t, hit)
+	pcx.Rebuild = false
+
+	// changing an include, the options or the output means it's compiled again
+	write(filepath.Join(includeDir, "lib.inc"), "stock Lib() { return 1; }\n")
+	changed, _, hit := pcx.cachedCompile(config)
+	assert.False(t, hit)
+	assert.NotEqual(t, key, changed)
+	write(filepath.Join(includeDir, "lib.inc"), "stock Lib() {}\n")
+	_, _, hit = pcx.cachedCompile(config)
+	assert.True(t, hit)
+
+	config.Args = []string{"-d0"}
+	_, _, hit = pcx.cachedCompile(config)
+	assert.False(t, hit)
+	config.Args = []string{"-d3"}
+
+	write(config.Output, "other")
+	_, _, hit = pcx.cachedCompile(config)
+	assert.False(t, hit)
+}
@@ -0,0 +1,136 @@
+package rook
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/runtime"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// ensureCompiler installs the compiler a build config declares as a dependency, if it declares one,
+// and points the config at it. The checksum of the compiler binary is pinned in the lockfile the
+// first time it is installed on a platform and checked on every build after that, in frozen mode a
+// compiler that isn't pinned yet is an error.
+func (pcx *PackageContext) ensureCompiler(ctx context.Context, config *types.BuildConfig) (err error) {
+	if config.Compiler == "" {
+		return
+	}
+
+	binary, checksum, err := pcx.installCompiler(ctx, config.Compiler)
+	if err != nil {
+		return
+	}
+
+	lock, err := types.ReadLockfile(pcx.Package.LocalPath)
+	if err != nil {
+		return
+	}
+	if lock == nil {
+		lock = &types.Lockfile{Dependencies: []types.LockedDependency{}}
+	}
+
+	expected, ok := lock.CompilerChecksum(config.Compiler, pcx.Platform)
+	switch {
+	case ok && !strings.EqualFold(expected, checksum):
+		return errors.Errorf("compiler %s for %s does not match %s: expected %s, got %s, run verify with --repair if the installed compiler was damaged",
+			config.Compiler, pcx.Platform, types.LockfileName, expected, checksum)
+	case !ok && pcx.Frozen:
+		return errors.Errorf("frozen build would add compiler %s for %s to %s", config.Compiler, pcx.Platform, types.LockfileName)
+	case !ok:
+		print.Verb(pcx.Package, "recording checksum of compiler", config.Compiler, "in", types.LockfileName)
+		lock.SetCompilerChecksum(config.Compiler, pcx.Platform, checksum)
+		err = lock.Write(pcx.Package.LocalPath)
+		if err != nil {
+			return
+		}
+	}
+
+	config.CompilerPath = binary
+	return
+}
+
+// installCompiler installs the compiler a dependency provides and returns the binary and its checksum
+func (pcx *PackageContext) installCompiler(ctx context.Context, dependency versioning.DependencyString) (binary, checksum string, err error) {
+	meta, err := pcx.explode(dependency)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "invalid compiler dependency %s", dependency)
+	}
+	binary, err = runtime.EnsureCompilerResource(ctx, pcx.GitHub, meta, pcx.Platform, pcx.CacheDir)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to install compiler %s", dependency)
+	}
+	checksum, err = runtime.PluginChecksum(binary)
+	return
+}
+
+// verifyCompilers checks the compilers that build configs install from dependencies against the
+// checksums pinned in the lockfile for the platform. If `repair` is set, a compiler that doesn't
+// match is removed and installed again.
+func (pcx *PackageContext) verifyCompilers(ctx context.Context, lock types.Lockfile, repair bool) (checks []VendorCheck, err error) {
+	builds := pcx.Package.Builds
+	if pcx.Package.Build != nil {
+		builds = append([]*types.BuildConfig{pcx.Package.Build}, builds...)
+	}
+
+	seen := make(map[versioning.DependencyString]bool)
+	for _, build := range builds {
+		if !build.MatchesPlatform(pcx.Platform) {
+			continue
+		}
+		config := build.ForPlatform(pcx.Platform)
+		if config.Compiler == "" || seen[config.Compiler] {
+			continue
+		}
+		seen[config.Compiler] = true
+
+		expected, ok := lock.CompilerChecksum(config.Compiler, pcx.Platform)
+		if !ok {
+			print.Verb("compiler", config.Compiler, "is not pinned for", pcx.Platform)
+			continue
+		}
+
+		check, binary := pcx.verifyCompiler(ctx, config.Compiler, expected)
+		if check.OK() {
+			print.Verb("compiler", config.Compiler, "matches locked checksum", expected)
+			continue
+		}
+		if repair && binary != "" {
+			print.Info(check.Dependency, "removing damaged compiler and installing it again")
+			err = os.RemoveAll(filepath.Dir(binary))
+			if err != nil {
+				return checks, errors.Wrap(err, "failed to remove damaged compiler")
+			}
+			if after, _ := pcx.verifyCompiler(ctx, config.Compiler, expected); !after.OK() {
+				return checks, errors.Errorf("compiler still does not match the locked checksum: %s", after)
+			}
+			check.Repaired = true
+		}
+		checks = append(checks, check)
+	}
+	return
+}
+
+// verifyCompiler installs a compiler if necessary and compares its binary with the locked checksum,
+// the binary is empty if the compiler couldn't be installed
+func (pcx *PackageContext) verifyCompiler(ctx context.Context, dependency versioning.DependencyString, expected string) (check VendorCheck, binary string) {
+	meta, _ := pcx.explode(dependency)
+	check.Dependency = meta
+
+	binary, checksum, err := pcx.installCompiler(ctx, dependency)
+	if err != nil {
+		check.Problem = err.Error()
+		return
+	}
+	if !strings.EqualFold(checksum, expected) {
+		check.Problem = fmt.Sprintf("compiler binary %s does not match locked checksum %s", checksum, expected)
+	}
+	return
+}
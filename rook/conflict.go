@@ -0,0 +1,281 @@
+package rook
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+	"gopkg.in/AlecAivazis/survey.v1"
+	"gopkg.in/src-d/go-git.v4"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// ConstraintRequest is a version constraint on a dependency and the package in the dependency tree
+// that declared it, the requester is empty for constraints the package itself declares
+type ConstraintRequest struct {
+	Requester  versioning.DependencyMeta
+	Constraint string
+}
+
+func (cr ConstraintRequest) String() string {
+	if cr.Requester.Repo == "" {
+		return fmt.Sprintf("the package requires %s", cr.Constraint)
+	}
+	return fmt.Sprintf("%s requires %s", cr.Requester, cr.Constraint)
+}
+
+// ConstraintConflict is a dependency with version constraints that can't all be satisfied, either
+// because no version satisfies every one of them or because the version the resolution strategy
+// picked doesn't
+type ConstraintConflict struct {
+	Dependency versioning.DependencyMeta
+	Requests   []ConstraintRequest
+	Versions   []string // the versions available from the version source of the dependency
+	Resolved   string   // the version the strategy resolved to anyway, empty if it couldn't resolve one
+}
+
+func (cc ConstraintConflict) Error() string {
+	lines := []string{fmt.Sprintf("the version constraints on %s/%s conflict:", cc.Dependency.User, cc.Dependency.Repo)}
+	for _, request := range cc.Requests {
+		lines = append(lines, "  "+request.String())
+	}
+	if len(cc.Versions) > 0 {
+		lines = append(lines, "  available versions are "+strings.Join(cc.Versions, ", "))
+	}
+	if cc.Resolved != "" {
+		lines = append(lines, fmt.Sprintf("  it resolved to %s which doesn't satisfy all of them", cc.Resolved))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ConflictAction describes how a constraint conflict is settled
+type ConflictAction string
+
+const (
+	// ConflictPickVersion overrides every constraint on the dependency with a single version
+	ConflictPickVersion ConflictAction = "version"
+	// ConflictOverride overrides every constraint on the dependency with a constraint
+	ConflictOverride ConflictAction = "override"
+	// ConflictDowngrade changes the constraint the package declares on one of the requesters, such as
+	// to an older version of it with a compatible constraint, or on the dependency itself
+	ConflictDowngrade ConflictAction = "downgrade"
+)
+
+// ConflictActions lists the valid conflict actions
+var ConflictActions = []ConflictAction{ConflictPickVersion, ConflictOverride, ConflictDowngrade}
+
+// ConflictResolution is a decision on how to settle a constraint conflict
+type ConflictResolution struct {
+	Action     ConflictAction
+	Constraint string                    // the version or constraint to use
+	Requester  versioning.DependencyMeta // whose constraint to change when downgrading, empty for the dependency itself
+}
+
+// addRequest records which package in the dependency tree declared a tag constraint on a dependency
+func (pcx *PackageContext) addRequest(requester, meta versioning.DependencyMeta) {
+	if meta.Tag == "" {
+		return
+	}
+	if pcx.requests == nil {
+		pcx.requests = make(map[string][]ConstraintRequest)
+	}
+	key := constraintKey(meta)
+	pcx.requests[key] = append(pcx.requests[key], ConstraintRequest{Requester: requester, Constraint: meta.Tag})
+}
+
+// overrideOf returns the constraint the package overrides every constraint on a dependency with
+func (pcx *PackageContext) overrideOf(meta versioning.DependencyMeta) string {
+	for dependency, override := range pcx.Package.Overrides {
+		if strings.EqualFold(dependency, meta.User+"/"+meta.Repo) {
+			return override
+		}
+	}
+	return ""
+}
+
+// constraintConflict describes a conflict between the constraints on a dependency, the requests are
+// the ones recorded while walking the dependency tree or, failing that, just the constraints
+func (pcx *PackageContext) constraintConflict(ctx context.Context, repo *git.Repository, meta versioning.DependencyMeta, source VersionSource, constraints []string) (conflict ConstraintConflict) {
+	conflict.Dependency = meta
+	for _, request := range pcx.requests[constraintKey(meta)] {
+		for _, constraint := range constraints {
+			if request.Constraint == constraint {
+				conflict.Requests = append(conflict.Requests, request)
+				break
+			}
+		}
+	}
+	if len(conflict.Requests) == 0 {
+		for _, constraint := range constraints {
+			conflict.Requests = append(conflict.Requests, ConstraintRequest{Constraint: constraint})
+		}
+	}
+
+	var tags versioning.VersionedTags
+	if source == VersionSourceReleases {
+		if releases, err := pcx.githubReleases(ctx, meta); err == nil {
+			tags = releaseVersions(repo, releases)
+		}
+	} else {
+		tags, _ = versioning.GetRepoSemverTags(repo)
+	}
+	sort.Sort(tags)
+	for _, tag := range tags {
+		conflict.Versions = append(conflict.Versions, tag.Name)
+	}
+	return
+}
+
+// settleConflict asks how to settle a constraint conflict and applies the decision to the package,
+// which is written to its definition file unless it was created in memory. Overrides take effect immediately so the dependency can be resolved again, a changed
+// constraint on a requester only takes effect once the dependency tree is walked again.
+func (pcx *PackageContext) settleConflict(conflict ConstraintConflict) (retry bool, err error) {
+	resolution, err := pcx.ResolveConflict(conflict)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to settle constraint conflict")
+	}
+
+	dependency := conflict.Dependency.User + "/" + conflict.Dependency.Repo
+	switch resolution.Action {
+	case ConflictPickVersion, ConflictOverride:
+		if _, err = semver.NewConstraint(resolution.Constraint); err != nil {
+			return false, errors.Wrapf(err, "'%s' is not a version constraint", resolution.Constraint)
+		}
+		pcx.Package.Overrides = withOverride(pcx.Package.Overrides, dependency, resolution.Constraint)
+		print.Info(pcx.Package, "overriding every constraint on", dependency, "with", resolution.Constraint)
+		retry = true
+	case ConflictDowngrade:
+		target := resolution.Requester
+		if target.Repo == "" {
+			target = conflict.Dependency
+		}
+		err = changeConstraint(&pcx.Package, target, resolution.Constraint)
+		if err != nil {
+			return
+		}
+		print.Info(pcx.Package, "changed the constraint on", target.User+"/"+target.Repo, "to", resolution.Constraint, "ensure again to resolve", dependency, "with it")
+	default:
+		return false, errors.Errorf("unknown conflict action %s, must be one of %v", resolution.Action, ConflictActions)
+	}
+
+	// a package that was created in memory has no definition file to keep the change in
+	if pcx.Package.Format == "" {
+		return
+	}
+	err = pcx.Package.WriteDefinition()
+	return
+}
+
+// withOverride sets the override for a dependency, replacing any that differs only by case
+func withOverride(overrides map[string]string, dependency, constraint string) map[string]string {
+	if overrides == nil {
+		overrides = make(map[string]string)
+	}
+	for existing := range overrides {
+		if strings.EqualFold(existing, dependency) {
+			delete(overrides, existing)
+		}
+	}
+	overrides[dependency] = constraint
+	return overrides
+}
+
+// changeConstraint rewrites the version constraint of one of the dependencies the package declares
+func changeConstraint(pkg *types.Package, target versioning.DependencyMeta, constraint string) (err error) {
+	change := func(deps []versioning.DependencyString) (found bool, errInner error) {
+		for i, dep := range deps {
+			meta, errExplode := dep.Explode()
+			if errExplode != nil || !strings.EqualFold(meta.User+"/"+meta.Repo, target.User+"/"+target.Repo) {
+				continue
+			}
+			switch {
+			case meta.Tag != "" && strings.HasSuffix(string(dep), ":"+meta.Tag):
+				deps[i] = versioning.DependencyString(strings.TrimSuffix(string(dep), meta.Tag) + constraint)
+			case meta.Tag == "" && meta.Branch == "" && meta.Commit == "":
+				deps[i] = versioning.DependencyString(string(dep) + ":" + constraint)
+			default:
+				return true, errors.Errorf("%s is not constrained to a version, change it by hand", dep)
+			}
+			return true, nil
+		}
+		return false, nil
+	}
+
+	found, err := change(pkg.Dependencies)
+	if err != nil || found {
+		return
+	}
+	found, err = change(pkg.Development)
+	if err != nil || found {
+		return
+	}
+	return errors.Errorf("%s/%s is not a dependency of the package, override the conflicting constraint instead", target.User, target.Repo)
+}
+
+// PromptConflictResolution describes a constraint conflict and asks how to settle it
+func PromptConflictResolution(conflict ConstraintConflict) (resolution ConflictResolution, err error) {
+	fmt.Println(conflict.Error())
+
+	dependency := conflict.Dependency.User + "/" + conflict.Dependency.Repo
+	actions := []string{
+		fmt.Sprintf("Use one version of %s everywhere", dependency),
+		fmt.Sprintf("Override every constraint on %s", dependency),
+		"Change the constraint on one of the packages that requires it",
+	}
+	var action string
+	err = survey.AskOne(&survey.Select{Message: "How should the conflict be settled?", Options: actions}, &action, survey.Required)
+	if err != nil {
+		return
+	}
+
+	validConstraint := func(answer interface{}) error {
+		_, errConstraint := semver.NewConstraint(fmt.Sprint(answer))
+		return errConstraint
+	}
+
+	switch action {
+	case actions[0]:
+		if len(conflict.Versions) == 0 {
+			return resolution, errors.Errorf("%s has no versions to choose from", dependency)
+		}
+		versions := make([]string, len(conflict.Versions))
+		for i, version := range conflict.Versions {
+			versions[len(versions)-1-i] = version
+		}
+		resolution.Action = ConflictPickVersion
+		err = survey.AskOne(&survey.Select{Message: "Version of " + dependency, Options: versions}, &resolution.Constraint, survey.Required)
+	case actions[1]:
+		resolution.Action = ConflictOverride
+		err = survey.AskOne(&survey.Input{Message: "Constraint for " + dependency}, &resolution.Constraint, validConstraint)
+	default:
+		var (
+			requesters = make(map[string]versioning.DependencyMeta)
+			options    []string
+		)
+		for _, request := range conflict.Requests {
+			name := "the package's own constraint on " + dependency
+			if request.Requester.Repo != "" {
+				name = request.Requester.User + "/" + request.Requester.Repo
+			}
+			if _, ok := requesters[name]; !ok {
+				requesters[name] = request.Requester
+				options = append(options, name)
+			}
+		}
+		var requester string
+		err = survey.AskOne(&survey.Select{Message: "Which constraint should be changed?", Options: options}, &requester, survey.Required)
+		if err != nil {
+			return
+		}
+		resolution.Action = ConflictDowngrade
+		resolution.Requester = requesters[requester]
+		err = survey.AskOne(&survey.Input{Message: "New version constraint"}, &resolution.Constraint, validConstraint)
+	}
+	return
+}
@@ -0,0 +1,87 @@
+package rook
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_refFromTagConflict(t *testing.T) {
+	dir := util.FullPath("./tests/conflict")
+	os.RemoveAll(dir)
+	commitVersions(t, filepath.Join(dir, "lib"), []string{"1.0.0", "1.2.0", "2.0.0"})
+	repo, err := git.PlainOpen(filepath.Join(dir, "lib"))
+	assert.NoError(t, err)
+
+	lib := versioning.DependencyMeta{User: "test", Repo: "lib", Tag: "^1.0.0"}
+	other := versioning.DependencyMeta{User: "test", Repo: "other", Tag: "1.0.0"}
+	newContext := func(resolve func(ConstraintConflict) (ConflictResolution, error)) *PackageContext {
+		pkg := types.Package{
+			LocalPath:    filepath.Join(dir, "package"),
+			Format:       "json",
+			Dependencies: []versioning.DependencyString{"test/lib:^1.0.0", "test/other:1.0.0"},
+		}
+		assert.NoError(t, os.MkdirAll(pkg.LocalPath, 0700))
+		assert.NoError(t, pkg.WriteDefinition())
+
+		pcx := &PackageContext{Package: pkg, Strategy: StrategyMinimal, ResolveConflict: resolve}
+		pcx.addConstraint(lib)
+		pcx.addRequest(versioning.DependencyMeta{}, lib)
+		pcx.addConstraint(versioning.DependencyMeta{User: "test", Repo: "lib", Tag: ">=2.0.0"})
+		pcx.addRequest(other, versioning.DependencyMeta{User: "test", Repo: "lib", Tag: ">=2.0.0"})
+		return pcx
+	}
+
+	_, err = newContext(nil).refFromTag(context.Background(), repo, lib)
+	assert.EqualError(t, err, `the version constraints on test/lib conflict:
+  the package requires ^1.0.0
+  test/other:1.0.0 requires >=2.0.0
+  available versions are 1.0.0, 1.2.0, 2.0.0`)
+
+	var asked ConstraintConflict
+	pcx := newContext(func(conflict ConstraintConflict) (ConflictResolution, error) {
+		asked = conflict
+		return ConflictResolution{Action: ConflictPickVersion, Constraint: "2.0.0"}, nil
+	})
+	ref, err := pcx.refFromTag(context.Background(), repo, lib)
+	assert.NoError(t, err)
+	assert.Equal(t, plumbing.ReferenceName("refs/tags/2.0.0"), ref.Name())
+	assert.Len(t, asked.Requests, 2)
+	written, err := types.PackageFromDir(filepath.Join(dir, "package"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"test/lib": "2.0.0"}, written.Overrides)
+
+	pcx = newContext(func(conflict ConstraintConflict) (ConflictResolution, error) {
+		return ConflictResolution{Action: ConflictDowngrade, Constraint: "0.9.0", Requester: other}, nil
+	})
+	_, err = pcx.refFromTag(context.Background(), repo, lib)
+	assert.EqualError(t, err, "test/lib:^1.0.0 can't be resolved until it's ensured again")
+	written, err = types.PackageFromDir(filepath.Join(dir, "package"))
+	assert.NoError(t, err)
+	assert.Equal(t, []versioning.DependencyString{"test/lib:^1.0.0", "test/other:0.9.0"}, written.Dependencies)
+	assert.Empty(t, written.Overrides)
+	assert.Equal(t, written.Dependencies, pcx.Package.Dependencies)
+
+	// a package created in memory is changed without writing a definition file
+	memory := filepath.Join(dir, "memory")
+	assert.NoError(t, os.MkdirAll(memory, 0700))
+	pcx = newContext(func(conflict ConstraintConflict) (ConflictResolution, error) {
+		return ConflictResolution{Action: ConflictPickVersion, Constraint: "2.0.0"}, nil
+	})
+	pcx.Package.LocalPath = memory
+	pcx.Package.Format = ""
+	ref, err = pcx.refFromTag(context.Background(), repo, lib)
+	assert.NoError(t, err)
+	assert.Equal(t, plumbing.ReferenceName("refs/tags/2.0.0"), ref.Name())
+	assert.Equal(t, map[string]string{"test/lib": "2.0.0"}, pcx.Package.Overrides)
+	assert.False(t, util.Exists(filepath.Join(memory, "pawn.json")))
+}
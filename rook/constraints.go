@@ -0,0 +1,135 @@
+package rook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// DefaultResolutionTTL is how long the version a constraint resolved to is reused for by every
+// package that shares the cache directory
+const DefaultResolutionTTL = time.Hour
+
+// constraintResolution is the version that a constraint on a dependency resolved to and when
+type constraintResolution struct {
+	Tag      string    `json:"tag"`
+	Commit   string    `json:"commit"`
+	Resolved time.Time `json:"resolved"`
+}
+
+// resolvedConstraintKey identifies a constraint decision by everything it depends on: the
+// dependency, where its versions come from and the constraints that were resolved, which is only
+// the package's own constraint unless every constraint must be satisfied by the minimal strategy
+func resolvedConstraintKey(meta versioning.DependencyMeta, source VersionSource, minimal bool, constraints []string) string {
+	dependency := constraintKey(meta)
+	if meta.Site != "" {
+		dependency = strings.ToLower(meta.Site) + "/" + dependency
+	}
+	if minimal {
+		return dependency + " " + string(source) + " minimal " + strings.Join(constraints, " ")
+	}
+	return dependency + " " + string(source) + " " + meta.Tag
+}
+
+func (pcx *PackageContext) resolvedConstraintsPath() string {
+	return filepath.Join(pcx.CacheDir, "constraints.json")
+}
+
+func (pcx *PackageContext) resolutionTTL() time.Duration {
+	if pcx.ResolutionTTL == 0 {
+		return DefaultResolutionTTL
+	}
+	return pcx.ResolutionTTL
+}
+
+// cachedConstraint returns the version a constraint resolved to within the TTL, as long as the
+// repository still has the commit it resolved to. The cache isn't read when refreshing.
+func (pcx *PackageContext) cachedConstraint(repo *git.Repository, key string) (ref *plumbing.Reference, ok bool) {
+	if pcx.CacheDir == "" || pcx.Refresh || pcx.resolutionTTL() < 0 {
+		return
+	}
+	pcx.readResolvedConstraints()
+
+	cached, found := pcx.resolved[key]
+	if !found {
+		return
+	}
+	if age := time.Since(cached.Resolved); age > pcx.resolutionTTL() {
+		print.Verb("constraint", key, "was resolved", age.Round(time.Second), "ago, resolving it again")
+		return
+	}
+	hash := plumbing.NewHash(cached.Commit)
+	if _, err := repo.CommitObject(hash); err != nil {
+		print.Verb("constraint", key, "resolved to", cached.Tag, "which is not in the repository, resolving it again")
+		return
+	}
+	print.Verb("constraint", key, "resolved to", cached.Tag, "at", cached.Resolved.Format(time.RFC3339))
+	return plumbing.NewHashReference(plumbing.ReferenceName("refs/tags/"+cached.Tag), hash), true
+}
+
+// cacheConstraint records the version a constraint resolved to for other packages to reuse, a
+// failure to write the cache only means the next package resolves it again
+func (pcx *PackageContext) cacheConstraint(key string, ref *plumbing.Reference) {
+	if pcx.CacheDir == "" || pcx.resolutionTTL() < 0 || !ref.Name().IsTag() {
+		return
+	}
+	pcx.readResolvedConstraints()
+	pcx.resolved[key] = constraintResolution{
+		Tag:      ref.Name().Short(),
+		Commit:   ref.Hash().String(),
+		Resolved: time.Now(),
+	}
+	if err := pcx.writeResolvedConstraints(); err != nil {
+		print.Verb("failed to cache constraint", key, err)
+	}
+}
+
+// readResolvedConstraints loads the shared cache once, an unreadable cache is treated as empty
+func (pcx *PackageContext) readResolvedConstraints() {
+	if pcx.resolved != nil {
+		return
+	}
+	pcx.resolved = make(map[string]constraintResolution)
+	contents, err := ioutil.ReadFile(pcx.resolvedConstraintsPath())
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(contents, &pcx.resolved); err != nil {
+		print.Verb("ignoring invalid constraint cache:", err)
+		pcx.resolved = make(map[string]constraintResolution)
+	}
+}
+
+func (pcx *PackageContext) writeResolvedConstraints() (err error) {
+	// drop expired entries so the cache doesn't grow with every constraint ever resolved
+	for key, cached := range pcx.resolved {
+		if time.Since(cached.Resolved) > pcx.resolutionTTL() {
+			delete(pcx.resolved, key)
+		}
+	}
+	contents, err := json.MarshalIndent(pcx.resolved, "", "\t")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode constraint cache")
+	}
+	path := pcx.resolvedConstraintsPath()
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return errors.Wrap(err, "failed to create cache directory")
+	}
+	// write to a temporary file first so a concurrent invocation never reads a partial cache
+	err = ioutil.WriteFile(path+".tmp", contents, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write constraint cache")
+	}
+	return os.Rename(path+".tmp", path)
+}
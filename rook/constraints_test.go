@@ -0,0 +1,68 @@
+package rook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_refFromTagCached(t *testing.T) {
+	dir := util.FullPath("./tests/constraints")
+	os.RemoveAll(dir)
+	commitVersions(t, filepath.Join(dir, "lib"), []string{"1.0.0", "1.1.0", "1.2.0"})
+	repo, err := git.PlainOpen(filepath.Join(dir, "lib"))
+	assert.NoError(t, err)
+	old, err := repo.Reference("refs/tags/1.0.0", true)
+	assert.NoError(t, err)
+
+	meta := versioning.DependencyMeta{User: "Test", Repo: "Lib", Tag: "^1.0.0"}
+	cacheDir := filepath.Join(dir, "cache")
+	key := resolvedConstraintKey(meta, VersionSourceTags, false, []string{meta.Tag})
+	assert.Equal(t, "test/lib tags ^1.0.0", key)
+
+	seed := func(tag, commit string, resolved time.Time) {
+		contents, _ := json.Marshal(map[string]constraintResolution{key: {Tag: tag, Commit: commit, Resolved: resolved}})
+		os.MkdirAll(cacheDir, 0700)
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(cacheDir, "constraints.json"), contents, 0600))
+	}
+	resolve := func(pcx PackageContext) string {
+		pcx.CacheDir = cacheDir
+		ref, errRef := pcx.refFromTag(context.Background(), repo, meta)
+		assert.NoError(t, errRef)
+		return ref.Name().Short()
+	}
+
+	// a recent decision is reused even though a newer version matches now
+	seed("1.0.0", old.Hash().String(), time.Now())
+	assert.Equal(t, "1.0.0", resolve(PackageContext{}))
+
+	// unless it's refreshed, expired or the commit it resolved to is gone
+	assert.Equal(t, "1.2.0", resolve(PackageContext{Refresh: true}))
+	seed("1.0.0", old.Hash().String(), time.Now().Add(-2*time.Hour))
+	assert.Equal(t, "1.2.0", resolve(PackageContext{}))
+	seed("1.0.0", plumbing.ZeroHash.String(), time.Now())
+	assert.Equal(t, "1.2.0", resolve(PackageContext{}))
+	seed("1.0.0", old.Hash().String(), time.Now())
+	assert.Equal(t, "1.2.0", resolve(PackageContext{ResolutionTTL: -1}))
+
+	// each new decision is recorded for the next package
+	seed("1.0.0", old.Hash().String(), time.Now().Add(-2*time.Hour))
+	assert.Equal(t, "1.2.0", resolve(PackageContext{}))
+	contents, err := ioutil.ReadFile(filepath.Join(cacheDir, "constraints.json"))
+	assert.NoError(t, err)
+	cached := map[string]constraintResolution{}
+	assert.NoError(t, json.Unmarshal(contents, &cached))
+	assert.Equal(t, "1.2.0", cached[key].Tag)
+	assert.WithinDuration(t, time.Now(), cached[key].Resolved, time.Minute)
+}
@@ -37,6 +37,11 @@ var IncludesToDependencies = map[string]versioning.DependencyString{
 
 // FindIncludes checks a list of files and scans the contents searching for includes with known dependency strings
 func FindIncludes(files []string) (includes []versioning.DependencyString) {
+	return FindIndexedIncludes(files, IncludesToDependencies)
+}
+
+// FindIndexedIncludes is FindIncludes with the include patterns of a package index, see IndexIncludes
+func FindIndexedIncludes(files []string, known map[string]versioning.DependencyString) (includes []versioning.DependencyString) {
 	mux := &sync.Mutex{}
 	seen := map[versioning.DependencyString]struct{}{}
 	wg := sync.WaitGroup{}
@@ -49,13 +54,18 @@ func FindIncludes(files []string) (includes []versioning.DependencyString) {
 				return
 			}
 
-			for expr := range IncludesToDependencies {
-				if len(regexp.MustCompile(fmt.Sprintf(`#include\s\<%s\>.*`, expr)).FindAllString(string(content), -1)) > 0 {
-					if _, ok := seen[IncludesToDependencies[expr]]; !ok {
+			for expr := range known {
+				matcher, errInner := regexp.Compile(fmt.Sprintf(`#include\s\<%s\>.*`, expr))
+				if errInner != nil {
+					print.Warn("Invalid include pattern", expr, "for", known[expr])
+					continue
+				}
+				if len(matcher.FindAllString(string(content), -1)) > 0 {
+					if _, ok := seen[known[expr]]; !ok {
 						mux.Lock()
-						includes = append(includes, IncludesToDependencies[expr])
+						includes = append(includes, known[expr])
 						mux.Unlock()
-						print.Info("Discovered include matching:", expr, " resolved to:", IncludesToDependencies[expr])
+						print.Info("Discovered include matching:", expr, " resolved to:", known[expr])
 					}
 				}
 			}
@@ -0,0 +1,252 @@
+package rook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/compiler"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// DependencyCheck is the result of compiling the entry script of a dependency on its own
+type DependencyCheck struct {
+	Dependency versioning.DependencyMeta
+	Commit     string              // the vendored commit that was checked
+	Entry      string              // the entry script of the dependency, usually a test harness
+	Problems   types.BuildProblems // warnings and errors reported by the compiler
+	Skipped    string              // why the dependency could not be checked, if it wasn't
+	Cached     bool                // whether the result came from an earlier check of the same commit
+}
+
+// Broken returns true if the dependency failed to compile on its own
+func (dc DependencyCheck) Broken() bool {
+	for _, problem := range dc.Problems {
+		if problem.Severity > types.ProblemWarning {
+			return true
+		}
+	}
+	return false
+}
+
+func (dc DependencyCheck) String() string {
+	switch {
+	case dc.Skipped != "":
+		return fmt.Sprintf("%s: skipped, %s", dc.Dependency, dc.Skipped)
+	case dc.Broken():
+		return fmt.Sprintf("%s: broken, %s does not compile", dc.Dependency, dc.Entry)
+	}
+	return fmt.Sprintf("%s: ok", dc.Dependency)
+}
+
+// CheckDependencies compiles the entry script of every vendored dependency in isolation from the
+// package itself, using only the include paths of the dependencies. This shows whether a build
+// failure comes from the package or from a library it depends on. Results are cached by commit,
+// compiler version, build config and lockfile, since the other dependencies are on the include path
+// too, so only dependencies that changed are compiled again. Dependencies should be ensured first.
+func (pcx *PackageContext) CheckDependencies(ctx context.Context, build string) (checks []DependencyCheck, err error) {
+	config, err := pcx.buildPrepare(ctx, build, false, false)
+	if err != nil {
+		err = errors.Wrap(err, "failed to prepare build config")
+		return
+	}
+
+	vendor := pcx.Package.Vendor
+	includes := isolatedIncludes(config.Includes, pcx.Package.LocalPath, vendor)
+	lockfile := lockfileStamp(pcx.Package.LocalPath)
+	stamp := configStamp(config, includes)
+
+	outputDir, err := ioutil.TempDir("", "sampctl-depcheck")
+	if err != nil {
+		err = errors.Wrap(err, "failed to create temporary output directory")
+		return
+	}
+	defer os.RemoveAll(outputDir) // nolint
+
+	seen := make(map[string]struct{})
+	for _, meta := range pcx.AllDependencies {
+		key := strings.ToLower(meta.VendorName())
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		check := DependencyCheck{Dependency: meta}
+		depDir := meta.PackageDir(filepath.Join(vendor, meta.VendorName()))
+
+		pkg, errInner := types.PackageFromDir(depDir)
+		if errInner != nil {
+			check.Skipped = "no package definition"
+			checks = append(checks, check)
+			continue
+		}
+		if pkg.Entry == "" {
+			check.Skipped = "no entry script"
+			checks = append(checks, check)
+			continue
+		}
+		check.Entry = pkg.Entry
+		input := filepath.Join(depDir, pkg.Entry)
+		if !util.Exists(input) {
+			check.Skipped = fmt.Sprintf("entry script %s does not exist", pkg.Entry)
+			checks = append(checks, check)
+			continue
+		}
+
+		check.Commit, errInner = pcx.vendoredCommit(meta)
+		if errInner != nil {
+			print.Verb(meta, "not checking cache for previous result:", errInner)
+		}
+
+		cacheFile := ""
+		if check.Commit != "" {
+			cacheFile = checkCacheFile(pcx.CacheDir, meta, check.Commit, string(config.Version), stamp, lockfile)
+			if problems, ok := readCachedCheck(cacheFile); ok {
+				check.Problems = problems
+				check.Cached = true
+				checks = append(checks, check)
+				continue
+			}
+		}
+
+		depConfig := *config
+		depConfig.Input = input
+		depConfig.Output = filepath.Join(outputDir, meta.VendorName()+".amx")
+		depConfig.WorkingDir = filepath.Dir(input)
+		depConfig.Includes = append([]string{filepath.Dir(input)}, includes...)
+		depConfig.Plugins = nil
+
+		print.Verb(meta, "compiling", pkg.Entry, "in isolation")
+		command, errInner := compiler.PrepareCommand(ctx, pcx.GitHub, depDir, pcx.CacheDir, pcx.Platform, depConfig)
+		if errInner != nil {
+			err = errors.Wrapf(errInner, "failed to prepare compiler for %s", meta)
+			return
+		}
+		check.Problems, _, errInner = compiler.CompileWithCommand(command, depConfig.WorkingDir, depDir, true)
+		if errInner != nil {
+			err = errors.Wrapf(errInner, "failed to compile %s", meta)
+			return
+		}
+
+		if cacheFile != "" {
+			writeCachedCheck(cacheFile, check.Problems)
+		}
+		checks = append(checks, check)
+	}
+
+	return
+}
+
+// isolatedIncludes removes the include paths that belong to the package itself, leaving only those
+// provided by dependencies and resources.
+func isolatedIncludes(includes []string, localPath, vendor string) (result []string) {
+	for _, include := range includes {
+		if isWithin(include, localPath) && !isWithin(include, vendor) {
+			continue
+		}
+		result = append(result, include)
+	}
+	return
+}
+
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// lockfileStamp identifies the contents of the lockfile of a package, it's empty without one
+func lockfileStamp(dir string) string {
+	contents, err := ioutil.ReadFile(filepath.Join(dir, types.LockfileName))
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:8])
+}
+
+// configStamp identifies the parts of a build config that change how a dependency compiles: the
+// compiler, its flags and constants and the include paths other than the dependency's own
+func configStamp(config *types.BuildConfig, includes []string) string {
+	contents, err := json.Marshal(struct {
+		Compiler        versioning.DependencyString
+		CompilerPath    string
+		Args            []string
+		Includes        []string
+		Constants       map[string]string
+		ForceIncludes   []string
+		CoverageMarkers bool
+		Profile         types.BuildProfile
+		Debug           *int
+		Optimization    *int
+		Compress        *bool
+	}{
+		config.Compiler,
+		config.CompilerPath,
+		config.Args,
+		includes,
+		config.Constants,
+		config.ForceIncludes,
+		config.CoverageMarkers,
+		config.Profile,
+		config.Debug,
+		config.Optimization,
+		config.Compress,
+	})
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:8])
+}
+
+// checkCacheFile is where the result of checking a dependency at a commit is cached, it's keyed by
+// the compiler version, the build config and the lockfile too
+func checkCacheFile(cacheDir string, meta versioning.DependencyMeta, commit, version, config, lockfile string) string {
+	if lockfile == "" {
+		lockfile = "unlocked"
+	}
+	return filepath.Join(cacheDir, "depcheck", meta.User, meta.Repo, fmt.Sprintf("%s-%s-%s-%s.json", commit, version, config, lockfile))
+}
+
+func readCachedCheck(file string) (problems types.BuildProblems, ok bool) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(contents, &problems)
+	if err != nil {
+		print.Verb("ignoring invalid cached dependency check", file, err)
+		return
+	}
+	return problems, true
+}
+
+func writeCachedCheck(file string, problems types.BuildProblems) {
+	if problems == nil {
+		problems = types.BuildProblems{}
+	}
+	contents, err := json.Marshal(problems)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(file), 0700)
+	}
+	if err == nil {
+		err = ioutil.WriteFile(file, contents, 0600)
+	}
+	if err != nil {
+		print.Warn("Failed to cache dependency check result:", err)
+	}
+}
@@ -0,0 +1,93 @@
+package rook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestIsolatedIncludes(t *testing.T) {
+	local := filepath.Join("/", "pkg")
+	vendor := filepath.Join(local, "dependencies")
+
+	got := isolatedIncludes([]string{
+		local,
+		filepath.Join(local, "src"),
+		filepath.Join(vendor, "lib-a"),
+		filepath.Join(vendor, "lib-b", "include"),
+		filepath.Join("/", "pkgs", "other"),
+		filepath.Join(local, "..src"),
+		filepath.Join(vendor, "..lib-c"),
+	}, local, vendor)
+
+	// names that only start with two dots are still within their parent
+	assert.Equal(t, []string{
+		filepath.Join(vendor, "lib-a"),
+		filepath.Join(vendor, "lib-b", "include"),
+		filepath.Join("/", "pkgs", "other"),
+		filepath.Join(vendor, "..lib-c"),
+	}, got)
+}
+
+func TestCheckCacheFile(t *testing.T) {
+	dir := util.FullPath("./tests/depcheck/stamp")
+	os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+	meta := versioning.DependencyMeta{User: "user", Repo: "lib-a"}
+
+	assert.Equal(t, "", lockfileStamp(dir))
+	unlocked := checkCacheFile("cache", meta, "commit", "3.10.8", "config", lockfileStamp(dir))
+	assert.Equal(t, filepath.Join("cache", "depcheck", "user", "lib-a", "commit-3.10.8-config-unlocked.json"), unlocked)
+
+	// the other dependencies are on the include path too, so a different lockfile is a different check
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, types.LockfileName), []byte(`{"dependencies": {"user/lib-b": "one"}}`), 0600))
+	first := checkCacheFile("cache", meta, "commit", "3.10.8", "config", lockfileStamp(dir))
+	assert.NotEqual(t, unlocked, first)
+	assert.Equal(t, first, checkCacheFile("cache", meta, "commit", "3.10.8", "config", lockfileStamp(dir)))
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, types.LockfileName), []byte(`{"dependencies": {"user/lib-b": "two"}}`), 0600))
+	assert.NotEqual(t, first, checkCacheFile("cache", meta, "commit", "3.10.8", "config", lockfileStamp(dir)))
+}
+
+func TestConfigStamp(t *testing.T) {
+	config := &types.BuildConfig{Args: []string{"-d3"}, Constants: map[string]string{"MAX_PLAYERS": "50"}}
+	includes := []string{filepath.Join("dependencies", "lib-b")}
+	stamp := configStamp(config, includes)
+	assert.NotEmpty(t, stamp)
+	assert.Equal(t, stamp, configStamp(config, includes))
+
+	// the same commit compiles differently with other flags, constants or include paths
+	assert.NotEqual(t, stamp, configStamp(&types.BuildConfig{Args: []string{"-d0"}, Constants: config.Constants}, includes))
+	assert.NotEqual(t, stamp, configStamp(&types.BuildConfig{Args: config.Args, Constants: map[string]string{"MAX_PLAYERS": "100"}}, includes))
+	assert.NotEqual(t, stamp, configStamp(config, nil))
+
+	// but not with another input or output
+	assert.Equal(t, stamp, configStamp(&types.BuildConfig{Args: config.Args, Constants: config.Constants, Input: "gamemode.pwn", Output: "gamemode.amx"}, includes))
+}
+
+func TestCachedCheck(t *testing.T) {
+	file := util.FullPath("./tests/depcheck/lib-a/commit-3.10.8.json")
+	os.RemoveAll(filepath.Dir(file))
+
+	_, ok := readCachedCheck(file)
+	assert.False(t, ok)
+
+	writeCachedCheck(file, nil)
+	problems, ok := readCachedCheck(file)
+	assert.True(t, ok)
+	assert.False(t, DependencyCheck{Problems: problems}.Broken())
+
+	writeCachedCheck(file, types.BuildProblems{
+		{File: "test.pwn", Line: 3, Severity: types.ProblemError, Description: "undefined symbol"},
+	})
+	problems, ok = readCachedCheck(file)
+	assert.True(t, ok)
+	assert.True(t, DependencyCheck{Problems: problems}.Broken())
+}
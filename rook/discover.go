@@ -0,0 +1,157 @@
+package rook
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// DiscoverResources inspects the release assets of a plugin repository and generates candidate
+// resource definitions from them. The platform of each asset is guessed from its name and, for
+// archives, from the plugin binaries inside it. The results are only a starting point and should
+// be checked before being added to a package definition.
+func DiscoverResources(ctx context.Context, gh *github.Client, meta versioning.DependencyMeta, cacheDir string) (resources []types.Resource, err error) {
+	release, err := download.GetRelease(ctx, gh, meta)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to get release for %s", meta)
+		return
+	}
+
+	dir := filepath.Join(cacheDir, "discover", meta.User, meta.Repo, release.GetTagName())
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		err = errors.Wrap(err, "failed to create discovery cache directory")
+		return
+	}
+
+	for _, asset := range release.Assets {
+		name := asset.GetName()
+
+		var contents []string
+		if isArchive(name) {
+			print.Verb(meta, "downloading release asset", name, "to inspect contents")
+
+			var filename string
+			filename, err = download.FromNet(ctx, asset.GetBrowserDownloadURL(), dir, name)
+			if err != nil {
+				err = errors.Wrapf(err, "failed to download release asset %s", name)
+				return
+			}
+			contents, err = download.ListArchive(ctx, filename)
+			if err != nil {
+				err = errors.Wrapf(err, "failed to list contents of release asset %s", name)
+				return
+			}
+		}
+
+		resources = append(resources, resourcesFromAsset(name, release.GetTagName(), contents)...)
+	}
+
+	return
+}
+
+// resourcesFromAsset generates resources for a single release asset, archives that contain both
+// Linux and Windows binaries without a platform in their name produce one resource per platform.
+func resourcesFromAsset(name, tag string, contents []string) (resources []types.Resource) {
+	pattern := assetPattern(name, tag)
+
+	if !isArchive(name) {
+		platform := platformFromPlugin(name)
+		if platform == "" {
+			return
+		}
+		return []types.Resource{{
+			Name:     pattern,
+			Platform: platform,
+		}}
+	}
+
+	var (
+		includeSet = make(map[string]struct{})
+		includes   []string
+		plugins    = make(map[string][]string)
+	)
+	for _, file := range contents {
+		if path.Ext(file) == ".inc" {
+			includeSet[path.Dir(file)] = struct{}{}
+		} else if platform := platformFromPlugin(file); platform != "" {
+			plugins[platform] = append(plugins[platform], file)
+		}
+	}
+	for include := range includeSet {
+		includes = append(includes, include)
+	}
+	sort.Strings(includes)
+
+	platforms := []string{}
+	if platform := platformFromName(name); platform != "" {
+		platforms = append(platforms, platform)
+	} else {
+		for platform := range plugins {
+			platforms = append(platforms, platform)
+		}
+		sort.Strings(platforms)
+	}
+
+	for _, platform := range platforms {
+		resources = append(resources, types.Resource{
+			Name:     pattern,
+			Platform: platform,
+			Archive:  true,
+			Includes: includes,
+			Plugins:  plugins[platform],
+		})
+	}
+
+	return
+}
+
+// assetPattern turns an asset name into a resource name pattern that matches future releases by
+// replacing the version number with a wildcard.
+func assetPattern(name, tag string) string {
+	pattern := regexp.QuoteMeta(name)
+	version := strings.TrimPrefix(tag, "v")
+	if version != "" {
+		pattern = strings.Replace(pattern, regexp.QuoteMeta(version), "(.*)", -1)
+	}
+	return "^" + pattern + "$"
+}
+
+func isArchive(name string) bool {
+	return strings.HasSuffix(name, ".zip") || strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tar.bz2") || strings.HasSuffix(name, ".tar.xz")
+}
+
+func platformFromName(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.Contains(lower, "linux"):
+		return "linux"
+	case strings.Contains(lower, "darwin") || strings.Contains(lower, "mac"):
+		return "darwin"
+	case strings.Contains(lower, "win"):
+		return "windows"
+	}
+	return ""
+}
+
+func platformFromPlugin(name string) string {
+	switch path.Ext(name) {
+	case ".so":
+		return "linux"
+	case ".dll":
+		return "windows"
+	}
+	return ""
+}
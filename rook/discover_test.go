@@ -0,0 +1,64 @@
+package rook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+)
+
+func Test_resourcesFromAsset(t *testing.T) {
+	tests := []struct {
+		name     string
+		asset    string
+		tag      string
+		contents []string
+		want     []types.Resource
+	}{
+		{"combined", "samp-streamer-plugin-2.9.2.zip", "v2.9.2", []string{
+			"plugins/streamer.so",
+			"plugins/streamer.dll",
+			"pawno/include/streamer.inc",
+			"README.md",
+		}, []types.Resource{
+			{
+				Name:     `^samp-streamer-plugin-(.*)\.zip$`,
+				Platform: "linux",
+				Archive:  true,
+				Includes: []string{"pawno/include"},
+				Plugins:  []string{"plugins/streamer.so"},
+			},
+			{
+				Name:     `^samp-streamer-plugin-(.*)\.zip$`,
+				Platform: "windows",
+				Archive:  true,
+				Includes: []string{"pawno/include"},
+				Plugins:  []string{"plugins/streamer.dll"},
+			},
+		}},
+		{"named", "mysql-R41-4-win32.zip", "R41-4", []string{
+			"plugins/mysql.dll",
+			"libmariadb.dll",
+			"pawno/include/a_mysql.inc",
+		}, []types.Resource{
+			{
+				Name:     `^mysql-(.*)-win32\.zip$`,
+				Platform: "windows",
+				Archive:  true,
+				Includes: []string{"pawno/include"},
+				Plugins:  []string{"plugins/mysql.dll", "libmariadb.dll"},
+			},
+		}},
+		{"binary", "bitmapper.so", "0.2.1", nil, []types.Resource{
+			{Name: `^bitmapper\.so$`, Platform: "linux"},
+		}},
+		{"source", "source.txt", "0.2.1", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resourcesFromAsset(tt.asset, tt.tag, tt.contents)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
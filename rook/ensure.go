@@ -2,14 +2,17 @@ package rook
 
 import (
 	"context"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 
+	"github.com/Southclaws/sampctl/events"
 	"github.com/Southclaws/sampctl/print"
 	"github.com/Southclaws/sampctl/runtime"
 	"github.com/Southclaws/sampctl/types"
@@ -20,8 +23,18 @@ import (
 // ErrNotRemotePackage describes a repository that does not contain a package definition file
 var ErrNotRemotePackage = errors.New("remote repository does not declare a package")
 
-// EnsureDependencies traverses package dependencies and ensures they are up to date
+// EnsureDependencies traverses package dependencies and ensures they are up to date, once all the
+// dependencies are ensured the lockfile is updated with the commits they resolved to. In frozen
+// mode, dependencies are checked out at their locked commits and any change to the lockfile is an
+// error instead. Dependencies whose constraint is unchanged since the lockfile was written and
+// whose vendored copy is still at the locked commit are not updated unless forceUpdate is set or
+// the resolution strategy changed. The locked strategy is the same as frozen mode. Progress is
+// recorded after each dependency, so an ensure that is interrupted resumes where it stopped, unless
+// forceUpdate is set or the strategy changed. A read-only vendor directory can't be ensured.
 func (pcx *PackageContext) EnsureDependencies(ctx context.Context, forceUpdate bool) (err error) {
+	if pcx.ReadOnlyVendor {
+		return errReadOnlyVendor("ensured")
+	}
 	if pcx.Package.LocalPath == "" {
 		return errors.New("package does not represent a locally stored package")
 	}
@@ -32,77 +45,293 @@ func (pcx *PackageContext) EnsureDependencies(ctx context.Context, forceUpdate b
 
 	pcx.Package.Vendor = filepath.Join(pcx.Package.LocalPath, "dependencies")
 
+	lock, err := types.ReadLockfile(pcx.Package.LocalPath)
+	if err != nil {
+		return
+	}
+	pcx.Strategy, err = pcx.resolutionStrategy(lock)
+	if err != nil {
+		return
+	}
+	if pcx.Strategy == StrategyLocked {
+		pcx.Frozen = true
+	}
+	if pcx.Frozen {
+		if lock == nil {
+			return errors.Errorf("frozen ensure requires a %s, run ensure without --frozen to create one", types.LockfileName)
+		}
+		err = pcx.checkLockfileDeclared(*lock)
+		if err != nil {
+			return
+		}
+	}
+
+	// dependencies that were resolved with a different strategy are resolved again
+	strategyChanged := lock != nil && lockedStrategy(pcx.Strategy, lock) != lock.Strategy
+
+	// dependencies are listed before their own dependencies, so the lockfiles they ship are read
+	// before anything they lock is resolved
+	pcx.upstream = nil
+
+	// an ensure that was interrupted recorded the dependencies it had already vendored, but an update
+	// resolves every dependency again so it starts over
+	progress := pcx.readEnsureProgress(!forceUpdate && !strategyChanged)
+
+	failed := 0
+	unchanged := 0
+	resumed := 0
 	for _, dependency := range pcx.AllDependencies {
-		errInner := pcx.EnsurePackage(dependency, forceUpdate)
+		meta := dependency
+		if pcx.Frozen {
+			meta = pinToLockfile(dependency, *lock)
+		} else if pcx.Strategy == StrategyUpstream {
+			meta = pcx.pinToUpstream(ctx, dependency)
+		}
+
+		var errInner error
+		started := time.Now()
+		if !forceUpdate && !strategyChanged && lock != nil && pcx.vendoredAtLock(dependency, *lock) {
+			// the constraint hasn't changed since the lockfile was written and the vendored copy is
+			// still at the locked commit, so there's nothing to resolve.
+			print.Verb(dependency, "unchanged since", types.LockfileName, "was written, skipping update")
+			errInner = pcx.applyTransforms(dependency, filepath.Join(pcx.Package.Vendor, dependency.VendorName()))
+			if errInner == nil {
+				errInner = pcx.ensureResources(ctx, dependency)
+			}
+			unchanged++
+		} else if !forceUpdate && !strategyChanged && pcx.resumable(progress, dependency) {
+			print.Verb(dependency, "was vendored before the last ensure was interrupted, skipping update")
+			errInner = pcx.applyTransforms(dependency, filepath.Join(pcx.Package.Vendor, dependency.VendorName()))
+			if errInner == nil {
+				errInner = pcx.ensureResources(ctx, dependency)
+			}
+			resumed++
+		} else {
+			errInner = pcx.EnsurePackage(ctx, meta, forceUpdate)
+		}
+		events.Measure(ctx, events.StepDependency, dependency.String(), started)
+		if ctx.Err() != nil {
+			return errors.Wrapf(ctx.Err(), "ensure cancelled while ensuring %s", dependency)
+		}
 		if errInner != nil {
+			if pcx.Frozen {
+				return errors.Wrapf(errInner, "failed to ensure package %s", dependency)
+			}
 			print.Warn(errors.Wrapf(errInner, "failed to ensure package %s", dependency))
+			failed++
 			continue
 		}
 		print.Info(pcx.Package, "successfully ensured dependency files for", dependency)
+
+		if errProgress := pcx.recordEnsureProgress(progress, dependency); errProgress != nil {
+			print.Verb(pcx.Package, "failed to record ensure progress:", errProgress)
+		}
+
+		if pcx.Strategy == StrategyUpstream {
+			pcx.recordUpstreamLocks(dependency)
+		}
+
+		if commit, errCommit := pcx.vendoredCommit(dependency); errCommit == nil {
+			events.Publish(ctx, events.DependencyResolved{Dependency: dependency, Commit: commit})
+		}
+	}
+
+	if unchanged > 0 {
+		print.Verb(pcx.Package, unchanged, "of", len(pcx.AllDependencies), "dependencies were already at their locked commits")
+	}
+	if resumed > 0 {
+		print.Info(pcx.Package, "resumed interrupted ensure,", resumed, "dependencies were already vendored")
+	}
+
+	if failed > 0 {
+		print.Warn("Not updating", types.LockfileName, "because", failed, "dependencies failed to ensure")
+		return
 	}
+	pcx.clearEnsureProgress()
 
+	resolved, err := pcx.ResolveLockfile()
+	if err != nil {
+		return errors.Wrap(err, "failed to resolve lockfile")
+	}
+
+	if lock == nil && len(resolved.Dependencies) == 0 {
+		return
+	}
+	if lock != nil {
+		resolved = types.NewLockfile(append(resolved.Dependencies, pcx.lockedOtherPlatforms(*lock)...))
+	}
+	resolved.Strategy = lockedStrategy(pcx.Strategy, lock)
+	if lock != nil {
+		resolved.KeepPlugins(*lock)
+		resolved.Compilers = lock.Compilers
+		resolved.Runtime = lock.Runtime
+		changes := lock.Diff(resolved)
+		if len(changes) == 0 {
+			return
+		}
+		if pcx.Frozen {
+			return errors.Errorf("frozen ensure would change %s:\n%s", types.LockfileName, strings.Join(changes, "\n"))
+		}
+	}
+
+	print.Debug(pcx.Package, "writing", types.LockfileName)
+	err = resolved.Write(pcx.Package.LocalPath)
 	return
 }
 
 func (pcx *PackageContext) GatherPlugins() (err error) {
-	print.Verb(pcx.Package, "gathering", len(pcx.AllPlugins), "plugins from package context")
+	print.Debug(pcx.Package, "gathering", len(pcx.AllPlugins), "plugins from package context")
 	for _, pluginMeta := range pcx.AllPlugins {
-		print.Verb("read plugin from dependency:", pluginMeta)
+		print.Debug("read plugin from dependency:", pluginMeta)
 		pcx.Package.Runtime.PluginDeps = append(pcx.Package.Runtime.PluginDeps, pluginMeta)
 	}
-	print.Verb(pcx.Package, "gathered plugins:", pcx.Package.Runtime.PluginDeps)
+	print.Debug(pcx.Package, "gathered plugins:", pcx.Package.Runtime.PluginDeps)
 	return
 }
 
 // EnsurePackage will make sure a vendor directory contains the specified package.
 // If the package is not present, it will clone it at the correct version tag, sha1 or HEAD
 // If the package is present, it will ensure the directory contains the correct version
-func (pcx *PackageContext) EnsurePackage(meta versioning.DependencyMeta, forceUpdate bool) (err error) {
+// If the package is present but isn't a complete clone, such as when an earlier ensure was killed
+// part way through cloning it, it is removed and cloned again.
+// If the context is cancelled before a new clone is complete, the partial clone is removed.
+func (pcx *PackageContext) EnsurePackage(ctx context.Context, meta versioning.DependencyMeta, forceUpdate bool) (err error) {
 	var (
-		dependencyPath = filepath.Join(pcx.Package.Vendor, meta.Repo)
+		dependencyPath = filepath.Join(pcx.Package.Vendor, meta.VendorName())
 		needToClone    = false // do we need to clone a new repo?
-		head           *plumbing.Reference
 	)
 
-	repo, err := git.PlainOpen(dependencyPath)
-	if err != nil && err != git.ErrRepositoryNotExists {
-		return errors.Wrap(err, "failed to open dependency repository")
-	} else if err == git.ErrRepositoryNotExists {
-		print.Verb(meta, "package does not exist at", dependencyPath, "cloning new copy")
-		needToClone = true
-	} else {
-		head, err = repo.Head()
+	if sparseDir(meta) != "" {
+		var sparse bool
+		sparse, err = pcx.ensureSparse(ctx, meta, dependencyPath, forceUpdate)
 		if err != nil {
-			print.Verb(meta, "package already exists but failed to get repository HEAD:", err)
-			needToClone = true
-			err = os.RemoveAll(dependencyPath)
+			return errors.Wrap(err, "failed to ensure sparse copy")
+		}
+		if sparse {
+			err = pcx.applyTransforms(meta, dependencyPath)
 			if err != nil {
-				return errors.Wrap(err, "failed to temporarily remove possibly corrupted dependency repo")
+				return
 			}
-		} else {
-			print.Verb(meta, "package already exists at", head)
+			return pcx.ensureResources(ctx, meta)
+		}
+	}
+
+	repo, problem := openVendored(dependencyPath)
+	if problem != "" {
+		print.Verb(meta, problem, "- cloning new copy")
+		needToClone = true
+		err = os.RemoveAll(dependencyPath)
+		if err != nil {
+			return errors.Wrap(err, "failed to remove incomplete dependency repo")
+		}
+	} else {
+		print.Debug(meta, "package already exists at", dependencyPath)
+		err = restoreTransformed(repo, dependencyPath)
+		if err != nil {
+			return errors.Wrap(err, "failed to restore transformed files")
 		}
 	}
 
 	if needToClone {
-		print.Verb(meta, "need to clone new copy from cache")
-		repo, err = pcx.EnsureDependencyFromCache(meta, dependencyPath, false)
+		print.Debug(meta, "need to clone new copy from cache")
+		repo, err = pcx.EnsureDependencyFromCache(ctx, meta, dependencyPath, false)
 		if err != nil {
 			return errors.Wrap(err, "failed to ensure dependency from cache")
 		}
+		defer func() {
+			if ctx.Err() != nil {
+				print.Verb(meta, "ensure cancelled, removing partially vendored copy at", dependencyPath)
+				if errRemove := os.RemoveAll(dependencyPath); errRemove != nil {
+					print.Erro("Failed to remove partially vendored dependency:", errRemove)
+				}
+			}
+		}()
 	}
 
-	print.Verb(meta, "updating dependency package")
-	err = pcx.updateRepoState(repo, meta, forceUpdate)
+	print.Debug(meta, "updating dependency package")
+	err = pcx.updateRepoState(ctx, repo, meta, forceUpdate)
 	if err != nil {
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err(), "failed to update repo state")
+		}
 		// try once more, but force a pull
 		print.Verb(meta, "unable to update repo in given state, force-pulling latest from repo tip")
-		err = pcx.updateRepoState(repo, meta, true)
+		err = pcx.updateRepoState(ctx, repo, meta, true)
 		if err != nil {
 			return errors.Wrap(err, "failed to update repo state")
 		}
 	}
+	// a new clone is only complete once the necessary commit is checked out
+	if needToClone {
+		markCloned(dependencyPath)
+	}
 
+	err = pcx.applyTransforms(meta, dependencyPath)
+	if err != nil {
+		return
+	}
+
+	return pcx.ensureResources(ctx, meta)
+}
+
+// cloneMarker is written to the git directory of a vendored dependency once it has been cloned and
+// checked out, a dependency without it may be the remains of an ensure that was interrupted.
+const cloneMarker = "sampctl-cloned"
+
+// openVendored opens the repository of a vendored dependency and checks that it is a complete
+// clone, if not, `problem` describes why. Dependencies vendored before the clone marker existed are
+// trusted if their checkout matches HEAD, which an interrupted checkout wouldn't.
+func openVendored(dir string) (repo *git.Repository, problem string) {
+	if !util.Exists(dir) {
+		return nil, "package does not exist at " + dir
+	}
+
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return nil, "package at " + dir + " is not a valid repository: " + err.Error()
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, "package already exists but failed to get repository HEAD: " + err.Error()
+	}
+	_, err = repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, "package already exists but HEAD is not a valid commit: " + err.Error()
+	}
+
+	if util.Exists(filepath.Join(dir, ".git", cloneMarker)) {
+		return repo, ""
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, "failed to get repo worktree: " + err.Error()
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return nil, "failed to get worktree status: " + err.Error()
+	}
+	for file, s := range status {
+		if s.Worktree != git.Unmodified && s.Worktree != git.Untracked {
+			return nil, "package checkout is incomplete, " + file + " differs from HEAD"
+		}
+	}
+
+	markCloned(dir)
+	return repo, ""
+}
+
+func markCloned(dir string) {
+	err := ioutil.WriteFile(filepath.Join(dir, ".git", cloneMarker), nil, 0600)
+	if err != nil {
+		print.Verb("failed to mark", dir, "as cloned:", err)
+	}
+}
+
+// ensureResources installs the release resources of a vendored package and records it as a plugin
+// if it provides any plugin binaries for the target platform.
+func (pcx *PackageContext) ensureResources(ctx context.Context, meta versioning.DependencyMeta) (err error) {
 	// To install resources (includes from within release archives) we can't use the user's locally
 	// cloned copy of the package that resides in `dependencies/` because that repository may be
 	// checked out to a commit that existed before a `pawn.json` file was added that describes where
@@ -110,6 +339,10 @@ func (pcx *PackageContext) EnsurePackage(meta versioning.DependencyMeta, forceUp
 	// the cached version of the package because the cached copy is always at the latest version, or
 	// at least guaranteed to be either later or equal to the local dependency version.
 	pkg, err := types.GetCachedPackage(meta, pcx.CacheDir)
+	if err != nil && isSparse(filepath.Join(pcx.Package.Vendor, meta.VendorName())) {
+		// sparse copies don't go through the cache, so only their own definition is available
+		pkg, err = types.PackageFromDir(meta.PackageDir(filepath.Join(pcx.Package.Vendor, meta.VendorName())))
+	}
 	if err != nil {
 		return
 	}
@@ -118,33 +351,21 @@ func (pcx *PackageContext) EnsurePackage(meta versioning.DependencyMeta, forceUp
 	// tag of the actual package we installed.
 	pkg.Tag = meta.Tag
 
-	// some resources may not be plugins
-	isPlugin := false
-
 	var includePath string
 	for _, resource := range pkg.Resources {
-		if resource.Platform != pcx.Platform {
+		if resource.Platform != pcx.Platform || len(resource.Includes) == 0 {
 			continue
 		}
 
-		if len(resource.Includes) == 0 {
-			if strings.Contains(resource.Name, "dll") || strings.Contains(resource.Name, "so") {
-				isPlugin = true
-			}
-		} else {
-			includePath, err = pcx.extractResourceDependencies(context.Background(), pkg, resource)
-			if err != nil {
-				return
-			}
-			pcx.AllIncludePaths = append(pcx.AllIncludePaths, includePath)
-
-			if len(resource.Plugins) > 0 {
-				isPlugin = true
-			}
+		includePath, err = pcx.extractResourceDependencies(ctx, pkg, resource)
+		if err != nil {
+			return
 		}
+		pcx.AllIncludePaths = append(pcx.AllIncludePaths, includePath)
 	}
 
-	if isPlugin {
+	// some resources may not be plugins
+	if providesPlugins(pkg, pcx.Platform) {
 		pcx.AllPlugins = append(pcx.AllPlugins, meta)
 		print.Verb(meta, "added plugin", meta)
 	}
@@ -172,13 +393,13 @@ func (pcx PackageContext) extractResourceDependencies(ctx context.Context, pkg t
 }
 
 // updateRepoState takes a repo that exists on disk and ensures it matches tag, branch or commit constraints
-func (pcx *PackageContext) updateRepoState(repo *git.Repository, meta versioning.DependencyMeta, forcePull bool) (err error) {
-	print.Verb(meta, "updating repository state with", pcx.GitAuth, "authentication method")
+func (pcx *PackageContext) updateRepoState(ctx context.Context, repo *git.Repository, meta versioning.DependencyMeta, forcePull bool) (err error) {
+	print.Debug(meta, "updating repository state with", pcx.GitAuth, "authentication method")
 
 	var wt *git.Worktree
 	if forcePull {
-		print.Verb(meta, "performing forced pull to latest tip")
-		repo, err = pcx.EnsureDependencyFromCache(meta, filepath.Join(pcx.Package.Vendor, meta.Repo), true)
+		print.Debug(meta, "performing forced pull to latest tip")
+		repo, err = pcx.EnsureDependencyFromCache(ctx, meta, filepath.Join(pcx.Package.Vendor, meta.VendorName()), true)
 		if err != nil {
 			return errors.Wrap(err, "failed to ensure dependency in cache")
 		}
@@ -187,8 +408,9 @@ func (pcx *PackageContext) updateRepoState(repo *git.Repository, meta versioning
 			return errors.Wrap(err, "failed to get repo worktree")
 		}
 
-		err = wt.Pull(&git.PullOptions{
-			Depth: 1000, // get full history
+		err = wt.PullContext(ctx, &git.PullOptions{
+			Depth:         1000, // get full history
+			ReferenceName: pullReference(repo, pcx.defaultBranch(ctx, meta)),
 		})
 		if err != nil && err != git.NoErrAlreadyUpToDate {
 			return errors.Wrap(err, "failed to force pull for full update")
@@ -210,19 +432,19 @@ func (pcx *PackageContext) updateRepoState(repo *git.Repository, meta versioning
 	}
 
 	if meta.Tag != "" {
-		print.Verb(meta, "package has tag constraint:", meta.Tag)
+		print.Debug(meta, "package has tag constraint:", meta.Tag)
 
-		ref, err = versioning.RefFromTag(repo, meta)
+		ref, err = pcx.refFromTag(ctx, repo, meta)
 		if err != nil {
 			return errors.Wrap(err, "failed to get ref from tag")
 		}
 	} else if meta.Branch != "" {
-		print.Verb(meta, "package has branch constraint:", meta.Branch)
+		print.Debug(meta, "package has branch constraint:", meta.Branch)
 
 		pullOpts.Depth = 1000 // get full history
 		pullOpts.ReferenceName = plumbing.ReferenceName("refs/heads/" + meta.Branch)
 
-		err = wt.Pull(pullOpts)
+		err = wt.PullContext(ctx, pullOpts)
 		if err != nil && err != git.NoErrAlreadyUpToDate {
 			return errors.Wrap(err, "failed to pull repo branch")
 		}
@@ -233,8 +455,9 @@ func (pcx *PackageContext) updateRepoState(repo *git.Repository, meta versioning
 		}
 	} else if meta.Commit != "" {
 		pullOpts.Depth = 1000 // get full history
+		pullOpts.ReferenceName = pullReference(repo, "")
 
-		err = wt.Pull(pullOpts)
+		err = wt.PullContext(ctx, pullOpts)
 		if err != nil && err != git.NoErrAlreadyUpToDate {
 			return errors.Wrap(err, "failed to pull repo")
 		}
@@ -246,7 +469,7 @@ func (pcx *PackageContext) updateRepoState(repo *git.Repository, meta versioning
 	}
 
 	if ref != nil {
-		print.Verb(meta, "checking out ref determined from constraint:", ref)
+		print.Debug(meta, "checking out ref determined from constraint:", ref)
 
 		err = wt.Checkout(&git.CheckoutOptions{
 			Hash:  ref.Hash(),
@@ -255,11 +478,13 @@ func (pcx *PackageContext) updateRepoState(repo *git.Repository, meta versioning
 		if err != nil {
 			return errors.Wrapf(err, "failed to checkout necessary commit %s", ref.Hash())
 		}
-		print.Verb(meta, "successfully checked out to", ref.Hash())
+		print.Debug(meta, "successfully checked out to", ref.Hash())
 	} else {
 		print.Verb(meta, "package does not have version constraint pulling latest")
 
-		err = wt.Pull(pullOpts)
+		// only the latest version is pulled from the default branch
+		pullOpts.ReferenceName = pullReference(repo, pcx.defaultBranch(ctx, meta))
+		err = wt.PullContext(ctx, pullOpts)
 		if err != nil {
 			if err == git.NoErrAlreadyUpToDate {
 				err = nil
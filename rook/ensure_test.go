@@ -3,13 +3,17 @@ package rook
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 
 	"github.com/Southclaws/sampctl/types"
 	"github.com/Southclaws/sampctl/util"
@@ -97,7 +101,7 @@ func TestPackageContext_EnsurePackage(t *testing.T) {
 				},
 			}
 
-			err := pcx.EnsurePackage(tt.args.meta, tt.args.forceUpdate)
+			err := pcx.EnsurePackage(context.Background(), tt.args.meta, tt.args.forceUpdate)
 			if tt.wantErr {
 				assert.Error(t, err)
 			} else {
@@ -131,3 +135,200 @@ func TestPackageContext_EnsurePackage(t *testing.T) {
 		})
 	}
 }
+
+func TestPackageContext_EnsureDependenciesFrozen(t *testing.T) {
+	dir := "./tests/frozen"
+	os.RemoveAll(dir)
+	os.MkdirAll(dir, 0700)
+
+	formatex := versioning.DependencyMeta{User: "Southclaws", Repo: "formatex", Tag: "1.0.0"}
+	pcx := PackageContext{
+		Package:         types.Package{LocalPath: dir},
+		AllDependencies: []versioning.DependencyMeta{formatex},
+		Frozen:          true,
+	}
+
+	err := pcx.EnsureDependencies(context.Background(), false)
+	assert.Error(t, err)
+
+	lock := types.NewLockfile([]types.LockedDependency{
+		{Dependency: "Southclaws/formatex:1.1.0", Commit: "0000000000000000000000000000000000000000"},
+	})
+	assert.NoError(t, lock.Write(dir))
+
+	err = pcx.EnsureDependencies(context.Background(), false)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "removed Southclaws/formatex:1.1.0")
+	assert.Contains(t, err.Error(), "added Southclaws/formatex:1.0.0")
+	assert.False(t, util.Exists(filepath.Join(dir, "dependencies")))
+}
+
+func TestPackageContext_EnsureDependenciesIncremental(t *testing.T) {
+	dir := util.FullPath("./tests/incremental")
+	os.RemoveAll(dir)
+	cacheDir := filepath.Join(dir, "cache")
+
+	meta := versioning.DependencyMeta{Site: "github.com", User: "test", Repo: "locked", Tag: "1.0.0"}
+
+	os.MkdirAll(meta.CachePath(cacheDir), 0700) //nolint
+	err := ioutil.WriteFile(filepath.Join(meta.CachePath(cacheDir), "pawn.json"), []byte(`{"user": "test", "repo": "locked"}`), 0644)
+	assert.NoError(t, err)
+
+	vendored := filepath.Join(dir, "package", "dependencies", "locked")
+	repo, err := git.PlainInit(vendored, false)
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(vendored, "locked.inc"), []byte("stock Locked() {}"), 0644)
+	assert.NoError(t, err)
+	wt, err := repo.Worktree()
+	assert.NoError(t, err)
+	_, err = wt.Add("locked.inc")
+	assert.NoError(t, err)
+	hash, err := wt.Commit("initial", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@test", When: time.Now()},
+	})
+	assert.NoError(t, err)
+	markCloned(vendored)
+
+	lock := types.NewLockfile([]types.LockedDependency{
+		{Dependency: versioning.DependencyString(meta.String()), Commit: hash.String()},
+	})
+	assert.NoError(t, lock.Write(filepath.Join(dir, "package")))
+
+	// the repository has no remote and the cache is not a repository so this can only succeed if
+	// the locked dependency is left alone
+	pcx := PackageContext{
+		Package:         types.Package{LocalPath: filepath.Join(dir, "package")},
+		AllDependencies: []versioning.DependencyMeta{meta},
+		CacheDir:        cacheDir,
+	}
+	err = pcx.EnsureDependencies(context.Background(), false)
+	assert.NoError(t, err)
+
+	after, err := types.ReadLockfile(filepath.Join(dir, "package"))
+	assert.NoError(t, err)
+	assert.Empty(t, lock.Diff(*after))
+
+	// a changed constraint is resolved again
+	pcx.AllDependencies[0].Tag = "2.0.0"
+	assert.False(t, pcx.vendoredAtLock(pcx.AllDependencies[0], lock))
+}
+
+func TestOpenVendored(t *testing.T) {
+	dir := util.FullPath("./tests/partial")
+	os.RemoveAll(dir)
+
+	setup := func(name string) string {
+		path := filepath.Join(dir, name)
+		repo, err := git.PlainInit(path, false)
+		if err != nil {
+			panic(err)
+		}
+		for _, file := range []string{"a.inc", "b.inc"} {
+			err = ioutil.WriteFile(filepath.Join(path, file), []byte("// "+file), 0644)
+			if err != nil {
+				panic(err)
+			}
+		}
+		wt, err := repo.Worktree()
+		if err != nil {
+			panic(err)
+		}
+		for _, file := range []string{"a.inc", "b.inc"} {
+			_, err = wt.Add(file)
+			if err != nil {
+				panic(err)
+			}
+		}
+		_, err = wt.Commit("initial", &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@test", When: time.Now()},
+		})
+		if err != nil {
+			panic(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name        string
+		prepare     func(path string)
+		wantProblem bool
+	}{
+		{"marked", func(path string) { markCloned(path) }, false},
+		{"complete", func(path string) {}, false},
+		{"untracked", func(path string) {
+			ioutil.WriteFile(filepath.Join(path, "build.amx"), nil, 0644) // nolint
+		}, false},
+		{"interrupted checkout", func(path string) { os.Remove(filepath.Join(path, "b.inc")) }, true},
+		{"empty", func(path string) {
+			os.Remove(filepath.Join(path, "a.inc"))
+			os.Remove(filepath.Join(path, "b.inc"))
+		}, true},
+		{"no git", func(path string) { os.RemoveAll(filepath.Join(path, ".git")) }, true},
+		{"no head", func(path string) {
+			ioutil.WriteFile(filepath.Join(path, ".git", "HEAD"), []byte("ref: refs/heads/missing\n"), 0644) // nolint
+		}, true},
+		{"missing", func(path string) { os.RemoveAll(path) }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := setup(tt.name)
+			tt.prepare(path)
+
+			repo, problem := openVendored(path)
+			if tt.wantProblem {
+				assert.NotEmpty(t, problem)
+				assert.Nil(t, repo)
+				return
+			}
+			assert.Empty(t, problem)
+			assert.NotNil(t, repo)
+			assert.True(t, util.Exists(filepath.Join(path, ".git", cloneMarker)))
+		})
+	}
+}
+
+func TestPackageContext_EnsurePackageCancelled(t *testing.T) {
+	dir := util.FullPath("./tests/cancelled")
+	os.RemoveAll(dir)
+
+	meta := versioning.DependencyMeta{Site: "github.com", User: "test", Repo: "cancelled"}
+	pcx := PackageContext{
+		CacheDir: filepath.Join(dir, "cache"),
+		Package: types.Package{
+			LocalPath:      dir,
+			Vendor:         filepath.Join(dir, "dependencies"),
+			DependencyMeta: versioning.DependencyMeta{User: "test", Repo: "cancelled-parent"},
+			Dependencies:   []versioning.DependencyString{"test/cancelled"},
+		},
+	}
+
+	// the cached copy exists so nothing is cloned over the network
+	cached := pcx.cachePath(meta)
+	repo, err := git.PlainInit(cached, false)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(cached, "cancelled.inc"), nil, 0644))
+	wt, err := repo.Worktree()
+	assert.NoError(t, err)
+	_, err = wt.Add("cancelled.inc")
+	assert.NoError(t, err)
+	_, err = wt.Commit("initial", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@test", When: time.Now()},
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// a copy left behind by an ensure that was killed part way through cloning is removed, then
+	// the new clone is abandoned
+	dependencyPath := filepath.Join(pcx.Package.Vendor, meta.VendorName())
+	assert.NoError(t, os.MkdirAll(filepath.Join(dependencyPath, ".git"), 0700))
+	err = pcx.EnsurePackage(ctx, meta, false)
+	assert.Equal(t, context.Canceled, errors.Cause(err))
+	assert.False(t, util.Exists(dependencyPath), "partial clone was left at %s", dependencyPath)
+
+	pcx.AllDependencies = []versioning.DependencyMeta{meta}
+	err = pcx.EnsureDependencies(ctx, false)
+	assert.Equal(t, context.Canceled, errors.Cause(err))
+	assert.False(t, util.Exists(dependencyPath), "partial clone was left at %s", dependencyPath)
+}
@@ -0,0 +1,272 @@
+package rook
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/runtime"
+	"github.com/Southclaws/sampctl/types"
+)
+
+// ReleaseArchive is a distributable archive written for one of the package's resources
+type ReleaseArchive struct {
+	Resource  types.Resource
+	Filename  string            // path of the archive
+	SHA256    string            // checksum of the archive, also written to `<archive>.sha256`
+	Checksums map[string]string // checksums of the plugin binaries, for the `checksums` of the resource
+}
+
+// ExportRelease writes an archive for each archive resource declared by the package, these contain
+// the files at the paths the resource definition tells consumers to expect: the exported includes
+// in every `includes` directory and the plugin binaries, compiler and other files from the package
+// directory. Plugin binaries that aren't at their archive path are also looked for in `plugins/`.
+// If a platform is given, only the resources for that platform are exported.
+func ExportRelease(pkg types.Package, version, platform, outputDir string) (archives []ReleaseArchive, err error) {
+	if version == "" {
+		return nil, errors.New("a version is required to name the release archives")
+	}
+
+	includes, err := exportedIncludes(pkg)
+	if err != nil {
+		return
+	}
+
+	err = os.MkdirAll(outputDir, 0700)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create release directory")
+	}
+
+	for _, resource := range pkg.Resources {
+		if platform != "" && resource.Platform != platform {
+			continue
+		}
+		if !resource.Archive {
+			print.Warn("resource", resource.Name, "is not an archive, upload the file itself instead")
+			continue
+		}
+
+		var archive ReleaseArchive
+		archive, err = exportResource(pkg, resource, includes, version, outputDir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to export %s resource", resource.Platform)
+		}
+		archives = append(archives, archive)
+
+		print.Info("Exported", archive.Filename, "sha256:", archive.SHA256)
+		for _, plugin := range resource.Plugins {
+			print.Info("  checksum of", plugin+":", archive.Checksums[plugin])
+		}
+	}
+	if len(archives) == 0 {
+		return nil, errors.New("package does not declare any archive resources to export")
+	}
+	return
+}
+
+func exportResource(pkg types.Package, resource types.Resource, includes []string, version, outputDir string) (archive ReleaseArchive, err error) {
+	name, err := releaseArchiveName(resource.Name, version)
+	if err != nil {
+		return
+	}
+	format, err := releaseArchiveFormat(name)
+	if err != nil {
+		return
+	}
+
+	entries, checksums, err := releaseEntries(pkg, resource, includes)
+	if err != nil {
+		return
+	}
+
+	archive = ReleaseArchive{
+		Resource:  resource,
+		Filename:  filepath.Join(outputDir, name),
+		Checksums: checksums,
+	}
+	err = download.WriteArchive(archive.Filename, format, entries)
+	if err != nil {
+		return
+	}
+
+	archive.SHA256, err = runtime.PluginChecksum(archive.Filename)
+	if err != nil {
+		return
+	}
+	err = ioutil.WriteFile(archive.Filename+".sha256", []byte(fmt.Sprintf("%s  %s\n", archive.SHA256, name)), 0600)
+	if err != nil {
+		err = errors.Wrap(err, "failed to write archive checksum")
+	}
+	return
+}
+
+// releaseEntries lists the files of a resource archive and checksums the plugin binaries
+func releaseEntries(pkg types.Package, resource types.Resource, includes []string) (entries []download.ArchiveEntry, checksums map[string]string, err error) {
+	var (
+		missing []string
+		errMode error
+	)
+	add := func(name string, candidates ...string) (source string) {
+		for _, candidate := range candidates {
+			candidate = filepath.Join(pkg.LocalPath, filepath.FromSlash(candidate))
+			if info, errStat := os.Stat(candidate); errStat == nil && !info.IsDir() {
+				source = candidate
+				break
+			}
+		}
+		if source == "" {
+			missing = append(missing, name)
+			return
+		}
+		mode, _, errInner := resource.Mode(name)
+		if errInner != nil {
+			errMode = errInner
+		}
+		entries = append(entries, download.ArchiveEntry{Name: name, Source: source, Mode: mode})
+		return
+	}
+
+	includePath := filepath.ToSlash(pkg.IncludePath)
+	for _, dir := range resource.Includes {
+		for _, include := range includes {
+			add(path.Join(dir, include), path.Join(includePath, include))
+		}
+	}
+
+	checksums = make(map[string]string)
+	for _, plugin := range resource.Plugins {
+		source := add(plugin, plugin, path.Join("plugins", path.Base(plugin)))
+		if source == "" {
+			continue
+		}
+		checksums[plugin], err = runtime.PluginChecksum(source)
+		if err != nil {
+			return
+		}
+	}
+	if resource.Compiler != "" {
+		add(resource.Compiler, resource.Compiler)
+	}
+	for file := range resource.Files {
+		add(file, file)
+	}
+
+	globbed, err := packageFiles(pkg.LocalPath, resource.Globs)
+	if err != nil {
+		return
+	}
+	for _, file := range globbed {
+		add(file, file)
+	}
+
+	if errMode != nil {
+		return nil, nil, errMode
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		err = errors.Errorf("files missing from the package: %s", strings.Join(missing, ", "))
+	}
+	return
+}
+
+// exportedIncludes lists the public include files of a package relative to its include path
+func exportedIncludes(pkg types.Package) (includes []string, err error) {
+	exports := pkg.Exports
+	if len(exports) == 0 {
+		exports = []string{"**/*.inc"}
+	}
+	includes, err = packageFiles(filepath.Join(pkg.LocalPath, pkg.IncludePath), exports)
+	if err != nil {
+		return
+	}
+	if len(includes) == 0 {
+		print.Warn("package does not export any include files")
+	}
+	return
+}
+
+// packageFiles lists the files within a directory that match any of the glob patterns, skipping
+// hidden directories and vendored dependencies
+func packageFiles(dir string, globs []string) (files []string, err error) {
+	if len(globs) == 0 {
+		return
+	}
+	var matchers []*regexp.Regexp
+	for _, glob := range globs {
+		matchers = append(matchers, runtime.GlobRegexp(glob))
+	}
+
+	err = filepath.Walk(dir, func(filename string, info os.FileInfo, errWalk error) error {
+		if errWalk != nil {
+			return errWalk
+		}
+		rel, errRel := filepath.Rel(dir, filename)
+		if errRel != nil {
+			return errRel
+		}
+		rel = filepath.ToSlash(rel)
+		if info.IsDir() {
+			if rel != "." && (strings.HasPrefix(info.Name(), ".") || rel == "dependencies") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		for _, matcher := range matchers {
+			if matcher.MatchString(rel) {
+				files = append(files, rel)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		err = errors.Wrap(err, "failed to list package files")
+	}
+	return
+}
+
+var (
+	nameGroup   = regexp.MustCompile(`\([^()]*\)|\.[*+]`)
+	nameEscapes = regexp.MustCompile(`\\(.)`)
+)
+
+// releaseArchiveName derives a file name for the archive of a resource by putting the version in
+// place of the first group or wildcard of the resource name pattern
+func releaseArchiveName(pattern, version string) (name string, err error) {
+	matcher, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", errors.Wrap(err, "resource name is not a valid regular expression")
+	}
+
+	name = strings.TrimSuffix(strings.TrimPrefix(pattern, "^"), "$")
+	if loc := nameGroup.FindStringIndex(name); loc != nil {
+		name = name[:loc[0]] + "\x00" + name[loc[1]:]
+	}
+	name = nameEscapes.ReplaceAllString(name, "$1")
+	name = strings.Replace(name, "\x00", version, 1)
+
+	if !matcher.MatchString(name) || strings.ContainsAny(name, `/\`) {
+		return "", errors.Errorf("can not derive an archive name for version %s from resource name %s", version, pattern)
+	}
+	return
+}
+
+// releaseArchiveFormat picks the archive format from the extension of an archive name
+func releaseArchiveFormat(name string) (format download.ArchiveFormat, err error) {
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return download.ArchiveZip, nil
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return download.ArchiveGzip, nil
+	}
+	return "", errors.Errorf("archive %s must be a .zip, .tar.gz or .tgz file", name)
+}
@@ -0,0 +1,128 @@
+package rook
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/runtime"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestExportRelease(t *testing.T) {
+	dir := util.FullPath("./tests/export")
+	os.RemoveAll(dir)
+	for name, contents := range map[string]string{
+		"test.pwn":                   "#include \"mylib\"\n",
+		"mylib.inc":                  "// public\n",
+		"mylib_impl.inc":             "// private\n",
+		"plugins/mylib.so":           "ELF",
+		"plugins/mylib.dll":          "MZ",
+		"data/config.json":           "{}\n",
+		"dependencies/other/dep.inc": "// vendored\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(contents), 0600))
+	}
+
+	pkg := types.Package{
+		LocalPath: dir,
+		Exports:   []string{"mylib.inc"},
+		Resources: []types.Resource{
+			{
+				Name:     `^mylib-(.*)-linux\.tar\.gz$`,
+				Platform: "linux",
+				Archive:  true,
+				Includes: []string{"mylib-linux/pawno/include"},
+				Plugins:  []string{"mylib-linux/plugins/mylib.so"},
+				Globs:    []string{"data/*.json"},
+			},
+			{
+				Name:     `^mylib-(.*)-win32\.zip$`,
+				Platform: "windows",
+				Archive:  true,
+				Includes: []string{"pawno/include"},
+				Plugins:  []string{"plugins/mylib.dll"},
+			},
+		},
+	}
+
+	output := filepath.Join(dir, "release")
+	archives, err := ExportRelease(pkg, "1.2.3", "", output)
+	assert.NoError(t, err)
+	assert.Len(t, archives, 2)
+
+	for _, tt := range []struct {
+		archive string
+		files   []string
+		plugin  string
+	}{
+		{"mylib-1.2.3-linux.tar.gz", []string{
+			"data/config.json",
+			"mylib-linux/pawno/include/mylib.inc",
+			"mylib-linux/plugins/mylib.so",
+		}, "mylib-linux/plugins/mylib.so"},
+		{"mylib-1.2.3-win32.zip", []string{
+			"pawno/include/mylib.inc",
+			"plugins/mylib.dll",
+		}, "plugins/mylib.dll"},
+	} {
+		filename := filepath.Join(output, tt.archive)
+		names, err := download.ListArchive(context.Background(), filename)
+		assert.NoError(t, err)
+		sort.Strings(names)
+		assert.Equal(t, tt.files, names)
+
+		sum, err := runtime.PluginChecksum(filename)
+		assert.NoError(t, err)
+		contents, err := ioutil.ReadFile(filename + ".sha256")
+		assert.NoError(t, err)
+		assert.Equal(t, sum+"  "+tt.archive+"\n", string(contents))
+
+		for _, archive := range archives {
+			if archive.Filename == filename {
+				assert.Equal(t, sum, archive.SHA256)
+				assert.NotEmpty(t, archive.Checksums[tt.plugin])
+			}
+		}
+	}
+
+	// only the linux resource is exported when the platform is set
+	archives, err = ExportRelease(pkg, "1.2.3", "linux", output)
+	assert.NoError(t, err)
+	assert.Len(t, archives, 1)
+
+	// plugins that can't be found are reported
+	pkg.Resources[0].Plugins = append(pkg.Resources[0].Plugins, "mylib-linux/plugins/missing.so")
+	_, err = ExportRelease(pkg, "1.2.3", "linux", output)
+	assert.EqualError(t, err, "failed to export linux resource: files missing from the package: mylib-linux/plugins/missing.so")
+}
+
+func TestReleaseArchiveName(t *testing.T) {
+	for _, tt := range []struct {
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{`^pawn-requests-(.*)-linux\.tar\.gz$`, "pawn-requests-0.8.6-linux.tar.gz", false},
+		{`mysql-.*-win32.zip`, "mysql-0.8.6-win32.zip", false},
+		{`^streamer\.zip$`, "streamer.zip", false},
+		{`^[a-z]+-(\d+)\.zip$`, "", true},
+	} {
+		got, err := releaseArchiveName(tt.pattern, "0.8.6")
+		if tt.wantErr {
+			assert.Error(t, err, tt.pattern)
+			continue
+		}
+		assert.NoError(t, err, tt.pattern)
+		assert.Equal(t, tt.want, got)
+	}
+}
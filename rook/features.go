@@ -0,0 +1,83 @@
+package rook
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+type featureConstant struct {
+	value   string
+	feature string
+	owner   versioning.DependencyMeta
+}
+
+// ResolveFeatures collects the constants of every enabled feature from each package that declares
+// it. If two packages define the same constant with different values, all conflicts are reported
+// in a single error. Enabled features that no package declares only produce a warning.
+func ResolveFeatures(enabled []string, packages []types.Package) (constants map[string]string, err error) {
+	if len(enabled) == 0 {
+		return
+	}
+
+	var (
+		resolved  = make(map[string]featureConstant)
+		declared  = make(map[string]bool)
+		conflicts []string
+	)
+	for _, pkg := range packages {
+		for _, feature := range enabled {
+			defines, ok := pkg.Features[feature]
+			if !ok {
+				continue
+			}
+			declared[feature] = true
+
+			names := make([]string, 0, len(defines))
+			for name := range defines {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				value := defines[name]
+				existing, exists := resolved[name]
+				if !exists {
+					print.Verb(pkg, "feature", feature, "defines", name, "as", value)
+					resolved[name] = featureConstant{value, feature, pkg.DependencyMeta}
+					continue
+				}
+				if existing.value != value {
+					conflicts = append(conflicts, fmt.Sprintf(
+						"'%s' is '%s' from feature '%s' of %s but '%s' from feature '%s' of %s",
+						name, existing.value, existing.feature, existing.owner, value, feature, pkg.DependencyMeta,
+					))
+				}
+			}
+		}
+	}
+
+	for _, feature := range enabled {
+		if !declared[feature] {
+			print.Warn("Feature", feature, "is enabled but not declared by any package")
+		}
+	}
+
+	if len(conflicts) > 0 {
+		err = errors.Errorf("conflicting feature constants:\n%s", strings.Join(conflicts, "\n"))
+		return
+	}
+
+	constants = make(map[string]string)
+	for name, constant := range resolved {
+		constants[name] = constant.value
+	}
+
+	return
+}
@@ -0,0 +1,50 @@
+package rook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestResolveFeatures(t *testing.T) {
+	logger := types.Package{
+		DependencyMeta: versioning.DependencyMeta{User: "Southclaws", Repo: "samp-logger"},
+		Features: map[string]map[string]string{
+			"debug": {"LOGGER_DEBUG": "1"},
+			"fast":  {"LOGGER_BUFFER": "4096"},
+		},
+	}
+	mysql := types.Package{
+		DependencyMeta: versioning.DependencyMeta{User: "pBlueG", Repo: "SA-MP-MySQL"},
+		Features: map[string]map[string]string{
+			"debug": {"MYSQL_DEBUG": "1", "LOGGER_DEBUG": "1"},
+			"fast":  {"LOGGER_BUFFER": "1024"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		enabled []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"none", nil, nil, false},
+		{"debug", []string{"debug"}, map[string]string{"LOGGER_DEBUG": "1", "MYSQL_DEBUG": "1"}, false},
+		{"undeclared", []string{"unknown"}, map[string]string{}, false},
+		{"conflict", []string{"fast"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveFeatures(tt.enabled, []types.Package{logger, mysql})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package rook
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// flatMarker is written into a flat include directory so later ensures know they can replace it
+const flatMarker = ".sampctl-flat"
+
+// FlatCollision is a path in a flat include directory that more than one dependency provides a
+// file for
+type FlatCollision struct {
+	File   string
+	Owners []string
+}
+
+func (fc FlatCollision) String() string {
+	return fmt.Sprintf("%s is provided by %s", fc.File, strings.Join(fc.Owners, " and "))
+}
+
+// flatFile is a file to put in a flat include directory, files without a source are generated
+type flatFile struct {
+	path   string
+	source string
+	owner  string
+}
+
+// EnsureFlat puts the include files of every ensured dependency directly under a single directory,
+// like a classic `pawno/include`, for tools that don't understand the vendor directory. Namespaced
+// and aliased dependencies are put under their namespace or alias like they are for builds. Files
+// that more than one dependency would put at the same path are collisions and nothing is written
+// until they're resolved with an alias or a namespace. The directory is replaced on each call, so
+// it must either not exist, be empty or have been created by a previous call.
+func (pcx *PackageContext) EnsureFlat(dir string) (files int, err error) {
+	var (
+		planned = make(map[string]flatFile)
+		owners  = make(map[string][]string)
+		aliases []versioning.DependencyMeta
+	)
+	add := func(file flatFile) {
+		key := strings.ToLower(filepath.ToSlash(file.path))
+		if _, exists := planned[key]; !exists {
+			planned[key] = file
+		}
+		owners[key] = append(owners[key], file.owner)
+	}
+
+	for _, depMeta := range pcx.AllDependencies {
+		_, _, includeDir, extraDirs := pcx.dependencyIncludes(depMeta)
+		for _, extraDir := range extraDirs {
+			err = flatIncludes(extraDir, "", depMeta.String(), add)
+			if err != nil {
+				return
+			}
+		}
+		if includeDir == "" {
+			continue
+		}
+
+		prefix := ""
+		if depMeta.Namespace != "" {
+			prefix = filepath.Join(depMeta.Namespace, depMeta.Repo)
+		} else if depMeta.Alias != "" {
+			prefix = depMeta.Alias
+			aliases = append(aliases, depMeta)
+			add(flatFile{path: depMeta.Alias + ".inc", owner: depMeta.String()})
+		}
+
+		err = flatIncludes(includeDir, prefix, depMeta.String(), add)
+		if err != nil {
+			return
+		}
+	}
+	for _, includePath := range pcx.AllIncludePaths {
+		owner, errRel := filepath.Rel(pcx.Package.LocalPath, includePath)
+		if errRel != nil {
+			owner = includePath
+		}
+		err = flatIncludes(includePath, "", filepath.ToSlash(owner), add)
+		if err != nil {
+			return
+		}
+	}
+
+	var collisions []FlatCollision
+	for key, list := range owners {
+		if len(list) > 1 {
+			collisions = append(collisions, FlatCollision{File: filepath.ToSlash(planned[key].path), Owners: list})
+		}
+	}
+	if len(collisions) > 0 {
+		sort.Slice(collisions, func(i, j int) bool {
+			return collisions[i].File < collisions[j].File
+		})
+		lines := make([]string, len(collisions))
+		for i, collision := range collisions {
+			lines[i] = collision.String()
+		}
+		return 0, errors.Errorf("%d include files collide in the flat layout, alias or namespace the dependencies that provide them:\n%s", len(collisions), strings.Join(lines, "\n"))
+	}
+
+	err = prepareFlatDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, file := range planned {
+		if file.source == "" {
+			continue
+		}
+		target := filepath.Join(dir, file.path)
+		err = os.MkdirAll(filepath.Dir(target), 0700)
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to create flat include directory")
+		}
+		err = util.CopyFile(file.source, target)
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to copy %s of %s", file.path, file.owner)
+		}
+		files++
+	}
+	for _, meta := range aliases {
+		err = writeAliasShim(dir, meta, filepath.Join(dir, meta.Alias))
+		if err != nil {
+			return
+		}
+	}
+
+	print.Verb(pcx.Package, "put", files, "include files in", dir)
+	return
+}
+
+// flatIncludes adds the include files in a directory, keeping their subdirectories, under a prefix
+func flatIncludes(includeDir, prefix, owner string, add func(flatFile)) (err error) {
+	if !util.Exists(includeDir) {
+		return
+	}
+	err = filepath.Walk(includeDir, func(path string, info os.FileInfo, errInner error) error {
+		if errInner != nil {
+			return errInner
+		}
+		if info.IsDir() {
+			if path != includeDir && (strings.HasPrefix(info.Name(), ".") || info.Name() == "dependencies") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".inc" {
+			return nil
+		}
+		rel, errInner := filepath.Rel(includeDir, path)
+		if errInner != nil {
+			return errInner
+		}
+		add(flatFile{path: filepath.Join(prefix, rel), source: path, owner: owner})
+		return nil
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed to list include files of %s", owner)
+	}
+	return
+}
+
+// prepareFlatDir empties a flat include directory that sampctl created before, or creates it. Any
+// other directory that isn't empty is left alone.
+func prepareFlatDir(dir string) (err error) {
+	if util.Exists(filepath.Join(dir, flatMarker)) {
+		err = os.RemoveAll(dir)
+		if err != nil {
+			return errors.Wrap(err, "failed to remove previous flat include directory")
+		}
+	} else if contents, errRead := ioutil.ReadDir(dir); errRead == nil && len(contents) > 0 {
+		return errors.Errorf("%s is not empty and was not created by sampctl, choose another directory", dir)
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return errors.Wrap(err, "failed to create flat include directory")
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, flatMarker), []byte("generated by sampctl, this directory is replaced on every ensure\n"), 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write flat include directory marker")
+	}
+	return
+}
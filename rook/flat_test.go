@@ -0,0 +1,77 @@
+package rook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_EnsureFlat(t *testing.T) {
+	dir := util.FullPath("./tests/flat")
+	os.RemoveAll(dir)
+	write := func(file string) {
+		path := filepath.Join(dir, "dependencies", file)
+		os.MkdirAll(filepath.Dir(path), 0700)
+		ioutil.WriteFile(path, []byte("// "+file), 0600)
+	}
+	write("samp-stdlib/a_samp.inc")
+	write("samp-stdlib/README.md")
+	write("YSI/YSI_Coding/y_hooks.inc")
+	write("logger/include/logger.inc")
+	write("other-logger/logger.inc")
+
+	stdlib := versioning.DependencyMeta{User: "sampctl", Repo: "samp-stdlib"}
+	ysi := versioning.DependencyMeta{User: "pawn-lang", Repo: "YSI"}
+	logger := versioning.DependencyMeta{User: "Southclaws", Repo: "logger"}
+	otherLogger := versioning.DependencyMeta{User: "someone", Repo: "other-logger"}
+	pcx := PackageContext{
+		Package:         types.Package{LocalPath: dir, Vendor: filepath.Join(dir, "dependencies")},
+		CacheDir:        "./tests/cache",
+		AllDependencies: []versioning.DependencyMeta{stdlib, ysi, logger, otherLogger},
+	}
+	flat := filepath.Join(dir, "include")
+
+	_, err := pcx.EnsureFlat(flat)
+	assert.EqualError(t, err, "1 include files collide in the flat layout, alias or namespace the dependencies that provide them:\n"+
+		"logger.inc is provided by Southclaws/logger and someone/other-logger")
+	assert.False(t, util.Exists(flat))
+
+	// aliased dependencies are vendored under their alias
+	os.Rename(filepath.Join(dir, "dependencies", "other-logger"), filepath.Join(dir, "dependencies", "logger2"))
+	otherLogger.Alias = "logger2"
+	ysi.Namespace = "pawn-lang"
+	pcx.AllDependencies = []versioning.DependencyMeta{stdlib, ysi, logger, otherLogger}
+
+	files, err := pcx.EnsureFlat(flat)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, files)
+	assert.True(t, util.Exists(filepath.Join(flat, "a_samp.inc")))
+	assert.False(t, util.Exists(filepath.Join(flat, "README.md")))
+	assert.True(t, util.Exists(filepath.Join(flat, "pawn-lang", "YSI", "YSI_Coding", "y_hooks.inc")))
+	assert.True(t, util.Exists(filepath.Join(flat, "logger.inc")))
+	assert.True(t, util.Exists(filepath.Join(flat, "logger2", "logger.inc")))
+	shim, err := ioutil.ReadFile(filepath.Join(flat, "logger2.inc"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(shim), `#include "logger2/logger.inc"`)
+
+	// a second ensure replaces the directory it created
+	pcx.AllDependencies = []versioning.DependencyMeta{stdlib}
+	files, err = pcx.EnsureFlat(flat)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, files)
+	assert.False(t, util.Exists(filepath.Join(flat, "logger.inc")))
+
+	// but leaves any other directory alone
+	foreign := filepath.Join(dir, "pawno")
+	os.MkdirAll(foreign, 0700)
+	ioutil.WriteFile(filepath.Join(foreign, "a_samp.inc"), []byte("// mine"), 0600)
+	_, err = pcx.EnsureFlat(foreign)
+	assert.EqualError(t, err, foreign+" is not empty and was not created by sampctl, choose another directory")
+}
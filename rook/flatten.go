@@ -0,0 +1,261 @@
+package rook
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+)
+
+var (
+	matchInclude    = regexp.MustCompile(`^\s*#\s*(include|tryinclude)\s*([<"])([^>"]+)[>"]`)
+	matchGuardOpen  = regexp.MustCompile(`^#\s*if\s+defined\s*\(?\s*(\w+)\s*\)?$`)
+	matchGuardClose = regexp.MustCompile(`^#\s*endinput\b`)
+	matchEndif      = regexp.MustCompile(`^#\s*endif\b`)
+	matchNotSymbol  = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+// Flattened is a package's include files with every include that belongs to the package inlined
+type Flattened struct {
+	Contents   []byte
+	Inlined    []string // files from the package that were inlined, in order
+	External   []string // includes provided by dependencies, these are left as they are
+	Unresolved []string // includes that could not be found, these are left as they are
+}
+
+// Flatten combines the include files at the root of the package's include path into a single file
+// by inlining every `#include` that resolves to a file within the package. Each file is inlined at
+// most once and a conventional `#endinput` include guard at the top of a file is turned into a
+// condition around the whole file, since `#endinput` would otherwise end the combined file early.
+// Includes from dependencies are left in place so users of the single file can provide them. The
+// combined file has its own include guard so it can be included by more than one path.
+func (pcx *PackageContext) Flatten(ctx context.Context, build string) (result Flattened, err error) {
+	config, err := pcx.buildPrepare(ctx, build, false, false)
+	if err != nil {
+		err = errors.Wrap(err, "failed to prepare build config")
+		return
+	}
+
+	root := filepath.Join(pcx.Package.LocalPath, pcx.Package.IncludePath)
+	files, err := ioutil.ReadDir(root)
+	if err != nil {
+		err = errors.Wrap(err, "failed to read package include directory")
+		return
+	}
+
+	var roots []string
+	for _, file := range files {
+		if !file.IsDir() && filepath.Ext(file.Name()) == ".inc" {
+			roots = append(roots, filepath.Join(root, file.Name()))
+		}
+	}
+	if len(roots) == 0 {
+		err = errors.Errorf("no .inc files in %s", root)
+		return
+	}
+	sort.Strings(roots)
+
+	includes := append([]string{root}, config.Includes...)
+	result, err = flatten(roots, includes, pcx.Package.LocalPath, filepath.Join(pcx.Package.LocalPath, "dependencies"))
+	if err != nil {
+		return
+	}
+
+	symbol := flattenGuard(pcx.Package.Repo)
+	guard := fmt.Sprintf("#if defined %s\n\t#endinput\n#endif\n#define %s\n\n", symbol, symbol)
+	result.Contents = append([]byte(guard), result.Contents...)
+	return
+}
+
+// flattenGuard returns the include guard symbol of a package's flattened include file, symbols are
+// limited to 31 characters by the compiler
+func flattenGuard(repo string) string {
+	symbol := "_flat_" + matchNotSymbol.ReplaceAllString(repo, "_")
+	if len(symbol) > 31 {
+		symbol = symbol[:31]
+	}
+	return symbol
+}
+
+type flattener struct {
+	includes []string
+	local    string
+	vendor   string
+	visited  map[string]bool
+	reported map[string]bool
+	buf      bytes.Buffer
+	result   Flattened
+}
+
+func flatten(roots, includes []string, local, vendor string) (result Flattened, err error) {
+	f := flattener{
+		includes: includes,
+		local:    local,
+		vendor:   vendor,
+		visited:  make(map[string]bool),
+		reported: make(map[string]bool),
+	}
+
+	for _, root := range roots {
+		err = f.inline(root)
+		if err != nil {
+			return
+		}
+	}
+
+	f.result.Contents = f.buf.Bytes()
+	return f.result, nil
+}
+
+func (f *flattener) inline(file string) (err error) {
+	if f.visited[file] {
+		return
+	}
+	f.visited[file] = true
+
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", file)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err = scanner.Err(); err != nil {
+		return errors.Wrapf(err, "failed to read %s", file)
+	}
+
+	name, _ := filepath.Rel(f.local, file)
+	name = filepath.ToSlash(name)
+	f.result.Inlined = append(f.result.Inlined, name)
+	fmt.Fprintf(&f.buf, "// -\n// begin %s\n// -\n\n", name)
+
+	symbol, start, end, guarded := detectGuard(lines)
+	for i := 0; i < len(lines); i++ {
+		if guarded && i == start {
+			fmt.Fprintf(&f.buf, "#if !defined %s\n", symbol)
+			i = end
+			continue
+		}
+
+		line := lines[i]
+		match := matchInclude.FindStringSubmatch(line)
+		if match == nil {
+			f.buf.WriteString(line + "\n")
+			continue
+		}
+
+		quoted := match[2] == `"`
+		target, found := f.resolve(match[3], filepath.Dir(file), quoted)
+		switch {
+		case !found:
+			if match[1] == "include" && !f.reported[match[3]] {
+				print.Warn(name, "includes", match[3], "which could not be resolved, leaving it as-is")
+				f.result.Unresolved = append(f.result.Unresolved, match[3])
+			}
+			f.reported[match[3]] = true
+			f.buf.WriteString(line + "\n")
+		case !isWithin(target, f.local) || isWithin(target, f.vendor):
+			if !f.reported[match[3]] {
+				print.Verb(name, "includes", match[3], "from a dependency, leaving it as-is")
+				f.result.External = append(f.result.External, match[3])
+			}
+			f.reported[match[3]] = true
+			f.buf.WriteString(line + "\n")
+		default:
+			err = f.inline(target)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	if guarded {
+		f.buf.WriteString("#endif\n")
+	}
+	fmt.Fprintf(&f.buf, "\n// -\n// end %s\n// -\n\n", name)
+
+	return
+}
+
+func (f *flattener) resolve(include, dir string, quoted bool) (path string, found bool) {
+	return resolveInclude(f.includes, include, dir, quoted)
+}
+
+// resolveInclude finds an included file the same way the compiler does, quoted includes are searched
+// for next to the including file first and the extension is optional.
+func resolveInclude(includes []string, include, dir string, quoted bool) (path string, found bool) {
+	include = filepath.FromSlash(include)
+
+	search := includes
+	if quoted {
+		search = append([]string{dir}, search...)
+	}
+
+	for _, base := range search {
+		for _, ext := range []string{"", ".inc", ".p", ".pawn"} {
+			candidate := filepath.Join(base, include+ext)
+			info, err := os.Stat(candidate)
+			if err == nil && !info.IsDir() {
+				return candidate, true
+			}
+		}
+	}
+	return "", false
+}
+
+// detectGuard finds an include guard in the form of `#if defined X`, `#endinput`, `#endif` before
+// any code in the file and returns the guard symbol and the lines the guard spans.
+func detectGuard(lines []string) (symbol string, start, end int, ok bool) {
+	state := 0
+	comment := false
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if comment {
+			if strings.Contains(line, "*/") {
+				comment = false
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		if strings.HasPrefix(line, "/*") {
+			comment = !strings.Contains(line, "*/")
+			continue
+		}
+
+		switch state {
+		case 0:
+			match := matchGuardOpen.FindStringSubmatch(line)
+			if match == nil {
+				return
+			}
+			symbol, start, state = match[1], i, 1
+		case 1:
+			if !matchGuardClose.MatchString(line) {
+				return
+			}
+			state = 2
+		case 2:
+			if !matchEndif.MatchString(line) {
+				return
+			}
+			return symbol, start, i, true
+		}
+	}
+	return
+}
@@ -0,0 +1,128 @@
+package rook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestFlatten(t *testing.T) {
+	dir := util.FullPath("./tests/flatten")
+	os.RemoveAll(dir)
+
+	files := map[string]string{
+		"lib.inc": `// lib
+#include <a_samp>
+#include "internal/util"
+#include <internal/util.inc>
+#include <missing>
+#tryinclude <optional>
+
+stock Lib() {}
+`,
+		"internal/util.inc": `/*
+	utilities
+*/
+
+#if defined _util_included
+	#endinput
+#endif
+#define _util_included
+
+stock Util() {}
+`,
+		"dependencies/samp-stdlib/a_samp.inc": "native print(const string[]);\n",
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755) //nolint
+		err := ioutil.WriteFile(path, []byte(contents), 0644)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	vendor := filepath.Join(dir, "dependencies")
+	result, err := flatten(
+		[]string{filepath.Join(dir, "lib.inc")},
+		[]string{dir, filepath.Join(vendor, "samp-stdlib")},
+		dir,
+		vendor,
+	)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"lib.inc", "internal/util.inc"}, result.Inlined)
+	assert.Equal(t, []string{"a_samp"}, result.External)
+	assert.Equal(t, []string{"missing"}, result.Unresolved)
+	assert.Equal(t, `// -
+// begin lib.inc
+// -
+
+// lib
+#include <a_samp>
+// -
+// begin internal/util.inc
+// -
+
+/*
+	utilities
+*/
+
+#if !defined _util_included
+#define _util_included
+
+stock Util() {}
+#endif
+
+// -
+// end internal/util.inc
+// -
+
+#include <missing>
+#tryinclude <optional>
+
+stock Lib() {}
+
+// -
+// end lib.inc
+// -
+
+`, string(result.Contents))
+}
+
+func TestDetectGuard(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		symbol  string
+		start   int
+		end     int
+		guarded bool
+	}{
+		{"guard", []string{"#if defined _x_included", "\t#endinput", "#endif", "#define _x_included"}, "_x_included", 0, 2, true},
+		{"parens", []string{"// header", "", "#if defined(_x)", "#endinput", "#endif"}, "_x", 2, 4, true},
+		{"code first", []string{"stock X() {}", "#if defined _x", "#endinput", "#endif"}, "", 0, 0, false},
+		{"no endinput", []string{"#if defined _x", "#define y", "#endif"}, "", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			symbol, start, end, guarded := detectGuard(tt.lines)
+			assert.Equal(t, tt.guarded, guarded)
+			if guarded {
+				assert.Equal(t, tt.symbol, symbol)
+				assert.Equal(t, tt.start, start)
+				assert.Equal(t, tt.end, end)
+			}
+		})
+	}
+}
+
+func TestFlattenGuard(t *testing.T) {
+	assert.Equal(t, "_flat_samp_logger", flattenGuard("samp-logger"))
+	assert.Equal(t, "_flat_a_very_long_package_name_", flattenGuard("a-very-long-package-name-that-goes-on"))
+}
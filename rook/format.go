@@ -0,0 +1,30 @@
+package rook
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/types"
+)
+
+// Format rewrites the package definition file with canonical key ordering and indentation. The
+// definition is re-read from disk so that any defaults applied while loading the package context
+// are not written back into the file. The original format of the definition is preserved, as are
+// comments in YAML definitions.
+func Format(pkg types.Package) (err error) {
+	if pkg.LocalPath == "" {
+		return errors.New("package does not represent a locally stored package")
+	}
+
+	def, err := types.PackageFromDir(pkg.LocalPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to read package definition")
+	}
+	def.LocalPath = pkg.LocalPath
+
+	err = def.WriteCanonicalDefinition()
+	if err != nil {
+		return errors.Wrap(err, "failed to write package definition")
+	}
+
+	return
+}
@@ -0,0 +1,68 @@
+package rook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		contents string
+		want     string
+	}{
+		{"json", "pawn.json", `{"output":"test.amx",  "entry": "test.pwn",
+   "dependencies":["sampctl/samp-stdlib"], "user":"test","repo":"format"}`, `{
+	"user": "test",
+	"repo": "format",
+	"entry": "test.pwn",
+	"output": "test.amx",
+	"dependencies": [
+		"sampctl/samp-stdlib"
+	]
+}`},
+		{"yaml", "pawn.yaml", `output: test.amx
+dependencies:
+    - sampctl/samp-stdlib
+user: test
+repo:   format
+entry: test.pwn
+`, `user: test
+repo: format
+entry: test.pwn
+output: test.amx
+dependencies:
+- sampctl/samp-stdlib
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := util.FullPath("./tests/format-" + tt.name)
+			os.RemoveAll(dir)
+			os.MkdirAll(dir, 0755) //nolint
+
+			err := ioutil.WriteFile(filepath.Join(dir, tt.file), []byte(tt.contents), 0755)
+			if err != nil {
+				panic(err)
+			}
+
+			// format twice to ensure the operation is idempotent
+			for i := 0; i < 2; i++ {
+				err = Format(types.Package{LocalPath: dir})
+				assert.NoError(t, err)
+
+				got, err := ioutil.ReadFile(filepath.Join(dir, tt.file))
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, string(got))
+			}
+		})
+	}
+}
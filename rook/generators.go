@@ -0,0 +1,125 @@
+package rook
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+// runGenerators runs the code generators of a build that are out of date. A generator is up to date
+// if all of its outputs exist and the command and the contents of its inputs are the same as the
+// last time it succeeded, which is recorded in the cache. Generator output is captured and shown if
+// it fails, which fails the build.
+func (pcx *PackageContext) runGenerators(ctx context.Context, generators []types.Generator) (err error) {
+	for _, generator := range generators {
+		if len(generator.Command) == 0 {
+			return errors.New("generator has no command")
+		}
+		name := strings.Join(generator.Command, " ")
+
+		var sum string
+		sum, err = generatorSum(pcx.Package.LocalPath, generator)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check inputs of generator %s", name)
+		}
+
+		stamp := filepath.Join(pcx.CacheDir, "generators", stampName(pcx.Package.LocalPath, name))
+		if previous, errRead := ioutil.ReadFile(stamp); errRead == nil && string(previous) == sum &&
+			outputsExist(pcx.Package.LocalPath, generator.Outputs) {
+			print.Verb("generator", name, "is up to date")
+			continue
+		}
+
+		print.Verb("running generator", name)
+		var output bytes.Buffer
+		cmd := exec.CommandContext(ctx, generator.Command[0], generator.Command[1:]...) //nolint:gas
+		cmd.Dir = pcx.Package.LocalPath
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		err = cmd.Run()
+		if err != nil {
+			print.Erro("Generator", name, "failed:")
+			io.Copy(os.Stdout, &output) // nolint
+			return errors.Wrapf(err, "generator %s failed", name)
+		}
+		if output.Len() > 0 {
+			print.Verb(strings.TrimSpace(output.String()))
+		}
+
+		for _, out := range generator.Outputs {
+			if !util.Exists(filepath.Join(pcx.Package.LocalPath, out)) {
+				return errors.Errorf("generator %s did not write %s", name, out)
+			}
+		}
+
+		err = os.MkdirAll(filepath.Dir(stamp), 0700)
+		if err == nil {
+			err = ioutil.WriteFile(stamp, []byte(sum), 0600)
+		}
+		if err != nil {
+			print.Warn("Failed to record generator state, it will run again next time:", err)
+			err = nil
+		}
+	}
+	return
+}
+
+// generatorSum hashes the command of a generator and the names and contents of its inputs
+func generatorSum(dir string, generator types.Generator) (sum string, err error) {
+	var inputs []string
+	for _, pattern := range generator.Inputs {
+		var matches []string
+		matches, err = filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid input pattern %s", pattern)
+		}
+		if len(matches) == 0 {
+			return "", errors.Errorf("input %s does not exist", pattern)
+		}
+		inputs = append(inputs, matches...)
+	}
+	sort.Strings(inputs)
+
+	hash := sha256.New()
+	fmt.Fprintln(hash, strings.Join(generator.Command, "\x00"))
+	for _, input := range inputs {
+		var contents []byte
+		contents, err = ioutil.ReadFile(input)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to read input %s", input)
+		}
+		rel, _ := filepath.Rel(dir, input)
+		fmt.Fprintln(hash, filepath.ToSlash(rel), len(contents))
+		hash.Write(contents) // nolint
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func stampName(dir, command string) string {
+	sum := sha256.Sum256([]byte(util.FullPath(dir) + "\x00" + command))
+	return hex.EncodeToString(sum[:16])
+}
+
+func outputsExist(dir string, outputs []string) bool {
+	for _, out := range outputs {
+		if !util.Exists(filepath.Join(dir, out)) {
+			return false
+		}
+	}
+	return true
+}
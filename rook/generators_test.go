@@ -0,0 +1,77 @@
+package rook
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestRunGenerators(t *testing.T) {
+	dir := util.FullPath("./tests/generators")
+	os.RemoveAll(dir)
+	os.MkdirAll(dir, 0755) //nolint
+
+	err := ioutil.WriteFile(filepath.Join(dir, "input.txt"), []byte("one"), 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	pcx := &PackageContext{
+		Package:  types.Package{LocalPath: dir},
+		CacheDir: filepath.Join(dir, "cache"),
+	}
+	generators := []types.Generator{{
+		Command: []string{"sh", "-c", "cat input.txt >> output.inc && echo x >> runs"},
+		Inputs:  []string{"*.txt"},
+		Outputs: []string{"output.inc"},
+	}}
+	runs := func() string {
+		contents, _ := ioutil.ReadFile(filepath.Join(dir, "runs"))
+		return string(contents)
+	}
+
+	// runs the first time
+	err = pcx.runGenerators(context.Background(), generators)
+	assert.NoError(t, err)
+	assert.Equal(t, "x\n", runs())
+	assert.True(t, util.Exists(filepath.Join(dir, "output.inc")))
+
+	// skipped when nothing changed
+	err = pcx.runGenerators(context.Background(), generators)
+	assert.NoError(t, err)
+	assert.Equal(t, "x\n", runs())
+
+	// runs again when an input changes
+	err = ioutil.WriteFile(filepath.Join(dir, "input.txt"), []byte("two"), 0644)
+	if err != nil {
+		panic(err)
+	}
+	err = pcx.runGenerators(context.Background(), generators)
+	assert.NoError(t, err)
+	assert.Equal(t, "x\nx\n", runs())
+
+	// runs again when an output is missing
+	os.Remove(filepath.Join(dir, "output.inc")) //nolint
+	err = pcx.runGenerators(context.Background(), generators)
+	assert.NoError(t, err)
+	assert.Equal(t, "x\nx\nx\n", runs())
+
+	// a failing command fails
+	err = pcx.runGenerators(context.Background(), []types.Generator{{Command: []string{"sh", "-c", "exit 1"}}})
+	assert.Error(t, err)
+
+	// as does one that doesn't write its outputs
+	err = pcx.runGenerators(context.Background(), []types.Generator{{Command: []string{"true"}, Outputs: []string{"never.inc"}}})
+	assert.Error(t, err)
+
+	// and a missing input
+	err = pcx.runGenerators(context.Background(), []types.Generator{{Command: []string{"true"}, Inputs: []string{"missing.txt"}}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,172 @@
+package rook
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+)
+
+// IncludeCaseMismatch is an include directive that only finds its file when case is ignored. It
+// works on Windows and macOS, where file systems are case-insensitive, but not on Linux.
+type IncludeCaseMismatch struct {
+	File    string // the file with the include directive
+	Line    int    // the line of the directive, starting at 1
+	Include string // the include name as it's written
+	Actual  string // the path of the file that it matches, as it is named on disk
+}
+
+func (icm IncludeCaseMismatch) String() string {
+	return fmt.Sprintf("%s:%d: include %s only matches %s when case is ignored, it won't be found on Linux",
+		icm.File, icm.Line, icm.Include, icm.Actual)
+}
+
+// warnIncludeCase warns about the includes of a prepared build config whose case doesn't match the
+// files they resolve to
+func (pcx *PackageContext) warnIncludeCase(config *types.BuildConfig) {
+	includes := make([]string, len(config.Includes))
+	for i, inc := range config.Includes {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(pcx.Package.LocalPath, inc)
+		}
+		includes[i] = inc
+	}
+
+	mismatches, err := IncludeCaseMismatches(config.Input, includes)
+	if err != nil {
+		print.Verb(pcx.Package, "failed to check the case of includes:", err)
+		return
+	}
+	for _, mismatch := range mismatches {
+		print.Warn(mismatch)
+	}
+}
+
+// IncludeCaseMismatches follows every include from an entry script, the same way `includeSet` does,
+// and lists those that only resolve when case is ignored. Files are compared against the names in
+// their directories, so the result is the same on every platform.
+func IncludeCaseMismatches(entry string, includes []string) (mismatches []IncludeCaseMismatch, err error) {
+	var (
+		listings = make(map[string][]string)
+		visited  = make(map[string]bool)
+		pending  = []string{entry}
+	)
+	for len(pending) > 0 {
+		file := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if visited[strings.ToLower(file)] {
+			continue
+		}
+		visited[strings.ToLower(file)] = true
+
+		var f *os.File
+		f, err = os.Open(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", file)
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			match := matchInclude.FindStringSubmatch(scanner.Text())
+			if match == nil {
+				continue
+			}
+			target, exact, ok := resolveIncludeFold(listings, includes, match[3], filepath.Dir(file), match[2] == `"`)
+			if !ok {
+				continue
+			}
+			if !exact {
+				mismatches = append(mismatches, IncludeCaseMismatch{
+					File:    file,
+					Line:    line,
+					Include: match[3],
+					Actual:  target,
+				})
+			}
+			pending = append(pending, target)
+		}
+		err = scanner.Err()
+		f.Close() // nolint
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", file)
+		}
+	}
+	return
+}
+
+// resolveIncludeFold resolves an include like `resolveInclude` but ignores case, `exact` is false
+// when the path on disk is cased differently to the include. Directory listings are kept in
+// `listings` since the same directories are searched for every include.
+func resolveIncludeFold(listings map[string][]string, includes []string, include, dir string, quoted bool) (path string, exact, found bool) {
+	search := includes
+	if quoted {
+		search = append([]string{dir}, search...)
+	}
+
+	// the compiler accepts either separator, so an include written on Windows may use backslashes
+	parts := strings.Split(strings.Replace(include, `\`, "/", -1), "/")
+	for _, base := range search {
+		for _, ext := range []string{"", ".inc", ".p", ".pawn"} {
+			names := append(append([]string{}, parts[:len(parts)-1]...), parts[len(parts)-1]+ext)
+			if path, exact, found = matchPathFold(listings, base, names); found {
+				return
+			}
+		}
+	}
+	return "", false, false
+}
+
+// matchPathFold follows path components from a directory, preferring an entry with the same case
+// for each one, and finds a file at the end
+func matchPathFold(listings map[string][]string, dir string, names []string) (path string, exact, found bool) {
+	path, exact = dir, true
+	for i, name := range names {
+		if name == "." || name == "" {
+			continue
+		}
+		if name == ".." {
+			path = filepath.Dir(path)
+			continue
+		}
+
+		entries, ok := listings[path]
+		if !ok {
+			infos, _ := ioutil.ReadDir(path) // nolint
+			for _, info := range infos {
+				entries = append(entries, info.Name())
+			}
+			listings[path] = entries
+		}
+
+		match := ""
+		for _, entry := range entries {
+			if entry == name {
+				match = entry
+				break
+			}
+			if match == "" && strings.EqualFold(entry, name) {
+				match = entry
+			}
+		}
+		if match == "" {
+			return "", false, false
+		}
+		exact = exact && match == name
+		path = filepath.Join(path, match)
+
+		if i == len(names)-1 {
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() {
+				return "", false, false
+			}
+		}
+	}
+	return path, exact, true
+}
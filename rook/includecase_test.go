@@ -0,0 +1,42 @@
+package rook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestIncludeCaseMismatches(t *testing.T) {
+	dir := util.FullPath("./tests/include-case")
+	os.RemoveAll(dir)
+	write := func(file, contents string) {
+		path := filepath.Join(dir, file)
+		os.MkdirAll(filepath.Dir(path), 0700)
+		ioutil.WriteFile(path, []byte(contents), 0600)
+	}
+	write("main.pwn", "#include <a_samp>\n#include <Logger>\n\n#include \"Utils/Strings.inc\"\n#tryinclude <missing>\n")
+	write("utils/strings.inc", "#include <YSI_Coding/y_hooks>\n")
+	write("dependencies/samp-stdlib/a_samp.inc", "")
+	write("dependencies/logger/logger.inc", "#include <a_samp>\n")
+	write("dependencies/YSI/YSI_Coding/y_hooks.inc", "")
+
+	includes := []string{
+		filepath.Join(dir, "dependencies", "samp-stdlib"),
+		filepath.Join(dir, "dependencies", "logger"),
+		filepath.Join(dir, "dependencies", "YSI"),
+	}
+	mismatches, err := IncludeCaseMismatches(filepath.Join(dir, "main.pwn"), includes)
+	assert.NoError(t, err)
+	assert.Equal(t, []IncludeCaseMismatch{
+		{File: filepath.Join(dir, "main.pwn"), Line: 2, Include: "Logger", Actual: filepath.Join(dir, "dependencies", "logger", "logger.inc")},
+		{File: filepath.Join(dir, "main.pwn"), Line: 4, Include: "Utils/Strings.inc", Actual: filepath.Join(dir, "utils", "strings.inc")},
+	}, mismatches)
+	assert.Equal(t, filepath.Join(dir, "main.pwn")+":2: include Logger only matches "+
+		filepath.Join(dir, "dependencies", "logger", "logger.inc")+" when case is ignored, it won't be found on Linux",
+		mismatches[0].String())
+}
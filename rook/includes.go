@@ -0,0 +1,84 @@
+package rook
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/types"
+)
+
+// MissingInclude is an include directory that would be passed to the compiler but doesn't exist
+type MissingInclude struct {
+	Dir   string // the include directory as it would be passed to the compiler
+	Owner string // the dependency the directory belongs to, or the build config that declares it
+}
+
+func (mi MissingInclude) String() string {
+	return fmt.Sprintf("include directory %s from %s is missing", mi.Dir, mi.Owner)
+}
+
+// validateIncludes checks that every include directory of a prepared build config exists. Without
+// this, a mistyped include directory or a dependency that moved its includes only shows up as a
+// cascade of undefined symbol errors from the compiler. Includes whose case doesn't match the file
+// they resolve to are warned about, since they only break once the package is built on Linux.
+func (pcx *PackageContext) validateIncludes(config *types.BuildConfig) (err error) {
+	missing := pcx.missingIncludes(config)
+	if len(missing) == 0 {
+		pcx.warnIncludeCase(config)
+		return
+	}
+
+	lines := make([]string, len(missing))
+	for i, m := range missing {
+		lines[i] = m.String()
+	}
+	return errors.Errorf("%d include directories do not exist, check the include paths or run ensure if dependencies are missing:\n%s",
+		len(missing), strings.Join(lines, "\n"))
+}
+
+// missingIncludes lists the include directories of a build config that don't exist, relative
+// directories are resolved against the package directory the same way the compiler resolves them
+func (pcx *PackageContext) missingIncludes(config *types.BuildConfig) (missing []MissingInclude) {
+	seen := make(map[string]bool)
+	for _, inc := range config.Includes {
+		dir := inc
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(pcx.Package.LocalPath, dir)
+		}
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+
+		if info, errStat := os.Stat(dir); errStat == nil && info.IsDir() {
+			continue
+		}
+		missing = append(missing, MissingInclude{Dir: inc, Owner: pcx.includeOwner(dir, config)})
+	}
+	return
+}
+
+// includeOwner describes where an include directory comes from: a vendored dependency, the resources
+// of a dependency or otherwise the build config itself
+func (pcx *PackageContext) includeOwner(dir string, config *types.BuildConfig) string {
+	rel, err := filepath.Rel(pcx.Package.Vendor, dir)
+	if err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		for _, meta := range pcx.AllDependencies {
+			if parts[0] == meta.VendorName() {
+				return fmt.Sprintf("dependency %s", meta)
+			}
+			if parts[0] == ".resources" && len(parts) > 1 && strings.HasPrefix(parts[1], meta.Repo+"-") {
+				return fmt.Sprintf("resources of dependency %s", meta)
+			}
+		}
+	}
+	if config.Name != "" {
+		return fmt.Sprintf("build config %s", config.Name)
+	}
+	return "the build config"
+}
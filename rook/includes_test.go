@@ -0,0 +1,50 @@
+package rook
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_validateIncludes(t *testing.T) {
+	dir := util.FullPath("./tests/includes")
+	os.RemoveAll(dir)
+	vendor := filepath.Join(dir, "dependencies")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "include"), 0700))
+	assert.NoError(t, os.MkdirAll(filepath.Join(vendor, "samp-logger"), 0700))
+	assert.NoError(t, os.MkdirAll(filepath.Join(vendor, "samp-errors"), 0700))
+
+	logger := versioning.DependencyMeta{User: "Southclaws", Repo: "samp-logger"}
+	errs := versioning.DependencyMeta{User: "Southclaws", Repo: "samp-errors"}
+	streamer := versioning.DependencyMeta{User: "samp-incognito", Repo: "samp-streamer-plugin"}
+	pcx := PackageContext{
+		Package:         types.Package{LocalPath: dir, Vendor: vendor},
+		AllDependencies: []versioning.DependencyMeta{logger, errs, streamer},
+	}
+
+	config := &types.BuildConfig{Name: "main", Includes: []string{
+		"include",
+		filepath.Join(vendor, "samp-logger"),
+		filepath.Join(vendor, "samp-errors", "inc"),
+		filepath.Join(vendor, ".resources", "samp-streamer-plugin-abcdef"),
+		"inclde",
+	}}
+	assert.Equal(t, []MissingInclude{
+		{Dir: filepath.Join(vendor, "samp-errors", "inc"), Owner: "dependency Southclaws/samp-errors"},
+		{Dir: filepath.Join(vendor, ".resources", "samp-streamer-plugin-abcdef"), Owner: "resources of dependency samp-incognito/samp-streamer-plugin"},
+		{Dir: "inclde", Owner: "build config main"},
+	}, pcx.missingIncludes(config))
+
+	err := pcx.validateIncludes(config)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "include directory inclde from build config main is missing")
+
+	config.Includes = config.Includes[:2]
+	assert.NoError(t, pcx.validateIncludes(config))
+}
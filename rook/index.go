@@ -0,0 +1,224 @@
+package rook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// indexSource is the URL or file path of the package index, when empty the built-in index is used
+var indexSource string
+
+// SetIndex configures where the package index that search and include suggestions use is read
+// from, either an HTTP(S) URL or the path of a file. An empty source means the built-in index of
+// well known packages is used.
+func SetIndex(source string) (err error) {
+	if source == "" {
+		indexSource = ""
+		return
+	}
+	if u, errParse := url.Parse(source); errParse == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		if u.Host == "" {
+			return errors.Errorf("index URL %s has no host", source)
+		}
+		indexSource = source
+		return
+	}
+	indexSource = util.FullPath(source)
+	return
+}
+
+// LoadIndex reads the package index. An index from a URL is cached and only downloaded again once
+// a day, if that fails the cached copy is used so search keeps working offline.
+func LoadIndex(ctx context.Context, cacheDir string) (index types.Index, err error) {
+	if indexSource == "" {
+		return BuiltinIndex(), nil
+	}
+
+	contents, err := readFeed(ctx, cacheDir, "index", indexSource)
+	if err != nil {
+		return index, errors.Wrapf(err, "failed to read package index %s", indexSource)
+	}
+
+	err = json.Unmarshal(contents, &index)
+	if err != nil {
+		return index, errors.Wrapf(err, "failed to decode package index %s", indexSource)
+	}
+	for i, entry := range index.Packages {
+		if entry.User == "" || entry.Repo == "" {
+			return index, errors.Errorf("package %d in index %s has no user or repo", i, indexSource)
+		}
+	}
+	return
+}
+
+// readFeed reads a JSON document that is either a file or at an HTTP(S) URL. A document from a URL
+// is cached in a directory of the cache directory and only downloaded again once it's a day old,
+// if that fails the cached copy is used so it keeps working offline.
+func readFeed(ctx context.Context, cacheDir, kind, source string) (contents []byte, err error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return ioutil.ReadFile(source)
+	}
+
+	hash := sha256.Sum256([]byte(source))
+	filename := filepath.Join(cacheDir, kind, hex.EncodeToString(hash[:8])+".json")
+
+	info, errStat := os.Stat(filename)
+	if errStat == nil && time.Since(info.ModTime()) < time.Hour*24 {
+		return ioutil.ReadFile(filename)
+	}
+
+	contents, err = downloadFeed(ctx, source)
+	if err != nil {
+		if errStat != nil {
+			return
+		}
+		print.Warn("failed to update", kind, "from", source+", using the cached copy:", err)
+		return ioutil.ReadFile(filename)
+	}
+
+	err = os.MkdirAll(filepath.Dir(filename), 0700)
+	if err == nil {
+		err = ioutil.WriteFile(filename, contents, 0600)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to cache %s", kind)
+	}
+	return
+}
+
+func downloadFeed(ctx context.Context, location string) (contents []byte, err error) {
+	req, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		return
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to download %s", location)
+	}
+	defer resp.Body.Close() // nolint
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("%s status %s", location, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// BuiltinIndex is the index of well known packages that is used when no index is configured, it
+// only has the packages that includes can be suggested for
+func BuiltinIndex() (index types.Index) {
+	entries := make(map[versioning.DependencyString]*types.IndexEntry)
+	for pattern, dep := range IncludesToDependencies {
+		entry, ok := entries[dep]
+		if !ok {
+			parts := strings.SplitN(string(dep), "/", 2)
+			entry = &types.IndexEntry{User: parts[0], Repo: parts[1]}
+			entries[dep] = entry
+		}
+		entry.Includes = append(entry.Includes, pattern)
+	}
+	for _, entry := range entries {
+		sort.Strings(entry.Includes)
+		index.Packages = append(index.Packages, *entry)
+	}
+	sort.Slice(index.Packages, func(i, j int) bool {
+		return strings.ToLower(index.Packages[i].User+"/"+index.Packages[i].Repo) <
+			strings.ToLower(index.Packages[j].User+"/"+index.Packages[j].Repo)
+	})
+	return
+}
+
+// IndexIncludes maps the include patterns of the packages in an index to their dependency strings
+func IndexIncludes(index types.Index) (includes map[string]versioning.DependencyString) {
+	includes = make(map[string]versioning.DependencyString)
+	for _, entry := range index.Packages {
+		for _, pattern := range entry.Includes {
+			includes[pattern] = versioning.DependencyString(entry.User + "/" + entry.Repo)
+		}
+	}
+	return
+}
+
+// SearchIndex returns the packages in an index that match every word of a query, best matches
+// first. A word matches the user, repository, a tag or the description of a package, and also
+// matches a repository that contains its letters in order, so `strmr` finds `streamer`.
+func SearchIndex(index types.Index, query string) (results []types.IndexEntry) {
+	words := strings.Fields(strings.ToLower(query))
+	scores := make(map[int]int)
+	var matched []int
+	for i, entry := range index.Packages {
+		total := 0
+		for _, word := range words {
+			score := searchScore(entry, word)
+			if score == 0 {
+				total = 0
+				break
+			}
+			total += score
+		}
+		if total > 0 {
+			scores[i] = total
+			matched = append(matched, i)
+		}
+	}
+
+	sort.SliceStable(matched, func(a, b int) bool {
+		return scores[matched[a]] > scores[matched[b]]
+	})
+	for _, i := range matched {
+		results = append(results, index.Packages[i])
+	}
+	return
+}
+
+// searchScore rates how well a lower case word matches a package, zero means it doesn't match
+func searchScore(entry types.IndexEntry, word string) int {
+	repo := strings.ToLower(entry.Repo)
+	switch {
+	case repo == word:
+		return 100
+	case strings.Contains(repo, word):
+		return 50
+	}
+	for _, tag := range entry.Tags {
+		if strings.ToLower(tag) == word {
+			return 30
+		}
+	}
+	switch {
+	case strings.Contains(strings.ToLower(entry.User), word):
+		return 20
+	case strings.Contains(strings.ToLower(entry.Description), word):
+		return 10
+	case isSubsequence(word, repo):
+		return 5
+	}
+	return 0
+}
+
+// isSubsequence is true if every character of a appears in b in the same order
+func isSubsequence(a, b string) bool {
+	i := 0
+	for j := 0; i < len(a) && j < len(b); j++ {
+		if a[i] == b[j] {
+			i++
+		}
+	}
+	return i == len(a)
+}
@@ -0,0 +1,86 @@
+package rook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+const testIndex = `{"packages": [
+	{"user": "corp", "repo": "samp-accounts", "description": "player accounts", "tags": ["mysql"], "includes": ["accounts"]},
+	{"user": "corp", "repo": "streamer", "description": "approved streamer fork", "includes": ["streamer"]},
+	{"user": "corp", "repo": "mysql-utils", "description": "helpers for the MySQL plugin"}
+]}`
+
+func TestLoadIndex(t *testing.T) {
+	dir := util.FullPath("./tests/index")
+	os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+	defer SetIndex("") // nolint
+
+	assert.NoError(t, SetIndex(""))
+	index, err := LoadIndex(context.Background(), dir)
+	assert.NoError(t, err)
+	assert.Equal(t, BuiltinIndex(), index)
+	assert.Contains(t, index.Packages, types.IndexEntry{User: "Southclaws", Repo: "zcmd", Includes: []string{"zcmd"}})
+
+	filename := filepath.Join(dir, "index.json")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(testIndex), 0600))
+	assert.NoError(t, SetIndex(filename))
+	index, err = LoadIndex(context.Background(), dir)
+	assert.NoError(t, err)
+	assert.Len(t, index.Packages, 3)
+	assert.Equal(t, map[string]versioning.DependencyString{
+		"accounts": "corp/samp-accounts",
+		"streamer": "corp/streamer",
+	}, IndexIncludes(index))
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, testIndex)
+	}))
+	assert.NoError(t, SetIndex(server.URL+"/index.json"))
+	remote, err := LoadIndex(context.Background(), filepath.Join(dir, "cache"))
+	assert.NoError(t, err)
+	assert.Equal(t, index, remote)
+
+	// the cached index is used until it's a day old, and when the server can't be reached
+	server.Close()
+	remote, err = LoadIndex(context.Background(), filepath.Join(dir, "cache"))
+	assert.NoError(t, err)
+	assert.Equal(t, index, remote)
+	assert.Equal(t, 1, requests)
+
+	assert.Error(t, SetIndex("https:///index.json"))
+}
+
+func TestSearchIndex(t *testing.T) {
+	var index types.Index
+	assert.NoError(t, json.Unmarshal([]byte(testIndex), &index))
+
+	names := func(results []types.IndexEntry) (names []string) {
+		for _, entry := range results {
+			names = append(names, entry.User+"/"+entry.Repo)
+		}
+		return
+	}
+
+	assert.Equal(t, []string{"corp/streamer"}, names(SearchIndex(index, "Streamer")))
+	assert.Equal(t, []string{"corp/mysql-utils", "corp/samp-accounts"}, names(SearchIndex(index, "mysql")))
+	assert.Equal(t, []string{"corp/samp-accounts"}, names(SearchIndex(index, "accnts")))
+	assert.Equal(t, []string{"corp/streamer"}, names(SearchIndex(index, "corp fork")))
+	assert.Empty(t, SearchIndex(index, "streamer accounts"))
+}
@@ -8,11 +8,12 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"text/template"
 
-	"github.com/fatih/color"
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
 	"gopkg.in/AlecAivazis/survey.v1"
@@ -40,6 +41,7 @@ type Answers struct {
 	Travis        bool
 	EntryGenerate bool
 	Entry         string
+	IncludePath   string
 }
 
 // Init prompts the user to initialise a package
@@ -86,7 +88,7 @@ func Init(ctx context.Context, gh *github.Client, dir string, config *types.Conf
 		return
 	}
 
-	color.Green("Found %d pwn files and %d inc files.", len(pwnFiles), len(incFiles))
+	print.Info(fmt.Sprintf("Found %d pwn files and %d inc files.", len(pwnFiles), len(incFiles)))
 
 	var questions = []*survey.Question{
 		{
@@ -148,11 +150,13 @@ func Init(ctx context.Context, gh *github.Client, dir string, config *types.Conf
 	}
 
 	if len(pwnFiles) > 0 {
+		pwnFiles = rankEntries(dir, pwnFiles, dirName)
 		questions = append(questions, &survey.Question{
 			Name: "Entry",
 			Prompt: &survey.Select{
 				Message: "Choose an entry point - this is the file that is passed to the compiler.",
 				Options: pwnFiles,
+				Default: pwnFiles[0],
 			},
 			Validate: survey.Required,
 		})
@@ -176,6 +180,18 @@ func Init(ctx context.Context, gh *github.Client, dir string, config *types.Conf
 		}
 	}
 
+	// the include path is only asked for if the include files are in a conventional subdirectory,
+	// the answer is prefilled with it and can be cleared if the package doesn't need one
+	if includePath := DetectIncludePath(dir); includePath != "" {
+		questions = append(questions, &survey.Question{
+			Name: "IncludePath",
+			Prompt: &survey.Input{
+				Message: "Include path - the directory that contains the .inc files of your package.",
+				Default: includePath,
+			},
+		})
+	}
+
 	answers := Answers{}
 	err = survey.Ask(questions, &answers)
 	if err != nil {
@@ -187,9 +203,10 @@ func Init(ctx context.Context, gh *github.Client, dir string, config *types.Conf
 	}
 
 	pkg := types.Package{
-		Parent:    true,
-		LocalPath: dir,
-		Format:    answers.Format,
+		Parent:      true,
+		LocalPath:   dir,
+		Format:      answers.Format,
+		IncludePath: filepath.ToSlash(answers.IncludePath),
 		DependencyMeta: versioning.DependencyMeta{
 			User: answers.User,
 			Repo: answers.Repo,
@@ -209,19 +226,9 @@ func Init(ctx context.Context, gh *github.Client, dir string, config *types.Conf
 		}
 	} else {
 		if answers.EntryGenerate {
-			buf := bytes.Buffer{}
-
-			buf.WriteString(`// generated by "sampctl package generate"`)
-			buf.WriteString("\n\n")
-			for _, inc := range incFiles {
-				buf.WriteString(fmt.Sprintf(`#include "%s"%s`, filepath.Base(inc), "\n"))
-			}
-			buf.WriteString("\nmain() {\n")
-			buf.WriteString(`	// write tests for libraries here and run "sampctl package run"`)
-			buf.WriteString("\n}\n")
-			err = ioutil.WriteFile(filepath.Join(dir, "test.pwn"), buf.Bytes(), 0600)
+			err = ioutil.WriteFile(filepath.Join(dir, "test.pwn"), generateEntry(dir, incFiles), 0600)
 			if err != nil {
-				color.Red("failed to write generated tests.pwn file: %v", err)
+				print.Erro("failed to write generated tests.pwn file:", err)
 			}
 		}
 		pkg.Entry = "test.pwn"
@@ -287,7 +294,12 @@ func Init(ctx context.Context, gh *github.Client, dir string, config *types.Conf
 	}
 
 	if answers.Scan {
-		pkg.Dependencies = append(pkg.Dependencies, FindIncludes(incFiles)...)
+		index, errIndex := LoadIndex(ctx, cacheDir)
+		if errIndex != nil {
+			print.Warn("Failed to load package index, using the built-in index:", errIndex)
+			index = BuiltinIndex()
+		}
+		pkg.Dependencies = append(pkg.Dependencies, FindIndexedIncludes(incFiles, IndexIncludes(index))...)
 	}
 
 	if answers.Git {
@@ -317,7 +329,7 @@ func Init(ctx context.Context, gh *github.Client, dir string, config *types.Conf
 
 	wg.Wait()
 
-	pcx, err := NewPackageContext(gh, auth, true, dir, platform, cacheDir, "")
+	pcx, err := NewPackageContext(ctx, gh, auth, true, dir, platform, cacheDir, "")
 	if err != nil {
 		return
 	}
@@ -378,6 +390,51 @@ func getTemplateFile(dir, filename string, answers Answers) (err error) {
 	return
 }
 
+// matchMain matches the declaration of the script entry point function, `main()`
+var matchMain = regexp.MustCompile(`(?m)^\s*main\s*\(\s*\)`)
+
+// rankEntries orders candidate entry scripts so the most likely gamemode comes first. A script that
+// declares `main()` ranks highest, followed by one named after the package, then the longest file
+// since test scripts are usually small. The original order is kept between equally ranked files.
+func rankEntries(dir string, files []string, name string) (ranked []string) {
+	type candidate struct {
+		file  string
+		main  bool
+		named bool
+		lines int
+	}
+
+	candidates := make([]candidate, len(files))
+	for i, file := range files {
+		candidates[i].file = file
+		candidates[i].named = strings.EqualFold(strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)), name)
+
+		contents, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			print.Verb("failed to read", file, "to rank entry points:", err)
+			continue
+		}
+		candidates[i].main = matchMain.Match(contents)
+		candidates[i].lines = bytes.Count(contents, []byte("\n")) + 1
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.main != b.main {
+			return a.main
+		}
+		if a.named != b.named {
+			return a.named
+		}
+		return a.lines > b.lines
+	})
+
+	for _, c := range candidates {
+		ranked = append(ranked, c.file)
+	}
+	return
+}
+
 func validateUser(ans interface{}) (err error) {
 	if strings.ContainsAny(ans.(string), ` :;/\\~`) {
 		return errors.New("Contains invalid characters")
@@ -412,3 +469,51 @@ func doTemplate(input string, answers Answers) (output string, err error) {
 	output = out.String()
 	return
 }
+
+// generateEntry creates a test script that includes the include files of a library. An include file
+// that another one already includes, directly or through other includes, is left out so nothing is
+// included twice, which would cause redefinition errors for files without include guards.
+func generateEntry(dir string, incFiles []string) []byte {
+	reach := make(map[string]map[string]bool)
+	for _, inc := range incFiles {
+		path := filepath.Join(dir, inc)
+		files, err := includeSet(path, []string{dir})
+		if err != nil {
+			print.Verb("failed to find includes of", inc, err)
+			files = map[string]bool{path: true}
+		}
+		reach[inc] = files
+	}
+
+	// files that include the most go first so they cover the files they include, files that include
+	// each other reach the same files so the first of them is kept
+	ordered := append([]string(nil), incFiles...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return len(reach[ordered[i]]) > len(reach[ordered[j]])
+	})
+	covered := make(map[string]bool)
+	kept := make(map[string]bool)
+	for _, inc := range ordered {
+		if covered[filepath.Join(dir, inc)] {
+			print.Verb("not including", inc, "in the generated entry, it's already included by another file")
+			continue
+		}
+		kept[inc] = true
+		for file := range reach[inc] {
+			covered[file] = true
+		}
+	}
+
+	buf := bytes.Buffer{}
+	buf.WriteString(`// generated by "sampctl package generate"`)
+	buf.WriteString("\n\n")
+	for _, inc := range incFiles {
+		if kept[inc] {
+			buf.WriteString(fmt.Sprintf(`#include "%s"%s`, filepath.ToSlash(inc), "\n"))
+		}
+	}
+	buf.WriteString("\nmain() {\n")
+	buf.WriteString(`	// write tests for libraries here and run "sampctl package run"`)
+	buf.WriteString("\n}\n")
+	return buf.Bytes()
+}
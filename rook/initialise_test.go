@@ -0,0 +1,85 @@
+package rook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/util"
+)
+
+func Test_rankEntries(t *testing.T) {
+	dir := util.FullPath("./tests/entry-rank")
+	os.RemoveAll(dir)
+
+	files := map[string]string{
+		"gamemodes/rivershell.pwn": "#include <a_samp>\n\nmain()\n{\n}\n\npublic OnGameModeInit()\n{\n}\n",
+		"test.pwn":                 "#include <a_samp>\n" + strings.Repeat("// padding\n", 20),
+		"tests/small.pwn":          "main() {}\n",
+		"scripts/myproject.pwn":    "#include <a_samp>\n",
+		"scripts/other.pwn":        "#include <a_samp>\n\n",
+	}
+	for name, contents := range files {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755) //nolint
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		files []string
+		repo  string
+		want  []string
+	}{
+		{"main wins", []string{"test.pwn", "tests/small.pwn", "gamemodes/rivershell.pwn"}, "x", []string{"gamemodes/rivershell.pwn", "tests/small.pwn", "test.pwn"}},
+		{"name over lines", []string{"test.pwn", "scripts/myproject.pwn"}, "myproject", []string{"scripts/myproject.pwn", "test.pwn"}},
+		{"lines", []string{"scripts/myproject.pwn", "scripts/other.pwn", "test.pwn"}, "x", []string{"test.pwn", "scripts/other.pwn", "scripts/myproject.pwn"}},
+		{"missing", []string{"missing.pwn", "scripts/other.pwn"}, "x", []string{"scripts/other.pwn", "missing.pwn"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, rankEntries(dir, tt.files, tt.repo))
+		})
+	}
+}
+
+func Test_generateEntry(t *testing.T) {
+	dir := util.FullPath("./tests/entry-generate")
+	os.RemoveAll(dir)
+
+	files := map[string]string{
+		"lib.inc":            "#include \"lib/core\"\n#include <lib/util>\n",
+		"lib/core.inc":       "#include \"util\"\n",
+		"lib/util.inc":       "stock Util() {}\n",
+		"extra.inc":          "stock Extra() {}\n",
+		"cycle/a.inc":        "#include \"b\"\n",
+		"cycle/b.inc":        "#include \"a\"\n",
+		"unrelated/util.inc": "stock Other() {}\n",
+	}
+	for name, contents := range files {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755) //nolint
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	incFiles := []string{"cycle/a.inc", "cycle/b.inc", "extra.inc", "lib.inc", "lib/core.inc", "lib/util.inc", "unrelated/util.inc"}
+	assert.Equal(t, `// generated by "sampctl package generate"
+
+#include "cycle/a.inc"
+#include "extra.inc"
+#include "lib.inc"
+#include "unrelated/util.inc"
+
+main() {
+	// write tests for libraries here and run "sampctl package run"
+}
+`, string(generateEntry(dir, incFiles)))
+}
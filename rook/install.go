@@ -44,7 +44,7 @@ func (pcx *PackageContext) Install(ctx context.Context, targets []versioning.Dep
 	}
 
 	print.Verb(pcx.Package, "ensuring dependencies are cached for package context")
-	err = pcx.EnsureDependenciesCached()
+	err = pcx.EnsureDependenciesCached(ctx)
 	if err != nil {
 		return
 	}
@@ -73,7 +73,7 @@ func Get(ctx context.Context, gh *github.Client, meta versioning.DependencyMeta,
 
 	print.Verb("cloning package", meta, "to", dir)
 
-	_, err = git.PlainClone(dir, false, &git.CloneOptions{
+	_, err = git.PlainCloneContext(ctx, dir, false, &git.CloneOptions{
 		URL: meta.URL(),
 	})
 	if err != nil {
@@ -81,7 +81,7 @@ func Get(ctx context.Context, gh *github.Client, meta versioning.DependencyMeta,
 	}
 
 	print.Verb("ensuring cloned package", meta, "to", dir)
-	pcx, err := NewPackageContext(gh, auth, true, dir, platform, cacheDir, "")
+	pcx, err := NewPackageContext(ctx, gh, auth, true, dir, platform, cacheDir, "")
 	if err != nil {
 		return errors.Wrap(err, "failed to read cloned repository as Pawn package")
 	}
@@ -47,7 +47,7 @@ func TestPackage_Install(t *testing.T) {
 
 			ioutil.WriteFile(filepath.Join(dir, "pawn.json"), tt.pkg, 0755) // nolint
 
-			pcx1, err := NewPackageContext(gh, gitAuth, true, dir, runtime.GOOS, "./tests/cache", "")
+			pcx1, err := NewPackageContext(context.Background(), gh, gitAuth, true, dir, runtime.GOOS, "./tests/cache", "")
 			if err != nil {
 				t.Error(err)
 			}
@@ -59,7 +59,7 @@ func TestPackage_Install(t *testing.T) {
 				assert.NoError(t, err)
 			}
 
-			pcx2, err := NewPackageContext(gh, gitAuth, true, dir, runtime.GOOS, "./tests/cache", "")
+			pcx2, err := NewPackageContext(context.Background(), gh, gitAuth, true, dir, runtime.GOOS, "./tests/cache", "")
 			if err != nil {
 				t.Error(err)
 			}
@@ -0,0 +1,44 @@
+package rook
+
+import (
+	"io/ioutil"
+	"path/filepath"
+)
+
+// includeLayouts are the subdirectories that packages conventionally keep their include files in,
+// in the order they're preferred when they hold as many include files as each other
+var includeLayouts = []string{
+	"include",
+	filepath.Join("pawno", "include"),
+	"gamemodes",
+}
+
+// DetectIncludePath infers the include path of a package that doesn't declare one from where its
+// .inc files are. A package with include files at the top level has none, otherwise it's the
+// conventional subdirectory with the most include files, or none if none of them have any.
+func DetectIncludePath(dir string) (includePath string) {
+	if countIncludes(dir) > 0 {
+		return ""
+	}
+	most := 0
+	for _, layout := range includeLayouts {
+		if count := countIncludes(filepath.Join(dir, layout)); count > most {
+			includePath, most = filepath.ToSlash(layout), count
+		}
+	}
+	return
+}
+
+// countIncludes counts the .inc files directly inside a directory
+func countIncludes(dir string) (count int) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		if !file.IsDir() && filepath.Ext(file.Name()) == ".inc" {
+			count++
+		}
+	}
+	return
+}
@@ -0,0 +1,39 @@
+package rook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectIncludePath(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"top level", []string{"lib.inc", "include/other.inc", "test.pwn"}, ""},
+		{"include", []string{"include/lib.inc", "test.pwn"}, "include"},
+		{"pawno", []string{"pawno/include/a.inc", "pawno/include/b.inc", "include/c.inc"}, "pawno/include"},
+		{"gamemodes", []string{"gamemodes/main.pwn", "gamemodes/utils.inc"}, "gamemodes"},
+		{"tie", []string{"gamemodes/a.inc", "include/b.inc"}, "include"},
+		{"elsewhere", []string{"src/lib.inc"}, ""},
+		{"none", []string{"test.pwn"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join("./tests/layout", tt.name)
+			os.RemoveAll(dir)
+			for _, file := range tt.files {
+				path := filepath.Join(dir, file)
+				os.MkdirAll(filepath.Dir(path), 0700)
+				ioutil.WriteFile(path, []byte("// "+file), 0600)
+			}
+
+			assert.Equal(t, tt.want, DetectIncludePath(dir))
+		})
+	}
+}
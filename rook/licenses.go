@@ -0,0 +1,159 @@
+package rook
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// LicenseStatus describes how a dependency license should be treated when distributing a package
+type LicenseStatus string
+
+const (
+	// LicenseOK is a recognised permissive or weak copyleft license
+	LicenseOK LicenseStatus = "ok"
+	// LicenseIncompatible is a recognised license that places requirements on the whole gamemode,
+	// such as the GPL, because dependencies are compiled into the same AMX file
+	LicenseIncompatible LicenseStatus = "incompatible"
+	// LicenseUnknown is a license file that could not be identified
+	LicenseUnknown LicenseStatus = "unknown"
+	// LicenseMissing means the dependency has no license file at all
+	LicenseMissing LicenseStatus = "missing"
+)
+
+// DependencyLicense is the license information found for a single vendored dependency
+type DependencyLicense struct {
+	Dependency versioning.DependencyMeta
+	File       string        // the license file, relative to the dependency directory
+	License    string        // an SPDX style identifier of the license, if it was recognised
+	Status     LicenseStatus // whether the license needs attention
+}
+
+func (dl DependencyLicense) String() string {
+	switch dl.Status {
+	case LicenseMissing:
+		return fmt.Sprintf("%s: no license file", dl.Dependency)
+	case LicenseUnknown:
+		return fmt.Sprintf("%s: unrecognised license in %s", dl.Dependency, dl.File)
+	}
+	return fmt.Sprintf("%s: %s (%s)", dl.Dependency, dl.License, dl.Status)
+}
+
+// licenseFile matches the names license files are commonly given, such as LICENSE.md or COPYING
+var licenseFile = regexp.MustCompile(`(?i)^(licen[cs]e|copying)([-_.].*)?$`)
+
+// licenseMatchers are checked in order against the contents of a license file, the more specific
+// licenses come first because many licenses mention each other.
+var licenseMatchers = []struct {
+	license  string
+	status   LicenseStatus
+	patterns []string
+}{
+	{"AGPL-3.0", LicenseIncompatible, []string{"gnu affero general public license"}},
+	{"LGPL-3.0", LicenseIncompatible, []string{"gnu lesser general public license", "version 3"}},
+	{"LGPL-2.1", LicenseIncompatible, []string{"gnu lesser general public license"}},
+	{"GPL-3.0", LicenseIncompatible, []string{"gnu general public license", "version 3"}},
+	{"GPL-2.0", LicenseIncompatible, []string{"gnu general public license"}},
+	{"MPL-1.1", LicenseOK, []string{"mozilla public license", "version 1.1"}},
+	{"MPL-2.0", LicenseOK, []string{"mozilla public license"}},
+	{"Apache-2.0", LicenseOK, []string{"apache license", "version 2.0"}},
+	{"Unlicense", LicenseOK, []string{"free and unencumbered software released into the public domain"}},
+	{"WTFPL", LicenseOK, []string{"do what the fuck you want to"}},
+	{"Zlib", LicenseOK, []string{"provided 'as-is'", "altered source versions must be plainly marked"}},
+	{"BSD-3-Clause", LicenseOK, []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-2-Clause", LicenseOK, []string{"redistribution and use in source and binary forms"}},
+	{"ISC", LicenseOK, []string{"permission to use, copy, modify, and/or distribute this software"}},
+	{"MIT", LicenseOK, []string{"permission is hereby granted, free of charge"}},
+	{"CC0-1.0", LicenseOK, []string{"cc0 1.0 universal"}},
+}
+
+// Licenses scans the vendor directory copy of every dependency for a license file and identifies
+// the license from its contents. The package should be ensured first, dependencies that are not in
+// the vendor directory are reported as having no license file.
+func (pcx *PackageContext) Licenses() (licenses []DependencyLicense, err error) {
+	if pcx.Package.Vendor == "" {
+		err = errors.New("package has no vendor directory")
+		return
+	}
+
+	seen := make(map[string]struct{})
+	for _, meta := range pcx.AllDependencies {
+		key := strings.ToLower(meta.User + "/" + meta.Repo)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		var license DependencyLicense
+		license, err = detectLicense(meta, filepath.Join(pcx.Package.Vendor, meta.VendorName()))
+		if err != nil {
+			return
+		}
+		licenses = append(licenses, license)
+	}
+
+	sort.Slice(licenses, func(i, j int) bool {
+		return licenses[i].Dependency.String() < licenses[j].Dependency.String()
+	})
+
+	return
+}
+
+func detectLicense(meta versioning.DependencyMeta, dir string) (license DependencyLicense, err error) {
+	license = DependencyLicense{Dependency: meta, Status: LicenseMissing}
+	if !util.Exists(dir) {
+		return
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read vendor directory of %s", meta)
+		return
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !licenseFile.MatchString(file.Name()) {
+			continue
+		}
+
+		var contents []byte
+		contents, err = ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			err = errors.Wrapf(err, "failed to read license file of %s", meta)
+			return
+		}
+
+		license.File = file.Name()
+		license.License, license.Status = identifyLicense(string(contents))
+		if license.Status != LicenseUnknown {
+			return
+		}
+	}
+
+	return
+}
+
+func identifyLicense(contents string) (license string, status LicenseStatus) {
+	text := strings.ToLower(strings.Join(strings.Fields(contents), " "))
+	for _, matcher := range licenseMatchers {
+		matched := true
+		for _, pattern := range matcher.patterns {
+			if !strings.Contains(text, pattern) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return matcher.license, matcher.status
+		}
+	}
+	return "", LicenseUnknown
+}
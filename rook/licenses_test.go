@@ -0,0 +1,65 @@
+package rook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_Licenses(t *testing.T) {
+	vendor := util.FullPath("./tests/licenses/dependencies")
+	os.RemoveAll(vendor)
+
+	vendored := map[string]map[string]string{
+		"lib-mit":  {"LICENSE": "MIT License\n\nPermission is hereby granted, free of charge, to any person obtaining a copy"},
+		"lib-gpl":  {"COPYING": "GNU GENERAL PUBLIC LICENSE\n   Version 3, 29 June 2007"},
+		"lib-mpl":  {"license.md": "Mozilla Public License\nVersion 1.1"},
+		"lib-odd":  {"LICENSE.txt": "You may do whatever you like as long as you buy me a coffee."},
+		"lib-none": {"README.md": "# lib-none"},
+	}
+	for repo, files := range vendored {
+		os.MkdirAll(filepath.Join(vendor, repo), 0755) //nolint
+		for name, contents := range files {
+			err := ioutil.WriteFile(filepath.Join(vendor, repo, name), []byte(contents), 0644)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	pcx := PackageContext{
+		Package: types.Package{Vendor: vendor},
+		AllDependencies: []versioning.DependencyMeta{
+			{Site: "github.com", User: "test", Repo: "lib-mit"},
+			{Site: "github.com", User: "test", Repo: "lib-gpl"},
+			{Site: "github.com", User: "test", Repo: "lib-mpl"},
+			{Site: "github.com", User: "test", Repo: "lib-odd"},
+			{Site: "github.com", User: "test", Repo: "lib-none"},
+			{Site: "github.com", User: "test", Repo: "lib-missing"},
+			{Site: "github.com", User: "test", Repo: "lib-mit"},
+		},
+	}
+
+	licenses, err := pcx.Licenses()
+	assert.NoError(t, err)
+
+	var got []string
+	for _, license := range licenses {
+		got = append(got, license.String())
+	}
+	assert.Equal(t, []string{
+		"github.com/test/lib-gpl: GPL-3.0 (incompatible)",
+		"github.com/test/lib-missing: no license file",
+		"github.com/test/lib-mit: MIT (ok)",
+		"github.com/test/lib-mpl: MPL-1.1 (ok)",
+		"github.com/test/lib-none: no license file",
+		"github.com/test/lib-odd: unrecognised license in LICENSE.txt",
+	}, got)
+}
@@ -1,7 +1,9 @@
 package rook
 
 import (
+	"context"
 	"path/filepath"
+	"time"
 
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
@@ -15,33 +17,63 @@ import (
 
 // PackageContext stores state for a package during its lifecycle.
 type PackageContext struct {
-	Package         types.Package               // the package this context wraps
-	GitHub          *github.Client              // GitHub client for downloading plugins
-	GitAuth         transport.AuthMethod        // Authentication method for git
-	Platform        string                      // the platform this package targets
-	CacheDir        string                      // the cache directory
-	AllDependencies []versioning.DependencyMeta // flattened list of dependencies
-	AllPlugins      []versioning.DependencyMeta // flattened list of plugin dependencies
-	AllIncludePaths []string                    // any additional include paths specified by resources
+	Package         types.Package                // the package this context wraps
+	GitHub          *github.Client               // GitHub client for downloading plugins
+	GitAuth         transport.AuthMethod         // Authentication method for git
+	Platform        string                       // the platform this package targets
+	CacheDir        string                       // the cache directory
+	AllDependencies []versioning.DependencyMeta  // flattened list of dependencies
+	AllPlugins      []versioning.DependencyMeta  // flattened list of plugin dependencies
+	AllIncludePaths []string                     // any additional include paths specified by resources
+	Constraints     map[string][]string          // every tag constraint on each dependency in the tree by `user/repo`
+	OtherPlatforms  []versioning.DependencyMeta  // dependencies that are only used on platforms other than the target
+	transformed     map[string]map[string]string // checksums of the files transformed during ensure by `user/repo`
 
 	// Runtime specific fields
-	Runtime     string // the runtime config to use, defaults to `default`
-	Container   bool   // whether or not to run the package in a container
-	AppVersion  string // the version of sampctl
-	BuildName   string // Build configuration to use
-	ForceBuild  bool   // Force a build before running
-	ForceEnsure bool   // Force an ensure before building before running
-	NoCache     bool   // Don't use a cache, download all plugin dependencies
-	BuildFile   string // File to increment build number
-	Relative    bool   // Show output as relative paths
-
+	Runtime     string             // the runtime config to use, defaults to `default`
+	Container   bool               // whether or not to run the package in a container
+	AppVersion  string             // the version of sampctl
+	BuildName   string             // Build configuration to use
+	ForceBuild  bool               // Force a build before running
+	ForceEnsure bool               // Force an ensure before building before running
+	NoCache     bool               // Don't use a cache, download all plugin dependencies
+	BuildFile   string             // File to increment build number
+	ReportFile  string             // File to write a JSON report of each build to
+	Rebuild     bool               // Compile even if the output is up to date with its sources
+	Relative    bool               // Show output as relative paths
+	Frozen      bool               // Fail instead of changing the lockfile during ensure
+	Stale       StalePolicy        // What to do when building with stale vendored dependencies
+	Strategy    ResolutionStrategy // Which versions constraints resolve to during ensure, defaults to the lockfile's
+
+	// ReadOnlyVendor uses the vendor directory as it is, dependencies are resolved from the vendored
+	// copies and never ensured, defaults to the mode set with `SetReadOnlyVendor`
+	ReadOnlyVendor bool
+
+	// Registry is the package registry dependencies without a host resolve through, they resolve to
+	// GitHub without one, defaults to the registry set with `SetRegistry`
+	Registry *Registry
+
+	// Shared constraint cache fields
+	Refresh       bool          // Resolve constraints again instead of reusing the versions they resolved to
+	ResolutionTTL time.Duration // How long resolved constraints are reused for, negative to never reuse them
+
+	// ResolveConflict asks how to settle version constraints that conflict during ensure, when it's
+	// nil conflicts fail the minimal strategy and are a warning for the newest strategy
+	ResolveConflict func(ConstraintConflict) (ConflictResolution, error)
+
+	releases map[string][]*github.RepositoryRelease // GitHub releases of dependencies by `user/repo`, listed once
+	resolved map[string]constraintResolution        // the shared constraint cache, read once
+	requests map[string][]ConstraintRequest         // every tag constraint on each dependency and who declared it by `user/repo`
+	upstream map[string][]upstreamLock              // commits each dependency is locked at by the lockfiles of other dependencies by `user/repo`
 }
 
 // NewPackageContext attempts to parse a directory as a Package by looking for a
 // `pawn.json` or `pawn.yaml` file and unmarshalling it - additional parameters
 // are required to specify whether or not the package is a "parent package" and
-// where the vendor directory is.
+// where the vendor directory is. Dependencies are cloned to the cache to resolve
+// the dependency tree, which stops when the context is cancelled.
 func NewPackageContext(
+	ctx context.Context,
 	gh *github.Client,
 	auth transport.AuthMethod,
 	parent bool,
@@ -50,26 +82,56 @@ func NewPackageContext(
 	cacheDir string,
 	vendor string,
 ) (pcx *PackageContext, err error) {
-	pcx = &PackageContext{
-		GitHub:   gh,
-		GitAuth:  auth,
-		Platform: platform,
-		CacheDir: cacheDir,
-	}
-	pcx.Package, err = types.PackageFromDir(dir)
+	pkg, err := types.PackageFromDir(dir)
 	if err != nil {
 		err = errors.Wrap(err, "failed to read package definition")
 		return
 	}
 
-	pcx.Package.Parent = parent
-	pcx.Package.LocalPath = dir
-	pcx.Package.Tag = getPackageTag(dir)
+	pkg.LocalPath = dir
+	pkg.Tag = getPackageTag(dir)
+
+	print.Verb(pkg, "read package from directory", dir)
+
+	return NewPackageContextFromPackage(ctx, gh, auth, parent, pkg, platform, cacheDir, vendor)
+}
+
+// NewPackageContextFromPackage creates a package context from a package that is already in
+// memory, this allows the ensure and build pipeline to be used as a library without a package
+// definition file on disk. The package must have `LocalPath` set, all other paths such as the
+// entry, output and vendor directory are resolved relative to it.
+func NewPackageContextFromPackage(
+	ctx context.Context,
+	gh *github.Client,
+	auth transport.AuthMethod,
+	parent bool,
+	pkg types.Package,
+	platform string,
+	cacheDir string,
+	vendor string,
+) (pcx *PackageContext, err error) {
+	if pkg.LocalPath == "" {
+		err = errors.New("package has no local path")
+		return
+	}
 
-	print.Verb(pcx.Package, "read package from directory", dir)
+	pcx = &PackageContext{
+		Package:        pkg,
+		GitHub:         gh,
+		GitAuth:        auth,
+		Platform:       platform,
+		CacheDir:       cacheDir,
+		ReadOnlyVendor: readOnlyVendor,
+		Registry:       defaultRegistry,
+	}
+
+	pcx.Package.Parent = parent
+	if pcx.Package.Tag == "" {
+		pcx.Package.Tag = getPackageTag(pcx.Package.LocalPath)
+	}
 
 	if vendor == "" {
-		pcx.Package.Vendor = filepath.Join(dir, "dependencies")
+		pcx.Package.Vendor = filepath.Join(pcx.Package.LocalPath, "dependencies")
 	} else {
 		pcx.Package.Vendor = vendor
 	}
@@ -101,7 +163,7 @@ func NewPackageContext(
 	types.ApplyRuntimeDefaults(pcx.Package.Runtime)
 
 	print.Verb(pcx.Package, "building dependency tree and ensuring cached copies")
-	err = pcx.EnsureDependenciesCached()
+	err = pcx.resolveDependencies(ctx)
 	if err != nil {
 		err = errors.Wrap(err, "failed to ensure dependencies are cached")
 		return
@@ -1,6 +1,8 @@
 package rook
 
 import (
+	"context"
+	"os"
 	"path/filepath"
 	"runtime"
 	"testing"
@@ -8,6 +10,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
 	"github.com/Southclaws/sampctl/versioning"
 )
 
@@ -71,7 +74,7 @@ func TestPackageFromDir(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotPcx, err := NewPackageContext(gh, gitAuth, true, tt.args.dir, runtime.GOOS, "./tests/cache", "")
+			gotPcx, err := NewPackageContext(context.Background(), gh, gitAuth, true, tt.args.dir, runtime.GOOS, "./tests/cache", "")
 			if tt.wantErr {
 				assert.Error(t, err)
 			} else {
@@ -84,3 +87,26 @@ func TestPackageFromDir(t *testing.T) {
 		})
 	}
 }
+
+func TestNewPackageContextFromPackage(t *testing.T) {
+	dir := "./tests/load-memory"
+	os.RemoveAll(dir)
+	os.MkdirAll(dir, 0700)
+
+	pkg := types.Package{
+		LocalPath: dir,
+		Entry:     "gamemodes/test.pwn",
+		Output:    "gamemodes/test.amx",
+	}
+
+	pcx, err := NewPackageContextFromPackage(context.Background(), gh, gitAuth, true, pkg, runtime.GOOS, "./tests/cache", "")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "dependencies"), pcx.Package.Vendor)
+	assert.Equal(t, "<local>", pcx.Package.Repo)
+	assert.NotNil(t, pcx.Package.Runtime)
+	assert.False(t, util.Exists(filepath.Join(dir, "pawn.json")))
+	assert.False(t, util.Exists(filepath.Join(dir, "pawn.yaml")))
+
+	_, err = NewPackageContextFromPackage(context.Background(), gh, gitAuth, true, types.Package{}, runtime.GOOS, "./tests/cache", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+package rook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+)
+
+// CheckLockfile checks that the lockfile of the package exists, is committed without changes and
+// is current: a frozen ensure must succeed with the lockfile as it is and must leave it unchanged.
+// The error describes the first of these that fails.
+func (pcx *PackageContext) CheckLockfile(ctx context.Context) (err error) {
+	lock, err := types.ReadLockfile(pcx.Package.LocalPath)
+	if err != nil {
+		return
+	}
+	if lock == nil {
+		return errors.Errorf("%s does not exist, run `sampctl package ensure` and commit it", types.LockfileName)
+	}
+
+	err = pcx.lockfileCommitted()
+	if err != nil {
+		return
+	}
+	print.Verb(pcx.Package, types.LockfileName, "is committed")
+
+	err = pcx.checkLockfileDeclared(*lock)
+	if err != nil {
+		return
+	}
+
+	pcx.Frozen = true
+	err = pcx.EnsureDependencies(ctx, false)
+	if err != nil {
+		return errors.Wrap(err, "frozen ensure failed")
+	}
+
+	err = pcx.lockfileCommitted()
+	if err != nil {
+		return errors.Wrap(err, "frozen ensure changed the lockfile")
+	}
+	return
+}
+
+// lockfileCommitted checks that the lockfile is in the HEAD commit of the package repository and
+// that the file on disk is the same as the committed one
+func (pcx *PackageContext) lockfileCommitted() (err error) {
+	repo, err := git.PlainOpen(pcx.Package.LocalPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to open package repository")
+	}
+	tree, err := headTree(repo)
+	if err != nil {
+		return
+	}
+
+	file, err := tree.File(types.LockfileName)
+	if err == object.ErrFileNotFound {
+		return errors.Errorf("%s is not committed", types.LockfileName)
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to find committed %s", types.LockfileName)
+	}
+	committed, err := file.Contents()
+	if err != nil {
+		return errors.Wrapf(err, "failed to read committed %s", types.LockfileName)
+	}
+	contents, err := ioutil.ReadFile(filepath.Join(pcx.Package.LocalPath, types.LockfileName))
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", types.LockfileName)
+	}
+	if committed == string(contents) {
+		return
+	}
+
+	// describe the changes if both versions can be read, otherwise they're only different
+	var before, after types.Lockfile
+	if json.Unmarshal([]byte(committed), &before) == nil && json.Unmarshal(contents, &after) == nil {
+		if changes := before.Diff(after); len(changes) > 0 {
+			return errors.Errorf("%s has uncommitted changes:\n%s", types.LockfileName, strings.Join(changes, "\n"))
+		}
+	}
+	return errors.Errorf("%s has uncommitted changes", types.LockfileName)
+}
@@ -0,0 +1,52 @@
+package rook
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestPackageContext_CheckLockfile(t *testing.T) {
+	dir := util.FullPath("./tests/lockcheck")
+	os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+
+	pcx := PackageContext{Package: types.Package{LocalPath: dir, Vendor: filepath.Join(dir, "dependencies")}}
+	assert.EqualError(t, pcx.CheckLockfile(context.Background()), "pawn.lock does not exist, run `sampctl package ensure` and commit it")
+
+	repo, err := git.PlainInit(dir, false)
+	assert.NoError(t, err)
+	wt, err := repo.Worktree()
+	assert.NoError(t, err)
+	commit := func(file string) {
+		_, errAdd := wt.Add(file)
+		assert.NoError(t, errAdd)
+		_, errCommit := wt.Commit(file, &git.CommitOptions{Author: &object.Signature{Name: "test", Email: "test@test"}})
+		assert.NoError(t, errCommit)
+	}
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "pawn.json"), []byte(`{"entry": "test.pwn"}`), 0644))
+	commit("pawn.json")
+	assert.NoError(t, types.NewLockfile(nil).Write(dir))
+	assert.EqualError(t, pcx.CheckLockfile(context.Background()), "pawn.lock is not committed")
+
+	commit(types.LockfileName)
+	assert.NoError(t, pcx.CheckLockfile(context.Background()))
+
+	changed := types.NewLockfile([]types.LockedDependency{{Dependency: "test/lib", Commit: "abc"}})
+	assert.NoError(t, changed.Write(dir))
+	assert.EqualError(t, pcx.CheckLockfile(context.Background()), "pawn.lock has uncommitted changes:\nadded test/lib")
+
+	// a committed lockfile that doesn't match the declared dependencies isn't current
+	commit(types.LockfileName)
+	assert.EqualError(t, pcx.CheckLockfile(context.Background()), "pawn.lock is out of date with the package definition:\nremoved test/lib")
+}
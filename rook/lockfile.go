@@ -0,0 +1,167 @@
+package rook
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// ResolveLockfile builds a lockfile from the commits currently checked out in the vendor directory
+func (pcx *PackageContext) ResolveLockfile() (lock types.Lockfile, err error) {
+	var locked []types.LockedDependency
+	for _, meta := range pcx.AllDependencies {
+		var commit string
+		commit, err = pcx.vendoredCommit(meta)
+		if err != nil {
+			return
+		}
+		locked = append(locked, types.LockedDependency{
+			Dependency: versioning.DependencyString(meta.String()),
+			Commit:     commit,
+			Transforms: pcx.transformed[constraintKey(meta)],
+			Platform:   pcx.platformOf(meta),
+		})
+	}
+	lock = types.NewLockfile(locked)
+	return
+}
+
+// checkLockfileDeclared ensures every declared dependency has an entry in the lockfile and that the
+// lockfile does not contain any dependencies that are no longer declared.
+func (pcx *PackageContext) checkLockfileDeclared(lock types.Lockfile) (err error) {
+	if changes := pcx.lockfileChanges(lock); len(changes) > 0 {
+		err = errors.Errorf("%s is out of date with the package definition:\n%s", types.LockfileName, strings.Join(changes, "\n"))
+	}
+	return
+}
+
+// lockfileChanges describes the dependencies that would be added to or removed from the lockfile to
+// match the dependencies the package declares
+func (pcx *PackageContext) lockfileChanges(lock types.Lockfile) (changes []string) {
+	var declared []types.LockedDependency
+	for _, meta := range pcx.AllDependencies {
+		commit, _ := lock.Commit(meta)
+		declared = append(declared, types.LockedDependency{
+			Dependency: versioning.DependencyString(meta.String()),
+			Commit:     commit,
+			Plugins:    lock.Plugins(meta),
+			Transforms: lock.Transformed(meta),
+			Platform:   pcx.platformOf(meta),
+		})
+	}
+	declared = append(declared, pcx.lockedOtherPlatforms(lock)...)
+
+	expected := types.NewLockfile(declared)
+	expected.Compilers = lock.Compilers
+	expected.Runtime = lock.Runtime
+	expected.Strategy = lock.Strategy
+	return lock.Diff(expected)
+}
+
+// vendoredAtLock checks whether a dependency constraint is recorded in the lockfile and whether the
+// vendored copy of it is still checked out at the locked commit. A constraint that was edited since
+// the lockfile was written no longer matches its entry so it will be resolved again. A vendored
+// copy that isn't marked as completely cloned is ensured again in case it was interrupted.
+func (pcx *PackageContext) vendoredAtLock(meta versioning.DependencyMeta, lock types.Lockfile) bool {
+	commit, ok := lock.Commit(meta)
+	if !ok {
+		return false
+	}
+	if !util.Exists(filepath.Join(pcx.Package.Vendor, meta.VendorName(), ".git", cloneMarker)) {
+		return false
+	}
+	head, err := pcx.vendoredCommit(meta)
+	if err != nil {
+		return false
+	}
+	return head == commit
+}
+
+// vendoredCommit returns the commit the vendored copy of a dependency is checked out at
+func (pcx *PackageContext) vendoredCommit(meta versioning.DependencyMeta) (commit string, err error) {
+	repo, err := git.PlainOpen(filepath.Join(pcx.Package.Vendor, meta.VendorName()))
+	if err != nil {
+		err = errors.Wrapf(err, "failed to open vendored repository for %s", meta)
+		return
+	}
+	head, err := repo.Head()
+	if err != nil {
+		err = errors.Wrapf(err, "failed to get HEAD of vendored repository for %s", meta)
+		return
+	}
+	return head.Hash().String(), nil
+}
+
+// pinToLockfile replaces the version constraint of a dependency with the commit it is locked to
+func pinToLockfile(meta versioning.DependencyMeta, lock types.Lockfile) versioning.DependencyMeta {
+	commit, ok := lock.Commit(meta)
+	if !ok {
+		return meta
+	}
+	meta.Tag = ""
+	meta.Branch = ""
+	meta.Commit = commit
+	return meta
+}
+
+// loadPluginChecksums gives the runtime the plugin checksums recorded in the lockfile so the plugin
+// binaries it ensures are verified against them. The lockfile is returned so the checksums of
+// plugins that weren't recorded yet can be added to it afterwards.
+func (pcx *PackageContext) loadPluginChecksums() (lock *types.Lockfile, err error) {
+	lock, err = types.ReadLockfile(pcx.Package.LocalPath)
+	if err != nil || lock == nil {
+		return
+	}
+
+	checksums := make(map[string]map[string]string)
+	for _, meta := range pcx.Package.Runtime.PluginDeps {
+		plugins := lock.Plugins(meta)
+		if len(plugins) == 0 {
+			continue
+		}
+		copied := make(map[string]string)
+		for file, checksum := range plugins {
+			copied[file] = checksum
+		}
+		checksums[meta.User+"/"+meta.Repo] = copied
+	}
+	pcx.Package.Runtime.PluginChecksums = checksums
+	return
+}
+
+// recordPluginChecksums adds the checksums of plugin binaries the runtime ensured to the lockfile,
+// in frozen mode a lockfile without them is an error instead.
+func (pcx *PackageContext) recordPluginChecksums(lock *types.Lockfile) (err error) {
+	if lock == nil {
+		return
+	}
+
+	updated := types.NewLockfile(append([]types.LockedDependency{}, lock.Dependencies...))
+	updated.Compilers = lock.Compilers
+	updated.Runtime = lock.Runtime
+	updated.Strategy = lock.Strategy
+	for _, meta := range pcx.Package.Runtime.PluginDeps {
+		checksums := pcx.Package.Runtime.PluginChecksums[meta.User+"/"+meta.Repo]
+		if len(checksums) > 0 {
+			updated.SetPlugins(meta, checksums)
+		}
+	}
+
+	changes := lock.Diff(updated)
+	if len(changes) == 0 {
+		return
+	}
+	if pcx.Frozen {
+		return errors.Errorf("frozen run would change %s:\n%s", types.LockfileName, strings.Join(changes, "\n"))
+	}
+
+	print.Verb(pcx.Package, "recording plugin checksums in", types.LockfileName)
+	return updated.Write(pcx.Package.LocalPath)
+}
@@ -0,0 +1,113 @@
+package rook
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+)
+
+// ManifestData is what the field templates of a build manifest are executed with
+type ManifestData struct {
+	Package     string // the user/repo of the package
+	Build       string // the name of the build config
+	Platform    string
+	Version     string // the version tag the package is at, empty if it isn't at one
+	Commit      string // the commit the package is at, empty if it isn't a repository
+	Time        string // when the build finished, in RFC 3339 format
+	Unix        int64  // when the build finished, in seconds since the epoch
+	BuildNumber uint32 // the build number from the build file, zero without one
+	Output      string // the file name of the AMX
+	SHA256      string // the checksum of the AMX
+	Size        int64  // the size of the AMX in bytes
+}
+
+// defaultManifestFields are the fields of a manifest that doesn't declare any
+var defaultManifestFields = map[string]string{
+	"package": "{{.Package}}",
+	"build":   "{{.Build}}",
+	"version": "{{.Version}}",
+	"commit":  "{{.Commit}}",
+	"time":    "{{.Time}}",
+	"output":  "{{.Output}}",
+	"sha256":  "{{.SHA256}}",
+}
+
+// writeManifest writes the manifest of a build config to the directory of its output, the config
+// must be prepared so its output is an absolute path and the output must have been compiled
+func (pcx *PackageContext) writeManifest(config types.BuildConfig, buildNumber uint32, built time.Time) (filename string, err error) {
+	manifest := config.Manifest
+	fields := manifest.Fields
+	if len(fields) == 0 {
+		fields = defaultManifestFields
+	}
+
+	artifact, err := pcx.reportArtifact(config.Output)
+	if err != nil {
+		return
+	}
+	version, commit, err := vendoredVersion(pcx.Package.LocalPath)
+	if err != nil {
+		return
+	}
+	data := ManifestData{
+		Package:     pcx.Package.String(),
+		Build:       config.Name,
+		Platform:    pcx.Platform,
+		Version:     version,
+		Commit:      commit,
+		Time:        built.UTC().Format(time.RFC3339),
+		Unix:        built.Unix(),
+		BuildNumber: buildNumber,
+		Output:      filepath.Base(config.Output),
+		SHA256:      artifact.SHA256,
+		Size:        artifact.Size,
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := make(map[string]string)
+	for _, name := range names {
+		var tmpl *template.Template
+		tmpl, err = template.New(name).Option("missingkey=error").Parse(fields[name])
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to parse manifest field %s", name)
+		}
+		buf := bytes.Buffer{}
+		err = tmpl.Execute(&buf, data)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to execute manifest field %s", name)
+		}
+		values[name] = buf.String()
+	}
+
+	contents, err := json.MarshalIndent(values, "", "\t")
+	if err != nil {
+		return
+	}
+
+	file := manifest.File
+	if file == "" {
+		file = strings.TrimSuffix(filepath.Base(config.Output), filepath.Ext(config.Output)) + ".json"
+	}
+	filename = filepath.Join(filepath.Dir(config.Output), filepath.FromSlash(file))
+	err = ioutil.WriteFile(filename, contents, 0644)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to write build manifest")
+	}
+	print.Verb(pcx.Package, "wrote build manifest", filename)
+	return
+}
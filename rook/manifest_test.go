@@ -0,0 +1,68 @@
+package rook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_writeManifest(t *testing.T) {
+	dir := util.FullPath("./tests/manifest")
+	os.RemoveAll(dir)
+	commitVersions(t, dir, []string{"1.0.0", "1.1.0"})
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "gamemodes"), 0700))
+	output := filepath.Join(dir, "gamemodes", "test.amx")
+	assert.NoError(t, ioutil.WriteFile(output, []byte("amx"), 0600))
+
+	pcx := PackageContext{
+		Package: types.Package{
+			LocalPath:      dir,
+			DependencyMeta: versioning.DependencyMeta{User: "user", Repo: "repo"},
+		},
+		Platform: "linux",
+	}
+	built := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	read := func(filename string) (fields map[string]string) {
+		contents, err := ioutil.ReadFile(filename)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(contents, &fields))
+		return
+	}
+
+	config := types.BuildConfig{Name: "main", Output: output, Manifest: &types.BuildManifest{}}
+	filename, err := pcx.writeManifest(config, 0, built)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "gamemodes", "test.json"), filename)
+	fields := read(filename)
+	assert.Equal(t, "user/repo", fields["package"])
+	assert.Equal(t, "1.1.0", fields["version"])
+	assert.Len(t, fields["commit"], 40)
+	assert.Equal(t, "2018-06-01T12:00:00Z", fields["time"])
+	assert.Equal(t, "test.amx", fields["output"])
+	assert.Equal(t, "c9fbecf5530beb84b4b0ca562226dc6973b99355947dc7cdc8520b2715f85d79", fields["sha256"])
+
+	config.Manifest = &types.BuildManifest{
+		File: "deploy.json",
+		Fields: map[string]string{
+			"release": "{{.Package}}@{{.Version}}+{{.BuildNumber}}",
+			"built":   "{{.Unix}}",
+		},
+	}
+	filename, err = pcx.writeManifest(config, 7, built)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "gamemodes", "deploy.json"), filename)
+	assert.Equal(t, map[string]string{"release": "user/repo@1.1.0+7", "built": "1527854400"}, read(filename))
+
+	config.Manifest.Fields = map[string]string{"bad": "{{.Missing}}"}
+	_, err = pcx.writeManifest(config, 0, built)
+	assert.Error(t, err)
+}
@@ -0,0 +1,209 @@
+package rook
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// namespaceInclude presents the include directory of a namespaced dependency at
+// `<vendor>/.namespaces/<namespace>/<repo>` and returns the root directory that must be passed to
+// the compiler so consumers can write `#include <namespace/repo/file>`. A symbolic link is used
+// where possible, otherwise the include files are copied. The link or copy is left as it is if it
+// already presents the include directory. See `presentRoot` for where the namespaces go when the
+// vendor directory is read-only.
+func (pcx *PackageContext) namespaceInclude(meta versioning.DependencyMeta, includeDir string) (root string, err error) {
+	root = pcx.presentRoot(".namespaces")
+	target := filepath.Join(root, meta.Namespace, meta.Repo)
+
+	source, err := filepath.Abs(includeDir)
+	if err != nil {
+		err = errors.Wrap(err, "failed to make canonical path to include directory")
+		return
+	}
+
+	err = presentDir(meta, source, target, "namespace "+meta.Namespace)
+	return
+}
+
+// presentDir links or copies the include directory source to target, the `name` of what it's
+// presented as is only for messages. A copy is marked with where it came from so `presented` can
+// tell whether it's still current. Nothing is done if target already presents source.
+func presentDir(meta versioning.DependencyMeta, source, target, name string) (err error) {
+	if presented(source, target) {
+		return
+	}
+
+	err = os.RemoveAll(target)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to remove existing %s directory", name)
+		return
+	}
+	err = os.MkdirAll(filepath.Dir(target), 0700)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create %s directory", name)
+		return
+	}
+
+	print.Verb(meta, "presenting includes from", source, "as", name)
+
+	if errLink := os.Symlink(source, target); errLink != nil {
+		print.Verb(meta, "failed to link", name, "copying includes instead:", errLink)
+		err = copyDir(source, target)
+		if err != nil {
+			err = errors.Wrapf(err, "failed to copy includes of %s into %s", meta, name)
+			return
+		}
+		err = ioutil.WriteFile(filepath.Join(target, copyMarker), []byte(source), 0600)
+		if err != nil {
+			err = errors.Wrap(err, "failed to mark copied includes")
+			return
+		}
+	}
+
+	return
+}
+
+// ShadowedInclude is an include file of a namespaced or aliased dependency that's also reachable by
+// its plain name, which resolves to the same name in another dependency that comes first instead
+type ShadowedInclude struct {
+	Name  string                    // the plain include name, without the extension
+	Owner versioning.DependencyMeta // the namespaced or aliased dependency
+	By    versioning.DependencyMeta // the dependency the plain name resolves to
+}
+
+// shadowedIncludes lists the include files of the prefixed sources whose plain names resolve to
+// another dependency. The include directories of namespaced and aliased dependencies must stay on
+// the include path since their own files include each other by their plain names, and the compiler
+// can't limit that to their own files. They're searched after all the plain sources, in order, so
+// a plain name only reaches them if no source before them has it.
+func shadowedIncludes(plain, prefixed []IncludeSource) (shadowed []ShadowedInclude, err error) {
+	owners := make(map[string]versioning.DependencyMeta)
+	for _, source := range plain {
+		err = addIncludeNames(owners, source, nil)
+		if err != nil {
+			return
+		}
+	}
+	for _, source := range prefixed {
+		err = addIncludeNames(owners, source, func(name string, by versioning.DependencyMeta) {
+			if by.User != source.Owner.User || by.Repo != source.Owner.Repo {
+				shadowed = append(shadowed, ShadowedInclude{Name: name, Owner: source.Owner, By: by})
+			}
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// addIncludeNames records the source as the owner of each include name it provides that isn't
+// owned yet and calls taken for the names that are
+func addIncludeNames(owners map[string]versioning.DependencyMeta, source IncludeSource, taken func(string, versioning.DependencyMeta)) error {
+	if !util.Exists(source.Dir) {
+		return nil
+	}
+	err := filepath.Walk(source.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != source.Dir && (strings.HasPrefix(info.Name(), ".") || info.Name() == "dependencies") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".inc" {
+			return nil
+		}
+		rel, err := filepath.Rel(source.Dir, path)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.ToSlash(rel), ".inc")
+		if by, ok := owners[name]; ok {
+			if taken != nil {
+				taken(name, by)
+			}
+			return nil
+		}
+		owners[name] = source.Owner
+		return nil
+	})
+	return errors.Wrapf(err, "failed to list include files of %s", source.Owner)
+}
+
+func warnShadowedIncludes(plain, prefixed []IncludeSource) {
+	shadowed, err := shadowedIncludes(plain, prefixed)
+	if err != nil {
+		print.Warn("Failed to check namespaced and aliased dependencies for shadowed includes:", err)
+		return
+	}
+	for _, include := range shadowed {
+		print.Warn(include.Owner, "include", fmt.Sprintf("<%s>", include.Name), "is shadowed by", include.By,
+			"so its own files that include it by that name get the other one")
+	}
+}
+
+// copyMarker is written into a copy of an include directory and holds the path it was copied from
+const copyMarker = ".sampctl-source"
+
+// presented reports whether target already presents the include directory source, either as a
+// link to it or as a copy of it made after anything in it last changed
+func presented(source, target string) bool {
+	if link, err := os.Readlink(target); err == nil {
+		return link == source
+	}
+
+	marker, err := os.Stat(filepath.Join(target, copyMarker))
+	if err != nil {
+		return false
+	}
+	copiedFrom, err := ioutil.ReadFile(filepath.Join(target, copyMarker))
+	if err != nil || string(copiedFrom) != source {
+		return false
+	}
+	changed := errors.New("changed")
+	err = filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		// a directory changes when a file in it is added or removed
+		if info.ModTime().After(marker.ModTime()) {
+			return changed
+		}
+		return nil
+	})
+	return err == nil
+}
+
+func copyDir(from, to string) error {
+	return filepath.Walk(from, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(filepath.Join(to, rel), 0700)
+		}
+		return util.CopyFile(path, filepath.Join(to, rel))
+	})
+}
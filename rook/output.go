@@ -0,0 +1,62 @@
+package rook
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// OutputData is what the output of a build is executed with when it's a template, such as
+// `{{.Repo}}-{{.Version}}-{{.Build}}.amx`, so the AMX files of different builds and versions are
+// named after them
+type OutputData struct {
+	Package   string // the user/repo of the package
+	User      string
+	Repo      string
+	Build     string // the name of the build config
+	BuildName string // the same as Build
+	Platform  string
+	Version   string // the version tag the package is at, empty if it isn't at one
+	Commit    string // the commit the package is at, empty if it isn't a repository
+}
+
+// expandOutput executes the output of a build as a template with the package and build details.
+// Outputs without a template action are returned as they are. Builds and runs both name the output
+// this way, so a run finds the AMX that a build of the same package state wrote.
+func (pcx *PackageContext) expandOutput(output, build string) (expanded string, err error) {
+	if !strings.Contains(output, "{{") {
+		return output, nil
+	}
+
+	tmpl, err := template.New("output").Option("missingkey=error").Parse(output)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse output template %s", output)
+	}
+	version, commit, err := vendoredVersion(pcx.Package.LocalPath)
+	if err != nil {
+		return
+	}
+	data := OutputData{
+		Package:   pcx.Package.String(),
+		User:      pcx.Package.User,
+		Repo:      pcx.Package.Repo,
+		Build:     build,
+		BuildName: build,
+		Platform:  pcx.Platform,
+		Version:   version,
+		Commit:    commit,
+	}
+
+	buf := bytes.Buffer{}
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to execute output template %s", output)
+	}
+	expanded = buf.String()
+	if strings.TrimSpace(expanded) == "" {
+		return "", errors.Errorf("output template %s expanded to an empty file name", output)
+	}
+	return
+}
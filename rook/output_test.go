@@ -0,0 +1,51 @@
+package rook
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_expandOutput(t *testing.T) {
+	dir := util.FullPath("./tests/output")
+	os.RemoveAll(dir)
+	commitVersions(t, dir, []string{"v1.0.0"})
+
+	pcx := PackageContext{
+		Package: types.Package{
+			DependencyMeta: versioning.DependencyMeta{User: "me", Repo: "gamemode"},
+			LocalPath:      dir,
+			Entry:          "main.pwn",
+			Output:         "{{.Repo}}-{{.Version}}-{{.BuildName}}.amx",
+			Builds: []*types.BuildConfig{
+				{Name: "main"},
+				{Name: "debug", Output: "debug/{{.Build}}-{{.Platform}}.amx"},
+			},
+		},
+		Platform: "linux",
+	}
+
+	config, err := pcx.buildPrepare(context.Background(), "main", false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "gamemode-v1.0.0-main.amx"), config.Output)
+
+	config, err = pcx.buildPrepare(context.Background(), "debug", false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "debug", "debug-linux.amx"), config.Output)
+
+	output, err := pcx.expandOutput("plain.amx", "main")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain.amx", output)
+
+	_, err = pcx.expandOutput("{{.Name}}.amx", "main")
+	assert.Error(t, err)
+	_, err = pcx.expandOutput("{{if .Commit}}{{end}}", "main")
+	assert.EqualError(t, err, "output template {{if .Commit}}{{end}} expanded to an empty file name")
+}
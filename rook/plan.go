@@ -0,0 +1,327 @@
+package rook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/yaml.v2"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// maxPlanCommits limits the number of commit summaries listed for a single dependency
+const maxPlanCommits = 100
+
+// Plan describes what updating the dependencies of a package would change
+type Plan struct {
+	Changes []PlannedChange
+}
+
+// PlannedChange describes how a single dependency would move if it were updated
+type PlannedChange struct {
+	Dependency versioning.DependencyMeta
+	From       string                        // the commit currently locked or vendored, empty if new
+	To         string                        // the commit the constraint resolves to now
+	FromTag    string                        // a tag pointing at From, if any
+	ToTag      string                        // a tag pointing at To, if any
+	Commits    []string                      // summaries of the commits between From and To, newest first
+	Notes      map[string]string             // annotated tag messages for the tags between From and To
+	Added      []versioning.DependencyString // dependencies the new version declares that the old one didn't
+	Removed    []versioning.DependencyString // dependencies the old version declared that the new one doesn't
+}
+
+func (pc PlannedChange) String() string {
+	from := shortCommit(pc.From, pc.FromTag)
+	if pc.From == "" {
+		from = "(not installed)"
+	}
+	return fmt.Sprintf("%s: %s -> %s", pc.Dependency, from, shortCommit(pc.To, pc.ToTag))
+}
+
+func shortCommit(commit, tag string) string {
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	if tag != "" {
+		return fmt.Sprintf("%s (%s)", tag, commit)
+	}
+	return commit
+}
+
+// UpdatePlan works out what an update of the target dependency would change without touching the
+// package, its vendor directory or its lockfile. The cached copy of each dependency is fetched so
+// the plan reflects the latest upstream versions, if that fails the plan uses what is cached. If
+// the target is empty, every dependency is planned.
+func (pcx *PackageContext) UpdatePlan(ctx context.Context, target versioning.DependencyMeta) (plan Plan, err error) {
+	lock, err := types.ReadLockfile(pcx.Package.LocalPath)
+	if err != nil {
+		return
+	}
+
+	found := false
+	for _, meta := range pcx.AllDependencies {
+		if target.Repo != "" && !sameDependency(meta, target) {
+			continue
+		}
+		found = true
+
+		var change PlannedChange
+		change, err = pcx.planDependency(ctx, meta, lock)
+		if err != nil {
+			err = errors.Wrapf(err, "failed to plan update of %s", meta)
+			return
+		}
+		if change.From == change.To {
+			continue
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	if target.Repo != "" && !found {
+		err = errors.Errorf("%s is not a dependency of %s", target, pcx.Package)
+	}
+
+	return
+}
+
+func (pcx *PackageContext) planDependency(ctx context.Context, meta versioning.DependencyMeta, lock *types.Lockfile) (change PlannedChange, err error) {
+	change.Dependency = meta
+
+	if lock != nil {
+		change.From, _ = lock.Commit(meta)
+	}
+	if change.From == "" {
+		change.From, _ = pcx.vendoredCommit(meta)
+	}
+
+	repo, err := git.PlainOpen(pcx.cachePath(meta))
+	if pcx.Registry.handles(meta) {
+		// registry packages have no remote to fetch from, new versions come from the registry
+		repo, err = pcx.EnsureDependencyCached(ctx, meta, true)
+		if err != nil {
+			return
+		}
+	} else if err != nil {
+		print.Verb(meta, "not cached yet, cloning to plan update")
+		repo, err = pcx.EnsureDependencyCached(ctx, meta, false)
+		if err != nil {
+			return
+		}
+	} else {
+		errFetch := repo.FetchContext(ctx, &git.FetchOptions{Tags: git.AllTags})
+		if errFetch != nil && errFetch != git.NoErrAlreadyUpToDate {
+			if ctx.Err() != nil {
+				return change, ctx.Err()
+			}
+			print.Warn(meta, "failed to fetch latest changes, planning with cached copy:", errFetch)
+		}
+	}
+
+	to, err := resolveLatest(repo, meta)
+	if err != nil {
+		return
+	}
+	change.To = to.String()
+
+	if change.From == change.To {
+		return
+	}
+
+	tags, err := commitTags(repo)
+	if err != nil {
+		return
+	}
+	change.ToTag = firstTag(tags[change.To])
+	change.FromTag = firstTag(tags[change.From])
+
+	change.Commits, change.Notes, err = commitsBetween(repo, change.From, to, tags)
+	if err != nil {
+		return
+	}
+
+	before, err := dependenciesAt(repo, change.From)
+	if err != nil {
+		return
+	}
+	after, err := dependenciesAt(repo, change.To)
+	if err != nil {
+		return
+	}
+	change.Added, change.Removed = diffDependencies(before, after)
+
+	return
+}
+
+// resolveLatest resolves the version constraint of a dependency against the latest fetched state
+// of its cached repository.
+func resolveLatest(repo *git.Repository, meta versioning.DependencyMeta) (hash plumbing.Hash, err error) {
+	var ref *plumbing.Reference
+	switch {
+	case meta.Tag != "":
+		ref, err = versioning.RefFromTag(repo, meta)
+	case meta.Commit != "":
+		return plumbing.NewHash(meta.Commit), nil
+	case meta.Branch != "":
+		ref, err = repo.Reference(plumbing.ReferenceName("refs/remotes/origin/"+meta.Branch), true)
+		if err != nil {
+			ref, err = versioning.RefFromBranch(repo, meta)
+		}
+	default:
+		ref, err = repo.Head()
+		if err == nil && ref.Name().IsBranch() {
+			remote, errRemote := repo.Reference(plumbing.ReferenceName("refs/remotes/origin/"+ref.Name().Short()), true)
+			if errRemote == nil {
+				ref = remote
+			}
+		}
+	}
+	if err != nil {
+		err = errors.Wrap(err, "failed to resolve version constraint")
+		return
+	}
+	return ref.Hash(), nil
+}
+
+// commitTags maps commit hashes to the tags that point at them, annotated tags are peeled
+func commitTags(repo *git.Repository) (tags map[string][]*plumbing.Reference, err error) {
+	iter, err := repo.Tags()
+	if err != nil {
+		err = errors.Wrap(err, "failed to get repo tags")
+		return
+	}
+	defer iter.Close()
+
+	tags = make(map[string][]*plumbing.Reference)
+	err = iter.ForEach(func(pr *plumbing.Reference) error {
+		ref, errInner := versioning.RefFromTagRef(repo, pr)
+		if errInner != nil {
+			return nil
+		}
+		tags[ref.Hash().String()] = append(tags[ref.Hash().String()], pr)
+		return nil
+	})
+	for _, refs := range tags {
+		sort.Slice(refs, func(i, j int) bool { return refs[i].Name() < refs[j].Name() })
+	}
+	return
+}
+
+func firstTag(refs []*plumbing.Reference) string {
+	if len(refs) == 0 {
+		return ""
+	}
+	return refs[0].Name().Short()
+}
+
+// commitsBetween lists the commits reachable from `to` that come after `from`. If `from` is not an
+// ancestor of `to`, such as a downgrade, no commits are listed.
+func commitsBetween(repo *git.Repository, from string, to plumbing.Hash, tags map[string][]*plumbing.Reference) (commits []string, notes map[string]string, err error) {
+	iter, err := repo.Log(&git.LogOptions{From: to})
+	if err != nil {
+		err = errors.Wrap(err, "failed to read commit history")
+		return
+	}
+	defer iter.Close()
+
+	reached := from == ""
+	err = iter.ForEach(func(commit *object.Commit) error {
+		if commit.Hash.String() == from {
+			reached = true
+			return storer.ErrStop
+		}
+
+		if len(commits) < maxPlanCommits {
+			summary := strings.SplitN(strings.TrimSpace(commit.Message), "\n", 2)[0]
+			commits = append(commits, fmt.Sprintf("%s %s", commit.Hash.String()[:7], summary))
+		}
+
+		for _, pr := range tags[commit.Hash.String()] {
+			tag, errTag := repo.TagObject(pr.Hash())
+			if errTag != nil {
+				continue // lightweight tags have no message
+			}
+			if notes == nil {
+				notes = make(map[string]string)
+			}
+			notes[pr.Name().Short()] = strings.TrimSpace(tag.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		err = errors.Wrap(err, "failed to walk commit history")
+		return
+	}
+
+	if !reached {
+		return nil, nil, nil
+	}
+	return
+}
+
+// dependenciesAt reads the dependencies declared by the package definition at a commit, a commit
+// without a package definition has no dependencies.
+func dependenciesAt(repo *git.Repository, hash string) (deps []versioning.DependencyString, err error) {
+	if hash == "" {
+		return
+	}
+
+	commit, err := repo.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		// the commit may only exist in the vendored copy, there's nothing to compare against
+		print.Verb("commit", hash, "not in cached repository:", err)
+		return nil, nil
+	}
+
+	var pkg types.Package
+	if file, errFile := commit.File("pawn.json"); errFile == nil {
+		var contents string
+		contents, err = file.Contents()
+		if err != nil {
+			return
+		}
+		err = json.Unmarshal([]byte(contents), &pkg)
+	} else if file, errFile := commit.File("pawn.yaml"); errFile == nil {
+		var contents string
+		contents, err = file.Contents()
+		if err != nil {
+			return
+		}
+		err = yaml.Unmarshal([]byte(contents), &pkg)
+	}
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read package definition at %s", hash)
+		return
+	}
+
+	return pkg.GetAllDependencies(), nil
+}
+
+func diffDependencies(before, after []versioning.DependencyString) (added, removed []versioning.DependencyString) {
+	old := make(map[versioning.DependencyString]struct{})
+	for _, dep := range before {
+		old[dep] = struct{}{}
+	}
+	now := make(map[versioning.DependencyString]struct{})
+	for _, dep := range after {
+		now[dep] = struct{}{}
+		if _, ok := old[dep]; !ok {
+			added = append(added, dep)
+		}
+	}
+	for _, dep := range before {
+		if _, ok := now[dep]; !ok {
+			removed = append(removed, dep)
+		}
+	}
+	return
+}
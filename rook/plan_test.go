@@ -0,0 +1,106 @@
+package rook
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_UpdatePlan(t *testing.T) {
+	binary, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git command not available")
+	}
+
+	dir := util.FullPath("./tests/plan")
+	os.RemoveAll(dir)
+	cacheDir := filepath.Join(dir, "cache")
+
+	meta := versioning.DependencyMeta{Site: "github.com", User: "test", Repo: "library", Tag: "^1.0.0"}
+	repo := meta.CachePath(cacheDir)
+	os.MkdirAll(repo, 0700) //nolint
+
+	git := func(args ...string) string {
+		cmd := exec.Command(binary, append([]string{"-c", "user.name=test", "-c", "user.email=test@test"}, args...)...)
+		cmd.Dir = repo
+		output, errRun := cmd.CombinedOutput()
+		if errRun != nil {
+			t.Fatal(errRun, string(output))
+		}
+		return strings.TrimSpace(string(output))
+	}
+	commit := func(message, definition string) string {
+		err := ioutil.WriteFile(filepath.Join(repo, "pawn.json"), []byte(definition), 0644)
+		assert.NoError(t, err)
+		git("add", "pawn.json")
+		git("commit", "--allow-empty", "-m", message)
+		return git("rev-parse", "HEAD")
+	}
+
+	git("init", "--quiet")
+	first := commit("Initial release", `{"user": "test", "repo": "library", "dependencies": ["test/old"]}`)
+	git("tag", "1.0.0")
+	commit("Fix a bug\n\nWith a longer description.", `{"user": "test", "repo": "library", "dependencies": ["test/old"]}`)
+	last := commit("Replace old with new", `{"user": "test", "repo": "library", "dependencies": ["test/new"]}`)
+	git("tag", "-a", "1.1.0", "-m", "Version 1.1.0\n\nold has been replaced by new")
+	git("tag", "2.0.0")
+
+	os.MkdirAll(filepath.Join(dir, "package"), 0700) //nolint
+	lock := types.NewLockfile([]types.LockedDependency{
+		{Dependency: versioning.DependencyString(meta.String()), Commit: first},
+	})
+	assert.NoError(t, lock.Write(filepath.Join(dir, "package")))
+
+	pcx := PackageContext{
+		Package:         types.Package{LocalPath: filepath.Join(dir, "package")},
+		AllDependencies: []versioning.DependencyMeta{meta},
+		CacheDir:        cacheDir,
+	}
+
+	plan, err := pcx.UpdatePlan(context.Background(), versioning.DependencyMeta{})
+	assert.NoError(t, err)
+	if !assert.Len(t, plan.Changes, 1) {
+		return
+	}
+	change := plan.Changes[0]
+
+	assert.Equal(t, first, change.From)
+	assert.Equal(t, last, change.To)
+	assert.Equal(t, "1.0.0", change.FromTag)
+	assert.Equal(t, "1.1.0", change.ToTag)
+	assert.Len(t, change.Commits, 2)
+	assert.Contains(t, change.Commits[0], "Replace old with new")
+	assert.Contains(t, change.Commits[1], "Fix a bug")
+	assert.Equal(t, map[string]string{"1.1.0": "Version 1.1.0\n\nold has been replaced by new"}, change.Notes)
+	assert.Equal(t, []versioning.DependencyString{"test/new"}, change.Added)
+	assert.Equal(t, []versioning.DependencyString{"test/old"}, change.Removed)
+
+	// nothing was touched
+	after, err := types.ReadLockfile(filepath.Join(dir, "package"))
+	assert.NoError(t, err)
+	assert.Empty(t, lock.Diff(*after))
+	assert.False(t, util.Exists(filepath.Join(dir, "package", "dependencies")))
+
+	_, err = pcx.UpdatePlan(context.Background(), versioning.DependencyMeta{User: "test", Repo: "other"})
+	assert.Error(t, err)
+
+	// a dependency that is already at the version its constraint resolves to has no changes
+	pcx.AllDependencies[0].Tag = "1.0.0"
+	lock = types.NewLockfile([]types.LockedDependency{
+		{Dependency: versioning.DependencyString(pcx.AllDependencies[0].String()), Commit: first},
+	})
+	assert.NoError(t, lock.Write(filepath.Join(dir, "package")))
+	plan, err = pcx.UpdatePlan(context.Background(), meta)
+	assert.NoError(t, err)
+	assert.Empty(t, plan.Changes)
+}
@@ -0,0 +1,42 @@
+package rook
+
+import (
+	"strings"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// platformOf returns the only platform the package uses a dependency on, or an empty string if the
+// dependency is used on every platform
+func (pcx *PackageContext) platformOf(meta versioning.DependencyMeta) string {
+	for dependency, platform := range pcx.Package.Platforms {
+		if strings.EqualFold(dependency, meta.User+"/"+meta.Repo) {
+			return platform
+		}
+	}
+	return ""
+}
+
+// otherPlatform checks whether a dependency is only used on a platform other than the target, these
+// are neither ensured nor included
+func (pcx *PackageContext) otherPlatform(meta versioning.DependencyMeta) bool {
+	platform := pcx.platformOf(meta)
+	return platform != "" && platform != pcx.Platform
+}
+
+// lockedOtherPlatforms returns the lockfile entries of the dependencies that are only used on other
+// platforms. They can't be resolved on this platform so their entries are kept as they are, which
+// lets the lockfile be written on any platform without dropping them.
+func (pcx *PackageContext) lockedOtherPlatforms(lock types.Lockfile) (locked []types.LockedDependency) {
+	for _, meta := range pcx.OtherPlatforms {
+		dependency := versioning.DependencyString(meta.String())
+		for _, entry := range lock.Dependencies {
+			if entry.Dependency == dependency {
+				entry.Platform = pcx.platformOf(meta)
+				locked = append(locked, entry)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,55 @@
+package rook
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_otherPlatforms(t *testing.T) {
+	vendor := util.FullPath("./tests/platform")
+	os.RemoveAll(vendor)
+	commitVersions(t, filepath.Join(vendor, "both"), []string{"1.0.0"})
+	commitVersions(t, filepath.Join(vendor, "linux"), []string{"1.0.0"})
+
+	both := versioning.DependencyMeta{User: "test", Repo: "both"}
+	linux := versioning.DependencyMeta{User: "test", Repo: "linux"}
+	windows := versioning.DependencyMeta{User: "test", Repo: "windows"}
+	pcx := PackageContext{
+		Package: types.Package{
+			Vendor: vendor,
+			Platforms: map[string]string{
+				"Test/Linux":   "linux",
+				"test/windows": "windows",
+			},
+		},
+		Platform:        "linux",
+		AllDependencies: []versioning.DependencyMeta{both, linux},
+		OtherPlatforms:  []versioning.DependencyMeta{windows},
+	}
+
+	assert.False(t, pcx.otherPlatform(both))
+	assert.False(t, pcx.otherPlatform(linux))
+	assert.True(t, pcx.otherPlatform(windows))
+
+	lock, err := pcx.ResolveLockfile()
+	assert.NoError(t, err)
+	assert.Len(t, lock.Dependencies, 2)
+	assert.Equal(t, "", lock.Dependencies[0].Platform)
+	assert.Equal(t, "linux", lock.Dependencies[1].Platform)
+
+	// the entries locked on other platforms are kept and aren't seen as changes
+	previous := types.NewLockfile(append(lock.Dependencies, types.LockedDependency{
+		Dependency: "test/windows",
+		Commit:     "abc",
+		Platform:   "windows",
+	}))
+	assert.Equal(t, []types.LockedDependency{previous.Dependencies[2]}, pcx.lockedOtherPlatforms(previous))
+	assert.Empty(t, pcx.lockfileChanges(previous))
+}
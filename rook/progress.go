@@ -0,0 +1,111 @@
+package rook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// ensureProgress is the set of dependencies that an ensure has vendored so far, stored in the
+// `.sampctl` directory of the package after each one. If the ensure is interrupted, the next one
+// with the same resolution key resumes from where it stopped instead of updating them all again.
+// It is removed once every dependency has been ensured.
+type ensureProgress struct {
+	Key      string            `json:"key"`
+	Vendored map[string]string `json:"vendored"` // dependency strings mapped to the commit they were vendored at
+}
+
+func (pcx *PackageContext) progressPath() string {
+	return filepath.Join(pcx.Package.LocalPath, ".sampctl", "ensure-progress.json")
+}
+
+// readEnsureProgress returns the progress of an interrupted ensure with the same resolution key or
+// empty progress for the key if there is none. If `resume` is false, the progress of an interrupted
+// ensure is removed and empty progress is returned.
+func (pcx *PackageContext) readEnsureProgress(resume bool) (progress ensureProgress) {
+	key, err := pcx.resolutionKey()
+	if err != nil {
+		print.Verb(pcx.Package, "can't resume ensure:", err)
+	}
+	progress = ensureProgress{Key: key, Vendored: make(map[string]string)}
+	if !resume {
+		pcx.clearEnsureProgress()
+		return
+	}
+	if key == "" {
+		return
+	}
+
+	contents, err := ioutil.ReadFile(pcx.progressPath())
+	if err != nil {
+		return
+	}
+	var previous ensureProgress
+	if err = json.Unmarshal(contents, &previous); err != nil {
+		print.Verb(pcx.Package, "ignoring invalid ensure progress:", err)
+		return
+	}
+	if previous.Key != key || previous.Vendored == nil {
+		print.Verb(pcx.Package, "package changed since the last ensure was interrupted, not resuming it")
+		return
+	}
+	print.Verb(pcx.Package, "resuming interrupted ensure,", len(previous.Vendored), "dependencies were already vendored")
+	return previous
+}
+
+// resumable is true if an interrupted ensure already vendored a dependency and its vendored copy is
+// a complete clone still checked out at the commit it was vendored at
+func (pcx *PackageContext) resumable(progress ensureProgress, meta versioning.DependencyMeta) bool {
+	commit, ok := progress.Vendored[meta.String()]
+	if !ok {
+		return false
+	}
+	if !util.Exists(filepath.Join(pcx.Package.Vendor, meta.VendorName(), ".git", cloneMarker)) {
+		return false
+	}
+	head, err := pcx.vendoredCommit(meta)
+	return err == nil && head == commit
+}
+
+// recordEnsureProgress adds a vendored dependency to the progress and writes it
+func (pcx *PackageContext) recordEnsureProgress(progress ensureProgress, meta versioning.DependencyMeta) (err error) {
+	if progress.Key == "" {
+		return
+	}
+	commit, err := pcx.vendoredCommit(meta)
+	if err != nil {
+		return
+	}
+	progress.Vendored[meta.String()] = commit
+
+	contents, err := json.Marshal(progress)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode ensure progress")
+	}
+	path := pcx.progressPath()
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return errors.Wrap(err, "failed to create .sampctl directory")
+	}
+	// write to a temporary file first so an interruption never leaves a partial file
+	err = ioutil.WriteFile(path+".tmp", contents, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write ensure progress")
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// clearEnsureProgress removes the progress once every dependency has been ensured
+func (pcx *PackageContext) clearEnsureProgress() {
+	err := os.Remove(pcx.progressPath())
+	if err != nil && !os.IsNotExist(err) {
+		print.Verb(pcx.Package, "failed to remove ensure progress:", err)
+	}
+}
@@ -0,0 +1,97 @@
+package rook
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_ensureProgress(t *testing.T) {
+	dir := util.FullPath("./tests/progress")
+	os.RemoveAll(dir)
+
+	lib := versioning.DependencyMeta{Site: "github.com", User: "test", Repo: "lib"}
+	other := versioning.DependencyMeta{Site: "github.com", User: "test", Repo: "other"}
+	pcx := PackageContext{
+		Package:         types.Package{LocalPath: dir, Vendor: filepath.Join(dir, "dependencies")},
+		AllDependencies: []versioning.DependencyMeta{lib, other},
+	}
+	vendored := filepath.Join(pcx.Package.Vendor, "lib")
+	commitVersions(t, vendored, []string{"v1.0.0"})
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(vendored, ".git", cloneMarker), nil, 0600))
+
+	// nothing to resume at first
+	progress := pcx.readEnsureProgress(true)
+	assert.NotEmpty(t, progress.Key)
+	assert.Empty(t, progress.Vendored)
+	assert.False(t, pcx.resumable(progress, lib))
+
+	// the next ensure resumes with the dependencies that were recorded
+	assert.NoError(t, pcx.recordEnsureProgress(progress, lib))
+	progress = pcx.readEnsureProgress(true)
+	assert.True(t, pcx.resumable(progress, lib))
+	assert.False(t, pcx.resumable(progress, other))
+
+	// unless the vendored copy moved away from the recorded commit
+	os.RemoveAll(vendored)
+	commitVersions(t, vendored, []string{"v1.0.0", "v1.1.0"})
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(vendored, ".git", cloneMarker), nil, 0600))
+	assert.False(t, pcx.resumable(progress, lib))
+
+	// or the package changed since
+	assert.NoError(t, pcx.recordEnsureProgress(progress, lib))
+	pcx.Package.Dependencies = []versioning.DependencyString{"test/lib:1.1.0"}
+	assert.False(t, pcx.resumable(pcx.readEnsureProgress(true), lib))
+
+	// and an update starts over
+	assert.NoError(t, pcx.recordEnsureProgress(progress, lib))
+	assert.Empty(t, pcx.readEnsureProgress(false).Vendored)
+	assert.False(t, util.Exists(pcx.progressPath()))
+}
+
+func TestPackageContext_EnsureDependenciesUpdateAfterFailure(t *testing.T) {
+	dir := util.FullPath("./tests/progress-update")
+	os.RemoveAll(dir)
+	source := filepath.Join(dir, "source")
+	run := sourceRepo(t, source)
+	write := func(contents string) {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(source, "include", "lib.inc"), []byte(contents), 0644))
+	}
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(source, "include"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(source, "pawn.json"), []byte(`{"user": "test", "repo": "lib"}`), 0644))
+	write("// 1.0.0\n")
+	run("add", "-A")
+	run("commit", "--quiet", "-m", "Initial release")
+
+	// the broken dependency asks for a tag that doesn't exist, so the first ensure fails part way
+	lib := versioning.DependencyMeta{Site: "github.com", User: "test", Repo: "lib", Path: "include"}
+	broken := versioning.DependencyMeta{Site: "github.com", User: "test", Repo: "broken", Path: "include", Tag: "9.9.9"}
+	defer cloneFrom(map[string]string{lib.URL(): source, broken.URL(): source})()
+
+	pcx := PackageContext{
+		Package:         types.Package{LocalPath: dir},
+		CacheDir:        filepath.Join(dir, "cache"),
+		AllDependencies: []versioning.DependencyMeta{lib, broken},
+	}
+	assert.NoError(t, pcx.EnsureDependencies(context.Background(), false))
+	assert.True(t, util.Exists(pcx.progressPath()))
+
+	// an update afterwards still fetches the dependency that was vendored before
+	write("// 1.1.0\n")
+	run("commit", "--quiet", "-am", "Next release")
+	latest := run("rev-parse", "HEAD")
+
+	assert.NoError(t, pcx.EnsureDependencies(context.Background(), true))
+	commit, err := pcx.vendoredCommit(lib)
+	assert.NoError(t, err)
+	assert.Equal(t, latest, commit)
+}
@@ -0,0 +1,64 @@
+package rook
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// Clone protocols that dependencies can be cloned with
+const (
+	ProtocolHTTPS = "https"
+	ProtocolSSH   = "ssh"
+)
+
+var (
+	cloneProtocol  = ProtocolHTTPS
+	cloneProtocols map[string]string
+)
+
+// SetCloneProtocols configures how dependencies are cloned, either over HTTPS or over SSH with the
+// `git` user, by default and for each host. SSH reuses key based authentication instead of needing
+// a token. Only cloning is affected, tags and releases are still listed through the host's API.
+func SetCloneProtocols(protocol string, hosts map[string]string) (err error) {
+	if protocol == "" {
+		protocol = ProtocolHTTPS
+	}
+	if err = checkProtocol(protocol); err != nil {
+		return
+	}
+	byHost := make(map[string]string)
+	for host, hostProtocol := range hosts {
+		if err = checkProtocol(hostProtocol); err != nil {
+			return errors.Wrapf(err, "invalid clone protocol for %s", host)
+		}
+		byHost[strings.ToLower(host)] = hostProtocol
+	}
+	cloneProtocol, cloneProtocols = protocol, byHost
+	return
+}
+
+func checkProtocol(protocol string) error {
+	if protocol != ProtocolHTTPS && protocol != ProtocolSSH {
+		return errors.Errorf("unknown clone protocol %s, must be %s or %s", protocol, ProtocolHTTPS, ProtocolSSH)
+	}
+	return nil
+}
+
+// cloneURL returns the URL a dependency is cloned from and whether it's cloned over SSH. A
+// dependency string that is an SSH URL is always cloned over SSH, others use the protocol for their
+// host.
+func cloneURL(meta versioning.DependencyMeta) (url string, ssh bool) {
+	if meta.SSH == "" {
+		protocol, ok := cloneProtocols[strings.ToLower(meta.Site)]
+		if !ok {
+			protocol = cloneProtocol
+		}
+		if protocol == ProtocolSSH {
+			meta.SSH = "git"
+		}
+	}
+	return meta.URL(), meta.SSH != ""
+}
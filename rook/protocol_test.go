@@ -0,0 +1,49 @@
+package rook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestCloneURL(t *testing.T) {
+	defer SetCloneProtocols("", nil) // nolint
+
+	github := versioning.DependencyMeta{Site: "github.com", User: "user", Repo: "repo"}
+	gitlab := versioning.DependencyMeta{Site: "gitlab.com", User: "user", Repo: "repo"}
+	sshMeta := versioning.DependencyMeta{Site: "github.com", User: "user", Repo: "repo", SSH: "git"}
+
+	tests := []struct {
+		name      string
+		protocol  string
+		hosts     map[string]string
+		meta      versioning.DependencyMeta
+		wantURL   string
+		wantSSH   bool
+		wantError bool
+	}{
+		{"default", "", nil, github, "https://github.com/user/repo", false, false},
+		{"ssh", "ssh", nil, github, "git@github.com:user/repo", true, false},
+		{"host", "https", map[string]string{"GitLab.com": "ssh"}, gitlab, "git@gitlab.com:user/repo", true, false},
+		{"host over default", "ssh", map[string]string{"github.com": "https"}, github, "https://github.com/user/repo", false, false},
+		{"ssh string", "https", nil, sshMeta, "git@github.com:user/repo", true, false},
+		{"unknown", "git", nil, github, "", false, true},
+		{"unknown host", "", map[string]string{"github.com": "ftp"}, github, "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, SetCloneProtocols("", nil))
+			err := SetCloneProtocols(tt.protocol, tt.hosts)
+			if tt.wantError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			url, ssh := cloneURL(tt.meta)
+			assert.Equal(t, tt.wantURL, url)
+			assert.Equal(t, tt.wantSSH, ssh)
+		})
+	}
+}
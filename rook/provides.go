@@ -0,0 +1,154 @@
+package rook
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// includeExtensions are the extensions the compiler tries, in order, for an include without one
+var includeExtensions = []string{".inc", ".p", ".pawn"}
+
+// Provides returns the dependencies of a package, direct or not, that have an include file which
+// `#include <name>` would find in their include path. The name may use either slash and may be
+// wrapped in the brackets or quotes of the directive. Dependencies are read from the vendor
+// directory so the package should be ensured first, includes provided by resources aren't checked.
+func Provides(pkg types.Package, includeName string) (providers []versioning.DependencyMeta, err error) {
+	if pkg.Vendor == "" {
+		err = errors.New("package has no vendor directory")
+		return
+	}
+	name := normaliseIncludeName(includeName)
+	if name == "" {
+		err = errors.Errorf("invalid include name %s", includeName)
+		return
+	}
+
+	var (
+		queue []versioning.DependencyString
+		seen  = make(map[string]bool)
+	)
+	if pkg.Parent {
+		queue = pkg.GetAllDependencies()
+	} else {
+		queue = pkg.Dependencies
+	}
+	for len(queue) > 0 {
+		depString := queue[0]
+		queue = queue[1:]
+
+		meta, errInner := depString.Explode()
+		if errInner != nil {
+			print.Verb(pkg, "invalid dependency string:", depString, errInner)
+			continue
+		}
+		key := dependencyKey(meta)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		meta.Alias = aliasIn(pkg, meta)
+		if namespace, ok := pkg.Namespaces[meta.User+"/"+meta.Repo]; ok {
+			meta.Namespace = namespace
+		}
+		depDir := meta.PackageDir(filepath.Join(pkg.Vendor, meta.VendorName()))
+		if !util.Exists(depDir) {
+			print.Verb(meta, "is not vendored, its includes can't be checked")
+			continue
+		}
+
+		includePath := ""
+		var extraPaths []string
+		inner, errInner := types.PackageFromDir(depDir)
+		if errInner == nil {
+			includePath = inner.IncludePath
+			extraPaths = inner.IncludePaths
+			queue = append(queue, inner.Dependencies...)
+		}
+		if includePath == "" {
+			includePath = DetectIncludePath(depDir)
+		}
+
+		if providesInclude(meta, filepath.Join(depDir, includePath), name) {
+			providers = append(providers, meta)
+			continue
+		}
+		for _, extraPath := range extraPaths {
+			if hasInclude(filepath.Join(depDir, extraPath), name) {
+				providers = append(providers, meta)
+				break
+			}
+		}
+	}
+	return
+}
+
+// SuggestProviders returns the packages in an index that declare an include pattern which matches
+// an include name, for includes that no dependency provides
+func SuggestProviders(index types.Index, includeName string) (suggestions []versioning.DependencyString) {
+	name := strings.Replace(normaliseIncludeName(includeName), "/", `\`, -1)
+	seen := make(map[versioning.DependencyString]bool)
+	for pattern, dep := range IndexIncludes(index) {
+		matcher, err := regexp.Compile(fmt.Sprintf(`^(?:%s)$`, pattern))
+		if err != nil {
+			print.Verb("Invalid include pattern", pattern, "for", dep)
+			continue
+		}
+		if (matcher.MatchString(name) || matcher.MatchString(strings.Replace(name, `\`, "/", -1))) && !seen[dep] {
+			seen[dep] = true
+			suggestions = append(suggestions, dep)
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool { return suggestions[i] < suggestions[j] })
+	return
+}
+
+// normaliseIncludeName strips the brackets or quotes from an include name and uses forward slashes
+func normaliseIncludeName(name string) string {
+	name = strings.TrimSpace(name)
+	name = strings.TrimPrefix(name, "#include")
+	name = strings.Trim(strings.TrimSpace(name), `<>"`)
+	return strings.Replace(name, `\`, "/", -1)
+}
+
+// providesInclude is true if a dependency with its includes in a directory provides an include,
+// namespaced dependencies only provide includes under their namespace and aliased dependencies
+// also provide their alias
+func providesInclude(meta versioning.DependencyMeta, dir, name string) bool {
+	if meta.Namespace != "" {
+		prefix := strings.ToLower(meta.Namespace + "/" + meta.Repo + "/")
+		if !strings.HasPrefix(strings.ToLower(name), prefix) {
+			return false
+		}
+		name = name[len(prefix):]
+	}
+	if meta.Alias != "" && strings.EqualFold(strings.TrimSuffix(name, ".inc"), meta.Alias) {
+		return true
+	}
+	return hasInclude(dir, name)
+}
+
+// hasInclude is true if an include directory has a file that an include name resolves to
+func hasInclude(dir, name string) bool {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return true
+	}
+	for _, ext := range includeExtensions {
+		if util.Exists(path + ext) {
+			return true
+		}
+	}
+	return false
+}
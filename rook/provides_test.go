@@ -0,0 +1,87 @@
+package rook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestProvides(t *testing.T) {
+	vendor := util.FullPath("./tests/provides/dependencies")
+	os.RemoveAll(vendor)
+
+	files := map[string]string{
+		"lib-a/pawn.json":               `{"user": "test", "repo": "lib-a", "include_path": "include", "dependencies": ["test/lib-c"]}`,
+		"lib-a/include/a_lib.inc":       "",
+		"lib-a/include/things/util.inc": "",
+		"lib-b/pawn.json":               `{"user": "test", "repo": "lib-b"}`,
+		"lib-b/pawno/include/a_lib.inc": "",
+		"lib-c/pawn.json":               `{"user": "test", "repo": "lib-c"}`,
+		"lib-c/lib_c.inc":               "",
+		"named/pawn.json":               `{"user": "test", "repo": "lib-d"}`,
+		"named/lib_d.inc":               "",
+	}
+	for name, contents := range files {
+		filename := filepath.Join(vendor, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filename), 0755))
+		assert.NoError(t, ioutil.WriteFile(filename, []byte(contents), 0644))
+	}
+
+	pkg := types.Package{
+		Parent: true,
+		Vendor: vendor,
+		Dependencies: []versioning.DependencyString{
+			"test/lib-a",
+			"test/lib-b",
+			"test/lib-d",
+			"test/lib-e",
+		},
+		Aliases:    map[string]string{"test/lib-d": "named"},
+		Namespaces: map[string]string{"test/lib-b": "other"},
+	}
+
+	tests := []struct {
+		name    string
+		include string
+		want    []string
+	}{
+		{"include path", "<a_lib>", []string{"test/lib-a"}},
+		{"subdirectory", `things\util`, []string{"test/lib-a"}},
+		{"extension", "things/util.inc", []string{"test/lib-a"}},
+		{"transitive", "lib_c", []string{"test/lib-c"}},
+		{"namespaced", "other/lib-b/a_lib", []string{"test/lib-b"}},
+		{"aliased", "named", []string{"test/lib-d"}},
+		{"missing", "nothing", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providers, err := Provides(pkg, tt.include)
+			assert.NoError(t, err)
+			var got []string
+			for _, meta := range providers {
+				got = append(got, meta.User+"/"+meta.Repo)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := Provides(pkg, "<>")
+	assert.Error(t, err)
+}
+
+func TestSuggestProviders(t *testing.T) {
+	index := types.Index{Packages: []types.IndexEntry{
+		{User: "pawn-lang", Repo: "YSI-Includes", Includes: []string{`YSI\\.+`, `YSI_.+`}},
+		{User: "samp-incognito", Repo: "samp-streamer-plugin", Includes: []string{`streamer`}},
+	}}
+	assert.Equal(t, []versioning.DependencyString{"pawn-lang/YSI-Includes"}, SuggestProviders(index, `<YSI_Coding\y_hooks>`))
+	assert.Equal(t, []versioning.DependencyString{"samp-incognito/samp-streamer-plugin"}, SuggestProviders(index, "streamer"))
+	assert.Nil(t, SuggestProviders(index, "streamer2"))
+}
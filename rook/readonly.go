@@ -0,0 +1,53 @@
+package rook
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/types"
+)
+
+var readOnlyVendor bool
+
+// SetReadOnlyVendor sets whether new package contexts treat the vendor directory as read-only, such
+// as a pre-populated one mounted into a CI container. Dependencies are resolved from the vendored
+// copies instead of the cache, nothing is ensured, cloned or written to the vendor directory and a
+// build fails if anything it needs isn't vendored.
+func SetReadOnlyVendor(readOnly bool) {
+	readOnlyVendor = readOnly
+}
+
+// errReadOnlyVendor is returned by operations that would have to change the vendor directory
+func errReadOnlyVendor(operation string) error {
+	return errors.Errorf("the vendor directory is read-only, dependencies can't be %s", operation)
+}
+
+// presentRoot returns the directory that the includes of aliased or namespaced dependencies are
+// presented in, such as `.aliases`. It's in the vendor directory unless that's read-only, then it's
+// in the `.sampctl` directory of the package instead.
+func (pcx *PackageContext) presentRoot(name string) string {
+	if pcx.ReadOnlyVendor {
+		return filepath.Join(pcx.Package.LocalPath, ".sampctl", strings.TrimPrefix(name, "."))
+	}
+	return filepath.Join(pcx.Package.Vendor, name)
+}
+
+// providesPlugins is true if a package has resources for the platform that are plugins, either
+// plugin binaries on their own or archives with includes that also contain plugins
+func providesPlugins(pkg types.Package, platform string) bool {
+	for _, resource := range pkg.Resources {
+		if resource.Platform != platform {
+			continue
+		}
+		if len(resource.Includes) == 0 {
+			if strings.Contains(resource.Name, "dll") || strings.Contains(resource.Name, "so") {
+				return true
+			}
+		} else if len(resource.Plugins) > 0 {
+			return true
+		}
+	}
+	return false
+}
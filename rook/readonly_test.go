@@ -0,0 +1,85 @@
+package rook
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestReadOnlyVendor(t *testing.T) {
+	dir := util.FullPath("./tests/read-only")
+	os.RemoveAll(dir)
+	vendor := filepath.Join(dir, "dependencies")
+	os.MkdirAll(filepath.Join(vendor, "d"), 0700)
+	os.MkdirAll(filepath.Join(vendor, "transitive"), 0700)
+	ioutil.WriteFile(filepath.Join(dir, "pawn.json"), []byte(`{
+	"entry": "main.pwn",
+	"output": "main.amx",
+	"dependencies": ["someone/dep"],
+	"aliases": {"someone/dep": "d"}
+}`), 0600)
+	ioutil.WriteFile(filepath.Join(vendor, "d", "pawn.json"), []byte(`{
+	"dependencies": ["someone/transitive"],
+	"resources": [{"name": "^dep.so$", "platform": "linux"}]
+}`), 0600)
+	ioutil.WriteFile(filepath.Join(vendor, "d", "dep.inc"), nil, 0600)
+
+	SetReadOnlyVendor(true)
+	defer SetReadOnlyVendor(false)
+
+	// dependencies are resolved from the vendored copies without anything in the cache
+	pcx, err := NewPackageContext(context.Background(), nil, nil, true, dir, "linux", "./tests/read-only-cache", "")
+	assert.NoError(t, err)
+	assert.True(t, pcx.ReadOnlyVendor)
+	assert.Equal(t, []string{"someone/dep", "someone/transitive"}, dependencyNames(pcx.AllDependencies))
+	assert.Equal(t, []string{"someone/dep"}, dependencyNames(pcx.AllPlugins))
+	assert.False(t, util.Exists(util.FullPath("./tests/read-only-cache")))
+
+	// aliases are presented outside of the vendor directory
+	config, err := pcx.buildPrepare(context.Background(), "default", false, false)
+	assert.NoError(t, err)
+	assert.Contains(t, config.Includes, filepath.Join(dir, ".sampctl", "aliases"))
+	assert.True(t, util.Exists(filepath.Join(dir, ".sampctl", "aliases", "d.inc")))
+	assert.False(t, util.Exists(filepath.Join(vendor, ".aliases")))
+
+	// nothing is ensured, even when it's asked for
+	ensure, err := pcx.checkStale(true)
+	assert.NoError(t, err)
+	assert.False(t, ensure)
+	assert.Error(t, pcx.EnsureDependencies(context.Background(), false))
+	_, err = pcx.Verify(context.Background(), true)
+	assert.Error(t, err)
+
+	// stale dependencies can't be ensured, so they fail the build
+	os.RemoveAll(filepath.Join(vendor, "transitive"))
+	pcx.Stale = StaleEnsure
+	_, err = pcx.checkStale(false)
+	assert.Error(t, err)
+
+	// and a dependency that isn't vendored fails to resolve
+	_, err = NewPackageContext(context.Background(), nil, nil, true, dir, "linux", "./tests/read-only-cache", "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "someone/transitive is not vendored")
+}
+
+func TestPackageContext_presentRoot(t *testing.T) {
+	pcx := PackageContext{Package: types.Package{LocalPath: "pkg", Vendor: filepath.Join("pkg", "dependencies")}}
+	assert.Equal(t, filepath.Join("pkg", "dependencies", ".namespaces"), pcx.presentRoot(".namespaces"))
+	pcx.ReadOnlyVendor = true
+	assert.Equal(t, filepath.Join("pkg", ".sampctl", "namespaces"), pcx.presentRoot(".namespaces"))
+}
+
+func dependencyNames(deps []versioning.DependencyMeta) (names []string) {
+	for _, dep := range deps {
+		names = append(names, dep.User+"/"+dep.Repo)
+	}
+	return
+}
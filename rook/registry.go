@@ -0,0 +1,356 @@
+package rook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// Registry is a package registry that serves versions of packages as archives instead of git
+// repositories. Dependencies without an explicit host resolve through the registry once one is set.
+//
+// The registry lists the versions of a package at `<url>/packages/<user>/<repo>/versions` as a JSON
+// array of objects with `version`, `archive` and `published` fields. The archive may be relative to
+// the listing and can be any format sampctl extracts. If every file in it is in the same directory,
+// that directory is treated as the package root.
+//
+// To reuse the tag, branch and lockfile handling of git dependencies, the cached copy of a registry
+// package is a git repository with a commit and tag per version, in the order they were published.
+// Commits are made with fixed details so they have the same hash on every machine.
+type Registry struct {
+	URL      string
+	Site     string // the host of the URL, which dependencies through the registry have as their site
+	Token    string
+	Username string
+	Password string
+
+	client *http.Client // the client requests are made with, the default client if nil
+}
+
+// RegistryVersion is a version of a package as listed by a registry
+type RegistryVersion struct {
+	Version   string    `json:"version"`
+	Archive   string    `json:"archive"`
+	Published time.Time `json:"published"`
+}
+
+var (
+	// defaultRegistry is the registry new package contexts use, set with `SetRegistry`
+	defaultRegistry *Registry
+
+	// matchRegistryVersion matches versions that are safe to use as tag names
+	matchRegistryVersion = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+)
+
+// SetRegistry configures the package registry that new package contexts resolve dependencies
+// without a host through, a nil config or one without a URL means they resolve to GitHub.
+func SetRegistry(cfg *types.RegistryConfig) (err error) {
+	r, err := NewRegistry(cfg)
+	if err != nil {
+		return
+	}
+	defaultRegistry = r
+	return
+}
+
+// NewRegistry creates a registry from its config, it's nil if the config has no URL. Credentials are
+// only sent to registries served over HTTPS.
+func NewRegistry(cfg *types.RegistryConfig) (r *Registry, err error) {
+	if cfg == nil || cfg.URL == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid registry URL")
+	}
+	if u.Host == "" {
+		return nil, errors.Errorf("registry URL %s has no host", cfg.URL)
+	}
+	if u.Scheme != "https" && (cfg.Token != "" || cfg.Username != "") {
+		return nil, errors.Errorf("registry URL %s is not HTTPS, refusing to send the registry credentials in plain text", cfg.URL)
+	}
+
+	return &Registry{
+		URL:      strings.TrimSuffix(cfg.URL, "/"),
+		Site:     u.Host,
+		Token:    cfg.Token,
+		Username: cfg.Username,
+		Password: cfg.Password,
+	}, nil
+}
+
+// handles returns true if the dependency resolves through the registry
+func (r *Registry) handles(meta versioning.DependencyMeta) bool {
+	return r != nil && meta.SSH == "" && meta.Site == r.Site
+}
+
+// explode splits a dependency string, those without a host resolve through the registry if the
+// package context has one and to GitHub otherwise
+func (pcx PackageContext) explode(dep versioning.DependencyString) (versioning.DependencyMeta, error) {
+	if pcx.Registry != nil {
+		return dep.ExplodeSite(pcx.Registry.Site)
+	}
+	return dep.Explode()
+}
+
+// cachePath returns where the cached copy of a dependency is kept, registry packages are kept apart
+// from git packages since the same user and repository may exist on both.
+func (pcx PackageContext) cachePath(meta versioning.DependencyMeta) string {
+	if pcx.Registry.handles(meta) {
+		return filepath.Join(pcx.CacheDir, "registry", strings.Replace(pcx.Registry.Site, ":", "_", -1), meta.User, meta.Repo)
+	}
+	return meta.CachePath(pcx.CacheDir)
+}
+
+// ensure makes sure the cached repository of a registry package exists, and if `update` is set,
+// that it has every version the registry lists.
+func (r *Registry) ensure(ctx context.Context, meta versioning.DependencyMeta, dir string, update bool) (repo *git.Repository, err error) {
+	repo, err = git.PlainOpen(dir)
+	if err == nil && !update {
+		return
+	}
+	if err == git.ErrRepositoryNotExists {
+		print.Verb(meta, "creating repository for registry package at", dir)
+		err = os.RemoveAll(dir)
+		if err == nil {
+			err = os.MkdirAll(dir, 0700)
+		}
+		if err == nil {
+			repo, err = git.PlainInit(dir, false)
+		}
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open repository for registry package")
+	}
+
+	err = r.sync(ctx, repo, meta, dir)
+	return
+}
+
+// sync commits and tags each version the registry lists that the repository doesn't have yet
+func (r *Registry) sync(ctx context.Context, repo *git.Repository, meta versioning.DependencyMeta, dir string) (err error) {
+	versions, err := r.versions(ctx, meta)
+	if err != nil {
+		return
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return errors.Wrap(err, "failed to get repo worktree")
+	}
+
+	for _, version := range versions {
+		tag := plumbing.ReferenceName("refs/tags/" + version.Version)
+		if _, errTag := repo.Reference(tag, false); errTag == nil {
+			continue
+		}
+
+		print.Verb(meta, "adding version", version.Version, "from registry")
+		err = r.extract(ctx, version.Archive, dir)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get version %s", version.Version)
+		}
+
+		var status git.Status
+		status, err = wt.Status()
+		if err != nil {
+			return errors.Wrap(err, "failed to get worktree status")
+		}
+		for file, s := range status {
+			if s.Worktree == git.Deleted {
+				_, err = wt.Remove(file)
+			} else {
+				_, err = wt.Add(file)
+			}
+			if err != nil {
+				return errors.Wrapf(err, "failed to stage %s", file)
+			}
+		}
+
+		signature := &object.Signature{Name: "sampctl", Email: "registry@" + r.Site, When: version.Published.UTC()}
+		var hash plumbing.Hash
+		hash, err = wt.Commit(version.Version, &git.CommitOptions{Author: signature, Committer: signature})
+		if err != nil {
+			return errors.Wrapf(err, "failed to commit version %s", version.Version)
+		}
+		err = repo.Storer.SetReference(plumbing.NewHashReference(tag, hash))
+		if err != nil {
+			return errors.Wrapf(err, "failed to tag version %s", version.Version)
+		}
+	}
+	return
+}
+
+// versions lists the versions of a package in the order they were published
+func (r *Registry) versions(ctx context.Context, meta versioning.DependencyMeta) (versions []RegistryVersion, err error) {
+	location := fmt.Sprintf("%s/packages/%s/%s/versions", r.URL, url.PathEscape(meta.User), url.PathEscape(meta.Repo))
+	resp, err := r.get(ctx, location)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close() // nolint
+
+	err = json.NewDecoder(resp.Body).Decode(&versions)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode versions of %s", meta)
+	}
+	if len(versions) == 0 {
+		return nil, errors.Errorf("registry has no versions of %s", meta)
+	}
+
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid registry URL")
+	}
+	for i, version := range versions {
+		if version.Version == "" || version.Archive == "" {
+			return nil, errors.Errorf("registry listed a version of %s without a version or archive", meta)
+		}
+		if !matchRegistryVersion.MatchString(version.Version) {
+			return nil, errors.Errorf("registry listed an invalid version of %s: %s", meta, version.Version)
+		}
+		var archive *url.URL
+		archive, err = url.Parse(version.Archive)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid archive URL for %s %s", meta, version.Version)
+		}
+		versions[i].Archive = base.ResolveReference(archive).String()
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		if !versions[i].Published.Equal(versions[j].Published) {
+			return versions[i].Published.Before(versions[j].Published)
+		}
+		a, errA := semver.NewVersion(versions[i].Version)
+		b, errB := semver.NewVersion(versions[j].Version)
+		return errA == nil && errB == nil && a.LessThan(b)
+	})
+	return
+}
+
+// extract replaces the contents of the repository worktree with those of an archive
+func (r *Registry) extract(ctx context.Context, location, dir string) (err error) {
+	archive, err := ioutil.TempFile("", "sampctl-registry")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary file")
+	}
+	defer os.Remove(archive.Name()) // nolint
+
+	resp, err := r.get(ctx, location)
+	if err != nil {
+		archive.Close() // nolint
+		return
+	}
+	_, err = io.Copy(archive, resp.Body)
+	resp.Body.Close() // nolint
+	archive.Close()   // nolint
+	if err != nil {
+		return errors.Wrap(err, "failed to download archive")
+	}
+
+	names, err := download.ListArchive(ctx, archive.Name())
+	if err != nil {
+		return errors.Wrap(err, "failed to read archive")
+	}
+	root := archiveRoot(names)
+	paths := make(map[string]string)
+	for _, name := range names {
+		target := filepath.FromSlash(strings.TrimPrefix(name, root))
+		clean := filepath.Clean(target)
+		if target == "" || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) || filepath.IsAbs(target) {
+			continue
+		}
+		paths["^"+regexp.QuoteMeta(name)+"$"] = target
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return errors.Wrap(err, "failed to read repository")
+	}
+	for _, file := range files {
+		if file.Name() == ".git" {
+			continue
+		}
+		err = os.RemoveAll(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return errors.Wrap(err, "failed to clear repository")
+		}
+	}
+
+	_, err = download.Extract(ctx, archive.Name(), dir, paths)
+	if err != nil {
+		return errors.Wrap(err, "failed to extract archive")
+	}
+	return
+}
+
+func (r *Registry) get(ctx context.Context, location string) (resp *http.Response, err error) {
+	req, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create request for %s", location)
+	}
+	// archives may be served from elsewhere, such as a CDN, which mustn't receive the credentials,
+	// and they're never sent in plain text
+	if req.URL.Host == r.Site && req.URL.Scheme == "https" {
+		if r.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+r.Token)
+		} else if r.Username != "" {
+			req.SetBasicAuth(r.Username, r.Password)
+		}
+	}
+
+	client := r.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err = client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to request %s", location)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close() // nolint
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return nil, errors.Errorf("registry rejected the request for %s, check the registry credentials in config.yaml or sampctl.yaml: %s", location, resp.Status)
+		}
+		return nil, errors.Errorf("failed to request %s: %s", location, resp.Status)
+	}
+	return
+}
+
+// archiveRoot returns the directory that every file in an archive is in, if there is one
+func archiveRoot(names []string) (root string) {
+	for i, name := range names {
+		slash := strings.Index(name, "/")
+		if slash == -1 {
+			return ""
+		}
+		if i == 0 {
+			root = name[:slash+1]
+		} else if !strings.HasPrefix(name, root) {
+			return ""
+		}
+	}
+	return
+}
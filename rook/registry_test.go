@@ -0,0 +1,161 @@
+package rook
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func makeArchive(files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, contents := range files {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents)), Typeflag: tar.TypeReg})
+		if err != nil {
+			panic(err)
+		}
+		_, err = tw.Write([]byte(contents))
+		if err != nil {
+			panic(err)
+		}
+	}
+	tw.Close() // nolint
+	gz.Close() // nolint
+	return buf.Bytes()
+}
+
+func TestRegistry(t *testing.T) {
+	dir := util.FullPath("./tests/registry")
+	os.RemoveAll(dir)
+
+	published := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	versions := []RegistryVersion{
+		{Version: "1.1.0", Archive: "/archives/1.1.0.tar.gz", Published: published.Add(time.Hour)},
+		{Version: "1.0.0", Archive: "/archives/1.0.0.tar.gz", Published: published},
+	}
+	archives := map[string][]byte{
+		"/archives/1.0.0.tar.gz": makeArchive(map[string]string{"lib-1.0.0/lib.inc": "// 1.0.0\n", "lib-1.0.0/old.inc": "// old\n"}),
+		"/archives/1.1.0.tar.gz": makeArchive(map[string]string{"lib-1.1.0/lib.inc": "// 1.1.0\n", "lib-1.1.0/pawn.json": `{"user":"test","repo":"lib"}`}),
+		"/archives/2.0.0.tar.gz": makeArchive(map[string]string{"lib.inc": "// 2.0.0\n"}),
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/packages/test/lib/versions" {
+			json.NewEncoder(w).Encode(versions) // nolint
+			return
+		}
+		if archive, ok := archives[r.URL.Path]; ok {
+			w.Write(archive) // nolint
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	registry, err := NewRegistry(&types.RegistryConfig{URL: server.URL, Token: "secret"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	registry.client = server.Client()
+
+	pcx := PackageContext{CacheDir: filepath.Join(dir, "first"), Registry: registry}
+	meta, err := pcx.explode("test/lib:^1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, strings.TrimPrefix(server.URL, "https://"), meta.Site)
+
+	explicit, err := pcx.explode("https://github.com/test/lib")
+	assert.NoError(t, err)
+	assert.False(t, registry.handles(explicit))
+
+	// without a registry, or in another package context, they resolve to GitHub
+	unregistered, err := PackageContext{}.explode("test/lib:^1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "github.com", unregistered.Site)
+	repo, err := pcx.EnsureDependencyCached(context.Background(), meta, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ref, err := versioning.RefFromTag(repo, meta)
+	assert.NoError(t, err)
+	assert.Equal(t, plumbing.ReferenceName("refs/tags/1.1.0"), ref.Name())
+
+	// the worktree has the latest version without the files it removed
+	cached := pcx.cachePath(meta)
+	contents, err := ioutil.ReadFile(filepath.Join(cached, "lib.inc"))
+	assert.NoError(t, err)
+	assert.Equal(t, "// 1.1.0\n", string(contents))
+	assert.False(t, util.Exists(filepath.Join(cached, "old.inc")))
+	assert.True(t, util.Exists(filepath.Join(cached, "pawn.json")))
+
+	// commits are the same wherever the package is cached
+	other := PackageContext{CacheDir: filepath.Join(dir, "second"), Registry: registry}
+	otherRepo, err := other.EnsureDependencyCached(context.Background(), meta, false)
+	assert.NoError(t, err)
+	otherRef, err := otherRepo.Reference("refs/tags/1.1.0", false)
+	assert.NoError(t, err)
+	assert.Equal(t, ref.Hash(), otherRef.Hash())
+
+	// updating adds new versions on top of the existing ones
+	versions = append(versions, RegistryVersion{Version: "2.0.0", Archive: "/archives/2.0.0.tar.gz", Published: published.Add(2 * time.Hour)})
+	repo, err = pcx.EnsureDependencyCached(context.Background(), meta, true)
+	assert.NoError(t, err)
+	tags, err := versioning.GetRepoSemverTags(repo)
+	assert.NoError(t, err)
+	assert.Len(t, tags, 3)
+	unchanged, err := repo.Reference("refs/tags/1.1.0", false)
+	assert.NoError(t, err)
+	assert.Equal(t, ref.Hash(), unchanged.Hash())
+
+	// bad credentials are reported as such
+	wrong, err := NewRegistry(&types.RegistryConfig{URL: server.URL, Token: "wrong"})
+	assert.NoError(t, err)
+	wrong.client = server.Client()
+	_, err = PackageContext{CacheDir: filepath.Join(dir, "wrong"), Registry: wrong}.EnsureDependencyCached(context.Background(), meta, false)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "credentials")
+}
+
+func TestNewRegistry(t *testing.T) {
+	r, err := NewRegistry(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, r)
+
+	r, err = NewRegistry(&types.RegistryConfig{URL: "https://packages.example.com/"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://packages.example.com", r.URL)
+	assert.Equal(t, "packages.example.com", r.Site)
+
+	// credentials are never sent in plain text, but public registries can be served over HTTP
+	_, err = NewRegistry(&types.RegistryConfig{URL: "http://packages.example.com", Token: "secret"})
+	assert.Error(t, err)
+	_, err = NewRegistry(&types.RegistryConfig{URL: "http://packages.example.com", Username: "user", Password: "secret"})
+	assert.Error(t, err)
+	_, err = NewRegistry(&types.RegistryConfig{URL: "http://packages.example.com"})
+	assert.NoError(t, err)
+
+	_, err = NewRegistry(&types.RegistryConfig{URL: "/packages"})
+	assert.Error(t, err)
+}
@@ -85,16 +85,36 @@ func Release(ctx context.Context, gh *github.Client, auth transport.AuthMethod,
 		bumpMinor := latest.Version.IncMinor()
 		bumpMajor := latest.Version.IncMajor()
 
+		options := []string{
+			fmt.Sprintf("%s: I made backwards-compatible bug fixes", bumpPatch.String()),
+			fmt.Sprintf("%s: I added functionality in a backwards-compatible manner", bumpMinor.String()),
+			fmt.Sprintf("%s: I made incompatible API changes", bumpMajor.String()),
+		}
+		suggested := options[0]
+
+		// suggest the bump that the changes to the exported symbols since the latest version need
+		comparison, errCompare := CompareAPI(pkg, latest.Name, "")
+		if errCompare != nil {
+			print.Warn("failed to compare exported symbols with", latest.Name, errCompare)
+		} else {
+			for _, change := range comparison.Breaking() {
+				print.Warn("breaking change since", latest.Name+":", change)
+			}
+			switch comparison.Bump() {
+			case "major":
+				suggested = options[2]
+			case "minor":
+				suggested = options[1]
+			}
+		}
+
 		questions = []*survey.Question{
 			{
 				Name: "Version",
 				Prompt: &survey.Select{
 					Message: "Select Version Bump",
-					Options: []string{
-						fmt.Sprintf("%s: I made backwards-compatible bug fixes", bumpPatch.String()),
-						fmt.Sprintf("%s: I added functionality in a backwards-compatible manner", bumpMinor.String()),
-						fmt.Sprintf("%s: I made incompatible API changes", bumpMajor.String()),
-					},
+					Options: options,
+					Default: suggested,
 				},
 				Validate: survey.Required,
 			},
@@ -109,13 +129,15 @@ func Release(ctx context.Context, gh *github.Client, auth transport.AuthMethod,
 		},
 	})
 
-	// questions = append(questions, &survey.Question{
-	// 	Name: "Distribution",
-	// 	Prompt: &survey.Confirm{
-	// 		Message: "Create Distribution Release?",
-	// 		Default: false,
-	// 	},
-	// })
+	if len(pkg.Resources) > 0 {
+		questions = append(questions, &survey.Question{
+			Name: "Distribution",
+			Prompt: &survey.Confirm{
+				Message: "Export release archives for the package resources?",
+				Default: false,
+			},
+		})
+	}
 
 	questions = append(questions, &survey.Question{
 		Name: "GitHub",
@@ -157,6 +179,11 @@ func Release(ctx context.Context, gh *github.Client, auth transport.AuthMethod,
 		return errors.Wrap(err, "failed to create version from result")
 	}
 
+	err = CheckVersionDefine(pkg, newVersion.String())
+	if err != nil {
+		return
+	}
+
 	versionFile, err := generateVersionInc(pkg, newVersion)
 	if err != nil {
 		return errors.Wrap(err, "failed to generate version.inc")
@@ -222,10 +249,12 @@ func Release(ctx context.Context, gh *github.Client, auth transport.AuthMethod,
 		print.Info("Released at:", fmt.Sprintf("https://github.com/%s/%s/releases", pkg.User, pkg.Repo))
 	}
 
-	// todo: zip the package in a `pawno/include` style
-	// possibly include dependencies too
-	// if answers.Distribution {
-	// }
+	if answers.Distribution {
+		_, err = ExportRelease(pkg, newVersion.String(), "", filepath.Join(pkg.LocalPath, "release"))
+		if err != nil {
+			return errors.Wrap(err, "failed to export release archives")
+		}
+	}
 
 	return
 }
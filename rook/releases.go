@@ -0,0 +1,187 @@
+package rook
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// VersionSource decides where the versions that a version constraint on a dependency resolves to
+// come from
+type VersionSource string
+
+const (
+	// VersionSourceTags resolves constraints against the tags of the repository, this is the default
+	VersionSourceTags VersionSource = "tags"
+	// VersionSourceReleases resolves constraints against the GitHub releases of the repository, drafts
+	// are ignored and releases marked as prereleases only match a constraint that names them exactly
+	VersionSourceReleases VersionSource = "releases"
+)
+
+// VersionSources lists the valid version sources
+var VersionSources = []VersionSource{VersionSourceTags, VersionSourceReleases}
+
+var matchCommitHash = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// versionSourceOf returns the version source of a dependency, the one the package declares for the
+// dependency if any, otherwise the one the package declares for every dependency
+func (pcx *PackageContext) versionSourceOf(meta versioning.DependencyMeta) (source VersionSource, err error) {
+	source = VersionSource(pcx.Package.VersionSource)
+	for dependency, declared := range pcx.Package.VersionSources {
+		if strings.EqualFold(dependency, meta.User+"/"+meta.Repo) {
+			source = VersionSource(declared)
+		}
+	}
+	switch source {
+	case "":
+		source = VersionSourceTags
+	case VersionSourceTags, VersionSourceReleases:
+	default:
+		return "", errors.Errorf("unknown version source %s, must be one of %v", source, VersionSources)
+	}
+	return
+}
+
+// refFromRelease resolves the tag constraint of a dependency against its GitHub releases using the
+// resolution strategy
+func (pcx *PackageContext) refFromRelease(ctx context.Context, repo *git.Repository, meta versioning.DependencyMeta, constraints []string) (ref *plumbing.Reference, err error) {
+	releases, err := pcx.githubReleases(ctx, meta)
+	if err != nil {
+		return
+	}
+
+	constraint, errConstraint := semver.NewConstraint(meta.Tag)
+	if errConstraint != nil {
+		for _, release := range releases {
+			if release.GetTagName() == meta.Tag {
+				return releaseRef(repo, release)
+			}
+		}
+		return nil, errors.Errorf("failed to satisfy constraint, '%s' is not a release of %s", meta.Tag, meta)
+	}
+
+	versions := releaseVersions(repo, releases)
+	var candidates versioning.VersionedTags
+	for _, version := range versions {
+		if version.Prerelease && version.Name != meta.Tag {
+			print.Verb(meta, "skipping release", version.Name, "which is marked as a prerelease")
+			continue
+		}
+		candidates = append(candidates, version)
+	}
+
+	if pcx.Strategy == StrategyMinimal && semverConstraints(constraints) {
+		sort.Sort(candidates)
+		for _, candidate := range candidates {
+			if satisfiesAll(candidate.Version, constraints) {
+				print.Verb(meta, "discovered release", candidate.Name, "as the lowest that matches", constraints)
+				return candidate.Ref, nil
+			}
+		}
+		return nil, errors.Errorf("failed to satisfy all of the constraints %v, none of the releases %v match", constraints, candidates)
+	}
+
+	sort.Sort(sort.Reverse(candidates))
+	for _, candidate := range candidates {
+		if constraint.Check(candidate.Version) {
+			print.Verb(meta, "discovered release", candidate.Name, "that matches constraint", meta.Tag)
+			return candidate.Ref, nil
+		}
+	}
+	return nil, errors.Errorf("failed to satisfy constraint, '%s' not in releases %v", meta.Tag, candidates)
+}
+
+// githubReleases lists the GitHub releases of a dependency that aren't drafts, the list is only
+// requested once for each dependency
+func (pcx *PackageContext) githubReleases(ctx context.Context, meta versioning.DependencyMeta) (releases []*github.RepositoryRelease, err error) {
+	key := constraintKey(meta)
+	if cached, ok := pcx.releases[key]; ok {
+		return cached, nil
+	}
+	if meta.Site != "" && meta.Site != "github.com" {
+		return nil, errors.Errorf("%s is not on GitHub so it can't be resolved from releases", meta)
+	}
+	if pcx.GitHub == nil {
+		return nil, errors.Errorf("a GitHub client is required to resolve %s from releases", meta)
+	}
+
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, errList := pcx.GitHub.Repositories.ListReleases(ctx, meta.User, meta.Repo, opts)
+		if errList != nil {
+			return nil, errors.Wrapf(errList, "failed to list releases of %s", meta)
+		}
+		for _, release := range page {
+			if !release.GetDraft() {
+				releases = append(releases, release)
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	if pcx.releases == nil {
+		pcx.releases = make(map[string][]*github.RepositoryRelease)
+	}
+	pcx.releases[key] = releases
+	return
+}
+
+// releaseVersions returns the releases that are named after a semantic version and can be found in
+// the repository as versioned tags
+func releaseVersions(repo *git.Repository, releases []*github.RepositoryRelease) (versions versioning.VersionedTags) {
+	for _, release := range releases {
+		version, err := semver.NewVersion(release.GetTagName())
+		if err != nil {
+			continue
+		}
+		ref, err := releaseRef(repo, release)
+		if err != nil {
+			print.Verb(err)
+			continue
+		}
+		versions = append(versions, versioning.VersionedTag{
+			Ref:        ref,
+			Name:       release.GetTagName(),
+			Version:    version,
+			Prerelease: release.GetPrerelease(),
+		})
+	}
+	return
+}
+
+// releaseRef finds the commit of a release, the commit of its tag or the commit it targets if the
+// tag isn't in the repository
+func releaseRef(repo *git.Repository, release *github.RepositoryRelease) (ref *plumbing.Reference, err error) {
+	name := plumbing.ReferenceName("refs/tags/" + release.GetTagName())
+	tag, err := repo.Reference(name, true)
+	if err == nil {
+		return versioning.RefFromTagRef(repo, tag)
+	}
+	if matchCommitHash.MatchString(release.GetTargetCommitish()) {
+		return plumbing.NewHashReference(name, plumbing.NewHash(release.GetTargetCommitish())), nil
+	}
+	return nil, errors.Errorf("release %s has no tag in the repository, the cached copy may be out of date", release.GetTagName())
+}
+
+func satisfiesAll(version *semver.Version, constraints []string) bool {
+	for _, constraint := range constraints {
+		c, err := semver.NewConstraint(constraint)
+		if err != nil || !c.Check(version) {
+			return false
+		}
+	}
+	return true
+}
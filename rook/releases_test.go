@@ -0,0 +1,97 @@
+package rook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_refFromRelease(t *testing.T) {
+	dir := util.FullPath("./tests/releases")
+	os.RemoveAll(dir)
+	commitVersions(t, dir, []string{"1.0.0", "1.1.0", "1.2.0", "2.0.0"})
+	repo, err := git.PlainOpen(dir)
+	assert.NoError(t, err)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/repos/test/lib/releases", r.URL.Path)
+		fmt.Fprint(w, `[
+			{"tag_name": "2.0.0", "draft": true},
+			{"tag_name": "1.2.0", "prerelease": true},
+			{"tag_name": "1.1.0"},
+			{"tag_name": "1.0.0"},
+			{"tag_name": "untagged", "target_commitish": "master"}
+		]`)
+	}))
+	defer server.Close()
+	gh := github.NewClient(nil)
+	gh.BaseURL, _ = url.Parse(server.URL + "/")
+
+	pcx := PackageContext{
+		GitHub:  gh,
+		Package: types.Package{VersionSources: map[string]string{"Test/Lib": "releases"}},
+	}
+
+	for _, tt := range []struct {
+		strategy    ResolutionStrategy
+		constraints []string
+		want        string
+		wantErr     bool
+	}{
+		// the prerelease is only used when it's named exactly and the draft is never used
+		{StrategyNewest, []string{"^1.0.0"}, "1.1.0", false},
+		{StrategyNewest, []string{"1.2.0"}, "1.2.0", false},
+		{StrategyNewest, []string{"^2.0.0"}, "", true},
+		{StrategyMinimal, []string{">=1.0.0", ">=1.1.0"}, "1.1.0", false},
+		{StrategyNewest, []string{"untagged"}, "", true},
+	} {
+		pcx.Strategy = tt.strategy
+		pcx.Constraints = nil
+		meta := versioning.DependencyMeta{User: "test", Repo: "lib", Tag: tt.constraints[0]}
+		for _, constraint := range tt.constraints {
+			pcx.addConstraint(versioning.DependencyMeta{User: "test", Repo: "lib", Tag: constraint})
+		}
+
+		ref, err := pcx.refFromTag(context.Background(), repo, meta)
+		if tt.wantErr {
+			assert.Error(t, err, tt.constraints)
+			continue
+		}
+		assert.NoError(t, err, tt.constraints)
+		assert.Equal(t, plumbing.ReferenceName("refs/tags/"+tt.want), ref.Name(), tt.constraints)
+	}
+	assert.Equal(t, 1, requests)
+}
+
+func TestPackageContext_versionSourceOf(t *testing.T) {
+	pcx := PackageContext{Package: types.Package{
+		VersionSource:  "releases",
+		VersionSources: map[string]string{"test/tagged": "tags", "test/bad": "branches"},
+	}}
+
+	source, err := pcx.versionSourceOf(versioning.DependencyMeta{User: "test", Repo: "other"})
+	assert.NoError(t, err)
+	assert.Equal(t, VersionSourceReleases, source)
+
+	source, err = pcx.versionSourceOf(versioning.DependencyMeta{User: "Test", Repo: "Tagged"})
+	assert.NoError(t, err)
+	assert.Equal(t, VersionSourceTags, source)
+
+	_, err = pcx.versionSourceOf(versioning.DependencyMeta{User: "test", Repo: "bad"})
+	assert.EqualError(t, err, "unknown version source branches, must be one of [tags releases]")
+}
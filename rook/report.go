@@ -0,0 +1,96 @@
+package rook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/events"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+)
+
+// buildReport describes the outcome of a build for the report file, `config` is nil if the build
+// failed before its config was prepared
+func (pcx *PackageContext) buildReport(
+	build string,
+	config *types.BuildConfig,
+	started time.Time,
+	problems types.BuildProblems,
+	result types.BuildResult,
+	buildErr error,
+) (report types.BuildReport) {
+	report = types.NewBuildReport(problems, result)
+	report.Package = pcx.Package.String()
+	report.Build = build
+	report.Platform = pcx.Platform
+	report.Started = started.UTC()
+	report.DurationMS = int64(time.Since(started) / time.Millisecond)
+	report.Success = buildErr == nil && problems.IsValid() && !problems.Fatal()
+	if buildErr != nil {
+		report.Error = buildErr.Error()
+	}
+	if config == nil {
+		return
+	}
+
+	if config.Name != "" {
+		report.Build = config.Name
+	}
+	if config.Compiler != "" {
+		report.Compiler = string(config.Compiler)
+	} else {
+		report.Compiler = string(config.Version)
+	}
+
+	if !report.Success {
+		return
+	}
+	artifact, err := pcx.reportArtifact(config.Output)
+	if err != nil {
+		print.Warn("Build output missing from report:", err)
+		return
+	}
+	report.Artifacts = append(report.Artifacts, artifact)
+	return
+}
+
+// reportBuild publishes a build report and writes it to the report file, if there is one
+func (pcx *PackageContext) reportBuild(ctx context.Context, report types.BuildReport) {
+	events.Publish(ctx, events.BuildReported{Report: report})
+	if pcx.ReportFile == "" {
+		return
+	}
+	if err := report.Write(pcx.ReportFile); err != nil {
+		print.Erro("Failed to write build report:", err)
+	}
+}
+
+// reportArtifact hashes a file produced by a build
+func (pcx *PackageContext) reportArtifact(filename string) (artifact types.ReportArtifact, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return artifact, errors.Wrap(err, "failed to open build output")
+	}
+	defer f.Close() // nolint
+
+	hash := sha256.New()
+	artifact.Size, err = io.Copy(hash, f)
+	if err != nil {
+		return artifact, errors.Wrap(err, "failed to read build output")
+	}
+	artifact.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	path, err := filepath.Rel(pcx.Package.LocalPath, filename)
+	if err != nil {
+		path = filename
+	}
+	artifact.Path = filepath.ToSlash(path)
+	return artifact, nil
+}
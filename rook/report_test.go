@@ -0,0 +1,72 @@
+package rook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_buildReport(t *testing.T) {
+	dir := util.FullPath("./tests/report")
+	os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "gamemodes"), 0700))
+	output := filepath.Join(dir, "gamemodes", "test.amx")
+	assert.NoError(t, ioutil.WriteFile(output, []byte("amx"), 0600))
+
+	pcx := PackageContext{
+		Package: types.Package{
+			LocalPath:      dir,
+			DependencyMeta: versioning.DependencyMeta{User: "user", Repo: "repo"},
+		},
+		Platform: "linux",
+	}
+	config := &types.BuildConfig{Name: "main", Version: "3.10.10", Output: output}
+	problems := types.BuildProblems{
+		{File: "gamemodes/test.pwn", Line: 3, Severity: types.ProblemWarning, Description: "symbol is never used: \"a\""},
+	}
+	result := types.BuildResult{Header: 1, Code: 2, Data: 3, StackHeap: 4, Estimate: 5, Total: 15}
+
+	report := pcx.buildReport("main", config, time.Now(), problems, result, nil)
+	assert.True(t, report.Success)
+	assert.Equal(t, "3.10.10", report.Compiler)
+	assert.Equal(t, []types.ReportArtifact{{
+		Path:   "gamemodes/test.amx",
+		SHA256: "c9fbecf5530beb84b4b0ca562226dc6973b99355947dc7cdc8520b2715f85d79",
+		Size:   3,
+	}}, report.Artifacts)
+
+	filename := filepath.Join(dir, "report.json")
+	assert.NoError(t, report.Write(filename))
+	contents, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	var decoded map[string]interface{}
+	assert.NoError(t, json.Unmarshal(contents, &decoded))
+	assert.Equal(t, float64(types.BuildReportSchema), decoded["schema"])
+	assert.Equal(t, "main", decoded["build"])
+	assert.Equal(t, "warning", decoded["diagnostics"].([]interface{})[0].(map[string]interface{})["severity"])
+	assert.Equal(t, float64(4), decoded["sizes"].(map[string]interface{})["stack_heap"])
+
+	// errors fail the build and leave out the artifacts
+	problems = append(problems, types.BuildProblem{File: "gamemodes/test.pwn", Line: 4, Severity: types.ProblemError, Description: "undefined symbol \"b\""})
+	report = pcx.buildReport("main", config, time.Now(), problems, types.BuildResult{}, nil)
+	assert.False(t, report.Success)
+	assert.Nil(t, report.Sizes)
+	assert.Empty(t, report.Artifacts)
+
+	// a build that failed before its config was prepared
+	report = pcx.buildReport("missing", nil, time.Now(), nil, types.BuildResult{}, errors.New("no build config named 'missing'"))
+	assert.False(t, report.Success)
+	assert.Equal(t, "missing", report.Build)
+	assert.Equal(t, "no build config named 'missing'", report.Error)
+	assert.Equal(t, []types.ReportDiagnostic{}, report.Diagnostics)
+}
@@ -0,0 +1,216 @@
+package rook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/compiler"
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+// Reproduction is a bundle written to report a bug in a build of a package
+type Reproduction struct {
+	Files   []string // files written to the output directory
+	Archive string   // the self-contained archive, empty unless one was requested
+	Omitted []string // included files outside the package, these are not in the archive
+}
+
+// Reproduce writes a bundle that reproduces a build of the package to a directory: a package
+// definition with only what the build uses, the lockfile, `command.txt` with the compiler command
+// line and `REPRODUCE.md` describing the environment. Paths within the package are relative in the
+// command, so it runs from the package directory of whoever reproduces the build. Dependencies are
+// not ensured so the bundle describes the package as it is.
+//
+// If `archive` is set, a gzipped tarball is also written with the same files, the entry script and
+// every file it includes from the package and its vendored dependencies. Extracted, it builds with
+// the command without downloading any dependencies.
+func (pcx *PackageContext) Reproduce(ctx context.Context, build, outputDir string, archive bool) (repro Reproduction, err error) {
+	selected := GetBuildConfig(pcx.Package, build, pcx.Platform)
+	if selected == nil {
+		return repro, errors.Errorf("no build config named '%s'", build)
+	}
+
+	config, err := pcx.buildPrepare(ctx, build, false, false)
+	if err != nil {
+		return repro, errors.Wrap(err, "failed to prepare build")
+	}
+	command, err := compiler.PrepareCommand(ctx, pcx.GitHub, pcx.Package.LocalPath, pcx.CacheDir, pcx.Platform, *config)
+	if err != nil {
+		return repro, errors.Wrap(err, "failed to prepare compiler command")
+	}
+	commandLine := compiler.CommandLine(relativeCommand(command, pcx.Package.LocalPath))
+
+	err = os.MkdirAll(outputDir, 0700)
+	if err != nil {
+		return repro, errors.Wrap(err, "failed to create reproduction directory")
+	}
+
+	// only what the build uses is kept from the definition, with requirements merged into the
+	// dependencies so they don't need a separate file
+	definition := types.Package{
+		DependencyMeta: pcx.Package.DependencyMeta,
+		LocalPath:      outputDir,
+		Format:         "json",
+		Entry:          pcx.Package.Entry,
+		Output:         pcx.Package.Output,
+		Dependencies:   pcx.Package.Dependencies,
+		Development:    pcx.Package.Development,
+		IncludePath:    pcx.Package.IncludePath,
+		IncludePaths:   pcx.Package.IncludePaths,
+		Features:       pcx.Package.Features,
+		EnableFeatures: pcx.Package.EnableFeatures,
+		Builds:         []*types.BuildConfig{selected},
+	}
+	err = definition.WriteCanonicalDefinition()
+	if err != nil {
+		return
+	}
+	repro.Files = append(repro.Files, filepath.Join(outputDir, "pawn.json"))
+
+	lockfile := filepath.Join(pcx.Package.LocalPath, types.LockfileName)
+	if util.Exists(lockfile) {
+		err = util.CopyFile(lockfile, filepath.Join(outputDir, types.LockfileName))
+		if err != nil {
+			return repro, errors.Wrap(err, "failed to copy lockfile")
+		}
+		repro.Files = append(repro.Files, filepath.Join(outputDir, types.LockfileName))
+	} else {
+		print.Warn("package has no", types.LockfileName+", run ensure first to record the commits of its dependencies")
+	}
+
+	err = ioutil.WriteFile(filepath.Join(outputDir, "command.txt"), []byte(commandLine+"\n"), 0600)
+	if err != nil {
+		return repro, errors.Wrap(err, "failed to write compiler command")
+	}
+	repro.Files = append(repro.Files, filepath.Join(outputDir, "command.txt"))
+
+	var entries []download.ArchiveEntry
+	if archive {
+		entries, repro.Omitted, err = pcx.reproductionSources(config)
+		if err != nil {
+			return
+		}
+	}
+
+	err = ioutil.WriteFile(filepath.Join(outputDir, "REPRODUCE.md"), pcx.reproductionNotes(config, commandLine, repro.Omitted), 0600)
+	if err != nil {
+		return repro, errors.Wrap(err, "failed to write reproduction notes")
+	}
+	repro.Files = append(repro.Files, filepath.Join(outputDir, "REPRODUCE.md"))
+
+	if archive {
+		for _, file := range repro.Files {
+			entries = append(entries, download.ArchiveEntry{Name: filepath.Base(file), Source: file})
+		}
+		repro.Archive = filepath.Join(outputDir, reproductionName(pcx.Package)+"-repro.tar.gz")
+		err = download.WriteArchive(repro.Archive, download.ArchiveGzip, entries)
+		if err != nil {
+			return repro, errors.Wrap(err, "failed to write reproduction archive")
+		}
+	}
+	return
+}
+
+// reproductionSources lists the entry script and everything it includes as archive entries at
+// their paths within the package. Files outside the package, such as includes from absolute
+// include paths, are returned separately since they can't be placed in the archive.
+func (pcx *PackageContext) reproductionSources(config *types.BuildConfig) (entries []download.ArchiveEntry, omitted []string, err error) {
+	includes := make([]string, len(config.Includes))
+	for i, inc := range config.Includes {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(pcx.Package.LocalPath, inc)
+		}
+		includes[i] = inc
+	}
+
+	files, err := includeSet(config.Input, includes)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to find included files")
+	}
+	for file := range files {
+		rel, errRel := filepath.Rel(pcx.Package.LocalPath, file)
+		if errRel != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			omitted = append(omitted, file)
+			continue
+		}
+		entries = append(entries, download.ArchiveEntry{Name: filepath.ToSlash(rel), Source: file})
+	}
+	sort.Strings(omitted)
+	for _, file := range omitted {
+		print.Warn(file, "is outside the package and is not in the reproduction archive")
+	}
+	return
+}
+
+func (pcx *PackageContext) reproductionNotes(config *types.BuildConfig, commandLine string, omitted []string) []byte {
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, "# Reproduction of %s\n\n", strings.TrimSpace(reproductionName(pcx.Package)+" "+config.Name))
+	fmt.Fprintf(&buf, "- sampctl: %s\n", pcx.AppVersion)
+	fmt.Fprintf(&buf, "- platform: %s\n", pcx.Platform)
+	if config.CompilerPath != "" {
+		fmt.Fprintf(&buf, "- compiler: %s\n", filepath.Base(config.CompilerPath))
+	} else {
+		fmt.Fprintf(&buf, "- compiler: %s\n", config.Version)
+	}
+
+	if len(pcx.AllDependencies) > 0 {
+		buf.WriteString("\n## Dependencies\n\n")
+		for _, dep := range pcx.AllDependencies {
+			fmt.Fprintf(&buf, "- %s\n", dep)
+		}
+	}
+
+	fmt.Fprintf(&buf, "\n## Building\n\n```\n%s\n```\n\n", strings.TrimSpace("sampctl package build "+config.Name))
+	buf.WriteString("or run the compiler directly from the package directory, `command.txt` has the same command:\n\n")
+	fmt.Fprintf(&buf, "```\n%s\n```\n", commandLine)
+
+	if len(omitted) > 0 {
+		buf.WriteString("\n## Missing from the archive\n\nThese files are included from outside the package:\n\n")
+		for _, file := range omitted {
+			fmt.Fprintf(&buf, "- %s\n", file)
+		}
+	}
+	return buf.Bytes()
+}
+
+// relativeCommand returns a copy of a command with every path within the package directory made
+// relative to it, so the command runs from another copy of the package and doesn't reveal where the
+// package is on the machine that wrote it
+func relativeCommand(cmd *exec.Cmd, root string) *exec.Cmd {
+	relative := func(value string) string {
+		value = strings.Replace(value, root+string(filepath.Separator), "."+string(filepath.Separator), -1)
+		if value == root {
+			return "."
+		}
+		return value
+	}
+
+	result := &exec.Cmd{Dir: relative(cmd.Dir)}
+	for _, arg := range cmd.Args {
+		result.Args = append(result.Args, relative(arg))
+	}
+	for _, variable := range cmd.Env {
+		result.Env = append(result.Env, relative(variable))
+	}
+	return result
+}
+
+// reproductionName names a reproduction after the repository of the package or its directory
+func reproductionName(pkg types.Package) string {
+	if pkg.Repo != "" {
+		return pkg.Repo
+	}
+	return filepath.Base(pkg.LocalPath)
+}
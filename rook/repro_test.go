@@ -0,0 +1,93 @@
+package rook
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestRelativeCommand(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "home", "user", "gamemode")
+	cmd := &exec.Cmd{
+		Dir: root,
+		Args: []string{
+			"/cache/pawn/3.10.10/pawncc",
+			"-i" + filepath.Join(root, "dependencies", "samp-stdlib"),
+			"-o" + filepath.Join(root, "gamemodes", "main.amx"),
+			filepath.Join(root, "gamemodes", "main.pwn"),
+		},
+		Env: []string{"LD_LIBRARY_PATH=/cache/pawn/3.10.10"},
+	}
+
+	got := relativeCommand(cmd, root)
+	assert.Equal(t, ".", got.Dir)
+	dot := "." + string(filepath.Separator)
+	assert.Equal(t, []string{
+		"/cache/pawn/3.10.10/pawncc",
+		"-i" + dot + filepath.Join("dependencies", "samp-stdlib"),
+		"-o" + dot + filepath.Join("gamemodes", "main.amx"),
+		dot + filepath.Join("gamemodes", "main.pwn"),
+	}, got.Args)
+	assert.Equal(t, cmd.Env, got.Env)
+	assert.Equal(t, root, cmd.Dir)
+}
+
+func TestPackageContext_reproductionSources(t *testing.T) {
+	dir := util.FullPath("./tests/repro")
+	shared := util.FullPath("./tests/repro-shared")
+	os.RemoveAll(dir)
+	os.RemoveAll(shared)
+	for path, contents := range map[string]string{
+		filepath.Join(dir, "main.pwn"):                                  "#include <a_samp>\n#include \"config\"\n#include <shared>\n",
+		filepath.Join(dir, "config.inc"):                                "// config\n",
+		filepath.Join(dir, "unused.inc"):                                "// not included\n",
+		filepath.Join(dir, "dependencies", "samp-stdlib", "a_samp.inc"): "#include <core>\n",
+		filepath.Join(dir, "dependencies", "samp-stdlib", "core.inc"):   "// core\n",
+		filepath.Join(shared, "shared.inc"):                             "// outside the package\n",
+	} {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(contents), 0600))
+	}
+
+	pcx := PackageContext{
+		Package: types.Package{
+			LocalPath: dir,
+			Vendor:    filepath.Join(dir, "dependencies"),
+			Entry:     "main.pwn",
+			Output:    "main.amx",
+			Builds:    []*types.BuildConfig{{Name: "main", Includes: []string{"dependencies/samp-stdlib", shared}}},
+		},
+		Platform: "linux",
+	}
+	config, err := pcx.buildPrepare(context.Background(), "main", false, false)
+	assert.NoError(t, err)
+
+	entries, omitted, err := pcx.reproductionSources(config)
+	assert.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{
+		"config.inc",
+		"dependencies/samp-stdlib/a_samp.inc",
+		"dependencies/samp-stdlib/core.inc",
+		"main.pwn",
+	}, names)
+	assert.Equal(t, []string{filepath.Join(shared, "shared.inc")}, omitted)
+
+	notes := string(pcx.reproductionNotes(config, "cd . && pawncc main.pwn", omitted))
+	assert.Contains(t, notes, "sampctl package build main")
+	assert.Contains(t, notes, "cd . && pawncc main.pwn")
+	assert.Contains(t, notes, filepath.Join(shared, "shared.inc"))
+}
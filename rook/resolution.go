@@ -0,0 +1,149 @@
+package rook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// resolutionCache is the dependency tree of a package as resolved by `EnsureDependenciesCached`,
+// stored in the `.sampctl` directory of the package so invocations that follow each other quickly,
+// such as from an editor on every save, don't have to resolve it again
+type resolutionCache struct {
+	Key          string                      `json:"key"`
+	Dependencies []versioning.DependencyMeta `json:"dependencies"`
+	IncludePaths []string                    `json:"include_paths"`
+	Constraints  map[string][]string         `json:"constraints,omitempty"`
+	Other        []versioning.DependencyMeta `json:"other_platforms,omitempty"`
+}
+
+// resolveDependencies fills in the dependency tree of the package, reusing the cached resolution if
+// the package definition, lockfile and environment are the same as when it was cached and every
+// dependency it lists is still in the cache. Otherwise the tree is resolved and cached again.
+func (pcx *PackageContext) resolveDependencies(ctx context.Context) (err error) {
+	// the vendored copies of a read-only vendor directory are read every time since the directory
+	// may have been replaced with a different one since the resolution was cached
+	if pcx.ReadOnlyVendor {
+		return pcx.EnsureDependenciesCached(ctx)
+	}
+
+	key, err := pcx.resolutionKey()
+	if err != nil {
+		return
+	}
+
+	if cached, ok := pcx.readResolution(key); ok {
+		print.Verb(pcx.Package, "using cached dependency resolution")
+		pcx.AllDependencies = cached.Dependencies
+		pcx.AllIncludePaths = cached.IncludePaths
+		pcx.Constraints = cached.Constraints
+		pcx.OtherPlatforms = cached.Other
+		return
+	}
+
+	err = pcx.EnsureDependenciesCached(ctx)
+	if err != nil {
+		return
+	}
+
+	errWrite := pcx.writeResolution(resolutionCache{
+		Key:          key,
+		Dependencies: pcx.AllDependencies,
+		IncludePaths: pcx.AllIncludePaths,
+		Constraints:  pcx.Constraints,
+		Other:        pcx.OtherPlatforms,
+	})
+	if errWrite != nil {
+		print.Verb(pcx.Package, "failed to cache dependency resolution:", errWrite)
+	}
+	return
+}
+
+// resolutionKey hashes everything the resolved dependency tree depends on
+func (pcx *PackageContext) resolutionKey() (key string, err error) {
+	definition, err := json.Marshal(pcx.Package)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode package definition")
+	}
+	lock, err := ioutil.ReadFile(filepath.Join(pcx.Package.LocalPath, types.LockfileName))
+	if err != nil && !os.IsNotExist(err) {
+		return "", errors.Wrapf(err, "failed to read %s", types.LockfileName)
+	}
+
+	hash := sha256.New()
+	for _, part := range [][]byte{
+		definition,
+		lock,
+		[]byte(pcx.Platform),
+		[]byte(pcx.CacheDir),
+		[]byte(pcx.Package.Vendor),
+	} {
+		hash.Write(part)      // nolint
+		hash.Write([]byte{0}) // nolint
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (pcx *PackageContext) resolutionPath() string {
+	return filepath.Join(pcx.Package.LocalPath, ".sampctl", "resolution.json")
+}
+
+// readResolution returns the cached resolution if it was cached with the same key and the cached
+// copies of its dependencies still exist
+func (pcx *PackageContext) readResolution(key string) (cached resolutionCache, ok bool) {
+	contents, err := ioutil.ReadFile(pcx.resolutionPath())
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(contents, &cached); err != nil {
+		print.Verb(pcx.Package, "ignoring invalid dependency resolution cache:", err)
+		return
+	}
+	if cached.Key != key {
+		return
+	}
+	if cached.Constraints == nil {
+		// caches written before tag constraints were recorded are missing them
+		for _, meta := range cached.Dependencies {
+			if meta.Tag != "" {
+				return
+			}
+		}
+	}
+	for _, meta := range cached.Dependencies {
+		if !util.Exists(pcx.cachePath(meta)) {
+			print.Verb(meta, "is no longer cached, resolving dependencies again")
+			return
+		}
+	}
+	return cached, true
+}
+
+func (pcx *PackageContext) writeResolution(cached resolutionCache) (err error) {
+	contents, err := json.Marshal(cached)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode dependency resolution")
+	}
+	path := pcx.resolutionPath()
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return errors.Wrap(err, "failed to create .sampctl directory")
+	}
+	// write to a temporary file first so a concurrent invocation never reads a partial cache
+	err = ioutil.WriteFile(path+".tmp", contents, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write dependency resolution")
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,74 @@
+package rook
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_resolveDependencies(t *testing.T) {
+	dir := util.FullPath("./tests/resolution")
+	os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+
+	pcx := PackageContext{
+		Package: types.Package{
+			Parent:         true,
+			LocalPath:      dir,
+			Vendor:         filepath.Join(dir, "dependencies"),
+			DependencyMeta: versioning.DependencyMeta{User: "user", Repo: "repo"},
+		},
+		Platform: "linux",
+		CacheDir: filepath.Join(dir, "cache"),
+	}
+
+	// a package without dependencies resolves without a network and is cached
+	assert.NoError(t, pcx.resolveDependencies(context.Background()))
+	assert.True(t, util.Exists(pcx.resolutionPath()))
+
+	// a cached resolution is used as long as nothing changed
+	lib := versioning.DependencyMeta{User: "test", Repo: "lib"}
+	assert.NoError(t, os.MkdirAll(pcx.cachePath(lib), 0700))
+	key, err := pcx.resolutionKey()
+	assert.NoError(t, err)
+	include := filepath.Join(pcx.Package.Vendor, ".resources", "lib-abcdef")
+	assert.NoError(t, pcx.writeResolution(resolutionCache{
+		Key:          key,
+		Dependencies: []versioning.DependencyMeta{lib},
+		IncludePaths: []string{include},
+	}))
+	assert.NoError(t, pcx.resolveDependencies(context.Background()))
+	assert.Equal(t, []versioning.DependencyMeta{lib}, pcx.AllDependencies)
+	assert.Equal(t, []string{include}, pcx.AllIncludePaths)
+
+	// changing the lockfile, the package definition or the platform changes the key
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, types.LockfileName), []byte(`{"dependencies":[]}`), 0600))
+	_, ok := pcx.readResolution(key)
+	assert.True(t, ok, "the key is only compared, not recomputed")
+	lockKey, err := pcx.resolutionKey()
+	assert.NoError(t, err)
+	assert.NotEqual(t, key, lockKey)
+
+	pcx.Package.Dependencies = []versioning.DependencyString{"test/other"}
+	depKey, err := pcx.resolutionKey()
+	assert.NoError(t, err)
+	assert.NotEqual(t, lockKey, depKey)
+
+	pcx.Platform = "windows"
+	platformKey, err := pcx.resolutionKey()
+	assert.NoError(t, err)
+	assert.NotEqual(t, depKey, platformKey)
+
+	// a dependency that is no longer cached means the tree must be resolved again
+	os.RemoveAll(pcx.cachePath(lib))
+	_, ok = pcx.readResolution(key)
+	assert.False(t, ok)
+}
@@ -0,0 +1,43 @@
+package rook
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/runtime"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// CacheResources downloads the resources that the dependencies of the package provide for its
+// platform into the resource cache, which is shared by all packages. Once they are cached, ensuring
+// and running the package can get its plugins without a network. The dependencies themselves are
+// cached first since their package definitions declare the resources.
+func (pcx *PackageContext) CacheResources(ctx context.Context) (cached []versioning.DependencyMeta, err error) {
+	err = pcx.EnsureDependenciesCached(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, meta := range pcx.AllDependencies {
+		pkg, errPkg := types.PackageFromDir(meta.PackageDir(pcx.cachePath(meta)))
+		if errPkg != nil {
+			print.Verb(meta, "has no package definition, skipping:", errPkg)
+			continue
+		}
+		if _, errResource := runtime.GetResourceForPlatform(pkg.Resources, pcx.Platform); errResource != nil {
+			print.Verb(meta, "has no resources for", pcx.Platform)
+			continue
+		}
+
+		print.Verb(meta, "caching resource for", pcx.Platform)
+		_, _, err = runtime.EnsureVersionedPluginCached(ctx, meta, pcx.Platform, pcx.CacheDir, false, pcx.GitHub)
+		if err != nil {
+			return cached, errors.Wrapf(err, "failed to cache resource of %s", meta)
+		}
+		cached = append(cached, meta)
+	}
+	return
+}
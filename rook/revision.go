@@ -0,0 +1,91 @@
+package rook
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+)
+
+// revisionFiles are the files of a package that decide which dependencies it has at a revision
+var revisionFiles = []string{"pawn.json", "pawn.yaml", types.LockfileName}
+
+// EnsureRevision ensures the dependencies that the package declared at a revision of its repository,
+// which may be a tag, branch or commit hash, into a directory of their own so the working tree is
+// left alone. The package definition and lockfile of the revision are written to the directory and
+// the dependencies are vendored in it at the commits that lockfile pinned. A revision without a
+// lockfile has its version constraints resolved again, which may not match what was used then.
+func (pcx *PackageContext) EnsureRevision(ctx context.Context, revision, dir string) (snapshot *PackageContext, err error) {
+	repo, err := git.PlainOpen(pcx.Package.LocalPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read package as git repository")
+	}
+	commit, err := revisionCommit(repo, revision)
+	if err != nil {
+		return
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read tree of %s", revision)
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create revision directory")
+	}
+
+	locked := false
+	for _, name := range revisionFiles {
+		filename := filepath.Join(dir, name)
+		// files left from another revision would be mixed with those of this one
+		err = os.Remove(filename)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "failed to remove %s", filename)
+		}
+
+		var file *object.File
+		file, err = tree.File(name)
+		if err == object.ErrFileNotFound {
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s at %s", name, revision)
+		}
+		var contents string
+		contents, err = file.Contents()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s at %s", name, revision)
+		}
+		err = ioutil.WriteFile(filename, []byte(contents), 0644)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to write %s", filename)
+		}
+		locked = locked || name == types.LockfileName
+	}
+
+	snapshot, err = NewPackageContext(ctx, pcx.GitHub, pcx.GitAuth, true, dir, pcx.Platform, pcx.CacheDir, "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read package definition at %s", revision)
+	}
+	snapshot.Refresh = pcx.Refresh
+	snapshot.ResolutionTTL = pcx.ResolutionTTL
+
+	if locked {
+		snapshot.Frozen = true
+	} else {
+		print.Warn(pcx.Package, "has no", types.LockfileName, "at", revision+", its dependencies are resolved again and may not match that revision")
+	}
+
+	print.Verb(pcx.Package, "ensuring dependencies of", revision, "at commit", commit.Hash, "in", dir)
+	err = snapshot.EnsureDependencies(ctx, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to ensure dependencies of %s", revision)
+	}
+	return
+}
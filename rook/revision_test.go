@@ -0,0 +1,78 @@
+package rook
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestPackageContext_EnsureRevision(t *testing.T) {
+	dir := util.FullPath("./tests/revision")
+	os.RemoveAll(dir)
+	pkgDir := filepath.Join(dir, "package")
+	assert.NoError(t, os.MkdirAll(pkgDir, 0700))
+
+	repo, err := git.PlainInit(pkgDir, false)
+	assert.NoError(t, err)
+	wt, err := repo.Worktree()
+	assert.NoError(t, err)
+
+	commit := func(tag string, files map[string]string, removed ...string) {
+		for name, contents := range files {
+			assert.NoError(t, ioutil.WriteFile(filepath.Join(pkgDir, name), []byte(contents), 0644))
+			_, errAdd := wt.Add(name)
+			assert.NoError(t, errAdd)
+		}
+		for _, name := range removed {
+			_, errRemove := wt.Remove(name)
+			assert.NoError(t, errRemove)
+		}
+		signature := &object.Signature{Name: "test", Email: "test@test", When: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)}
+		hash, errCommit := wt.Commit(tag, &git.CommitOptions{Author: signature, Committer: signature})
+		assert.NoError(t, errCommit)
+		assert.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName("refs/tags/"+tag), hash)))
+	}
+	commit("1.0.0", map[string]string{
+		"pawn.json": `{"user": "user", "repo": "repo", "entry": "old.pwn", "output": "old.amx"}`,
+		"pawn.lock": `{"dependencies": []}`,
+	})
+	commit("2.0.0", map[string]string{
+		"pawn.json": `{"user": "user", "repo": "repo", "entry": "new.pwn", "output": "new.amx"}`,
+	}, "pawn.lock")
+
+	pcx, err := NewPackageContext(context.Background(), nil, nil, true, pkgDir, "linux", filepath.Join(dir, "cache"), "")
+	assert.NoError(t, err)
+
+	refDir := filepath.Join(dir, "1.0.0")
+	snapshot, err := pcx.EnsureRevision(context.Background(), "1.0.0", refDir)
+	assert.NoError(t, err)
+	assert.True(t, snapshot.Frozen)
+	assert.Equal(t, refDir, snapshot.Package.LocalPath)
+	assert.Equal(t, "old.pwn", snapshot.Package.Entry)
+	assert.True(t, util.Exists(filepath.Join(refDir, "pawn.lock")))
+
+	// the working tree is left at the newest revision
+	contents, err := ioutil.ReadFile(filepath.Join(pkgDir, "pawn.json"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(contents), "new.pwn")
+	assert.False(t, util.Exists(filepath.Join(pkgDir, "pawn.lock")))
+
+	// a revision without a lockfile has its constraints resolved again
+	snapshot, err = pcx.EnsureRevision(context.Background(), "2.0.0", refDir)
+	assert.NoError(t, err)
+	assert.False(t, snapshot.Frozen)
+	assert.Equal(t, "new.pwn", snapshot.Package.Entry)
+
+	_, err = pcx.EnsureRevision(context.Background(), "3.0.0", refDir)
+	assert.Error(t, err)
+}
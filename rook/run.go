@@ -14,6 +14,7 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/Southclaws/sampctl/compiler"
 	"github.com/Southclaws/sampctl/print"
 	"github.com/Southclaws/sampctl/runtime"
 	"github.com/Southclaws/sampctl/types"
@@ -56,7 +57,7 @@ func (pcx *PackageContext) RunWatch(ctx context.Context) (err error) {
 	running.Store(false)
 
 	go func() {
-		errorCh <- pcx.BuildWatch(ctx, pcx.BuildName, pcx.ForceEnsure, pcx.BuildFile, pcx.Relative, trigger)
+		errorCh <- pcx.BuildWatch(ctx, pcx.buildName(), pcx.ForceEnsure, pcx.BuildFile, pcx.Relative, trigger)
 	}()
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 
@@ -91,7 +92,7 @@ loop:
 				defer cancel()
 			}
 
-			err = runtime.CopyFileToRuntime(pcx.CacheDir, pcx.Package.Runtime.Version, util.FullPath(pcx.Package.Output))
+			err = runtime.CopyFileToRuntime(pcx.CacheDir, pcx.Package.Runtime.Version, filepath.Join(pcx.Package.LocalPath, pcx.Package.Output))
 			if err != nil {
 				err = errors.Wrap(err, "failed to copy amx file to temporary runtime directory")
 				print.Erro(err)
@@ -118,13 +119,21 @@ loop:
 }
 
 func (pcx *PackageContext) runPrepare(ctx context.Context) (err error) {
+	runtimeConfig, build := pcx.selectRuntime()
+
+	// the output may be a template, it's named the same way the build names it
+	pcx.Package.Output, err = pcx.expandOutput(pcx.Package.Output, build)
+	if err != nil {
+		return
+	}
+
 	var (
 		filename = filepath.Join(pcx.Package.LocalPath, pcx.Package.Output)
 		problems types.BuildProblems
 		canRun   = true
 	)
 	if !util.Exists(filename) || pcx.ForceBuild {
-		problems, _, err = pcx.Build(ctx, pcx.BuildName, pcx.ForceEnsure, false, pcx.Relative, pcx.BuildFile)
+		problems, _, err = pcx.Build(ctx, pcx.buildName(), pcx.ForceEnsure, false, pcx.Relative, pcx.BuildFile)
 		if err != nil {
 			return
 		}
@@ -141,8 +150,12 @@ func (pcx *PackageContext) runPrepare(ctx context.Context) (err error) {
 		return
 	}
 
-	pcx.Package.Runtime = GetRuntimeConfig(pcx.Package, pcx.Runtime)
+	pcx.Package.Runtime = runtimeConfig
 	pcx.Package.Runtime.Gamemodes = []string{strings.TrimSuffix(filepath.Base(pcx.Package.Output), ".amx")}
+	pcx.Package.Runtime.Coverage, err = compiler.ReadCoverageManifest(filename)
+	if err != nil {
+		return
+	}
 
 	pcx.Package.Runtime.AppVersion = pcx.AppVersion
 	pcx.Package.Runtime.Format = pcx.Package.Format
@@ -153,6 +166,12 @@ func (pcx *PackageContext) runPrepare(ctx context.Context) (err error) {
 		pcx.Package.Runtime.Platform = rt.GOOS
 	}
 
+	declared := pcx.Package.Runtime.Version
+	pcx.Package.Runtime.Version, err = pcx.runtimeVersion(declared)
+	if err != nil {
+		return
+	}
+
 	if !pcx.Package.Local {
 		print.Verb(pcx.Package, "package is not local, preparing temporary runtime")
 
@@ -161,6 +180,7 @@ func (pcx *PackageContext) runPrepare(ctx context.Context) (err error) {
 			scriptfiles = ""
 		}
 		err = runtime.PrepareRuntimeDirectory(
+			ctx,
 			pcx.CacheDir,
 			pcx.Package.Runtime.Version,
 			pcx.Package.Runtime.Platform,
@@ -189,11 +209,15 @@ func (pcx *PackageContext) runPrepare(ctx context.Context) (err error) {
 		}
 	}
 
-	print.Verb(pcx.Package, "ensuring dependencies pre-run")
-	err = pcx.EnsureDependencies(ctx, false)
-	if err != nil {
-		err = errors.Wrap(err, "failed to ensure dependencies")
-		return
+	if pcx.ReadOnlyVendor {
+		print.Verb(pcx.Package, "the vendor directory is read-only, not ensuring dependencies pre-run")
+	} else {
+		print.Verb(pcx.Package, "ensuring dependencies pre-run")
+		err = pcx.EnsureDependencies(ctx, false)
+		if err != nil {
+			err = errors.Wrap(err, "failed to ensure dependencies")
+			return
+		}
 	}
 
 	print.Verb(pcx.Package, "gathering plugins pre-run")
@@ -203,6 +227,17 @@ func (pcx *PackageContext) runPrepare(ctx context.Context) (err error) {
 		return
 	}
 
+	err = pcx.applyServerConfig()
+	if err != nil {
+		err = errors.Wrap(err, "failed to apply server.cfg fragments of dependencies")
+		return
+	}
+
+	lock, err := pcx.loadPluginChecksums()
+	if err != nil {
+		return
+	}
+
 	print.Verb(pcx.Package, "ensuring runtime pre-run")
 	err = runtime.Ensure(ctx, pcx.GitHub, pcx.Package.Runtime, pcx.NoCache)
 	if err != nil {
@@ -210,9 +245,77 @@ func (pcx *PackageContext) runPrepare(ctx context.Context) (err error) {
 		return
 	}
 
+	err = pcx.recordPluginChecksums(lock)
+	if err != nil {
+		err = errors.Wrap(err, "failed to record plugin checksums")
+		return
+	}
+
+	err = pcx.pinServerBinary(declared)
+	if err != nil {
+		err = errors.Wrap(err, "failed to pin server binary")
+		return
+	}
+
 	return
 }
 
+// selectRuntime picks the runtime config to run the package with. When a build is selected, either
+// with its name or in place of the name of a runtime config that doesn't exist, its output is run
+// instead of the package output, with the runtime config the build names and its overrides. When no
+// build is selected, the output of the default build for the platform is run if it declares one, so
+// a build can place its output in a different server layout on each platform. The name of the build
+// whose output is run is returned along with the runtime config.
+func (pcx *PackageContext) selectRuntime() (config *types.Runtime, build string) {
+	if pcx.BuildName == "" && pcx.Runtime != "default" && !hasRuntimeConfig(pcx.Package, pcx.Runtime) {
+		for _, build := range pcx.buildNames() {
+			if build == pcx.Runtime {
+				print.Verb(pcx.Package, "no runtime config called", pcx.Runtime, "running the build with that name")
+				pcx.BuildName, pcx.Runtime = pcx.Runtime, "default"
+				break
+			}
+		}
+	}
+
+	name := pcx.Runtime
+	var overrides *types.Runtime
+	selected := GetBuildConfig(pcx.Package, pcx.buildName(), pcx.Platform)
+	if selected.Output != "" {
+		pcx.Package.Output = selected.Output
+	}
+	if pcx.BuildName != "" {
+		if selected.Runtime != "" && name == "default" {
+			name = selected.Runtime
+		}
+		overrides = selected.RuntimeOverrides
+	}
+	build = selected.Name
+
+	config = GetRuntimeConfig(pcx.Package, name)
+	if overrides != nil {
+		overlaid := config.Overlay(*overrides)
+		config = &overlaid
+	}
+	return
+}
+
+// buildName is the build that is run, which is the default build unless one is selected
+func (pcx *PackageContext) buildName() string {
+	if pcx.BuildName == "" {
+		return "default"
+	}
+	return pcx.BuildName
+}
+
+func hasRuntimeConfig(pkg types.Package, name string) bool {
+	for _, cfg := range pkg.Runtimes {
+		if cfg.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // GetRuntimeConfig returns a matching runtime config by name from the package
 // runtime list. If no name is specified, the first config is returned. If the
 // package has no configurations, a default configuration is returned.
@@ -0,0 +1,82 @@
+package rook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+)
+
+func TestPackageContext_selectRuntime(t *testing.T) {
+	debugHostname := "debug"
+	pkg := types.Package{
+		Output: "main.amx",
+		Runtimes: []*types.Runtime{
+			{Name: "main", Version: "0.3.7"},
+			{Name: "staging", Version: "0.3.DL"},
+		},
+		Builds: []*types.BuildConfig{
+			{Name: "main"},
+			{
+				Name:             "debug",
+				Output:           "debug.amx",
+				Runtime:          "staging",
+				RuntimeOverrides: &types.Runtime{Hostname: &debugHostname, Plugins: []types.Plugin{"crashdetect"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		runtime     string
+		build       string
+		wantRuntime string
+		wantVersion string
+		wantOutput  string
+		wantPlugins []types.Plugin
+	}{
+		{"package runtime", "main", "", "main", "0.3.7", "main.amx", nil},
+		{"build flag", "default", "debug", "staging", "0.3.DL", "debug.amx", []types.Plugin{"crashdetect"}},
+		{"build name", "debug", "", "staging", "0.3.DL", "debug.amx", []types.Plugin{"crashdetect"}},
+		{"explicit runtime", "main", "debug", "main", "0.3.7", "debug.amx", []types.Plugin{"crashdetect"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pcx := PackageContext{Package: pkg, Runtime: tt.runtime, BuildName: tt.build}
+
+			config, _ := pcx.selectRuntime()
+			assert.Equal(t, tt.wantRuntime, config.Name)
+			assert.Equal(t, tt.wantVersion, config.Version)
+			assert.Equal(t, tt.wantPlugins, config.Plugins)
+			assert.Equal(t, tt.wantOutput, pcx.Package.Output)
+			if tt.wantPlugins != nil {
+				assert.Equal(t, "debug", *config.Hostname)
+			}
+		})
+	}
+}
+
+func TestPackageContext_selectRuntimePlatformOutput(t *testing.T) {
+	pkg := types.Package{
+		Output: "main.amx",
+		Builds: []*types.BuildConfig{
+			{
+				Name:   "main",
+				Output: "server/gamemodes/main.amx",
+				Platforms: map[string]*types.BuildConfig{
+					"windows": {Output: "../server/gamemodes/main.amx"},
+				},
+			},
+		},
+	}
+
+	for platform, want := range map[string]string{
+		"linux":   "server/gamemodes/main.amx",
+		"windows": "../server/gamemodes/main.amx",
+	} {
+		pcx := PackageContext{Package: pkg, Runtime: "default", Platform: platform}
+		pcx.selectRuntime()
+		assert.Equal(t, want, pcx.Package.Output, platform)
+	}
+}
@@ -0,0 +1,260 @@
+package rook
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// BOM is a CycloneDX software bill of materials, only the parts of the format that describe a
+// package, its vendored dependencies and the advisories that affect them are included
+type BOM struct {
+	BOMFormat       string             `json:"bomFormat"`
+	SpecVersion     string             `json:"specVersion"`
+	SerialNumber    string             `json:"serialNumber"`
+	Version         int                `json:"version"`
+	Metadata        BOMMetadata        `json:"metadata"`
+	Components      []BOMComponent     `json:"components"`
+	Vulnerabilities []BOMVulnerability `json:"vulnerabilities,omitempty"`
+}
+
+// BOMMetadata describes when and by what a BOM was generated and the package it's for
+type BOMMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     []BOMTool    `json:"tools"`
+	Component BOMComponent `json:"component"`
+}
+
+// BOMTool is the tool that generated a BOM
+type BOMTool struct {
+	Vendor  string `json:"vendor"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// BOMComponent is a package or one of the files it provides, such as a plugin binary
+type BOMComponent struct {
+	Type               string         `json:"type"`
+	BOMRef             string         `json:"bom-ref,omitempty"`
+	Group              string         `json:"group,omitempty"`
+	Name               string         `json:"name"`
+	Version            string         `json:"version,omitempty"`
+	PURL               string         `json:"purl,omitempty"`
+	Hashes             []BOMHash      `json:"hashes,omitempty"`
+	Licenses           []BOMLicense   `json:"licenses,omitempty"`
+	ExternalReferences []BOMReference `json:"externalReferences,omitempty"`
+	Properties         []BOMProperty  `json:"properties,omitempty"`
+	Components         []BOMComponent `json:"components,omitempty"`
+}
+
+// BOMHash is a checksum of a component
+type BOMHash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+// BOMLicense is the license of a component, either a recognised SPDX identifier or a name
+type BOMLicense struct {
+	License struct {
+		ID   string `json:"id,omitempty"`
+		Name string `json:"name,omitempty"`
+	} `json:"license"`
+}
+
+// BOMReference is a link to where a component comes from
+type BOMReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// BOMProperty is a sampctl specific detail of a component
+type BOMProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// BOMVulnerability is a known problem that affects components, from an advisory
+type BOMVulnerability struct {
+	ID             string      `json:"id"`
+	Source         *BOMSource  `json:"source,omitempty"`
+	Ratings        []BOMRating `json:"ratings,omitempty"`
+	Description    string      `json:"description,omitempty"`
+	Recommendation string      `json:"recommendation,omitempty"`
+	Affects        []BOMAffect `json:"affects"`
+}
+
+// BOMSource is where a vulnerability is described
+type BOMSource struct {
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+// BOMRating is the severity of a vulnerability
+type BOMRating struct {
+	Severity string `json:"severity"`
+}
+
+// BOMAffect refers to a component that a vulnerability affects
+type BOMAffect struct {
+	Ref string `json:"ref"`
+}
+
+// SBOM lists every vendored dependency of the package as a CycloneDX software bill of materials,
+// with the version and commit it's at, its license and the checksums of the plugin binaries its
+// resources provided. The package should be ensured first, the commits and checksums are read from
+// the vendor directory and the lockfile.
+func (pcx *PackageContext) SBOM() (bom BOM, err error) {
+	lock, err := types.ReadLockfile(pcx.Package.LocalPath)
+	if err != nil {
+		return
+	}
+	if lock == nil {
+		lock = &types.Lockfile{}
+	}
+
+	licenses, err := pcx.Licenses()
+	if err != nil {
+		return
+	}
+
+	bom = BOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.4",
+		SerialNumber: "urn:uuid:" + uuid.New().String(),
+		Version:      1,
+		Metadata: BOMMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Tools:     []BOMTool{{Vendor: "Southclaws", Name: "sampctl", Version: pcx.AppVersion}},
+			Component: BOMComponent{
+				Type:    "application",
+				Group:   pcx.Package.User,
+				Name:    pcx.Package.Repo,
+				Version: pcx.Package.Tag,
+			},
+		},
+		Components: []BOMComponent{},
+	}
+	for _, license := range licenses {
+		var component BOMComponent
+		component, err = pcx.bomComponent(license, *lock)
+		if err != nil {
+			return
+		}
+		bom.Components = append(bom.Components, component)
+	}
+	return
+}
+
+func (pcx *PackageContext) bomComponent(license DependencyLicense, lock types.Lockfile) (component BOMComponent, err error) {
+	meta := license.Dependency
+	version, commit, err := pcx.dependencyVersion(meta, lock)
+	if err != nil {
+		return
+	}
+	if version == "" {
+		version = commit
+	}
+
+	site := meta.Site
+	if site == "" {
+		site = "github.com"
+	}
+	component = BOMComponent{
+		Type:               "library",
+		Group:              meta.User,
+		Name:               meta.Repo,
+		Version:            version,
+		PURL:               bomPURL(site, meta, version),
+		ExternalReferences: []BOMReference{{Type: "vcs", URL: fmt.Sprintf("https://%s/%s/%s", site, meta.User, meta.Repo)}},
+		Properties:         []BOMProperty{{Name: "sampctl:dependency", Value: meta.String()}},
+	}
+	component.BOMRef = component.PURL
+	if commit != "" {
+		component.Properties = append(component.Properties, BOMProperty{Name: "sampctl:commit", Value: commit})
+	}
+
+	switch license.Status {
+	case LicenseMissing:
+		// there's no license to list
+	case LicenseUnknown:
+		bomLicense := BOMLicense{}
+		bomLicense.License.Name = "unrecognised license in " + license.File
+		component.Licenses = []BOMLicense{bomLicense}
+	default:
+		bomLicense := BOMLicense{}
+		bomLicense.License.ID = license.License
+		component.Licenses = []BOMLicense{bomLicense}
+	}
+
+	plugins := lock.Plugins(meta)
+	files := make([]string, 0, len(plugins))
+	for file := range plugins {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		component.Components = append(component.Components, BOMComponent{
+			Type:   "file",
+			Name:   file,
+			Hashes: []BOMHash{{Algorithm: "SHA-256", Content: plugins[file]}},
+		})
+	}
+	return
+}
+
+// dependencyVersion returns the version tag and commit that a dependency is vendored at, or only the
+// commit from the lockfile if it isn't vendored
+func (pcx *PackageContext) dependencyVersion(meta versioning.DependencyMeta, lock types.Lockfile) (version, commit string, err error) {
+	version, commit, err = vendoredVersion(filepath.Join(pcx.Package.Vendor, meta.VendorName()))
+	if err != nil {
+		return
+	}
+	if commit == "" {
+		commit, _ = lock.Commit(meta)
+		print.Verb(meta, "is not vendored, using the commit from", types.LockfileName)
+	}
+	return
+}
+
+// vendoredVersion returns the version tag and commit that the vendored copy of a dependency is at,
+// both are empty if it isn't vendored as a repository
+func vendoredVersion(dir string) (version, commit string, err error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", "", nil
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to get vendored commit of %s", dir)
+	}
+	commit = head.Hash().String()
+	if tag, errTag := versioning.GetRepoCurrentVersionedTag(repo); errTag == nil && tag != nil {
+		version = tag.Name
+	}
+	return
+}
+
+// bomPURL is the package URL of a dependency, GitHub has its own type and any other host is
+// described as a generic package with a link to its repository
+func bomPURL(site string, meta versioning.DependencyMeta, version string) string {
+	purl := fmt.Sprintf("pkg:github/%s/%s", meta.User, meta.Repo)
+	if site != "github.com" {
+		purl = fmt.Sprintf("pkg:generic/%s", meta.Repo)
+	}
+	if version != "" {
+		purl += "@" + version
+	}
+	if site != "github.com" {
+		purl += fmt.Sprintf("?vcs_url=git%%2Bhttps://%s/%s/%s", site, meta.User, meta.Repo)
+	}
+	return purl
+}
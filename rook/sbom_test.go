@@ -0,0 +1,84 @@
+package rook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_SBOM(t *testing.T) {
+	dir := util.FullPath("./tests/sbom")
+	os.RemoveAll(dir)
+	vendor := filepath.Join(dir, "dependencies")
+	commitVersions(t, filepath.Join(vendor, "lib"), []string{"1.0.0", "1.1.0"})
+	ioutil.WriteFile(filepath.Join(vendor, "lib", "LICENSE"), []byte("MIT License\n\nPermission is hereby granted, free of charge"), 0644)
+	repo, err := git.PlainOpen(filepath.Join(vendor, "lib"))
+	assert.NoError(t, err)
+	head, err := repo.Head()
+	assert.NoError(t, err)
+
+	lib := versioning.DependencyMeta{User: "test", Repo: "lib", Tag: "^1.0.0"}
+	plugin := versioning.DependencyMeta{Site: "git.example.com", User: "test", Repo: "plugin", Tag: "2.0.0"}
+	lock := types.NewLockfile([]types.LockedDependency{
+		{Dependency: versioning.DependencyString(lib.String()), Commit: head.Hash().String()},
+		{Dependency: versioning.DependencyString(plugin.String()), Commit: "0123456789abcdef0123456789abcdef01234567", Plugins: map[string]string{"plugin.so": "abc123"}},
+	})
+	assert.NoError(t, lock.Write(dir))
+
+	pcx := PackageContext{
+		Package: types.Package{
+			LocalPath:      dir,
+			Vendor:         vendor,
+			DependencyMeta: versioning.DependencyMeta{User: "test", Repo: "gamemode", Tag: "3.0.0"},
+		},
+		AppVersion:      "1.8.0",
+		AllDependencies: []versioning.DependencyMeta{lib, plugin},
+	}
+
+	bom, err := pcx.SBOM()
+	assert.NoError(t, err)
+	assert.Equal(t, "CycloneDX", bom.BOMFormat)
+	assert.Equal(t, BOMComponent{Type: "application", Group: "test", Name: "gamemode", Version: "3.0.0"}, bom.Metadata.Component)
+	assert.Equal(t, []BOMTool{{Vendor: "Southclaws", Name: "sampctl", Version: "1.8.0"}}, bom.Metadata.Tools)
+
+	mit := BOMLicense{}
+	mit.License.ID = "MIT"
+	assert.Equal(t, []BOMComponent{
+		{
+			Type:               "library",
+			BOMRef:             "pkg:generic/plugin@0123456789abcdef0123456789abcdef01234567?vcs_url=git%2Bhttps://git.example.com/test/plugin",
+			Group:              "test",
+			Name:               "plugin",
+			Version:            "0123456789abcdef0123456789abcdef01234567",
+			PURL:               "pkg:generic/plugin@0123456789abcdef0123456789abcdef01234567?vcs_url=git%2Bhttps://git.example.com/test/plugin",
+			ExternalReferences: []BOMReference{{Type: "vcs", URL: "https://git.example.com/test/plugin"}},
+			Properties: []BOMProperty{
+				{Name: "sampctl:dependency", Value: plugin.String()},
+				{Name: "sampctl:commit", Value: "0123456789abcdef0123456789abcdef01234567"},
+			},
+			Components: []BOMComponent{{Type: "file", Name: "plugin.so", Hashes: []BOMHash{{Algorithm: "SHA-256", Content: "abc123"}}}},
+		},
+		{
+			Type:               "library",
+			BOMRef:             "pkg:github/test/lib@1.1.0",
+			Group:              "test",
+			Name:               "lib",
+			Version:            "1.1.0",
+			PURL:               "pkg:github/test/lib@1.1.0",
+			Licenses:           []BOMLicense{mit},
+			ExternalReferences: []BOMReference{{Type: "vcs", URL: "https://github.com/test/lib"}},
+			Properties: []BOMProperty{
+				{Name: "sampctl:dependency", Value: lib.String()},
+				{Name: "sampctl:commit", Value: head.Hash().String()},
+			},
+		},
+	}, bom.Components)
+}
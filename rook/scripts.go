@@ -0,0 +1,135 @@
+package rook
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// ScriptNames returns the names of the scripts a package declares in alphabetical order
+func ScriptNames(pkg types.Package) (names []string) {
+	for name := range pkg.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// RunScript runs a named script of the package from the package directory. Any arguments are
+// appended to the script's command and the include paths and outputs of the given build are passed
+// to it as environment variables, see `ScriptEnv`.
+func (pcx *PackageContext) RunScript(ctx context.Context, name, build string, args []string) (err error) {
+	if !pcx.Package.Parent {
+		return errors.Errorf("%s is a dependency, its scripts can only be run from the package that depends on it", pcx.Package)
+	}
+	return pcx.runScript(ctx, pcx.Package.Scripts, pcx.Package.LocalPath, pcx.Package.String(), name, build, args)
+}
+
+// RunDependencyScript runs a named script of a vendored dependency from the dependency's directory
+// with the same environment as `RunScript`. Scripts of dependencies are code from somebody else, so
+// this is refused unless `allow` is set.
+func (pcx *PackageContext) RunDependencyScript(ctx context.Context, dep versioning.DependencyMeta, name, build string, args []string, allow bool) (err error) {
+	if !allow {
+		return errors.Errorf("running scripts of dependencies is disabled, allow it explicitly to run %s of %s", name, dep)
+	}
+
+	var found *versioning.DependencyMeta
+	for i := range pcx.AllDependencies {
+		if sameDependency(pcx.AllDependencies[i], dep) {
+			found = &pcx.AllDependencies[i]
+			break
+		}
+	}
+	if found == nil {
+		return errors.Errorf("%s is not a dependency of %s", dep, pcx.Package)
+	}
+
+	dir := found.PackageDir(filepath.Join(pcx.Package.Vendor, found.VendorName()))
+	if !util.Exists(dir) {
+		return errors.Errorf("%s is not vendored, run ensure first", found)
+	}
+	inner, err := types.PackageFromDir(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read package definition of %s", found)
+	}
+
+	print.Warn("running script", name, "of dependency", found)
+	return pcx.runScript(ctx, inner.Scripts, dir, found.String(), name, build, args)
+}
+
+func (pcx *PackageContext) runScript(ctx context.Context, scripts map[string][]string, dir, owner, name, build string, args []string) (err error) {
+	command, ok := scripts[name]
+	if !ok {
+		return errors.Errorf("%s has no script named %s", owner, name)
+	}
+	if len(command) == 0 {
+		return errors.Errorf("script %s of %s has no command", name, owner)
+	}
+
+	env, err := pcx.ScriptEnv(ctx, build)
+	if err != nil {
+		return errors.Wrap(err, "failed to prepare script environment")
+	}
+
+	command = append(append([]string{}, command...), args...)
+	print.Verb("running script", name+":", strings.Join(command, " "))
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...) //nolint:gas
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		return errors.Wrapf(err, "script %s failed", name)
+	}
+	return
+}
+
+// ScriptEnv returns the environment variables that scripts are run with, as `KEY=value` pairs:
+//
+// - `SAMPCTL_PACKAGE`: the package directory
+// - `SAMPCTL_VENDOR`: the directory dependencies are vendored in
+// - `SAMPCTL_INCLUDES`: the include paths of the build, separated by the OS path list separator
+// - `SAMPCTL_BUILD`: the name of the build
+// - `SAMPCTL_INPUT` and `SAMPCTL_OUTPUT`: the entry script and the output of the build
+// - `SAMPCTL_PLATFORM`: the target platform
+//
+// Dependencies are not ensured, so the include paths are those of the currently vendored copies.
+func (pcx *PackageContext) ScriptEnv(ctx context.Context, build string) (env []string, err error) {
+	config, err := pcx.buildPrepare(ctx, build, false, false)
+	if err != nil {
+		return
+	}
+
+	includes := make([]string, len(config.Includes))
+	for i, inc := range config.Includes {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(pcx.Package.LocalPath, inc)
+		}
+		includes[i] = inc
+	}
+
+	env = []string{
+		fmt.Sprintf("SAMPCTL_PACKAGE=%s", pcx.Package.LocalPath),
+		fmt.Sprintf("SAMPCTL_VENDOR=%s", pcx.Package.Vendor),
+		fmt.Sprintf("SAMPCTL_INCLUDES=%s", strings.Join(includes, string(os.PathListSeparator))),
+		fmt.Sprintf("SAMPCTL_BUILD=%s", config.Name),
+		fmt.Sprintf("SAMPCTL_INPUT=%s", config.Input),
+		fmt.Sprintf("SAMPCTL_OUTPUT=%s", config.Output),
+		fmt.Sprintf("SAMPCTL_PLATFORM=%s", pcx.Platform),
+	}
+	return
+}
@@ -0,0 +1,66 @@
+package rook
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_RunScript(t *testing.T) {
+	dir := util.FullPath("./tests/scripts")
+	os.RemoveAll(dir)
+	depDir := filepath.Join(dir, "dependencies", "lib")
+	os.MkdirAll(depDir, 0700)
+	ioutil.WriteFile(filepath.Join(depDir, "lib.inc"), []byte("// lib"), 0600)
+	ioutil.WriteFile(filepath.Join(depDir, "pawn.json"), []byte(`{"scripts": {"where": ["sh", "-c", "pwd > where"]}}`), 0600)
+
+	lib := versioning.DependencyMeta{User: "someone", Repo: "lib"}
+	pcx := PackageContext{
+		Package: types.Package{
+			DependencyMeta: versioning.DependencyMeta{User: "me", Repo: "gamemode"},
+			Parent:         true,
+			LocalPath:      dir,
+			Vendor:         filepath.Join(dir, "dependencies"),
+			Entry:          "main.pwn",
+			Output:         "main.amx",
+			Scripts: map[string][]string{
+				"env":   {"sh", "-c", `echo "$SAMPCTL_INCLUDES $SAMPCTL_OUTPUT $1" > env`, "sh"},
+				"empty": {},
+			},
+		},
+		CacheDir:        "./tests/cache",
+		Platform:        "linux",
+		AllDependencies: []versioning.DependencyMeta{lib},
+	}
+
+	assert.Equal(t, []string{"empty", "env"}, ScriptNames(pcx.Package))
+
+	err := pcx.RunScript(context.Background(), "env", "default", []string{"extra"})
+	assert.NoError(t, err)
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "env"))
+	assert.NoError(t, err)
+	assert.Equal(t, depDir+" "+filepath.Join(dir, "main.amx")+" extra\n", string(contents))
+
+	assert.EqualError(t, pcx.RunScript(context.Background(), "lint", "default", nil), "me/gamemode has no script named lint")
+	assert.EqualError(t, pcx.RunScript(context.Background(), "empty", "default", nil), "script empty of me/gamemode has no command")
+
+	// scripts of dependencies only run when explicitly allowed
+	err = pcx.RunDependencyScript(context.Background(), lib, "where", "default", nil, false)
+	assert.EqualError(t, err, "running scripts of dependencies is disabled, allow it explicitly to run where of someone/lib")
+	assert.False(t, util.Exists(filepath.Join(depDir, "where")))
+
+	err = pcx.RunDependencyScript(context.Background(), lib, "where", "default", nil, true)
+	assert.NoError(t, err)
+	contents, err = ioutil.ReadFile(filepath.Join(depDir, "where"))
+	assert.NoError(t, err)
+	real, _ := filepath.EvalSymlinks(depDir)
+	assert.Equal(t, real+"\n", string(contents))
+}
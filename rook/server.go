@@ -0,0 +1,71 @@
+package rook
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/runtime"
+	"github.com/Southclaws/sampctl/types"
+)
+
+// runtimeVersion returns the server version to run for the version a runtime config declares. Once
+// the lockfile records what the declared version resolved to, that version is run, so an alias
+// keeps running the same server until the lockfile is updated.
+func (pcx *PackageContext) runtimeVersion(version string) (resolved string, err error) {
+	lock, err := types.ReadLockfile(pcx.Package.LocalPath)
+	if err != nil {
+		return
+	}
+	if lock != nil {
+		if locked, ok := lock.RuntimeVersion(version); ok {
+			print.Verb(pcx.Package, "running server", locked, "locked for", version)
+			return locked, nil
+		}
+	}
+
+	resolved, err = runtime.ResolveVersion(pcx.CacheDir, version)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve server version %s", version)
+	}
+	return
+}
+
+// pinServerBinary checks the server binary that was ensured for the runtime against the checksum
+// pinned in the lockfile for the platform. The version and checksum are pinned the first time the
+// server is ensured on a platform, in frozen mode a server that isn't pinned yet is an error.
+func (pcx *PackageContext) pinServerBinary(version string) (err error) {
+	cfg := pcx.Package.Runtime
+	checksum, err := runtime.ServerChecksum(cfg.WorkingDir, cfg.Platform)
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			print.Verb(pcx.Package, "no server binary in", cfg.WorkingDir, "to pin")
+			return nil
+		}
+		return
+	}
+
+	lock, err := types.ReadLockfile(pcx.Package.LocalPath)
+	if err != nil {
+		return
+	}
+	if lock == nil {
+		lock = &types.Lockfile{Dependencies: []types.LockedDependency{}}
+	}
+
+	expected, ok := lock.RuntimeChecksum(version, cfg.Platform)
+	switch {
+	case ok && !strings.EqualFold(expected, checksum):
+		return errors.Errorf("server %s for %s does not match %s: expected %s, got %s",
+			cfg.Version, cfg.Platform, types.LockfileName, expected, checksum)
+	case !ok && pcx.Frozen:
+		return errors.Errorf("frozen run would add server %s for %s to %s", cfg.Version, cfg.Platform, types.LockfileName)
+	case !ok:
+		print.Verb(pcx.Package, "recording server", cfg.Version, "for", cfg.Platform, "in", types.LockfileName)
+		lock.SetRuntimeChecksum(version, cfg.Version, cfg.Platform, checksum)
+		err = lock.Write(pcx.Package.LocalPath)
+	}
+	return
+}
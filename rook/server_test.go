@@ -0,0 +1,53 @@
+package rook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestPackageContext_pinServerBinary(t *testing.T) {
+	dir := util.FullPath("./tests/server")
+	os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+
+	newContext := func(frozen bool) *PackageContext {
+		return &PackageContext{
+			Package: types.Package{
+				LocalPath: dir,
+				Runtime:   &types.Runtime{WorkingDir: dir, Platform: "linux", Version: "0.3.7-R2-2-1"},
+			},
+			Frozen: frozen,
+		}
+	}
+
+	// no server binary, nothing to pin
+	assert.NoError(t, newContext(false).pinServerBinary("0.3.7"))
+	assert.False(t, util.Exists(filepath.Join(dir, types.LockfileName)))
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "samp03svr"), []byte("server"), 0700))
+	assert.EqualError(t, newContext(true).pinServerBinary("0.3.7"),
+		"frozen run would add server 0.3.7-R2-2-1 for linux to pawn.lock")
+
+	assert.NoError(t, newContext(false).pinServerBinary("0.3.7"))
+	lock, err := types.ReadLockfile(dir)
+	assert.NoError(t, err)
+	resolved, ok := lock.RuntimeVersion("0.3.7")
+	assert.True(t, ok)
+	assert.Equal(t, "0.3.7-R2-2-1", resolved)
+
+	version, err := newContext(false).runtimeVersion("0.3.7")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.3.7-R2-2-1", version)
+
+	assert.NoError(t, newContext(true).pinServerBinary("0.3.7"))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "samp03svr"), []byte("damaged"), 0700))
+	assert.Contains(t, newContext(false).pinServerBinary("0.3.7").Error(),
+		"server 0.3.7-R2-2-1 for linux does not match pawn.lock")
+}
@@ -0,0 +1,127 @@
+package rook
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+type serverSetting struct {
+	name  string
+	value string
+	owner versioning.DependencyMeta
+}
+
+// ResolveServerConfig collects the server.cfg fragments of dependencies into settings and plugins
+// to add to a runtime config. Settings the runtime config already has in Extra are the user's own
+// and always kept, so fragments only fill in the rest. If two packages set the same setting to
+// different values, all conflicts are reported in a single error. Settings that have their own
+// runtime field, such as `maxplayers`, can't be set by fragments and only produce a warning.
+func ResolveServerConfig(cfg types.Runtime, packages []types.Package) (settings map[string]string, plugins []types.Plugin, err error) {
+	var (
+		explicit  = make(map[string]bool)
+		loaded    = make(map[string]bool)
+		resolved  = make(map[string]serverSetting)
+		conflicts []string
+	)
+	for name := range cfg.Extra {
+		explicit[strings.ToLower(name)] = true
+	}
+	for _, plugin := range cfg.Plugins {
+		loaded[pluginKey(plugin)] = true
+	}
+
+	for _, pkg := range packages {
+		names := make([]string, 0, len(pkg.ServerConfig))
+		for name := range pkg.ServerConfig {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			value := pkg.ServerConfig[name]
+			key := strings.ToLower(name)
+
+			if key == "plugins" {
+				for _, plugin := range strings.Fields(value) {
+					if loaded[pluginKey(types.Plugin(plugin))] {
+						continue
+					}
+					print.Verb(pkg, "server.cfg fragment adds plugin", plugin)
+					loaded[pluginKey(types.Plugin(plugin))] = true
+					plugins = append(plugins, types.Plugin(plugin))
+				}
+				continue
+			}
+			if types.IsServerCfgField(name) {
+				print.Warn(pkg, "sets", name, "in its server.cfg fragment but that is a runtime setting, ignoring it")
+				continue
+			}
+			if explicit[key] {
+				print.Verb(pkg, "sets", name, "but the runtime config sets it too, keeping the runtime config value")
+				continue
+			}
+
+			existing, exists := resolved[key]
+			if !exists {
+				print.Verb(pkg, "server.cfg fragment sets", name, "to", value)
+				resolved[key] = serverSetting{name, value, pkg.DependencyMeta}
+				continue
+			}
+			if existing.value != value {
+				conflicts = append(conflicts, fmt.Sprintf(
+					"'%s' is '%s' from %s but '%s' from %s",
+					name, existing.value, existing.owner, value, pkg.DependencyMeta,
+				))
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		err = errors.Errorf("conflicting server.cfg settings, set them in the runtime config to choose a value:\n%s", strings.Join(conflicts, "\n"))
+		return
+	}
+
+	settings = make(map[string]string)
+	for _, setting := range resolved {
+		settings[setting.name] = setting.value
+	}
+	return
+}
+
+// pluginKey identifies a plugin in a plugin list regardless of its extension and case
+func pluginKey(plugin types.Plugin) string {
+	name := string(plugin)
+	return strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+}
+
+// applyServerConfig merges the server.cfg fragments of every dependency into the runtime config
+func (pcx *PackageContext) applyServerConfig() (err error) {
+	var packages []types.Package
+	for _, depMeta := range pcx.AllDependencies {
+		pkg, found, _, _ := pcx.dependencyIncludes(depMeta)
+		if found && len(pkg.ServerConfig) > 0 {
+			packages = append(packages, pkg)
+		}
+	}
+
+	settings, plugins, err := ResolveServerConfig(*pcx.Package.Runtime, packages)
+	if err != nil {
+		return
+	}
+	if len(settings) > 0 && pcx.Package.Runtime.Extra == nil {
+		pcx.Package.Runtime.Extra = make(map[string]string)
+	}
+	for name, value := range settings {
+		pcx.Package.Runtime.Extra[name] = value
+	}
+	pcx.Package.Runtime.Plugins = append(pcx.Package.Runtime.Plugins, plugins...)
+	return
+}
@@ -0,0 +1,58 @@
+package rook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestResolveServerConfig(t *testing.T) {
+	mysql := types.Package{
+		DependencyMeta: versioning.DependencyMeta{User: "pBlueG", Repo: "SA-MP-MySQL"},
+		ServerConfig:   map[string]string{"mysql_log": "ERROR", "plugins": "mysql"},
+	}
+	streamer := types.Package{
+		DependencyMeta: versioning.DependencyMeta{User: "samp-incognito", Repo: "samp-streamer-plugin"},
+		ServerConfig:   map[string]string{"streamer_ticks": "50", "maxplayers": "100", "plugins": "streamer.so MySQL"},
+	}
+	logger := types.Package{
+		DependencyMeta: versioning.DependencyMeta{User: "Southclaws", Repo: "samp-logger"},
+		ServerConfig:   map[string]string{"mysql_log": "ALL"},
+	}
+
+	tests := []struct {
+		name        string
+		cfg         types.Runtime
+		packages    []types.Package
+		wantConfig  map[string]string
+		wantPlugins []types.Plugin
+		wantErr     bool
+	}{
+		{"none", types.Runtime{}, nil, map[string]string{}, nil, false},
+		{"merged", types.Runtime{}, []types.Package{mysql, streamer},
+			map[string]string{"mysql_log": "ERROR", "streamer_ticks": "50"},
+			[]types.Plugin{"mysql", "streamer.so"}, false},
+		{"already loaded", types.Runtime{Plugins: []types.Plugin{"streamer"}}, []types.Package{streamer},
+			map[string]string{"streamer_ticks": "50"},
+			[]types.Plugin{"MySQL"}, false},
+		{"conflict", types.Runtime{}, []types.Package{mysql, logger}, nil, nil, true},
+		{"user wins", types.Runtime{Extra: map[string]string{"MYSQL_LOG": "WARNING"}}, []types.Package{mysql, logger},
+			map[string]string{},
+			[]types.Plugin{"mysql"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotConfig, gotPlugins, err := ResolveServerConfig(tt.cfg, tt.packages)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantConfig, gotConfig)
+			assert.Equal(t, tt.wantPlugins, gotPlugins)
+		})
+	}
+}
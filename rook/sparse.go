@@ -0,0 +1,173 @@
+package rook
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// ensureSparse vendors a dependency that specifies a `Path` or a `Package` as a partial clone with
+// a sparse checkout of that directory, so only the history and the files under it are downloaded
+// instead of the whole repository. The git library used for everything else supports neither, so
+// this relies on the `git` command and doesn't go through the cache. If `git` isn't installed, the
+// remote doesn't support it or the dependency was already vendored as a full clone, `sparse` is
+// false and the dependency should be ensured as usual.
+func (pcx *PackageContext) ensureSparse(ctx context.Context, meta versioning.DependencyMeta, dir string, forceUpdate bool) (sparse bool, err error) {
+	if sparseDir(meta) == "" || pcx.Registry.handles(meta) {
+		return false, nil
+	}
+	binary, err := exec.LookPath("git")
+	if err != nil {
+		print.Verb(meta, "git command not available, cloning the full repository:", err)
+		return false, nil
+	}
+
+	fetch := forceUpdate || meta.Tag == ""
+	if util.Exists(dir) {
+		if !isSparse(dir) {
+			return false, nil
+		}
+		repo, problem := openVendored(dir)
+		if problem != "" {
+			print.Verb(meta, problem, "- cloning new copy")
+			err = os.RemoveAll(dir)
+			if err != nil {
+				return false, errors.Wrap(err, "failed to remove incomplete dependency repo")
+			}
+		} else {
+			err = restoreTransformed(repo, dir)
+			if err != nil {
+				return false, errors.Wrap(err, "failed to restore transformed files")
+			}
+		}
+	}
+
+	if !util.Exists(dir) {
+		ok, errClone := cloneSparse(ctx, binary, meta, dir)
+		if errClone != nil || !ok {
+			return false, errClone
+		}
+		fetch = false
+	}
+
+	if fetch {
+		print.Debug(meta, "fetching latest sparse copy")
+		output, errFetch := runGit(ctx, binary, dir, "fetch", "--quiet", "--tags", "origin")
+		if errFetch != nil {
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			return false, errors.Wrapf(errFetch, "failed to fetch sparse copy: %s", output)
+		}
+	}
+
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to open sparse copy")
+	}
+	ref, err := pcx.sparseRef(ctx, repo, meta)
+	if err != nil {
+		return false, err
+	}
+
+	print.Debug(meta, "checking out", ref.Hash(), "in sparse copy")
+	output, err := runGit(ctx, binary, dir, "-c", "advice.detachedHead=false", "checkout", "--quiet", "--force", ref.Hash().String())
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		return false, errors.Wrapf(err, "failed to checkout necessary commit %s: %s", ref.Hash(), output)
+	}
+	markCloned(dir)
+	return true, nil
+}
+
+// cloneSparse makes a partial clone of a dependency without checking anything out and limits its
+// checkout to the directory of the dependency. `ok` is false if either isn't supported, then nothing
+// is left behind.
+func cloneSparse(ctx context.Context, binary string, meta versioning.DependencyMeta, dir string) (ok bool, err error) {
+	url, _ := cloneURL(meta)
+	print.Verb(meta, "making a partial clone of", url, "limited to", sparseDir(meta))
+
+	err = os.MkdirAll(filepath.Dir(dir), 0700)
+	if err != nil {
+		return
+	}
+	output, err := runGit(ctx, binary, "", "clone", "--quiet", "--filter=blob:none", "--no-checkout", url, dir)
+	if err == nil {
+		output, err = runGit(ctx, binary, dir, "sparse-checkout", "set", "--cone", "--", sparseDir(meta))
+	}
+	if err != nil {
+		if errRemove := os.RemoveAll(dir); errRemove != nil {
+			print.Erro("Failed to remove partial clone:", errRemove)
+		}
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		print.Verb(meta, "sparse checkout not available, cloning the full repository:", err, output)
+		return false, nil
+	}
+	return true, nil
+}
+
+// sparseRef resolves the commit a sparse copy is checked out at. Branches are only fetched as
+// remote branches, so they're looked up there instead of among the local ones.
+func (pcx *PackageContext) sparseRef(ctx context.Context, repo *git.Repository, meta versioning.DependencyMeta) (ref *plumbing.Reference, err error) {
+	switch {
+	case meta.Tag != "":
+		ref, err = pcx.refFromTag(ctx, repo, meta)
+		return ref, errors.Wrap(err, "failed to get ref from tag")
+	case meta.Commit != "":
+		ref, err = versioning.RefFromCommit(repo, meta)
+		return ref, errors.Wrap(err, "failed to get ref from commit")
+	case meta.Branch != "":
+		ref, err = repo.Reference(plumbing.ReferenceName("refs/remotes/origin/"+meta.Branch), true)
+		return ref, errors.Wrapf(err, "no branch named '%s' found", meta.Branch)
+	}
+	ref, err = repo.Reference(plumbing.ReferenceName("refs/remotes/origin/HEAD"), true)
+	return ref, errors.Wrap(err, "failed to get default branch of sparse copy")
+}
+
+// sparseDir returns the directory of the repository a dependency needs, the directory of its package
+// in a monorepo or otherwise its include path, or nothing if it needs all of it
+func sparseDir(meta versioning.DependencyMeta) string {
+	if meta.Package != "" {
+		return meta.Package
+	}
+	return strings.Trim(filepath.ToSlash(meta.Path), "/")
+}
+
+// isSparse is true if a vendored dependency has a sparse checkout
+func isSparse(dir string) bool {
+	return util.Exists(filepath.Join(dir, ".git", "info", "sparse-checkout"))
+}
+
+// inSparseCone is true if a file is checked out by a cone mode sparse checkout of `dir`, which
+// includes the files at the root and in each parent of `dir` as well as everything under it
+func inSparseCone(name, dir string) bool {
+	dir = strings.Trim(filepath.ToSlash(dir), "/")
+	if strings.HasPrefix(name, dir+"/") {
+		return true
+	}
+	parent := path.Dir(name)
+	return parent == "." || strings.HasPrefix(dir+"/", parent+"/")
+}
+
+func runGit(ctx context.Context, binary, dir string, args ...string) (output string, err error) {
+	cmd := exec.CommandContext(ctx, binary, args...) //nolint:gas
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
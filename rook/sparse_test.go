@@ -0,0 +1,179 @@
+package rook
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_ensureSparse(t *testing.T) {
+	dir := util.FullPath("./tests/sparse")
+	os.RemoveAll(dir)
+	source := filepath.Join(dir, "source")
+	run := sourceRepo(t, source)
+	write := func(name, contents string) {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(source, name)), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(source, name), []byte(contents), 0644))
+	}
+
+	write("pawn.json", `{"user": "test", "repo": "monorepo"}`)
+	write("include/lib.inc", "// 1.0.0\n")
+	write("assets/large.bin", "large\n")
+	run("add", "-A")
+	run("commit", "--quiet", "-m", "Initial release")
+	first := run("rev-parse", "HEAD")
+	run("tag", "1.0.0")
+	write("include/lib.inc", "// 1.1.0\n")
+	run("commit", "--quiet", "-am", "Next release")
+	last := run("rev-parse", "HEAD")
+
+	meta := versioning.DependencyMeta{Site: "github.com", User: "test", Repo: "monorepo", Path: "include", Tag: "1.0.0"}
+	// the dependency is cloned from the source repository instead of GitHub
+	defer cloneFrom(map[string]string{meta.URL(): source})()
+
+	pcx := PackageContext{
+		Package:  types.Package{LocalPath: dir, Vendor: filepath.Join(dir, "dependencies")},
+		CacheDir: filepath.Join(dir, "cache"),
+	}
+	vendored := filepath.Join(pcx.Package.Vendor, meta.VendorName())
+
+	err := pcx.EnsurePackage(context.Background(), meta, false)
+	assert.NoError(t, err)
+	assert.True(t, isSparse(vendored))
+	assert.True(t, util.Exists(filepath.Join(vendored, "pawn.json")))
+	assert.True(t, util.Exists(filepath.Join(vendored, "include", "lib.inc")))
+	assert.False(t, util.Exists(filepath.Join(vendored, "assets")))
+	assert.False(t, util.Exists(meta.CachePath(pcx.CacheDir)), "sparse copies don't go through the cache")
+
+	// the files outside of the path were never downloaded
+	repo, err := git.PlainOpen(vendored)
+	assert.NoError(t, err)
+	head, err := repo.Head()
+	assert.NoError(t, err)
+	assert.Equal(t, first, head.Hash().String())
+	tree, err := headTree(repo)
+	assert.NoError(t, err)
+	entry, err := tree.FindEntry("assets/large.bin")
+	assert.NoError(t, err)
+	_, err = repo.BlobObject(entry.Hash)
+	assert.Equal(t, plumbing.ErrObjectNotFound, err)
+
+	// the files that are checked out verify against the commit
+	assert.True(t, pcx.verifyVendored(meta, first, nil).OK())
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(vendored, "include", "lib.inc"), []byte("edited"), 0644))
+	check := pcx.verifyVendored(meta, first, nil)
+	assert.Equal(t, []string{"include/lib.inc"}, check.Corrupted)
+	assert.Empty(t, check.Missing)
+	assert.NoError(t, checkoutLocked(context.Background(), vendored, first))
+	assert.True(t, pcx.verifyVendored(meta, first, nil).OK())
+
+	// and it's updated in place
+	meta.Tag = ""
+	meta.Commit = last
+	err = pcx.EnsurePackage(context.Background(), meta, false)
+	assert.NoError(t, err)
+	head, err = repo.Head()
+	assert.NoError(t, err)
+	assert.Equal(t, last, head.Hash().String())
+	contents, err := ioutil.ReadFile(filepath.Join(vendored, "include", "lib.inc"))
+	assert.NoError(t, err)
+	assert.Equal(t, "// 1.1.0\n", string(contents))
+	assert.False(t, util.Exists(filepath.Join(vendored, "assets")))
+}
+
+// sourceRepo creates a repository for dependencies to be cloned from and returns a function that runs
+// git commands in it, it skips the test if the git command isn't available
+func sourceRepo(t *testing.T, dir string) func(args ...string) string {
+	binary, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git command not available")
+	}
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+	run := func(args ...string) string {
+		cmd := exec.Command(binary, append([]string{"-c", "user.name=test", "-c", "user.email=test@test"}, args...)...)
+		cmd.Dir = dir
+		output, errRun := cmd.CombinedOutput()
+		if errRun != nil {
+			t.Fatal(errRun, string(output))
+		}
+		return strings.TrimSpace(string(output))
+	}
+	run("init", "--quiet")
+	run("config", "uploadpack.allowFilter", "true")
+	return run
+}
+
+// cloneFrom makes the git command clone the repositories at each URL from a local repository instead,
+// the returned function undoes it
+func cloneFrom(sources map[string]string) func() {
+	i := 0
+	for url, source := range sources {
+		os.Setenv(fmt.Sprintf("GIT_CONFIG_KEY_%d", i), "url.file://"+filepath.ToSlash(source)+".insteadOf") // nolint
+		os.Setenv(fmt.Sprintf("GIT_CONFIG_VALUE_%d", i), url)                                               // nolint
+		i++
+	}
+	os.Setenv("GIT_CONFIG_COUNT", fmt.Sprint(i)) // nolint
+	return func() {
+		for j := 0; j < i; j++ {
+			os.Unsetenv(fmt.Sprintf("GIT_CONFIG_KEY_%d", j))   // nolint
+			os.Unsetenv(fmt.Sprintf("GIT_CONFIG_VALUE_%d", j)) // nolint
+		}
+		os.Unsetenv("GIT_CONFIG_COUNT") // nolint
+	}
+}
+
+func TestPackageContext_ensureSparseFallback(t *testing.T) {
+	pcx := PackageContext{}
+	meta := versioning.DependencyMeta{Site: "github.com", User: "test", Repo: "monorepo", Path: "include"}
+
+	// without the git command the dependency is cloned in full
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", "") // nolint
+	sparse, err := pcx.ensureSparse(context.Background(), meta, util.FullPath("./tests/sparse/fallback"), false)
+	os.Setenv("PATH", path) // nolint
+	assert.NoError(t, err)
+	assert.False(t, sparse)
+
+	// as are dependencies without a path or package
+	meta.Path = ""
+	sparse, err = pcx.ensureSparse(context.Background(), meta, util.FullPath("./tests/sparse/fallback"), false)
+	assert.NoError(t, err)
+	assert.False(t, sparse)
+}
+
+func Test_sparseDir(t *testing.T) {
+	assert.Equal(t, "", sparseDir(versioning.DependencyMeta{}))
+	assert.Equal(t, "include", sparseDir(versioning.DependencyMeta{Path: "/include/"}))
+	assert.Equal(t, "packages/logger", sparseDir(versioning.DependencyMeta{Path: "include", Package: "packages/logger"}))
+}
+
+func Test_inSparseCone(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		want bool
+	}{
+		{"pawn.json", true},
+		{"packages/README.md", true},
+		{"packages/logger/include/logger.inc", true},
+		{"packages/logger/include/nested/a.inc", true},
+		{"packages/logger/test.pwn", true},
+		{"packages/logger/tests/test.pwn", false},
+		{"packages/other/other.inc", false},
+		{"assets/large.bin", false},
+	} {
+		assert.Equal(t, tt.want, inSparseCone(tt.name, "packages/logger/include/"), tt.name)
+	}
+}
@@ -0,0 +1,109 @@
+package rook
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+// StalePolicy describes what a build does when the vendored dependencies don't match the
+// dependencies the package declares, such as after adding one to the package definition
+type StalePolicy string
+
+const (
+	// StaleError refuses to build until the dependencies are ensured, this is the default
+	StaleError StalePolicy = "error"
+	// StaleEnsure ensures the dependencies before building
+	StaleEnsure StalePolicy = "ensure"
+	// StaleIgnore builds with the vendored dependencies as they are
+	StaleIgnore StalePolicy = "ignore"
+)
+
+// StalePolicies lists the valid policies
+var StalePolicies = []StalePolicy{StaleError, StaleEnsure, StaleIgnore}
+
+// checkStale decides whether dependencies must be ensured before a build. Unless they are being
+// ensured anyway, the vendored dependencies are compared with the package definition and if they
+// are stale, the policy of the package context decides what happens. A read-only vendor directory
+// is never ensured, so stale dependencies in it are an error unless they're ignored.
+func (pcx *PackageContext) checkStale(ensure bool) (bool, error) {
+	if ensure && !pcx.ReadOnlyVendor {
+		return true, nil
+	}
+
+	policy := pcx.Stale
+	if policy == "" {
+		policy = StaleError
+	}
+	if pcx.ReadOnlyVendor {
+		if ensure {
+			print.Verb(pcx.Package, "the vendor directory is read-only, not ensuring dependencies")
+		}
+		if policy == StaleEnsure {
+			policy = StaleError
+		}
+	}
+	switch policy {
+	case StaleError, StaleEnsure:
+	case StaleIgnore:
+		return false, nil
+	default:
+		return false, errors.Errorf("unknown stale policy %s, must be one of %v", policy, StalePolicies)
+	}
+
+	reasons, err := pcx.staleVendored()
+	if err != nil {
+		return false, err
+	}
+	if len(reasons) == 0 {
+		return false, nil
+	}
+
+	if policy == StaleEnsure {
+		print.Info(pcx.Package, "vendored dependencies are stale, ensuring them before build")
+		for _, reason := range reasons {
+			print.Verb(pcx.Package, reason)
+		}
+		return true, nil
+	}
+	if pcx.ReadOnlyVendor {
+		return false, errors.Errorf("the read-only vendor directory does not match the package definition:\n%s", strings.Join(reasons, "\n"))
+	}
+	return false, errors.Errorf("vendored dependencies do not match the package definition, run ensure first:\n%s", strings.Join(reasons, "\n"))
+}
+
+// staleVendored describes how the vendored dependencies differ from the dependencies the package
+// declares. With a lockfile, it must list exactly the declared dependencies and each one must be
+// vendored at its locked commit, without one each dependency must at least be vendored.
+func (pcx *PackageContext) staleVendored() (reasons []string, err error) {
+	lock, err := types.ReadLockfile(pcx.Package.LocalPath)
+	if err != nil {
+		return
+	}
+
+	if lock == nil {
+		for _, meta := range pcx.AllDependencies {
+			if !util.Exists(filepath.Join(pcx.Package.Vendor, meta.VendorName())) {
+				reasons = append(reasons, fmt.Sprintf("%s is not vendored", meta))
+			}
+		}
+		return
+	}
+
+	reasons = pcx.lockfileChanges(*lock)
+	for _, meta := range pcx.AllDependencies {
+		if _, ok := lock.Commit(meta); !ok {
+			continue
+		}
+		if !pcx.vendoredAtLock(meta, *lock) {
+			reasons = append(reasons, fmt.Sprintf("%s is not vendored at its locked commit", meta))
+		}
+	}
+	return
+}
@@ -0,0 +1,83 @@
+package rook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_checkStale(t *testing.T) {
+	dir := util.FullPath("./tests/stale")
+	os.RemoveAll(dir)
+
+	lib := versioning.DependencyMeta{Site: "github.com", User: "test", Repo: "lib"}
+	pcx := PackageContext{
+		Package:         types.Package{LocalPath: dir, Vendor: filepath.Join(dir, "dependencies")},
+		AllDependencies: []versioning.DependencyMeta{lib},
+	}
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+
+	// the dependency was declared but never ensured
+	_, err := pcx.checkStale(false)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "run ensure first")
+	assert.Contains(t, err.Error(), "github.com/test/lib is not vendored")
+
+	pcx.Stale = StaleEnsure
+	ensure, err := pcx.checkStale(false)
+	assert.NoError(t, err)
+	assert.True(t, ensure)
+
+	pcx.Stale = StaleIgnore
+	ensure, err = pcx.checkStale(false)
+	assert.NoError(t, err)
+	assert.False(t, ensure)
+
+	pcx.Stale = "sometimes"
+	_, err = pcx.checkStale(false)
+	assert.Error(t, err)
+
+	// ensuring anyway skips the check
+	ensure, err = pcx.checkStale(true)
+	assert.NoError(t, err)
+	assert.True(t, ensure)
+
+	// once vendored and locked, nothing is stale
+	pcx.Stale = ""
+	vendored := filepath.Join(pcx.Package.Vendor, "lib")
+	commitVersions(t, vendored, []string{"v1.0.0"})
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(vendored, ".git", cloneMarker), nil, 0600))
+	ensure, err = pcx.checkStale(false)
+	assert.NoError(t, err)
+	assert.False(t, ensure)
+
+	lock, err := pcx.ResolveLockfile()
+	assert.NoError(t, err)
+	assert.NoError(t, lock.Write(dir))
+	reasons, err := pcx.staleVendored()
+	assert.NoError(t, err)
+	assert.Empty(t, reasons)
+
+	// a dependency added to the package definition without ensuring
+	other := versioning.DependencyMeta{Site: "github.com", User: "test", Repo: "other"}
+	pcx.AllDependencies = append(pcx.AllDependencies, other)
+	reasons, err = pcx.staleVendored()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"added github.com/test/other"}, reasons)
+
+	// a vendored dependency that moved away from its locked commit
+	pcx.AllDependencies = []versioning.DependencyMeta{lib}
+	os.RemoveAll(vendored)
+	commitVersions(t, vendored, []string{"v1.0.0", "v1.1.0"})
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(vendored, ".git", cloneMarker), nil, 0600))
+	reasons, err = pcx.staleVendored()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"github.com/test/lib is not vendored at its locked commit"}, reasons)
+}
@@ -0,0 +1,215 @@
+package rook
+
+import (
+	"context"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// ResolutionStrategy decides which version a version constraint on a dependency resolves to when
+// dependencies are ensured, the strategy is recorded in the lockfile so it can be reproduced
+type ResolutionStrategy string
+
+const (
+	// StrategyNewest resolves a dependency to the newest version allowed by the constraint closest to
+	// the package, this is the default
+	StrategyNewest ResolutionStrategy = "newest"
+	// StrategyMinimal resolves a dependency to the lowest version that satisfies every constraint on
+	// it anywhere in the dependency tree
+	StrategyMinimal ResolutionStrategy = "minimal"
+	// StrategyUpstream resolves a dependency to the commit that the lockfiles shipped by the
+	// dependencies which depend on it lock it at, as long as that satisfies every constraint on it,
+	// and to the newest allowed version otherwise
+	StrategyUpstream ResolutionStrategy = "upstream"
+	// StrategyLocked doesn't resolve anything, dependencies are checked out at their locked commits
+	StrategyLocked ResolutionStrategy = "locked"
+)
+
+// ResolutionStrategies lists the valid strategies
+var ResolutionStrategies = []ResolutionStrategy{StrategyNewest, StrategyMinimal, StrategyUpstream, StrategyLocked}
+
+// resolutionStrategy picks the strategy for an ensure, the strategy of the package context if one
+// was set, otherwise the strategy the lockfile was resolved with
+func (pcx *PackageContext) resolutionStrategy(lock *types.Lockfile) (strategy ResolutionStrategy, err error) {
+	strategy = pcx.Strategy
+	if strategy == "" && lock != nil {
+		strategy = ResolutionStrategy(lock.ResolutionStrategy())
+	}
+	if strategy == "" {
+		strategy = StrategyNewest
+	}
+	switch strategy {
+	case StrategyNewest, StrategyMinimal, StrategyUpstream, StrategyLocked:
+	default:
+		return "", errors.Errorf("unknown resolution strategy %s, must be one of %v", strategy, ResolutionStrategies)
+	}
+	return
+}
+
+// lockedStrategy is the strategy to record in a lockfile resolved with a strategy, the newest
+// strategy is the default so it isn't recorded and a locked ensure keeps whatever was recorded
+func lockedStrategy(strategy ResolutionStrategy, lock *types.Lockfile) string {
+	switch strategy {
+	case StrategyMinimal, StrategyUpstream:
+		return string(strategy)
+	case StrategyLocked:
+		if lock != nil {
+			return lock.Strategy
+		}
+	}
+	return ""
+}
+
+// addConstraint records a tag constraint on a dependency found while walking the dependency tree
+func (pcx *PackageContext) addConstraint(meta versioning.DependencyMeta) {
+	if meta.Tag == "" {
+		return
+	}
+	if pcx.Constraints == nil {
+		pcx.Constraints = make(map[string][]string)
+	}
+	key := constraintKey(meta)
+	for _, tag := range pcx.Constraints[key] {
+		if tag == meta.Tag {
+			return
+		}
+	}
+	pcx.Constraints[key] = append(pcx.Constraints[key], meta.Tag)
+}
+
+// tagConstraints lists every tag constraint on a dependency, starting with its own
+func (pcx *PackageContext) tagConstraints(meta versioning.DependencyMeta) (constraints []string) {
+	constraints = []string{meta.Tag}
+	for _, tag := range pcx.Constraints[constraintKey(meta)] {
+		if tag != meta.Tag {
+			constraints = append(constraints, tag)
+		}
+	}
+	return
+}
+
+func constraintKey(meta versioning.DependencyMeta) string {
+	return dependencyKey(meta)
+}
+
+// refFromTag resolves the tag constraint of a dependency from its version source using the
+// resolution strategy. Constraints that aren't semantic versions name a single tag, so they are
+// resolved the same by every strategy. Decisions are shared through the cache directory for the
+// resolution TTL. Constraints that conflict are an error for the minimal strategy and a warning for
+// the newest strategy, unless there's a way to ask how to settle them.
+func (pcx *PackageContext) refFromTag(ctx context.Context, repo *git.Repository, meta versioning.DependencyMeta) (ref *plumbing.Reference, err error) {
+	ref, conflict, err := pcx.resolveTag(ctx, repo, meta)
+	if err != nil || conflict == nil {
+		return
+	}
+	if pcx.ResolveConflict == nil {
+		if ref == nil {
+			return nil, *conflict
+		}
+		print.Warn(conflict)
+		return
+	}
+
+	retry, err := pcx.settleConflict(*conflict)
+	if err != nil {
+		return nil, err
+	}
+	if !retry {
+		if ref == nil {
+			return nil, errors.Errorf("%s can't be resolved until it's ensured again", meta)
+		}
+		return
+	}
+	meta.Tag = pcx.overrideOf(meta)
+	if pcx.Constraints == nil {
+		pcx.Constraints = make(map[string][]string)
+	}
+	pcx.Constraints[constraintKey(meta)] = []string{meta.Tag}
+	return pcx.refFromTag(ctx, repo, meta)
+}
+
+// resolveTag resolves a tag constraint, along with a description of the conflict if the
+// constraints on the dependency can't all be satisfied
+func (pcx *PackageContext) resolveTag(ctx context.Context, repo *git.Repository, meta versioning.DependencyMeta) (ref *plumbing.Reference, conflict *ConstraintConflict, err error) {
+	source, err := pcx.versionSourceOf(meta)
+	if err != nil {
+		return
+	}
+	constraints := pcx.tagConstraints(meta)
+	minimal := pcx.Strategy == StrategyMinimal && semverConstraints(constraints)
+
+	key := resolvedConstraintKey(meta, source, minimal, constraints)
+	if cached, ok := pcx.cachedConstraint(repo, key); ok {
+		ref = cached
+	} else {
+		if source == VersionSourceReleases {
+			ref, err = pcx.refFromRelease(ctx, repo, meta, constraints)
+		} else if minimal {
+			ref, err = versioning.MinimalRefFromTag(repo, constraints)
+		} else {
+			ref, err = versioning.RefFromTag(repo, meta)
+		}
+		if err != nil {
+			if minimal {
+				described := pcx.constraintConflict(ctx, repo, meta, source, constraints)
+				if len(described.Versions) > 0 && !anySatisfiesAll(described.Versions, constraints) {
+					return nil, &described, nil
+				}
+			}
+			return
+		}
+		pcx.cacheConstraint(key, ref)
+	}
+	if minimal {
+		return
+	}
+
+	// the newest strategy lets the constraint closest to the package win, others are only checked
+	version, errVersion := semver.NewVersion(ref.Name().Short())
+	if errVersion != nil {
+		return
+	}
+	if !satisfiesAll(version, semverOnly(constraints[1:])) {
+		described := pcx.constraintConflict(ctx, repo, meta, source, constraints)
+		described.Resolved = ref.Name().Short()
+		conflict = &described
+	}
+	return
+}
+
+// anySatisfiesAll checks whether any of the versions satisfies every constraint
+func anySatisfiesAll(versions []string, constraints []string) bool {
+	for _, v := range versions {
+		version, err := semver.NewVersion(v)
+		if err == nil && satisfiesAll(version, constraints) {
+			return true
+		}
+	}
+	return false
+}
+
+// semverOnly drops the constraints that aren't semantic version constraints
+func semverOnly(constraints []string) (result []string) {
+	for _, constraint := range constraints {
+		if _, err := semver.NewConstraint(constraint); err == nil {
+			result = append(result, constraint)
+		}
+	}
+	return
+}
+
+func semverConstraints(constraints []string) bool {
+	for _, constraint := range constraints {
+		if _, err := semver.NewConstraint(constraint); err != nil {
+			return false
+		}
+	}
+	return true
+}
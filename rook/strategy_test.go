@@ -0,0 +1,81 @@
+package rook
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_refFromTag(t *testing.T) {
+	dir := util.FullPath("./tests/strategy")
+	os.RemoveAll(dir)
+	commitVersions(t, dir, []string{"1.0.0", "1.1.0", "1.2.0", "2.0.0"})
+	repo, err := git.PlainOpen(dir)
+	assert.NoError(t, err)
+
+	meta := versioning.DependencyMeta{User: "test", Repo: "lib", Tag: "^1.0.0"}
+
+	for _, tt := range []struct {
+		strategy    ResolutionStrategy
+		constraints []string
+		want        string
+		wantErr     bool
+	}{
+		{StrategyNewest, []string{"^1.0.0", ">=1.1.0"}, "1.2.0", false},
+		{StrategyMinimal, []string{"^1.0.0", ">=1.1.0"}, "1.1.0", false},
+		{StrategyMinimal, []string{"^1.0.0"}, "1.0.0", false},
+		// the newest strategy lets the first constraint win, minimal can't satisfy both
+		{StrategyNewest, []string{"^1.0.0", ">=2.0.0"}, "1.2.0", false},
+		{StrategyMinimal, []string{"^1.0.0", ">=2.0.0"}, "", true},
+	} {
+		pcx := PackageContext{Strategy: tt.strategy}
+		for _, constraint := range tt.constraints {
+			pcx.addConstraint(versioning.DependencyMeta{User: "Test", Repo: "lib", Tag: constraint})
+		}
+
+		ref, err := pcx.refFromTag(context.Background(), repo, meta)
+		if tt.wantErr {
+			assert.Error(t, err, tt.strategy)
+			continue
+		}
+		assert.NoError(t, err, tt.strategy)
+		assert.Equal(t, plumbing.ReferenceName("refs/tags/"+tt.want), ref.Name(), tt.strategy)
+	}
+}
+
+func TestPackageContext_resolutionStrategy(t *testing.T) {
+	minimal := &types.Lockfile{Strategy: "minimal"}
+
+	for _, tt := range []struct {
+		strategy ResolutionStrategy
+		lock     *types.Lockfile
+		want     ResolutionStrategy
+		recorded string
+		wantErr  bool
+	}{
+		{"", nil, StrategyNewest, "", false},
+		{"", minimal, StrategyMinimal, "minimal", false},
+		{StrategyNewest, minimal, StrategyNewest, "", false},
+		{StrategyUpstream, minimal, StrategyUpstream, "upstream", false},
+		{StrategyLocked, minimal, StrategyLocked, "minimal", false},
+		{"oldest", nil, "", "", true},
+	} {
+		pcx := PackageContext{Strategy: tt.strategy}
+		got, err := pcx.resolutionStrategy(tt.lock)
+		if tt.wantErr {
+			assert.EqualError(t, err, "unknown resolution strategy oldest, must be one of [newest minimal upstream locked]")
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.Equal(t, tt.recorded, lockedStrategy(got, tt.lock))
+	}
+}
@@ -0,0 +1,223 @@
+package rook
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/runtime"
+	"github.com/Southclaws/sampctl/types"
+)
+
+// TestBackend is the convention a test script follows to report its results in the server output
+type TestBackend string
+
+const (
+	// TestBackendMarkers reads a line for each test, `[TEST] PASS name` or `[TEST] FAIL name: reason`,
+	// and `[TEST] DONE` once every test has run, this is the default
+	TestBackendMarkers TestBackend = "markers"
+	// TestBackendYTesting reads the output of YSI's y_testing, `*** Test Start: name` before each test,
+	// `*** Test Failure: reason` for a failed assertion and `*** Tests: 3, Fails: 1` once every test
+	// has run
+	TestBackendYTesting TestBackend = "y_testing"
+)
+
+// TestBackends lists the valid test backends
+var TestBackends = []TestBackend{TestBackendMarkers, TestBackendYTesting}
+
+// DefaultTestTimeout is how long a test run may take to report that every test has run
+const DefaultTestTimeout = time.Minute
+
+var (
+	// [TEST] FAIL Test_Parse: expected 3, got 4
+	matchTestMarker = regexp.MustCompile(`\[TEST\] (PASS|FAIL) ([^\s:]+)(?::\s*(.*))?$`)
+	matchTestDone   = regexp.MustCompile(`\[TEST\] DONE`)
+	// *** Test Start: Test_Parse
+	matchYTestingStart   = regexp.MustCompile(`\*\*\* Test Start: (\S+)`)
+	matchYTestingFailure = regexp.MustCompile(`\*\*\* Test Fail(?:ure|ed):\s*(.*)$`)
+	matchYTestingEnd     = regexp.MustCompile(`\*\*\* Tests: (\d+), Fails: (\d+)`)
+)
+
+// TestOptions configures a test run
+type TestOptions struct {
+	Build   string        // the build config that compiles the test entry, the default build if empty
+	Runtime string        // the runtime config the tests run with, `default` if empty
+	Backend TestBackend   // how results are read from the server output, `markers` if empty
+	Timeout time.Duration // how long the run may take, DefaultTestTimeout if zero
+	Output  io.Writer     // where the server output is copied to, discarded if nil
+}
+
+// TestCase is the result of a single test
+type TestCase struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// TestResult is the outcome of a test run. Finished is only set once the test script reported that
+// every test has run, a run that crashed or timed out first didn't pass even if no test failed.
+type TestResult struct {
+	Backend  TestBackend   `json:"backend"`
+	Cases    []TestCase    `json:"cases"`
+	Tests    int           `json:"tests"`
+	Failures int           `json:"failures"`
+	Finished bool          `json:"finished"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Passed is true when every test ran and none of them failed
+func (tr TestResult) Passed() bool {
+	return tr.Finished && tr.Failures == 0
+}
+
+// Test builds the package, runs its output under the runtime in the `headless` mode until the test
+// script reports that every test has run and returns the result of each test read from the server
+// output by the backend. The build is always compiled first so the results are never from a stale
+// output. An error is only returned when the tests couldn't run or didn't finish, failed tests are
+// reported in the result.
+func (pcx *PackageContext) Test(ctx context.Context, opts TestOptions) (result TestResult, err error) {
+	if opts.Backend == "" {
+		opts.Backend = TestBackendMarkers
+	}
+	switch opts.Backend {
+	case TestBackendMarkers, TestBackendYTesting:
+	default:
+		return result, errors.Errorf("unknown test backend %s, must be one of %v", opts.Backend, TestBackends)
+	}
+	if opts.Runtime == "" {
+		opts.Runtime = "default"
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTestTimeout
+	}
+
+	pcx.BuildName = opts.Build
+	pcx.Runtime = opts.Runtime
+	pcx.ForceBuild = true
+	err = pcx.runPrepare(ctx)
+	if err != nil {
+		return result, errors.Wrap(err, "failed to prepare package for testing")
+	}
+
+	cfg := *pcx.Package.Runtime
+	cfg.Mode = types.Headless
+	cfg.Test = &types.HeadlessTest{
+		Success:  []string{testEndPattern(opts.Backend)},
+		Duration: opts.Timeout.String(),
+	}
+
+	result.Backend = opts.Backend
+	output := &testOutput{backend: opts.Backend, result: &result, output: opts.Output}
+	started := time.Now()
+	errRun := runtime.Run(ctx, cfg, pcx.CacheDir, true, false, output, bytes.NewReader(nil))
+	result.Duration = time.Since(started)
+	output.finish()
+
+	if !result.Finished {
+		if errRun == nil {
+			errRun = errors.New("the server stopped before every test ran")
+		}
+		return result, errors.Wrap(errRun, "test run did not finish")
+	}
+	print.Verb(pcx.Package, "ran", result.Tests, "tests with", result.Failures, "failures in", result.Duration)
+	return
+}
+
+// testEndPattern is the line a backend reports once every test has run, which ends the run
+func testEndPattern(backend TestBackend) string {
+	if backend == TestBackendYTesting {
+		return matchYTestingEnd.String()
+	}
+	return matchTestDone.String()
+}
+
+// testOutput reads test results from the server output a line at a time as it's written
+type testOutput struct {
+	backend TestBackend
+	result  *TestResult
+	output  io.Writer
+	partial []byte
+	current *TestCase // the y_testing test that is running
+}
+
+func (to *testOutput) Write(p []byte) (n int, err error) {
+	if to.output != nil {
+		_, err = to.output.Write(p)
+		if err != nil {
+			return
+		}
+	}
+	to.partial = append(to.partial, p...)
+	for {
+		i := bytes.IndexByte(to.partial, '\n')
+		if i == -1 {
+			break
+		}
+		to.line(strings.TrimRight(string(to.partial[:i]), "\r"))
+		to.partial = to.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+func (to *testOutput) line(line string) {
+	if to.result.Finished {
+		return
+	}
+	switch to.backend {
+	case TestBackendYTesting:
+		if captures := matchYTestingStart.FindStringSubmatch(line); captures != nil {
+			to.endCase()
+			to.current = &TestCase{Name: captures[1], Passed: true}
+		} else if captures := matchYTestingFailure.FindStringSubmatch(line); captures != nil && to.current != nil {
+			to.current.Passed = false
+			if to.current.Message == "" {
+				to.current.Message = captures[1]
+			}
+		} else if captures := matchYTestingEnd.FindStringSubmatch(line); captures != nil {
+			to.endCase()
+			to.result.Tests, _ = strconv.Atoi(captures[1])
+			to.result.Failures, _ = strconv.Atoi(captures[2])
+			to.result.Finished = true
+		}
+	default:
+		if captures := matchTestMarker.FindStringSubmatch(line); captures != nil {
+			to.result.Cases = append(to.result.Cases, TestCase{Name: captures[2], Passed: captures[1] == "PASS", Message: captures[3]})
+		} else if matchTestDone.MatchString(line) {
+			to.result.Finished = true
+		}
+	}
+}
+
+// endCase records the y_testing test that was running, it passed unless an assertion failed
+func (to *testOutput) endCase() {
+	if to.current != nil {
+		to.result.Cases = append(to.result.Cases, *to.current)
+		to.current = nil
+	}
+}
+
+// finish reads any unterminated last line and counts the results the backend doesn't summarise
+func (to *testOutput) finish() {
+	if len(to.partial) > 0 {
+		to.line(strings.TrimRight(string(to.partial), "\r"))
+		to.partial = nil
+	}
+	to.endCase()
+	if to.backend == TestBackendYTesting && to.result.Finished {
+		return
+	}
+	to.result.Tests = len(to.result.Cases)
+	to.result.Failures = 0
+	for _, tc := range to.result.Cases {
+		if !tc.Passed {
+			to.result.Failures++
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package rook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestOutput(t *testing.T) {
+	for _, tt := range []struct {
+		backend TestBackend
+		output  string
+		want    TestResult
+	}{
+		{TestBackendMarkers, `Loaded 0 filterscripts.
+[12:00:00] [TEST] PASS Test_Parse
+[12:00:00] [TEST] FAIL Test_Format: expected "1.0", got "1"
+[TEST] PASS Test_Empty
+[TEST] DONE
+[TEST] FAIL Test_After
+`, TestResult{
+			Cases: []TestCase{
+				{Name: "Test_Parse", Passed: true},
+				{Name: "Test_Format", Message: `expected "1.0", got "1"`},
+				{Name: "Test_Empty", Passed: true},
+			},
+			Tests: 3, Failures: 1, Finished: true,
+		}},
+		{TestBackendMarkers, "[TEST] PASS Test_Parse\r\n[TEST] PASS Test_Crash", TestResult{
+			Cases: []TestCase{{Name: "Test_Parse", Passed: true}, {Name: "Test_Crash", Passed: true}},
+			Tests: 2,
+		}},
+		{TestBackendYTesting, `*** Test Start: y_test_One
+*** Test Start: y_test_Two
+*** Test Failure: ASSERT(a == b) failed
+*** Test Failure: ASSERT(b == c) failed
+*** Test Start: y_test_Three
+*** Tests: 3, Fails: 1
+`, TestResult{
+			Cases: []TestCase{
+				{Name: "y_test_One", Passed: true},
+				{Name: "y_test_Two", Message: "ASSERT(a == b) failed"},
+				{Name: "y_test_Three", Passed: true},
+			},
+			Tests: 3, Failures: 1, Finished: true,
+		}},
+	} {
+		var result TestResult
+		copied := &bytes.Buffer{}
+		output := &testOutput{backend: tt.backend, result: &result, output: copied}
+		// write in uneven chunks, lines can be split across writes
+		for i := 0; i < len(tt.output); i += 7 {
+			end := i + 7
+			if end > len(tt.output) {
+				end = len(tt.output)
+			}
+			fmt.Fprint(output, tt.output[i:end])
+		}
+		output.finish()
+
+		assert.Equal(t, tt.output, copied.String())
+		assert.Equal(t, tt.want, result, tt.backend)
+		assert.Equal(t, tt.want.Finished && tt.want.Failures == 0, result.Passed())
+	}
+}
+
+func TestPackageContext_TestBackend(t *testing.T) {
+	pcx := PackageContext{}
+	_, err := pcx.Test(context.Background(), TestOptions{Backend: "gtest"})
+	assert.EqualError(t, err, "unknown test backend gtest, must be one of [markers y_testing]")
+}
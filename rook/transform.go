@@ -0,0 +1,198 @@
+package rook
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/runtime"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// transformMarker is written to the git directory of a vendored dependency and lists the files that
+// were transformed, so they can be restored before the dependency is updated or transformed again.
+const transformMarker = "sampctl-transformed"
+
+// transformsOf returns the transforms the package declares for a dependency, if any
+func (pcx *PackageContext) transformsOf(meta versioning.DependencyMeta) []types.Transform {
+	for dependency, transforms := range pcx.Package.Transforms {
+		if strings.EqualFold(dependency, meta.User+"/"+meta.Repo) {
+			return transforms
+		}
+	}
+	return nil
+}
+
+// applyTransforms rewrites the files of a vendored dependency with the transforms the package
+// declares for it. Files that were transformed before are restored first and transforms always
+// start from the committed contents, so applying them on every ensure gives the same result. The
+// checksums of the transformed files are kept for the lockfile.
+func (pcx *PackageContext) applyTransforms(meta versioning.DependencyMeta, dir string) (err error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return errors.Wrap(err, "failed to open vendored repository")
+	}
+	err = restoreTransformed(repo, dir)
+	if err != nil {
+		return
+	}
+
+	transforms := pcx.transformsOf(meta)
+	if len(transforms) == 0 {
+		return
+	}
+
+	type compiled struct {
+		files   *regexp.Regexp
+		find    *regexp.Regexp
+		replace string
+		changed bool
+	}
+	var all []*compiled
+	for _, transform := range transforms {
+		files := transform.Files
+		if files == "" {
+			files = "**/*.inc"
+		}
+		find, errCompile := regexp.Compile(transform.Find)
+		if errCompile != nil {
+			return errors.Wrapf(errCompile, "transform of %s has an invalid expression", meta)
+		}
+		all = append(all, &compiled{runtime.GlobRegexp(files), find, transform.Replace, false})
+	}
+
+	tree, err := headTree(repo)
+	if err != nil {
+		return
+	}
+
+	checksums := make(map[string]string)
+	err = walkFiles(tree, func(name string, entry object.TreeEntry) error {
+		var matching []*compiled
+		for _, transform := range all {
+			if transform.files.MatchString(name) {
+				matching = append(matching, transform)
+			}
+		}
+		// files excluded by a sparse checkout are left out, their contents aren't downloaded
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		info, errStat := os.Stat(path)
+		if len(matching) == 0 || errStat != nil {
+			return nil
+		}
+
+		file, errFile := tree.TreeEntryFile(&entry)
+		if errFile != nil {
+			return errFile
+		}
+		original, errContents := file.Contents()
+		if errContents != nil {
+			return errContents
+		}
+		contents := original
+		for _, transform := range matching {
+			replaced := transform.find.ReplaceAllString(contents, transform.replace)
+			if replaced != contents {
+				transform.changed = true
+				contents = replaced
+			}
+		}
+		if contents == original {
+			return nil
+		}
+
+		print.Verb(meta, "transformed", name)
+		sum := sha256.Sum256([]byte(contents))
+		checksums[name] = hex.EncodeToString(sum[:])
+		return ioutil.WriteFile(path, []byte(contents), info.Mode().Perm())
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to transform files of %s", meta)
+	}
+
+	for i, transform := range all {
+		if !transform.changed {
+			print.Warn(meta, "transform", transforms[i].Find, "did not change any files")
+		}
+	}
+
+	var names []string
+	for name := range checksums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	err = ioutil.WriteFile(filepath.Join(dir, ".git", transformMarker), []byte(strings.Join(names, "\n")), 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to record transformed files")
+	}
+
+	if pcx.transformed == nil {
+		pcx.transformed = make(map[string]map[string]string)
+	}
+	pcx.transformed[constraintKey(meta)] = checksums
+	return
+}
+
+// restoreTransformed writes the committed contents back to the files of a vendored dependency that
+// were transformed, otherwise they would get in the way of pulling updates
+func restoreTransformed(repo *git.Repository, dir string) (err error) {
+	marker := filepath.Join(dir, ".git", transformMarker)
+	contents, err := ioutil.ReadFile(marker)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+
+	tree, err := headTree(repo)
+	if err != nil {
+		return
+	}
+	for _, name := range strings.Split(string(contents), "\n") {
+		if name == "" {
+			continue
+		}
+		file, errFile := tree.File(name)
+		if errFile != nil {
+			continue
+		}
+		original, errContents := file.Contents()
+		if errContents != nil {
+			return errors.Wrapf(errContents, "failed to read committed %s", name)
+		}
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		info, errStat := os.Stat(path)
+		if errStat != nil {
+			continue
+		}
+		err = ioutil.WriteFile(path, []byte(original), info.Mode().Perm())
+		if err != nil {
+			return errors.Wrapf(err, "failed to restore %s", name)
+		}
+	}
+	return os.Remove(marker)
+}
+
+func headTree(repo *git.Repository) (tree *object.Tree, err error) {
+	head, err := repo.Head()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get repository HEAD")
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get HEAD commit")
+	}
+	return commit.Tree()
+}
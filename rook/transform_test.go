@@ -0,0 +1,62 @@
+package rook
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_applyTransforms(t *testing.T) {
+	vendor := util.FullPath("./tests/transform")
+	os.RemoveAll(vendor)
+	dir := filepath.Join(vendor, "lib")
+	commitVersions(t, dir, []string{"1.0.0"})
+
+	meta := versioning.DependencyMeta{User: "test", Repo: "lib", Tag: "1.0.0"}
+	pcx := PackageContext{
+		Package: types.Package{
+			Vendor: vendor,
+			Transforms: map[string][]types.Transform{
+				"Test/Lib": {
+					{Find: `// (\d+)\.0\.0`, Replace: "// v$1"},
+					{Find: `v1`, Replace: "v1 patched"},
+					{Files: "*.txt", Find: "anything", Replace: ""},
+				},
+			},
+		},
+		AllDependencies: []versioning.DependencyMeta{meta},
+	}
+
+	// applying the transforms again starts from the committed contents so the result is the same
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, pcx.applyTransforms(meta, dir))
+		contents, err := ioutil.ReadFile(filepath.Join(dir, "lib.inc"))
+		assert.NoError(t, err)
+		assert.Equal(t, "// v1 patched", string(contents))
+	}
+
+	sum := sha256.Sum256([]byte("// v1 patched"))
+	lock, err := pcx.ResolveLockfile()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"lib.inc": hex.EncodeToString(sum[:])}, lock.Dependencies[0].Transforms)
+
+	// removing the transforms restores the committed file
+	pcx.Package.Transforms = nil
+	assert.NoError(t, pcx.applyTransforms(meta, dir))
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "lib.inc"))
+	assert.NoError(t, err)
+	assert.Equal(t, "// 1.0.0", string(contents))
+	assert.False(t, util.Exists(filepath.Join(dir, ".git", transformMarker)))
+
+	pcx.Package.Transforms = map[string][]types.Transform{"test/lib": {{Find: "("}}}
+	assert.Error(t, pcx.applyTransforms(meta, dir))
+}
@@ -0,0 +1,158 @@
+package rook
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/compiler"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// UnusedDependency is a declared dependency that no build of the package includes a file from
+type UnusedDependency struct {
+	Dependency versioning.DependencyString
+	// Provides lists dependencies of this one that are included, removing it would remove them too
+	// so they should be declared directly first
+	Provides []versioning.DependencyMeta
+}
+
+// UnusedDependencies follows every `#include` from the entry script of each build config for the
+// platform and returns the declared dependencies that none of them reach, the same way the include
+// set of `AffectedBuilds` is found. Dependencies must be vendored. Development dependencies, those
+// used on another platform and those that declare resources, which may provide plugins or a
+// compiler rather than includes, are never reported.
+func (pcx *PackageContext) UnusedDependencies(ctx context.Context) (unused []UnusedDependency, err error) {
+	files := make(map[string]bool)
+	for _, name := range pcx.buildNames() {
+		config, errPrepare := pcx.buildPrepare(ctx, name, false, false)
+		if errPrepare != nil {
+			return nil, errors.Wrapf(errPrepare, "failed to prepare build %s", name)
+		}
+		includes := make([]string, len(config.Includes))
+		for i, inc := range config.Includes {
+			if !filepath.IsAbs(inc) {
+				inc = filepath.Join(pcx.Package.LocalPath, inc)
+			}
+			includes[i] = inc
+		}
+
+		entries := []string{config.Input}
+		for _, include := range config.ForceIncludes {
+			if file := compiler.ForceIncludeFile(pcx.Package.LocalPath, include); file != "" {
+				entries = append(entries, file)
+			} else if file, ok := resolveInclude(includes, include, pcx.Package.LocalPath, false); ok {
+				entries = append(entries, file)
+			}
+		}
+		for _, entry := range entries {
+			found, errIncludes := includeSet(entry, includes)
+			if errIncludes != nil {
+				return nil, errors.Wrapf(errIncludes, "failed to find includes of build %s", name)
+			}
+			for file := range found {
+				files[file] = true
+			}
+		}
+	}
+
+	for _, depString := range pcx.Package.Dependencies {
+		meta, errInner := pcx.explode(depString)
+		if errInner != nil {
+			print.Verb(pcx.Package, "invalid dependency string:", depString, errInner)
+			continue
+		}
+		meta, ok := pcx.resolvedDependency(meta)
+		if !ok {
+			print.Verb(meta, "is not used on", pcx.Platform)
+			continue
+		}
+		depDir := filepath.Join(pcx.Package.Vendor, meta.VendorName())
+		if !util.Exists(depDir) {
+			print.Warn(meta, "is not vendored, run `sampctl package ensure` to check whether it's used")
+			continue
+		}
+		if pkg, errPkg := types.PackageFromDir(meta.PackageDir(depDir)); errPkg == nil && len(pkg.Resources) > 0 {
+			print.Verb(meta, "declares resources, it may be used for more than its includes")
+			continue
+		}
+		if pcx.includesFrom(files, meta) {
+			continue
+		}
+
+		dependency := UnusedDependency{Dependency: depString}
+		for _, inner := range pcx.vendoredDependencies(meta) {
+			if pcx.includesFrom(files, inner) {
+				dependency.Provides = append(dependency.Provides, inner)
+			}
+		}
+		unused = append(unused, dependency)
+	}
+	return
+}
+
+// resolvedDependency finds a declared dependency in the dependencies resolved for the platform,
+// which carry their alias and namespace
+func (pcx *PackageContext) resolvedDependency(meta versioning.DependencyMeta) (versioning.DependencyMeta, bool) {
+	for _, resolved := range pcx.AllDependencies {
+		if sameDependency(resolved, meta) {
+			return resolved, true
+		}
+	}
+	return meta, false
+}
+
+// includesFrom is true if any of the files belongs to a dependency, either in its vendor directory
+// or through the directory that presents it under its namespace or alias
+func (pcx *PackageContext) includesFrom(files map[string]bool, meta versioning.DependencyMeta) bool {
+	dirs := []string{filepath.Join(pcx.Package.Vendor, meta.VendorName())}
+	if meta.Namespace != "" {
+		dirs = append(dirs, filepath.Join(pcx.presentRoot(".namespaces"), meta.Namespace, meta.Repo))
+	}
+	if meta.Alias != "" {
+		dirs = append(dirs, filepath.Join(pcx.presentRoot(".aliases"), meta.Alias))
+	}
+	for file := range files {
+		for _, dir := range dirs {
+			if strings.HasPrefix(file, dir+string(os.PathSeparator)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// vendoredDependencies lists the dependencies of a vendored dependency, direct or not, as resolved
+func (pcx *PackageContext) vendoredDependencies(meta versioning.DependencyMeta) (deps []versioning.DependencyMeta) {
+	seen := map[string]bool{dependencyKey(meta): true}
+	queue := []versioning.DependencyMeta{meta}
+	for len(queue) > 0 {
+		pkg, err := types.PackageFromDir(queue[0].PackageDir(filepath.Join(pcx.Package.Vendor, queue[0].VendorName())))
+		queue = queue[1:]
+		if err != nil {
+			continue
+		}
+		for _, depString := range pkg.Dependencies {
+			inner, errInner := pcx.explode(depString)
+			if errInner != nil {
+				continue
+			}
+			key := dependencyKey(inner)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if inner, ok := pcx.resolvedDependency(inner); ok {
+				deps = append(deps, inner)
+				queue = append(queue, inner)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,64 @@
+package rook
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_UnusedDependencies(t *testing.T) {
+	dir := util.FullPath("./tests/unused")
+	os.RemoveAll(dir)
+	for name, contents := range map[string]string{
+		"main.pwn":                         "#include <used>\n#include <inner>\n",
+		"dependencies/used/used.inc":       "// used\n",
+		"dependencies/unused/unused.inc":   "// unused\n",
+		"dependencies/wrapper/wrapper.inc": "#include <inner>\n",
+		"dependencies/wrapper/pawn.json":   `{"user": "test", "repo": "wrapper", "dependencies": ["test/inner"]}`,
+		"dependencies/inner/inner.inc":     "// inner\n",
+		"dependencies/plugin/plugin.inc":   "// plugin\n",
+		"dependencies/plugin/pawn.json":    `{"user": "test", "repo": "plugin", "resources": [{"name": "plugin.zip", "platform": "linux"}]}`,
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(contents), 0600))
+	}
+
+	var all []versioning.DependencyMeta
+	for _, repo := range []string{"used", "unused", "wrapper", "inner", "plugin"} {
+		all = append(all, versioning.DependencyMeta{Site: "github.com", User: "test", Repo: repo})
+	}
+	pcx := PackageContext{
+		Package: types.Package{
+			LocalPath: dir,
+			Vendor:    filepath.Join(dir, "dependencies"),
+			Entry:     "main.pwn",
+			Output:    "main.amx",
+			Dependencies: []versioning.DependencyString{
+				"test/used",
+				"test/unused:1.0.0",
+				"test/wrapper",
+				"test/plugin",
+				"test/windows",
+			},
+			Development: []versioning.DependencyString{"test/inner"},
+		},
+		AllDependencies: all,
+		Platform:        "linux",
+	}
+
+	unused, err := pcx.UnusedDependencies(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []UnusedDependency{
+		{Dependency: "test/unused:1.0.0"},
+		{Dependency: "test/wrapper", Provides: []versioning.DependencyMeta{all[3]}},
+	}, unused)
+}
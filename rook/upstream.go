@@ -0,0 +1,133 @@
+package rook
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// upstreamLock is the commit that the lockfile a dependency ships locks another dependency at
+type upstreamLock struct {
+	Requester versioning.DependencyMeta
+	Commit    string
+}
+
+func (ul upstreamLock) String() string {
+	return fmt.Sprintf("%s locks it at %s", ul.Requester, shortCommit(ul.Commit, ""))
+}
+
+// recordUpstreamLocks reads the lockfile that a vendored dependency ships, if it has one, so the
+// dependencies it locks can be resolved to the same commits by the upstream strategy
+func (pcx *PackageContext) recordUpstreamLocks(meta versioning.DependencyMeta) {
+	lock, err := types.ReadLockfile(meta.PackageDir(filepath.Join(pcx.Package.Vendor, meta.VendorName())))
+	if err != nil {
+		print.Verb(meta, "has an unreadable", types.LockfileName+":", err)
+		return
+	}
+	if lock == nil {
+		return
+	}
+	if pcx.upstream == nil {
+		pcx.upstream = make(map[string][]upstreamLock)
+	}
+	for _, locked := range lock.Dependencies {
+		dependency, errInner := pcx.explode(locked.Dependency)
+		if errInner != nil || locked.Commit == "" {
+			continue
+		}
+		key := constraintKey(dependency)
+		pcx.upstream[key] = append(pcx.upstream[key], upstreamLock{Requester: meta, Commit: locked.Commit})
+	}
+}
+
+// pinToUpstream pins a dependency to the commit that the lockfiles of the dependencies ensured
+// before it lock it at, as long as they agree and the commit satisfies every constraint on the
+// dependency. Otherwise the dependency is returned as it is, to be resolved like the newest
+// strategy does, and the reason is reported. A dependency declared at a branch or commit is never
+// pinned.
+func (pcx *PackageContext) pinToUpstream(ctx context.Context, meta versioning.DependencyMeta) versioning.DependencyMeta {
+	locks := pcx.upstream[constraintKey(meta)]
+	if len(locks) == 0 || meta.Branch != "" || meta.Commit != "" {
+		return meta
+	}
+
+	for _, other := range locks[1:] {
+		if !sameCommit(other.Commit, locks[0].Commit) {
+			descriptions := make([]string, len(locks))
+			for i, lock := range locks {
+				descriptions[i] = "  " + lock.String()
+			}
+			print.Warn(fmt.Sprintf("the lockfiles of dependencies disagree on %s/%s, resolving it from its constraints:\n%s",
+				meta.User, meta.Repo, strings.Join(descriptions, "\n")))
+			return meta
+		}
+	}
+	lock := locks[0]
+
+	constraints := pcx.Constraints[constraintKey(meta)]
+	if meta.Tag != "" && len(constraints) == 0 {
+		constraints = []string{meta.Tag}
+	}
+	if len(constraints) > 0 {
+		version, ok := pcx.upstreamVersion(ctx, meta, lock.Commit)
+		if !ok || !satisfiesConstraints(version, constraints) {
+			conflict := ConstraintConflict{Dependency: meta, Requests: pcx.requests[constraintKey(meta)]}
+			if len(conflict.Requests) == 0 {
+				for _, constraint := range constraints {
+					conflict.Requests = append(conflict.Requests, ConstraintRequest{Constraint: constraint})
+				}
+			}
+			locked := shortCommit(lock.Commit, "")
+			if version != nil {
+				locked = version.Original()
+			}
+			print.Warn(fmt.Sprintf("%s\n  %s locks it at %s which doesn't satisfy all of them, resolving it from its constraints",
+				conflict.Error(), lock.Requester, locked))
+			return meta
+		}
+	}
+
+	print.Verb(meta, "pinned to", shortCommit(lock.Commit, ""), "locked by", lock.Requester)
+	meta.Tag = ""
+	meta.Branch = ""
+	meta.Commit = lock.Commit
+	return meta
+}
+
+// upstreamVersion returns the newest version of a dependency at a commit, from its version source
+func (pcx *PackageContext) upstreamVersion(ctx context.Context, meta versioning.DependencyMeta, commit string) (version *semver.Version, ok bool) {
+	tags, err := pcx.cachedVersionTags(ctx, meta)
+	if err != nil {
+		print.Verb(meta, "locked version can't be checked:", err)
+		return
+	}
+	for _, tag := range tags {
+		if sameCommit(tag.Ref.Hash().String(), commit) && (version == nil || tag.Version.GreaterThan(version)) {
+			version = tag.Version
+		}
+	}
+	return version, version != nil
+}
+
+// satisfiesConstraints checks a version against semantic version constraints and tag names
+func satisfiesConstraints(version *semver.Version, constraints []string) bool {
+	for _, constraint := range constraints {
+		if _, err := semver.NewConstraint(constraint); err != nil {
+			if constraint != version.Original() {
+				return false
+			}
+		}
+	}
+	return satisfiesAll(version, semverOnly(constraints))
+}
+
+func sameCommit(a, b string) bool {
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
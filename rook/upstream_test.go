@@ -0,0 +1,84 @@
+package rook
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_pinToUpstream(t *testing.T) {
+	dir := util.FullPath("./tests/upstream")
+	os.RemoveAll(dir)
+
+	lib := versioning.DependencyMeta{Site: "github.com", User: "test", Repo: "lib"}
+	cacheDir := filepath.Join(dir, "cache")
+	commitVersions(t, lib.CachePath(cacheDir), []string{"v1.0.0", "v1.1.0", "v2.0.0"})
+
+	repo, err := git.PlainOpen(lib.CachePath(cacheDir))
+	assert.NoError(t, err)
+	ref, err := repo.Reference(plumbing.ReferenceName("refs/tags/v1.0.0"), true)
+	assert.NoError(t, err)
+	locked := ref.Hash().String()
+
+	// two dependencies that ship lockfiles, one locks test/lib at v1.0.0 and the other doesn't
+	vendor := filepath.Join(dir, "package", "dependencies")
+	first := versioning.DependencyMeta{Site: "github.com", User: "test", Repo: "first"}
+	second := versioning.DependencyMeta{Site: "github.com", User: "test", Repo: "second"}
+	for _, requester := range []versioning.DependencyMeta{first, second} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(vendor, requester.Repo), 0700))
+	}
+	assert.NoError(t, types.NewLockfile([]types.LockedDependency{
+		{Dependency: "test/lib:^1.0.0", Commit: locked},
+	}).Write(filepath.Join(vendor, first.Repo)))
+
+	pcx := PackageContext{
+		Package:  types.Package{LocalPath: filepath.Join(dir, "package"), Vendor: vendor},
+		CacheDir: cacheDir,
+	}
+	pcx.recordUpstreamLocks(first)
+	pcx.recordUpstreamLocks(second)
+
+	for _, tt := range []struct {
+		name        string
+		dep         versioning.DependencyMeta
+		constraints []string
+		wantCommit  string
+	}{
+		{"satisfied", versioning.DependencyMeta{User: "test", Repo: "lib", Tag: "^1.0.0"}, []string{"^1.0.0"}, locked},
+		{"satisfied by tag", versioning.DependencyMeta{User: "test", Repo: "lib", Tag: "v1.0.0"}, nil, locked},
+		{"conflict", versioning.DependencyMeta{User: "test", Repo: "lib", Tag: "^1.0.0"}, []string{"^1.0.0", ">=1.1.0"}, ""},
+		{"branch", versioning.DependencyMeta{User: "test", Repo: "lib", Branch: "master"}, nil, ""},
+		{"not locked", versioning.DependencyMeta{User: "test", Repo: "other", Tag: "^1.0.0"}, nil, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pcx.Constraints = map[string][]string{constraintKey(tt.dep): tt.constraints}
+			got := pcx.pinToUpstream(context.Background(), tt.dep)
+			assert.Equal(t, tt.wantCommit, got.Commit)
+			if tt.wantCommit == "" {
+				assert.Equal(t, tt.dep, got)
+			} else {
+				assert.Empty(t, got.Tag)
+			}
+		})
+	}
+
+	// lockfiles that disagree pin nothing
+	assert.NoError(t, types.NewLockfile([]types.LockedDependency{
+		{Dependency: "test/lib", Commit: "0123456789abcdef"},
+	}).Write(filepath.Join(vendor, second.Repo)))
+	pcx.upstream = nil
+	pcx.recordUpstreamLocks(first)
+	pcx.recordUpstreamLocks(second)
+	pcx.Constraints = nil
+	dep := versioning.DependencyMeta{User: "test", Repo: "lib", Tag: "^1.0.0"}
+	assert.Equal(t, dep, pcx.pinToUpstream(context.Background(), dep))
+}
@@ -0,0 +1,25 @@
+package rook
+
+import (
+	"context"
+)
+
+// Validate checks that a package is ready to build without running the compiler: the vendored
+// dependencies must match the package definition and every include directory of the build must
+// exist. The problems that were found are returned, err is only set if the checks couldn't run,
+// such as when there is no build with the given name.
+func (pcx *PackageContext) Validate(ctx context.Context, build string) (problems []string, err error) {
+	problems, err = pcx.staleVendored()
+	if err != nil {
+		return
+	}
+
+	config, err := pcx.buildPrepare(ctx, build, false, false)
+	if err != nil {
+		return
+	}
+	for _, missing := range pcx.missingIncludes(config) {
+		problems = append(problems, missing.String())
+	}
+	return
+}
@@ -0,0 +1,270 @@
+package rook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// VendorCheck is the result of verifying the vendored copy of a dependency against its locked commit
+type VendorCheck struct {
+	Dependency versioning.DependencyMeta
+	Commit     string   // the commit the dependency is locked to
+	Problem    string   // why the vendored copy as a whole can't be trusted, such as a damaged repository
+	Corrupted  []string // files whose contents differ from the locked commit, or the locked transform
+	Missing    []string // files in the locked commit that are not in the vendored copy
+	Repaired   bool     // whether the vendored copy was restored to the locked commit
+}
+
+// OK returns true if the vendored copy matches the locked commit
+func (vc VendorCheck) OK() bool {
+	return vc.Problem == "" && len(vc.Corrupted) == 0 && len(vc.Missing) == 0
+}
+
+func (vc VendorCheck) String() string {
+	if vc.Problem != "" {
+		return fmt.Sprintf("%s: %s", vc.Dependency, vc.Problem)
+	}
+	var parts []string
+	if len(vc.Corrupted) > 0 {
+		parts = append(parts, fmt.Sprintf("%d corrupted files (%s)", len(vc.Corrupted), summariseFiles(vc.Corrupted)))
+	}
+	if len(vc.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("%d missing files (%s)", len(vc.Missing), summariseFiles(vc.Missing)))
+	}
+	return fmt.Sprintf("%s: %s", vc.Dependency, strings.Join(parts, ", "))
+}
+
+// Verify checks the vendored copy of each dependency against the commit it is locked to. A commit
+// records the hash of every file in it so the contents of each vendored file are hashed and compared
+// with the locked commit, this catches files that were damaged or edited by accident which would
+// otherwise only show up as confusing compile errors. Files that the package transforms are checked
+// against the checksums of their transformed contents in the lockfile instead. Only dependencies with
+// problems are returned.
+//
+// If `repair` is set, each dependency with problems is restored: files are checked out again from
+// the locked commit, or if the repository itself is damaged or not at the locked commit, the vendored
+// copy is removed and ensured again at the locked commit. Either way the transforms are applied again.
+//
+// Compilers that build configs install from dependencies are checked against the checksums pinned
+// for them in the lockfile too, and with `repair` a damaged compiler is installed again.
+//
+// Verifying only reads the vendor directory, so it works when the vendor directory is read-only,
+// but repairing it doesn't.
+func (pcx *PackageContext) Verify(ctx context.Context, repair bool) (checks []VendorCheck, err error) {
+	if repair && pcx.ReadOnlyVendor {
+		return nil, errReadOnlyVendor("repaired")
+	}
+	lock, err := types.ReadLockfile(pcx.Package.LocalPath)
+	if err != nil {
+		return
+	}
+	if lock == nil {
+		return nil, errors.Errorf("verify requires a %s, run ensure to create one", types.LockfileName)
+	}
+	err = pcx.checkLockfileDeclared(*lock)
+	if err != nil {
+		return
+	}
+
+	for _, meta := range pcx.AllDependencies {
+		commit, _ := lock.Commit(meta)
+		check := pcx.verifyVendored(meta, commit, lock.Transformed(meta))
+		if check.OK() {
+			print.Verb(meta, "matches locked commit", commit)
+			continue
+		}
+
+		if repair {
+			err = pcx.repairVendored(ctx, *lock, &check)
+			if err != nil {
+				return checks, errors.Wrapf(err, "failed to repair %s", meta)
+			}
+		}
+		checks = append(checks, check)
+	}
+
+	compilers, err := pcx.verifyCompilers(ctx, *lock, repair)
+	checks = append(checks, compilers...)
+	return
+}
+
+// verifyVendored compares the files of a vendored dependency with those in the locked commit, except
+// for the files that were transformed, which are compared with the checksums in `transformed`
+func (pcx *PackageContext) verifyVendored(meta versioning.DependencyMeta, commit string, transformed map[string]string) (check VendorCheck) {
+	check = VendorCheck{Dependency: meta, Commit: commit}
+	dir := filepath.Join(pcx.Package.Vendor, meta.VendorName())
+
+	if !util.Exists(dir) {
+		check.Problem = "not vendored"
+		return
+	}
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		check.Problem = "not a valid repository: " + err.Error()
+		return
+	}
+	head, err := repo.Head()
+	if err != nil {
+		check.Problem = "failed to get repository HEAD: " + err.Error()
+		return
+	}
+	if head.Hash().String() != commit {
+		check.Problem = fmt.Sprintf("checked out at %s instead of locked commit %s", head.Hash(), commit)
+		return
+	}
+	locked, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		check.Problem = "locked commit is damaged: " + err.Error()
+		return
+	}
+	tree, err := locked.Tree()
+	if err != nil {
+		check.Problem = "locked commit is damaged: " + err.Error()
+		return
+	}
+
+	// files outside of a sparse checkout are absent on purpose and so are their contents, so only
+	// the entries of the tree are read, not the files themselves
+	sparse := isSparse(dir)
+	err = walkFiles(tree, func(name string, entry object.TreeEntry) error {
+		if entry.Mode == filemode.Symlink || entry.Mode == filemode.Submodule {
+			return nil
+		}
+		if sparse && !inSparseCone(name, sparseDir(meta)) {
+			return nil
+		}
+		contents, errRead := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if errRead != nil {
+			if os.IsNotExist(errRead) {
+				check.Missing = append(check.Missing, name)
+				return nil
+			}
+			return errRead
+		}
+		if sum, ok := transformed[name]; ok {
+			actual := sha256.Sum256(contents)
+			if hex.EncodeToString(actual[:]) != sum {
+				check.Corrupted = append(check.Corrupted, name)
+			}
+			return nil
+		}
+		if plumbing.ComputeHash(plumbing.BlobObject, contents) != entry.Hash {
+			check.Corrupted = append(check.Corrupted, name)
+		}
+		return nil
+	})
+	if err != nil {
+		check.Problem = "failed to read files: " + err.Error()
+	}
+	return
+}
+
+// walkFiles calls fn with the path and entry of every file in a tree and its subtrees
+func walkFiles(tree *object.Tree, fn func(name string, entry object.TreeEntry) error) (err error) {
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, errNext := walker.Next()
+		if errNext == io.EOF {
+			return nil
+		}
+		if errNext != nil {
+			return errNext
+		}
+		if !entry.Mode.IsFile() {
+			continue
+		}
+		err = fn(name, entry)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// repairVendored restores a vendored dependency to its locked commit
+func (pcx *PackageContext) repairVendored(ctx context.Context, lock types.Lockfile, check *VendorCheck) (err error) {
+	meta := check.Dependency
+	dir := filepath.Join(pcx.Package.Vendor, meta.VendorName())
+
+	if check.Problem == "" {
+		print.Info(meta, "checking out", len(check.Corrupted)+len(check.Missing), "damaged files from", check.Commit)
+		err = checkoutLocked(ctx, dir, check.Commit)
+		if err == nil {
+			// the checkout restores the committed contents of the transformed files too
+			err = pcx.applyTransforms(meta, dir)
+		}
+		if err == nil {
+			if after := pcx.verifyVendored(meta, check.Commit, lock.Transformed(meta)); after.OK() {
+				check.Repaired = true
+				return
+			}
+		}
+		print.Verb(meta, "checkout did not repair vendored copy, cloning it again:", err)
+	}
+
+	print.Info(meta, "removing vendored copy and ensuring it again at", check.Commit)
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return errors.Wrap(err, "failed to remove vendored copy")
+	}
+	err = pcx.EnsurePackage(ctx, pinToLockfile(meta, lock), false)
+	if err != nil {
+		return
+	}
+	if after := pcx.verifyVendored(meta, check.Commit, lock.Transformed(meta)); !after.OK() {
+		return errors.Errorf("vendored copy still does not match the locked commit: %s", after)
+	}
+	check.Repaired = true
+	return
+}
+
+// checkoutLocked overwrites the files of a vendored dependency with those from the locked commit.
+// The git library can't check out a sparse copy without writing the whole tree, so those are checked
+// out with the `git` command.
+func checkoutLocked(ctx context.Context, dir, commit string) (err error) {
+	if isSparse(dir) {
+		binary, errLook := exec.LookPath("git")
+		if errLook != nil {
+			return errors.Wrap(errLook, "dependency has a sparse checkout but the git command is not available")
+		}
+		output, errCheckout := runGit(ctx, binary, dir, "checkout", "--quiet", "--force", commit)
+		return errors.Wrapf(errCheckout, "failed to checkout %s: %s", commit, output)
+	}
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return
+	}
+	return wt.Checkout(&git.CheckoutOptions{Hash: plumbing.NewHash(commit), Force: true})
+}
+
+// summariseFiles lists the first few of a set of files for display
+func summariseFiles(files []string) string {
+	const limit = 5
+	if len(files) <= limit {
+		return strings.Join(files, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(files[:limit], ", "), len(files)-limit)
+}
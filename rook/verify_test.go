@@ -0,0 +1,164 @@
+package rook
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageContext_Verify(t *testing.T) {
+	dir := util.FullPath("./tests/verify")
+	os.RemoveAll(dir)
+
+	meta := versioning.DependencyMeta{Site: "github.com", User: "test", Repo: "verified", Tag: "1.0.0"}
+
+	vendored := filepath.Join(dir, "dependencies", "verified")
+	repo, err := git.PlainInit(vendored, false)
+	assert.NoError(t, err)
+	files := map[string]string{
+		"verified.inc":     "stock Verified() {}",
+		"other/helper.inc": "stock Helper() {}",
+	}
+	wt, err := repo.Worktree()
+	assert.NoError(t, err)
+	for name, contents := range files {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(vendored, name)), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(vendored, name), []byte(contents), 0644))
+		_, err = wt.Add(name)
+		assert.NoError(t, err)
+	}
+	hash, err := wt.Commit("initial", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@test", When: time.Now()},
+	})
+	assert.NoError(t, err)
+
+	pcx := PackageContext{
+		Package:         types.Package{LocalPath: dir, Vendor: filepath.Join(dir, "dependencies")},
+		AllDependencies: []versioning.DependencyMeta{meta},
+	}
+
+	// without a lockfile there is nothing to verify against
+	_, err = pcx.Verify(context.Background(), false)
+	assert.Error(t, err)
+
+	lock := types.NewLockfile([]types.LockedDependency{
+		{Dependency: versioning.DependencyString(meta.String()), Commit: hash.String()},
+	})
+	assert.NoError(t, lock.Write(dir))
+
+	checks, err := pcx.Verify(context.Background(), false)
+	assert.NoError(t, err)
+	assert.Empty(t, checks)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(vendored, "verified.inc"), []byte("stock Verified() {"), 0644))
+	assert.NoError(t, os.Remove(filepath.Join(vendored, "other", "helper.inc")))
+
+	checks, err = pcx.Verify(context.Background(), false)
+	assert.NoError(t, err)
+	if assert.Len(t, checks, 1) {
+		assert.Equal(t, []string{"verified.inc"}, checks[0].Corrupted)
+		assert.Equal(t, []string{"other/helper.inc"}, checks[0].Missing)
+		assert.False(t, checks[0].Repaired)
+	}
+
+	checks, err = pcx.Verify(context.Background(), true)
+	assert.NoError(t, err)
+	if assert.Len(t, checks, 1) {
+		assert.True(t, checks[0].Repaired)
+	}
+	for name, contents := range files {
+		actual, errRead := ioutil.ReadFile(filepath.Join(vendored, name))
+		assert.NoError(t, errRead)
+		assert.Equal(t, contents, string(actual))
+	}
+
+	checks, err = pcx.Verify(context.Background(), false)
+	assert.NoError(t, err)
+	assert.Empty(t, checks)
+
+	// a vendored copy at another commit is reported as a whole
+	other := types.NewLockfile([]types.LockedDependency{
+		{Dependency: versioning.DependencyString(meta.String()), Commit: "0123456789012345678901234567890123456789"},
+	})
+	assert.NoError(t, other.Write(dir))
+	checks, err = pcx.Verify(context.Background(), false)
+	assert.NoError(t, err)
+	if assert.Len(t, checks, 1) {
+		assert.Contains(t, checks[0].Problem, "instead of locked commit")
+	}
+}
+
+func TestPackageContext_VerifyTransformed(t *testing.T) {
+	dir := util.FullPath("./tests/verify-transformed")
+	os.RemoveAll(dir)
+
+	meta := versioning.DependencyMeta{Site: "github.com", User: "test", Repo: "transformed", Tag: "1.0.0"}
+
+	vendored := filepath.Join(dir, "dependencies", "transformed")
+	repo, err := git.PlainInit(vendored, false)
+	assert.NoError(t, err)
+	wt, err := repo.Worktree()
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(vendored, "transformed.inc"), []byte(`#include "C:\lib\a_samp"`), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(vendored, "other.inc"), []byte("stock Other() {}"), 0644))
+	for _, name := range []string{"transformed.inc", "other.inc"} {
+		_, err = wt.Add(name)
+		assert.NoError(t, err)
+	}
+	hash, err := wt.Commit("initial", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@test", When: time.Now()},
+	})
+	assert.NoError(t, err)
+
+	pcx := PackageContext{
+		Package: types.Package{
+			LocalPath:  dir,
+			Vendor:     filepath.Join(dir, "dependencies"),
+			Transforms: map[string][]types.Transform{"test/transformed": {{Find: `C:\\lib\\`, Replace: ""}}},
+		},
+		AllDependencies: []versioning.DependencyMeta{meta},
+	}
+	assert.NoError(t, pcx.applyTransforms(meta, vendored))
+	lock, err := pcx.ResolveLockfile()
+	assert.NoError(t, err)
+	assert.NoError(t, lock.Write(dir))
+	assert.Equal(t, hash.String(), lock.Dependencies[0].Commit)
+
+	// the transformed file differs from the commit but matches the transform
+	checks, err := pcx.Verify(context.Background(), false)
+	assert.NoError(t, err)
+	assert.Empty(t, checks)
+
+	// an edit to it is still caught
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(vendored, "transformed.inc"), []byte("edited"), 0644))
+	checks, err = pcx.Verify(context.Background(), false)
+	assert.NoError(t, err)
+	if assert.Len(t, checks, 1) {
+		assert.Equal(t, []string{"transformed.inc"}, checks[0].Corrupted)
+	}
+
+	// and repairing it applies the transform again instead of leaving the committed contents
+	checks, err = pcx.Verify(context.Background(), true)
+	assert.NoError(t, err)
+	if assert.Len(t, checks, 1) {
+		assert.True(t, checks[0].Repaired)
+	}
+	contents, err := ioutil.ReadFile(filepath.Join(vendored, "transformed.inc"))
+	assert.NoError(t, err)
+	assert.Equal(t, `#include "a_samp"`, string(contents))
+
+	checks, err = pcx.Verify(context.Background(), false)
+	assert.NoError(t, err)
+	assert.Empty(t, checks)
+}
@@ -0,0 +1,141 @@
+package rook
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+)
+
+// VersionMismatchPolicy describes what a release does when the version define in the source doesn't
+// match the version being released
+type VersionMismatchPolicy string
+
+const (
+	// VersionMismatchError stops the release
+	VersionMismatchError VersionMismatchPolicy = "error"
+	// VersionMismatchWarn prints a warning and continues the release
+	VersionMismatchWarn VersionMismatchPolicy = "warn"
+)
+
+// VersionMismatchPolicies lists the valid version mismatch policies
+var VersionMismatchPolicies = []VersionMismatchPolicy{VersionMismatchError, VersionMismatchWarn}
+
+// #define MYLIB_VERSION "1.2.0"
+var matchDefineValue = regexp.MustCompile(`^\s*#define\s+([A-Za-z_@][\w@]*)\s+(.+)$`)
+
+// CheckVersionDefine checks that the version define the package declares matches the version being
+// released. Packages that don't declare a version define aren't checked.
+func CheckVersionDefine(pkg types.Package, version string) (err error) {
+	if pkg.VersionDefine == "" {
+		return
+	}
+	policy := VersionMismatchPolicy(pkg.VersionMismatch)
+	switch policy {
+	case "":
+		policy = VersionMismatchError
+	case VersionMismatchError, VersionMismatchWarn:
+	default:
+		return errors.Errorf("unknown version mismatch policy %s, must be one of %v", policy, VersionMismatchPolicies)
+	}
+
+	err = compareVersionDefine(pkg, version)
+	if err != nil && policy == VersionMismatchWarn {
+		print.Warn(err)
+		return nil
+	}
+	return
+}
+
+func compareVersionDefine(pkg types.Package, version string) (err error) {
+	released, err := semver.NewVersion(version)
+	if err != nil {
+		return errors.Wrapf(err, "failed to interpret %s as a version", version)
+	}
+
+	file, value, err := findDefine(pkg.LocalPath, pkg.VersionDefine)
+	if err != nil {
+		return
+	}
+	if file == "" {
+		return errors.Errorf("version define %s was not found in the package source", pkg.VersionDefine)
+	}
+
+	declared, err := semver.NewVersion(defineValue(value))
+	if err != nil {
+		return errors.Errorf("version define %s in %s is %s which is not a version", pkg.VersionDefine, file, value)
+	}
+	if !declared.Equal(released) {
+		return errors.Errorf("version define %s in %s is %s but the release is %s, update it before releasing", pkg.VersionDefine, file, declared, released)
+	}
+	print.Verb(pkg, "version define", pkg.VersionDefine, "in", file, "matches", released)
+	return
+}
+
+// findDefine searches the source files of a package, excluding its dependencies, for the first
+// definition of a constant and returns the file it's in relative to the package and its value
+func findDefine(dir, name string) (file, value string, err error) {
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, errWalk error) error {
+		if errWalk != nil || file != "" {
+			return errWalk
+		}
+		if info.IsDir() {
+			if path != dir && (info.Name() == "dependencies" || strings.HasPrefix(info.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(path); ext != ".inc" && ext != ".pwn" {
+			return nil
+		}
+
+		found, ok, errScan := scanDefine(path, name)
+		if errScan != nil {
+			return errScan
+		}
+		if ok {
+			file, errScan = filepath.Rel(dir, path)
+			value = found
+		}
+		return errScan
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed to search package source for %s", name)
+	}
+	return
+}
+
+func scanDefine(path, name string) (value string, ok bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close() // nolint
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		captures := matchDefineValue.FindStringSubmatch(scanner.Text())
+		if captures == nil || captures[1] != name {
+			continue
+		}
+		value = captures[2]
+		if comment := strings.Index(value, "//"); comment != -1 {
+			value = value[:comment]
+		}
+		return strings.TrimSpace(value), true, nil
+	}
+	err = scanner.Err()
+	return
+}
+
+// defineValue strips the parentheses and quotes that a version constant is usually wrapped in
+func defineValue(value string) string {
+	return strings.Trim(value, `()" `)
+}
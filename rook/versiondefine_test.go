@@ -0,0 +1,51 @@
+package rook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+)
+
+func TestCheckVersionDefine(t *testing.T) {
+	dir := "./tests/versiondefine"
+	os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "include"), 0700)
+	os.MkdirAll(filepath.Join(dir, "dependencies", "other"), 0700)
+	ioutil.WriteFile(filepath.Join(dir, "test.pwn"), []byte("#include <mylib>\n"), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "include", "mylib.inc"), []byte("#define MYLIB_VERSION \"1.2.0\" // bump on release\n#define MYLIB_CHANNEL \"nightly\"\n"), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "dependencies", "other", "other.inc"), []byte("#define OTHER_VERSION \"9.9.9\"\n"), 0600)
+
+	tests := []struct {
+		name     string
+		define   string
+		mismatch string
+		version  string
+		wantErr  string
+	}{
+		{"undeclared", "", "", "2.0.0", ""},
+		{"match", "MYLIB_VERSION", "", "1.2.0", ""},
+		{"match prefixed", "MYLIB_VERSION", "", "v1.2.0", ""},
+		{"mismatch", "MYLIB_VERSION", "", "1.3.0", "version define MYLIB_VERSION in " + filepath.Join("include", "mylib.inc") + " is 1.2.0 but the release is 1.3.0, update it before releasing"},
+		{"mismatch warning", "MYLIB_VERSION", "warn", "1.3.0", ""},
+		{"not a version", "MYLIB_CHANNEL", "", "1.2.0", "version define MYLIB_CHANNEL in " + filepath.Join("include", "mylib.inc") + " is \"nightly\" which is not a version"},
+		{"dependency", "OTHER_VERSION", "", "9.9.9", "version define OTHER_VERSION was not found in the package source"},
+		{"unknown policy", "MYLIB_VERSION", "ignore", "1.2.0", "unknown version mismatch policy ignore, must be one of [error warn]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg := types.Package{LocalPath: dir, VersionDefine: tt.define, VersionMismatch: tt.mismatch}
+
+			err := CheckVersionDefine(pkg, tt.version)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
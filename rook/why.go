@@ -0,0 +1,108 @@
+package rook
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// DependencyPath represents a single chain of dependencies starting at a direct dependency of a
+// package and ending at the dependency in question.
+type DependencyPath []versioning.DependencyMeta
+
+func (dp DependencyPath) String() string {
+	parts := make([]string, len(dp))
+	for i, meta := range dp {
+		parts[i] = meta.String()
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// Why returns every path through the dependency graph of a package that leads to the given
+// dependency. The graph is read from the package vendor directory so the package should have been
+// ensured first, dependencies that are missing from the vendor directory are treated as leaves.
+func Why(pkg types.Package, dep versioning.DependencyMeta) (paths []DependencyPath, err error) {
+	if pkg.Vendor == "" {
+		err = errors.New("package has no vendor directory")
+		return
+	}
+
+	var (
+		recurse func(depStrings []versioning.DependencyString, current DependencyPath)
+		loaded  = make(map[string]types.Package)
+	)
+
+	recurse = func(depStrings []versioning.DependencyString, current DependencyPath) {
+		for _, depString := range depStrings {
+			meta, errInner := depString.Explode()
+			if errInner != nil {
+				print.Verb(pkg, "invalid dependency string:", depString, errInner)
+				continue
+			}
+
+			// guard against cycles by never visiting a package twice in a single path
+			if pathContains(current, meta) {
+				continue
+			}
+
+			next := make(DependencyPath, len(current), len(current)+1)
+			copy(next, current)
+			next = append(next, meta)
+
+			if sameDependency(meta, dep) {
+				paths = append(paths, next)
+				continue
+			}
+
+			meta.Alias = aliasIn(pkg, meta)
+			inner, ok := loaded[meta.VendorName()]
+			if !ok {
+				inner, errInner = types.PackageFromDir(meta.PackageDir(filepath.Join(pkg.Vendor, meta.VendorName())))
+				if errInner != nil {
+					print.Verb(meta, "is not a package:", errInner)
+				}
+				loaded[meta.VendorName()] = inner
+			}
+
+			recurse(inner.Dependencies, next)
+		}
+	}
+
+	if pkg.Parent {
+		recurse(pkg.GetAllDependencies(), nil)
+	} else {
+		recurse(pkg.Dependencies, nil)
+	}
+
+	return
+}
+
+func pathContains(path DependencyPath, meta versioning.DependencyMeta) bool {
+	for _, m := range path {
+		if sameDependency(m, meta) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameDependency compares two dependencies by user, repo and package subdirectory only, ignoring
+// version constraints.
+func sameDependency(a, b versioning.DependencyMeta) bool {
+	return strings.EqualFold(a.User, b.User) && strings.EqualFold(a.Repo, b.Repo) && a.Package == b.Package
+}
+
+// dependencyKey identifies a dependency regardless of its version, packages in subdirectories of the
+// same repository are different dependencies
+func dependencyKey(meta versioning.DependencyMeta) string {
+	key := strings.ToLower(meta.User + "/" + meta.Repo)
+	if meta.Package != "" {
+		key += "//" + meta.Package
+	}
+	return key
+}
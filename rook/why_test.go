@@ -0,0 +1,70 @@
+package rook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestWhy(t *testing.T) {
+	vendor := util.FullPath("./tests/why-basic/dependencies")
+	os.RemoveAll(vendor)
+
+	vendored := map[string]string{
+		"lib-a": `{"user": "test", "repo": "lib-a", "dependencies": ["test/lib-c", "test/lib-b"]}`,
+		"lib-b": `{"user": "test", "repo": "lib-b", "dependencies": ["test/lib-c"]}`,
+		"lib-c": `{"user": "test", "repo": "lib-c", "dependencies": ["test/lib-a"]}`,
+	}
+	for repo, contents := range vendored {
+		os.MkdirAll(filepath.Join(vendor, repo), 0755) //nolint
+		err := ioutil.WriteFile(filepath.Join(vendor, repo, "pawn.json"), []byte(contents), 0755)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	pkg := types.Package{
+		Parent: true,
+		Vendor: vendor,
+		Dependencies: []versioning.DependencyString{
+			"test/lib-a:1.0.0",
+			"test/lib-b",
+		},
+	}
+
+	tests := []struct {
+		name      string
+		dep       versioning.DependencyMeta
+		wantPaths []string
+	}{
+		{"direct", versioning.DependencyMeta{User: "test", Repo: "lib-a"}, []string{
+			"github.com/test/lib-a:1.0.0",
+			"github.com/test/lib-b -> github.com/test/lib-c -> github.com/test/lib-a",
+		}},
+		{"transitive", versioning.DependencyMeta{User: "test", Repo: "lib-c"}, []string{
+			"github.com/test/lib-a:1.0.0 -> github.com/test/lib-c",
+			"github.com/test/lib-a:1.0.0 -> github.com/test/lib-b -> github.com/test/lib-c",
+			"github.com/test/lib-b -> github.com/test/lib-c",
+		}},
+		{"missing", versioning.DependencyMeta{User: "test", Repo: "lib-d"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotPaths, err := Why(pkg, tt.dep)
+			assert.NoError(t, err)
+
+			var got []string
+			for _, path := range gotPaths {
+				got = append(got, path.String())
+			}
+			assert.Equal(t, tt.wantPaths, got)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package runtime
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// checksumMismatch is returned when a plugin binary doesn't match its pinned checksum, unlike other
+// failures to ensure a plugin this stops the runtime from being ensured.
+type checksumMismatch struct {
+	path     string
+	expected string
+	actual   string
+}
+
+func (c checksumMismatch) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s: expected %s, got %s", c.path, c.expected, c.actual)
+}
+
+// PluginChecksum returns the sha256 of a plugin binary as a hex string
+func PluginChecksum(path string) (checksum string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open plugin for checksum")
+	}
+	defer f.Close() // nolint
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read plugin for checksum")
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ServerChecksum returns the sha256 of the server binary in a runtime directory as a hex string
+func ServerChecksum(dir, platform string) (checksum string, err error) {
+	return PluginChecksum(filepath.Join(dir, getServerBinary(platform)))
+}
+
+// verifyPluginChecksum checks a plugin binary against its expected checksum, a binary that doesn't
+// match is removed so it can't be loaded by a server started later without ensuring it again.
+func verifyPluginChecksum(path, expected string) (err error) {
+	actual, err := PluginChecksum(path)
+	if err != nil {
+		return
+	}
+	if strings.EqualFold(actual, expected) {
+		return
+	}
+	if errRemove := os.Remove(path); errRemove != nil {
+		print.Warn("failed to remove plugin with mismatched checksum:", errRemove)
+	}
+	return checksumMismatch{path: path, expected: expected, actual: actual}
+}
+
+// verifyLockedPlugins checks the plugin binaries ensured from a dependency against the checksums
+// recorded for it and records the checksums of any binaries that weren't recorded yet.
+func verifyLockedPlugins(cfg *types.Runtime, meta versioning.DependencyMeta, pluginsDir string, files []types.Plugin) (err error) {
+	key := meta.User + "/" + meta.Repo
+	for _, file := range files {
+		path := filepath.Join(pluginsDir, string(file))
+		if expected, ok := cfg.PluginChecksums[key][string(file)]; ok {
+			err = verifyPluginChecksum(path, expected)
+			if err != nil {
+				return errors.Wrapf(err, "plugin %s of %s does not match %s", file, meta, types.LockfileName)
+			}
+			continue
+		}
+
+		var checksum string
+		checksum, err = PluginChecksum(path)
+		if err != nil {
+			return
+		}
+		if cfg.PluginChecksums == nil {
+			cfg.PluginChecksums = make(map[string]map[string]string)
+		}
+		if cfg.PluginChecksums[key] == nil {
+			cfg.PluginChecksums[key] = make(map[string]string)
+		}
+		cfg.PluginChecksums[key][string(file)] = checksum
+	}
+	return
+}
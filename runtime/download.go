@@ -1,6 +1,7 @@
 package runtime
 
 import (
+	"context"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -14,7 +15,7 @@ import (
 )
 
 // GetServerPackage checks if a cached package is available and if not, downloads it to dir
-func GetServerPackage(version, dir, platform string) (err error) {
+func GetServerPackage(ctx context.Context, version, dir, platform string) (err error) {
 	cacheDir, err := download.GetCacheDir()
 	if err != nil {
 		return errors.Wrap(err, "failed to get or create cache directory")
@@ -28,7 +29,7 @@ func GetServerPackage(version, dir, platform string) (err error) {
 		return
 	}
 
-	err = FromNet(cacheDir, version, dir, platform)
+	err = FromNet(ctx, cacheDir, version, dir, platform)
 	if err != nil {
 		return errors.Wrapf(err, "failed to get package %s from net", version)
 	}
@@ -66,7 +67,7 @@ func FromCache(cacheDir, version, dir, platform string) (hit bool, err error) {
 }
 
 // FromNet downloads a server package to the cache, then calls FromCache to finish the job
-func FromNet(cacheDir, version, dir, platform string) (err error) {
+func FromNet(ctx context.Context, cacheDir, version, dir, platform string) (err error) {
 	print.Info("Downloading package", version, "into", dir)
 
 	pkg, err := FindPackage(cacheDir, version)
@@ -85,7 +86,7 @@ func FromNet(cacheDir, version, dir, platform string) (err error) {
 		}
 	}
 
-	fullPath, err := download.FromNet(location, cacheDir, filename)
+	fullPath, err := download.FromNet(ctx, location, cacheDir, filename)
 	if err != nil {
 		return errors.Wrap(err, "failed to download package")
 	}
@@ -1,6 +1,7 @@
 package runtime
 
 import (
+	"context"
 	"runtime"
 	"testing"
 
@@ -31,7 +32,7 @@ func Test_ServerFromNet(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := FromNet(tt.args.cacheDir, tt.args.version, tt.args.dir, runtime.GOOS)
+			err := FromNet(context.Background(), tt.args.cacheDir, tt.args.version, tt.args.dir, runtime.GOOS)
 			assert.NoError(t, err)
 		})
 	}
@@ -31,7 +31,7 @@ func Ensure(ctx context.Context, gh *github.Client, cfg *types.Runtime, noCache
 		return
 	}
 
-	err = EnsureBinaries(cacheDir, *cfg)
+	err = EnsureBinaries(ctx, cacheDir, *cfg)
 	if err != nil {
 		return errors.Wrap(err, "failed to ensure runtime binaries")
 	}
@@ -46,6 +46,16 @@ func Ensure(ctx context.Context, gh *github.Client, cfg *types.Runtime, noCache
 		return errors.Wrap(err, "failed to ensure scripts")
 	}
 
+	err = EnsureRequiredPlugins(*cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to ensure required plugins")
+	}
+
+	err = CheckPlugins(*cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to check plugins")
+	}
+
 	err = GenerateServerCfg(cfg)
 	if err != nil {
 		return errors.Wrap(err, "failed to generate server.cfg")
@@ -55,7 +65,7 @@ func Ensure(ctx context.Context, gh *github.Client, cfg *types.Runtime, noCache
 }
 
 // EnsureBinaries ensures the dir has all the necessary files to run a server
-func EnsureBinaries(cacheDir string, cfg types.Runtime) (err error) {
+func EnsureBinaries(ctx context.Context, cacheDir string, cfg types.Runtime) (err error) {
 	missing := false
 
 	if !util.Exists(filepath.Join(cfg.WorkingDir, getNpcBinary(cfg.Platform))) {
@@ -69,7 +79,7 @@ func EnsureBinaries(cacheDir string, cfg types.Runtime) (err error) {
 	}
 
 	if missing {
-		err = GetServerPackage(cfg.Version, cfg.WorkingDir, cfg.Platform)
+		err = GetServerPackage(ctx, cfg.Version, cfg.WorkingDir, cfg.Platform)
 		if err != nil {
 			return errors.Wrap(err, "failed to get runtime package")
 		}
@@ -127,6 +137,35 @@ func EnsureScripts(cfg types.Runtime) (err error) {
 	return
 }
 
+// EnsureRequiredPlugins checks that every plugin listed in `required_plugins` is in the plugin list,
+// either declared directly or resolved from a dependency, and that its binary is present. Without
+// this, a missing plugin is only noticed once every call to one of its natives fails at runtime.
+func EnsureRequiredPlugins(cfg types.Runtime) (err error) {
+	ext := pluginExtForFile(cfg.Platform)
+
+	loaded := make(map[string]struct{})
+	for _, plugin := range cfg.Plugins {
+		name := strings.TrimSuffix(filepath.Base(string(plugin)), ext)
+		if !util.Exists(filepath.Join(cfg.WorkingDir, "plugins", name+ext)) {
+			continue
+		}
+		loaded[strings.ToLower(name)] = struct{}{}
+	}
+
+	missing := []string{}
+	for _, required := range cfg.RequiredPlugins {
+		if _, ok := loaded[strings.ToLower(strings.TrimSuffix(required, ext))]; !ok {
+			missing = append(missing, required)
+		}
+	}
+
+	if len(missing) > 0 {
+		err = errors.Errorf("required plugins are not in the plugin list or their binaries are missing: %s", strings.Join(missing, ", "))
+	}
+
+	return
+}
+
 func pluginExtForFile(os string) (ext string) {
 	switch os {
 	case "windows":
@@ -0,0 +1,91 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// EnsureCompilerResource installs the compiler that a package provides as a resource and returns the
+// path to the compiler binary. The resource for the platform must be an archive with a `compiler`
+// path, its `files` are extracted next to the binary so libraries such as `libpawnc.so` can be found.
+// The resource is downloaded through the resource cache like any other and each distinct archive is
+// installed once to its own directory in the cache, which is named after the archive's checksum.
+func EnsureCompilerResource(ctx context.Context, gh *github.Client, meta versioning.DependencyMeta, platform, cacheDir string) (binary string, err error) {
+	filename, resource, err := EnsureVersionedPluginCached(ctx, meta, platform, cacheDir, false, gh)
+	if err != nil {
+		return
+	}
+	if resource.Compiler == "" {
+		return "", errors.Errorf("resource of %s for %s does not provide a compiler", meta, platform)
+	}
+	if !resource.Archive {
+		return "", errors.Errorf("compiler resource of %s for %s must be an archive", meta, platform)
+	}
+
+	checksum, err := PluginChecksum(filename)
+	if err != nil {
+		return
+	}
+	dir := filepath.Join(cacheDir, "pawn", "resources", fmt.Sprintf("%s-%s-%s", meta.User, meta.Repo, checksum[:16]))
+	binary = filepath.Join(dir, filepath.Base(resource.Compiler))
+	if util.Exists(binary) {
+		print.Verb(meta, "compiler already installed at", dir)
+		return
+	}
+
+	// extract next to the final directory first so a concurrent or interrupted install never leaves a
+	// partial compiler in place
+	err = os.MkdirAll(filepath.Dir(dir), 0700)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create compiler directory")
+	}
+	tmp, err := ioutil.TempDir(filepath.Dir(dir), filepath.Base(dir)+"-")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create compiler directory")
+	}
+	defer os.RemoveAll(tmp) // nolint
+
+	paths := map[string]string{resource.Compiler: ""}
+	for src, dest := range resource.Files {
+		paths[src] = dest
+	}
+	print.Verb(meta, "installing compiler to", dir)
+	extracted, err := download.Extract(ctx, filename, tmp, paths)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to extract compiler of %s", meta)
+	}
+
+	target, ok := extracted[resource.Compiler]
+	if !ok {
+		return "", errors.Errorf("compiler %s is not in the resource archive of %s", resource.Compiler, meta)
+	}
+	if expected, ok := resource.Checksums[resource.Compiler]; ok {
+		err = verifyPluginChecksum(target, expected)
+		if err != nil {
+			return "", errors.Wrapf(err, "compiler of %s", meta)
+		}
+	}
+	for source, file := range extracted {
+		err = applyResourceMode(resource, source, file, source == resource.Compiler)
+		if err != nil {
+			return
+		}
+	}
+
+	err = os.Rename(tmp, dir)
+	if err != nil && !util.Exists(binary) {
+		return "", errors.Wrap(err, "failed to install compiler")
+	}
+	return binary, nil
+}
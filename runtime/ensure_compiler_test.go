@@ -0,0 +1,71 @@
+package runtime
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestEnsureCompilerResource(t *testing.T) {
+	var (
+		cacheDir = util.FullPath("./tests/compiler-resource/cache")
+		meta     = versioning.DependencyMeta{User: "sampctl-test-user", Repo: "compiler-test", Tag: "3.10.10"}
+	)
+	os.RemoveAll("./tests/compiler-resource")
+	assert.NoError(t, os.MkdirAll(filepath.Join(cacheDir, "resources"), 0700))
+
+	store := func(resource types.Resource) {
+		archive := filepath.Join(cacheDir, "resources", "pawnc-3.10.10-linux.tar.gz")
+		writeTestArchive(t, archive, map[string]int64{
+			"pawnc-3.10.10-linux/bin/pawncc":      0755,
+			"pawnc-3.10.10-linux/lib/libpawnc.so": 0644,
+		})
+		_, err := download.StoreResource(cacheDir, archive, download.ResourceEntry{
+			URL:        "https://example.com/pawnc-3.10.10-linux.tar.gz",
+			Name:       "pawnc-3.10.10-linux.tar.gz",
+			Dependency: "sampctl-test-user/compiler-test",
+			Tag:        "3.10.10",
+			Platform:   "linux",
+			Resource:   &resource,
+		})
+		assert.NoError(t, err)
+	}
+
+	store(types.Resource{
+		Name:     "^pawnc-(.*)-linux.tar.gz$",
+		Platform: "linux",
+		Archive:  true,
+		Compiler: "pawnc-3.10.10-linux/bin/pawncc",
+		Files:    map[string]string{"pawnc-3.10.10-linux/lib/libpawnc.so": "libpawnc.so"},
+	})
+	binary, err := EnsureCompilerResource(context.Background(), gh, meta, "linux", cacheDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "pawncc", filepath.Base(binary))
+	assert.True(t, util.Exists(filepath.Join(filepath.Dir(binary), "libpawnc.so")))
+	contents, err := ioutil.ReadFile(binary)
+	assert.NoError(t, err)
+	assert.Equal(t, "pawnc-3.10.10-linux/bin/pawncc", string(contents))
+
+	// installing again uses the existing directory
+	again, err := EnsureCompilerResource(context.Background(), gh, meta, "linux", cacheDir)
+	assert.NoError(t, err)
+	assert.Equal(t, binary, again)
+
+	// a resource without a compiler can't be used as one
+	store(types.Resource{
+		Name:     "^pawnc-(.*)-linux.tar.gz$",
+		Platform: "linux",
+		Archive:  true,
+	})
+	_, err = EnsureCompilerResource(context.Background(), gh, meta, "linux", cacheDir)
+	assert.Error(t, err)
+}
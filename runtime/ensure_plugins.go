@@ -1,24 +1,30 @@
 package runtime
 
 import (
+	"bytes"
 	"context"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
 
 	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/events"
 	"github.com/Southclaws/sampctl/print"
 	"github.com/Southclaws/sampctl/types"
 	"github.com/Southclaws/sampctl/util"
 	"github.com/Southclaws/sampctl/versioning"
 )
 
-// EnsurePlugins validates and downloads plugin binary files
+// EnsurePlugins validates and downloads plugin binary files. The plugins of different dependencies
+// are downloaded at the same time, see `SetResourceConcurrency`, and are added to the runtime in the
+// order the dependencies are listed.
 func EnsurePlugins(ctx context.Context, gh *github.Client, cfg *types.Runtime, cacheDir string, noCache bool) (err error) {
 	pluginsDir := util.FullPath(filepath.Join(cfg.WorkingDir, "plugins"))
 
@@ -29,23 +35,60 @@ func EnsurePlugins(ctx context.Context, gh *github.Client, cfg *types.Runtime, c
 
 	fileExt := pluginExtForFile(cfg.Platform)
 
+	// a dependency listed twice would be downloaded to the same file twice at the same time
+	var deps []versioning.DependencyMeta
+	seen := make(map[string]bool)
+	for _, plugin := range cfg.PluginDeps {
+		if !seen[plugin.String()] {
+			seen[plugin.String()] = true
+			deps = append(deps, plugin)
+		}
+	}
+
 	var (
 		newPlugins = []types.Plugin{}
-		files      []types.Plugin
+		ensured    = make([][]types.Plugin, len(deps))
+		cfgMu      sync.Mutex
 	)
 
-	for _, plugin := range cfg.PluginDeps {
+	err = forEachResource(ctx, deps, func(ctx context.Context, i int, plugin versioning.DependencyMeta) error {
 		print.Verb("plugin", plugin, "is a package dependency")
-		files, err = EnsureVersionedPlugin(ctx, gh, plugin, cfg.WorkingDir, cfg.Platform, cacheDir, true, false, noCache)
-		if err != nil {
-			print.Warn("failed to ensure plugin", plugin, err)
-			err = nil
-			continue
+		files, resource, errInner := ensureVersionedPlugin(ctx, gh, plugin, cfg.WorkingDir, cfg.Platform, cacheDir, true, false, noCache)
+		if errInner != nil {
+			if _, ok := errors.Cause(errInner).(checksumMismatch); ok {
+				return errInner
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			print.Warn("failed to ensure plugin", plugin, errInner)
+			return nil
+		}
+
+		cfgMu.Lock()
+		defer cfgMu.Unlock()
+		errInner = verifyLockedPlugins(cfg, plugin, pluginsDir, files)
+		if errInner != nil {
+			return errInner
+		}
+		if !resource.SupportsServer(cfg.Version) {
+			print.Warn(plugin, "is built for SA-MP server", strings.Join(resource.Servers, ", "),
+				"but the runtime is", cfg.Version+", the server may crash when it loads the plugin")
 		}
+		ensured[i] = files
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	for _, files := range ensured {
 		newPlugins = append(newPlugins, files...)
 	}
 
 	added := make(map[types.Plugin]struct{})
+	for _, plugin := range cfg.Plugins {
+		added[types.Plugin(strings.TrimSuffix(string(plugin), fileExt))] = struct{}{}
+	}
 
 	// trim extensions for plugins list, they are added later by GenerateServerCFG if needed
 	for _, plugin := range newPlugins {
@@ -64,7 +107,17 @@ func EnsurePlugins(ctx context.Context, gh *github.Client, cfg *types.Runtime, c
 
 // EnsureVersionedPlugin automatically downloads a plugin binary from its github releases page
 func EnsureVersionedPlugin(ctx context.Context, gh *github.Client, meta versioning.DependencyMeta, dir, platform, cacheDir string, plugins, includes, noCache bool) (files []types.Plugin, err error) {
-	filename, resource, err := EnsureVersionedPluginCached(ctx, meta, platform, cacheDir, noCache, gh)
+	files, _, err = ensureVersionedPlugin(ctx, gh, meta, dir, platform, cacheDir, plugins, includes, noCache)
+	return
+}
+
+// ensureVersionedPlugin does the work of `EnsureVersionedPlugin` and also returns the resource the
+// files came from
+func ensureVersionedPlugin(ctx context.Context, gh *github.Client, meta versioning.DependencyMeta, dir, platform, cacheDir string, plugins, includes, noCache bool) (files []types.Plugin, resource types.Resource, err error) {
+	defer events.Measure(ctx, events.StepResource, meta.String(), time.Now())
+
+	var filename string
+	filename, resource, err = EnsureVersionedPluginCached(ctx, meta, platform, cacheDir, noCache, gh)
 	if err != nil {
 		return
 	}
@@ -72,16 +125,9 @@ func EnsureVersionedPlugin(ctx context.Context, gh *github.Client, meta versioni
 	print.Verb(meta, "retrieved package to file:", filename)
 
 	if resource.Archive {
-		var (
-			ext    = filepath.Ext(filename)
-			method download.ExtractFunc
-		)
-		if ext == ".zip" {
-			method = download.Unzip
-		} else if ext == ".gz" {
-			method = download.Untar
-		} else {
-			err = errors.Errorf("unsupported archive format: %s", filename)
+		_, err = download.DetectArchive(filename)
+		if err != nil {
+			err = errors.Wrapf(err, "resource %s of %s", resource.Name, meta)
 			return
 		}
 
@@ -106,19 +152,56 @@ func EnsureVersionedPlugin(ctx context.Context, gh *github.Client, meta versioni
 			paths[src] = dest
 		}
 
+		// get files selected by glob, these are matched against the archive listing so each one can
+		// be given an exact path and keep its directory structure
+		globbed := make(map[string]string)
+		if len(resource.Globs) > 0 {
+			var names []string
+			names, err = matchArchiveGlobs(ctx, filename, resource.Globs)
+			if err != nil {
+				err = errors.Wrapf(err, "failed to select files of resource %s of %s", resource.Name, meta)
+				return
+			}
+			for _, name := range names {
+				key := "^" + regexp.QuoteMeta(name) + "$"
+				paths[key] = filepath.Join(ResourceFilesDir(meta), filepath.FromSlash(name))
+				globbed[key] = name
+			}
+		}
+
 		var extractedFiles map[string]string
-		extractedFiles, err = method(filename, dir, paths)
+		extractedFiles, err = download.Extract(ctx, filename, dir, paths)
 		if err != nil {
 			err = errors.Wrapf(err, "failed to extract plugin %s to %s", meta, dir)
 			return
 		}
 
 		for source, target := range extractedFiles {
+			if name, ok := globbed[source]; ok {
+				source = name
+			}
+			events.Publish(ctx, events.FileExtracted{Source: source, Target: target})
+
+			isPlugin := false
 			for _, plugin := range resource.Plugins {
 				if source == plugin {
 					files = append(files, types.Plugin(filepath.Base(target)))
+					isPlugin = true
 				}
 			}
+
+			if expected, ok := resource.Checksums[source]; ok {
+				err = verifyPluginChecksum(target, expected)
+				if err != nil {
+					err = errors.Wrapf(err, "plugin %s of %s", source, meta)
+					return
+				}
+			}
+
+			err = applyResourceMode(resource, source, target, isPlugin)
+			if err != nil {
+				return
+			}
 		}
 	} else {
 		base := filepath.Base(filename)
@@ -134,7 +217,98 @@ func EnsureVersionedPlugin(ctx context.Context, gh *github.Client, meta versioni
 	return
 }
 
-// EnsureVersionedPluginCached ensures that a plugin exists in the cache
+// ResourceFilesDir returns the directory, relative to the working directory, that files selected by
+// the `globs` of a dependency's resource are extracted to
+func ResourceFilesDir(meta versioning.DependencyMeta) string {
+	return filepath.Join("resources", meta.Repo)
+}
+
+// matchArchiveGlobs lists the files in an archive that match any of the glob patterns. Patterns use
+// forward slashes, `*` and `?` don't match a slash and `**` matches any number of directories.
+func matchArchiveGlobs(ctx context.Context, filename string, globs []string) (names []string, err error) {
+	var matchers []*regexp.Regexp
+	for _, glob := range globs {
+		matchers = append(matchers, GlobRegexp(glob))
+	}
+
+	all, err := download.ListArchive(ctx, filename)
+	if err != nil {
+		return
+	}
+
+	matched := make([]bool, len(globs))
+	for _, name := range all {
+		name = strings.TrimPrefix(filepath.ToSlash(name), "./")
+		for i, matcher := range matchers {
+			if matcher.MatchString(name) {
+				names = append(names, name)
+				matched[i] = true
+				break
+			}
+		}
+	}
+	for i, glob := range globs {
+		if !matched[i] {
+			print.Warn("resource glob", glob, "did not match any files")
+		}
+	}
+	return
+}
+
+// GlobRegexp converts a glob pattern to an equivalent regular expression
+func GlobRegexp(glob string) *regexp.Regexp {
+	var expr bytes.Buffer
+	expr.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
+
+// applyResourceMode sets the permissions of an extracted file, a mode override from the resource
+// takes precedence, otherwise plugin binaries are made executable so the server can load them and
+// all other files keep the permissions from the archive.
+func applyResourceMode(resource types.Resource, source, target string, isPlugin bool) (err error) {
+	mode, ok, err := resource.Mode(source)
+	if err != nil {
+		return
+	}
+	if !ok {
+		if !isPlugin {
+			return
+		}
+		var info os.FileInfo
+		info, err = os.Stat(target)
+		if err != nil {
+			return errors.Wrap(err, "failed to stat extracted plugin")
+		}
+		mode = info.Mode().Perm() | 0755
+	}
+
+	print.Verb("setting mode of", target, "to", mode)
+	err = os.Chmod(target, mode)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to set permissions of %s", target)
+	}
+	return
+}
+
+// EnsureVersionedPluginCached ensures that a plugin exists in the cache. If the plugin can't be
+// downloaded, such as when there is no network, a matching resource from the resource cache is used.
 func EnsureVersionedPluginCached(
 	ctx context.Context,
 	meta versioning.DependencyMeta,
@@ -150,10 +324,13 @@ func EnsureVersionedPluginCached(
 	hit := false
 	// only pull from cache if there is a version tag specified
 	if !noCache && meta.Tag != "" {
-		hit, filename, resource, err = PluginFromCache(meta, platform, cacheDir)
-		if err != nil {
-			err = errors.Wrapf(err, "failed to get plugin %s from cache", meta)
-			return
+		hit, filename, resource = PluginFromResourceCache(meta, platform, cacheDir)
+		if !hit {
+			hit, filename, resource, err = PluginFromCache(meta, platform, cacheDir)
+			if err != nil {
+				err = errors.Wrapf(err, "failed to get plugin %s from cache", meta)
+				return
+			}
 		}
 	}
 	if !hit {
@@ -163,6 +340,10 @@ func EnsureVersionedPluginCached(
 
 		filename, resource, err = PluginFromNet(ctx, gh, meta, platform, cacheDir)
 		if err != nil {
+			if hit, cached, cachedResource := PluginFromResourceCache(meta, platform, cacheDir); hit {
+				print.Warn(meta, "failed to download plugin, using cached copy:", err)
+				return cached, cachedResource, nil
+			}
 			err = errors.Wrapf(err, "failed to get plugin %s from net", meta)
 			return
 		}
@@ -171,6 +352,18 @@ func EnsureVersionedPluginCached(
 	return
 }
 
+// PluginFromResourceCache tries to grab a plugin asset from the resource cache shared by all packages,
+// without a version tag the most recently cached release is used
+func PluginFromResourceCache(meta versioning.DependencyMeta, platform, cacheDir string) (hit bool, filename string, resource types.Resource) {
+	entry, ok := download.CachedResource(cacheDir, meta.User+"/"+meta.Repo, meta.Tag, platform, nil)
+	if !ok || entry.Resource == nil {
+		print.Verb(meta, "not in resource cache for", platform)
+		return
+	}
+	print.Verb(meta, "using release", entry.Tag, "asset", entry.Name, "from resource cache")
+	return true, entry.Path(cacheDir), *entry.Resource
+}
+
 // PluginFromCache tries to grab a plugin asset from the cache, `hit` indicates if it was successful
 func PluginFromCache(meta versioning.DependencyMeta, platform, cacheDir string) (hit bool, filename string, resource types.Resource, err error) {
 	resourcePath := filepath.Join(cacheDir, GetResourcePath(meta))
@@ -222,14 +415,13 @@ func PluginFromCache(meta versioning.DependencyMeta, platform, cacheDir string)
 	return
 }
 
-// PluginFromNet downloads a plugin from the given metadata to the cache directory
+// PluginFromNet downloads a plugin from the given metadata to the resource cache. If the release
+// asset was downloaded before, by this or any other package, the cached copy is used instead. A
+// download that is cut off is resumed, by the next run if it can't be finished in this one.
 func PluginFromNet(ctx context.Context, gh *github.Client, meta versioning.DependencyMeta, platform, cacheDir string) (filename string, resource types.Resource, err error) {
 	print.Info(meta, "downloading plugin resource for", platform)
 
-	resourcePathOnly := GetResourcePath(meta)
-	resourcePath := filepath.Join(cacheDir, resourcePathOnly)
-
-	err = os.MkdirAll(resourcePath, 0700)
+	err = os.MkdirAll(filepath.Join(cacheDir, "resources"), 0700)
 	if err != nil {
 		err = errors.Wrap(err, "failed to create cache directory for package resources")
 		return
@@ -255,10 +447,40 @@ func PluginFromNet(ctx context.Context, gh *github.Client, meta versioning.Depen
 		return
 	}
 
-	filename, _, err = download.ReleaseAssetByPattern(ctx, gh, meta, matcher, resourcePathOnly, "", cacheDir)
+	asset, tag, err := download.ReleaseAsset(ctx, gh, meta, matcher)
+	if err != nil {
+		return
+	}
+
+	entry := download.ResourceEntry{
+		URL:        asset.GetBrowserDownloadURL(),
+		Name:       asset.GetName(),
+		Dependency: meta.User + "/" + meta.Repo,
+		Tag:        tag,
+		Platform:   platform,
+		Resource:   &resource,
+	}
+
+	if cached, ok := download.CachedResourceByURL(cacheDir, entry.URL); ok {
+		print.Verb(meta, "release asset", entry.URL, "is already cached")
+		entry.SHA256 = cached.SHA256
+		entry.Name = cached.Name
+		err = download.IndexResource(cacheDir, entry)
+		if err != nil {
+			return
+		}
+		return entry.Path(cacheDir), resource, nil
+	}
+
+	downloaded, err := download.ResumableFromNet(ctx, entry.URL, cacheDir, filepath.Join("resources", entry.Name+".download"))
+	if err != nil {
+		return
+	}
+	entry, err = download.StoreResource(cacheDir, downloaded, entry)
 	if err != nil {
 		return
 	}
+	filename = entry.Path(cacheDir)
 
 	print.Verb(meta, "downloaded", filename, "to cache")
 
@@ -1,13 +1,19 @@
 package runtime
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/Southclaws/sampctl/download"
 	"github.com/Southclaws/sampctl/types"
 	"github.com/Southclaws/sampctl/util"
 	"github.com/Southclaws/sampctl/versioning"
@@ -149,3 +155,247 @@ func TestGetPluginRemotePackage(t *testing.T) {
 		})
 	}
 }
+
+func TestEnsureVersionedPluginPermissions(t *testing.T) {
+	var (
+		cacheDir = "./tests/permissions/cache"
+		dir      = "./tests/permissions/server"
+		meta     = versioning.DependencyMeta{User: "user", Repo: "perms", Tag: "1.0.0"}
+	)
+	os.RemoveAll("./tests/permissions")
+
+	pkg := types.Package{
+		DependencyMeta: meta,
+		Format:         "json",
+		Resources: []types.Resource{{
+			Name:     "^perms-(.*).tar.gz$",
+			Platform: "linux",
+			Archive:  true,
+			Plugins:  []string{"plugins/perms.so"},
+			Files: map[string]string{
+				"data/config.txt": "scriptfiles/config.txt",
+				"data/start.sh":   "start.sh",
+			},
+			Modes: map[string]string{
+				"data/start.sh": "0750",
+			},
+		}},
+	}
+	pkg.LocalPath = meta.CachePath(cacheDir)
+	assert.NoError(t, os.MkdirAll(pkg.LocalPath, 0700))
+	assert.NoError(t, pkg.WriteDefinition())
+
+	resourceDir := filepath.Join(cacheDir, GetResourcePath(meta))
+	assert.NoError(t, os.MkdirAll(resourceDir, 0700))
+	writeTestArchive(t, filepath.Join(resourceDir, "perms-1.0.0.tar.gz"), map[string]int64{
+		"plugins/perms.so": 0644,
+		"data/config.txt":  0600,
+		"data/start.sh":    0644,
+	})
+
+	files, err := EnsureVersionedPlugin(context.Background(), gh, meta, dir, "linux", cacheDir, true, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Plugin{"perms.so"}, files)
+
+	info, err := os.Stat(filepath.Join(dir, "plugins", "perms.so"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	info, err = os.Stat(filepath.Join(dir, "scriptfiles", "config.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	info, err = os.Stat(filepath.Join(dir, "start.sh"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0750), info.Mode().Perm())
+}
+
+func TestEnsureVersionedPluginGlobs(t *testing.T) {
+	var (
+		cacheDir = "./tests/globs/cache"
+		dir      = "./tests/globs/server"
+		meta     = versioning.DependencyMeta{User: "user", Repo: "models", Tag: "1.0.0"}
+	)
+	os.RemoveAll("./tests/globs")
+
+	pkg := types.Package{
+		DependencyMeta: meta,
+		Format:         "json",
+		Resources: []types.Resource{{
+			Name:     "^models-(.*).tar.gz$",
+			Platform: "linux",
+			Archive:  true,
+			Globs:    []string{"data/**/*.json", "templates/*.cfg"},
+		}},
+	}
+	pkg.LocalPath = meta.CachePath(cacheDir)
+	assert.NoError(t, os.MkdirAll(pkg.LocalPath, 0700))
+	assert.NoError(t, pkg.WriteDefinition())
+
+	resourceDir := filepath.Join(cacheDir, GetResourcePath(meta))
+	assert.NoError(t, os.MkdirAll(resourceDir, 0700))
+	writeTestArchive(t, filepath.Join(resourceDir, "models-1.0.0.tar.gz"), map[string]int64{
+		"data/index.json":            0644,
+		"data/vehicles/cars.json":    0644,
+		"data/vehicles/readme.txt":   0644,
+		"templates/server.cfg":       0644,
+		"templates/nested/other.cfg": 0644,
+	})
+
+	files, err := EnsureVersionedPlugin(context.Background(), gh, meta, dir, "linux", cacheDir, true, false, false)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	for name, want := range map[string]bool{
+		"data/index.json":            true,
+		"data/vehicles/cars.json":    true,
+		"data/vehicles/readme.txt":   false,
+		"templates/server.cfg":       true,
+		"templates/nested/other.cfg": false,
+	} {
+		assert.Equal(t, want, util.Exists(filepath.Join(dir, "resources", "models", name)), name)
+	}
+}
+
+func TestEnsureVersionedPluginChecksums(t *testing.T) {
+	var (
+		cacheDir = "./tests/checksums/cache"
+		dir      = "./tests/checksums/server"
+		meta     = versioning.DependencyMeta{User: "user", Repo: "sums", Tag: "1.0.0"}
+	)
+	os.RemoveAll("./tests/checksums")
+
+	// the test archive contains each file's own name
+	sum := sha256.Sum256([]byte("plugins/sums.so"))
+	pkg := types.Package{
+		DependencyMeta: meta,
+		Format:         "json",
+		Resources: []types.Resource{{
+			Name:      "^sums-(.*).tar.gz$",
+			Platform:  "linux",
+			Archive:   true,
+			Plugins:   []string{"plugins/sums.so"},
+			Checksums: map[string]string{"plugins/sums.so": hex.EncodeToString(sum[:])},
+		}},
+	}
+	pkg.LocalPath = meta.CachePath(cacheDir)
+	assert.NoError(t, os.MkdirAll(pkg.LocalPath, 0700))
+	assert.NoError(t, pkg.WriteDefinition())
+
+	resourceDir := filepath.Join(cacheDir, GetResourcePath(meta))
+	assert.NoError(t, os.MkdirAll(resourceDir, 0700))
+	writeTestArchive(t, filepath.Join(resourceDir, "sums-1.0.0.tar.gz"), map[string]int64{
+		"plugins/sums.so": 0644,
+	})
+
+	files, err := EnsureVersionedPlugin(context.Background(), gh, meta, dir, "linux", cacheDir, true, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Plugin{"sums.so"}, files)
+
+	// a checksum recorded in the lockfile is verified and new ones are recorded
+	cfg := &types.Runtime{}
+	pluginsDir := filepath.Join(dir, "plugins")
+	assert.NoError(t, verifyLockedPlugins(cfg, meta, pluginsDir, files))
+	assert.Equal(t, hex.EncodeToString(sum[:]), cfg.PluginChecksums["user/sums"]["sums.so"])
+	assert.NoError(t, verifyLockedPlugins(cfg, meta, pluginsDir, files))
+
+	cfg.PluginChecksums["user/sums"]["sums.so"] = "0000"
+	err = verifyLockedPlugins(cfg, meta, pluginsDir, files)
+	assert.Error(t, err)
+	assert.IsType(t, checksumMismatch{}, errors.Cause(err))
+	assert.False(t, util.Exists(filepath.Join(pluginsDir, "sums.so")))
+
+	// a binary that doesn't match the resource's checksum is not left behind
+	pkg.Resources[0].Checksums["plugins/sums.so"] = "0000"
+	assert.NoError(t, pkg.WriteDefinition())
+	_, err = EnsureVersionedPlugin(context.Background(), gh, meta, dir, "linux", cacheDir, true, false, false)
+	assert.Error(t, err)
+	assert.IsType(t, checksumMismatch{}, errors.Cause(err))
+	assert.False(t, util.Exists(filepath.Join(pluginsDir, "sums.so")))
+}
+
+func TestEnsureVersionedPluginResourceCache(t *testing.T) {
+	var (
+		cacheDir = util.FullPath("./tests/resource-cache/cache")
+		dir      = "./tests/resource-cache/server"
+		meta     = versioning.DependencyMeta{User: "sampctl-test-user", Repo: "resource-cache-test", Tag: "1.0.0"}
+	)
+	os.RemoveAll("./tests/resource-cache")
+	assert.NoError(t, os.MkdirAll(filepath.Join(cacheDir, "resources"), 0700))
+
+	archive := filepath.Join(cacheDir, "resources", "cached-1.0.0.tar.gz")
+	writeTestArchive(t, archive, map[string]int64{"plugins/cached.so": 0644})
+	_, err := download.StoreResource(cacheDir, archive, download.ResourceEntry{
+		URL:        "https://example.com/cached-1.0.0.tar.gz",
+		Name:       "cached-1.0.0.tar.gz",
+		Dependency: "sampctl-test-user/resource-cache-test",
+		Tag:        "1.0.0",
+		Platform:   "linux",
+		Resource: &types.Resource{
+			Name:     "^cached-(.*).tar.gz$",
+			Platform: "linux",
+			Archive:  true,
+			Plugins:  []string{"plugins/cached.so"},
+		},
+	})
+	assert.NoError(t, err)
+
+	// a tagged release in the resource cache needs no package definition or network
+	files, err := EnsureVersionedPlugin(context.Background(), gh, meta, dir, "linux", cacheDir, true, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Plugin{"cached.so"}, files)
+
+	// without a tag, the newest release is looked up but the cached one is used if that fails
+	os.RemoveAll(dir)
+	meta.Tag = ""
+	files, err = EnsureVersionedPlugin(context.Background(), gh, meta, dir, "linux", cacheDir, true, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Plugin{"cached.so"}, files)
+	assert.True(t, util.Exists(filepath.Join(dir, "plugins", "cached.so")))
+
+	_, err = EnsureVersionedPlugin(context.Background(), gh, meta, dir, "windows", cacheDir, true, false, false)
+	assert.Error(t, err)
+}
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		glob  string
+		name  string
+		match bool
+	}{
+		{"data/**/*.json", "data/a.json", true},
+		{"data/**/*.json", "data/x/y/a.json", true},
+		{"data/**/*.json", "other/a.json", false},
+		{"data/*.json", "data/x/a.json", false},
+		{"data/?.json", "data/a.json", true},
+		{"data/?.json", "data/ab.json", false},
+		{"**", "anything/at/all", true},
+		{"a+b.txt", "a+b.txt", true},
+		{"a+b.txt", "aab.txt", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.match, GlobRegexp(tt.glob).MatchString(tt.name), tt.glob+" "+tt.name)
+	}
+}
+
+func writeTestArchive(t *testing.T, path string, files map[string]int64) {
+	f, err := os.Create(path)
+	assert.NoError(t, err)
+	defer f.Close() // nolint
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for name, mode := range files {
+		contents := []byte(name)
+		assert.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     mode,
+			Size:     int64(len(contents)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err = tw.Write(contents)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gz.Close())
+}
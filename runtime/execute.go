@@ -1,6 +1,7 @@
 package runtime
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 
@@ -10,7 +11,7 @@ import (
 )
 
 // PrepareRuntimeDirectory sets up a directory in ~/.samp that contains the server runtime
-func PrepareRuntimeDirectory(cacheDir, version, platform, scriptfiles string) (err error) {
+func PrepareRuntimeDirectory(ctx context.Context, cacheDir, version, platform, scriptfiles string) (err error) {
 	dir := GetRuntimePath(cacheDir, version)
 
 	err = os.MkdirAll(dir, 0700)
@@ -18,7 +19,7 @@ func PrepareRuntimeDirectory(cacheDir, version, platform, scriptfiles string) (e
 		return errors.Wrap(err, "failed to create temporary directory")
 	}
 
-	err = GetServerPackage(version, dir, platform)
+	err = GetServerPackage(ctx, version, dir, platform)
 	if err != nil {
 		return errors.Wrap(err, "failed to get server package")
 	}
@@ -0,0 +1,135 @@
+package runtime
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+)
+
+// defaultHeadlessDuration is how long a headless run lasts if the test config doesn't say
+const defaultHeadlessDuration = time.Minute
+
+// headless tracks the server output of a headless run against its success and failure patterns
+type headless struct {
+	success  []*regexp.Regexp
+	seen     []bool
+	failure  []*regexp.Regexp
+	duration time.Duration
+}
+
+func newHeadless(cfg *types.HeadlessTest) (h *headless, err error) {
+	h = &headless{duration: defaultHeadlessDuration}
+	if cfg == nil {
+		return
+	}
+
+	for _, pattern := range cfg.Success {
+		var re *regexp.Regexp
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid success pattern '%s'", pattern)
+		}
+		h.success = append(h.success, re)
+	}
+	h.seen = make([]bool, len(h.success))
+
+	for _, pattern := range cfg.Failure {
+		var re *regexp.Regexp
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid failure pattern '%s'", pattern)
+		}
+		h.failure = append(h.failure, re)
+	}
+
+	if cfg.Duration != "" {
+		h.duration, err = time.ParseDuration(cfg.Duration)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid test duration '%s'", cfg.Duration)
+		}
+		if h.duration <= 0 {
+			return nil, errors.Errorf("test duration must be positive, not %s", cfg.Duration)
+		}
+	}
+
+	return
+}
+
+// line checks a line of output, `done` is true once the run has passed or failed
+func (h *headless) line(line string) (done bool, err error) {
+	for _, re := range h.failure {
+		if re.MatchString(line) {
+			return true, errors.Errorf("failure pattern '%s' matched: %s", re, line)
+		}
+	}
+
+	if len(h.success) == 0 {
+		return false, nil
+	}
+	for i, re := range h.success {
+		if !h.seen[i] && re.MatchString(line) {
+			h.seen[i] = true
+		}
+	}
+	for _, seen := range h.seen {
+		if !seen {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// result decides the outcome of a run that ended without passing or failing, because either the
+// duration passed or the server exited. Without success patterns, surviving is a pass.
+func (h *headless) result() (err error) {
+	var missing []string
+	for i, re := range h.success {
+		if !h.seen[i] {
+			missing = append(missing, re.String())
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("success patterns never matched: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// stopGracefully asks the server to shut down so scripts get to run their exit callbacks, if it's
+// still running after a few seconds or can't be signalled (such as on Windows) it's killed.
+func stopGracefully(cmd *exec.Cmd) {
+	if cmd == nil || cmd.Process == nil {
+		print.Verb("not attempting to stop server: cmd.Process is nil")
+		return
+	}
+
+	err := cmd.Process.Signal(os.Interrupt)
+	if err == nil {
+		exited := make(chan struct{})
+		go func() {
+			cmd.Wait() // nolint
+			close(exited)
+		}()
+
+		select {
+		case <-exited:
+			print.Verb("server shut down after interrupt")
+			return
+		case <-time.After(5 * time.Second):
+			print.Warn("server did not shut down within 5 seconds of an interrupt")
+		}
+	} else {
+		print.Verb("failed to interrupt server:", err)
+	}
+
+	err = cmd.Process.Kill()
+	if err != nil {
+		print.Verb("failed to kill server:", err)
+	}
+}
@@ -0,0 +1,121 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestHeadless(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      *types.HeadlessTest
+		lines    []string
+		wantDone bool
+		wantErr  bool
+	}{
+		{"no config", nil, []string{"anything"}, false, false},
+		{"all success", &types.HeadlessTest{Success: []string{`^ready$`, `tests passed`}}, []string{"ready", "3 tests passed"}, true, false},
+		{"missing success", &types.HeadlessTest{Success: []string{`^ready$`, `tests passed`}}, []string{"ready"}, false, true},
+		{"failure", &types.HeadlessTest{Success: []string{`^ready$`}, Failure: []string{`Run time error`}}, []string{"[debug] Run time error 4"}, true, true},
+		{"failure before success", &types.HeadlessTest{Failure: []string{`crash`}}, []string{"ok", "crash"}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := newHeadless(tt.cfg)
+			assert.NoError(t, err)
+
+			var (
+				done    bool
+				errLine error
+			)
+			for _, line := range tt.lines {
+				done, errLine = h.line(line)
+				if done {
+					break
+				}
+			}
+			assert.Equal(t, tt.wantDone, done)
+
+			if !done {
+				errLine = h.result()
+			}
+			if tt.wantErr {
+				assert.Error(t, errLine)
+			} else {
+				assert.NoError(t, errLine)
+			}
+		})
+	}
+}
+
+func TestNewHeadlessInvalid(t *testing.T) {
+	_, err := newHeadless(&types.HeadlessTest{Success: []string{`(`}})
+	assert.Error(t, err)
+	_, err = newHeadless(&types.HeadlessTest{Duration: "soon"})
+	assert.Error(t, err)
+	_, err = newHeadless(&types.HeadlessTest{Duration: "-1s"})
+	assert.Error(t, err)
+
+	h, err := newHeadless(&types.HeadlessTest{Duration: "30s"})
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, h.duration)
+}
+
+func TestRunHeadless(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake server is a shell script")
+	}
+
+	dir := util.FullPath("./tests/headless")
+	os.RemoveAll(dir)
+	os.MkdirAll(dir, 0755) //nolint
+
+	binary := filepath.Join(dir, "samp03svr")
+	err := ioutil.WriteFile(binary, []byte("#!/bin/sh\necho 'Loaded 0 filterscripts.'\necho \"$MARKER\"\nexec sleep 30\n"), 0755)
+	if err != nil {
+		panic(err)
+	}
+
+	tests := []struct {
+		name    string
+		marker  string
+		cfg     *types.HeadlessTest
+		wantErr bool
+	}{
+		{"pass", "all tests passed", &types.HeadlessTest{Success: []string{`tests passed`}, Duration: "10s"}, false},
+		{"fail", "Run time error 4", &types.HeadlessTest{Success: []string{`tests passed`}, Failure: []string{`Run time error`}, Duration: "10s"}, true},
+		{"timeout", "nothing", &types.HeadlessTest{Success: []string{`tests passed`}, Duration: "500ms"}, true},
+		{"duration only", "nothing", &types.HeadlessTest{Duration: "500ms"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("MARKER", tt.marker)
+			defer os.Unsetenv("MARKER")
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+			defer cancel()
+
+			started := time.Now()
+			output := &bytes.Buffer{}
+			err := run(ctx, binary, types.Headless, false, nil, tt.cfg, nil, output, &bytes.Buffer{})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Contains(t, output.String(), tt.marker)
+			assert.True(t, time.Since(started) < 10*time.Second, "server should be stopped without waiting for it to exit")
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+// instanceDirs are the directories of the working directory that each instance gets a copy of
+var instanceDirs = []string{"gamemodes", "filterscripts", "npcmodes", "plugins", "models"}
+
+// InstancePath returns the working directory of an instance of a runtime
+func InstancePath(workingDir string, instance int) string {
+	return filepath.Join(workingDir, "instances", strconv.Itoa(instance))
+}
+
+// PrepareInstances creates a working directory for each instance of a runtime and returns a config
+// for each one with its own directory and port. The server binaries, scripts and plugins are linked
+// where possible since they are only read, scriptfiles are copied so each instance can write to its
+// own. Instance directories are recreated each time so every run starts from the same state.
+func PrepareInstances(cfg types.Runtime) (instances []types.Runtime, err error) {
+	base := 7777
+	if cfg.BasePort != nil {
+		base = *cfg.BasePort
+	} else if cfg.Port != nil {
+		base = *cfg.Port
+	}
+
+	for i := 0; i < cfg.Instances; i++ {
+		dir := InstancePath(cfg.WorkingDir, i)
+		print.Verb("preparing instance", i, "in", dir)
+
+		err = os.RemoveAll(dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to remove instance %d directory", i)
+		}
+		err = os.MkdirAll(dir, 0700)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create instance %d directory", i)
+		}
+
+		for _, binary := range []string{getServerBinary(cfg.Platform), getNpcBinary(cfg.Platform), getAnnounceBinary(cfg.Platform)} {
+			source := filepath.Join(cfg.WorkingDir, binary)
+			if !util.Exists(source) {
+				continue
+			}
+			err = util.CopyFile(source, filepath.Join(dir, binary))
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to copy %s to instance %d", binary, i)
+			}
+		}
+		for _, name := range instanceDirs {
+			err = copyTree(filepath.Join(cfg.WorkingDir, name), filepath.Join(dir, name), true)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to copy %s to instance %d", name, i)
+			}
+		}
+		err = copyTree(filepath.Join(cfg.WorkingDir, "scriptfiles"), filepath.Join(dir, "scriptfiles"), false)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to copy scriptfiles to instance %d", i)
+		}
+
+		instance := cfg
+		instance.WorkingDir = dir
+		instance.Instances = 0
+		instance.Port = &[]int{base + i}[0]
+		instance.Plugins = append([]types.Plugin{}, cfg.Plugins...)
+		err = GenerateServerCfg(&instance)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to generate server.cfg for instance %d", i)
+		}
+		instances = append(instances, instance)
+	}
+	return
+}
+
+// runInstances runs each instance of a runtime at the same time with its output prefixed by its
+// number. Only the first instance receives input. If any instance fails, the others are stopped.
+func runInstances(ctx context.Context, cfg types.Runtime, recover bool, output io.Writer, input io.Reader) (err error) {
+	instances, err := PrepareInstances(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to prepare instances")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		outputMu sync.Mutex
+	)
+	for i, instance := range instances {
+		wg.Add(1)
+		go func(i int, instance types.Runtime) {
+			defer wg.Done()
+
+			prefixed := &prefixWriter{w: output, mu: &outputMu, prefix: fmt.Sprintf("[%d] ", i)}
+			in := input
+			if i > 0 {
+				in = strings.NewReader("")
+			}
+
+			binary := filepath.Join(instance.WorkingDir, getServerBinary(instance.Platform))
+			print.Verb("starting instance", i, "on port", *instance.Port)
+			errRun := run(ctx, binary, instance.Mode, recover, instance.Debugger, instance.Test, instance.Coverage, prefixed, in)
+			prefixed.Flush()
+			if errRun == nil {
+				return
+			}
+
+			mu.Lock()
+			if err == nil {
+				err = errors.Wrapf(errRun, "instance %d failed", i)
+			}
+			mu.Unlock()
+			cancel()
+		}(i, instance)
+	}
+	wg.Wait()
+	return
+}
+
+// prefixWriter writes each line with a prefix, lines from writers that share a mutex are never
+// interleaved
+type prefixWriter struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	prefix string
+	buf    bytes.Buffer
+}
+
+func (pw *prefixWriter) Write(p []byte) (n int, err error) {
+	pw.buf.Write(p)
+	for {
+		line := pw.buf.Bytes()
+		end := bytes.IndexByte(line, '\n')
+		if end == -1 {
+			break
+		}
+		err = pw.writeLine(line[:end+1])
+		pw.buf.Next(end + 1)
+		if err != nil {
+			return
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes any incomplete line left in the buffer
+func (pw *prefixWriter) Flush() {
+	if pw.buf.Len() == 0 {
+		return
+	}
+	pw.writeLine(append(pw.buf.Bytes(), '\n')) // nolint
+	pw.buf.Reset()
+}
+
+func (pw *prefixWriter) writeLine(line []byte) (err error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	_, err = io.WriteString(pw.w, pw.prefix)
+	if err == nil {
+		_, err = pw.w.Write(line)
+	}
+	return
+}
+
+// copyTree copies a directory, following it if it is a symlink, and does nothing if it doesn't
+// exist. Files are hard linked where possible if `link` is set, otherwise their contents are copied.
+func copyTree(src, dst string, link bool) (err error) {
+	src, err = filepath.EvalSymlinks(src)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return
+	}
+	return filepath.Walk(src, func(path string, info os.FileInfo, errWalk error) (err error) {
+		if errWalk != nil {
+			return errWalk
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0700)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if link {
+			return util.CopyFile(path, target)
+		}
+		return copyContents(path, target, info.Mode())
+	})
+}
+
+func copyContents(src, dst string, mode os.FileMode) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close() // nolint
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(out, in)
+	if errClose := out.Close(); err == nil {
+		err = errClose
+	}
+	return
+}
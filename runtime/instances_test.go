@@ -0,0 +1,94 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+func TestRunInstances(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake server is a shell script")
+	}
+
+	dir := util.FullPath("./tests/instances")
+	os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "gamemodes"), 0755)   //nolint
+	os.MkdirAll(filepath.Join(dir, "scriptfiles"), 0755) //nolint
+
+	err := ioutil.WriteFile(filepath.Join(dir, "samp03svr"), []byte("#!/bin/sh\necho \"$(grep '^port' server.cfg)\"\necho written >> scriptfiles/data.txt\necho ready\nexec sleep 30\n"), 0755)
+	if err != nil {
+		panic(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "gamemodes", "test.amx"), []byte("amx"), 0644)
+	if err != nil {
+		panic(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "scriptfiles", "data.txt"), []byte("original\n"), 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	cfg := types.Runtime{
+		WorkingDir:   dir,
+		Platform:     runtime.GOOS,
+		Format:       "json",
+		Version:      "0.3.7",
+		Mode:         types.Headless,
+		Test:         &types.HeadlessTest{Success: []string{`^ready$`}, Duration: "10s"},
+		Instances:    2,
+		BasePort:     &[]int{9000}[0],
+		Port:         &[]int{7777}[0],
+		RCONPassword: &[]string{"password"}[0],
+		Gamemodes:    []string{"test"},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	output := &bytes.Buffer{}
+	err = Run(ctx, cfg, "", false, false, output, &bytes.Buffer{})
+	assert.NoError(t, err)
+	assert.Contains(t, output.String(), "[0] port 9000\n")
+	assert.Contains(t, output.String(), "[1] port 9001\n")
+	assert.Contains(t, output.String(), "[1] ready\n")
+
+	for i := 0; i < 2; i++ {
+		assert.True(t, util.Exists(filepath.Join(InstancePath(dir, i), "gamemodes", "test.amx")))
+		contents, errRead := ioutil.ReadFile(filepath.Join(InstancePath(dir, i), "scriptfiles", "data.txt"))
+		assert.NoError(t, errRead)
+		assert.Equal(t, "original\nwritten\n", string(contents))
+	}
+
+	// instances write to their own scriptfiles
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "scriptfiles", "data.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "original\n", string(contents))
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var (
+		output bytes.Buffer
+		mu     sync.Mutex
+	)
+	a := &prefixWriter{w: &output, mu: &mu, prefix: "[0] "}
+	b := &prefixWriter{w: &output, mu: &mu, prefix: "[1] "}
+
+	a.Write([]byte("first "))  // nolint
+	b.Write([]byte("one\ntw")) // nolint
+	a.Write([]byte("line\n"))  // nolint
+	b.Flush()
+
+	assert.Equal(t, "[1] one\n[0] first line\n[1] tw\n", output.String())
+}
@@ -2,6 +2,7 @@ package runtime
 
 import (
 	"os"
+	"path/filepath"
 	"runtime"
 	"testing"
 
@@ -144,3 +145,42 @@ func TestEnsureScripts(t *testing.T) {
 		})
 	}
 }
+
+func TestEnsureRequiredPlugins(t *testing.T) {
+	os.MkdirAll("./tests/required-plugins/plugins", 0700) //nolint
+	for _, name := range []string{"streamer.so", "crashdetect.so"} {
+		f, err := os.Create(filepath.Join("./tests/required-plugins/plugins", name))
+		if err != nil {
+			panic(err)
+		}
+		f.Close() // nolint
+	}
+
+	tests := []struct {
+		name        string
+		plugins     []types.Plugin
+		required    []string
+		wantMissing string
+	}{
+		{"none", []types.Plugin{"streamer"}, nil, ""},
+		{"present", []types.Plugin{"streamer", "crashdetect.so"}, []string{"Streamer", "crashdetect"}, ""},
+		{"not listed", []types.Plugin{"streamer"}, []string{"streamer", "crashdetect"}, "crashdetect"},
+		{"no binary", []types.Plugin{"streamer", "mysql"}, []string{"mysql", "sscanf"}, "mysql, sscanf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := EnsureRequiredPlugins(types.Runtime{
+				WorkingDir:      "./tests/required-plugins",
+				Platform:        "linux",
+				Plugins:         tt.plugins,
+				RequiredPlugins: tt.required,
+			})
+			if tt.wantMissing == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), ": "+tt.wantMissing)
+			}
+		})
+	}
+}
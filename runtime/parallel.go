@@ -0,0 +1,78 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Southclaws/sampctl/events"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// resourceConcurrency is how many resources are downloaded at the same time
+var resourceConcurrency = 4
+
+// SetResourceConcurrency sets how many resources of different dependencies are downloaded at the
+// same time, one downloads them in order
+func SetResourceConcurrency(concurrency int) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	resourceConcurrency = concurrency
+}
+
+// forEachResource calls fn for each dependency, at most `resourceConcurrency` at a time, and reports
+// how many are done as each one finishes. If fn fails for any of them, the context of the others is
+// cancelled, no more are started and the first error is returned.
+func forEachResource(ctx context.Context, deps []versioning.DependencyMeta, fn func(ctx context.Context, i int, meta versioning.DependencyMeta) error) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done int
+		pool = make(chan struct{}, resourceConcurrency)
+	)
+	for i, meta := range deps {
+		select {
+		case pool <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, meta versioning.DependencyMeta) {
+			// the slot is freed after a failure cancels the others, so no more are started
+			defer func() {
+				<-pool
+				wg.Done()
+			}()
+			errInner := fn(ctx, i, meta)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if errInner != nil {
+				if err == nil {
+					err = errInner
+					cancel()
+				}
+				return
+			}
+			done++
+			if len(deps) > 1 {
+				print.Info(fmt.Sprintf("[%d/%d]", done, len(deps)), meta, "resources ready")
+			}
+			events.Publish(ctx, events.ResourceEnsured{Dependency: meta, Done: done, Total: len(deps)})
+		}(i, meta)
+	}
+	wg.Wait()
+
+	if err == nil {
+		err = ctx.Err()
+	}
+	return
+}
@@ -0,0 +1,88 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/events"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func Test_forEachResource(t *testing.T) {
+	deps := []versioning.DependencyMeta{
+		{User: "a", Repo: "1"}, {User: "a", Repo: "2"}, {User: "a", Repo: "3"},
+		{User: "a", Repo: "4"}, {User: "a", Repo: "5"}, {User: "a", Repo: "6"},
+	}
+
+	SetResourceConcurrency(2)
+	defer SetResourceConcurrency(4)
+
+	t.Run("bounded", func(t *testing.T) {
+		var (
+			mu      sync.Mutex
+			running int
+			most    int
+			visited = make([]bool, len(deps))
+			ready   []events.ResourceEnsured
+		)
+		bus := events.NewBus()
+		bus.Subscribe(func(e events.Event) {
+			if r, ok := e.(events.ResourceEnsured); ok {
+				ready = append(ready, r)
+			}
+		})
+
+		err := forEachResource(events.WithBus(context.Background(), bus), deps, func(ctx context.Context, i int, meta versioning.DependencyMeta) error {
+			mu.Lock()
+			running++
+			if running > most {
+				most = running
+			}
+			visited[i] = true
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, most)
+		assert.Equal(t, []bool{true, true, true, true, true, true}, visited)
+		assert.Len(t, ready, len(deps))
+		assert.Equal(t, len(deps), ready[len(ready)-1].Done)
+		assert.Equal(t, len(deps), ready[len(ready)-1].Total)
+	})
+
+	t.Run("failure cancels the rest", func(t *testing.T) {
+		var (
+			mu      sync.Mutex
+			started int
+		)
+		failed := errors.New("checksum mismatch")
+		err := forEachResource(context.Background(), deps, func(ctx context.Context, i int, meta versioning.DependencyMeta) error {
+			mu.Lock()
+			started++
+			mu.Unlock()
+			if i == 0 {
+				return failed
+			}
+			// the other download in progress stops once it's cancelled
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+				return nil
+			}
+		})
+		assert.Equal(t, failed, err)
+		assert.True(t, started < len(deps), "started %d", started)
+	})
+}
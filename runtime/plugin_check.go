@@ -0,0 +1,142 @@
+package runtime
+
+import (
+	"debug/elf"
+	"debug/pe"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+// libmysqlclient.so.18 => not found
+var matchMissingLibrary = regexp.MustCompile(`^\s*(\S+)\s+=>\s+not found`)
+
+// PluginProblem is something about a plugin binary that stops the server from loading it
+type PluginProblem struct {
+	Plugin  string
+	Problem string
+}
+
+func (pp PluginProblem) String() string {
+	return fmt.Sprintf("%s %s", pp.Plugin, pp.Problem)
+}
+
+// CheckPlugins checks that the binary of each plugin is one the server can load before it's started,
+// since the server only reports that a plugin failed to load without saying why. Each binary must be
+// a library for the platform built for the same architecture as the server and, when running Linux
+// plugins directly on Linux, the shared libraries it depends on must be installed.
+func CheckPlugins(cfg types.Runtime) (err error) {
+	libraries := runtime.GOOS == "linux" && cfg.Platform == "linux" && cfg.Container == nil
+	problems := checkPlugins(cfg, libraries)
+	if len(problems) == 0 {
+		return
+	}
+
+	lines := make([]string, len(problems))
+	for i, problem := range problems {
+		lines[i] = problem.String()
+	}
+	return errors.Errorf("%d plugins can't be loaded by the server:\n%s", len(problems), strings.Join(lines, "\n"))
+}
+
+func checkPlugins(cfg types.Runtime, libraries bool) (problems []PluginProblem) {
+	ext := pluginExtForFile(cfg.Platform)
+
+	server, err := binaryArch(filepath.Join(cfg.WorkingDir, getServerBinary(cfg.Platform)), cfg.Platform)
+	if err != nil {
+		print.Verb("can't check plugin architectures, failed to read server binary:", err)
+	}
+
+	for _, plugin := range cfg.Plugins {
+		name := strings.TrimSuffix(filepath.Base(string(plugin)), ext) + ext
+		path := filepath.Join(cfg.WorkingDir, "plugins", name)
+		if !util.Exists(path) {
+			// missing plugins are reported by `EnsureRequiredPlugins` if they're required
+			continue
+		}
+
+		arch, errArch := binaryArch(path, cfg.Platform)
+		if errArch != nil {
+			problems = append(problems, PluginProblem{name, fmt.Sprintf("is not a valid %s plugin: %s", cfg.Platform, errArch)})
+			continue
+		}
+		if server != "" && arch != server {
+			problems = append(problems, PluginProblem{name, fmt.Sprintf("is built for %s but the server is %s, download the build of the plugin for the server", arch, server)})
+			continue
+		}
+
+		if libraries {
+			missing, errLibs := missingLibraries(cfg.WorkingDir, path)
+			if errLibs != nil {
+				print.Verb(name, "failed to check shared libraries:", errLibs)
+			} else if len(missing) > 0 {
+				problems = append(problems, PluginProblem{name, fmt.Sprintf("needs shared libraries that aren't installed: %s", strings.Join(missing, ", "))})
+			}
+		}
+	}
+
+	return
+}
+
+// binaryArch describes the architecture of a binary in the executable format of the platform, an
+// ELF file on Linux and macOS, where the server is a Linux binary, and a PE file on Windows
+func binaryArch(path, platform string) (arch string, err error) {
+	switch platform {
+	case "windows":
+		var f *pe.File
+		f, err = pe.Open(path)
+		if err != nil {
+			return
+		}
+		defer f.Close() // nolint
+		switch f.Machine {
+		case pe.IMAGE_FILE_MACHINE_I386:
+			arch = "x86"
+		case pe.IMAGE_FILE_MACHINE_AMD64:
+			arch = "x86-64"
+		default:
+			arch = fmt.Sprintf("machine %#x", f.Machine)
+		}
+	case "linux", "darwin":
+		var f *elf.File
+		f, err = elf.Open(path)
+		if err != nil {
+			return
+		}
+		defer f.Close() // nolint
+		arch = fmt.Sprintf("%s %s", f.Class, f.Machine)
+	default:
+		err = errors.Errorf("unsupported platform %s", platform)
+	}
+	return
+}
+
+// missingLibraries lists the shared libraries that a binary depends on which the dynamic linker can't
+// find, according to `ldd` run from the directory the server runs in
+func missingLibraries(dir, path string) (missing []string, err error) {
+	ldd, err := exec.LookPath("ldd")
+	if err != nil {
+		return
+	}
+	cmd := exec.Command(ldd, path)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "ldd failed")
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if captures := matchMissingLibrary.FindStringSubmatch(line); captures != nil {
+			missing = append(missing, captures[1])
+		}
+	}
+	return
+}
@@ -0,0 +1,76 @@
+package runtime
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/types"
+)
+
+// elfHeader builds the header of a 32 bit little endian shared object without any sections, which
+// is all that's needed to tell its architecture
+func elfHeader(machine elf.Machine) []byte {
+	header := elf.Header32{
+		Type:      uint16(elf.ET_DYN),
+		Machine:   uint16(machine),
+		Version:   uint32(elf.EV_CURRENT),
+		Ehsize:    52,
+		Shentsize: 40,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS32)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	buf := bytes.Buffer{}
+	binary.Write(&buf, binary.LittleEndian, header) // nolint
+	return buf.Bytes()
+}
+
+func TestCheckPlugins(t *testing.T) {
+	dir := "./tests/plugin-check"
+	os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "plugins"), 0700)
+	ioutil.WriteFile(filepath.Join(dir, "samp03svr"), elfHeader(elf.EM_386), 0700)
+	ioutil.WriteFile(filepath.Join(dir, "plugins", "streamer.so"), elfHeader(elf.EM_386), 0700)
+	ioutil.WriteFile(filepath.Join(dir, "plugins", "arm.so"), elfHeader(elf.EM_ARM), 0700)
+	ioutil.WriteFile(filepath.Join(dir, "plugins", "broken.so"), []byte("404: Not Found"), 0700)
+
+	tests := []struct {
+		name    string
+		plugins []types.Plugin
+		want    []PluginProblem
+	}{
+		{"valid", []types.Plugin{"streamer", "missing"}, nil},
+		{"wrong architecture", []types.Plugin{"streamer", "arm.so"}, []PluginProblem{
+			{"arm.so", "is built for ELFCLASS32 EM_ARM but the server is ELFCLASS32 EM_386, download the build of the plugin for the server"},
+		}},
+		{"not a binary", []types.Plugin{"broken"}, []PluginProblem{
+			{"broken.so", "is not a valid linux plugin: "},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := types.Runtime{WorkingDir: dir, Platform: "linux", Plugins: tt.plugins}
+			got := checkPlugins(cfg, false)
+			if assert.Len(t, got, len(tt.want)) {
+				// the reason a binary is invalid comes from the standard library so only its start is checked
+				for i, problem := range tt.want {
+					assert.Equal(t, problem.Plugin, got[i].Plugin)
+					assert.True(t, strings.HasPrefix(got[i].Problem, problem.Problem), got[i].Problem)
+				}
+			}
+		})
+	}
+
+	err := CheckPlugins(types.Runtime{WorkingDir: dir, Platform: "windows", Plugins: []types.Plugin{"streamer.dll"}})
+	assert.NoError(t, err)
+}
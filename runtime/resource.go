@@ -95,6 +95,15 @@ func FindPackage(cacheDir, version string) (runtime types.RuntimePackage, err er
 	return findPackageRecursive(cacheDir, version, true)
 }
 
+// ResolveVersion returns the server version that a version, or an alias of one, refers to
+func ResolveVersion(cacheDir, version string) (resolved string, err error) {
+	pkg, err := FindPackage(cacheDir, version)
+	if err != nil {
+		return
+	}
+	return pkg.Version, nil
+}
+
 func findPackageRecursive(cacheDir, version string, aliases bool) (runtime types.RuntimePackage, err error) {
 	packages, err := download.GetRuntimeList(cacheDir)
 	if err != nil {
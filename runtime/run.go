@@ -10,12 +10,14 @@ import (
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/pkg/errors"
 
+	"github.com/Southclaws/sampctl/events"
 	"github.com/Southclaws/sampctl/print"
 	"github.com/Southclaws/sampctl/types"
 )
@@ -24,6 +26,7 @@ var (
 	matchPreamble = regexp.MustCompile(`Loaded [0-9]{1,2} filterscripts\.`)
 	matchMainEnd  = regexp.MustCompile(`Number of vehicle models\: [0-9]*`)
 	matchTestEnd  = regexp.MustCompile(`\*\*\* Tests: (\d+), Fails: (\d+)`)
+	matchCoverage = regexp.MustCompile(`\*\*\* Coverage: (.+)$`)
 )
 
 type testResults struct {
@@ -34,6 +37,12 @@ type testResults struct {
 // Run handles the actual running of the server process - it collects log output too
 func Run(ctx context.Context, cfg types.Runtime, cacheDir string, passArgs, recover bool, output io.Writer, input io.Reader) (err error) {
 	if cfg.Container != nil {
+		if cfg.Instances > 1 {
+			return errors.New("running multiple instances is not supported in a container")
+		}
+		if events.FromContext(ctx) != nil {
+			output = io.MultiWriter(output, events.LogWriter(ctx))
+		}
 		return RunContainer(ctx, cfg, cacheDir, passArgs, output, input)
 	}
 
@@ -41,11 +50,26 @@ func Run(ctx context.Context, cfg types.Runtime, cacheDir string, passArgs, reco
 	fullPath := filepath.Join(cfg.WorkingDir, binary)
 	print.Verb("starting", binary, "in", cfg.WorkingDir)
 
-	return run(ctx, fullPath, cfg.Mode, recover, output, input)
+	if cfg.Debugger != nil {
+		if cfg.Platform != "linux" {
+			return errors.Errorf("running the server under a debugger is only supported on linux, not %s", cfg.Platform)
+		}
+		if cfg.Debugger.Tool == "" {
+			return errors.New("debugger is missing a tool")
+		}
+		// a crash is exactly what the debugger is there to catch, restarting would lose it
+		recover = false
+	}
+
+	if cfg.Instances > 1 {
+		return runInstances(ctx, cfg, recover, output, input)
+	}
+
+	return run(ctx, fullPath, cfg.Mode, recover, cfg.Debugger, cfg.Test, cfg.Coverage, output, input)
 }
 
 // nolint:gocyclo
-func run(ctx context.Context, binary string, runType types.RunMode, recover bool, output io.Writer, input io.Reader) (err error) {
+func run(ctx context.Context, binary string, runType types.RunMode, recover bool, debugger *types.Debugger, testConfig *types.HeadlessTest, functions []types.CoverageFunction, output io.Writer, input io.Reader) (err error) {
 	// termination is an internal instruction for communicating successful or failed runs.
 	// It contains an error and a boolean to indicate whether or not to terminate the process.
 	type termination struct {
@@ -53,6 +77,14 @@ func run(ctx context.Context, binary string, runType types.RunMode, recover bool
 		exit bool
 	}
 
+	var test *headless
+	if runType == types.Headless {
+		test, err = newHeadless(testConfig)
+		if err != nil {
+			return errors.Wrap(err, "invalid test config")
+		}
+	}
+
 	outputReader, outputWriter := io.Pipe()
 	streamChan := make(chan string)    // channel for lines of output text
 	errChan := make(chan termination)  // channel for sending runtime errors to watchdog
@@ -130,6 +162,13 @@ func run(ctx context.Context, binary string, runType types.RunMode, recover bool
 				}
 			}
 		}()
+	case types.Headless:
+		go func() {
+			scanner := bufio.NewScanner(outputReader)
+			for scanner.Scan() {
+				streamChan <- scanner.Text()
+			}
+		}()
 	default:
 		runType = types.Server // set default for later use
 		go func() {
@@ -150,7 +189,17 @@ func run(ctx context.Context, binary string, runType types.RunMode, recover bool
 			exponentialBackoff = time.Second // exponential backoff cooldown
 		)
 		for {
-			cmd = exec.CommandContext(ctx, binary) //nolint:gas
+			if debugger != nil {
+				absolute, errAbs := filepath.Abs(binary)
+				if errAbs != nil {
+					absolute = binary
+				}
+				name, args := debugger.Command(absolute)
+				print.Verb("running server under", name, args)
+				cmd = exec.CommandContext(ctx, name, args...) //nolint:gas
+			} else {
+				cmd = exec.CommandContext(ctx, binary) //nolint:gas
+			}
 			cmd.Dir = filepath.Dir(binary)
 
 			startTime = time.Now()
@@ -192,26 +241,60 @@ func run(ctx context.Context, binary string, runType types.RunMode, recover bool
 
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	var term termination
+	var (
+		term     termination
+		coverage = make(map[string]int) // hits of the coverage markers of builds that have them
+		deadline <-chan time.Time       // the end of a headless run
+	)
+	if test != nil {
+		deadline = time.After(test.duration)
+	}
 loop:
 	for {
 		select {
 		case line := <-streamChan:
+			if name, ok := coverageHit(line); ok {
+				coverage[name]++
+				continue
+			}
 			fmt.Fprintln(output, line)
+			events.Publish(ctx, events.ServerLog{Line: line})
+
+			if test != nil {
+				if done, errTest := test.line(line); done {
+					term = termination{errTest, true}
+					break loop
+				}
+			}
+
+		case <-deadline:
+			print.Verb("headless run finished")
+			term = termination{test.result(), true}
+			break loop
 
 		case s := <-sigChan:
 			term.err = errors.Errorf("received signal: %v", s)
 			break loop
 
 		case term = <-errChan:
-			break loop
+			if test == nil || term.err != nil {
+				break loop
+			}
+			// the server exited by itself, decide the result once the rest of its output is read
+			deadline = time.After(100 * time.Millisecond)
 		}
 	}
 	print.Verb("finished server execution with:", term)
 
+	if len(coverage) > 0 || len(functions) > 0 {
+		reportCoverage(coverage, functions)
+	}
+
 	err = errors.Wrap(term.err, "received runtime error")
 
-	if term.exit {
+	if term.exit && runType == types.Headless {
+		stopGracefully(cmd)
+	} else if term.exit {
 		if cmd.Process != nil {
 			killErr := cmd.Process.Kill()
 			if killErr != nil {
@@ -228,6 +311,55 @@ loop:
 	return err
 }
 
+// coverageHit returns the name passed to a `COVERAGE_HIT` marker if the line was printed by one
+func coverageHit(line string) (name string, ok bool) {
+	match := matchCoverage.FindStringSubmatch(line)
+	if len(match) != 2 {
+		return "", false
+	}
+	return match[1], true
+}
+
+// reportCoverage prints how many times each instrumented function was hit, including the ones that
+// never were, followed by any other coverage markers in order of name
+func reportCoverage(coverage map[string]int, functions []types.CoverageFunction) {
+	if len(functions) > 0 {
+		hit := 0
+		for _, function := range functions {
+			if coverage[function.Name] > 0 {
+				hit++
+			}
+		}
+		print.Info(hit, "of", len(functions), "instrumented functions hit:")
+	} else {
+		print.Info(len(coverage), "coverage markers hit:")
+	}
+	for _, line := range coverageReport(coverage, functions) {
+		print.Info(" ", line)
+	}
+}
+
+func coverageReport(coverage map[string]int, functions []types.CoverageFunction) (lines []string) {
+	instrumented := make(map[string]bool)
+	for _, function := range functions {
+		instrumented[function.Name] = true
+		lines = append(lines, fmt.Sprintf("%s (%s:%d) - %d hits", function.Name, function.File, function.Line, coverage[function.Name]))
+	}
+
+	names := make([]string, 0, len(coverage))
+	for name := range coverage {
+		if !instrumented[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("%s - %d hits", name, coverage[name]))
+	}
+	return
+}
+
 func testResultsFromLine(line string) (results testResults) {
 	match := matchTestEnd.FindStringSubmatch(line)
 	results.Tests, _ = strconv.Atoi(match[1])
@@ -92,3 +92,34 @@ Number of vehicle models: 0
 		})
 	}
 }
+
+func Test_coverageHit(t *testing.T) {
+	name, ok := coverageHit("*** Coverage: Library_Function")
+	assert.True(t, ok)
+	assert.Equal(t, "Library_Function", name)
+
+	name, ok = coverageHit("[12:00:00] *** Coverage: Timestamped")
+	assert.True(t, ok)
+	assert.Equal(t, "Timestamped", name)
+
+	_, ok = coverageHit("*** Tests: 1, Fails: 0")
+	assert.False(t, ok)
+}
+
+func Test_coverageReport(t *testing.T) {
+	assert.Equal(t, []string{
+		"Alpha - 1 hits",
+		"Beta - 3 hits",
+	}, coverageReport(map[string]int{"Beta": 3, "Alpha": 1}, nil))
+	assert.Empty(t, coverageReport(nil, nil))
+
+	// instrumented functions are listed in order even if they weren't hit, other markers follow
+	assert.Equal(t, []string{
+		"Zeta (main.pwn:3) - 2 hits",
+		"Alpha (main.pwn:9) - 0 hits",
+		"Manual - 1 hits",
+	}, coverageReport(map[string]int{"Zeta": 2, "Manual": 1}, []types.CoverageFunction{
+		{Name: "Zeta", File: "main.pwn", Line: 3},
+		{Name: "Alpha", File: "main.pwn", Line: 9},
+	}))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"gopkg.in/segmentio/analytics-go.v3"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/daemon"
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/util"
+)
+
+var serveFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "addr",
+		Value: "127.0.0.1:7878",
+		Usage: "address to serve the API on, only expose this to the local machine",
+	},
+	cli.StringFlag{
+		Name:  "token-file",
+		Value: filepath.Join(".sampctl", "daemon-token"),
+		Usage: "file to write the token that requests must be authorised with to, it's removed when the server stops",
+	},
+}
+
+func serve(c *cli.Context) error {
+	if c.Bool("verbose") {
+		print.SetVerbose()
+	}
+
+	if config.Metrics {
+		segment.Enqueue(analytics.Track{
+			Event:  "serve",
+			UserId: config.UserID,
+		})
+	}
+
+	cacheDir, err := download.GetCacheDir()
+	if err != nil {
+		print.Erro("Failed to retrieve cache directory path (attempted <user folder>/.samp) ")
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		print.Info("signal received", sig, "stopping API server...")
+		cancel()
+	}()
+
+	server, err := daemon.New(gh, gitAuth, platform(c), cacheDir)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	// editors read the token from the file, or from the output of the command that started the server
+	tokenFile := util.FullPath(c.String("token-file"))
+	err = os.MkdirAll(filepath.Dir(tokenFile), 0700)
+	if err != nil {
+		return cli.NewExitError(errors.Wrap(err, "failed to create token file directory").Error(), 1)
+	}
+	err = ioutil.WriteFile(tokenFile, []byte(server.Token), 0600)
+	if err != nil {
+		return cli.NewExitError(errors.Wrap(err, "failed to write token file").Error(), 1)
+	}
+	defer os.Remove(tokenFile) // nolint
+	print.Info("API token:", server.Token, "written to", tokenFile)
+
+	err = server.ListenAndServe(ctx, c.String("addr"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	return nil
+}
@@ -41,5 +41,8 @@ func serverDownload(c *cli.Context) error {
 		})
 	}
 
-	return runtime.GetServerPackage(version, dir, appRuntime.GOOS)
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	return runtime.GetServerPackage(ctx, version, dir, appRuntime.GOOS)
 }
@@ -47,7 +47,10 @@ func serverInit(c *cli.Context) error {
 		return errors.Wrap(err, "failed to initialise server")
 	}
 
-	err = runtime.GetServerPackage(version, dir, appRuntime.GOOS)
+	ctx, cancel := timeout(c, 0)
+	defer cancel()
+
+	err = runtime.GetServerPackage(ctx, version, dir, appRuntime.GOOS)
 	if err != nil {
 		return errors.Wrap(err, "failed to get package")
 	}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/minio/go-homedir"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/compiler"
+	"github.com/Southclaws/sampctl/download"
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/rook"
+	"github.com/Southclaws/sampctl/runtime"
+	"github.com/Southclaws/sampctl/types"
+	"github.com/Southclaws/sampctl/util"
+)
+
+// withSettings wraps the actions of commands so the settings are applied before each one runs, the
+// project settings depend on the `--dir` of the command so they can't be applied any earlier
+func withSettings(commands []cli.Command) {
+	for i := range commands {
+		withSettings(commands[i].Subcommands)
+
+		action, ok := commands[i].Action.(func(*cli.Context) error)
+		if !ok {
+			continue
+		}
+		commands[i].Action = func(c *cli.Context) error {
+			// the global flags are repeated on every command and when they're given after the command
+			// name, only the command sees them, not the `Before` of the app
+			if c.Bool("quiet") {
+				print.SetQuiet()
+			}
+			if err := configure(c); err != nil {
+				return err
+			}
+			return action(c)
+		}
+	}
+}
+
+// configure loads the settings for a command and applies them. Flags that weren't given on the
+// command line take their value from the environment, then the project settings and then the
+// global settings, and if none of those set it, the flag keeps its built-in default.
+func configure(c *cli.Context) (err error) {
+	dir := c.String("dir")
+	if dir == "" {
+		dir = "."
+	}
+	settings, err := types.LoadSettings(util.FullPath(dir))
+	if err != nil {
+		return errors.Wrap(err, "failed to load settings")
+	}
+
+	for _, flag := range c.Command.Flags {
+		names := strings.Split(flag.GetName(), ",")
+		set := false
+		for i := range names {
+			names[i] = strings.TrimSpace(names[i])
+			set = set || c.IsSet(names[i])
+		}
+		if set {
+			continue
+		}
+
+		name := names[0]
+		value, ok := types.FlagFromEnv(name)
+		if !ok {
+			value, ok = settings.Flags[name]
+		}
+		if !ok {
+			continue
+		}
+		err = c.Set(name, value)
+		if err != nil {
+			return errors.Wrapf(err, "invalid default '%s' for flag --%s", value, name)
+		}
+	}
+
+	// unlike the settings, the flag can be given as false to turn read-only vendoring off
+	if c.IsSet("read-only-vendor") {
+		readOnly := c.Bool("read-only-vendor")
+		settings.ReadOnlyVendor = &readOnly
+	} else if c.GlobalIsSet("read-only-vendor") {
+		readOnly := c.GlobalBool("read-only-vendor")
+		settings.ReadOnlyVendor = &readOnly
+	}
+
+	return setup(settings)
+}
+
+// setup configures the clients and download sources from the settings, the user configuration in
+// the cache directory provides anything the settings don't
+func setup(settings types.Settings) (err error) {
+	merged := types.Settings{
+		GitHubToken:      config.GitHubToken,
+		GitUsername:      config.GitUsername,
+		GitPassword:      config.GitPassword,
+		CompilerMirrors:  config.CompilerMirrors,
+		CompilerAttempts: config.CompilerAttempts,
+		Registry:         config.Registry,
+		Index:            config.Index,
+	}
+	merged.Merge(settings)
+
+	if merged.CacheDir != "" {
+		var cacheDir string
+		cacheDir, err = homedir.Expand(merged.CacheDir)
+		if err != nil {
+			return errors.Wrap(err, "failed to expand cache directory")
+		}
+		download.SetCacheDir(util.FullPath(cacheDir))
+	}
+
+	if merged.TempDir != "" {
+		var tempDir string
+		tempDir, err = homedir.Expand(merged.TempDir)
+		if err != nil {
+			return errors.Wrap(err, "failed to expand temporary directory")
+		}
+		err = util.SetTempDir(util.FullPath(tempDir))
+		if err != nil {
+			return errors.Wrap(err, "failed to set temporary directory")
+		}
+	}
+
+	if merged.CompilerAttempts == 0 {
+		merged.CompilerAttempts = 3
+	}
+	compiler.SetSources(compiler.Sources{
+		Mirrors:   merged.CompilerMirrors,
+		Checksums: config.CompilerChecksums,
+		Attempts:  merged.CompilerAttempts,
+	})
+
+	err = rook.SetRegistry(merged.Registry)
+	if err != nil {
+		return errors.Wrap(err, "failed to configure package registry")
+	}
+
+	err = rook.SetIndex(merged.Index)
+	if err != nil {
+		return errors.Wrap(err, "failed to configure package index")
+	}
+
+	err = rook.SetCloneProtocols(merged.CloneProtocol, merged.CloneProtocols)
+	if err != nil {
+		return errors.Wrap(err, "failed to configure clone protocol")
+	}
+
+	rook.SetReadOnlyVendor(merged.ReadOnlyVendor != nil && *merged.ReadOnlyVendor)
+
+	if merged.ResourceConcurrency == 0 {
+		merged.ResourceConcurrency = 4
+	}
+	runtime.SetResourceConcurrency(merged.ResourceConcurrency)
+
+	if merged.GitHubToken == "" {
+		gh = github.NewClient(nil)
+	} else {
+		gh = github.NewClient(oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: merged.GitHubToken})))
+	}
+
+	if merged.GitUsername != "" && merged.GitPassword != "" {
+		gitAuth = http.NewBasicAuth(merged.GitUsername, merged.GitPassword)
+	} else {
+		gitAuth, err = ssh.DefaultAuthBuilder("git")
+		if err != nil {
+			print.Verb("Failed to set up SSH:", err)
+			err = nil
+		}
+	}
+	return
+}
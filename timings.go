@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/Southclaws/sampctl/events"
+	"github.com/Southclaws/sampctl/print"
+)
+
+var timingsFlag = cli.StringFlag{
+	Name:  "timings",
+	Value: "",
+	Usage: "prints how long each dependency, resource and compile took once the command finishes, as a `table` or `json`",
+}
+
+// collectTimings subscribes a collector to the event bus if the `--timings` flag is set, the
+// returned function prints the summary and unsubscribes it, so defer it once the flag is checked.
+func collectTimings(c *cli.Context) (summarise func(), err error) {
+	format := c.String("timings")
+	if format == "" {
+		return func() {}, nil
+	}
+	if format != "table" && format != "json" {
+		return nil, errors.Errorf("unknown timings format %s, must be one of [table json]", format)
+	}
+
+	timings := &events.Timings{}
+	unsubscribe := bus.Subscribe(timings.Collect)
+	return func() {
+		unsubscribe()
+		var errWrite error
+		if format == "json" {
+			errWrite = timings.WriteJSON(os.Stdout)
+		} else {
+			errWrite = timings.WriteTable(os.Stdout)
+		}
+		if errWrite != nil {
+			print.Erro("Failed to write timings:", errWrite)
+		}
+	}, nil
+}
@@ -1,18 +1,77 @@
 package types
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/versioning"
+)
 
 // BuildConfig represents a configuration for compiling a file
 type BuildConfig struct {
-	Name       string            `json:"name"`                 // name of the configuration
-	Version    CompilerVersion   `json:"version,omitempty"`    // compiler version to use for this build
-	WorkingDir string            `json:"workingDir,omitempty"` // working directory for the -D flag
-	Args       []string          `json:"args,omitempty"`       // list of arguments to pass to the compiler
-	Input      string            `json:"input,omitempty"`      // input .pwn file
-	Output     string            `json:"output,omitempty"`     // output .amx file
-	Includes   []string          `json:"includes,omitempty"`   // list of include files to include in compilation via -i flags
-	Constants  map[string]string `json:"constants,omitempty"`  // set of constant definitions to pass to the compiler
-	Plugins    [][]string        `json:"plugins,omitempty"`    // set of commands to run before compilation
+	Name       string                  `json:"name"`                 // name of the configuration
+	Platform   string                  `json:"platform,omitempty"`   // restricts this configuration to a single platform
+	Version    CompilerVersion         `json:"version,omitempty"`    // compiler version to use for this build
+	WorkingDir string                  `json:"workingDir,omitempty"` // directory the compiler runs in, relative to the package, defaults to the package root
+	Args       []string                `json:"args,omitempty"`       // list of arguments to pass to the compiler
+	Input      string                  `json:"input,omitempty"`      // input .pwn file
+	Output     string                  `json:"output,omitempty"`     // output .amx file
+	Includes   []string                `json:"includes,omitempty"`   // list of include files to include in compilation via -i flags
+	Constants  map[string]string       `json:"constants,omitempty"`  // set of constant definitions to pass to the compiler
+	Plugins    [][]string              `json:"plugins,omitempty"`    // set of commands to run before compilation
+	Generators []Generator             `json:"generators,omitempty"` // commands that generate source files before compilation
+	Platforms  map[string]*BuildConfig `json:"platforms,omitempty"`  // per-platform overlays merged onto this configuration
+
+	// ForceIncludes are included ahead of the input script in order, such as a config include that
+	// dependencies read, each is a file relative to the package or the name of an include
+	ForceIncludes []string `json:"forceIncludes,omitempty"`
+
+	// CoverageMarkers instruments the functions defined in the sources under the working directory
+	// with a `COVERAGE_HIT(name)` marker, which is counted in a coverage report when the build runs.
+	// The header that defines the marker is force-included so it can also be placed by hand.
+	CoverageMarkers bool `json:"coverageMarkers,omitempty"`
+
+	// Runtime is the name of the runtime config that the output of this build is run with, and
+	// RuntimeOverrides are settings that replace those of the runtime config when it is
+	Runtime          string   `json:"runtime,omitempty"`
+	RuntimeOverrides *Runtime `json:"runtimeOverrides,omitempty"`
+
+	// Compiler is a package whose resource for the platform provides the compiler, it is used instead
+	// of `version` and the checksum of the compiler binary is pinned in the lockfile
+	Compiler versioning.DependencyString `json:"compiler,omitempty"`
+	// CompilerPath is set internally to the binary provided by `compiler` once it is installed
+	CompilerPath string `json:"-" yaml:"-"`
+
+	// Profile is a named set of compiler options the build starts from, see BuildProfiles, the
+	// options and arguments the build sets itself take precedence over those of the profile
+	Profile BuildProfile `json:"profile,omitempty"`
+
+	// Typed compiler options, these replace the equivalent flags in `args` when they are set
+	Debug        *int  `json:"debug,omitempty"`        // debug information level from 0 to 3, the -d flag
+	Optimization *int  `json:"optimization,omitempty"` // optimization level from 0 to 2, the -O flag
+	Compress     *bool `json:"compress,omitempty"`     // compact encoding of the output AMX, the -C flag
+
+	// Manifest describes a file written next to the output of a successful build for deployment
+	// tooling to read, with details such as the version, commit and build time
+	Manifest *BuildManifest `json:"manifest,omitempty"`
+}
+
+// Generator is a command that writes source files, such as includes generated from a schema, before
+// compilation. It is skipped if all of its outputs exist and its inputs have not changed since the
+// last time it ran successfully.
+type Generator struct {
+	Command []string `json:"command"`           // the command and its arguments, run from the package directory
+	Inputs  []string `json:"inputs,omitempty"`  // files the command reads, glob patterns are allowed
+	Outputs []string `json:"outputs,omitempty"` // files the command writes
+}
+
+// BuildManifest is a JSON object written to the output directory of a build once it succeeds. Each
+// field is a Go template, such as `{{.Version}}`, executed with the details of the build.
+type BuildManifest struct {
+	File   string            `json:"file,omitempty"`   // file name in the output directory, the output name with a .json extension by default
+	Fields map[string]string `json:"fields,omitempty"` // templates of the fields of the manifest, a default set of fields is used if empty
 }
 
 // CompilerVersion represents a compiler version number
@@ -26,6 +85,202 @@ func GetBuildConfigDefault() *BuildConfig {
 	}
 }
 
+// MatchesPlatform returns true if the build config is not scoped to a platform or if it is scoped
+// to the given platform.
+func (bc BuildConfig) MatchesPlatform(platform string) bool {
+	return bc.Platform == "" || bc.Platform == platform
+}
+
+// ForPlatform returns a copy of the build config with the overlay for the given platform merged
+// on top. Non-empty fields in the overlay replace those in the base, includes, plugins and
+// generators are appended and constants are merged with the overlay taking precedence.
+func (bc BuildConfig) ForPlatform(platform string) (result BuildConfig) {
+	result = bc
+	result.Platforms = nil
+	result.Args = append([]string{}, bc.Args...)
+	result.Includes = append([]string{}, bc.Includes...)
+	result.Plugins = append([][]string{}, bc.Plugins...)
+	result.Constants = make(map[string]string)
+	for name, value := range bc.Constants {
+		result.Constants[name] = value
+	}
+
+	overlay, ok := bc.Platforms[platform]
+	if !ok || overlay == nil {
+		return
+	}
+
+	if overlay.Version != "" {
+		result.Version = overlay.Version
+	}
+	if overlay.Compiler != "" {
+		result.Compiler = overlay.Compiler
+	}
+	if overlay.Profile != "" {
+		result.Profile = overlay.Profile
+	}
+	if overlay.WorkingDir != "" {
+		result.WorkingDir = overlay.WorkingDir
+	}
+	if len(overlay.Args) > 0 {
+		result.Args = append([]string{}, overlay.Args...)
+	}
+	if overlay.Input != "" {
+		result.Input = overlay.Input
+	}
+	if overlay.Output != "" {
+		result.Output = overlay.Output
+	}
+	if overlay.CoverageMarkers {
+		result.CoverageMarkers = true
+	}
+	if overlay.Debug != nil {
+		result.Debug = overlay.Debug
+	}
+	if overlay.Optimization != nil {
+		result.Optimization = overlay.Optimization
+	}
+	if overlay.Compress != nil {
+		result.Compress = overlay.Compress
+	}
+	if overlay.Runtime != "" {
+		result.Runtime = overlay.Runtime
+	}
+	if overlay.RuntimeOverrides != nil {
+		result.RuntimeOverrides = overlay.RuntimeOverrides
+	}
+	if overlay.Manifest != nil {
+		result.Manifest = overlay.Manifest
+	}
+	result.Includes = append(result.Includes, overlay.Includes...)
+	if len(overlay.ForceIncludes) > 0 {
+		result.ForceIncludes = append(append([]string{}, bc.ForceIncludes...), overlay.ForceIncludes...)
+	}
+	result.Plugins = append(result.Plugins, overlay.Plugins...)
+	if len(overlay.Generators) > 0 {
+		result.Generators = append(append([]Generator{}, bc.Generators...), overlay.Generators...)
+	}
+	for name, value := range overlay.Constants {
+		result.Constants[name] = value
+	}
+
+	return
+}
+
+// BuildProfile is the name of a set of compiler options for a purpose
+type BuildProfile string
+
+const (
+	// ProfileStrict turns on every check: full debug information, required semicolons and
+	// parentheses, tag mismatch warnings and warnings treated as errors
+	ProfileStrict BuildProfile = "strict"
+	// ProfileFast compiles as quickly as possible for iterative work, with runtime checks but no
+	// symbolic information, optimization or compression
+	ProfileFast BuildProfile = "fast"
+	// ProfileRelease produces a small and fast AMX without debug information
+	ProfileRelease BuildProfile = "release"
+)
+
+// BuildProfiles lists the valid build profiles
+var BuildProfiles = []BuildProfile{ProfileStrict, ProfileFast, ProfileRelease}
+
+// profileOptions are the compiler options of each profile, arguments are toggles that a build
+// overrides by passing the same flag
+var profileOptions = map[BuildProfile]BuildConfig{
+	ProfileStrict: {
+		Args:  []string{"-;+", "-(+", "-w213+", "-E"},
+		Debug: intOption(3),
+	},
+	ProfileFast: {
+		Debug:        intOption(1),
+		Optimization: intOption(0),
+		Compress:     boolOption(false),
+	},
+	ProfileRelease: {
+		Debug:        intOption(0),
+		Optimization: intOption(2),
+		Compress:     boolOption(true),
+	},
+}
+
+func intOption(n int) *int    { return &n }
+func boolOption(b bool) *bool { return &b }
+
+// ValidateProfile returns an error if the profile of the build config or any of its platform
+// overlays isn't one of BuildProfiles
+func (bc BuildConfig) ValidateProfile() error {
+	if _, ok := profileOptions[bc.Profile]; !ok && bc.Profile != "" {
+		return errors.Errorf("unknown build profile %s, must be one of %v", bc.Profile, BuildProfiles)
+	}
+	for _, overlay := range bc.Platforms {
+		if overlay == nil {
+			continue
+		}
+		if err := overlay.ValidateProfile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithProfile returns a copy of the build config with the options of its profile applied to it,
+// the arguments it starts from are those of the build config or the defaults if it has none. A
+// typed option or argument of the profile is only used if the build config doesn't set the same
+// option, either as a typed option or in its own arguments.
+func (bc BuildConfig) WithProfile(defaults []string) (result BuildConfig) {
+	result = bc
+	profile, ok := profileOptions[bc.Profile]
+	if !ok {
+		return
+	}
+
+	if result.Debug == nil && !hasFlag(bc.Args, "-d") {
+		result.Debug = profile.Debug
+	}
+	if result.Optimization == nil && !hasFlag(bc.Args, "-O") {
+		result.Optimization = profile.Optimization
+	}
+	if result.Compress == nil && !hasFlag(bc.Args, "-C") {
+		result.Compress = profile.Compress
+	}
+
+	base := bc.Args
+	if len(base) == 0 {
+		base = defaults
+	}
+	result.Args = nil
+outer:
+	for _, arg := range base {
+		for _, toggle := range profile.Args {
+			if len(bc.Args) == 0 && toggleFlag(arg) == toggleFlag(toggle) {
+				continue outer
+			}
+		}
+		result.Args = append(result.Args, arg)
+	}
+	for _, toggle := range profile.Args {
+		if len(bc.Args) == 0 || !hasFlag(bc.Args, toggleFlag(toggle)) {
+			result.Args = append(result.Args, toggle)
+		}
+	}
+	return
+}
+
+// hasFlag is true if any of the arguments starts with a flag
+func hasFlag(args []string, flag string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// toggleFlag is the flag of an argument that turns an option on or off, such as `-;` for `-;+`
+func toggleFlag(arg string) string {
+	return strings.TrimRight(arg, "+-")
+}
+
 // ProblemSeverity represents the severity of a problem, warning error or fatal
 type ProblemSeverity int8
 
@@ -112,3 +367,11 @@ type BuildResult struct {
 	Estimate  int
 	Total     int
 }
+
+// CoverageFunction is a function that a build with coverage markers instrumented, the file is
+// relative to the working directory of the build
+type CoverageFunction struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
@@ -20,6 +20,22 @@ type Config struct {
 	GitUsername string `json:"git_username,omitempty"`
 	GitPassword string `json:"git_password,omitempty"`
 	NewUser     bool   `json:"-"`
+
+	CompilerMirrors   []string          `json:"compiler_mirrors,omitempty"`   // URLs tried in order before GitHub when downloading a compiler
+	CompilerChecksums map[string]string `json:"compiler_checksums,omitempty"` // SHA-256 checksums of compiler packages by file name
+	CompilerAttempts  int               `json:"compiler_attempts,omitempty"`  // how many times each compiler download source is tried
+
+	Registry *RegistryConfig `json:"registry,omitempty"` // package registry that dependencies without a host resolve through
+	Index    string          `json:"index,omitempty"`    // URL or path of the package index that search and include suggestions use
+}
+
+// RegistryConfig points to a package registry and the credentials to use with it, either a token
+// or a username and password. Credentials are only sent to a registry with an HTTPS URL.
+type RegistryConfig struct {
+	URL      string `json:"url"`
+	Token    string `json:"token,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
 }
 
 // LoadOrCreateConfig reads a config file from the given cache directory
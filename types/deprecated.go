@@ -0,0 +1,105 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Southclaws/sampctl/print"
+)
+
+// DeprecatedField is a key of package definitions that was renamed
+type DeprecatedField struct {
+	Path        string // keys from the root of the definition to the old key, `*` matches every list item
+	Replacement string // the key that replaced it, in the same object
+}
+
+// DeprecatedFields are the renamed keys of package definitions. Definitions that still use them load
+// with a warning and the value of each old key is used for its replacement, unless that's set too.
+var DeprecatedFields = []DeprecatedField{
+	{Path: "resources.*.release", Replacement: "archive"},
+}
+
+var (
+	warnedDeprecated   = make(map[string]bool)
+	warnedDeprecatedMu sync.Mutex
+)
+
+// warnDeprecated prints the deprecation warnings of a definition file, only the first time the file
+// is loaded since the definitions of dependencies are read many times over
+func warnDeprecated(file string, warnings []string) {
+	warnedDeprecatedMu.Lock()
+	defer warnedDeprecatedMu.Unlock()
+	if len(warnings) == 0 || warnedDeprecated[file] {
+		return
+	}
+	warnedDeprecated[file] = true
+	for _, warning := range warnings {
+		print.Warn(file+":", warning)
+	}
+}
+
+// migrateDeprecated moves the values of deprecated keys in a decoded package definition to their
+// replacements and describes each one it finds. Objects may be decoded from JSON or YAML.
+func migrateDeprecated(definition interface{}) (warnings []string) {
+	for _, field := range DeprecatedFields {
+		warnings = append(warnings, migrateField(definition, strings.Split(field.Path, "."), "", field.Replacement)...)
+	}
+	return
+}
+
+func migrateField(value interface{}, path []string, at, replacement string) (warnings []string) {
+	if path[0] == "*" {
+		if list, ok := value.([]interface{}); ok {
+			for i, item := range list {
+				warnings = append(warnings, migrateField(item, path[1:], fmt.Sprintf("%s[%d]", at, i), replacement)...)
+			}
+		}
+		return
+	}
+
+	object := decodedObject(value)
+	if object == nil {
+		return
+	}
+	inner, ok := object.get(path[0])
+	if !ok {
+		return
+	}
+	name := strings.TrimPrefix(at+"."+path[0], ".")
+	if len(path) > 1 {
+		return migrateField(inner, path[1:], name, replacement)
+	}
+
+	object.remove(path[0])
+	if _, set := object.get(replacement); set {
+		return []string{fmt.Sprintf("`%s` is deprecated and ignored since `%s` is also set, remove it", name, replacement)}
+	}
+	object.set(replacement, inner)
+	return []string{fmt.Sprintf("`%s` is deprecated, rename it to `%s`", name, replacement)}
+}
+
+// decodedObjectAccess reads and writes the keys of a JSON or YAML object
+type decodedObjectAccess struct {
+	get    func(key string) (interface{}, bool)
+	set    func(key string, value interface{})
+	remove func(key string)
+}
+
+func decodedObject(value interface{}) *decodedObjectAccess {
+	switch object := value.(type) {
+	case map[string]interface{}:
+		return &decodedObjectAccess{
+			get:    func(key string) (v interface{}, ok bool) { v, ok = object[key]; return },
+			set:    func(key string, v interface{}) { object[key] = v },
+			remove: func(key string) { delete(object, key) },
+		}
+	case map[interface{}]interface{}:
+		return &decodedObjectAccess{
+			get:    func(key string) (v interface{}, ok bool) { v, ok = object[key]; return },
+			set:    func(key string, v interface{}) { object[key] = v },
+			remove: func(key string) { delete(object, key) },
+		}
+	}
+	return nil
+}
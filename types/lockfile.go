@@ -0,0 +1,358 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// LockfileName is the name of the file that records the exact commit each dependency of a package
+// was resolved to, it lives alongside the package definition file and should be committed.
+const LockfileName = "pawn.lock"
+
+// Lockfile records the resolved state of every dependency of a package
+type Lockfile struct {
+	Strategy     string             `json:"strategy,omitempty"` // the resolution strategy the dependencies were resolved with, if not the newest
+	Dependencies []LockedDependency `json:"dependencies"`
+	Compilers    []LockedCompiler   `json:"compilers,omitempty"`
+	Runtime      *LockedRuntime     `json:"runtime,omitempty"`
+}
+
+// LockedDependency pairs a dependency, as declared, with the commit it was resolved to
+type LockedDependency struct {
+	Dependency versioning.DependencyString `json:"dependency"`           // the dependency constraint as declared
+	Commit     string                      `json:"commit"`               // the commit hash the constraint resolved to
+	Plugins    map[string]string           `json:"plugins,omitempty"`    // sha256 of the plugin binaries extracted from the dependency's resources by file name
+	Transforms map[string]string           `json:"transforms,omitempty"` // sha256 of the files of the dependency changed by transforms, by path
+	Platform   string                      `json:"platform,omitempty"`   // the only platform the dependency is used on, if it's conditional
+}
+
+// LockedCompiler pairs a compiler dependency, as declared by a build config, with the sha256 of the
+// compiler binary its resources provided on each platform
+type LockedCompiler struct {
+	Dependency versioning.DependencyString `json:"dependency"`
+	Checksums  map[string]string           `json:"checksums"`
+}
+
+// LockedRuntime pairs the server version a package runs with, as declared by its runtime config,
+// with the version it resolved to, which differs for an alias, and the sha256 of the server binary
+// of that version on each platform
+type LockedRuntime struct {
+	Version   string            `json:"version"`
+	Resolved  string            `json:"resolved"`
+	Checksums map[string]string `json:"checksums"`
+}
+
+// NewLockfile creates a lockfile from a set of locked dependencies, duplicates are removed and the
+// entries are sorted so the output is stable.
+func NewLockfile(deps []LockedDependency) (lock Lockfile) {
+	seen := make(map[versioning.DependencyString]struct{})
+	for _, dep := range deps {
+		if _, ok := seen[dep.Dependency]; ok {
+			continue
+		}
+		seen[dep.Dependency] = struct{}{}
+		lock.Dependencies = append(lock.Dependencies, dep)
+	}
+	sort.Slice(lock.Dependencies, func(i, j int) bool {
+		return lock.Dependencies[i].Dependency < lock.Dependencies[j].Dependency
+	})
+	return
+}
+
+// ReadLockfile reads the lockfile from a package directory, if there is no lockfile the result is
+// nil and no error is returned.
+func ReadLockfile(dir string) (lock *Lockfile, err error) {
+	contents, err := ioutil.ReadFile(filepath.Join(dir, LockfileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+		err = errors.Wrapf(err, "failed to read %s", LockfileName)
+		return
+	}
+
+	lock = new(Lockfile)
+	err = json.Unmarshal(contents, lock)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to unmarshal %s", LockfileName)
+		return
+	}
+
+	return
+}
+
+// Write writes the lockfile to a package directory
+func (lock Lockfile) Write(dir string) (err error) {
+	contents, err := json.MarshalIndent(lock, "", "\t")
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode %s", LockfileName)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, LockfileName), append(contents, '\n'), 0644)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write %s", LockfileName)
+	}
+	return
+}
+
+// ResolutionStrategy returns the resolution strategy the lockfile was resolved with
+func (lock Lockfile) ResolutionStrategy() string {
+	if lock.Strategy == "" {
+		return "newest"
+	}
+	return lock.Strategy
+}
+
+// Commit returns the locked commit for a dependency
+func (lock Lockfile) Commit(meta versioning.DependencyMeta) (commit string, ok bool) {
+	dependency := versioning.DependencyString(meta.String())
+	for _, locked := range lock.Dependencies {
+		if locked.Dependency == dependency {
+			return locked.Commit, true
+		}
+	}
+	return
+}
+
+// Plugins returns the locked plugin checksums of a dependency, matched by user and repository so a
+// dependency that was pinned to its locked commit still finds its entry.
+func (lock Lockfile) Plugins(meta versioning.DependencyMeta) map[string]string {
+	if i := lock.find(meta); i >= 0 {
+		return lock.Dependencies[i].Plugins
+	}
+	return nil
+}
+
+// Transformed returns the locked checksums of the transformed files of a dependency
+func (lock Lockfile) Transformed(meta versioning.DependencyMeta) map[string]string {
+	if i := lock.find(meta); i >= 0 {
+		return lock.Dependencies[i].Transforms
+	}
+	return nil
+}
+
+// SetPlugins records the plugin checksums of a dependency, it returns false if the dependency is not
+// in the lockfile.
+func (lock *Lockfile) SetPlugins(meta versioning.DependencyMeta, plugins map[string]string) bool {
+	i := lock.find(meta)
+	if i < 0 {
+		return false
+	}
+	lock.Dependencies[i].Plugins = plugins
+	return true
+}
+
+// KeepPlugins copies the plugin checksums from a previous lockfile for dependencies that are still
+// locked to the same commit. Dependencies that moved to a different commit lose them, since a new
+// version may legitimately come with new binaries, so they are recorded again.
+func (lock *Lockfile) KeepPlugins(previous Lockfile) {
+	for i, locked := range lock.Dependencies {
+		for _, old := range previous.Dependencies {
+			if old.Dependency == locked.Dependency && old.Commit == locked.Commit {
+				lock.Dependencies[i].Plugins = old.Plugins
+			}
+		}
+	}
+}
+
+// CompilerChecksum returns the locked checksum of the binary a compiler dependency provides for a
+// platform
+func (lock Lockfile) CompilerChecksum(dependency versioning.DependencyString, platform string) (checksum string, ok bool) {
+	for _, locked := range lock.Compilers {
+		if locked.Dependency == dependency {
+			checksum, ok = locked.Checksums[platform]
+			return
+		}
+	}
+	return
+}
+
+// SetCompilerChecksum records the checksum of the binary a compiler dependency provides for a
+// platform, keeping the compilers sorted so the output is stable
+func (lock *Lockfile) SetCompilerChecksum(dependency versioning.DependencyString, platform, checksum string) {
+	for i, locked := range lock.Compilers {
+		if locked.Dependency == dependency {
+			checksums := make(map[string]string)
+			for p, sum := range locked.Checksums {
+				checksums[p] = sum
+			}
+			checksums[platform] = checksum
+			lock.Compilers[i].Checksums = checksums
+			return
+		}
+	}
+	lock.Compilers = append(lock.Compilers, LockedCompiler{
+		Dependency: dependency,
+		Checksums:  map[string]string{platform: checksum},
+	})
+	sort.Slice(lock.Compilers, func(i, j int) bool {
+		return lock.Compilers[i].Dependency < lock.Compilers[j].Dependency
+	})
+}
+
+// RuntimeVersion returns the server version a declared runtime version was locked to
+func (lock Lockfile) RuntimeVersion(version string) (resolved string, ok bool) {
+	if lock.Runtime == nil || lock.Runtime.Version != version {
+		return
+	}
+	return lock.Runtime.Resolved, true
+}
+
+// RuntimeChecksum returns the locked checksum of the server binary of a version for a platform
+func (lock Lockfile) RuntimeChecksum(version, platform string) (checksum string, ok bool) {
+	if _, locked := lock.RuntimeVersion(version); !locked {
+		return
+	}
+	checksum, ok = lock.Runtime.Checksums[platform]
+	return
+}
+
+// SetRuntimeChecksum records the server version a declared runtime version resolved to and the
+// checksum of its server binary for a platform, checksums of a different version are dropped
+func (lock *Lockfile) SetRuntimeChecksum(version, resolved, platform, checksum string) {
+	checksums := map[string]string{platform: checksum}
+	if lock.Runtime != nil && lock.Runtime.Version == version && lock.Runtime.Resolved == resolved {
+		for p, sum := range lock.Runtime.Checksums {
+			if p != platform {
+				checksums[p] = sum
+			}
+		}
+	}
+	lock.Runtime = &LockedRuntime{Version: version, Resolved: resolved, Checksums: checksums}
+}
+
+func (lock Lockfile) find(meta versioning.DependencyMeta) int {
+	dependency := versioning.DependencyString(meta.String())
+	for i, locked := range lock.Dependencies {
+		if locked.Dependency == dependency {
+			return i
+		}
+	}
+	for i, locked := range lock.Dependencies {
+		other, err := locked.Dependency.Explode()
+		if err == nil && strings.EqualFold(other.User, meta.User) && strings.EqualFold(other.Repo, meta.Repo) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Diff describes every difference between this lockfile and another, an empty result means the
+// two lockfiles are equivalent.
+func (lock Lockfile) Diff(other Lockfile) (changes []string) {
+	before := make(map[versioning.DependencyString]LockedDependency)
+	for _, locked := range lock.Dependencies {
+		before[locked.Dependency] = locked
+	}
+	after := make(map[versioning.DependencyString]LockedDependency)
+	for _, locked := range other.Dependencies {
+		after[locked.Dependency] = locked
+	}
+
+	if lock.ResolutionStrategy() != other.ResolutionStrategy() {
+		changes = append(changes, fmt.Sprintf("changed resolution strategy from %s to %s", lock.ResolutionStrategy(), other.ResolutionStrategy()))
+	}
+
+	for _, locked := range lock.Dependencies {
+		updated, ok := after[locked.Dependency]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("removed %s", locked.Dependency))
+		} else if updated.Commit != locked.Commit {
+			changes = append(changes, fmt.Sprintf("changed %s from %s to %s", locked.Dependency, locked.Commit, updated.Commit))
+		} else if updated.Platform != locked.Platform {
+			changes = append(changes, fmt.Sprintf("changed platform of %s from %s to %s", locked.Dependency, platformName(locked.Platform), platformName(updated.Platform)))
+		} else {
+			changes = append(changes, diffChecksums("plugin", locked.Dependency, locked.Plugins, updated.Plugins)...)
+			changes = append(changes, diffChecksums("transformed file", locked.Dependency, locked.Transforms, updated.Transforms)...)
+		}
+	}
+	for _, locked := range other.Dependencies {
+		if _, ok := before[locked.Dependency]; !ok {
+			changes = append(changes, fmt.Sprintf("added %s", locked.Dependency))
+		}
+	}
+
+	compilers := make(map[versioning.DependencyString]map[string]string)
+	for _, locked := range other.Compilers {
+		compilers[locked.Dependency] = locked.Checksums
+	}
+	for _, locked := range lock.Compilers {
+		updated, ok := compilers[locked.Dependency]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("removed compiler %s", locked.Dependency))
+			continue
+		}
+		changes = append(changes, diffChecksums("compiler", locked.Dependency, locked.Checksums, updated)...)
+		delete(compilers, locked.Dependency)
+	}
+	for _, locked := range other.Compilers {
+		if _, ok := compilers[locked.Dependency]; ok {
+			changes = append(changes, fmt.Sprintf("added compiler %s", locked.Dependency))
+		}
+	}
+
+	changes = append(changes, diffRuntime(lock.Runtime, other.Runtime)...)
+
+	return
+}
+
+// diffRuntime describes the differences between two locked server runtimes
+func diffRuntime(before, after *LockedRuntime) (changes []string) {
+	switch {
+	case before == nil && after == nil:
+	case after == nil:
+		changes = append(changes, fmt.Sprintf("removed runtime %s", before.Version))
+	case before == nil:
+		changes = append(changes, fmt.Sprintf("added runtime %s resolved to %s", after.Version, after.Resolved))
+	case before.Version != after.Version || before.Resolved != after.Resolved:
+		changes = append(changes, fmt.Sprintf("changed runtime from %s (%s) to %s (%s)", before.Version, before.Resolved, after.Version, after.Resolved))
+	default:
+		changes = append(changes, diffChecksums("server binary", versioning.DependencyString("runtime "+after.Resolved), before.Checksums, after.Checksums)...)
+	}
+	return
+}
+
+// diffChecksums describes the differences between two sets of checksums of the files of a kind,
+// plugins by name or compiler binaries by platform, that a dependency provides
+func diffChecksums(kind string, dependency versioning.DependencyString, before, after map[string]string) (changes []string) {
+	var names []string
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		old, hadOld := before[name]
+		sum, hasNew := after[name]
+		switch {
+		case !hasNew:
+			changes = append(changes, fmt.Sprintf("removed %s %s of %s", kind, name, dependency))
+		case !hadOld:
+			changes = append(changes, fmt.Sprintf("added %s %s of %s", kind, name, dependency))
+		case old != sum:
+			changes = append(changes, fmt.Sprintf("changed %s %s of %s from %s to %s", kind, name, dependency, old, sum))
+		}
+	}
+	return
+}
+
+func platformName(platform string) string {
+	if platform == "" {
+		return "all"
+	}
+	return platform
+}
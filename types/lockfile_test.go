@@ -0,0 +1,179 @@
+package types
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestLockfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lockfile")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	lock, err := ReadLockfile(dir)
+	assert.NoError(t, err)
+	assert.Nil(t, lock)
+
+	written := NewLockfile([]LockedDependency{
+		{Dependency: "Southclaws/formatex:1.0.0", Commit: "b"},
+		{Dependency: "sampctl/samp-stdlib", Commit: "a"},
+		{Dependency: "Southclaws/formatex:1.0.0", Commit: "b"},
+	})
+	assert.Len(t, written.Dependencies, 2)
+	assert.NoError(t, written.Write(dir))
+
+	lock, err = ReadLockfile(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, written, *lock)
+
+	commit, ok := lock.Commit(versioning.DependencyMeta{User: "Southclaws", Repo: "formatex", Tag: "1.0.0"})
+	assert.True(t, ok)
+	assert.Equal(t, "b", commit)
+
+	_, ok = lock.Commit(versioning.DependencyMeta{User: "Southclaws", Repo: "formatex", Tag: "1.1.0"})
+	assert.False(t, ok)
+}
+
+func TestLockfile_Diff(t *testing.T) {
+	before := NewLockfile([]LockedDependency{
+		{Dependency: "a/a", Commit: "1"},
+		{Dependency: "b/b", Commit: "2"},
+	})
+	after := NewLockfile([]LockedDependency{
+		{Dependency: "a/a", Commit: "3"},
+		{Dependency: "c/c", Commit: "4"},
+	})
+
+	assert.Empty(t, before.Diff(before))
+	assert.Equal(t, []string{
+		"changed a/a from 1 to 3",
+		"removed b/b",
+		"added c/c",
+	}, before.Diff(after))
+}
+
+func TestLockfile_Transforms(t *testing.T) {
+	before := NewLockfile([]LockedDependency{{Dependency: "a/a", Commit: "1", Transforms: map[string]string{"a.inc": "x"}}})
+	after := NewLockfile([]LockedDependency{{Dependency: "a/a", Commit: "1", Transforms: map[string]string{"a.inc": "y", "b.inc": "z"}}})
+
+	assert.Equal(t, map[string]string{"a.inc": "x"}, before.Transformed(versioning.DependencyMeta{User: "a", Repo: "a"}))
+	assert.Equal(t, []string{
+		"changed transformed file a.inc of a/a from x to y",
+		"added transformed file b.inc of a/a",
+	}, before.Diff(after))
+}
+
+func TestLockfile_Platform(t *testing.T) {
+	before := NewLockfile([]LockedDependency{{Dependency: "a/a", Commit: "1"}})
+	after := NewLockfile([]LockedDependency{{Dependency: "a/a", Commit: "1", Platform: "windows"}})
+
+	assert.Equal(t, []string{
+		"changed platform of a/a from all to windows",
+	}, before.Diff(after))
+}
+
+func TestLockfile_Strategy(t *testing.T) {
+	newest := NewLockfile([]LockedDependency{{Dependency: "a/a", Commit: "1"}})
+	minimal := NewLockfile([]LockedDependency{{Dependency: "a/a", Commit: "1"}})
+	minimal.Strategy = "minimal"
+
+	assert.Equal(t, "newest", newest.ResolutionStrategy())
+	assert.Equal(t, []string{
+		"changed resolution strategy from newest to minimal",
+	}, newest.Diff(minimal))
+}
+
+func TestLockfile_Plugins(t *testing.T) {
+	previous := NewLockfile([]LockedDependency{
+		{Dependency: "a/a:1.0.0", Commit: "1", Plugins: map[string]string{"a.so": "aa"}},
+		{Dependency: "b/b", Commit: "2", Plugins: map[string]string{"b.so": "bb"}},
+	})
+	lock := NewLockfile([]LockedDependency{
+		{Dependency: "a/a:1.0.0", Commit: "1"},
+		{Dependency: "b/b", Commit: "3"},
+	})
+	lock.KeepPlugins(previous)
+
+	// a dependency pinned to its locked commit still finds its entry
+	pinned := versioning.DependencyMeta{User: "a", Repo: "a", Commit: "1"}
+	assert.Equal(t, map[string]string{"a.so": "aa"}, lock.Plugins(pinned))
+	assert.Nil(t, lock.Plugins(versioning.DependencyMeta{User: "b", Repo: "b"}))
+
+	assert.True(t, lock.SetPlugins(versioning.DependencyMeta{User: "b", Repo: "b"}, map[string]string{"b.so": "cc"}))
+	assert.False(t, lock.SetPlugins(versioning.DependencyMeta{User: "c", Repo: "c"}, map[string]string{"c.so": "dd"}))
+
+	after := NewLockfile([]LockedDependency{
+		{Dependency: "a/a:1.0.0", Commit: "1", Plugins: map[string]string{"a.dll": "ab"}},
+		{Dependency: "b/b", Commit: "3", Plugins: map[string]string{"b.so": "dd"}},
+	})
+	assert.Equal(t, []string{
+		"added plugin a.dll of a/a:1.0.0",
+		"removed plugin a.so of a/a:1.0.0",
+		"changed plugin b.so of b/b from cc to dd",
+	}, lock.Diff(after))
+}
+
+func TestLockfile_Compilers(t *testing.T) {
+	lock := NewLockfile(nil)
+	_, ok := lock.CompilerChecksum("b/pawn:3.10.10", "linux")
+	assert.False(t, ok)
+
+	lock.SetCompilerChecksum("b/pawn:3.10.10", "linux", "aa")
+	lock.SetCompilerChecksum("a/pawn:3.10.8", "linux", "bb")
+	lock.SetCompilerChecksum("b/pawn:3.10.10", "windows", "cc")
+	assert.Equal(t, []LockedCompiler{
+		{Dependency: "a/pawn:3.10.8", Checksums: map[string]string{"linux": "bb"}},
+		{Dependency: "b/pawn:3.10.10", Checksums: map[string]string{"linux": "aa", "windows": "cc"}},
+	}, lock.Compilers)
+
+	sum, ok := lock.CompilerChecksum("b/pawn:3.10.10", "windows")
+	assert.True(t, ok)
+	assert.Equal(t, "cc", sum)
+	_, ok = lock.CompilerChecksum("a/pawn:3.10.8", "windows")
+	assert.False(t, ok)
+
+	after := NewLockfile(nil)
+	after.SetCompilerChecksum("b/pawn:3.10.10", "linux", "dd")
+	after.SetCompilerChecksum("c/pawn:3.10.10", "linux", "ee")
+	assert.Equal(t, []string{
+		"removed compiler a/pawn:3.10.8",
+		"changed compiler linux of b/pawn:3.10.10 from aa to dd",
+		"removed compiler windows of b/pawn:3.10.10",
+		"added compiler c/pawn:3.10.10",
+	}, lock.Diff(after))
+}
+
+func TestLockfile_Runtime(t *testing.T) {
+	lock := NewLockfile(nil)
+	_, ok := lock.RuntimeVersion("0.3.7")
+	assert.False(t, ok)
+
+	lock.SetRuntimeChecksum("0.3.7", "0.3.7-R2-2-1", "linux", "aa")
+	lock.SetRuntimeChecksum("0.3.7", "0.3.7-R2-2-1", "windows", "bb")
+	resolved, ok := lock.RuntimeVersion("0.3.7")
+	assert.True(t, ok)
+	assert.Equal(t, "0.3.7-R2-2-1", resolved)
+	sum, ok := lock.RuntimeChecksum("0.3.7", "windows")
+	assert.True(t, ok)
+	assert.Equal(t, "bb", sum)
+	_, ok = lock.RuntimeChecksum("0.3.DL", "windows")
+	assert.False(t, ok)
+
+	after := NewLockfile(nil)
+	after.SetRuntimeChecksum("0.3.7", "0.3.7-R2-2-1", "linux", "cc")
+	assert.Equal(t, []string{
+		"changed server binary linux of runtime 0.3.7-R2-2-1 from aa to cc",
+		"removed server binary windows of runtime 0.3.7-R2-2-1",
+	}, lock.Diff(after))
+
+	// a different version starts over
+	after.SetRuntimeChecksum("0.3.DL", "0.3.DL-R1", "linux", "dd")
+	assert.Equal(t, map[string]string{"linux": "dd"}, after.Runtime.Checksums)
+	assert.Equal(t, []string{"changed runtime from 0.3.7 (0.3.7-R2-2-1) to 0.3.DL (0.3.DL-R1)"}, lock.Diff(after))
+	assert.Equal(t, []string{"removed runtime 0.3.7"}, lock.Diff(NewLockfile(nil)))
+}
@@ -1,12 +1,14 @@
 package types
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"path/filepath"
+	"strings"
 
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
@@ -44,9 +46,12 @@ type Package struct {
 	Vendor string `json:"-" yaml:"-"`
 	// format stores the original format of the package definition file, either `json` or `yaml`
 	Format string `json:"-" yaml:"-"`
+	// Requirements lists the dependencies that were merged into Dependencies from a requirements
+	// file, these are never written back to the package definition file.
+	Requirements []versioning.DependencyString `json:"-" yaml:"-"`
 
 	// Inferred metadata, not always explicitly set via JSON/YAML but inferred from the dependency path
-	versioning.DependencyMeta
+	versioning.DependencyMeta `yaml:",inline"`
 
 	// Metadata, set by the package author to describe the package
 	Contributors []string `json:"contributors,omitempty" yaml:"contributors,omitempty"` // list of contributors
@@ -63,7 +68,51 @@ type Package struct {
 	Runtime      *Runtime                      `json:"runtime,omitempty" yaml:"runtime,omitempty"`                   // runtime configuration
 	Runtimes     []*Runtime                    `json:"runtimes,omitempty" yaml:"runtimes,omitempty"`                 // multiple runtime configurations
 	IncludePath  string                        `json:"include_path,omitempty" yaml:"include_path,omitempty"`         // include path within the repository, so users don't need to specify the path explicitly
+	IncludePaths []string                      `json:"include_paths,omitempty" yaml:"include_paths,omitempty"`       // additional include paths within the repository, for libraries that split their includes across directories
 	Resources    []Resource                    `json:"resources,omitempty" yaml:"resources,omitempty"`               // list of additional resources associated with the package
+	Exports      []string                      `json:"exports,omitempty" yaml:"exports,omitempty"`                   // glob patterns of the public include files within the include path, by default every .inc file
+
+	// Scripts maps names to commands, with their arguments, that `package run-script` runs from the
+	// package directory for auxiliary automation such as linting or deployment.
+	Scripts map[string][]string `json:"scripts,omitempty" yaml:"scripts,omitempty"`
+
+	// ServerConfig holds server.cfg lines, by setting name, that a package needs when it's a
+	// dependency, such as the settings of the plugin it provides. They're merged into the runtime
+	// config of packages that depend on it and `plugins` adds to the plugin list.
+	ServerConfig map[string]string `json:"server_cfg,omitempty" yaml:"server_cfg,omitempty"`
+
+	// Features, compile-time options declared by libraries and enabled by the packages using them
+	Features       map[string]map[string]string `json:"features,omitempty" yaml:"features,omitempty"`               // named features mapped to the constants they define
+	EnableFeatures []string                     `json:"enable_features,omitempty" yaml:"enable_features,omitempty"` // features to enable across this package and its dependencies
+
+	// Namespaces maps `user/repo` dependencies to an include namespace (experimental). Includes of a
+	// namespaced dependency must be written as `#include <namespace/repo/file>` by the consumer.
+	Namespaces map[string]string `json:"namespaces,omitempty" yaml:"namespaces,omitempty"`
+	// Aliases maps `user/repo` dependencies to a different name that they are vendored under and
+	// included as, this resolves collisions between dependencies that share a name.
+	Aliases map[string]string `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	// DefaultBranches maps `user/repo` dependencies to the branch to use when they have no version
+	// constraint, for repositories where the default branch can't be looked up.
+	DefaultBranches map[string]string `json:"default_branches,omitempty" yaml:"default_branches,omitempty"`
+	// Transforms maps `user/repo` dependencies to find and replace transforms that are applied to
+	// their files in order every time they are vendored, to patch them without forking them.
+	Transforms map[string][]Transform `json:"transforms,omitempty" yaml:"transforms,omitempty"`
+	// Platforms maps `user/repo` dependencies to the only platform they are ensured and included for,
+	// such as a dependency that wraps a Windows-only plugin.
+	Platforms map[string]string `json:"platforms,omitempty" yaml:"platforms,omitempty"`
+	// VersionSource is where the versions that dependency constraints resolve to come from, `tags` of
+	// the repository by default or its GitHub `releases`, and VersionSources overrides it for
+	// `user/repo` dependencies.
+	VersionSource  string            `json:"version_source,omitempty" yaml:"version_source,omitempty"`
+	VersionSources map[string]string `json:"version_sources,omitempty" yaml:"version_sources,omitempty"`
+	// Overrides maps `user/repo` dependencies to a version constraint that replaces every constraint
+	// on them anywhere in the dependency tree, to settle constraints that conflict.
+	Overrides map[string]string `json:"overrides,omitempty" yaml:"overrides,omitempty"`
+	// VersionDefine is the name of a `#define` in the package source that holds the version of the
+	// package, `package release` checks it matches the version being released. VersionMismatch is
+	// what the check does when it doesn't, `error` by default or `warn`.
+	VersionDefine   string `json:"version_define,omitempty" yaml:"version_define,omitempty"`
+	VersionMismatch string `json:"version_mismatch,omitempty" yaml:"version_mismatch,omitempty"`
 }
 
 func (pkg Package) String() string {
@@ -75,6 +124,48 @@ func (pkg Package) Validate() (err error) {
 	if pkg.Entry == pkg.Output && pkg.Entry != "" && pkg.Output != "" {
 		return errors.New("package entry and output point to the same file")
 	}
+	if pkg.Build != nil {
+		if err = pkg.Build.ValidateProfile(); err != nil {
+			return
+		}
+	}
+	for _, build := range pkg.Builds {
+		if err = build.ValidateProfile(); err != nil {
+			return errors.Wrapf(err, "invalid build %s", build.Name)
+		}
+	}
+
+	for _, includePath := range pkg.IncludePaths {
+		clean := filepath.ToSlash(filepath.Clean(includePath))
+		if strings.TrimSpace(includePath) == "" || filepath.IsAbs(includePath) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return errors.Errorf("include path %q must be a directory within the package", includePath)
+		}
+	}
+
+	for name, command := range pkg.Scripts {
+		if len(command) == 0 {
+			return errors.Errorf("script %s has no command", name)
+		}
+	}
+
+	for name, value := range pkg.ServerConfig {
+		if name == "" || strings.ContainsAny(name, " \t\r\n") {
+			return errors.Errorf("server.cfg setting %q must be a single word", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return errors.Errorf("server.cfg setting %s must be on a single line", name)
+		}
+	}
+
+	var problems []string
+	for i, res := range pkg.Resources {
+		for _, problem := range res.problems() {
+			problems = append(problems, fmt.Sprintf("  resource %d (%s): %s", i+1, res.Name, problem))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("invalid resources:\n%s", strings.Join(problems, "\n"))
+	}
 
 	return
 }
@@ -90,27 +181,41 @@ func (pkg Package) GetAllDependencies() (result []versioning.DependencyString) {
 func PackageFromDep(depString versioning.DependencyString) (pkg Package, err error) {
 	dep, err := depString.Explode()
 	pkg.Site, pkg.User, pkg.Repo, pkg.Path, pkg.Tag, pkg.Branch, pkg.Commit = dep.Site, dep.User, dep.Repo, dep.Path, dep.Tag, dep.Branch, dep.Commit
+	pkg.DependencyMeta.Package = dep.Package
 	return
 }
 
-// PackageFromDir attempts to parse a pawn.json or pawn.yaml file from a directory
+// PackageFromDir attempts to parse a pawn.json or pawn.yaml file from a directory, if a
+// requirements file is present, its dependencies are merged into the package dependencies.
 func PackageFromDir(dir string) (pkg Package, err error) {
 	jsonFile := filepath.Join(dir, "pawn.json")
+	yamlFile := filepath.Join(dir, "pawn.yaml")
 	if util.Exists(jsonFile) {
-		return PackageFromJSON(jsonFile)
+		pkg, err = PackageFromJSON(jsonFile)
+	} else if util.Exists(yamlFile) {
+		pkg, err = PackageFromYAML(yamlFile)
+	} else {
+		err = errors.New("no pawn.json/pawn.yaml present")
 	}
-
-	yamlFile := filepath.Join(dir, "pawn.yaml")
-	if util.Exists(yamlFile) {
-		return PackageFromYAML(yamlFile)
+	if err != nil {
+		return
 	}
 
-	err = errors.New("no pawn.json/pawn.yaml present")
+	requirementsFile := filepath.Join(dir, RequirementsFile)
+	if util.Exists(requirementsFile) {
+		var requirements []versioning.DependencyString
+		requirements, err = ReadRequirements(requirementsFile)
+		if err != nil {
+			return
+		}
+		err = pkg.mergeRequirements(requirements)
+	}
 
 	return
 }
 
-// PackageFromJSON creates a config from a JSON file
+// PackageFromJSON creates a config from a JSON file, deprecated keys are migrated with a warning, see
+// DeprecatedFields
 func PackageFromJSON(file string) (pkg Package, err error) {
 	var contents []byte
 	contents, err = ioutil.ReadFile(file)
@@ -119,6 +224,21 @@ func PackageFromJSON(file string) (pkg Package, err error) {
 		return
 	}
 
+	// numbers are kept as they're written in case the definition is encoded again
+	var decoded interface{}
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.UseNumber()
+	if decoder.Decode(&decoded) == nil {
+		if warnings := migrateDeprecated(decoded); len(warnings) > 0 {
+			warnDeprecated(file, warnings)
+			contents, err = json.Marshal(decoded)
+			if err != nil {
+				err = errors.Wrap(err, "failed to encode migrated pawn.json")
+				return
+			}
+		}
+	}
+
 	err = json.Unmarshal(contents, &pkg)
 	if err != nil {
 		err = errors.Wrap(err, "failed to unmarshal pawn.json")
@@ -130,7 +250,8 @@ func PackageFromJSON(file string) (pkg Package, err error) {
 	return
 }
 
-// PackageFromYAML creates a config from a YAML file
+// PackageFromYAML creates a config from a YAML file, deprecated keys are migrated with a warning, see
+// DeprecatedFields
 func PackageFromYAML(file string) (pkg Package, err error) {
 	var contents []byte
 	contents, err = ioutil.ReadFile(file)
@@ -139,6 +260,18 @@ func PackageFromYAML(file string) (pkg Package, err error) {
 		return
 	}
 
+	var decoded interface{}
+	if yaml.Unmarshal(contents, &decoded) == nil {
+		if warnings := migrateDeprecated(decoded); len(warnings) > 0 {
+			warnDeprecated(file, warnings)
+			contents, err = yaml.Marshal(decoded)
+			if err != nil {
+				err = errors.Wrap(err, "failed to encode migrated pawn.yaml")
+				return
+			}
+		}
+	}
+
 	err = yaml.Unmarshal(contents, &pkg)
 	if err != nil {
 		err = errors.Wrap(err, "failed to unmarshal pawn.yaml")
@@ -151,8 +284,21 @@ func PackageFromYAML(file string) (pkg Package, err error) {
 }
 
 // WriteDefinition creates a JSON or YAML file for a package object, the format depends
-// on the `Format` field of the package.
+// on the `Format` field of the package. When an existing YAML file is rewritten, its comments and
+// key order are kept.
 func (pkg Package) WriteDefinition() (err error) {
+	return pkg.writeDefinition(true)
+}
+
+// WriteCanonicalDefinition is the same as WriteDefinition except keys are always written in the
+// canonical order, comments in an existing YAML file are still kept.
+func (pkg Package) WriteCanonicalDefinition() (err error) {
+	return pkg.writeDefinition(false)
+}
+
+func (pkg Package) writeDefinition(keepOrder bool) (err error) {
+	pkg.Dependencies = pkg.withoutRequirements()
+
 	switch pkg.Format {
 	case "json":
 		var contents []byte
@@ -170,7 +316,9 @@ func (pkg Package) WriteDefinition() (err error) {
 		if err != nil {
 			return errors.Wrap(err, "failed to encode package metadata")
 		}
-		err = ioutil.WriteFile(filepath.Join(pkg.LocalPath, "pawn.yaml"), contents, 0755)
+		path := filepath.Join(pkg.LocalPath, "pawn.yaml")
+		contents = preserveYAMLFile(path, contents, keepOrder)
+		err = ioutil.WriteFile(path, contents, 0755)
 		if err != nil {
 			return errors.Wrap(err, "failed to write pawn.yaml")
 		}
@@ -183,7 +331,7 @@ func (pkg Package) WriteDefinition() (err error) {
 
 // GetCachedPackage returns a package using the cached copy, if it exists
 func GetCachedPackage(meta versioning.DependencyMeta, cacheDir string) (pkg Package, err error) {
-	path := meta.CachePath(cacheDir)
+	path := meta.PackageDir(meta.CachePath(cacheDir))
 	return PackageFromDir(path)
 }
 
@@ -0,0 +1,202 @@
+package types
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+func TestPackageFromDirRequirements(t *testing.T) {
+	pkg, err := PackageFromDir("./tests/requirements")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []versioning.DependencyString{
+		"sampctl/samp-stdlib",
+		"Southclaws/formatex:1.0.0",
+		"pawn-lang/YSI-Includes@5.x",
+		"Southclaws/samp-logger",
+	}, pkg.Dependencies)
+	assert.Equal(t, []versioning.DependencyString{
+		"pawn-lang/YSI-Includes@5.x",
+		"Southclaws/samp-logger",
+	}, pkg.Requirements)
+	assert.Equal(t, []versioning.DependencyString{
+		"sampctl/samp-stdlib",
+		"Southclaws/formatex:1.0.0",
+	}, pkg.withoutRequirements())
+}
+
+func TestPackageFromDirDeprecated(t *testing.T) {
+	for _, dir := range []string{"./tests/deprecated-json", "./tests/deprecated-yaml"} {
+		pkg, err := PackageFromDir(dir)
+		assert.NoError(t, err, dir)
+		assert.True(t, pkg.Resources[0].Archive, dir)
+	}
+
+	pkg, err := PackageFromDir("./tests/deprecated-json")
+	assert.NoError(t, err)
+	assert.False(t, pkg.Resources[1].Archive)
+}
+
+func TestMigrateDeprecated(t *testing.T) {
+	definition := map[string]interface{}{
+		"resources": []interface{}{
+			map[string]interface{}{"name": "plugin.so"},
+			map[interface{}]interface{}{"name": "plugin.zip", "release": true},
+			map[string]interface{}{"name": "plugin.tar.gz", "release": true, "archive": false},
+		},
+	}
+	assert.Equal(t, []string{
+		"`resources[1].release` is deprecated, rename it to `archive`",
+		"`resources[2].release` is deprecated and ignored since `archive` is also set, remove it",
+	}, migrateDeprecated(definition))
+	assert.Equal(t, map[string]interface{}{
+		"resources": []interface{}{
+			map[string]interface{}{"name": "plugin.so"},
+			map[interface{}]interface{}{"name": "plugin.zip", "archive": true},
+			map[string]interface{}{"name": "plugin.tar.gz", "archive": false},
+		},
+	}, definition)
+}
+
+func TestPackage_mergeRequirements(t *testing.T) {
+	pkg := Package{Dependencies: []versioning.DependencyString{"Southclaws/formatex:1.0.0"}}
+
+	err := pkg.mergeRequirements([]versioning.DependencyString{"Southclaws/formatex:1.1.0"})
+	assert.Error(t, err)
+}
+
+func TestPackage_ValidateProfile(t *testing.T) {
+	pkg := Package{Builds: []*BuildConfig{{Name: "main", Profile: ProfileStrict}}}
+	assert.NoError(t, pkg.Validate())
+
+	pkg.Builds[0].Platforms = map[string]*BuildConfig{"linux": {Profile: "paranoid"}}
+	assert.EqualError(t, pkg.Validate(), "invalid build main: unknown build profile paranoid, must be one of [strict fast release]")
+}
+
+func TestPackage_ValidateIncludePaths(t *testing.T) {
+	pkg := Package{IncludePath: "include", IncludePaths: []string{"modules", "lib/public"}}
+	assert.NoError(t, pkg.Validate())
+
+	pkg.IncludePaths = append(pkg.IncludePaths, "../other")
+	assert.EqualError(t, pkg.Validate(), `include path "../other" must be a directory within the package`)
+}
+
+func TestPackage_ValidateServerConfig(t *testing.T) {
+	pkg := Package{ServerConfig: map[string]string{"streamer_ticks": "50", "plugins": "streamer"}}
+	assert.NoError(t, pkg.Validate())
+
+	pkg.ServerConfig["mysql log"] = "ALL"
+	assert.EqualError(t, pkg.Validate(), `server.cfg setting "mysql log" must be a single word`)
+}
+
+func TestPackage_ValidateResources(t *testing.T) {
+	pkg := Package{Resources: []Resource{
+		{Name: "plugin-.*\\.zip", Platform: "linux", Archive: true, Plugins: []string{"plugin.so"}},
+		{Name: "plugin.dll"},
+	}}
+	assert.NoError(t, pkg.Validate())
+
+	pkg.Resources = append(pkg.Resources,
+		Resource{Name: " ", Platform: "win32"},
+		Resource{Name: "bundle.zip", Archive: true},
+	)
+	assert.EqualError(t, pkg.Validate(), `invalid resources:
+  resource 3 ( ): missing name field
+  resource 3 ( ): unknown platform win32, must be one of [linux windows darwin]
+  resource 4 (bundle.zip): archive declares no includes, plugins, files, globs or compiler to extract`)
+}
+
+func TestWriteDefinitionPreservesYAML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sampctl-yaml")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	original := `# my gamemode
+user: Southclaws
+repo: gamemode
+entry: gamemodes/main.pwn # compiled by CI
+output: gamemodes/main.amx
+
+# libraries
+dependencies:
+# the standard library
+- sampctl/samp-stdlib
+- pawn-lang/YSI-Includes@5.x # hooks
+
+builds:
+# used for releases
+- name: release
+  args: [-d0, -O1]
+`
+	err = ioutil.WriteFile(filepath.Join(dir, "pawn.yaml"), []byte(original), 0600)
+	assert.NoError(t, err)
+
+	pkg, err := PackageFromDir(dir)
+	assert.NoError(t, err)
+	pkg.LocalPath = dir
+	pkg.Dependencies = append(pkg.Dependencies, "Southclaws/formatex")
+	err = pkg.WriteDefinition()
+	assert.NoError(t, err)
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "pawn.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, `# my gamemode
+user: Southclaws
+repo: gamemode
+entry: gamemodes/main.pwn # compiled by CI
+output: gamemodes/main.amx
+
+# libraries
+dependencies:
+# the standard library
+- sampctl/samp-stdlib
+- pawn-lang/YSI-Includes@5.x # hooks
+- Southclaws/formatex
+
+builds:
+# used for releases
+- name: release
+  args:
+  - -d0
+  - -O1
+`, string(contents))
+}
+
+func TestScanYAML(t *testing.T) {
+	document := `a: 1
+b:
+  c: 2 # two
+  d:
+    - x
+    - y: 3
+      z: 4
+e: |
+  text: not a key
+f: "quoted # not a comment"
+`
+	var paths, comments []string
+	scanYAML([]byte(document), func(line, path, comment string) {
+		paths = append(paths, path)
+		comments = append(comments, comment)
+	})
+	assert.Equal(t, []string{
+		"a",
+		"b",
+		"b/c",
+		"b/d",
+		"b/d/-x",
+		"b/d/#1/y",
+		"b/d/#1/z",
+		"e",
+		"e/|2text: not a key",
+		"f",
+	}, paths)
+	assert.Equal(t, "# two", comments[2])
+	assert.Equal(t, "", comments[9])
+}
@@ -35,3 +35,44 @@ type RuntimePackage struct {
 	LinuxPaths    map[string]string `json:"linux_paths"`
 	Win32Paths    map[string]string `json:"win32_paths"`
 }
+
+// -
+// Package index for search and include suggestions
+// -
+
+// Index is a list of packages that can be searched and suggested for the includes of a project
+type Index struct {
+	Packages []IndexEntry `json:"packages"`
+}
+
+// IndexEntry is a package in an index, its includes are regular expressions matched against the
+// path in `#include <...>` directives to suggest the package as a dependency
+type IndexEntry struct {
+	User        string   `json:"user"`
+	Repo        string   `json:"repo"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Includes    []string `json:"includes,omitempty"`
+}
+
+// -
+// Security advisories for auditing dependencies
+// -
+
+// Advisories is a list of known problems with versions of packages
+type Advisories struct {
+	Advisories []Advisory `json:"advisories"`
+}
+
+// Advisory describes versions of a package that have a known problem. Affected versions are either
+// a semantic version constraint or, for packages without version tags, a list of commits.
+type Advisory struct {
+	ID         string   `json:"id"`
+	Dependency string   `json:"dependency"`         // the `user/repo` of the affected package
+	Versions   string   `json:"versions,omitempty"` // version constraint of the affected versions, such as `<1.2.3`
+	Commits    []string `json:"commits,omitempty"`  // affected commits, for packages without version tags
+	Severity   string   `json:"severity"`           // `low`, `medium`, `high` or `critical`
+	Summary    string   `json:"summary"`            // what the problem is
+	Safe       string   `json:"safe,omitempty"`     // the recommended version that doesn't have the problem
+	URL        string   `json:"url,omitempty"`      // where the problem is described in full
+}
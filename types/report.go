@@ -0,0 +1,94 @@
+package types
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// BuildReportSchema is the version of the build report format. Fields may be added without changing
+// it, it only changes if a field is removed or its meaning changes.
+const BuildReportSchema = 1
+
+// BuildReport is a machine-readable record of a single build, written alongside the console output
+// so CI systems can keep it as an artifact and track builds over time
+type BuildReport struct {
+	Schema      int                `json:"schema"`
+	Package     string             `json:"package"`
+	Build       string             `json:"build"`
+	Platform    string             `json:"platform"`
+	Compiler    string             `json:"compiler"`        // the compiler version, or the dependency that provided the compiler
+	Success     bool               `json:"success"`         // whether an output was produced without errors
+	Error       string             `json:"error,omitempty"` // why the build couldn't run, compiler errors are diagnostics instead
+	Started     time.Time          `json:"started"`
+	DurationMS  int64              `json:"duration_ms"`
+	Diagnostics []ReportDiagnostic `json:"diagnostics"`
+	Sizes       *ReportSizes       `json:"sizes,omitempty"` // only set if the compiler reported them
+	Artifacts   []ReportArtifact   `json:"artifacts"`
+}
+
+// ReportDiagnostic is a warning or error reported by the compiler
+type ReportDiagnostic struct {
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	Severity    string `json:"severity"` // `warning`, `error` or `fatal`
+	Description string `json:"description"`
+}
+
+// ReportSizes are the sizes of the sections of the output, in bytes
+type ReportSizes struct {
+	Header    int `json:"header"`
+	Code      int `json:"code"`
+	Data      int `json:"data"`
+	StackHeap int `json:"stack_heap"`
+	Estimate  int `json:"estimate"`
+	Total     int `json:"total"`
+}
+
+// ReportArtifact is a file produced by the build
+type ReportArtifact struct {
+	Path   string `json:"path"` // relative to the package directory, with forward slashes
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// NewBuildReport creates a report from the problems and result of a build
+func NewBuildReport(problems BuildProblems, result BuildResult) (report BuildReport) {
+	report.Schema = BuildReportSchema
+	report.Diagnostics = []ReportDiagnostic{}
+	report.Artifacts = []ReportArtifact{}
+	for _, problem := range problems {
+		report.Diagnostics = append(report.Diagnostics, ReportDiagnostic{
+			File:        problem.File,
+			Line:        problem.Line,
+			Severity:    problem.Severity.String(),
+			Description: problem.Description,
+		})
+	}
+	if result != (BuildResult{}) {
+		report.Sizes = &ReportSizes{
+			Header:    result.Header,
+			Code:      result.Code,
+			Data:      result.Data,
+			StackHeap: result.StackHeap,
+			Estimate:  result.Estimate,
+			Total:     result.Total,
+		}
+	}
+	return
+}
+
+// Write writes the report to a file as indented JSON
+func (report BuildReport) Write(filename string) (err error) {
+	contents, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode build report")
+	}
+	err = ioutil.WriteFile(filename, append(contents, '\n'), 0644)
+	if err != nil {
+		return errors.Wrap(err, "failed to write build report")
+	}
+	return
+}
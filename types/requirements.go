@@ -0,0 +1,107 @@
+package types
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/sampctl/print"
+	"github.com/Southclaws/sampctl/versioning"
+)
+
+// RequirementsFile is the name of the optional line-based dependency list that lives alongside the
+// package definition file. Each line holds a single dependency string, blank lines are ignored and
+// anything following a `#` at the start of a line or after whitespace is treated as a comment.
+const RequirementsFile = "pawn.deps"
+
+// ReadRequirements parses a requirements file into a list of dependency strings
+func ReadRequirements(file string) (deps []versioning.DependencyString, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to open %s", RequirementsFile)
+		return
+	}
+	defer f.Close() // nolint
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		// dependency strings never contain whitespace so the first field is the dependency
+		// and anything after it must be a trailing comment
+		fields := strings.Fields(text)
+		if len(fields) > 1 && !strings.HasPrefix(fields[1], "#") {
+			err = errors.Errorf("%s:%d: unexpected text after dependency string", RequirementsFile, line)
+			return
+		}
+
+		dep := versioning.DependencyString(fields[0])
+		if _, err = dep.Explode(); err != nil {
+			err = errors.Wrapf(err, "%s:%d: invalid dependency string %s", RequirementsFile, line, dep)
+			return
+		}
+
+		deps = append(deps, dep)
+	}
+
+	err = scanner.Err()
+	return
+}
+
+// mergeRequirements adds dependencies from a requirements file to the package dependency list,
+// dependencies declared in both places with different version constraints are reported as errors.
+func (pkg *Package) mergeRequirements(requirements []versioning.DependencyString) (err error) {
+	declared := make(map[string]versioning.DependencyString)
+	for _, dep := range pkg.Dependencies {
+		meta, errInner := dep.Explode()
+		if errInner != nil {
+			continue
+		}
+		declared[meta.User+"/"+meta.Repo] = dep
+	}
+
+	for _, dep := range requirements {
+		meta, _ := dep.Explode() // already validated by ReadRequirements
+		existing, ok := declared[meta.User+"/"+meta.Repo]
+		if ok {
+			if existing != dep {
+				return errors.Errorf("dependency %s in %s conflicts with %s in package definition", dep, RequirementsFile, existing)
+			}
+			print.Verb(pkg, "dependency", dep, "declared in both package definition and", RequirementsFile)
+			continue
+		}
+
+		declared[meta.User+"/"+meta.Repo] = dep
+		pkg.Dependencies = append(pkg.Dependencies, dep)
+		pkg.Requirements = append(pkg.Requirements, dep)
+	}
+
+	return
+}
+
+// withoutRequirements returns the package dependencies that were declared inline in the package
+// definition file, excluding anything that was merged in from a requirements file.
+func (pkg Package) withoutRequirements() (deps []versioning.DependencyString) {
+	if len(pkg.Requirements) == 0 {
+		return pkg.Dependencies
+	}
+
+	fromFile := make(map[versioning.DependencyString]struct{})
+	for _, dep := range pkg.Requirements {
+		fromFile[dep] = struct{}{}
+	}
+	for _, dep := range pkg.Dependencies {
+		if _, ok := fromFile[dep]; !ok {
+			deps = append(deps, dep)
+		}
+	}
+	return
+}
@@ -3,32 +3,95 @@ package types
 import (
 	"crypto/md5" //nolint
 	"fmt"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 )
 
 // Resource represents a resource associated with a package
 type Resource struct {
-	Name     string            `json:"name,omitempty"`     // filename pattern of the resource
-	Platform string            `json:"platform,omitempty"` // target platform, if empty the resource is always used but if this is set and does not match the runtime OS, the resource is ignored
-	Archive  bool              `json:"archive,omitempty"`  // is this resource an archive file or just a single file?
-	Includes []string          `json:"includes,omitempty"` // if archive: paths to directories containing .inc files for the compiler
-	Plugins  []string          `json:"plugins,omitempty"`  // if archive: paths to plugin binaries, either .so or .dll
-	Files    map[string]string `json:"files,omitempty"`    // if archive: path-to-path map of any other files, keys are paths inside the archive and values are extraction paths relative to the sampctl working directory
+	Name      string            `json:"name,omitempty"`      // filename pattern of the resource
+	Platform  string            `json:"platform,omitempty"`  // target platform, if empty the resource is always used but if this is set and does not match the runtime OS, the resource is ignored
+	Archive   bool              `json:"archive,omitempty"`   // is this resource an archive file or just a single file?
+	Includes  []string          `json:"includes,omitempty"`  // if archive: paths to directories containing .inc files for the compiler
+	Plugins   []string          `json:"plugins,omitempty"`   // if archive: paths to plugin binaries, either .so or .dll
+	Files     map[string]string `json:"files,omitempty"`     // if archive: path-to-path map of any other files, keys are paths inside the archive and values are extraction paths relative to the sampctl working directory
+	Modes     map[string]string `json:"modes,omitempty"`     // if archive: octal file mode overrides such as `0755`, keys are the same archive paths used in `plugins` or `files`
+	Globs     []string          `json:"globs,omitempty"`     // if archive: glob patterns such as `data/**/*.json` of other files, these are extracted to `resources/<repo>/` keeping their paths inside the archive
+	Checksums map[string]string `json:"checksums,omitempty"` // if archive: sha256 checksums of plugin binaries, keys are the same archive paths used in `plugins` or `compiler`
+	Compiler  string            `json:"compiler,omitempty"`  // if archive: path to the compiler binary, for packages that provide the compiler of a build, `files` are extracted next to it
+	Servers   []string          `json:"servers,omitempty"`   // SA-MP server versions the plugins are built for, such as `0.3.7` or `0.3.DL`, which also cover their releases like `0.3.7-R2`
 }
 
-// Validate checks for missing fields
+// ResourcePlatforms are the platforms a resource can target, as named by `runtime.GOOS`
+var ResourcePlatforms = []string{"linux", "windows", "darwin"}
+
+// Validate checks that a resource is consistent and reports every problem with it at once
 func (res Resource) Validate() (err error) {
-	if res.Name == "" {
-		return errors.New("missing name field in resource")
+	problems := res.problems()
+	if len(problems) > 0 {
+		return errors.Errorf("invalid resource: %s", strings.Join(problems, ", "))
+	}
+	return
+}
+
+// problems lists what is wrong with a resource: a missing name, an unknown platform or an archive
+// that nothing is extracted from
+func (res Resource) problems() (problems []string) {
+	if strings.TrimSpace(res.Name) == "" {
+		problems = append(problems, "missing name field")
+	}
+	if res.Platform != "" {
+		known := false
+		for _, platform := range ResourcePlatforms {
+			known = known || res.Platform == platform
+		}
+		if !known {
+			problems = append(problems, fmt.Sprintf("unknown platform %s, must be one of %v", res.Platform, ResourcePlatforms))
+		}
 	}
-	if res.Platform == "" {
-		return errors.New("missing platform field in resource")
+	if res.Archive && len(res.Includes) == 0 && len(res.Plugins) == 0 && len(res.Files) == 0 &&
+		len(res.Globs) == 0 && res.Compiler == "" {
+		problems = append(problems, "archive declares no includes, plugins, files, globs or compiler to extract")
 	}
 	return
 }
 
+// Mode returns the file mode override for an archive path, if one was specified
+func (res Resource) Mode(source string) (mode os.FileMode, ok bool, err error) {
+	value, ok := res.Modes[source]
+	if !ok {
+		return
+	}
+	parsed, err := strconv.ParseUint(value, 8, 32)
+	if err != nil {
+		err = errors.Wrapf(err, "invalid file mode '%s' for %s", value, source)
+		return
+	}
+	mode = os.FileMode(parsed).Perm()
+	return
+}
+
+// SupportsServer reports whether the plugins of a resource are built for a SA-MP server version. A
+// declared version covers the releases of that version and the other way around, so `0.3.7` and
+// `0.3.7-R2-2-1` match. Resources that don't declare any versions support every server.
+func (res Resource) SupportsServer(version string) bool {
+	if len(res.Servers) == 0 || version == "" {
+		return true
+	}
+	version = strings.ToLower(version)
+	for _, server := range res.Servers {
+		server = strings.ToLower(server)
+		if server == version || strings.HasPrefix(version, server+"-") || strings.HasPrefix(server, version+"-") {
+			return true
+		}
+	}
+	return false
+}
+
 // Path returns a file path for a resource based on a hash of the label
 // nolint
 func (res Resource) Path(pkg Package) (path string) {
@@ -0,0 +1,30 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResource_SupportsServer(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers []string
+		version string
+		want    bool
+	}{
+		{"undeclared", nil, "0.3.DL", true},
+		{"exact", []string{"0.3.7"}, "0.3.7", true},
+		{"release of declared", []string{"0.3.7"}, "0.3.7-R2-2-1", true},
+		{"declared release", []string{"0.3.7-R2"}, "0.3.7", true},
+		{"case", []string{"0.3.dl"}, "0.3.DL-R1", true},
+		{"one of", []string{"0.3.7", "0.3.DL"}, "0.3.DL", true},
+		{"mismatch", []string{"0.3.7"}, "0.3.DL", false},
+		{"not a prefix", []string{"0.3.7"}, "0.3.70", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Resource{Servers: tt.servers}.SupportsServer(tt.version))
+		})
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"strings"
 
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
@@ -25,11 +27,34 @@ type Runtime struct {
 	PluginDeps []versioning.DependencyMeta `ignore:"1" json:"-" yaml:"-"` // an internal list of remote plugins to download
 	Format     string                      `ignore:"1" json:"-" yaml:"-"` // format stores the original format of the package definition file, either `json` or `yaml`
 
+	// PluginChecksums holds the sha256 of plugin binaries by `user/repo` of the dependency and then
+	// by file name. Checksums present before plugins are ensured are verified, the checksums of
+	// plugins that weren't present are added.
+	PluginChecksums map[string]map[string]string `ignore:"1" json:"-" yaml:"-"`
+
+	// Coverage lists the functions the gamemode was instrumented with when it was built with
+	// coverage markers, so the coverage report includes the ones that were never hit
+	Coverage []CoverageFunction `ignore:"1" json:"-" yaml:"-"`
+
 	// Only used to configure sampctl, not used in server.cfg generation
 	Name    string  `ignore:"1" json:"name,omitempty"     yaml:"name,omitempty"`    // configuration name
-	Version string  `ignore:"1" json:"version,omitempty"  yaml:"version,omitempty"` // runtime version
+	Version string  `ignore:"1" json:"version,omitempty"  yaml:"version,omitempty"` // runtime version, the SA-MP server version that plugin resources are checked against
 	Mode    RunMode `ignore:"1" json:"mode,omitempty"     yaml:"mode,omitempty"`    // the runtime mode
 
+	// Debugger wraps the server process in a debugger or memory checker, only supported on Linux
+	Debugger *Debugger `ignore:"1" json:"debugger,omitempty" yaml:"debugger,omitempty"`
+
+	// Test configures the `headless` run mode, which runs the server as an automated test
+	Test *HeadlessTest `ignore:"1" json:"test,omitempty" yaml:"test,omitempty"`
+
+	// RequiredPlugins lists plugins, by name without an extension, that must be loaded by the server
+	RequiredPlugins []string `ignore:"1" json:"required_plugins,omitempty" yaml:"required_plugins,omitempty"`
+
+	// Instances runs this many copies of the server at once, each in its own copy of the working
+	// directory and on consecutive ports starting at BasePort, which defaults to Port
+	Instances int  `ignore:"1" json:"instances,omitempty" yaml:"instances,omitempty"`
+	BasePort  *int `ignore:"1" json:"base_port,omitempty" yaml:"base_port,omitempty"`
+
 	// Echo - set automatically
 	Echo *string `default:"-" required:"0" json:"echo,omitempty" yaml:"echo,omitempty"`
 
@@ -89,6 +114,36 @@ type ContainerConfig struct {
 	MountCache bool // whether or not to mount the local cache directory inside the container
 }
 
+// Debugger describes a tool such as gdb or valgrind that the server is launched under
+type Debugger struct {
+	Tool string   `json:"tool"           yaml:"tool"`           // the tool binary, such as `gdb` or `valgrind`
+	Args []string `json:"args,omitempty" yaml:"args,omitempty"` // arguments placed before the server binary, gdb and valgrind have defaults
+}
+
+// Command returns the executable and arguments that launch the server binary under the debugger.
+// If no arguments are specified, gdb runs in batch mode and prints a backtrace when the server
+// crashes and valgrind performs a full leak check.
+func (d Debugger) Command(binary string) (name string, args []string) {
+	args = d.Args
+	if len(args) == 0 {
+		switch filepath.Base(d.Tool) {
+		case "gdb":
+			args = []string{"-batch", "-ex", "run", "-ex", "bt", "--args"}
+		case "valgrind":
+			args = []string{"--leak-check=full"}
+		}
+	}
+	return d.Tool, append(append([]string{}, args...), binary)
+}
+
+// HeadlessTest describes how the server output decides whether a `headless` run passes. Patterns
+// are regular expressions matched against each line of output.
+type HeadlessTest struct {
+	Success  []string `json:"success,omitempty"  yaml:"success,omitempty"`  // the run passes once every one of these has appeared
+	Failure  []string `json:"failure,omitempty"  yaml:"failure,omitempty"`  // the run fails as soon as any of these appears
+	Duration string   `json:"duration,omitempty" yaml:"duration,omitempty"` // the longest the server may run, such as `30s`, defaults to one minute
+}
+
 // RunMode represents a method of running the server
 type RunMode string
 
@@ -99,6 +154,9 @@ const (
 	MainOnly RunMode = "main"
 	// YTesting hides preamble and closes the server after y_testing output has finished
 	YTesting RunMode = "y_testing"
+	// Headless hides nothing and stops the server once its output passes or fails the `test` config,
+	// or once the configured duration has passed
+	Headless RunMode = "headless"
 )
 
 // Plugin represents either a plugin name or a dependency-string description of where to get it
@@ -126,6 +184,10 @@ func (cfg Runtime) Validate() (err error) {
 		return errors.New("Mode empty")
 	}
 
+	if cfg.Instances < 0 {
+		return errors.New("Instances negative")
+	}
+
 	return
 }
 
@@ -216,6 +278,43 @@ func (cfg *Runtime) ResolveRemotePlugins() {
 	}
 }
 
+// Overlay returns a copy of the runtime config with every field that is set in another config
+// replacing its own, this is how a build overrides the settings of the runtime config it runs with
+func (cfg Runtime) Overlay(overlay Runtime) (result Runtime) {
+	result = cfg
+	to := reflect.ValueOf(&result).Elem()
+	from := reflect.ValueOf(overlay)
+	for i := 0; i < from.NumField(); i++ {
+		if !from.Field(i).IsZero() {
+			to.Field(i).Set(from.Field(i))
+		}
+	}
+	return
+}
+
+// IsServerCfgField returns true if a server.cfg setting has its own field in the runtime config,
+// such as `maxplayers`, rather than being written from Extra
+func IsServerCfgField(name string) bool {
+	t := reflect.TypeOf(Runtime{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("ignore") != "" || field.Name == "Extra" {
+			continue
+		}
+		key := strings.Split(field.Tag.Get("json"), ",")[0]
+		if real := field.Tag.Get("cfg"); real != "" {
+			key = real
+		}
+		if field.Tag.Get("numbered") != "" && strings.HasPrefix(strings.ToLower(name), key) {
+			return true
+		}
+		if strings.EqualFold(name, key) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetRuntimeDefault returns a default config for temporary runtimes
 func GetRuntimeDefault() (config *Runtime) {
 	return &Runtime{
@@ -306,6 +405,12 @@ func (cfg Runtime) ToJSON() (err error) {
 func (cfg Runtime) ToYAML() (err error) {
 	path := filepath.Join(cfg.WorkingDir, "samp.yaml")
 
+	contents, err := yaml.Marshal(cfg)
+	if err != nil {
+		return
+	}
+	contents = preserveYAMLFile(path, contents, true)
+
 	if util.Exists(path) {
 		if err = os.Remove(path); err != nil {
 			panic(err)
@@ -322,11 +427,6 @@ func (cfg Runtime) ToYAML() (err error) {
 		}
 	}()
 
-	contents, err := yaml.Marshal(cfg)
-	if err != nil {
-		return
-	}
-
 	_, err = fh.Write(contents)
 	return
 }
@@ -264,3 +264,49 @@ rcon: true
 		})
 	}
 }
+
+func TestDebuggerCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		debugger Debugger
+		wantName string
+		wantArgs []string
+	}{
+		{"gdb", Debugger{Tool: "gdb"}, "gdb", []string{"-batch", "-ex", "run", "-ex", "bt", "--args", "/srv/samp03svr"}},
+		{"valgrind", Debugger{Tool: "/usr/bin/valgrind"}, "/usr/bin/valgrind", []string{"--leak-check=full", "/srv/samp03svr"}},
+		{"custom", Debugger{Tool: "gdb", Args: []string{"-ex", "run", "--args"}}, "gdb", []string{"-ex", "run", "--args", "/srv/samp03svr"}},
+		{"unknown", Debugger{Tool: "strace"}, "strace", []string{"/srv/samp03svr"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, args := tt.debugger.Command("/srv/samp03svr")
+			assert.Equal(t, tt.wantName, name)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
+
+func TestRuntimeOverlay(t *testing.T) {
+	port, hostname, debugHostname := 7777, "release", "debug"
+	base := Runtime{Name: "main", Version: "0.3.7", Port: &port, Hostname: &hostname, Plugins: []Plugin{"streamer"}}
+
+	overlaid := base.Overlay(Runtime{Hostname: &debugHostname, Mode: Server, Plugins: []Plugin{"crashdetect"}})
+	assert.Equal(t, Runtime{
+		Name:     "main",
+		Version:  "0.3.7",
+		Mode:     Server,
+		Port:     &port,
+		Hostname: &debugHostname,
+		Plugins:  []Plugin{"crashdetect"},
+	}, overlaid)
+	assert.Equal(t, "release", *base.Hostname)
+}
+
+func TestIsServerCfgField(t *testing.T) {
+	for _, name := range []string{"maxplayers", "MaxPlayers", "rcon_password", "plugins", "gamemode0", "stream_rate"} {
+		assert.True(t, IsServerCfgField(name), name)
+	}
+	for _, name := range []string{"streamer_ticks", "mysql_log", "mode", "version", "extra"} {
+		assert.False(t, IsServerCfgField(name), name)
+	}
+}
@@ -0,0 +1,198 @@
+package types
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/minio/go-homedir"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/Southclaws/sampctl/util"
+)
+
+// SettingsFile is the name of the file in a project directory that overrides the global settings
+// for that project
+const SettingsFile = "sampctl.yaml"
+
+// Settings are defaults for sampctl that would otherwise be repeated in every project or on every
+// command. They are read from the global settings file and the project settings file, and each
+// setting can also be given as a `SAMPCTL_` environment variable. Flags override all of them.
+type Settings struct {
+	GitHubToken         string            `yaml:"github_token,omitempty"`         // GitHub API token
+	GitUsername         string            `yaml:"git_username,omitempty"`         // username for git over HTTPS
+	GitPassword         string            `yaml:"git_password,omitempty"`         // password for git over HTTPS
+	CacheDir            string            `yaml:"cache_dir,omitempty"`            // directory that packages, compilers and runtimes are cached in
+	TempDir             string            `yaml:"temp_dir,omitempty"`             // directory that temporary files are created in instead of the system's
+	CompilerMirrors     []string          `yaml:"compiler_mirrors,omitempty"`     // URLs tried in order before GitHub when downloading a compiler
+	CompilerAttempts    int               `yaml:"compiler_attempts,omitempty"`    // how many times each compiler download source is tried
+	Registry            *RegistryConfig   `yaml:"registry,omitempty"`             // package registry that dependencies without a host resolve through
+	Index               string            `yaml:"index,omitempty"`                // URL or path of the package index that search and include suggestions use
+	CloneProtocol       string            `yaml:"clone_protocol,omitempty"`       // how dependencies are cloned, `https` by default or `ssh`
+	CloneProtocols      map[string]string `yaml:"clone_protocols,omitempty"`      // clone protocols by host, such as `gitlab.com: ssh`, overriding the clone protocol
+	ReadOnlyVendor      *bool             `yaml:"read_only_vendor,omitempty"`     // use the vendor directory as it is, never ensuring or writing to it
+	ResourceConcurrency int               `yaml:"resource_concurrency,omitempty"` // how many resources are downloaded at the same time
+	Flags               map[string]string `yaml:"flags,omitempty"`                // defaults for command flags by name, such as `timeout: 10m`
+}
+
+// GlobalSettingsPath returns the path of the global settings file, `sampctl/config.yaml` within
+// `$XDG_CONFIG_HOME` or `~/.config` if that's not set
+func GlobalSettingsPath() (path string, err error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		var home string
+		home, err = homedir.Dir()
+		if err != nil {
+			return "", errors.Wrap(err, "failed to get home directory")
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "sampctl", "config.yaml"), nil
+}
+
+// LoadSettings reads the global settings, then the settings of the project in dir and then the
+// environment variables, each overriding the settings before it
+func LoadSettings(dir string) (settings Settings, err error) {
+	global, err := GlobalSettingsPath()
+	if err != nil {
+		return
+	}
+	for _, path := range []string{global, filepath.Join(dir, SettingsFile)} {
+		var layer Settings
+		layer, err = SettingsFromFile(path)
+		if err != nil {
+			return
+		}
+		settings.Merge(layer)
+	}
+
+	env, err := SettingsFromEnv()
+	if err != nil {
+		return
+	}
+	settings.Merge(env)
+	return
+}
+
+// SettingsFromFile reads settings from a YAML file, a file that doesn't exist has no settings
+func SettingsFromFile(path string) (settings Settings, err error) {
+	if !util.Exists(path) {
+		return
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return settings, errors.Wrapf(err, "failed to read settings file %s", path)
+	}
+	err = yaml.Unmarshal(contents, &settings)
+	if err != nil {
+		return settings, errors.Wrapf(err, "failed to parse settings file %s", path)
+	}
+	return
+}
+
+// SettingsFromEnv reads settings from environment variables named after the setting, such as
+// `SAMPCTL_GITHUB_TOKEN`. Lists are separated by commas and flags are read by `FlagFromEnv`.
+func SettingsFromEnv() (settings Settings, err error) {
+	settings.GitHubToken = os.Getenv("SAMPCTL_GITHUB_TOKEN")
+	settings.GitUsername = os.Getenv("SAMPCTL_GIT_USERNAME")
+	settings.GitPassword = os.Getenv("SAMPCTL_GIT_PASSWORD")
+	settings.CacheDir = os.Getenv("SAMPCTL_CACHE_DIR")
+	settings.TempDir = os.Getenv("SAMPCTL_TEMP_DIR")
+	if mirrors := os.Getenv("SAMPCTL_COMPILER_MIRRORS"); mirrors != "" {
+		settings.CompilerMirrors = strings.Split(mirrors, ",")
+	}
+	if attempts := os.Getenv("SAMPCTL_COMPILER_ATTEMPTS"); attempts != "" {
+		settings.CompilerAttempts, err = strconv.Atoi(attempts)
+		if err != nil {
+			return settings, errors.Wrap(err, "SAMPCTL_COMPILER_ATTEMPTS is not a number")
+		}
+	}
+	settings.Index = os.Getenv("SAMPCTL_INDEX")
+	settings.CloneProtocol = os.Getenv("SAMPCTL_CLONE_PROTOCOL")
+	if concurrency := os.Getenv("SAMPCTL_RESOURCE_CONCURRENCY"); concurrency != "" {
+		settings.ResourceConcurrency, err = strconv.Atoi(concurrency)
+		if err != nil {
+			return settings, errors.Wrap(err, "SAMPCTL_RESOURCE_CONCURRENCY is not a number")
+		}
+	}
+	if value := os.Getenv("SAMPCTL_READ_ONLY_VENDOR"); value != "" {
+		var readOnly bool
+		readOnly, err = strconv.ParseBool(value)
+		if err != nil {
+			return settings, errors.Wrap(err, "SAMPCTL_READ_ONLY_VENDOR is not a boolean")
+		}
+		settings.ReadOnlyVendor = &readOnly
+	}
+	if url := os.Getenv("SAMPCTL_REGISTRY_URL"); url != "" {
+		settings.Registry = &RegistryConfig{
+			URL:      url,
+			Token:    os.Getenv("SAMPCTL_REGISTRY_TOKEN"),
+			Username: os.Getenv("SAMPCTL_REGISTRY_USERNAME"),
+			Password: os.Getenv("SAMPCTL_REGISTRY_PASSWORD"),
+		}
+	}
+	return
+}
+
+// FlagFromEnv returns the value of the environment variable for a command flag, the flag name in
+// upper case with dashes as underscores and a `SAMPCTL_` prefix, such as `SAMPCTL_TIMEOUT`
+func FlagFromEnv(name string) (value string, ok bool) {
+	value = os.Getenv("SAMPCTL_" + strings.ToUpper(strings.Replace(name, "-", "_", -1)))
+	return value, value != ""
+}
+
+// Merge overrides settings with those that are set in another set of settings, flag defaults are
+// overridden one by one
+func (settings *Settings) Merge(other Settings) {
+	if other.GitHubToken != "" {
+		settings.GitHubToken = other.GitHubToken
+	}
+	if other.GitUsername != "" {
+		settings.GitUsername = other.GitUsername
+	}
+	if other.GitPassword != "" {
+		settings.GitPassword = other.GitPassword
+	}
+	if other.CacheDir != "" {
+		settings.CacheDir = other.CacheDir
+	}
+	if other.TempDir != "" {
+		settings.TempDir = other.TempDir
+	}
+	if len(other.CompilerMirrors) > 0 {
+		settings.CompilerMirrors = other.CompilerMirrors
+	}
+	if other.CompilerAttempts != 0 {
+		settings.CompilerAttempts = other.CompilerAttempts
+	}
+	if other.Registry != nil {
+		settings.Registry = other.Registry
+	}
+	if other.Index != "" {
+		settings.Index = other.Index
+	}
+	if other.CloneProtocol != "" {
+		settings.CloneProtocol = other.CloneProtocol
+	}
+	for host, protocol := range other.CloneProtocols {
+		if settings.CloneProtocols == nil {
+			settings.CloneProtocols = make(map[string]string)
+		}
+		settings.CloneProtocols[host] = protocol
+	}
+	if other.ReadOnlyVendor != nil {
+		settings.ReadOnlyVendor = other.ReadOnlyVendor
+	}
+	if other.ResourceConcurrency != 0 {
+		settings.ResourceConcurrency = other.ResourceConcurrency
+	}
+	for name, value := range other.Flags {
+		if settings.Flags == nil {
+			settings.Flags = make(map[string]string)
+		}
+		settings.Flags[name] = value
+	}
+}
@@ -0,0 +1,105 @@
+package types
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "settings")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	config := filepath.Join(dir, "config")
+	project := filepath.Join(dir, "project")
+	assert.NoError(t, os.MkdirAll(filepath.Join(config, "sampctl"), 0700))
+	assert.NoError(t, os.MkdirAll(project, 0700))
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(config, "sampctl", "config.yaml"), []byte(`
+github_token: global
+cache_dir: /cache
+temp_dir: /tmp/global
+compiler_mirrors:
+  - https://mirror
+clone_protocols:
+  github.com: ssh
+  gitlab.com: ssh
+flags:
+  timeout: 10m
+  stale: ensure
+`), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(project, SettingsFile), []byte(`
+github_token: project
+clone_protocol: ssh
+clone_protocols:
+  github.com: https
+read_only_vendor: true
+resource_concurrency: 2
+flags:
+  stale: ignore
+`), 0600))
+
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME")) // nolint
+	os.Setenv("XDG_CONFIG_HOME", config)                             // nolint
+	defer os.Unsetenv("SAMPCTL_CACHE_DIR")                           // nolint
+	os.Setenv("SAMPCTL_CACHE_DIR", "/env")                           // nolint
+	defer os.Unsetenv("SAMPCTL_STALE")                               // nolint
+	os.Setenv("SAMPCTL_STALE", "error")                              // nolint
+
+	settings, err := LoadSettings(project)
+	assert.NoError(t, err)
+	assert.Equal(t, Settings{
+		GitHubToken:         "project",
+		CacheDir:            "/env",
+		TempDir:             "/tmp/global",
+		CompilerMirrors:     []string{"https://mirror"},
+		CloneProtocol:       "ssh",
+		CloneProtocols:      map[string]string{"github.com": "https", "gitlab.com": "ssh"},
+		ReadOnlyVendor:      &[]bool{true}[0],
+		ResourceConcurrency: 2,
+		Flags:               map[string]string{"timeout": "10m", "stale": "ignore"},
+	}, settings)
+
+	value, ok := FlagFromEnv("stale")
+	assert.True(t, ok)
+	assert.Equal(t, "error", value)
+	_, ok = FlagFromEnv("dry-run")
+	assert.False(t, ok)
+
+	// a project without settings only has the global and environment settings
+	settings, err = LoadSettings(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "global", settings.GitHubToken)
+	assert.Nil(t, settings.ReadOnlyVendor)
+
+	defer os.Unsetenv("SAMPCTL_READ_ONLY_VENDOR") // nolint
+	os.Setenv("SAMPCTL_READ_ONLY_VENDOR", "1")    // nolint
+	settings, err = LoadSettings(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, &[]bool{true}[0], settings.ReadOnlyVendor)
+
+	// an explicit false overrides a true from the settings before it
+	os.Setenv("SAMPCTL_READ_ONLY_VENDOR", "false") // nolint
+	settings, err = LoadSettings(project)
+	assert.NoError(t, err)
+	assert.Equal(t, &[]bool{false}[0], settings.ReadOnlyVendor)
+
+	os.Setenv("SAMPCTL_READ_ONLY_VENDOR", "maybe") // nolint
+	_, err = LoadSettings(dir)
+	assert.Error(t, err)
+}
+
+func TestSettings_Merge(t *testing.T) {
+	enabled, disabled := true, false
+
+	settings := Settings{ReadOnlyVendor: &enabled}
+	settings.Merge(Settings{})
+	assert.Equal(t, &enabled, settings.ReadOnlyVendor, "an unset setting keeps the one before it")
+
+	settings.Merge(Settings{ReadOnlyVendor: &disabled})
+	assert.Equal(t, &disabled, settings.ReadOnlyVendor, "an explicit false overrides it")
+}
@@ -0,0 +1,9 @@
+package types
+
+// Transform is a find and replace applied to the files of a vendored dependency, for patching
+// upstream includes that don't work as they are, such as ones that contain absolute paths
+type Transform struct {
+	Files   string `json:"files,omitempty" yaml:"files,omitempty"` // glob pattern of the files to transform within the repository, by default every .inc file
+	Find    string `json:"find" yaml:"find"`                       // regular expression to find
+	Replace string `json:"replace" yaml:"replace"`                 // replacement text, `$1` and `${name}` refer to groups of the expression
+}
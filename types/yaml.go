@@ -0,0 +1,313 @@
+package types
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/Southclaws/sampctl/print"
+)
+
+// preserveYAMLFile keeps the comments, and optionally the key order, of an existing YAML file when
+// it's rewritten with new contents. If the file doesn't exist or can't be parsed, the new contents
+// are used as-is.
+func preserveYAMLFile(path string, contents []byte, keepOrder bool) []byte {
+	original, err := ioutil.ReadFile(path)
+	if err != nil {
+		return contents
+	}
+	preserved, err := preserveYAML(original, contents, keepOrder)
+	if err != nil {
+		print.Verb("not preserving comments of", path, err)
+		return contents
+	}
+	return preserved
+}
+
+// preserveYAML rewrites freshly encoded YAML so it keeps the comments of the file it is replacing.
+// Comments are re-attached to the key or list item they were written above or beside, comments
+// belonging to something that no longer exists are dropped. If `keepOrder` is set, keys from the
+// original are also kept in their original order with any new, non-empty keys after them.
+func preserveYAML(original, updated []byte, keepOrder bool) (result []byte, err error) {
+	if keepOrder {
+		var before, after yaml.MapSlice
+		err = yaml.Unmarshal(original, &before)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse original YAML")
+		}
+		err = yaml.Unmarshal(updated, &after)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse updated YAML")
+		}
+
+		updated, err = yaml.Marshal(orderLike(after, before))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to encode reordered YAML")
+		}
+	}
+
+	return attachComments(updated, collectComments(original)), nil
+}
+
+// orderLike orders the keys of every map in `value` the same way as the corresponding map in `like`
+func orderLike(value, like interface{}) interface{} {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		l, ok := like.(yaml.MapSlice)
+		if !ok {
+			return v
+		}
+		index := make(map[interface{}]int)
+		for i, item := range v {
+			index[item.Key] = i
+		}
+		used := make([]bool, len(v))
+		result := make(yaml.MapSlice, 0, len(v))
+		for _, item := range l {
+			i, ok := index[item.Key]
+			if !ok {
+				continue
+			}
+			used[i] = true
+			result = append(result, yaml.MapItem{Key: v[i].Key, Value: orderLike(v[i].Value, item.Value)})
+		}
+		for i, item := range v {
+			// empty values decode the same as missing ones, so don't add them to a hand-written file
+			if !used[i] && !emptyYAML(item.Value) {
+				result = append(result, item)
+			}
+		}
+		return result
+
+	case []interface{}:
+		l, ok := like.([]interface{})
+		if !ok {
+			return v
+		}
+		result := make([]interface{}, len(v))
+		for i := range v {
+			if i < len(l) {
+				result[i] = orderLike(v[i], l[i])
+			} else {
+				result[i] = v[i]
+			}
+		}
+		return result
+	}
+	return value
+}
+
+func emptyYAML(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case int:
+		return v == 0
+	case float64:
+		return v == 0
+	case []interface{}:
+		return len(v) == 0
+	case yaml.MapSlice:
+		return len(v) == 0
+	}
+	return false
+}
+
+// yamlComments holds the comments of a YAML document keyed by the path of the line they belong to
+type yamlComments struct {
+	head   map[string][]string // comment and blank lines above a line
+	inline map[string]string   // a comment at the end of a line
+	foot   []string            // comment lines after the last line
+}
+
+func collectComments(document []byte) (comments yamlComments) {
+	comments.head = make(map[string][]string)
+	comments.inline = make(map[string]string)
+
+	var pending []string
+	scanYAML(document, func(line, path, comment string) {
+		if path == "" {
+			pending = append(pending, line)
+			return
+		}
+		if len(pending) > 0 {
+			comments.head[path] = pending
+			pending = nil
+		}
+		if comment != "" {
+			comments.inline[path] = comment
+		}
+	})
+
+	// blank lines at the end of the file aren't worth keeping
+	for len(pending) > 0 && strings.TrimSpace(pending[len(pending)-1]) == "" {
+		pending = pending[:len(pending)-1]
+	}
+	comments.foot = pending
+	return
+}
+
+func attachComments(document []byte, comments yamlComments) []byte {
+	var buf bytes.Buffer
+	used := make(map[string]bool)
+	scanYAML(document, func(line, path, comment string) {
+		if path == "" {
+			buf.WriteString(line + "\n")
+			return
+		}
+		if !used[path] {
+			used[path] = true
+			for _, head := range comments.head[path] {
+				buf.WriteString(head + "\n")
+			}
+			if inline, ok := comments.inline[path]; ok && comment == "" {
+				line += " " + inline
+			}
+		}
+		buf.WriteString(line + "\n")
+	})
+	for _, foot := range comments.foot {
+		buf.WriteString(foot + "\n")
+	}
+	return buf.Bytes()
+}
+
+type yamlFrame struct {
+	indent int
+	key    string
+	item   bool
+	items  int
+}
+
+// scanYAML calls fn for each line of a block style YAML document with a path identifying the line,
+// such as `build/args` or `dependencies/-pawn-lang/samp-stdlib`, and its trailing comment. Lines
+// that are blank or only a comment have an empty path. Maps in lists are identified by position and
+// scalars in lists by value so comments follow a list entry if others are added or removed.
+func scanYAML(document []byte, fn func(line, path, comment string)) {
+	var (
+		stack = []yamlFrame{{indent: -1}}
+		block = -1 // the indentation of the key that started a block scalar, if inside one
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(document))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		if block >= 0 {
+			if trimmed == "" || indent > block {
+				fn(line, pathOf(stack)+"/|"+strconv.Itoa(indent)+trimmed, "")
+				continue
+			}
+			block = -1
+		}
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			fn(line, "", "")
+			continue
+		}
+
+		content, comment := splitComment(trimmed)
+		var path string
+
+		if content == "-" || strings.HasPrefix(content, "- ") {
+			// list items belong to the closest key with the same or less indentation
+			for len(stack) > 1 {
+				top := stack[len(stack)-1]
+				if top.indent > indent || (top.item && top.indent >= indent) {
+					stack = stack[:len(stack)-1]
+					continue
+				}
+				break
+			}
+			parent := &stack[len(stack)-1]
+			rest := strings.TrimSpace(strings.TrimPrefix(content, "-"))
+			if key, _, isKey := splitKey(rest); isKey {
+				stack = append(stack, yamlFrame{indent: indent, key: "#" + strconv.Itoa(parent.items), item: true})
+				parent.items++
+				stack = append(stack, yamlFrame{indent: indent + len(content) - len(rest), key: key})
+			} else {
+				parent.items++
+				stack = append(stack, yamlFrame{indent: indent, key: "-" + rest, item: true})
+			}
+			path = pathOf(stack)
+		} else {
+			for len(stack) > 1 && stack[len(stack)-1].indent >= indent {
+				stack = stack[:len(stack)-1]
+			}
+			key, _, isKey := splitKey(content)
+			if !isKey {
+				key = "=" + content // a continuation of a plain scalar, or something unusual
+			}
+			stack = append(stack, yamlFrame{indent: indent, key: key})
+			path = pathOf(stack)
+		}
+
+		if _, value, isKey := splitKey(strings.TrimSpace(strings.TrimPrefix(content, "-"))); isKey {
+			if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+				block = indent
+			}
+		}
+
+		fn(line, path, comment)
+	}
+}
+
+func pathOf(stack []yamlFrame) string {
+	keys := make([]string, 0, len(stack))
+	for _, frame := range stack[1:] {
+		keys = append(keys, frame.key)
+	}
+	return strings.Join(keys, "/")
+}
+
+// splitKey splits `key: value` into its parts, `ok` is false if the text isn't a mapping entry
+func splitKey(text string) (key, value string, ok bool) {
+	quote := byte(0)
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case i == 0 && (c == '"' || c == '\''):
+			quote = c
+		case c == ':' && (i == len(text)-1 || text[i+1] == ' '):
+			return strings.Trim(text[:i], `"'`), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// splitComment separates a trailing `# comment` from a line, ignoring `#` inside quoted strings
+func splitComment(text string) (content, comment string) {
+	quote := byte(0)
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || text[i-1] == ' ' || text[i-1] == ':' {
+				quote = c
+			}
+		case c == '#' && i > 0 && (text[i-1] == ' ' || text[i-1] == '\t'):
+			return strings.TrimSpace(text[:i]), text[i:]
+		}
+	}
+	return text, ""
+}
@@ -108,3 +108,34 @@ func DirEmpty(path string) bool {
 	_, err = f.Readdirnames(1)
 	return err == io.EOF
 }
+
+// tempDirVariables are the environment variables that the temporary directory is read from, `TMPDIR`
+// on Unix and `TMP` or `TEMP` on Windows
+var tempDirVariables = []string{"TMPDIR", "TMP", "TEMP"}
+
+// SetTempDir makes a directory the one that temporary files are created in, both by sampctl and by
+// the commands it runs, creating it if it doesn't exist
+func SetTempDir(dir string) (err error) {
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return
+	}
+	for _, name := range tempDirVariables {
+		err = os.Setenv(name, dir)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// TempDirEnv returns the environment variables that point to the temporary directory, for commands
+// that are run with an environment of their own rather than that of sampctl
+func TempDirEnv() (env []string) {
+	for _, name := range tempDirVariables {
+		if value := os.Getenv(name); value != "" {
+			env = append(env, name+"="+value)
+		}
+	}
+	return
+}
@@ -1,7 +1,9 @@
 package util
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -41,3 +43,20 @@ func TestCopyFile(t *testing.T) {
 		})
 	}
 }
+
+func TestSetTempDir(t *testing.T) {
+	for _, name := range tempDirVariables {
+		defer os.Setenv(name, os.Getenv(name)) // nolint
+	}
+
+	dir := FullPath("./tests/temp")
+	os.RemoveAll(dir)
+	assert.NoError(t, SetTempDir(dir))
+	assert.True(t, Exists(dir))
+	assert.Equal(t, dir, os.TempDir())
+	assert.Equal(t, []string{"TMPDIR=" + dir, "TMP=" + dir, "TEMP=" + dir}, TempDirEnv())
+
+	tmp, err := ioutil.TempDir("", "sampctl")
+	assert.NoError(t, err)
+	assert.Equal(t, dir, filepath.Dir(tmp))
+}
@@ -21,6 +21,10 @@ type VersionedTag struct {
 	Ref     *plumbing.Reference
 	Name    string
 	Version *semver.Version
+
+	// Prerelease is set for a version whose GitHub release is marked as a prerelease, a version with a
+	// prerelease part is a prerelease either way
+	Prerelease bool
 }
 
 // VersionedTags is just for implementing the Sort interface
@@ -53,7 +57,7 @@ func RefFromTag(repo *git.Repository, meta DependencyMeta) (ref *plumbing.Refere
 	}
 
 	if constraintErr != nil || len(versionedTags) == 0 {
-		print.Verb(meta, "specified version or repo tags not semantic versions", constraintErr)
+		print.Debug(meta, "specified version or repo tags not semantic versions", constraintErr)
 
 		var tags storer.ReferenceIter
 		tags, err = repo.Tags()
@@ -86,17 +90,17 @@ func RefFromTag(repo *git.Repository, meta DependencyMeta) (ref *plumbing.Refere
 			err = errors.Errorf("failed to satisfy constraint, '%s' not in %v", meta.Tag, tagList)
 		}
 	} else {
-		print.Verb(meta, "specified version and repo tags are semantic versions")
+		print.Debug(meta, "specified version and repo tags are semantic versions")
 
 		sort.Sort(sort.Reverse(versionedTags))
 
 		for _, version := range versionedTags {
 			if !constraint.Check(version.Version) {
-				print.Verb(meta, "incompatible tag", version.Name, "does not satisfy constraint", meta.Tag)
+				print.Debug(meta, "incompatible tag", version.Name, "does not satisfy constraint", meta.Tag)
 				continue
 			}
 
-			print.Verb(meta, "discovered tag", version.Version, "that matches constraint", meta.Tag)
+			print.Debug(meta, "discovered tag", version.Version, "that matches constraint", meta.Tag)
 			ref = version.Ref
 			break
 		}
@@ -109,6 +113,42 @@ func RefFromTag(repo *git.Repository, meta DependencyMeta) (ref *plumbing.Refere
 	return
 }
 
+// MinimalRefFromTag returns the ref of the lowest tagged version that satisfies every one of the
+// version constraints, this is the version that minimal version selection resolves to
+func MinimalRefFromTag(repo *git.Repository, constraints []string) (ref *plumbing.Reference, err error) {
+	var parsed []*semver.Constraints
+	for _, constraint := range constraints {
+		var c *semver.Constraints
+		c, err = semver.NewConstraint(constraint)
+		if err != nil {
+			return nil, errors.Wrapf(err, "'%s' is not a semantic version constraint", constraint)
+		}
+		parsed = append(parsed, c)
+	}
+
+	versionedTags, err := GetRepoSemverTags(repo)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get repo tags")
+	}
+	sort.Sort(versionedTags)
+
+	for _, version := range versionedTags {
+		satisfied := true
+		for _, c := range parsed {
+			if !c.Check(version.Version) {
+				satisfied = false
+				break
+			}
+		}
+		if satisfied {
+			print.Debug("discovered tag", version.Version, "as the lowest that matches", constraints)
+			return version.Ref, nil
+		}
+	}
+
+	return nil, errors.Errorf("failed to satisfy all of the constraints %v, none of %v match", constraints, versionedTags)
+}
+
 // RefFromBranch returns a ref from a branch name
 func RefFromBranch(repo *git.Repository, meta DependencyMeta) (ref *plumbing.Reference, err error) {
 	branches, err := repo.Branches()
@@ -122,7 +162,7 @@ func RefFromBranch(repo *git.Repository, meta DependencyMeta) (ref *plumbing.Ref
 	err = branches.ForEach(func(pr *plumbing.Reference) error {
 		branch := pr.Name().Short()
 
-		print.Verb(meta, "checking branch", branch)
+		print.Debug(meta, "checking branch", branch)
 		if branch == meta.Branch {
 			ref = pr
 			return storer.ErrStop
@@ -152,9 +192,9 @@ func RefFromCommit(repo *git.Repository, meta DependencyMeta) (ref *plumbing.Ref
 	err = commits.ForEach(func(commit *object.Commit) error {
 		hash := commit.Hash.String()
 
-		print.Verb(meta, "checking commit", hash, "<>", meta.Commit)
+		print.Debug(meta, "checking commit", hash, "<>", meta.Commit)
 		if hash == meta.Commit {
-			print.Verb(meta, "match found")
+			print.Debug(meta, "match found")
 			ref = plumbing.NewHashReference(plumbing.ReferenceName(hash), commit.Hash)
 			return storer.ErrStop
 		}
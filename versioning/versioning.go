@@ -3,8 +3,10 @@ package versioning
 import (
 	"fmt"
 	"net/url"
+	"path"
 	"path/filepath"
 	"regexp"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -14,7 +16,7 @@ type DependencyString string
 
 // DependencyMeta represents all the individual components of a DependencyString
 type DependencyMeta struct {
-	Site   string `json:"site,omitempty"`                           // The site the repo exists on, default is github.com
+	Site   string `json:"site,omitempty" yaml:"site,omitempty"`     // The site the repo exists on, default is github.com
 	User   string `json:"user"`                                     // Repository owner
 	Repo   string `json:"repo"`                                     // Repository name
 	Path   string `json:"path,omitempty" yaml:"path,omitempty"`     // Optional subdirectory for .inc files
@@ -22,6 +24,22 @@ type DependencyMeta struct {
 	Branch string `json:"branch,omitempty" yaml:"branch,omitempty"` // Target branch
 	Commit string `json:"commit,omitempty" yaml:"commit,omitempty"` // Target commit sha
 	SSH    string `json:"ssh,omitempty" yaml:"ssh,omitempty"`       // SSH user (usually 'git')
+
+	// Package is an optional subdirectory of the repository that the package is in, for repositories
+	// that contain several packages. Its own package definition is read for dependencies and includes.
+	Package string `json:"package,omitempty" yaml:"package,omitempty"`
+
+	// Namespace is an optional include namespace, when set the includes of the dependency are only
+	// available to the compiler as `<namespace/repo/file>` instead of `<file>`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+
+	// Alias is an optional name the dependency is vendored and included under instead of its
+	// repository name, used to resolve collisions between dependencies with the same name
+	Alias string `json:"alias,omitempty" yaml:"alias,omitempty"`
+
+	// DefaultBranch is an optional branch that is used when there is no tag, branch or commit
+	// constraint, when it's empty the default branch of the repository is looked up instead
+	DefaultBranch string `json:"default_branch,omitempty" yaml:"default_branch,omitempty"`
 }
 
 func (dm DependencyMeta) String() string {
@@ -30,14 +48,38 @@ func (dm DependencyMeta) String() string {
 		site = dm.Site + "/"
 	}
 
+	var pkg string
+	if dm.Package != "" {
+		pkg = "//" + dm.Package
+	}
+
 	if dm.Tag != "" {
-		return fmt.Sprintf("%s%s/%s:%s", site, dm.User, dm.Repo, dm.Tag)
+		return fmt.Sprintf("%s%s/%s%s:%s", site, dm.User, dm.Repo, pkg, dm.Tag)
 	} else if dm.Branch != "" {
-		return fmt.Sprintf("%s%s/%s@%s", site, dm.User, dm.Repo, dm.Branch)
+		return fmt.Sprintf("%s%s/%s%s@%s", site, dm.User, dm.Repo, pkg, dm.Branch)
 	} else if dm.Commit != "" {
-		return fmt.Sprintf("%s%s/%s#%s", site, dm.User, dm.Repo, dm.Commit)
+		return fmt.Sprintf("%s%s/%s%s#%s", site, dm.User, dm.Repo, pkg, dm.Commit)
+	}
+	return fmt.Sprintf("%s%s/%s%s", site, dm.User, dm.Repo, pkg)
+}
+
+// VendorName returns the name of the directory the dependency is vendored to, this is the alias if
+// one is set or the repository name otherwise. A package in a subdirectory of a repository is
+// vendored under the repository name followed by the subdirectory, so that several packages from the
+// same repository can be vendored at once.
+func (dm DependencyMeta) VendorName() string {
+	if dm.Alias != "" {
+		return dm.Alias
+	}
+	if dm.Package != "" {
+		return dm.Repo + "-" + strings.Replace(dm.Package, "/", "-", -1)
 	}
-	return fmt.Sprintf("%s%s/%s", site, dm.User, dm.Repo)
+	return dm.Repo
+}
+
+// PackageDir returns the directory of the package within a copy of its repository at root
+func (dm DependencyMeta) PackageDir(root string) string {
+	return filepath.Join(root, filepath.FromSlash(dm.Package))
 }
 
 // CachePath returns the path from the cache to a cached package
@@ -59,12 +101,21 @@ func (dm DependencyMeta) Validate() (err error) {
 	if dm.Repo == "" {
 		return errors.New("dependency meta missing repo")
 	}
+	if dm.Package != "" {
+		clean := path.Clean(dm.Package)
+		if clean != dm.Package || clean == ".." || strings.HasPrefix(clean, "../") {
+			return errors.Errorf("dependency package directory %s must be a clean path within the repository", dm.Package)
+		}
+	}
 	return
 }
 
+// DefaultSite is the site of dependency strings that don't specify one
+const DefaultSite = "github.com"
+
 var (
 	// MatchGitSSH matches ssh URLs such as 'git@github.com:Southclaws/sampctl'
-	MatchGitSSH = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9_]+)\@((?:[a-zA-Z][a-zA-Z0-9\-]*\.)*[a-zA-Z][a-zA-Z0-9\-]*)\:((?:[A-Za-z0-9_\-\.]+\/?)*)$`)
+	MatchGitSSH = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9_]+)\@((?:[a-zA-Z][a-zA-Z0-9\-]*\.)*[a-zA-Z][a-zA-Z0-9\-]*)\:((?:[A-Za-z0-9_\-\.]+\/{0,2})*)$`)
 	// MatchDependencyString matches a dependency string such as 'Username/Repository:tag', 'Username/Repository@branch', 'Username/Repository#commit'
 	MatchDependencyString = regexp.MustCompile(`^\/?([a-zA-Z0-9-]+)\/([a-zA-Z0-9-._]+)(?:\/)?([a-zA-Z0-9-_$\[\]{}().,\/]*)?((?:@)|(?:\:)|(?:#))?(.+)?$`)
 )
@@ -92,7 +143,17 @@ var (
 //   http://github.com/user/repo/includes:1.2.3
 //   github.com/user/repo/includes:1.2.3
 //   user/repo/includes:1.2.3
+//
+// A repository that contains several packages in subdirectories can have one of them addressed with
+// a double slash, the package is read from that subdirectory instead of the repository root.
+//   user/repo//packages/logger:1.2.3
 func (d DependencyString) Explode() (dep DependencyMeta, err error) {
+	return d.ExplodeSite(DefaultSite)
+}
+
+// ExplodeSite is Explode with another site for dependency strings that don't specify one, such as
+// the host of a package registry
+func (d DependencyString) ExplodeSite(site string) (dep DependencyMeta, err error) {
 	u, err := url.Parse(string(d))
 	if err == nil {
 
@@ -115,9 +176,9 @@ func (d DependencyString) Explode() (dep DependencyMeta, err error) {
 		}
 	}
 
-	// default to github
+	// default to github, or the given site
 	if dep.Site == "" {
-		dep.Site = "github.com"
+		dep.Site = site
 	}
 
 	if err == nil {
@@ -161,6 +222,13 @@ func explodePath(d string) (dep DependencyMeta, err error) {
 	dep.Repo = captures[2]
 	dep.Path = captures[3]
 
+	// a path starting with a slash followed the repository with a double slash, so it's the
+	// subdirectory of a package rather than an include path
+	if strings.HasPrefix(dep.Path, "/") {
+		dep.Package = strings.Trim(dep.Path, "/")
+		dep.Path = ""
+	}
+
 	if len(captures[4]) == 1 && len(captures[5]) > 0 {
 		switch captures[4][0] {
 		case ':':
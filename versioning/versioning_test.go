@@ -1,6 +1,7 @@
 package versioning
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,6 +18,7 @@ func TestDependencyMeta_String(t *testing.T) {
 		{"s/u/r:t", DependencyMeta{Site: "github.com", User: "user", Repo: "repo", Tag: "1.2.3"}, "github.com/user/repo:1.2.3"},
 		{"s/u/r@b", DependencyMeta{Site: "github.com", User: "user", Repo: "repo", Branch: "dev"}, "github.com/user/repo@dev"},
 		{"s/u/r#c", DependencyMeta{Site: "github.com", User: "user", Repo: "repo", Commit: "123abc"}, "github.com/user/repo#123abc"},
+		{"u/r//p:t", DependencyMeta{User: "user", Repo: "repo", Package: "packages/logger", Tag: "1.2.3"}, "user/repo//packages/logger:1.2.3"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -74,6 +76,12 @@ func TestDependencyString_Explode(t *testing.T) {
 		{"v u ssh url", DependencyString("git@gitlab.com:user/repo.name"), DependencyMeta{Site: "gitlab.com", User: "user", Repo: "repo.name", SSH: "git"}, false},
 		{"v u ssh url path", DependencyString("git@gitlab.com:user/repo.name/inc/path"), DependencyMeta{Site: "gitlab.com", User: "user", Repo: "repo.name", Path: "inc/path", SSH: "git"}, false},
 
+		// Package subdirectories
+		{"v t user/repo package", DependencyString("user/repo//packages/logger:1.2.3"), DependencyMeta{Site: "github.com", User: "user", Repo: "repo", Package: "packages/logger", Tag: "1.2.3"}, false},
+		{"v b https url package", DependencyString("https://github.com/user/repo//logger@dev"), DependencyMeta{Site: "github.com", User: "user", Repo: "repo", Package: "logger", Branch: "dev"}, false},
+		{"v u ssh url package", DependencyString("git@gitlab.com:user/repo//packages/logger"), DependencyMeta{Site: "gitlab.com", User: "user", Repo: "repo", Package: "packages/logger", SSH: "git"}, false},
+		{"i u user/repo package outside", DependencyString("user/repo//../logger"), DependencyMeta{}, true},
+
 		// Invalid
 		{"i u user", DependencyString("http://github.com/repo"), DependencyMeta{}, true},
 		{"i u project", DependencyString("project"), DependencyMeta{}, true},
@@ -94,3 +102,15 @@ func TestDependencyString_Explode(t *testing.T) {
 		})
 	}
 }
+
+func TestDependencyMeta_Package(t *testing.T) {
+	meta := DependencyMeta{User: "user", Repo: "monorepo", Package: "packages/logger"}
+	assert.Equal(t, "monorepo-packages-logger", meta.VendorName())
+	assert.Equal(t, filepath.Join("vendor", "monorepo", "packages", "logger"), meta.PackageDir(filepath.Join("vendor", "monorepo")))
+
+	meta.Alias = "logger"
+	assert.Equal(t, "logger", meta.VendorName())
+
+	meta.Package = ""
+	assert.Equal(t, filepath.Join("vendor", "monorepo"), meta.PackageDir(filepath.Join("vendor", "monorepo")))
+}